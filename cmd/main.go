@@ -40,10 +40,18 @@ import (
 	"github.com/denisAlshanov/stPlaner/internal/api/router"
 	"github.com/denisAlshanov/stPlaner/internal/config"
 	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/services/audit"
+	"github.com/denisAlshanov/stPlaner/internal/services/avatar"
 	"github.com/denisAlshanov/stPlaner/internal/services/auth"
 	"github.com/denisAlshanov/stPlaner/internal/services/downloader"
+	"github.com/denisAlshanov/stPlaner/internal/services/geoip"
+	"github.com/denisAlshanov/stPlaner/internal/services/invitation"
+	"github.com/denisAlshanov/stPlaner/internal/services/mail"
+	"github.com/denisAlshanov/stPlaner/internal/services/quota"
+	"github.com/denisAlshanov/stPlaner/internal/services/secrets"
 	"github.com/denisAlshanov/stPlaner/internal/services/storage"
 	"github.com/denisAlshanov/stPlaner/internal/services/telegram"
+	"github.com/denisAlshanov/stPlaner/internal/services/uploader"
 	"github.com/denisAlshanov/stPlaner/internal/services/youtube"
 	"github.com/denisAlshanov/stPlaner/internal/utils"
 )
@@ -67,6 +75,23 @@ func main() {
 		logger.Fatalf("Failed to connect to PostgreSQL: %v", err)
 	}
 
+	// Encrypt stream keys and Zoom passcodes at rest
+	keyProvider, err := secrets.NewKeyProvider(&cfg.Secrets)
+	if err != nil {
+		logger.Fatalf("Failed to initialize secrets key provider: %v", err)
+	}
+	db.SetSecretCipher(secrets.NewCipher(keyProvider))
+
+	// Refuse to serve until no show or event secret is left in plain text. Instances
+	// starting together wait for each other rather than failing.
+	encrypted, err := db.EncryptPlaintextSecrets(context.Background())
+	if err != nil {
+		logger.Fatalf("Failed to encrypt existing secrets: %v", err)
+	}
+	if encrypted > 0 {
+		logger.Infof("Encrypted secrets of %d existing shows and events", encrypted)
+	}
+
 	// Initialize S3 storage
 	s3Storage, err := storage.NewStorage(&cfg.S3)
 	if err != nil {
@@ -92,8 +117,23 @@ func main() {
 	youtubeClient := youtube.NewClient()
 	logger.Info("YouTube client initialized")
 
+	// Initialize media quota service
+	quotaService := quota.NewService(db, &cfg.Download)
+
 	// Initialize downloader service
-	downloaderService := downloader.NewDownloader(db, s3Storage, telegramClient, youtubeClient, &cfg.Download)
+	downloaderService := downloader.NewDownloader(db, s3Storage, telegramClient, youtubeClient, quotaService, &cfg.Download)
+
+	// Initialize uploader service (requires multipart support from storage)
+	multipartStorage, ok := s3Storage.(storage.MultipartStorageInterface)
+	if !ok {
+		logger.Fatalf("Storage backend does not support multipart uploads")
+	}
+	uploaderService := uploader.NewUploader(db, multipartStorage, quotaService, &cfg.Upload, cfg.Download.MaxFileSize)
+
+	// Periodically expire abandoned uploads
+	cleanupCtx, cancelCleanup := context.WithCancel(context.Background())
+	defer cancelCleanup()
+	go uploaderService.StartCleanup(cleanupCtx, time.Hour)
 
 	// Initialize authentication services
 	jwtConfig := auth.JWTConfig{
@@ -101,34 +141,137 @@ func main() {
 		AccessTokenDuration:  15 * time.Minute,
 		RefreshTokenDuration: 7 * 24 * time.Hour, // 7 days
 		Issuer:               "stPlaner",
+		AcceptLegacyHS256:    cfg.API.JWTAcceptLegacyHS256,
+	}
+
+	// Load the JWT signing keys; rotated keys keep verifying until every refresh token they signed has expired
+	keyRing, err := auth.NewKeyRing(db, auth.KeyRingConfig{
+		Algorithm:        cfg.API.JWTSigningAlgorithm,
+		RotationInterval: cfg.API.JWTKeyRotationInterval,
+		Retention:        jwtConfig.RefreshTokenDuration,
+	})
+	if err != nil {
+		logger.Fatalf("Invalid JWT key ring configuration: %v", err)
+	}
+	if err := keyRing.Load(context.Background()); err != nil {
+		logger.Fatalf("Failed to load JWT signing keys: %v", err)
 	}
+	go keyRing.StartRotation(cleanupCtx, 5*time.Minute)
 	
-	jwtService := auth.NewJWTService(jwtConfig)
+	jwtService := auth.NewJWTService(jwtConfig, keyRing)
 	sessionService := auth.NewSessionService(db, jwtService)
+	sessionService.OnLoginAlert(auth.LogLoginAlert)
+	if cfg.API.GeoIPDatabase != "" {
+		geoDB, err := geoip.Open(cfg.API.GeoIPDatabase)
+		if err != nil {
+			logger.Fatalf("Failed to load GeoIP database: %v", err)
+		}
+		sessionService.SetGeoLocator(geoDB)
+		logger.Infof("Loaded %d GeoIP ranges", geoDB.Len())
+	}
 	
-	// Initialize Google OIDC service
-	googleConfig := auth.GoogleOIDCConfig{
-		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURI:  os.Getenv("GOOGLE_REDIRECT_URI"),
-		Scopes:       []string{"openid", "email", "profile"},
+	// Initialize OpenID Connect providers
+	var oidcProviders []*auth.OIDCProvider
+	for _, providerConfig := range cfg.OIDC.Providers {
+		provider, err := auth.NewOIDCProvider(auth.OIDCProviderConfig{
+			Name:         providerConfig.Name,
+			DisplayName:  providerConfig.DisplayName,
+			Issuer:       providerConfig.Issuer,
+			ClientID:     providerConfig.ClientID,
+			ClientSecret: providerConfig.ClientSecret,
+			RedirectURI:  providerConfig.RedirectURI,
+			Scopes:       providerConfig.Scopes,
+			RoleClaim:    providerConfig.RoleClaim,
+			RoleMapping:  providerConfig.RoleMapping,
+			DefaultRole:  providerConfig.DefaultRole,
+			AllowSignup:  providerConfig.AllowSignup,
+			TrustEmail:   providerConfig.TrustEmail,
+		}, nil)
+		if err != nil {
+			logger.Fatalf("Invalid OIDC provider configuration: %v", err)
+		}
+		oidcProviders = append(oidcProviders, provider)
+	}
+
+	// Initialize two-factor authentication service
+	mfaService := auth.NewMFAService(auth.MFAConfig{
+		Issuer:       cfg.API.MFAIssuer,
+		ChallengeTTL: cfg.API.MFAChallengeTTL,
+	}, db)
+
+	oidcService, err := auth.NewOIDCService(oidcProviders, db, sessionService, mfaService)
+	if err != nil {
+		logger.Fatalf("Invalid OIDC provider configuration: %v", err)
+	}
+
+	// Initialize brute-force protection for password logins
+	loginGuard := auth.NewLoginGuard(db, auth.LoginGuardConfig{
+		AccountMaxFailures: cfg.API.LoginMaxFailures,
+		IPMaxFailures:      cfg.API.LoginIPMaxFailures,
+		Window:             cfg.API.LoginFailureWindow,
+		LockoutDuration:    cfg.API.LoginLockoutDuration,
+		MaxLockoutDuration: cfg.API.LoginMaxLockout,
+		DelayBase:          time.Second,
+		MaxDelay:           30 * time.Second,
+	})
+	loginGuard.OnLockout(auth.LogLockoutEvent)
+	go loginGuard.StartCleanup(cleanupCtx, time.Hour)
+
+	// Audit log, written by database triggers; entries past the retention period are purged
+	auditService := audit.NewService(db, &cfg.Audit)
+	go auditService.StartRetention(cleanupCtx, time.Hour)
+
+	// Initialize mail delivery and self-service account recovery
+	mailSender, err := mail.NewSender(&cfg.Mail)
+	if err != nil {
+		logger.Fatalf("Failed to initialize mail sender: %v", err)
+	}
+	accountService := auth.NewAccountService(auth.AccountConfig{
+		AppBaseURL:           cfg.Mail.AppBaseURL,
+		PasswordResetTTL:     cfg.Mail.PasswordResetTTL,
+		EmailVerificationTTL: cfg.Mail.EmailVerificationTTL,
+	}, db, sessionService, mailSender)
+	// Guest invitations with signed RSVP links and reminders before the event
+	rsvpSigner, err := invitation.NewSigner(cfg.Invitation.SigningKey)
+	if err != nil {
+		logger.Fatalf("Invalid INVITATION_SIGNING_KEY: %v", err)
 	}
-	googleService := auth.NewGoogleOIDCService(googleConfig, db, sessionService)
+	guestNotifier, err := invitation.NewNotifier(cfg.Invitation.Notifier, mailSender)
+	if err != nil {
+		logger.Fatalf("Failed to initialize guest notifier: %v", err)
+	}
+	invitationService := invitation.NewService(db, rsvpSigner, guestNotifier, &cfg.Invitation, cfg.Mail.AppBaseURL)
+	go invitationService.StartReminders(cleanupCtx, 5*time.Minute)
+
+	// Guest avatars are kept in the media storage and can be pulled from Telegram profiles
+	avatarService := avatar.NewService(s3Storage, telegramClient, &cfg.Avatar)
+
+	apiTokenService := auth.NewAPITokenService(auth.APITokenConfig{
+		DefaultTTL: cfg.API.APITokenDefaultTTL,
+		MaxTTL:     cfg.API.APITokenMaxTTL,
+	}, db)
 
 	// Initialize handlers
 	postHandler := handlers.NewPostHandler(db, downloaderService)
 	mediaHandler := handlers.NewMediaHandler(db, s3Storage, telegramClient, youtubeClient)
+	uploadHandler := handlers.NewUploadHandler(uploaderService)
+	collectionHandler := handlers.NewCollectionHandler(db)
+	shareHandler := handlers.NewShareHandler(db, mediaHandler, &cfg.Share)
+	quotaHandler := handlers.NewQuotaHandler(db, quotaService)
+	teamHandler := handlers.NewTeamHandler(db)
 	healthHandler := handlers.NewHealthHandler(db, s3Storage)
 	showHandler := handlers.NewShowHandler(db)
 	eventHandler := handlers.NewEventHandler(db)
-	guestHandler := handlers.NewGuestHandler(db)
+	guestHandler := handlers.NewGuestHandler(db, avatarService)
 	blockHandler := handlers.NewBlockHandler(db)
-	userHandler := handlers.NewUserHandler(db)
+	invitationHandler := handlers.NewInvitationHandler(db, invitationService)
+	userHandler := handlers.NewUserHandler(db, loginGuard, accountService)
 	roleHandler := handlers.NewRoleHandler(db)
-	authHandler := handlers.NewAuthHandlers(db, jwtService, sessionService, googleService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	authHandler := handlers.NewAuthHandlers(db, jwtService, sessionService, oidcService, mfaService, loginGuard, accountService, apiTokenService)
 
 	// Initialize router
-	r := router.NewRouter(cfg, postHandler, mediaHandler, healthHandler, showHandler, eventHandler, guestHandler, blockHandler, userHandler, roleHandler, authHandler, jwtService, sessionService)
+	r := router.NewRouter(cfg, postHandler, mediaHandler, uploadHandler, collectionHandler, shareHandler, quotaHandler, teamHandler, healthHandler, showHandler, eventHandler, guestHandler, blockHandler, invitationHandler, userHandler, roleHandler, auditHandler, authHandler, jwtService, sessionService, apiTokenService)
 
 	// Start server
 	go func() {
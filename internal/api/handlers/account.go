@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/auth"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a single-use password reset link. The response is the same whether or not the address belongs to an account.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 202 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/password/forgot [post]
+func (h *AuthHandlers) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.RequestPasswordReset(c, req.Email); err != nil {
+		utils.LogError(c, "Failed to request password reset", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "PASSWORD_RESET_ERROR",
+			Message: "Failed to request password reset",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.AccountMessageResponse{
+		Success: true,
+		Message: "If the address belongs to an account, a reset link is on its way",
+	})
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with the token from the reset email. All sessions of the user are revoked.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/password/reset [post]
+func (h *AuthHandlers) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := utils.ValidatePasswordComplexity(req.NewPassword, utils.DefaultPasswordComplexity()); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_PASSWORD",
+			Message: "Invalid password",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResetPassword(c, req.Token, req.NewPassword); err != nil {
+		if errors.Is(err, auth.ErrAccountTokenInvalid) {
+			c.JSON(http.StatusBadRequest, models.APIError{
+				Error:   "INVALID_RESET_TOKEN",
+				Message: "Reset link is invalid or expired",
+			})
+			return
+		}
+
+		utils.LogError(c, "Failed to reset password", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "PASSWORD_RESET_ERROR",
+			Message: "Failed to reset password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountMessageResponse{
+		Success: true,
+		Message: "Password reset, please log in with the new password",
+	})
+}
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm an email address with the token from the verification email. Pending users are activated.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/email/verify [post]
+func (h *AuthHandlers) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.accountService.VerifyEmail(c, req.Token); err != nil {
+		if errors.Is(err, auth.ErrAccountTokenInvalid) {
+			c.JSON(http.StatusBadRequest, models.APIError{
+				Error:   "INVALID_VERIFICATION_TOKEN",
+				Message: "Verification link is invalid or expired",
+			})
+			return
+		}
+
+		utils.LogError(c, "Failed to verify email", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "EMAIL_VERIFICATION_ERROR",
+			Message: "Failed to verify email address",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountMessageResponse{
+		Success: true,
+		Message: "Email address verified",
+	})
+}
+
+// ResendVerification godoc
+// @Summary Resend the verification email
+// @Description Email a new verification link to an unverified address. The response is the same whether or not the address belongs to an account.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResendVerificationRequest true "Account email"
+// @Success 202 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/email/resend [post]
+func (h *AuthHandlers) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.accountService.ResendVerification(c, req.Email); err != nil {
+		utils.LogError(c, "Failed to resend verification email", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "EMAIL_VERIFICATION_ERROR",
+			Message: "Failed to send verification email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.AccountMessageResponse{
+		Success: true,
+		Message: "If the address needs verification, a new link is on its way",
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/auth"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// ListAPITokens godoc
+// @Summary List personal API tokens
+// @Description List the active personal API tokens of the current user. Token values are never returned again after creation.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APITokenListResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/tokens [get]
+func (h *AuthHandlers) ListAPITokens(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.apiTokenService.List(c, userID)
+	if err != nil {
+		h.apiTokenErrorResponse(c, "Failed to list API tokens", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APITokenListResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// CreateAPIToken godoc
+// @Summary Create a personal API token
+// @Description Create a long-lived token for scripts and integrations. It is sent as a Bearer token and can only use the requested scopes, at least one, which must be permissions the user holds. Each endpoint needs the scope of its resource and action, such as guests:read for reading guests or blocks:delete for deleting blocks. The token value is only returned in this response.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateAPITokenRequest true "Token details"
+// @Success 201 {object} models.CreateAPITokenResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/tokens [post]
+func (h *AuthHandlers) CreateAPIToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid API token request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	token, value, err := h.apiTokenService.Create(c, userID, &req)
+	if err != nil {
+		h.apiTokenErrorResponse(c, "Failed to create API token", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateAPITokenResponse{
+		Success: true,
+		Data: &models.CreatedAPITokenData{
+			APIToken: *token,
+			Token:    value,
+		},
+	})
+}
+
+// RevokeAPIToken godoc
+// @Summary Revoke a personal API token
+// @Description Revoke a personal API token of the current user; requests using it are rejected immediately
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param token_id path string true "Token ID"
+// @Success 200 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/tokens/{token_id} [delete]
+func (h *AuthHandlers) RevokeAPIToken(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(c.Param("token_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_TOKEN_ID",
+			Message: "Invalid token ID format",
+		})
+		return
+	}
+
+	if err := h.apiTokenService.Revoke(c, userID, tokenID); err != nil {
+		h.apiTokenErrorResponse(c, "Failed to revoke API token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountMessageResponse{
+		Success: true,
+		Message: "API token revoked",
+	})
+}
+
+// apiTokenErrorResponse maps API token service errors to API errors
+func (h *AuthHandlers) apiTokenErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrAPITokenScope):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "SCOPE_NOT_GRANTED",
+			Message: "A requested scope is not one of your permissions",
+			Details: err.Error(),
+		})
+	case errors.Is(err, auth.ErrAPITokenNoScopes):
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "SCOPES_REQUIRED",
+			Message: "An API token needs at least one scope",
+		})
+	case errors.Is(err, auth.ErrAPITokenExpiry):
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_EXPIRY",
+			Message: "Invalid token expiry",
+			Details: err.Error(),
+		})
+	case errors.Is(err, auth.ErrAPITokenUnavailable):
+		c.JSON(http.StatusConflict, models.APIError{
+			Error:   "API_TOKEN_UNAVAILABLE",
+			Message: "A token with this name already exists or the token limit is reached",
+		})
+	case errors.Is(err, auth.ErrAPITokenInvalid):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "ACCOUNT_INACTIVE",
+			Message: "User account is not active",
+		})
+	case errors.Is(err, auth.ErrAPITokenNotFound):
+		c.JSON(http.StatusNotFound, models.APIError{
+			Error:   "API_TOKEN_NOT_FOUND",
+			Message: "API token not found",
+		})
+	default:
+		utils.LogError(c, message, err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "INTERNAL_ERROR",
+			Message: message,
+		})
+	}
+}
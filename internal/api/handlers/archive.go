@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const archiveManifestName = "manifest.json"
+
+// archiveFile is a single media file written into a ZIP archive
+type archiveFile struct {
+	path  string
+	media models.Media
+}
+
+// DownloadArchive godoc
+// @Summary Download media as a ZIP archive
+// @Description Stream a ZIP archive with all media of a post (content_id) or all block media of an event (event_id). Event media is ordered by block and prefixed with the block position and title. The archive contains a manifest.json with titles and descriptions.
+// @Tags media
+// @Accept json
+// @Produce application/zip
+// @Param request body models.MediaArchiveRequest true "Post or event to archive"
+// @Success 200 {file} binary "ZIP archive"
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/archive [post]
+// @Security BearerAuth
+func (h *MediaHandler) DownloadArchive(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.MediaArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	if (req.ContentID == "") == (req.EventID == "") {
+		h.errorResponse(c, utils.NewValidationError("Exactly one of content_id or event_id is required", nil))
+		return
+	}
+
+	var (
+		archiveName string
+		files       []archiveFile
+		manifest    *models.MediaArchiveManifest
+		ok          bool
+	)
+	if req.ContentID != "" {
+		archiveName, files, manifest, ok = h.collectPostArchive(c, req.ContentID)
+	} else {
+		archiveName, files, manifest, ok = h.collectEventArchive(c, req.EventID)
+	}
+	if !ok {
+		return
+	}
+
+	if len(files) == 0 {
+		h.errorResponse(c, utils.NewNotFoundError("No media found to archive"))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", archiveName))
+	c.Status(http.StatusOK)
+
+	// The archive is written straight to the response; once the first byte is
+	// out, failures can only be logged and the connection cut short.
+	zw := zip.NewWriter(c.Writer)
+
+	manifestWriter, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     archiveManifestName,
+		Method:   zip.Deflate,
+		Modified: manifest.GeneratedAt,
+	})
+	if err == nil {
+		encoder := json.NewEncoder(manifestWriter)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to write archive manifest", err)
+		c.Abort()
+		return
+	}
+
+	var written int64
+	for _, file := range files {
+		// Media is already compressed, so entries are stored as-is
+		entryWriter, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.path,
+			Method:   zip.Store,
+			Modified: file.media.DownloadedAt,
+		})
+		if err != nil {
+			utils.LogError(ctx, "Failed to create archive entry", err, utils.Fields{
+				"media_id": file.media.MediaID,
+			})
+			c.Abort()
+			return
+		}
+
+		n, err := h.copyMediaToArchive(c, entryWriter, &file.media)
+		written += n
+		if err != nil {
+			utils.LogError(ctx, "Failed to stream media into archive", err, utils.Fields{
+				"media_id":      file.media.MediaID,
+				"bytes_written": written,
+			})
+			c.Abort()
+			return
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		utils.LogError(ctx, "Failed to finalize archive", err)
+		c.Abort()
+		return
+	}
+
+	utils.LogInfo(ctx, "Successfully streamed media archive", utils.Fields{
+		"archive_name":  archiveName,
+		"file_count":    len(files),
+		"bytes_written": written,
+	})
+}
+
+func (h *MediaHandler) copyMediaToArchive(c *gin.Context, w io.Writer, media *models.Media) (int64, error) {
+	reader, err := h.storage.Download(c.Request.Context(), media.S3Key)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	return io.Copy(w, reader)
+}
+
+// collectPostArchive gathers the media of a post in download order
+func (h *MediaHandler) collectPostArchive(c *gin.Context, contentID string) (string, []archiveFile, *models.MediaArchiveManifest, bool) {
+	ctx := c.Request.Context()
+
+	post, ok := h.getVisiblePost(c, contentID)
+	if !ok {
+		return "", nil, nil, false
+	}
+
+	mediaFiles, err := h.db.GetMediaByContentID(ctx, contentID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to find media", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return "", nil, nil, false
+	}
+
+	manifest := &models.MediaArchiveManifest{
+		ContentID:   &post.ContentID,
+		GeneratedAt: time.Now().UTC(),
+		Files:       make([]models.MediaArchiveManifestEntry, 0, len(mediaFiles)),
+	}
+
+	used := make(map[string]bool)
+	files := make([]archiveFile, 0, len(mediaFiles))
+	for i, media := range mediaFiles {
+		path := uniqueArchivePath(used, fmt.Sprintf("%02d_%s", i+1, archiveSafeName(media.FileName)))
+		files = append(files, archiveFile{path: path, media: media})
+
+		manifest.Files = append(manifest.Files, models.MediaArchiveManifestEntry{
+			Path:        path,
+			MediaID:     media.MediaID,
+			FileName:    media.FileName,
+			FileType:    media.FileType,
+			FileSize:    media.FileSize,
+			Title:       metadataString(media.Metadata, "title"),
+			Description: metadataString(media.Metadata, "description"),
+			OrderIndex:  i,
+		})
+	}
+
+	return archiveSafeName(post.ContentID) + ".zip", files, manifest, true
+}
+
+// collectEventArchive gathers the block media of an event in rundown order
+func (h *MediaHandler) collectEventArchive(c *gin.Context, eventIDStr string) (string, []archiveFile, *models.MediaArchiveManifest, bool) {
+	ctx := c.Request.Context()
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return "", nil, nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return "", nil, nil, false
+	}
+
+	eventID, err := uuid.Parse(eventIDStr)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid event ID format", map[string]interface{}{
+			"event_id": eventIDStr,
+		}))
+		return "", nil, nil, false
+	}
+	event, err := h.db.GetEventByID(ctx, eventID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get event", err, utils.Fields{
+			"event_id": eventID,
+		})
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return "", nil, nil, false
+	}
+	if event == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Event not found"))
+		return "", nil, nil, false
+	}
+	if event.UserID != userUUID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return "", nil, nil, false
+	}
+
+	items, err := h.db.GetEventArchiveMedia(ctx, eventID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get event media", err, utils.Fields{
+			"event_id": eventID,
+		})
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return "", nil, nil, false
+	}
+
+	manifest := &models.MediaArchiveManifest{
+		EventID:     &event.ID,
+		Title:       event.EventTitle,
+		GeneratedAt: time.Now().UTC(),
+		Files:       make([]models.MediaArchiveManifestEntry, 0, len(items)),
+	}
+
+	used := make(map[string]bool)
+	files := make([]archiveFile, 0, len(items))
+	blockPosition := 0
+	var currentBlock uuid.UUID
+	for _, item := range items {
+		if item.BlockID != currentBlock {
+			currentBlock = item.BlockID
+			blockPosition++
+		}
+
+		item := item
+		name := fmt.Sprintf("%02d_%s_%s", blockPosition, archiveSafeName(item.BlockTitle), archiveSafeName(item.Media.FileName))
+		path := uniqueArchivePath(used, name)
+		files = append(files, archiveFile{path: path, media: item.Media})
+
+		title := item.Title
+		if title == nil {
+			title = metadataString(item.Media.Metadata, "title")
+		}
+		manifest.Files = append(manifest.Files, models.MediaArchiveManifestEntry{
+			Path:        path,
+			MediaID:     item.Media.MediaID,
+			FileName:    item.Media.FileName,
+			FileType:    item.Media.FileType,
+			FileSize:    item.Media.FileSize,
+			Title:       title,
+			Description: item.Description,
+			BlockID:     &item.BlockID,
+			BlockTitle:  &item.BlockTitle,
+			OrderIndex:  item.OrderIndex,
+		})
+	}
+
+	archiveName := "event_" + event.ID.String() + ".zip"
+	if event.EventTitle != nil && strings.TrimSpace(*event.EventTitle) != "" {
+		archiveName = archiveSafeName(*event.EventTitle) + ".zip"
+	}
+
+	return archiveName, files, manifest, true
+}
+
+// archiveSafeName strips characters that are not allowed or are ambiguous in
+// ZIP entry names and Content-Disposition headers
+func archiveSafeName(name string) string {
+	name = strings.TrimSpace(name)
+	replacer := strings.NewReplacer(
+		"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
+		"\"", "_", "<", "_", ">", "_", "|", "_", "\n", " ", "\r", " ", "\t", " ",
+	)
+	name = replacer.Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "untitled"
+	}
+	if runes := []rune(name); len(runes) > 120 {
+		name = string(runes[:120])
+	}
+	return name
+}
+
+// uniqueArchivePath appends a counter before the extension when two entries
+// would otherwise end up with the same name
+func uniqueArchivePath(used map[string]bool, path string) string {
+	candidate := path
+	ext := ""
+	base := path
+	if idx := strings.LastIndex(path, "."); idx > 0 {
+		base, ext = path[:idx], path[idx:]
+	}
+	for i := 2; used[candidate] || candidate == archiveManifestName; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func metadataString(metadata map[string]interface{}, key string) *string {
+	value, ok := metadata[key].(string)
+	if !ok || value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/audit"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// AuditHandler handles audit log HTTP requests
+type AuditHandler struct {
+	auditService *audit.Service
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(auditService *audit.Service) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListAuditLog handles GET /api/v1/audit
+// @Summary Query the audit log
+// @Description List recorded changes to shows, events, blocks, guests, users, roles and media, newest first. Updates only include the changed fields; secrets such as stream keys and password hashes are shown as "[redacted]". Requires the audit:read permission.
+// @Tags audit
+// @Produce json
+// @Param entity_type query string false "Entity type (show, event, block, block_guest, guest, user, user_role, role, media)"
+// @Param entity_id query string false "Entity ID"
+// @Param actor_id query string false "ID of the user who made the change"
+// @Param action query string false "Action (create, update, delete, reveal)"
+// @Param correlation_id query string false "Correlation ID of the request that made the change"
+// @Param from query string false "Only changes at or after this time (RFC 3339)"
+// @Param to query string false "Only changes before this time (RFC 3339)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Success 200 {object} models.AuditLogListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/audit [get]
+func (h *AuditHandler) ListAuditLog(c *gin.Context) {
+	if !hasPermission(c, models.PermissionAuditRead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	page := 1
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 200 {
+			limit = l
+		}
+	}
+
+	filters := &models.AuditLogFilters{
+		EntityType:    c.Query("entity_type"),
+		EntityID:      c.Query("entity_id"),
+		Action:        c.Query("action"),
+		CorrelationID: c.Query("correlation_id"),
+	}
+
+	if actorStr := c.Query("actor_id"); actorStr != "" {
+		actorID, err := uuid.Parse(actorStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor ID format"})
+			return
+		}
+		filters.ActorUserID = &actorID
+	}
+
+	for param, target := range map[string]**time.Time{"from": &filters.From, "to": &filters.To} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " time, use RFC 3339"})
+			return
+		}
+		*target = &parsed
+	}
+
+	pagination := &models.PaginationOptions{
+		Page:  page,
+		Limit: limit,
+	}
+
+	entries, total, err := h.auditService.List(c.Request.Context(), filters, pagination)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to list audit log", err, utils.Fields{
+			"filters": filters,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AuditLogListResponse{
+		Success: true,
+		Data: &models.AuditLogListData{
+			Entries: entries,
+			Pagination: &models.PaginationResponse{
+				Page:       page,
+				Limit:      limit,
+				Total:      total,
+				TotalPages: (total + limit - 1) / limit,
+			},
+		},
+	})
+}
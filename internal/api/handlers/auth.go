@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -15,18 +18,26 @@ import (
 )
 
 type AuthHandlers struct {
-	db             *database.PostgresDB
-	jwtService     *auth.JWTService
-	sessionService *auth.SessionService
-	googleService  *auth.GoogleOIDCService
+	db              *database.PostgresDB
+	jwtService      *auth.JWTService
+	sessionService  *auth.SessionService
+	oidcService     *auth.OIDCService
+	mfaService      *auth.MFAService
+	loginGuard      *auth.LoginGuard
+	accountService  *auth.AccountService
+	apiTokenService *auth.APITokenService
 }
 
-func NewAuthHandlers(db *database.PostgresDB, jwtService *auth.JWTService, sessionService *auth.SessionService, googleService *auth.GoogleOIDCService) *AuthHandlers {
+func NewAuthHandlers(db *database.PostgresDB, jwtService *auth.JWTService, sessionService *auth.SessionService, oidcService *auth.OIDCService, mfaService *auth.MFAService, loginGuard *auth.LoginGuard, accountService *auth.AccountService, apiTokenService *auth.APITokenService) *AuthHandlers {
 	return &AuthHandlers{
-		db:             db,
-		jwtService:     jwtService,
-		sessionService: sessionService,
-		googleService:  googleService,
+		db:              db,
+		jwtService:      jwtService,
+		sessionService:  sessionService,
+		oidcService:     oidcService,
+		mfaService:      mfaService,
+		loginGuard:      loginGuard,
+		accountService:  accountService,
+		apiTokenService: apiTokenService,
 	}
 }
 
@@ -38,8 +49,10 @@ func NewAuthHandlers(db *database.PostgresDB, jwtService *auth.JWTService, sessi
 // @Produce json
 // @Param request body models.LoginRequest true "Login credentials"
 // @Success 200 {object} models.LoginResponse
+// @Success 202 {object} models.MFAChallengeResponse "Second factor required, complete with /auth/mfa/verify"
 // @Failure 400 {object} models.APIError
 // @Failure 401 {object} models.APIError
+// @Failure 423 {object} models.APIError "Account temporarily locked after too many failed logins"
 // @Failure 429 {object} models.APIError
 // @Failure 500 {object} models.APIError
 // @Router /api/v1/auth/login [post]
@@ -66,6 +79,21 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse the attempt while the account or IP is slowed down or locked
+	block, err := h.loginGuard.Check(c, req.Email, c.ClientIP())
+	if err != nil {
+		utils.LogError(c, "Failed to check login throttle", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "DATABASE_ERROR",
+			Message: "Failed to authenticate user",
+		})
+		return
+	}
+	if block != nil {
+		loginBlockedResponse(c, block)
+		return
+	}
+
 	// Get user by email
 	user, err := h.db.GetUserByEmail(c, req.Email)
 	if err != nil {
@@ -78,6 +106,7 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 	}
 
 	if user == nil {
+		h.recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, models.APIError{
 			Error:   "INVALID_CREDENTIALS",
 			Message: "Invalid email or password",
@@ -96,6 +125,7 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 
 	// Verify password
 	if err := utils.VerifyPassword(req.Password, *user.PasswordHash); err != nil {
+		h.recordLoginFailure(c, req.Email)
 		c.JSON(http.StatusUnauthorized, models.APIError{
 			Error:   "INVALID_CREDENTIALS",
 			Message: "Invalid email or password",
@@ -103,6 +133,30 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 		return
 	}
 
+	if err := h.loginGuard.RecordSuccess(c, req.Email); err != nil {
+		utils.LogError(c, "Failed to clear login failures", err)
+		// Don't fail the login for this
+	}
+
+	// Ask for the second factor if the user enabled MFA or a role requires it
+	challenge, err := h.mfaService.BeginLogin(c, user.ID)
+	if err != nil {
+		utils.LogError(c, "Failed to start MFA challenge", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "MFA_ERROR",
+			Message: "Failed to authenticate user",
+		})
+		return
+	}
+	if challenge != nil {
+		utils.LogInfo(c, fmt.Sprintf("User %s passed password check, MFA required", user.Email))
+		c.JSON(http.StatusAccepted, models.MFAChallengeResponse{
+			Success: true,
+			Data:    challenge,
+		})
+		return
+	}
+
 	// Update last login
 	if err := h.db.UpdateUserLoginTime(c, user.ID); err != nil {
 		utils.LogError(c, "Failed to update last login", err)
@@ -164,6 +218,13 @@ func (h *AuthHandlers) RefreshToken(c *gin.Context) {
 
 	// Refresh the session
 	tokenPair, err := h.sessionService.RefreshSession(c, req.RefreshToken)
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, models.APIError{
+			Error:   "REFRESH_TOKEN_REUSED",
+			Message: "Refresh token was already used, the session has been revoked",
+		})
+		return
+	}
 	if err != nil {
 		utils.LogError(c, "Failed to refresh session", err)
 		c.JSON(http.StatusUnauthorized, models.APIError{
@@ -229,7 +290,7 @@ func (h *AuthHandlers) Logout(c *gin.Context) {
 
 	// Validate refresh token if provided
 	if req.RefreshToken != nil && *req.RefreshToken != "" {
-		if session.RefreshToken != *req.RefreshToken {
+		if !h.sessionService.MatchesRefreshToken(session, *req.RefreshToken) {
 			c.JSON(http.StatusBadRequest, models.APIError{
 				Error:   "TOKEN_MISMATCH",
 				Message: "Refresh token does not match current session",
@@ -285,6 +346,18 @@ func (h *AuthHandlers) Logout(c *gin.Context) {
 	})
 }
 
+// JWKS godoc
+// @Summary Get JWT verification keys
+// @Description Public keys (JWK Set) that verify access and refresh tokens issued by this service, identified by kid. Includes the active key and rotated keys that are not retired yet.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} models.JSONWebKeySet
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandlers) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwtService.JWKS())
+}
+
 // VerifyToken godoc
 // @Summary Verify access token
 // @Description Verify if the provided access token is valid
@@ -351,7 +424,7 @@ func (h *AuthHandlers) VerifyToken(c *gin.Context) {
 
 // GetActiveSessions godoc
 // @Summary Get user's active sessions
-// @Description Retrieve all active sessions for the authenticated user
+// @Description Retrieve all active sessions for the authenticated user with the device and location derived by the server, and the alerts about logins from a new device or country that weren't acknowledged yet
 // @Tags Authentication
 // @Produce json
 // @Security BearerAuth
@@ -399,8 +472,19 @@ func (h *AuthHandlers) GetActiveSessions(c *gin.Context) {
 		}
 	}
 
+	alerts, err := h.sessionService.GetLoginAlerts(c, currentSession.UserID)
+	if err != nil {
+		utils.LogError(c, "Failed to get login alerts", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "SESSION_ERROR",
+			Message: "Failed to retrieve sessions",
+		})
+		return
+	}
+
 	responseData := &models.SessionListData{
 		Sessions: sessions,
+		Alerts:   alerts,
 	}
 
 	c.JSON(http.StatusOK, models.SessionListResponse{
@@ -409,6 +493,57 @@ func (h *AuthHandlers) GetActiveSessions(c *gin.Context) {
 	})
 }
 
+// AcknowledgeLoginAlert godoc
+// @Summary Acknowledge a login alert
+// @Description Dismiss an alert about a login from a new device or country
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param alert_id path string true "Alert ID"
+// @Success 200 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/sessions/alerts/{alert_id}/acknowledge [post]
+func (h *AuthHandlers) AcknowledgeLoginAlert(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	alertID, err := uuid.Parse(c.Param("alert_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_ALERT_ID",
+			Message: "Invalid alert ID format",
+		})
+		return
+	}
+
+	acknowledged, err := h.sessionService.AcknowledgeLoginAlert(c, userID, alertID)
+	if err != nil {
+		utils.LogError(c, "Failed to acknowledge login alert", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "SESSION_ERROR",
+			Message: "Failed to acknowledge login alert",
+		})
+		return
+	}
+	if !acknowledged {
+		c.JSON(http.StatusNotFound, models.APIError{
+			Error:   "ALERT_NOT_FOUND",
+			Message: "Login alert not found or already acknowledged",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountMessageResponse{
+		Success: true,
+		Message: "Login alert acknowledged",
+	})
+}
+
 // RevokeSession godoc
 // @Summary Revoke a specific session
 // @Description Revoke a specific session by session ID
@@ -491,27 +626,24 @@ func (h *AuthHandlers) RevokeSession(c *gin.Context) {
 }
 
 // Google OIDC Handlers
+//
+// These endpoints predate generic OpenID Connect support and drive the provider named "google".
 
 // GoogleLogin godoc
 // @Summary Initiate Google OAuth login
 // @Description Generate Google OAuth authorization URL
 // @Tags Authentication
 // @Produce json
-// @Param redirect_uri query string false "Redirect URI after Google auth"
 // @Success 200 {object} models.GoogleLoginResponse
+// @Failure 404 {object} models.APIError
 // @Failure 500 {object} models.APIError
 // @Router /api/v1/auth/google/login [get]
 func (h *AuthHandlers) GoogleLogin(c *gin.Context) {
 	utils.LogInfo(c, "Google OAuth login initiation")
 
-	// Generate Google OAuth URL
-	authURL, state, err := h.googleService.GenerateAuthURL(c)
+	authURL, state, err := h.oidcService.BeginLogin(c, googleProvider, nil)
 	if err != nil {
-		utils.LogError(c, "Failed to generate Google auth URL", err)
-		c.JSON(http.StatusInternalServerError, models.APIError{
-			Error:   "OAUTH_ERROR",
-			Message: "Failed to initialize Google authentication",
-		})
+		h.oidcErrorResponse(c, "Failed to initialize Google authentication", err)
 		return
 	}
 
@@ -534,8 +666,10 @@ func (h *AuthHandlers) GoogleLogin(c *gin.Context) {
 // @Produce json
 // @Param request body models.GoogleCallbackRequest true "Google callback data"
 // @Success 200 {object} models.GoogleCallbackResponse
+// @Success 202 {object} models.MFAChallengeResponse
 // @Failure 400 {object} models.APIError
 // @Failure 401 {object} models.APIError
+// @Failure 409 {object} models.APIError
 // @Failure 500 {object} models.APIError
 // @Router /api/v1/auth/google/callback [post]
 func (h *AuthHandlers) GoogleCallback(c *gin.Context) {
@@ -554,24 +688,33 @@ func (h *AuthHandlers) GoogleCallback(c *gin.Context) {
 	// Extract device info from request
 	deviceInfo := extractDeviceInfo(c, req.DeviceInfo)
 
-	// Handle Google OAuth callback
-	tokenPair, user, isNewUser, err := h.googleService.HandleCallback(c, req.Code, req.State, deviceInfo)
+	result, err := h.oidcService.HandleCallback(c, googleProvider, req.Code, req.State, deviceInfo)
 	if err != nil {
-		utils.LogError(c, "Failed to handle Google callback", err)
-		c.JSON(http.StatusUnauthorized, models.APIError{
-			Error:   "OAUTH_CALLBACK_ERROR",
-			Message: "Failed to process Google authentication",
-			Details: err.Error(),
+		h.oidcErrorResponse(c, "Failed to process Google authentication", err)
+		return
+	}
+	if result.MFAChallenge != nil {
+		utils.LogInfo(c, fmt.Sprintf("User %s authenticated via Google, MFA required", result.User.Email))
+		c.JSON(http.StatusAccepted, models.MFAChallengeResponse{
+			Success: true,
+			Data:    result.MFAChallenge,
+		})
+		return
+	}
+	if result.TokenPair == nil {
+		c.JSON(http.StatusOK, models.GoogleLinkResponse{
+			Success: true,
+			Message: "Google account successfully linked",
 		})
 		return
 	}
 
-	utils.LogInfo(c, fmt.Sprintf("User %s authenticated via Google (new_user: %v)", user.Email, isNewUser))
+	utils.LogInfo(c, fmt.Sprintf("User %s authenticated via Google (new_user: %v)", result.User.Email, result.IsNewUser))
 
 	responseData := &models.GoogleCallbackResponseData{
-		TokenPair: *tokenPair,
-		User:      user,
-		IsNewUser: isNewUser,
+		TokenPair: *result.TokenPair,
+		User:      result.User,
+		IsNewUser: result.IsNewUser,
 	}
 
 	c.JSON(http.StatusOK, models.GoogleCallbackResponse{
@@ -607,55 +750,17 @@ func (h *AuthHandlers) GoogleLink(c *gin.Context) {
 		return
 	}
 
-	token := extractTokenFromHeader(c)
-	if token == "" {
-		c.JSON(http.StatusUnauthorized, models.APIError{
-			Error:   "MISSING_TOKEN",
-			Message: "Authorization token required",
-		})
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	// Get current user from token
-	claims, err := h.jwtService.ValidateAccessToken(token)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.APIError{
-			Error:   "INVALID_TOKEN",
-			Message: "Invalid authorization token",
-		})
+	if _, err := h.oidcService.LinkIDToken(c, googleProvider, userID, req.IDToken); err != nil {
+		h.oidcErrorResponse(c, "Failed to link Google account", err)
 		return
 	}
 
-	userID, err := uuid.Parse(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, models.APIError{
-			Error:   "INVALID_USER_ID",
-			Message: "Invalid user ID in token",
-		})
-		return
-	}
-
-	// Link Google account
-	if err := h.googleService.LinkGoogleAccount(c, userID, req.IDToken); err != nil {
-		utils.LogError(c, "Failed to link Google account", err)
-		
-		if strings.Contains(err.Error(), "already linked") {
-			c.JSON(http.StatusConflict, models.APIError{
-				Error:   "ACCOUNT_ALREADY_LINKED",
-				Message: "Google account is already linked to another user",
-			})
-			return
-		}
-
-		c.JSON(http.StatusInternalServerError, models.APIError{
-			Error:   "LINK_ERROR",
-			Message: "Failed to link Google account",
-			Details: err.Error(),
-		})
-		return
-	}
-
-	utils.LogInfo(c, fmt.Sprintf("Google account linked to user %s", claims.UserID))
+	utils.LogInfo(c, fmt.Sprintf("Google account linked to user %s", userID))
 
 	c.JSON(http.StatusOK, models.GoogleLinkResponse{
 		Success: true,
@@ -665,6 +770,40 @@ func (h *AuthHandlers) GoogleLink(c *gin.Context) {
 
 // Helper functions
 
+// recordLoginFailure counts a failed password login towards lockout
+func (h *AuthHandlers) recordLoginFailure(c *gin.Context, email string) {
+	if err := h.loginGuard.RecordFailure(c, email, c.ClientIP()); err != nil {
+		utils.LogError(c, "Failed to record login failure", err)
+	}
+}
+
+// loginBlockedResponse answers a login refused by the login guard
+func loginBlockedResponse(c *gin.Context, block *auth.LoginBlock) {
+	retryAfter := int(math.Ceil(block.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	switch {
+	case block.Locked && block.Scope == models.LoginThrottleScopeAccount:
+		c.JSON(http.StatusLocked, models.APIError{
+			Error:   "ACCOUNT_LOCKED",
+			Message: "Account temporarily locked after too many failed logins",
+			Details: fmt.Sprintf("Try again in %d seconds", retryAfter),
+		})
+	case block.Locked:
+		c.JSON(http.StatusTooManyRequests, models.APIError{
+			Error:   "TOO_MANY_FAILED_LOGINS",
+			Message: "Too many failed logins from this address",
+			Details: fmt.Sprintf("Try again in %d seconds", retryAfter),
+		})
+	default:
+		c.JSON(http.StatusTooManyRequests, models.APIError{
+			Error:   "LOGIN_THROTTLED",
+			Message: "Please wait before trying again",
+			Details: fmt.Sprintf("Try again in %d seconds", retryAfter),
+		})
+	}
+}
+
 func extractTokenFromHeader(c *gin.Context) string {
 	bearerToken := c.GetHeader("Authorization")
 	if bearerToken == "" {
@@ -696,4 +835,4 @@ func extractDeviceInfo(c *gin.Context, requestDeviceInfo *models.DeviceInfo) *mo
 	}
 
 	return deviceInfo
-}
\ No newline at end of file
+}
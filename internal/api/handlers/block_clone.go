@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// CloneBlocks handles POST /api/v1/block/clone
+// @Summary Clone blocks between events
+// @Description Copy all or selected blocks of an event into another event with their media and, unless without_guests, their guests. Guest invitations start over. The copies start at position in the target's rundown and the blocks from there move down; without a position they follow the last block. With preview the resulting rundown is returned without saving it.
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param request body models.CloneBlocksRequest true "Clone options"
+// @Success 200 {object} models.CloneBlocksResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/block/clone [post]
+func (h *BlockHandler) CloneBlocks(c *gin.Context) {
+	var req models.CloneBlocksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	// Parse block IDs, dropping duplicates
+	var blockIDs []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, blockIDStr := range req.BlockIDs {
+		blockID, err := uuid.Parse(blockIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block ID format", "block_id": blockIDStr})
+			return
+		}
+		if !seen[blockID] {
+			seen[blockID] = true
+			blockIDs = append(blockIDs, blockID)
+		}
+	}
+
+	source, ok := h.getOwnedEvent(c, req.SourceEventID)
+	if !ok {
+		return
+	}
+	target, ok := h.getOwnedEvent(c, req.TargetEventID)
+	if !ok {
+		return
+	}
+
+	blocks, cloned, err := h.db.CloneBlocks(c.Request.Context(), source.ID, target, blockIDs, req.Position, req.WithoutGuests, req.Preview)
+	if err != nil {
+		if errors.Is(err, database.ErrBlockNotInEvent) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Some blocks do not belong to the source event"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to clone blocks", err, utils.Fields{
+			"source_event_id": source.ID,
+			"target_event_id": target.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clone blocks"})
+		return
+	}
+	if cloned == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source event has no blocks"})
+		return
+	}
+
+	if blocks == nil {
+		blocks = []models.BlockSummary{}
+	}
+	var totalEstimatedTime int
+	for _, block := range blocks {
+		totalEstimatedTime += block.EstimatedLength
+	}
+
+	if !req.Preview {
+		utils.LogInfo(c.Request.Context(), "Blocks cloned", utils.Fields{
+			"source_event_id": source.ID,
+			"target_event_id": target.ID,
+			"cloned":          cloned,
+			"without_guests":  req.WithoutGuests,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.CloneBlocksResponse{
+		Success: true,
+		Data: &models.CloneBlocksData{
+			SourceEventID:      source.ID,
+			TargetEventID:      target.ID,
+			Cloned:             cloned,
+			Preview:            req.Preview,
+			Blocks:             blocks,
+			TotalEstimatedTime: totalEstimatedTime,
+		},
+	})
+}
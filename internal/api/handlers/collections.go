@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+type CollectionHandler struct {
+	db *database.PostgresDB
+}
+
+func NewCollectionHandler(db *database.PostgresDB) *CollectionHandler {
+	return &CollectionHandler{
+		db: db,
+	}
+}
+
+// CreateCollection godoc
+// @Summary Create a media collection
+// @Description Create a named collection of media files, optionally with initial media
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param request body models.CreateMediaCollectionRequest true "Collection details"
+// @Success 201 {object} models.MediaCollectionResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections [post]
+// @Security BearerAuth
+func (h *CollectionHandler) CreateCollection(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateMediaCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		h.errorResponse(c, utils.NewValidationError("Collection name is required", nil))
+		return
+	}
+
+	var mediaIDs []uuid.UUID
+	if len(req.MediaIDs) > 0 {
+		scope, appErr := mediaScope(c)
+		if appErr != nil {
+			h.errorResponse(c, appErr)
+			return
+		}
+
+		var err error
+		mediaIDs, err = h.db.GetMediaUUIDs(ctx, scope, req.MediaIDs)
+		if err != nil {
+			h.errorResponse(c, utils.NewValidationError("Invalid media IDs", map[string]interface{}{
+				"error": err.Error(),
+			}))
+			return
+		}
+	}
+
+	collection := &models.MediaCollection{
+		Name:        name,
+		Description: req.Description,
+		UserID:      userUUID,
+	}
+
+	if err := h.db.CreateMediaCollection(ctx, collection, mediaIDs); err != nil {
+		if strings.Contains(err.Error(), "unique_user_collection") {
+			h.errorResponse(c, utils.NewErrorWithDetails(utils.ErrorCodeValidationError, "A collection with this name already exists", http.StatusConflict, map[string]interface{}{
+				"name": name,
+			}))
+			return
+		}
+		utils.LogError(ctx, "Failed to create collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	utils.LogInfo(ctx, "Media collection created", utils.Fields{
+		"collection_id": collection.ID,
+		"user_id":       userUUID,
+		"media_count":   len(mediaIDs),
+	})
+
+	h.respondWithCollection(c, http.StatusCreated, collection)
+}
+
+// ListCollections godoc
+// @Summary List media collections
+// @Description List the media collections of the authenticated user
+// @Tags media
+// @Produce json
+// @Success 200 {object} models.MediaCollectionListResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections [get]
+// @Security BearerAuth
+func (h *CollectionHandler) ListCollections(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	collections, err := h.db.ListMediaCollections(ctx, userUUID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list collections", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MediaCollectionListResponse{
+		Success: true,
+		Data:    collections,
+	})
+}
+
+// GetCollection godoc
+// @Summary Get a media collection
+// @Description Get a media collection with its media files in collection order
+// @Tags media
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Success 200 {object} models.MediaCollectionResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id} [get]
+// @Security BearerAuth
+func (h *CollectionHandler) GetCollection(c *gin.Context) {
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	h.respondWithCollection(c, http.StatusOK, collection)
+}
+
+// UpdateCollection godoc
+// @Summary Update a media collection
+// @Description Rename a media collection or change its description
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Param request body models.UpdateMediaCollectionRequest true "Collection changes"
+// @Success 200 {object} models.MediaCollectionResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id} [put]
+// @Security BearerAuth
+func (h *CollectionHandler) UpdateCollection(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.UpdateMediaCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			h.errorResponse(c, utils.NewValidationError("Collection name cannot be empty", nil))
+			return
+		}
+		collection.Name = name
+	}
+	if req.Description != nil {
+		collection.Description = req.Description
+	}
+
+	if err := h.db.UpdateMediaCollection(ctx, collection); err != nil {
+		if strings.Contains(err.Error(), "unique_user_collection") {
+			h.errorResponse(c, utils.NewErrorWithDetails(utils.ErrorCodeValidationError, "A collection with this name already exists", http.StatusConflict, map[string]interface{}{
+				"name": collection.Name,
+			}))
+			return
+		}
+		utils.LogError(ctx, "Failed to update collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithCollection(c, http.StatusOK, collection)
+}
+
+// DeleteCollection godoc
+// @Summary Delete a media collection
+// @Description Delete a media collection. The media files themselves are kept.
+// @Tags media
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id} [delete]
+// @Security BearerAuth
+func (h *CollectionHandler) DeleteCollection(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteMediaCollection(ctx, collection.ID); err != nil {
+		utils.LogError(ctx, "Failed to delete collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "success",
+		"message":       "Collection deleted successfully",
+		"collection_id": collection.ID,
+	})
+}
+
+// AddCollectionMedia godoc
+// @Summary Add media to a collection
+// @Description Append media files to a collection. Media already in the collection is skipped.
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Param request body models.MediaCollectionItemsRequest true "Media IDs"
+// @Success 200 {object} models.MediaCollectionResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id}/media [post]
+// @Security BearerAuth
+func (h *CollectionHandler) AddCollectionMedia(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.MediaCollectionItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	mediaIDs, err := h.db.GetMediaUUIDs(ctx, scope, req.MediaIDs)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid media IDs", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	if err := h.db.AddMediaToCollection(ctx, collection.ID, mediaIDs); err != nil {
+		utils.LogError(ctx, "Failed to add media to collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithCollection(c, http.StatusOK, collection)
+}
+
+// RemoveCollectionMedia godoc
+// @Summary Remove media from a collection
+// @Description Remove media files from a collection
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Param request body models.MediaCollectionItemsRequest true "Media IDs"
+// @Success 200 {object} models.MediaCollectionResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id}/media [delete]
+// @Security BearerAuth
+func (h *CollectionHandler) RemoveCollectionMedia(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.MediaCollectionItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	// Media that is no longer visible to the user can still be taken out of their collection
+	mediaIDs, err := h.db.GetMediaUUIDs(ctx, models.MediaScope{All: true}, req.MediaIDs)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid media IDs", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	if err := h.db.RemoveMediaFromCollection(ctx, collection.ID, mediaIDs); err != nil {
+		utils.LogError(ctx, "Failed to remove media from collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithCollection(c, http.StatusOK, collection)
+}
+
+// AttachCollection godoc
+// @Summary Attach a collection to a block
+// @Description Append every media file of a collection to a block, after the media the block already has
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param collection_id path string true "Collection ID"
+// @Param request body models.AttachCollectionRequest true "Target block"
+// @Success 200 {object} models.UpdateBlockResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/collections/{collection_id}/attach [post]
+// @Security BearerAuth
+func (h *CollectionHandler) AttachCollection(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.AttachCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	collection, ok := h.getOwnedCollection(c)
+	if !ok {
+		return
+	}
+
+	blockID, err := uuid.Parse(req.BlockID)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid block ID format", map[string]interface{}{
+			"block_id": req.BlockID,
+		}))
+		return
+	}
+	blockDetail, err := h.db.GetBlockByID(ctx, blockID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get block", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+	if blockDetail == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Block not found"))
+		return
+	}
+	if blockDetail.UserID != collection.UserID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	attached, err := h.db.AttachCollectionToBlock(ctx, collection.ID, blockID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to attach collection to block", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	updatedBlock, err := h.db.GetBlockByID(ctx, blockID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get updated block", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	utils.LogInfo(ctx, "Media collection attached to block", utils.Fields{
+		"collection_id": collection.ID,
+		"block_id":      blockID,
+		"media_count":   attached,
+	})
+
+	c.JSON(http.StatusOK, models.UpdateBlockResponse{
+		Success: true,
+		Data:    updatedBlock,
+	})
+}
+
+// getOwnedCollection loads the collection from the path and verifies the user owns it
+func (h *CollectionHandler) getOwnedCollection(c *gin.Context) (*models.MediaCollection, bool) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	collectionID, err := uuid.Parse(c.Param("collection_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid collection ID format", map[string]interface{}{
+			"collection_id": c.Param("collection_id"),
+		}))
+		return nil, false
+	}
+
+	collection, err := h.db.GetMediaCollection(ctx, collectionID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get collection", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, false
+	}
+	if collection == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Collection not found"))
+		return nil, false
+	}
+	if collection.UserID != userUUID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return nil, false
+	}
+
+	return collection, true
+}
+
+func (h *CollectionHandler) respondWithCollection(c *gin.Context, status int, collection *models.MediaCollection) {
+	ctx := c.Request.Context()
+
+	items, err := h.db.GetMediaCollectionItems(ctx, collection.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get collection media", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(status, models.MediaCollectionResponse{
+		Success: true,
+		Data: &models.MediaCollectionDetail{
+			MediaCollection: *collection,
+			Items:           items,
+		},
+	})
+}
+
+func (h *CollectionHandler) getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return uuid.Nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
+
+func (h *CollectionHandler) errorResponse(c *gin.Context, err *utils.AppError) {
+	c.JSON(err.StatusCode, gin.H{
+		"error":      err,
+		"request_id": c.GetString("request_id"),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
@@ -175,6 +175,7 @@ func (h *EventHandler) UpdateEventREST(c *gin.Context) {
 		"user_id":  userUUID,
 	})
 
+	eventDetail.YouTubeKey = maskSecret(eventDetail.YouTubeKey)
 	c.JSON(http.StatusOK, models.EventResponseREST{
 		Success: true,
 		Data:    eventDetail,
@@ -348,6 +349,7 @@ func (h *EventHandler) GetEventREST(c *gin.Context) {
 		return
 	}
 
+	eventDetail.YouTubeKey = maskSecret(eventDetail.YouTubeKey)
 	c.JSON(http.StatusOK, models.EventResponseREST{
 		Success: true,
 		Data:    eventDetail,
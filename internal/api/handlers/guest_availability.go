@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// GetGuestAvailability handles GET /api/v1/guest/availability/{guest_id}
+// @Summary Get guest availability
+// @Description Weekly windows the guest is free in and blackout days, in the guest's timezone. A guest whose availability was never set has no windows or blackouts and counts as free at any time.
+// @Tags guests
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Success 200 {object} models.GuestAvailabilityResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/availability/{guest_id} [get]
+func (h *GuestHandler) GetGuestAvailability(c *gin.Context) {
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	availability, err := h.db.GetGuestAvailability(c.Request.Context(), guest.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest availability", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve availability"})
+		return
+	}
+
+	if availability == nil {
+		availability = &models.GuestAvailability{
+			GuestID:   guest.ID,
+			Timezone:  "UTC",
+			Windows:   []models.GuestAvailabilityWindow{},
+			Blackouts: []models.GuestBlackout{},
+		}
+	}
+
+	c.JSON(http.StatusOK, models.GuestAvailabilityResponse{
+		Success: true,
+		Data:    availability,
+	})
+}
+
+// SetGuestAvailability handles PUT /api/v1/guest/availability/{guest_id}
+// @Summary Set guest availability
+// @Description Replace the guest's availability. Windows repeat weekly (weekday 0 is Sunday) with HH:MM times in the guest's IANA timezone; a window ending at or before its start runs past midnight. Blackouts are inclusive date ranges. Without windows the guest is free at any time outside their blackouts.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Param request body models.SetGuestAvailabilityRequest true "Availability"
+// @Success 200 {object} models.GuestAvailabilityResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/availability/{guest_id} [put]
+func (h *GuestHandler) SetGuestAvailability(c *gin.Context) {
+	var req models.SetGuestAvailabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+	if guest.ErasedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Guest was erased"})
+		return
+	}
+
+	availability := &models.GuestAvailability{
+		GuestID:   guest.ID,
+		Timezone:  req.Timezone,
+		Windows:   req.Windows,
+		Blackouts: req.Blackouts,
+	}
+	if err := guests.ValidateAvailability(availability); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid availability", "details": err.Error()})
+		return
+	}
+
+	if err := h.db.SetGuestAvailability(c.Request.Context(), availability); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to set guest availability", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save availability"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest availability updated", utils.Fields{
+		"guest_id":  guest.ID,
+		"windows":   len(availability.Windows),
+		"blackouts": len(availability.Blackouts),
+	})
+
+	c.JSON(http.StatusOK, models.GuestAvailabilityResponse{
+		Success: true,
+		Data:    availability,
+	})
+}
+
+// SuggestEvents handles POST /api/v1/guest/availability/suggest
+// @Summary Suggest events for guests
+// @Description Check the upcoming events of a show against the availability of a set of guests and list those every guest can make, earliest first. A guest can't make an event outside their windows, during a blackout or while booked into another event at the same time. preferred_count is how many of the guests would be booked at a preferred time.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body models.SuggestEventsRequest true "Show and guests"
+// @Success 200 {object} models.SuggestEventsResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/availability/suggest [post]
+func (h *GuestHandler) SuggestEvents(c *gin.Context) {
+	var req models.SuggestEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	showID, err := uuid.Parse(req.ShowID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID format"})
+		return
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var guestIDs []uuid.UUID
+	for _, idStr := range req.GuestIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format", "guest_id": idStr})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			guestIDs = append(guestIDs, id)
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	limit = min(limit, 50)
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	show, err := h.db.GetShowByID(ctx, showID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get show", err, utils.Fields{
+			"show_id": showID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve show"})
+		return
+	}
+	if show == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+		return
+	}
+	if show.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	for _, guestID := range guestIDs {
+		guest, err := h.db.GetGuestByID(ctx, guestID)
+		if err != nil {
+			utils.LogError(ctx, "Failed to get guest", err, utils.Fields{
+				"guest_id": guestID,
+			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest"})
+			return
+		}
+		if guest == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found", "guest_id": guestID})
+			return
+		}
+		if guest.UserID != userUUID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "guest_id": guestID})
+			return
+		}
+	}
+
+	events, err := h.db.GetFutureEvents(ctx, show.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get upcoming events", err, utils.Fields{
+			"show_id": show.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve events"})
+		return
+	}
+
+	data := &models.SuggestEventsData{
+		ShowID:      show.ID,
+		Checked:     len(events),
+		Suggestions: []models.EventSuggestion{},
+	}
+	if len(events) == 0 {
+		c.JSON(http.StatusOK, models.SuggestEventsResponse{Success: true, Data: data})
+		return
+	}
+
+	availability, err := h.db.ListGuestAvailability(ctx, guestIDs)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list guest availability", err, utils.Fields{
+			"guest_ids": guestIDs,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve availability"})
+		return
+	}
+
+	// Events are ordered by start, but a longer one may end after the last
+	from, to := events[0].StartDateTime, events[0].EndDateTime
+	for _, event := range events {
+		if event.EndDateTime.After(to) {
+			to = event.EndDateTime
+		}
+	}
+	bookings, err := h.db.ListGuestBookingsBetween(ctx, guestIDs, from, to)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list guest bookings", err, utils.Fields{
+			"guest_ids": guestIDs,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest bookings"})
+		return
+	}
+
+	for _, suggestion := range guests.SuggestEvents(events, guestIDs, availability, bookings) {
+		if !suggestion.AllAvailable && !req.IncludeUnavailable {
+			continue
+		}
+		data.Suggestions = append(data.Suggestions, suggestion)
+		if len(data.Suggestions) == limit {
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuggestEventsResponse{
+		Success: true,
+		Data:    data,
+	})
+}
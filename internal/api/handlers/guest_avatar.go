@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/avatar"
+	"github.com/denisAlshanov/stPlaner/internal/services/telegram"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// UploadGuestAvatar handles POST /api/v1/guest/avatar/{guest_id}
+// @Summary Upload guest avatar
+// @Description Upload a JPEG, PNG or GIF image as the guest's avatar. The image is cropped to a centered square and stored as 512, 256 and 64 pixel JPEGs with stable public URLs; the guest's avatar is set to the largest one.
+// @Tags guests
+// @Accept multipart/form-data
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Param file formData file true "Image file (5MB by default)"
+// @Success 200 {object} models.GuestAvatarResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/avatar/{guest_id} [post]
+func (h *GuestHandler) UploadGuestAvatar(c *gin.Context) {
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart framing around the image
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.avatars.MaxUploadSize()+64<<10)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Avatar file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar file is required", "details": err.Error()})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read avatar file"})
+		return
+	}
+	defer file.Close()
+
+	stored, err := h.avatars.Upload(c.Request.Context(), guest.ID, file)
+	if err != nil {
+		h.avatarError(c, guest.ID, err)
+		return
+	}
+
+	h.setGuestAvatar(c, guest, stored)
+}
+
+// ImportTelegramAvatar handles POST /api/v1/guest/avatar/{guest_id}/telegram
+// @Summary Use Telegram profile photo as guest avatar
+// @Description Fetch the public profile photo of a Telegram username, by default the guest's primary Telegram contact, and store it as the guest's avatar like an upload.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Param request body models.TelegramAvatarRequest false "Telegram username or t.me link"
+// @Success 200 {object} models.GuestAvatarResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 501 {object} map[string]interface{}
+// @Failure 502 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/avatar/{guest_id}/telegram [post]
+func (h *GuestHandler) ImportTelegramAvatar(c *gin.Context) {
+	var req models.TelegramAvatarRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	username := req.Username
+	if username == "" {
+		for _, contact := range guest.Contacts {
+			if contact.Type == models.ContactTypeTelegram && (username == "" || contact.IsPrimary) {
+				username = contact.Value
+			}
+		}
+	}
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Guest has no Telegram contact, pass a username"})
+		return
+	}
+
+	stored, err := h.avatars.FromTelegram(c.Request.Context(), guest.ID, username)
+	if err != nil {
+		h.avatarError(c, guest.ID, err)
+		return
+	}
+
+	h.setGuestAvatar(c, guest, stored)
+}
+
+// ServeGuestAvatar handles GET /avatars/guests/{guest_id}/{version}/{file}
+// @Summary Get guest avatar image
+// @Description Public avatar image. URLs change whenever the avatar does, so responses are cacheable forever.
+// @Tags guests
+// @Produce image/jpeg
+// @Param guest_id path string true "Guest ID"
+// @Param version path string true "Avatar version"
+// @Param file path string true "Size file name such as 256.jpg"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]interface{}
+// @Router /avatars/guests/{guest_id}/{version}/{file} [get]
+func (h *GuestHandler) ServeGuestAvatar(c *gin.Context) {
+	guestID, err := uuid.Parse(c.Param("guest_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+		return
+	}
+
+	reader, err := h.avatars.Open(c.Request.Context(), guestID, c.Param("version"), c.Param("file"))
+	if errors.Is(err, avatar.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Avatar not found"})
+		return
+	}
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to open guest avatar", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve avatar"})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to stream guest avatar", err, utils.Fields{
+			"guest_id": guestID,
+		})
+	}
+}
+
+// getOwnedGuest loads the guest of the guest_id path parameter and checks that the current
+// user owns it, writing the error response otherwise
+func (h *GuestHandler) getOwnedGuest(c *gin.Context) (*models.Guest, bool) {
+	guestID, err := uuid.Parse(c.Param("guest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format"})
+		return nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
+	}
+
+	guest, err := h.db.GetGuestByID(c.Request.Context(), guestID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest"})
+		return nil, false
+	}
+	if guest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found"})
+		return nil, false
+	}
+	if guest.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return guest, true
+}
+
+// setGuestAvatar points the guest at a stored avatar and removes the images of the previous one
+func (h *GuestHandler) setGuestAvatar(c *gin.Context, guest *models.Guest, stored *models.GuestAvatar) {
+	ctx := c.Request.Context()
+
+	if err := h.db.UpdateGuestAvatar(ctx, guest.ID, &stored.URL); err != nil {
+		utils.LogError(ctx, "Failed to update guest avatar", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		if guest.Avatar == nil || *guest.Avatar != stored.URL {
+			h.avatars.Remove(ctx, guest.ID, stored.URL)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update guest avatar"})
+		return
+	}
+
+	if guest.Avatar != nil && *guest.Avatar != stored.URL {
+		if err := h.avatars.Remove(ctx, guest.ID, *guest.Avatar); err != nil {
+			utils.LogWarn(ctx, "Failed to remove previous guest avatar", utils.Fields{
+				"guest_id": guest.ID,
+				"avatar":   *guest.Avatar,
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	utils.LogInfo(ctx, "Guest avatar updated", utils.Fields{
+		"guest_id": guest.ID,
+		"version":  stored.Version,
+	})
+
+	c.JSON(http.StatusOK, models.GuestAvatarResponse{
+		Success: true,
+		Data:    stored,
+	})
+}
+
+func (h *GuestHandler) avatarError(c *gin.Context, guestID uuid.UUID, err error) {
+	switch {
+	case errors.Is(err, avatar.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Avatar must be at most " + strconv.FormatInt(h.avatars.MaxUploadSize()>>20, 10) + "MB"})
+	case errors.Is(err, avatar.ErrUnsupportedType), errors.Is(err, avatar.ErrImageTooSmall), errors.Is(err, avatar.ErrImageTooLarge), errors.Is(err, avatar.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, telegram.ErrInvalidUsername):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not a valid Telegram username"})
+	case errors.Is(err, telegram.ErrNoProfilePhoto):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Telegram profile has no public photo"})
+	case errors.Is(err, avatar.ErrTelegramUnavailable):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Telegram integration can't fetch profile photos, configure Telegram API credentials"})
+	case errors.Is(err, avatar.ErrTelegramFetch):
+		utils.LogError(c.Request.Context(), "Failed to fetch Telegram profile photo", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch Telegram profile photo"})
+	default:
+		utils.LogError(c.Request.Context(), "Failed to store guest avatar", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store avatar"})
+	}
+}
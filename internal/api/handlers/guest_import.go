@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	maxGuestImportSize = 5 << 20
+	// exportFlushRows is how many guests are written between flushes of an export stream
+	exportFlushRows = 200
+)
+
+// ImportGuests handles POST /api/v1/guest/import
+// @Summary Import guests from CSV or vCard
+// @Description Import guests from a CSV file with a header row or a vCard 3.0/4.0 file. CSV columns are mapped to guest fields with a JSON object such as {"First": "name", "Mail": "email"}; columns named after a field or a common alias are picked up without a mapping, and cells may hold several tags or contacts separated by semicolons. Every row is validated and matched against existing guests by name and contacts. With dry_run nothing is written; otherwise the new guests are created together or not at all.
+// @Tags guests
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or vCard file (max 5MB)"
+// @Param format formData string false "csv or vcard, taken from the file extension when omitted"
+// @Param mapping formData string false "JSON object mapping CSV headers to name, surname, full_name, short_name, notes, tags, avatar, ignore or a contact type"
+// @Param dry_run formData bool false "Validate and match without creating guests" default(false)
+// @Param on_duplicate formData string false "skip or create guests matched to an existing one" default(skip)
+// @Success 200 {object} models.GuestImportResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/import [post]
+func (h *GuestHandler) ImportGuests(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	// Leave room for the other form fields next to the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGuestImportSize+64<<10)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file must be at most 5MB"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is required", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxGuestImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file must be at most 5MB"})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+		case ".csv":
+			format = guests.FormatCSV
+		case ".vcf", ".vcard":
+			format = guests.FormatVCard
+		}
+	}
+	if format != guests.FormatCSV && format != guests.FormatVCard {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or vcard"})
+		return
+	}
+
+	var mapping map[string]string
+	if mappingStr := c.PostForm("mapping"); mappingStr != "" {
+		if err := json.Unmarshal([]byte(mappingStr), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mapping must be a JSON object of CSV headers to guest fields", "details": err.Error()})
+			return
+		}
+	}
+
+	dryRun := false
+	if dryRunStr := c.PostForm("dry_run"); dryRunStr != "" {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+	}
+
+	onDuplicate := c.DefaultPostForm("on_duplicate", "skip")
+	if onDuplicate != "skip" && onDuplicate != "create" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "on_duplicate must be skip or create"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import file"})
+		return
+	}
+	defer file.Close()
+
+	var rows []guests.ImportRow
+	if format == guests.FormatCSV {
+		rows, err = guests.ParseCSV(file, mapping)
+	} else {
+		rows, err = guests.ParseVCard(file)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse import file", "details": err.Error()})
+		return
+	}
+
+	existing, err := h.db.ListAllGuests(c.Request.Context(), userUUID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to load guests for import", err, utils.Fields{
+			"user_id": userUUID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import guests"})
+		return
+	}
+
+	result := &models.GuestImportResult{
+		DryRun: dryRun,
+		Format: format,
+		Total:  len(rows),
+		Rows:   make([]models.GuestImportRow, 0, len(rows)),
+	}
+
+	// Rows are matched against the directory and against the rows accepted before them
+	var accepted []*models.Guest
+	acceptedRows := make(map[*models.Guest]int)
+	var acceptedIndexes []int
+	for _, row := range rows {
+		guest := row.Guest
+		item := models.GuestImportRow{
+			Row:     row.Row,
+			Name:    guest.Name,
+			Surname: guest.Surname,
+			Errors:  row.Errors,
+		}
+
+		if len(row.Errors) > 0 {
+			item.Status = models.GuestImportInvalid
+			result.Invalid++
+			result.Rows = append(result.Rows, item)
+			continue
+		}
+
+		if match := guests.FindMatch(guest, existing); match != nil {
+			item.Match = &models.GuestImportMatch{
+				GuestID: match.Duplicate.ID,
+				Name:    match.Duplicate.Name,
+				Surname: match.Duplicate.Surname,
+				Score:   match.Score,
+				Reasons: match.Reasons,
+			}
+			// A guest with the very same name can't be created twice
+			if onDuplicate == "skip" || sameGuestName(guest, match.Duplicate) {
+				item.Status = models.GuestImportDuplicate
+				result.Duplicates++
+				result.Rows = append(result.Rows, item)
+				continue
+			}
+		}
+
+		if match := guests.FindMatch(guest, accepted); match != nil && (onDuplicate == "skip" || sameGuestName(guest, match.Duplicate)) {
+			item.Status = models.GuestImportDuplicate
+			item.Errors = []string{fmt.Sprintf("same guest as row %d", acceptedRows[match.Duplicate])}
+			result.Duplicates++
+			result.Rows = append(result.Rows, item)
+			continue
+		}
+
+		guest.UserID = userUUID
+		accepted = append(accepted, guest)
+		acceptedRows[guest] = row.Row
+		acceptedIndexes = append(acceptedIndexes, len(result.Rows))
+
+		item.Status = models.GuestImportNew
+		result.New++
+		result.Rows = append(result.Rows, item)
+	}
+
+	if !dryRun && len(accepted) > 0 {
+		// New tags join the vocabulary and known ones take its spelling
+		var tags []string
+		seen := make(map[string]bool)
+		for _, guest := range accepted {
+			for _, tag := range guest.Tags {
+				if !seen[strings.ToLower(tag)] {
+					seen[strings.ToLower(tag)] = true
+					tags = append(tags, tag)
+				}
+			}
+		}
+		tags, err = h.db.EnsureGuestTags(c.Request.Context(), userUUID, tags)
+		if err != nil {
+			utils.LogError(c.Request.Context(), "Failed to add imported guest tags", err, utils.Fields{
+				"user_id": userUUID,
+			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import guests"})
+			return
+		}
+		spelling := make(map[string]string, len(tags))
+		for _, tag := range tags {
+			spelling[strings.ToLower(tag)] = tag
+		}
+		for _, guest := range accepted {
+			for i, tag := range guest.Tags {
+				guest.Tags[i] = spelling[strings.ToLower(tag)]
+			}
+		}
+
+		if err := h.db.CreateGuests(c.Request.Context(), accepted); err != nil {
+			if strings.Contains(err.Error(), "unique_user_guest") {
+				c.JSON(http.StatusConflict, gin.H{"error": "A guest in the file was created meanwhile, run the import again", "details": err.Error()})
+				return
+			}
+			utils.LogError(c.Request.Context(), "Failed to import guests", err, utils.Fields{
+				"user_id": userUUID,
+				"count":   len(accepted),
+			})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import guests"})
+			return
+		}
+
+		for i, index := range acceptedIndexes {
+			guestID := accepted[i].ID
+			result.Rows[index].Status = models.GuestImportCreated
+			result.Rows[index].GuestID = &guestID
+		}
+		result.Created = len(accepted)
+		result.New = 0
+
+		utils.LogInfo(c.Request.Context(), "Guests imported", utils.Fields{
+			"user_id":    userUUID,
+			"format":     format,
+			"created":    result.Created,
+			"duplicates": result.Duplicates,
+			"invalid":    result.Invalid,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.GuestImportResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// ExportGuests handles POST /api/v1/guest/export
+// @Summary Export guests to CSV or vCard
+// @Description Export all guests matching the guest list filters or a saved segment, in list order, as a CSV file or a vCard 3.0 file. The file is streamed, so large directories don't have to fit in memory. Pagination in the body is ignored.
+// @Tags guests
+// @Accept json
+// @Produce text/csv
+// @Produce text/vcard
+// @Param format query string false "csv or vcard" default(csv)
+// @Param request body models.ListGuestsRequest false "Guest list filters and sorting"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/export [post]
+func (h *GuestHandler) ExportGuests(c *gin.Context) {
+	var req models.ListGuestsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", guests.FormatCSV))
+	if format != guests.FormatCSV && format != guests.FormatVCard {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be csv or vcard"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	filters, ok := h.guestListFilters(c, &req)
+	if !ok {
+		return
+	}
+
+	filename := "guests-" + time.Now().Format("20060102")
+	contentType := "text/csv; charset=utf-8"
+	if format == guests.FormatCSV {
+		filename += ".csv"
+	} else {
+		filename += ".vcf"
+		contentType = "text/vcard; charset=utf-8"
+	}
+
+	// Headers are only sent with the first guest, so a failing query can still answer with an error
+	csvWriter := guests.NewCSVWriter(c.Writer)
+	count := 0
+	start := func() error {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+		c.Status(http.StatusOK)
+		if format == guests.FormatCSV {
+			return csvWriter.WriteHeader()
+		}
+		return nil
+	}
+
+	err := h.db.StreamGuests(c.Request.Context(), userUUID, filters, req.Sort, func(guest *models.Guest) error {
+		if count == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		count++
+
+		if format == guests.FormatCSV {
+			if err := csvWriter.Write(guest); err != nil {
+				return err
+			}
+		} else if err := guests.WriteVCard(c.Writer, guest); err != nil {
+			return err
+		}
+
+		if count%exportFlushRows == 0 {
+			if err := csvWriter.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && count == 0 {
+		err = start()
+	}
+	if err == nil {
+		err = csvWriter.Flush()
+	}
+
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to export guests", err, utils.Fields{
+			"user_id":  userUUID,
+			"format":   format,
+			"exported": count,
+		})
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export guests"})
+		}
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guests exported", utils.Fields{
+		"user_id":  userUUID,
+		"format":   format,
+		"exported": count,
+	})
+}
+
+func sameGuestName(a, b *models.Guest) bool {
+	return a.Name == b.Name && a.Surname == b.Surname
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	defaultDuplicateMinScore = 0.5
+	defaultDuplicateLimit    = 50
+	maxDuplicateLimit        = 200
+	// duplicatePairsPerResult bounds how many candidate pairs are scored per returned result
+	duplicatePairsPerResult = 4
+)
+
+// FindDuplicateGuests handles GET /api/v1/guest/duplicates
+// @Summary Find duplicate guests
+// @Description List pairs of guests that may be the same person, best match first. The score combines trigram name similarity, ignoring annotations in brackets such as "(TG)", with shared contact values.
+// @Tags guests
+// @Produce json
+// @Param guest_id query string false "Only pairs involving this guest"
+// @Param min_score query number false "Minimum score from 0 to 1" default(0.5)
+// @Param limit query int false "Maximum number of pairs (max 200)" default(50)
+// @Success 200 {object} models.GuestDuplicatesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/duplicates [get]
+func (h *GuestHandler) FindDuplicateGuests(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var guestID *uuid.UUID
+	if guestIDStr := c.Query("guest_id"); guestIDStr != "" {
+		parsed, err := uuid.Parse(guestIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format"})
+			return
+		}
+		guestID = &parsed
+	}
+
+	minScore := defaultDuplicateMinScore
+	if minScoreStr := c.Query("min_score"); minScoreStr != "" {
+		parsed, err := strconv.ParseFloat(minScoreStr, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_score must be a number from 0 to 1"})
+			return
+		}
+		minScore = parsed
+	}
+
+	limit := defaultDuplicateLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= maxDuplicateLimit {
+			limit = l
+		}
+	}
+
+	pairs, err := h.db.ListGuestDuplicatePairs(c.Request.Context(), userUUID, guestID, limit*duplicatePairsPerResult)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to find duplicate guests", err, utils.Fields{
+			"user_id": userUUID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicate guests"})
+		return
+	}
+
+	candidates := guests.RankDuplicates(pairs, minScore)
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	c.JSON(http.StatusOK, models.GuestDuplicatesResponse{
+		Success: true,
+		Data:    candidates,
+	})
+}
+
+// MergeGuests handles POST /api/v1/guest/merge
+// @Summary Merge duplicate guests
+// @Description Fold duplicate guests into a surviving guest in one transaction: contacts, tags and metadata the survivor lacks are added, notes are appended, and all block bookings and consent records move to the survivor. A survivor without availability takes over the most recently updated one of the duplicates. The duplicates are deleted. The merge is recorded and can be undone.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body models.MergeGuestsRequest true "Surviving guest and duplicates"
+// @Success 200 {object} models.MergeGuestsResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/merge [post]
+func (h *GuestHandler) MergeGuests(c *gin.Context) {
+	var req models.MergeGuestsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	survivorID, err := uuid.Parse(req.SurvivorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format", "guest_id": req.SurvivorID})
+		return
+	}
+
+	seen := map[uuid.UUID]bool{survivorID: true}
+	var duplicateIDs []uuid.UUID
+	for _, idStr := range req.DuplicateIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format", "guest_id": idStr})
+			return
+		}
+		if seen[id] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A guest can only be merged once and not into itself", "guest_id": idStr})
+			return
+		}
+		seen[id] = true
+		duplicateIDs = append(duplicateIDs, id)
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	guest, merge, err := h.db.MergeGuests(c.Request.Context(), userUUID, survivorID, duplicateIDs, guests.Merge)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to merge guests", err, utils.Fields{
+			"survivor_id":   survivorID,
+			"duplicate_ids": duplicateIDs,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge guests"})
+		return
+	}
+
+	if guest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guests merged", utils.Fields{
+		"merge_id":          merge.ID,
+		"survivor_id":       survivorID,
+		"duplicate_ids":     duplicateIDs,
+		"moved_appearances": merge.MovedAppearances,
+		"moved_consents":    merge.MovedConsents,
+	})
+
+	c.JSON(http.StatusOK, models.MergeGuestsResponse{
+		Success: true,
+		Data: &models.MergeGuestsData{
+			Guest: guest,
+			Merge: merge,
+		},
+	})
+}
+
+// UndoGuestMerge handles POST /api/v1/guest/merge/{merge_id}/undo
+// @Summary Undo a guest merge
+// @Description Restore the merged duplicates with their block bookings, consent records and availability and put the surviving guest back as it was. Only possible while the surviving guest is unchanged since the merge.
+// @Tags guests
+// @Produce json
+// @Param merge_id path string true "Merge ID"
+// @Success 200 {object} models.UndoGuestMergeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/merge/{merge_id}/undo [post]
+func (h *GuestHandler) UndoGuestMerge(c *gin.Context) {
+	mergeID, err := uuid.Parse(c.Param("merge_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge ID format"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	merge, err := h.db.UndoGuestMerge(c.Request.Context(), userUUID, mergeID)
+	if err != nil {
+		if errors.Is(err, database.ErrGuestMergeConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Merge was already undone or the guest changed since"})
+			return
+		}
+		if strings.Contains(err.Error(), "unique_user_guest") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A guest with the name of a merged guest exists now"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to undo guest merge", err, utils.Fields{
+			"merge_id": mergeID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo guest merge"})
+		return
+	}
+
+	if merge == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Merge not found"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest merge undone", utils.Fields{
+		"merge_id":    mergeID,
+		"survivor_id": merge.SurvivorID,
+	})
+
+	c.JSON(http.StatusOK, models.UndoGuestMergeResponse{
+		Success: true,
+		Data:    merge,
+	})
+}
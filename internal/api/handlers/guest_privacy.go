@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// GetGuestConsents handles GET /api/v1/guest/consents/{guest_id}
+// @Summary Get guest consents
+// @Description Current consent state for every purpose (contact, recording, publication, marketing) and the full consent history, latest first. A purpose is active when its latest record grants consent that hasn't expired.
+// @Tags guests
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Success 200 {object} models.GuestConsentsResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/consents/{guest_id} [get]
+func (h *GuestHandler) GetGuestConsents(c *gin.Context) {
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.db.ListGuestConsents(c.Request.Context(), guest.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to list guest consents", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve consents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestConsentsResponse{
+		Success: true,
+		Data: &models.GuestConsentsData{
+			Current: guests.CurrentConsents(history, time.Now()),
+			History: history,
+		},
+	})
+}
+
+// RecordGuestConsent handles POST /api/v1/guest/consents/{guest_id}
+// @Summary Record guest consent
+// @Description Record that a guest granted or withdrew consent for a purpose, how it was obtained and when. Records are never changed; a withdrawal is a new record with granted false.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Param request body models.RecordGuestConsentRequest true "Consent record"
+// @Success 201 {object} models.RecordGuestConsentResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/consents/{guest_id} [post]
+func (h *GuestHandler) RecordGuestConsent(c *gin.Context) {
+	var req models.RecordGuestConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if !req.Purpose.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Purpose must be contact, recording, publication or marketing"})
+		return
+	}
+	if !req.Method.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Method must be verbal, written, email, web_form, rsvp, contract, imported or other"})
+		return
+	}
+
+	now := time.Now()
+	obtainedAt := now
+	if req.ObtainedAt != nil {
+		if req.ObtainedAt.After(now.Add(time.Minute)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "obtained_at can't be in the future"})
+			return
+		}
+		obtainedAt = *req.ObtainedAt
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(obtainedAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be after obtained_at"})
+		return
+	}
+
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+	if guest.ErasedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Guest was erased"})
+		return
+	}
+
+	consent := &models.GuestConsent{
+		GuestID:    guest.ID,
+		Purpose:    req.Purpose,
+		Granted:    *req.Granted,
+		Method:     req.Method,
+		Evidence:   req.Evidence,
+		ObtainedAt: obtainedAt,
+		ExpiresAt:  req.ExpiresAt,
+		RecordedBy: &guest.UserID,
+	}
+	if err := h.db.RecordGuestConsent(c.Request.Context(), consent); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to record guest consent", err, utils.Fields{
+			"guest_id": guest.ID,
+			"purpose":  req.Purpose,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record consent"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest consent recorded", utils.Fields{
+		"guest_id": guest.ID,
+		"purpose":  consent.Purpose,
+		"granted":  consent.Granted,
+	})
+
+	c.JSON(http.StatusCreated, models.RecordGuestConsentResponse{
+		Success: true,
+		Data:    consent,
+	})
+}
+
+// ExportGuestPersonalData handles GET /api/v1/guest/personal-data/{guest_id}
+// @Summary Export guest personal data
+// @Description Download everything stored about a guest for a data subject access request: the guest record, consent history, all block bookings with their events including cancelled ones, records merged into the guest and the guest's audit trail. The export itself is recorded in the audit log.
+// @Tags guests
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Success 200 {object} models.GuestPersonalData
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/personal-data/{guest_id} [get]
+func (h *GuestHandler) ExportGuestPersonalData(c *gin.Context) {
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.db.GetGuestPersonalData(c.Request.Context(), guest.ID)
+	if err == nil && data == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found"})
+		return
+	}
+	if err == nil {
+		err = h.db.RecordAuditEntry(c.Request.Context(), "guest", guest.ID.String(), "export")
+	}
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to export guest personal data", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export personal data"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="guest-%s-%s.json"`, guest.ID, data.ExportedAt.Format("20060102")))
+	c.JSON(http.StatusOK, data)
+}
+
+// EraseGuest handles POST /api/v1/guest/erase/{guest_id}
+// @Summary Erase guest personal data
+// @Description Anonymise a guest for an erasure request. The guest and its block bookings stay so rundowns of past events keep their structure, but the name becomes a placeholder and contacts, notes, avatar, tags, metadata, booking notes, consent evidence and snapshots of merged records are removed, as are the personal fields of the audit entries of the guest and the duplicates merged into it. This can't be undone.
+// @Tags guests
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Success 200 {object} models.EraseGuestResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/erase/{guest_id} [post]
+func (h *GuestHandler) EraseGuest(c *gin.Context) {
+	guest, ok := h.getOwnedGuest(c)
+	if !ok {
+		return
+	}
+
+	before, erased, err := h.db.EraseGuest(c.Request.Context(), guest.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to erase guest", err, utils.Fields{
+			"guest_id": guest.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase guest"})
+		return
+	}
+	if erased == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found"})
+		return
+	}
+
+	if before.Avatar != nil {
+		if err := h.avatars.Remove(c.Request.Context(), guest.ID, *before.Avatar); err != nil {
+			utils.LogWarn(c.Request.Context(), "Failed to remove avatar of erased guest", utils.Fields{
+				"guest_id": guest.ID,
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest erased", utils.Fields{
+		"guest_id": guest.ID,
+	})
+
+	c.JSON(http.StatusOK, models.EraseGuestResponse{
+		Success: true,
+		Data:    erased,
+	})
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// ListGuestSegments handles GET /api/v1/guest/segments
+// @Summary List guest segments
+// @Description The user's saved guest segments and those shared by team mates, with the number of the user's guests in each. Pass a segment's ID as segment_id to the guest list or export to use it.
+// @Tags guests
+// @Produce json
+// @Success 200 {object} models.GuestSegmentsResponse
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/segments [get]
+func (h *GuestHandler) ListGuestSegments(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	segments, err := h.db.ListGuestSegments(c.Request.Context(), userUUID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to list guest segments", err, utils.Fields{
+			"user_id": userUUID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve segments"})
+		return
+	}
+
+	for i := range segments {
+		if !h.countSegmentGuests(c, userUUID, &segments[i]) {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.GuestSegmentsResponse{
+		Success: true,
+		Data:    segments,
+	})
+}
+
+// CreateGuestSegment handles POST /api/v1/guest/segments
+// @Summary Create guest segment
+// @Description Save guest filters as a named segment. Besides the guest list filters, segments can filter by appearance count and last appearance. Shared segments are visible to the user's team mates, who get their own guests matching the filters.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body models.CreateGuestSegmentRequest true "Segment"
+// @Success 201 {object} models.GuestSegmentResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/segments [post]
+func (h *GuestHandler) CreateGuestSegment(c *gin.Context) {
+	var req models.CreateGuestSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	segment := &models.GuestSegment{
+		UserID:  userUUID,
+		Filters: req.Filters,
+		Shared:  req.Shared,
+	}
+	if !applyGuestSegmentChanges(c, segment, &req.Name, req.Description, &req.Filters) {
+		return
+	}
+
+	if err := h.db.CreateGuestSegment(c.Request.Context(), segment); err != nil {
+		if strings.Contains(err.Error(), "unique_user_segment") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A segment with this name already exists"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to create guest segment", err, utils.Fields{
+			"user_id": userUUID,
+			"name":    segment.Name,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create segment"})
+		return
+	}
+
+	if !h.countSegmentGuests(c, userUUID, segment) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.GuestSegmentResponse{
+		Success: true,
+		Data:    segment,
+	})
+}
+
+// GetGuestSegment handles GET /api/v1/guest/segments/{segment_id}
+// @Summary Get guest segment
+// @Description A saved segment with the number of the user's guests matching it
+// @Tags guests
+// @Produce json
+// @Param segment_id path string true "Segment ID"
+// @Success 200 {object} models.GuestSegmentResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/segments/{segment_id} [get]
+func (h *GuestHandler) GetGuestSegment(c *gin.Context) {
+	segment, userUUID, ok := h.getVisibleGuestSegment(c, c.Param("segment_id"))
+	if !ok {
+		return
+	}
+
+	if !h.countSegmentGuests(c, userUUID, segment) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestSegmentResponse{
+		Success: true,
+		Data:    segment,
+	})
+}
+
+// UpdateGuestSegment handles PUT /api/v1/guest/segments/{segment_id}
+// @Summary Update guest segment
+// @Description Change a segment's name, description, filters or sharing. Only the owner can change a segment.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param segment_id path string true "Segment ID"
+// @Param request body models.UpdateGuestSegmentRequest true "Changes"
+// @Success 200 {object} models.GuestSegmentResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/segments/{segment_id} [put]
+func (h *GuestHandler) UpdateGuestSegment(c *gin.Context) {
+	var req models.UpdateGuestSegmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	segment, userUUID, ok := h.getVisibleGuestSegment(c, c.Param("segment_id"))
+	if !ok {
+		return
+	}
+	if segment.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change a segment"})
+		return
+	}
+
+	if !applyGuestSegmentChanges(c, segment, req.Name, req.Description, req.Filters) {
+		return
+	}
+	if req.Shared != nil {
+		segment.Shared = *req.Shared
+	}
+
+	if err := h.db.UpdateGuestSegment(c.Request.Context(), segment); err != nil {
+		if strings.Contains(err.Error(), "unique_user_segment") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A segment with this name already exists"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to update guest segment", err, utils.Fields{
+			"segment_id": segment.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update segment"})
+		return
+	}
+
+	if !h.countSegmentGuests(c, userUUID, segment) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestSegmentResponse{
+		Success: true,
+		Data:    segment,
+	})
+}
+
+// DeleteGuestSegment handles DELETE /api/v1/guest/segments/{segment_id}
+// @Summary Delete guest segment
+// @Description Delete a saved segment. Guests aren't touched. Only the owner can delete a segment.
+// @Tags guests
+// @Produce json
+// @Param segment_id path string true "Segment ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/segments/{segment_id} [delete]
+func (h *GuestHandler) DeleteGuestSegment(c *gin.Context) {
+	segment, userUUID, ok := h.getVisibleGuestSegment(c, c.Param("segment_id"))
+	if !ok {
+		return
+	}
+	if segment.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can delete a segment"})
+		return
+	}
+
+	if err := h.db.DeleteGuestSegment(c.Request.Context(), segment.ID); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to delete guest segment", err, utils.Fields{
+			"segment_id": segment.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete segment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Segment deleted"})
+}
+
+// guestListFilters returns the filters a guest list or export runs with: those of the saved
+// segment when the request names one, otherwise its own. Answers the request itself when
+// that fails.
+func (h *GuestHandler) guestListFilters(c *gin.Context, req *models.ListGuestsRequest) (models.GuestFilters, bool) {
+	if req.SegmentID == "" {
+		if err := guests.ValidateFilters(req.Filters); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filters", "details": err.Error()})
+			return models.GuestFilters{}, false
+		}
+		return req.Filters, true
+	}
+
+	segment, _, ok := h.getVisibleGuestSegment(c, req.SegmentID)
+	if !ok {
+		return models.GuestFilters{}, false
+	}
+	return segment.Filters, true
+}
+
+// getVisibleGuestSegment loads a segment the authenticated user owns or a team mate shared,
+// answering the request itself when that fails
+func (h *GuestHandler) getVisibleGuestSegment(c *gin.Context, idStr string) (*models.GuestSegment, uuid.UUID, bool) {
+	segmentID, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid segment ID format"})
+		return nil, uuid.Nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, uuid.Nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, uuid.Nil, false
+	}
+
+	segment, err := h.db.GetGuestSegment(c.Request.Context(), segmentID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest segment", err, utils.Fields{
+			"segment_id": segmentID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve segment"})
+		return nil, uuid.Nil, false
+	}
+	if segment == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Segment not found"})
+		return nil, uuid.Nil, false
+	}
+
+	visible, err := h.db.CanViewGuestSegment(c.Request.Context(), segment, userUUID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to check guest segment access", err, utils.Fields{
+			"segment_id": segmentID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve segment"})
+		return nil, uuid.Nil, false
+	}
+	if !visible {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, uuid.Nil, false
+	}
+
+	return segment, userUUID, true
+}
+
+// countSegmentGuests sets how many of the user's guests match a segment. Answers the request
+// itself when that fails.
+func (h *GuestHandler) countSegmentGuests(c *gin.Context, userID uuid.UUID, segment *models.GuestSegment) bool {
+	count, err := h.db.CountGuests(c.Request.Context(), userID, segment.Filters)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to count segment guests", err, utils.Fields{
+			"segment_id": segment.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count segment guests"})
+		return false
+	}
+	segment.GuestCount = &count
+	return true
+}
+
+// applyGuestSegmentChanges validates the given fields and sets them on the segment. An empty
+// description clears it. Answers the request itself when a field is invalid.
+func applyGuestSegmentChanges(c *gin.Context, segment *models.GuestSegment, name, description *string, filters *models.GuestFilters) bool {
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Segment name can't be empty"})
+			return false
+		}
+		if len(trimmed) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Segment name is too long"})
+			return false
+		}
+		segment.Name = trimmed
+	}
+
+	if description != nil {
+		if trimmed := strings.TrimSpace(*description); trimmed == "" {
+			segment.Description = nil
+		} else {
+			segment.Description = &trimmed
+		}
+	}
+
+	if filters != nil {
+		if err := guests.ValidateFilters(*filters); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filters", "details": err.Error()})
+			return false
+		}
+		segment.Filters = *filters
+	}
+
+	return true
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/guests"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// ListGuestTags handles GET /api/v1/guest/tags
+// @Summary List guest tags
+// @Description The user's tag vocabulary ordered by name, with the number of guests carrying each tag. Tags form a hierarchy through parent_id. Tags set on guests join the vocabulary automatically.
+// @Tags guests
+// @Produce json
+// @Success 200 {object} models.GuestTagsResponse
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/tags [get]
+func (h *GuestHandler) ListGuestTags(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	tags, err := h.db.ListGuestTags(c.Request.Context(), userUUID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to list guest tags", err, utils.Fields{
+			"user_id": userUUID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestTagsResponse{
+		Success: true,
+		Data:    tags,
+	})
+}
+
+// CreateGuestTag handles POST /api/v1/guest/tags
+// @Summary Create guest tag
+// @Description Add a tag to the vocabulary, optionally with a hex color like #3b82f6 and a parent tag.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body models.CreateGuestTagRequest true "Tag"
+// @Success 201 {object} models.GuestTagResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/tags [post]
+func (h *GuestHandler) CreateGuestTag(c *gin.Context) {
+	var req models.CreateGuestTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	tag := &models.GuestTag{UserID: userUUID}
+	if !h.applyGuestTagChanges(c, tag, &req.Name, req.Color, req.ParentID, req.Description) {
+		return
+	}
+
+	if err := h.db.CreateGuestTag(c.Request.Context(), tag); err != nil {
+		if strings.Contains(err.Error(), "idx_guest_tags_user_name") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to create guest tag", err, utils.Fields{
+			"user_id": userUUID,
+			"name":    tag.Name,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.GuestTagResponse{
+		Success: true,
+		Data:    tag,
+	})
+}
+
+// UpdateGuestTag handles PUT /api/v1/guest/tags/{tag_id}
+// @Summary Update guest tag
+// @Description Rename a tag, change its color, parent or description. A rename applies to every guest carrying the tag. Renaming to the name of another tag is refused; merge the tags instead.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param tag_id path string true "Tag ID"
+// @Param request body models.UpdateGuestTagRequest true "Changes"
+// @Success 200 {object} models.GuestTagChangeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/tags/{tag_id} [put]
+func (h *GuestHandler) UpdateGuestTag(c *gin.Context) {
+	var req models.UpdateGuestTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	tag, ok := h.getOwnedGuestTag(c, c.Param("tag_id"))
+	if !ok {
+		return
+	}
+
+	oldName := tag.Name
+	if !h.applyGuestTagChanges(c, tag, req.Name, req.Color, req.ParentID, req.Description) {
+		return
+	}
+
+	updated, err := h.db.UpdateGuestTag(c.Request.Context(), tag, oldName)
+	if err != nil {
+		if errors.Is(err, database.ErrGuestTagCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A tag can't be placed below itself or one of its children"})
+			return
+		}
+		if strings.Contains(err.Error(), "idx_guest_tags_user_name") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists, merge the tags instead"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to update guest tag", err, utils.Fields{
+			"tag_id": tag.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+
+	if tag.Name != oldName {
+		utils.LogInfo(c.Request.Context(), "Guest tag renamed", utils.Fields{
+			"tag_id":         tag.ID,
+			"old_name":       oldName,
+			"name":           tag.Name,
+			"updated_guests": updated,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.GuestTagChangeResponse{
+		Success: true,
+		Data: &models.GuestTagChangeData{
+			Tag:           tag,
+			UpdatedGuests: updated,
+		},
+	})
+}
+
+// MergeGuestTags handles POST /api/v1/guest/tags/merge
+// @Summary Merge guest tags
+// @Description Fold the source tags into the target tag. Guests carrying a source tag carry the target instead, tags below a source move below the target and the source tags are deleted. The target can't be below one of the sources.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body models.MergeGuestTagsRequest true "Tags to merge"
+// @Success 200 {object} models.GuestTagChangeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/tags/merge [post]
+func (h *GuestHandler) MergeGuestTags(c *gin.Context) {
+	var req models.MergeGuestTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	target, ok := h.getOwnedGuestTag(c, req.TargetID)
+	if !ok {
+		return
+	}
+
+	seen := map[uuid.UUID]bool{target.ID: true}
+	var sources []models.GuestTag
+	for _, idStr := range req.SourceIDs {
+		source, ok := h.getOwnedGuestTag(c, idStr)
+		if !ok {
+			return
+		}
+		if seen[source.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A tag can only be merged once and not into itself", "tag_id": idStr})
+			return
+		}
+		seen[source.ID] = true
+		sources = append(sources, *source)
+	}
+
+	updated, err := h.db.MergeGuestTags(c.Request.Context(), target, sources)
+	if err != nil {
+		if errors.Is(err, database.ErrGuestTagCycle) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The target tag is below one of the merged tags, move it first"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to merge guest tags", err, utils.Fields{
+			"target_id":  target.ID,
+			"source_ids": req.SourceIDs,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest tags merged", utils.Fields{
+		"target_id":      target.ID,
+		"source_ids":     req.SourceIDs,
+		"updated_guests": updated,
+	})
+
+	merged, err := h.db.GetGuestTag(c.Request.Context(), target.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest tag", err, utils.Fields{
+			"tag_id": target.ID,
+		})
+		merged = target
+	}
+
+	c.JSON(http.StatusOK, models.GuestTagChangeResponse{
+		Success: true,
+		Data: &models.GuestTagChangeData{
+			Tag:           merged,
+			UpdatedGuests: updated,
+		},
+	})
+}
+
+// DeleteGuestTag handles DELETE /api/v1/guest/tags/{tag_id}
+// @Summary Delete guest tag
+// @Description Remove a tag from the vocabulary and from every guest carrying it. Tags below it move up to its parent.
+// @Tags guests
+// @Produce json
+// @Param tag_id path string true "Tag ID"
+// @Success 200 {object} models.GuestTagChangeResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/tags/{tag_id} [delete]
+func (h *GuestHandler) DeleteGuestTag(c *gin.Context) {
+	tag, ok := h.getOwnedGuestTag(c, c.Param("tag_id"))
+	if !ok {
+		return
+	}
+
+	updated, err := h.db.DeleteGuestTag(c.Request.Context(), tag)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to delete guest tag", err, utils.Fields{
+			"tag_id": tag.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest tag deleted", utils.Fields{
+		"tag_id":         tag.ID,
+		"name":           tag.Name,
+		"updated_guests": updated,
+	})
+
+	c.JSON(http.StatusOK, models.GuestTagChangeResponse{
+		Success: true,
+		Data:    &models.GuestTagChangeData{UpdatedGuests: updated},
+	})
+}
+
+// getOwnedGuestTag loads a tag of the authenticated user, answering the request itself when
+// that fails
+func (h *GuestHandler) getOwnedGuestTag(c *gin.Context, idStr string) (*models.GuestTag, bool) {
+	tagID, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID format", "tag_id": idStr})
+		return nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
+	}
+
+	tag, err := h.db.GetGuestTag(c.Request.Context(), tagID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest tag", err, utils.Fields{
+			"tag_id": tagID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tag"})
+		return nil, false
+	}
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found", "tag_id": idStr})
+		return nil, false
+	}
+	if tag.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied", "tag_id": idStr})
+		return nil, false
+	}
+
+	return tag, true
+}
+
+// applyGuestTagChanges validates the given fields and sets them on the tag. Empty color,
+// parent and description clear them. Answers the request itself when a field is invalid.
+func (h *GuestHandler) applyGuestTagChanges(c *gin.Context, tag *models.GuestTag, name, color, parentID, description *string) bool {
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name can't be empty"})
+			return false
+		}
+		if utf8.RuneCountInString(trimmed) > guests.MaxTagLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is too long"})
+			return false
+		}
+		tag.Name = trimmed
+	}
+
+	if color != nil {
+		if *color == "" {
+			tag.Color = nil
+		} else if !guests.ValidTagColor(*color) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Color must be a hex color like #3b82f6"})
+			return false
+		} else {
+			lowered := strings.ToLower(*color)
+			tag.Color = &lowered
+		}
+	}
+
+	if description != nil {
+		if trimmed := strings.TrimSpace(*description); trimmed == "" {
+			tag.Description = nil
+		} else {
+			tag.Description = &trimmed
+		}
+	}
+
+	if parentID != nil {
+		if *parentID == "" {
+			tag.ParentID = nil
+			return true
+		}
+		parent, ok := h.getOwnedGuestTag(c, *parentID)
+		if !ok {
+			return false
+		}
+		if parent.ID == tag.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A tag can't be placed below itself or one of its children"})
+			return false
+		}
+		tag.ParentID = &parent.ID
+	}
+
+	return true
+}
+
+// normalizeGuestTags cleans up the tags set on a guest and spells them as in the user's
+// vocabulary, adding the new ones to it. Answers the request itself when that fails.
+func (h *GuestHandler) normalizeGuestTags(c *gin.Context, userID uuid.UUID, tags []string) ([]string, bool) {
+	normalized, err := guests.NormalizeTags(tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tags", "details": err.Error()})
+		return nil, false
+	}
+
+	normalized, err = h.db.EnsureGuestTags(c.Request.Context(), userID, normalized)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to add guest tags", err, utils.Fields{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+		return nil, false
+	}
+
+	return normalized, true
+}
@@ -11,17 +11,19 @@ import (
 
 	"github.com/denisAlshanov/stPlaner/internal/database"
 	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/avatar"
 	"github.com/denisAlshanov/stPlaner/internal/utils"
 )
 
 // GuestHandler handles guest-related HTTP requests
 type GuestHandler struct {
-	db *database.PostgresDB
+	db      *database.PostgresDB
+	avatars *avatar.Service
 }
 
 // NewGuestHandler creates a new guest handler
-func NewGuestHandler(db *database.PostgresDB) *GuestHandler {
-	return &GuestHandler{db: db}
+func NewGuestHandler(db *database.PostgresDB, avatars *avatar.Service) *GuestHandler {
+	return &GuestHandler{db: db, avatars: avatars}
 }
 
 // CreateGuest handles POST /api/v1/guest/new
@@ -62,6 +64,11 @@ func (h *GuestHandler) CreateGuest(c *gin.Context) {
 		return
 	}
 
+	tags, ok := h.normalizeGuestTags(c, userUUID, req.Tags)
+	if !ok {
+		return
+	}
+
 	// Create guest object
 	guest := &models.Guest{
 		UserID:    userUUID,
@@ -71,7 +78,7 @@ func (h *GuestHandler) CreateGuest(c *gin.Context) {
 		Contacts:  req.Contacts,
 		Notes:     req.Notes,
 		Avatar:    req.Avatar,
-		Tags:      req.Tags,
+		Tags:      tags,
 		Metadata:  req.Metadata,
 	}
 
@@ -174,6 +181,12 @@ func (h *GuestHandler) UpdateGuest(c *gin.Context) {
 		return
 	}
 
+	// Erased guests stay anonymous
+	if guest.ErasedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Guest was erased and can't be edited"})
+		return
+	}
+
 	// Update fields
 	updated := false
 
@@ -228,7 +241,11 @@ func (h *GuestHandler) UpdateGuest(c *gin.Context) {
 	}
 
 	if req.Tags != nil {
-		guest.Tags = req.Tags
+		tags, ok := h.normalizeGuestTags(c, userUUID, req.Tags)
+		if !ok {
+			return
+		}
+		guest.Tags = tags
 		updated = true
 	}
 
@@ -275,9 +292,11 @@ func (h *GuestHandler) UpdateGuest(c *gin.Context) {
 // @Tags guests
 // @Accept json
 // @Produce json
-// @Param request body models.ListGuestsRequest true "Guest list filters"
+// @Param request body models.ListGuestsRequest true "Guest list filters, or a saved segment"
 // @Success 200 {object} models.ListGuestsResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Security BearerAuth
 // @Router /api/v1/guest/list [post]
@@ -315,12 +334,17 @@ func (h *GuestHandler) ListGuests(c *gin.Context) {
 		req.Sort.Order = "asc"
 	}
 
+	filters, ok := h.guestListFilters(c, &req)
+	if !ok {
+		return
+	}
+
 	// Get guests from database
-	guests, total, err := h.db.ListGuests(c.Request.Context(), userUUID, req.Filters, req.Pagination, req.Sort)
+	guests, total, err := h.db.ListGuests(c.Request.Context(), userUUID, filters, req.Pagination, req.Sort)
 	if err != nil {
 		utils.LogError(c.Request.Context(), "Failed to list guests", err, utils.Fields{
 			"user_id": userUUID,
-			"filters": req.Filters,
+			"filters": filters,
 		})
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guests"})
 		return
@@ -460,12 +484,13 @@ func (h *GuestHandler) GetGuestInfo(c *gin.Context) {
 		return
 	}
 
-	// TODO: Implement guest stats (total shows, last appearance, upcoming shows)
-	// This would require additional database queries to events/shows tables
-	stats := &models.GuestStats{
-		TotalShows:     0,
-		LastAppearance: nil,
-		UpcomingShows:  0,
+	stats, err := h.db.GetGuestStats(c.Request.Context(), guestID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest stats", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest stats"})
+		return
 	}
 
 	c.JSON(http.StatusOK, models.GetGuestInfoResponse{
@@ -477,6 +502,95 @@ func (h *GuestHandler) GetGuestInfo(c *gin.Context) {
 	})
 }
 
+// GetGuestAppearances handles GET /api/v1/guest/appearances/{guest_id}
+// @Summary Get guest appearance timeline
+// @Description Get the events a guest is booked on, one entry per block, newest first. Cancelled events are left out.
+// @Tags guests
+// @Produce json
+// @Param guest_id path string true "Guest ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(20)
+// @Success 200 {object} models.GuestAppearancesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/guest/appearances/{guest_id} [get]
+func (h *GuestHandler) GetGuestAppearances(c *gin.Context) {
+	guestID, err := uuid.Parse(c.Param("guest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format"})
+		return
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	pagination := models.PaginationOptions{Page: 1, Limit: 20}
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			pagination.Page = p
+		}
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+			pagination.Limit = l
+		}
+	}
+
+	guest, err := h.db.GetGuestByID(c.Request.Context(), guestID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest"})
+		return
+	}
+
+	if guest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest not found"})
+		return
+	}
+
+	// Verify ownership
+	if guest.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	appearances, total, err := h.db.ListGuestAppearances(c.Request.Context(), guestID, pagination)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to list guest appearances", err, utils.Fields{
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve guest appearances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestAppearancesResponse{
+		Success: true,
+		Data: &models.GuestAppearancesData{
+			Appearances: appearances,
+			Pagination: models.PaginationResponse{
+				Page:       pagination.Page,
+				Limit:      pagination.Limit,
+				Total:      total,
+				TotalPages: (total + pagination.Limit - 1) / pagination.Limit,
+			},
+		},
+	})
+}
+
 // DeleteGuest handles DELETE /api/v1/guest/delete
 // @Summary Delete guest
 // @Description Delete a guest from the system
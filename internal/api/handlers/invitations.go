@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/invitation"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// InvitationHandler handles guest invitations and the public RSVP links
+type InvitationHandler struct {
+	db          *database.PostgresDB
+	invitations *invitation.Service
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(db *database.PostgresDB, invitations *invitation.Service) *InvitationHandler {
+	return &InvitationHandler{
+		db:          db,
+		invitations: invitations,
+	}
+}
+
+// InviteGuest handles POST /api/v1/block/{block_id}/guests/{guest_id}/invite
+// @Summary Invite a guest
+// @Description Email the guest of a block an RSVP link they can answer without an account. Inviting again sends a new link and a new reminder. The link is returned too, for guests without an email address.
+// @Tags blocks
+// @Produce json
+// @Param block_id path string true "Block ID"
+// @Param guest_id path string true "Guest ID"
+// @Success 200 {object} models.InviteGuestResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/block/{block_id}/guests/{guest_id}/invite [post]
+func (h *InvitationHandler) InviteGuest(c *gin.Context) {
+	booking, ok := h.getOwnedInvitation(c)
+	if !ok {
+		return
+	}
+
+	rsvpURL, emailSent, err := h.invitations.Invite(c.Request.Context(), booking)
+	if err != nil {
+		if errors.Is(err, invitation.ErrEventClosed) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is cancelled or over"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to invite guest", err, utils.Fields{
+			"block_id": booking.BlockID,
+			"guest_id": booking.GuestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite guest"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Guest invited", utils.Fields{
+		"block_id":   booking.BlockID,
+		"guest_id":   booking.GuestID,
+		"email_sent": emailSent,
+	})
+
+	c.JSON(http.StatusOK, models.InviteGuestResponse{
+		Success: true,
+		Data: &models.InviteGuestData{
+			Invitation: booking,
+			RSVPURL:    rsvpURL,
+			EmailSent:  emailSent,
+		},
+	})
+}
+
+// SetInvitationStatus handles PUT /api/v1/block/{block_id}/guests/{guest_id}/status
+// @Summary Set a guest's invitation status
+// @Description Record a guest's answer on their behalf, or mark them as a no-show
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param block_id path string true "Block ID"
+// @Param guest_id path string true "Guest ID"
+// @Param request body models.SetInvitationStatusRequest true "Invitation status: invited, confirmed, declined, tentative or no_show"
+// @Success 200 {object} models.GuestInvitationResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/block/{block_id}/guests/{guest_id}/status [put]
+func (h *InvitationHandler) SetInvitationStatus(c *gin.Context) {
+	var req models.SetInvitationStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	if !req.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation status", "status": req.Status})
+		return
+	}
+
+	booking, ok := h.getOwnedInvitation(c)
+	if !ok {
+		return
+	}
+
+	if err := h.invitations.SetStatus(c.Request.Context(), booking, req.Status); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to set invitation status", err, utils.Fields{
+			"block_id": booking.BlockID,
+			"guest_id": booking.GuestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set invitation status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.GuestInvitationResponse{
+		Success: true,
+		Data:    booking,
+	})
+}
+
+// GetRSVP handles GET /rsvp/{token}
+// @Summary Open an RSVP link
+// @Description Show a guest what they are invited to. No authentication; the signed token identifies the invitation.
+// @Tags rsvp
+// @Produce json
+// @Param token path string true "RSVP token"
+// @Success 200 {object} models.RSVPResponse
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /rsvp/{token} [get]
+func (h *InvitationHandler) GetRSVP(c *gin.Context) {
+	booking, err := h.invitations.Resolve(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.rsvpError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RSVPResponse{
+		Success: true,
+		Data:    rsvpDetails(booking),
+	})
+}
+
+// RespondRSVP handles POST /rsvp/{token}
+// @Summary Answer an RSVP link
+// @Description Confirm, decline or tentatively accept an invitation. The answer can be changed until the event ends.
+// @Tags rsvp
+// @Accept json
+// @Produce json
+// @Param token path string true "RSVP token"
+// @Param request body models.RSVPRequest true "Answer: confirmed, declined or tentative"
+// @Success 200 {object} models.RSVPResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /rsvp/{token} [post]
+func (h *InvitationHandler) RespondRSVP(c *gin.Context) {
+	var req models.RSVPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	booking, err := h.invitations.Respond(c.Request.Context(), c.Param("token"), req.Status)
+	if err != nil {
+		h.rsvpError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RSVPResponse{
+		Success: true,
+		Data:    rsvpDetails(booking),
+	})
+}
+
+func (h *InvitationHandler) rsvpError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, invitation.ErrInvalidLink):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case errors.Is(err, invitation.ErrExpiredLink):
+		c.JSON(http.StatusGone, gin.H{"error": "Invitation has expired"})
+	case errors.Is(err, invitation.ErrInvalidResponse):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be confirmed, declined or tentative"})
+	case errors.Is(err, invitation.ErrEventClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Event is cancelled or over"})
+	default:
+		utils.LogError(c.Request.Context(), "Failed to process RSVP", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process invitation"})
+	}
+}
+
+// getOwnedInvitation loads the booking named by the path and checks that the
+// current user owns its event, writing the error response otherwise
+func (h *InvitationHandler) getOwnedInvitation(c *gin.Context) (*models.GuestInvitation, bool) {
+	blockID, err := uuid.Parse(c.Param("block_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid block ID format"})
+		return nil, false
+	}
+
+	guestID, err := uuid.Parse(c.Param("guest_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest ID format"})
+		return nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
+	}
+
+	booking, err := h.db.GetGuestInvitation(c.Request.Context(), blockID, guestID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get guest invitation", err, utils.Fields{
+			"block_id": blockID,
+			"guest_id": guestID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitation"})
+		return nil, false
+	}
+
+	if booking == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Guest is not on this block"})
+		return nil, false
+	}
+
+	if booking.OwnerID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return booking, true
+}
+
+// rsvpDetails keeps what a guest sees to their own invitation
+func rsvpDetails(booking *models.GuestInvitation) *models.RSVPDetails {
+	return &models.RSVPDetails{
+		GuestName:     booking.GuestName,
+		EventTitle:    booking.EventTitle,
+		ShowName:      booking.ShowName,
+		BlockTitle:    booking.BlockTitle,
+		Role:          booking.Role,
+		StartDatetime: booking.StartDatetime,
+		Status:        booking.InvitationStatus,
+	}
+}
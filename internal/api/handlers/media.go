@@ -60,14 +60,8 @@ func (h *MediaHandler) GetLinkList(c *gin.Context) {
 	}
 
 	// Find the post by content ID
-	post, err := h.db.GetPostByContentID(ctx, req.ContentID)
-	if err != nil {
-		utils.LogError(ctx, "Failed to find post", err)
-		h.errorResponse(c, utils.NewDatabaseError(err))
-		return
-	}
-	if post == nil {
-		h.errorResponse(c, utils.NewPostNotFoundError(req.ContentID))
+	post, ok := h.getVisiblePost(c, req.ContentID)
+	if !ok {
 		return
 	}
 
@@ -128,17 +122,17 @@ func (h *MediaHandler) GetLinkMedia(c *gin.Context) {
 	}
 
 	// Find the media file
-	media, err := h.db.GetMediaByID(ctx, req.MediaID)
-	if err != nil {
-		utils.LogError(ctx, "Failed to find media", err)
-		h.errorResponse(c, utils.NewDatabaseError(err))
-		return
-	}
-	if media == nil {
-		h.errorResponse(c, utils.NewMediaNotFoundError(req.MediaID))
+	media, _, ok := h.getVisibleMedia(c, req.MediaID)
+	if !ok {
 		return
 	}
 
+	h.streamMedia(c, ctx, media)
+}
+
+// streamMedia writes a stored media file to the response, honouring range
+// requests for video files
+func (h *MediaHandler) streamMedia(c *gin.Context, ctx context.Context, media *models.Media) {
 	// Get file metadata for proper handling
 	metadata, err := h.storage.GetMetadata(ctx, media.S3Key)
 	if err != nil {
@@ -160,9 +154,9 @@ func (h *MediaHandler) GetLinkMedia(c *gin.Context) {
 
 	// Handle range requests for video files
 	if isVideo {
-		h.handleVideoStream(c, ctx, media, fileSize, req.MediaID)
+		h.handleVideoStream(c, ctx, media, fileSize, media.MediaID)
 	} else {
-		h.handleFileStream(c, ctx, media, fileSize, req.MediaID)
+		h.handleFileStream(c, ctx, media, fileSize, media.MediaID)
 	}
 }
 
@@ -223,7 +217,9 @@ func (h *MediaHandler) streamFullVideo(c *gin.Context, ctx context.Context, medi
 	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", media.FileName))
 
 	// Add cache headers for video
-	c.Header("Cache-Control", "public, max-age=3600")
+	if c.Writer.Header().Get("Cache-Control") == "" {
+		c.Header("Cache-Control", "public, max-age=3600")
+	}
 
 	// Download from S3
 	reader, err := h.storage.Download(ctx, media.S3Key)
@@ -305,7 +301,9 @@ func (h *MediaHandler) handleRangeRequest(c *gin.Context, ctx context.Context, m
 	c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
 	c.Header("Accept-Ranges", "bytes")
 	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", media.FileName))
-	c.Header("Cache-Control", "public, max-age=3600")
+	if c.Writer.Header().Get("Cache-Control") == "" {
+		c.Header("Cache-Control", "public, max-age=3600")
+	}
 	c.Status(http.StatusPartialContent)
 
 	// For now, we'll stream the full file and skip to the range
@@ -379,14 +377,8 @@ func (h *MediaHandler) GetLinkMediaURI(c *gin.Context) {
 	}
 
 	// Find the media file
-	media, err := h.db.GetMediaByID(ctx, req.MediaID)
-	if err != nil {
-		utils.LogError(ctx, "Failed to find media", err)
-		h.errorResponse(c, utils.NewDatabaseError(err))
-		return
-	}
-	if media == nil {
-		h.errorResponse(c, utils.NewMediaNotFoundError(req.MediaID))
+	media, _, ok := h.getVisibleMedia(c, req.MediaID)
+	if !ok {
 		return
 	}
 
@@ -410,13 +402,14 @@ func (h *MediaHandler) GetLinkMediaURI(c *gin.Context) {
 
 // UpdateLinkMedia godoc
 // @Summary Update media metadata
-// @Description Update media file metadata including filename and custom metadata
+// @Description Update media file metadata including filename, custom metadata, tags and notes
 // @Tags media
 // @Accept json
 // @Produce json
 // @Param request body models.UpdateMediaRequest true "Media update request"
 // @Success 200 {object} models.UpdateMediaResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
 // @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /api/v1/media/get [put]
@@ -433,14 +426,14 @@ func (h *MediaHandler) UpdateLinkMedia(c *gin.Context) {
 	}
 
 	// Find the media file
-	media, err := h.db.GetMediaByID(ctx, req.MediaID)
-	if err != nil {
-		utils.LogError(ctx, "Failed to find media", err)
-		h.errorResponse(c, utils.NewDatabaseError(err))
+	media, scope, ok := h.getVisibleMedia(c, req.MediaID)
+	if !ok {
 		return
 	}
-	if media == nil {
-		h.errorResponse(c, utils.NewMediaNotFoundError(req.MediaID))
+
+	// Only the owner may change a file; shared access is read-only
+	if !scope.All && (media.UserID == nil || *media.UserID != scope.UserID) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
 		return
 	}
 
@@ -460,6 +453,12 @@ func (h *MediaHandler) UpdateLinkMedia(c *gin.Context) {
 			media.Metadata[key] = value
 		}
 	}
+	if req.Tags != nil {
+		media.Tags = normalizeTags(*req.Tags)
+	}
+	if req.Notes != nil {
+		media.Notes = req.Notes
+	}
 
 	// Update in database
 	if err := h.db.UpdateMedia(ctx, media); err != nil {
@@ -502,14 +501,14 @@ func (h *MediaHandler) DeleteLinkMedia(c *gin.Context) {
 	}
 
 	// Find the media file first to get S3 info
-	media, err := h.db.GetMediaByID(ctx, req.MediaID)
-	if err != nil {
-		utils.LogError(ctx, "Failed to find media", err)
-		h.errorResponse(c, utils.NewDatabaseError(err))
+	media, scope, ok := h.getVisibleMedia(c, req.MediaID)
+	if !ok {
 		return
 	}
-	if media == nil {
-		h.errorResponse(c, utils.NewMediaNotFoundError(req.MediaID))
+
+	// Only the owner may delete a file; shared access is read-only
+	if !scope.All && (media.UserID == nil || *media.UserID != scope.UserID) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
 		return
 	}
 
@@ -539,6 +538,196 @@ func (h *MediaHandler) DeleteLinkMedia(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SearchMedia godoc
+// @Summary Search the media library
+// @Description Full-text search across file names, source captions, channel names, notes and tags with filters by type, size, duration, date and source
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param request body models.SearchMediaRequest true "Search filters, pagination and sorting"
+// @Success 200 {object} models.SearchMediaResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/search [post]
+// @Security BearerAuth
+func (h *MediaHandler) SearchMedia(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.SearchMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	for _, source := range req.Filters.Sources {
+		if source != models.MediaSourceTelegram && source != models.MediaSourceYouTube && source != models.MediaSourceUpload {
+			h.errorResponse(c, utils.NewValidationError("Invalid media source", map[string]interface{}{
+				"source":  source,
+				"allowed": []models.MediaSource{models.MediaSourceTelegram, models.MediaSourceYouTube, models.MediaSourceUpload},
+			}))
+			return
+		}
+	}
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	items, total, err := h.db.SearchMedia(ctx, scope, req.Filters, req.Pagination, req.Sort)
+	if err != nil {
+		utils.LogError(ctx, "Failed to search media", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	// Mirror the defaults applied by the database layer
+	page := req.Pagination.Page
+	if page <= 0 {
+		page = 1
+	}
+	limit := req.Pagination.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	c.JSON(http.StatusOK, models.SearchMediaResponse{
+		Success: true,
+		Data: &models.SearchMediaData{
+			Media: items,
+			Pagination: models.PaginationResponse{
+				Page:       page,
+				Limit:      limit,
+				Total:      total,
+				TotalPages: (total + limit - 1) / limit,
+			},
+		},
+	})
+}
+
+// ListMediaTags godoc
+// @Summary List media tags
+// @Description List all tags used in the media library with the number of media files per tag
+// @Tags media
+// @Produce json
+// @Success 200 {object} models.MediaTagsResponse
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/tags [get]
+// @Security BearerAuth
+func (h *MediaHandler) ListMediaTags(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	tags, err := h.db.ListMediaTags(ctx, scope)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list media tags", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MediaTagsResponse{
+		Success: true,
+		Data:    tags,
+	})
+}
+
+// getVisiblePost loads a post and checks that the authenticated user may see it.
+// Posts the user can't see are reported as missing so their existence isn't leaked.
+func (h *MediaHandler) getVisiblePost(c *gin.Context, contentID string) (*models.Post, bool) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return nil, false
+	}
+
+	post, err := h.db.GetPostByContentID(ctx, contentID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to find post", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, false
+	}
+	if post == nil {
+		h.errorResponse(c, utils.NewPostNotFoundError(contentID))
+		return nil, false
+	}
+
+	allowed, err := h.db.CanAccessPost(ctx, scope, post.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to check post access", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, false
+	}
+	if !allowed {
+		h.errorResponse(c, utils.NewPostNotFoundError(contentID))
+		return nil, false
+	}
+
+	return post, true
+}
+
+// getVisibleMedia loads a media file and checks that the authenticated user may see it
+func (h *MediaHandler) getVisibleMedia(c *gin.Context, mediaID string) (*models.Media, models.MediaScope, bool) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return nil, scope, false
+	}
+
+	media, err := h.db.GetMediaByID(ctx, mediaID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to find media", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, scope, false
+	}
+	if media == nil {
+		h.errorResponse(c, utils.NewMediaNotFoundError(mediaID))
+		return nil, scope, false
+	}
+
+	allowed, err := h.db.CanAccessMedia(ctx, scope, media.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to check media access", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, scope, false
+	}
+	if !allowed {
+		h.errorResponse(c, utils.NewMediaNotFoundError(mediaID))
+		return nil, scope, false
+	}
+
+	return media, scope, true
+}
+
+// normalizeTags trims, lowercases and deduplicates tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
 func (h *MediaHandler) errorResponse(c *gin.Context, err *utils.AppError) {
 	c.JSON(err.StatusCode, gin.H{
 		"error":      err,
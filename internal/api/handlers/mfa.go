@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/auth"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// VerifyMFA godoc
+// @Summary Complete login with a second factor
+// @Description Exchange the MFA token returned by login and a TOTP or recovery code for a session. If the user enrolled during login, the TOTP code confirms the enrollment and the new recovery codes are returned once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.MFAVerifyRequest true "MFA token and code"
+// @Success 200 {object} models.LoginResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/verify [post]
+func (h *AuthHandlers) VerifyMFA(c *gin.Context) {
+	utils.LogInfo(c, "MFA verification attempt")
+
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Either code or recovery_code is required",
+		})
+		return
+	}
+
+	user, recoveryCodes, err := h.mfaService.VerifyLogin(c, req.MFAToken, req.Code, req.RecoveryCode)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to verify MFA challenge", err)
+		return
+	}
+
+	if err := h.db.UpdateUserLoginTime(c, user.ID); err != nil {
+		utils.LogError(c, "Failed to update last login", err)
+		// Don't fail the login for this
+	}
+
+	deviceInfo := extractDeviceInfo(c, req.DeviceInfo)
+
+	tokenPair, err := h.sessionService.CreateSession(c, user, deviceInfo)
+	if err != nil {
+		utils.LogError(c, "Failed to create session", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "SESSION_ERROR",
+			Message: "Failed to create user session",
+		})
+		return
+	}
+
+	utils.LogInfo(c, fmt.Sprintf("User %s logged in successfully with MFA", user.Email))
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Success: true,
+		Data: &models.LoginResponseData{
+			TokenPair:     *tokenPair,
+			User:          user,
+			RecoveryCodes: recoveryCodes,
+		},
+	})
+}
+
+// EnrollMFAChallenge godoc
+// @Summary Enroll TOTP during login
+// @Description For users whose role requires MFA but who have not enrolled yet. Returns a new TOTP secret; complete the login with /auth/mfa/verify and a code from the authenticator app.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.MFAEnrollChallengeRequest true "MFA token returned by login"
+// @Success 200 {object} models.TOTPEnrollmentResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/enroll [post]
+func (h *AuthHandlers) EnrollMFAChallenge(c *gin.Context) {
+	var req models.MFAEnrollChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginChallengeEnrollment(c, req.MFAToken)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to start MFA enrollment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TOTPEnrollmentResponse{
+		Success: true,
+		Data:    enrollment,
+	})
+}
+
+// GetMFAStatus godoc
+// @Summary Get two-factor authentication status
+// @Description Get whether MFA is enabled or required for the current user
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.MFAStatusResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa [get]
+func (h *AuthHandlers) GetMFAStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.mfaService.GetStatus(c, userID)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to get MFA status", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFAStatusResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+// BeginTOTPEnrollment godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a TOTP secret for the current user. MFA is enabled once the enrollment is confirmed with a code.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TOTPEnrollmentResponse
+// @Failure 401 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/totp [post]
+func (h *AuthHandlers) BeginTOTPEnrollment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.db.GetUserByID(c, userID)
+	if err != nil || user == nil {
+		utils.LogError(c, "Failed to get user for MFA enrollment", err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "DATABASE_ERROR",
+			Message: "Failed to start MFA enrollment",
+		})
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginEnrollment(c, user)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to start MFA enrollment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TOTPEnrollmentResponse{
+		Success: true,
+		Data:    enrollment,
+	})
+}
+
+// ConfirmTOTPEnrollment godoc
+// @Summary Confirm TOTP enrollment
+// @Description Enable MFA with a code from the authenticator app. The recovery codes are returned only once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} models.MFARecoveryCodesResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/totp/confirm [post]
+func (h *AuthHandlers) ConfirmTOTPEnrollment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.mfaService.ConfirmEnrollment(c, userID, req.Code)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to confirm MFA enrollment", err)
+		return
+	}
+
+	utils.LogInfo(c, "MFA enabled", utils.Fields{"user_id": userID})
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{
+		Success: true,
+		Data:    &models.MFARecoveryCodesData{RecoveryCodes: codes},
+	})
+}
+
+// DisableTOTP godoc
+// @Summary Disable two-factor authentication
+// @Description Disable MFA for the current user after checking a current TOTP code. Not allowed when a role requires MFA.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} models.MFAMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/totp [delete]
+func (h *AuthHandlers) DisableTOTP(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.mfaService.Disable(c, userID, req.Code); err != nil {
+		h.mfaErrorResponse(c, "Failed to disable MFA", err)
+		return
+	}
+
+	utils.LogInfo(c, "MFA disabled", utils.Fields{"user_id": userID})
+
+	c.JSON(http.StatusOK, models.MFAMessageResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current user after checking a current TOTP code
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} models.MFARecoveryCodesResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/mfa/recovery-codes [post]
+func (h *AuthHandlers) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c, userID, req.Code)
+	if err != nil {
+		h.mfaErrorResponse(c, "Failed to regenerate recovery codes", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MFARecoveryCodesResponse{
+		Success: true,
+		Data:    &models.MFARecoveryCodesData{RecoveryCodes: codes},
+	})
+}
+
+// mfaErrorResponse maps MFA service errors to API errors
+func (h *AuthHandlers) mfaErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrMFAInvalidCode):
+		c.JSON(http.StatusUnauthorized, models.APIError{
+			Error:   "INVALID_MFA_CODE",
+			Message: "Invalid authentication code",
+		})
+	case errors.Is(err, auth.ErrMFAChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, models.APIError{
+			Error:   "INVALID_MFA_TOKEN",
+			Message: "MFA token is invalid or expired, please log in again",
+		})
+	case errors.Is(err, auth.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, models.APIError{
+			Error:   "MFA_ALREADY_ENABLED",
+			Message: "Two-factor authentication is already enabled",
+		})
+	case errors.Is(err, auth.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "MFA_NOT_ENABLED",
+			Message: "Two-factor authentication is not enabled",
+		})
+	case errors.Is(err, auth.ErrMFAEnrollmentNotStarted):
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "MFA_ENROLLMENT_NOT_STARTED",
+			Message: "Start the TOTP enrollment first",
+		})
+	case errors.Is(err, auth.ErrMFARequired):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "MFA_REQUIRED",
+			Message: "Two-factor authentication is required by your role",
+		})
+	default:
+		utils.LogError(c, message, err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "MFA_ERROR",
+			Message: message,
+		})
+	}
+}
+
+// currentUserID returns the authenticated user's ID, responding with 401 if there is none
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if exists {
+		if userUUID, ok := userID.(uuid.UUID); ok {
+			return userUUID, true
+		}
+	}
+
+	c.JSON(http.StatusUnauthorized, models.APIError{
+		Error:   "UNAUTHORIZED",
+		Message: "User not authenticated",
+	})
+	return uuid.Nil, false
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/auth"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// googleProvider is the provider behind the /auth/google endpoints
+const googleProvider = "google"
+
+// ListOIDCProviders godoc
+// @Summary List identity providers
+// @Description List the OpenID Connect providers users can sign in with
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} models.OIDCProviderListResponse
+// @Router /api/v1/auth/oidc/providers [get]
+func (h *AuthHandlers) ListOIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.OIDCProviderListResponse{
+		Success: true,
+		Data:    h.oidcService.Providers(),
+	})
+}
+
+// OIDCLogin godoc
+// @Summary Start OpenID Connect sign-in
+// @Description Generate the authorization URL of an identity provider. Send the code and state the provider redirects back with to the callback endpoint.
+// @Tags Authentication
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.OIDCLoginResponse
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (h *AuthHandlers) OIDCLogin(c *gin.Context) {
+	authURL, state, err := h.oidcService.BeginLogin(c, c.Param("provider"), nil)
+	if err != nil {
+		h.oidcErrorResponse(c, "Failed to initialize authentication", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OIDCLoginResponse{
+		Success: true,
+		Data:    &models.OIDCLoginResponseData{AuthURL: authURL, State: state},
+	})
+}
+
+// OIDCCallback godoc
+// @Summary Finish OpenID Connect sign-in
+// @Description Exchange the authorization code for a session. If the flow was started by the link endpoint the identity is linked instead and no session is created.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body models.OIDCCallbackRequest true "Authorization response"
+// @Success 200 {object} models.OIDCCallbackResponse
+// @Success 202 {object} models.MFAChallengeResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 403 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/oidc/{provider}/callback [post]
+func (h *AuthHandlers) OIDCCallback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_REQUEST",
+			Message: "Invalid callback request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	deviceInfo := extractDeviceInfo(c, req.DeviceInfo)
+
+	result, err := h.oidcService.HandleCallback(c, c.Param("provider"), req.Code, req.State, deviceInfo)
+	if err != nil {
+		h.oidcErrorResponse(c, "Failed to process authentication", err)
+		return
+	}
+	if result.MFAChallenge != nil {
+		c.JSON(http.StatusAccepted, models.MFAChallengeResponse{
+			Success: true,
+			Data:    result.MFAChallenge,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OIDCCallbackResponse{
+		Success: true,
+		Data: &models.OIDCCallbackResponseData{
+			TokenPair:      result.TokenPair,
+			User:           result.User,
+			IsNewUser:      result.IsNewUser,
+			LinkedIdentity: result.LinkedIdentity,
+		},
+	})
+}
+
+// OIDCLink godoc
+// @Summary Start linking an identity provider
+// @Description Generate the authorization URL of an identity provider; the callback links the identity to the current user
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.OIDCLoginResponse
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/oidc/{provider}/link [post]
+func (h *AuthHandlers) OIDCLink(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	authURL, state, err := h.oidcService.BeginLogin(c, c.Param("provider"), &userID)
+	if err != nil {
+		h.oidcErrorResponse(c, "Failed to initialize identity linking", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.OIDCLoginResponse{
+		Success: true,
+		Data:    &models.OIDCLoginResponseData{AuthURL: authURL, State: state},
+	})
+}
+
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description List the identity provider accounts linked to the current user
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.UserIdentityListResponse
+// @Failure 401 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/identities [get]
+func (h *AuthHandlers) ListIdentities(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.oidcService.ListIdentities(c, userID)
+	if err != nil {
+		h.oidcErrorResponse(c, "Failed to list linked identities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UserIdentityListResponse{
+		Success: true,
+		Data:    identities,
+	})
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink an identity
+// @Description Remove a linked identity provider account and the roles it granted. The only way a user without a password can sign in can't be removed.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param identity_id path string true "Identity ID"
+// @Success 200 {object} models.AccountMessageResponse
+// @Failure 400 {object} models.APIError
+// @Failure 401 {object} models.APIError
+// @Failure 404 {object} models.APIError
+// @Failure 409 {object} models.APIError
+// @Failure 500 {object} models.APIError
+// @Router /api/v1/auth/identities/{identity_id} [delete]
+func (h *AuthHandlers) UnlinkIdentity(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	identityID, err := uuid.Parse(c.Param("identity_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_IDENTITY_ID",
+			Message: "Invalid identity ID format",
+		})
+		return
+	}
+
+	if err := h.oidcService.Unlink(c, userID, identityID); err != nil {
+		h.oidcErrorResponse(c, "Failed to unlink identity", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.AccountMessageResponse{
+		Success: true,
+		Message: "Identity unlinked",
+	})
+}
+
+// oidcErrorResponse maps OpenID Connect service errors to API errors
+func (h *AuthHandlers) oidcErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrOIDCProviderNotFound):
+		c.JSON(http.StatusNotFound, models.APIError{
+			Error:   "OIDC_PROVIDER_NOT_FOUND",
+			Message: "Unknown identity provider",
+		})
+	case errors.Is(err, auth.ErrOIDCStateInvalid):
+		c.JSON(http.StatusBadRequest, models.APIError{
+			Error:   "INVALID_OAUTH_STATE",
+			Message: "Sign-in request is invalid or expired, please start again",
+		})
+	case errors.Is(err, auth.ErrOIDCAuthFailed):
+		utils.LogWarn(c, message, utils.Fields{"error": err.Error()})
+		c.JSON(http.StatusUnauthorized, models.APIError{
+			Error:   "OAUTH_CALLBACK_ERROR",
+			Message: message,
+		})
+	case errors.Is(err, auth.ErrOIDCEmailNotVerified):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "EMAIL_NOT_VERIFIED",
+			Message: "The identity provider did not verify your email address",
+		})
+	case errors.Is(err, auth.ErrOIDCSignupDisabled):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "OIDC_SIGNUP_DISABLED",
+			Message: "No account is linked to this identity",
+		})
+	case errors.Is(err, auth.ErrOIDCUserInactive):
+		c.JSON(http.StatusForbidden, models.APIError{
+			Error:   "ACCOUNT_INACTIVE",
+			Message: "User account is not active",
+		})
+	case errors.Is(err, auth.ErrOIDCAccountExists):
+		c.JSON(http.StatusConflict, models.APIError{
+			Error:   "ACCOUNT_EXISTS",
+			Message: "An account with this email already exists, sign in and link the identity from your account",
+		})
+	case errors.Is(err, auth.ErrOIDCIdentityLinked):
+		c.JSON(http.StatusConflict, models.APIError{
+			Error:   "ACCOUNT_ALREADY_LINKED",
+			Message: "This identity is already linked to another user",
+		})
+	case errors.Is(err, auth.ErrOIDCIdentityNotFound):
+		c.JSON(http.StatusNotFound, models.APIError{
+			Error:   "IDENTITY_NOT_FOUND",
+			Message: "Linked identity not found",
+		})
+	case errors.Is(err, auth.ErrOIDCLastLoginMethod):
+		c.JSON(http.StatusConflict, models.APIError{
+			Error:   "LAST_LOGIN_METHOD",
+			Message: "Set a password or link another identity before unlinking this one",
+		})
+	default:
+		utils.LogError(c, message, err)
+		c.JSON(http.StatusInternalServerError, models.APIError{
+			Error:   "OAUTH_ERROR",
+			Message: message,
+		})
+	}
+}
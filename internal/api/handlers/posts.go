@@ -50,8 +50,14 @@ func (h *PostHandler) AddPost(c *gin.Context) {
 		return
 	}
 
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
 	// Process the post
-	post, err := h.downloader.ProcessPost(ctx, req.Link)
+	post, err := h.downloader.ProcessPost(ctx, req.Link, scope.UserID)
 	if err != nil {
 		if appErr, ok := err.(*utils.AppError); ok {
 			h.errorResponse(c, appErr)
@@ -80,7 +86,7 @@ func (h *PostHandler) AddPost(c *gin.Context) {
 
 // GetList godoc
 // @Summary Get list of processed posts
-// @Description Retrieve list of all previously processed Telegram and YouTube links
+// @Description Retrieve list of previously processed Telegram and YouTube links grabbed by the user or their teams
 // @Tags media
 // @Accept json
 // @Produce json
@@ -106,8 +112,14 @@ func (h *PostHandler) GetList(c *gin.Context) {
 		limit = 20
 	}
 
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
 	// Get posts with pagination
-	posts, total, err := h.db.ListPosts(ctx, models.PaginationOptions{
+	posts, total, err := h.db.ListPosts(ctx, scope, models.PaginationOptions{
 		Page:  page,
 		Limit: limit,
 		Sort:  sort,
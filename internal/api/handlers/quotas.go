@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/quota"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+type QuotaHandler struct {
+	db    *database.PostgresDB
+	quota *quota.Service
+}
+
+func NewQuotaHandler(db *database.PostgresDB, quota *quota.Service) *QuotaHandler {
+	return &QuotaHandler{
+		db:    db,
+		quota: quota,
+	}
+}
+
+// GetMyUsage godoc
+// @Summary Get own media usage
+// @Description Get the storage used by the authenticated user together with the byte and file limits that apply to them
+// @Tags media
+// @Produce json
+// @Success 200 {object} models.MediaUsageResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/usage [get]
+// @Security BearerAuth
+func (h *QuotaHandler) GetMyUsage(c *gin.Context) {
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	h.respondWithUsage(c, scope.UserID)
+}
+
+// GetUserUsage godoc
+// @Summary Get media usage of a user
+// @Description Get the storage used by a user and the limits that apply to them. Requires the users:read permission unless it is the caller's own usage.
+// @Tags media
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.MediaUsageResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/usage/{user_id} [get]
+// @Security BearerAuth
+func (h *QuotaHandler) GetUserUsage(c *gin.Context) {
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid user ID format", nil))
+		return
+	}
+
+	if userID != scope.UserID && !hasPermission(c, models.PermissionUsersRead) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	h.respondWithUsage(c, userID)
+}
+
+// SetUserQuota godoc
+// @Summary Set the media quota of a user
+// @Description Set byte and file limits for a single user, overriding role quotas and the default. Omitted limits are unlimited. Requires the users:update permission.
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Param request body models.SetMediaQuotaRequest true "Quota limits"
+// @Success 200 {object} models.MediaQuotaResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/quotas/users/{user_id} [put]
+// @Security BearerAuth
+func (h *QuotaHandler) SetUserQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !hasPermission(c, models.PermissionUsersUpdate) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid user ID format", nil))
+		return
+	}
+
+	var req models.SetMediaQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	user, err := h.db.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get user", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+	if user == nil {
+		h.errorResponse(c, utils.NewNotFoundError("User not found"))
+		return
+	}
+
+	h.setQuota(c, &models.MediaQuota{
+		UserID:   &userID,
+		MaxBytes: req.MaxBytes,
+		MaxFiles: req.MaxFiles,
+	})
+}
+
+// DeleteUserQuota godoc
+// @Summary Remove the media quota of a user
+// @Description Remove the user-specific quota so role quotas or the default apply again. Requires the users:update permission.
+// @Tags media
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/quotas/users/{user_id} [delete]
+// @Security BearerAuth
+func (h *QuotaHandler) DeleteUserQuota(c *gin.Context) {
+	if !hasPermission(c, models.PermissionUsersUpdate) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid user ID format", nil))
+		return
+	}
+
+	h.deleteQuota(c, &userID, nil)
+}
+
+// SetRoleQuota godoc
+// @Summary Set the media quota of a role
+// @Description Set byte and file limits for every member of a role. A user with several roles gets the most generous limits. Requires the roles:update permission.
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param role_id path string true "Role ID"
+// @Param request body models.SetMediaQuotaRequest true "Quota limits"
+// @Success 200 {object} models.MediaQuotaResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/quotas/roles/{role_id} [put]
+// @Security BearerAuth
+func (h *QuotaHandler) SetRoleQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !hasPermission(c, models.PermissionRolesUpdate) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid role ID format", nil))
+		return
+	}
+
+	var req models.SetMediaQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	role, err := h.db.GetRoleByID(ctx, roleID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get role", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+	if role == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Role not found"))
+		return
+	}
+
+	h.setQuota(c, &models.MediaQuota{
+		RoleID:   &roleID,
+		MaxBytes: req.MaxBytes,
+		MaxFiles: req.MaxFiles,
+	})
+}
+
+// DeleteRoleQuota godoc
+// @Summary Remove the media quota of a role
+// @Description Remove the quota of a role. Requires the roles:update permission.
+// @Tags media
+// @Produce json
+// @Param role_id path string true "Role ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/quotas/roles/{role_id} [delete]
+// @Security BearerAuth
+func (h *QuotaHandler) DeleteRoleQuota(c *gin.Context) {
+	if !hasPermission(c, models.PermissionRolesUpdate) {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid role ID format", nil))
+		return
+	}
+
+	h.deleteQuota(c, nil, &roleID)
+}
+
+func (h *QuotaHandler) respondWithUsage(c *gin.Context, userID uuid.UUID) {
+	ctx := c.Request.Context()
+
+	usage, err := h.quota.GetUsage(ctx, userID)
+	if err != nil {
+		if appErr, ok := err.(*utils.AppError); ok {
+			h.errorResponse(c, appErr)
+		} else {
+			utils.LogError(ctx, "Failed to get media usage", err)
+			h.errorResponse(c, utils.NewInternalError())
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MediaUsageResponse{
+		Success: true,
+		Data:    usage,
+	})
+}
+
+func (h *QuotaHandler) setQuota(c *gin.Context, quota *models.MediaQuota) {
+	ctx := c.Request.Context()
+
+	if err := h.db.SetMediaQuota(ctx, quota); err != nil {
+		utils.LogError(ctx, "Failed to set media quota", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	utils.LogInfo(ctx, "Media quota updated", utils.Fields{
+		"quota_id": quota.ID,
+		"user_id":  quota.UserID,
+		"role_id":  quota.RoleID,
+	})
+
+	c.JSON(http.StatusOK, models.MediaQuotaResponse{
+		Success: true,
+		Data:    quota,
+	})
+}
+
+func (h *QuotaHandler) deleteQuota(c *gin.Context, userID, roleID *uuid.UUID) {
+	ctx := c.Request.Context()
+
+	deleted, err := h.db.DeleteMediaQuota(ctx, userID, roleID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to delete media quota", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+	if !deleted {
+		h.errorResponse(c, utils.NewNotFoundError("Quota not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Quota removed successfully",
+	})
+}
+
+func (h *QuotaHandler) errorResponse(c *gin.Context, err *utils.AppError) {
+	c.JSON(err.StatusCode, gin.H{
+		"error":      err,
+		"request_id": c.GetString("request_id"),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
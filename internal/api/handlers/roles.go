@@ -513,4 +513,64 @@ func (h *RoleHandler) AddUserToRole(c *gin.Context) {
 		Success: true,
 		Message: "User added to role successfully",
 	})
-}
\ No newline at end of file
+}
+// SetRoleMFA handles PUT /api/v1/roles/{role_id}/mfa
+// @Summary Require two-factor authentication for a role
+// @Description Members of a role that requires MFA must complete a second factor at login, enrolling first if needed
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param role_id path string true "Role ID"
+// @Param request body models.SetRoleMFARequest true "MFA requirement"
+// @Success 200 {object} models.RoleMFAResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/roles/{role_id}/mfa [put]
+func (h *RoleHandler) SetRoleMFA(c *gin.Context) {
+	if !hasPermission(c, models.PermissionRolesUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("role_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID format"})
+		return
+	}
+
+	var req models.SetRoleMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.db.SetRoleMFARequired(c.Request.Context(), roleID, *req.Required)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to update role MFA requirement", err, utils.Fields{
+			"role_id": roleID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Role MFA requirement updated", utils.Fields{
+		"role_id":     roleID,
+		"require_mfa": *req.Required,
+	})
+
+	c.JSON(http.StatusOK, models.RoleMFAResponse{
+		Success: true,
+		Data: &models.RoleMFAData{
+			RoleID:     roleID,
+			RequireMFA: *req.Required,
+		},
+	})
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// defaultTemplateBlockLength matches the default estimated length of a block
+const defaultTemplateBlockLength = 5
+
+// GetRundownTemplate handles GET /api/v1/shows/{show_id}/rundown-template
+// @Summary Get a show's rundown template
+// @Description Get the blocks every episode of the show starts with
+// @Tags blocks
+// @Produce json
+// @Param show_id path string true "Show ID"
+// @Success 200 {object} models.RundownTemplateResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/shows/{show_id}/rundown-template [get]
+func (h *BlockHandler) GetRundownTemplate(c *gin.Context) {
+	show, ok := h.getOwnedShow(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.db.GetRundownTemplate(c.Request.Context(), show.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get rundown template", err, utils.Fields{
+			"show_id": show.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rundown template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Show has no rundown template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RundownTemplateResponse{
+		Success: true,
+		Data:    template,
+	})
+}
+
+// SetRundownTemplate handles PUT /api/v1/shows/{show_id}/rundown-template
+// @Summary Set a show's rundown template
+// @Description Replace the blocks every episode of the show starts with. Title patterns can use {show}, {title}, {date}, {time} and {episode}. With auto_apply on, newly generated events get the template's blocks.
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param show_id path string true "Show ID"
+// @Param request body models.SetRundownTemplateRequest true "Template blocks"
+// @Success 200 {object} models.RundownTemplateResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/shows/{show_id}/rundown-template [put]
+func (h *BlockHandler) SetRundownTemplate(c *gin.Context) {
+	var req models.SetRundownTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+
+	show, ok := h.getOwnedShow(c)
+	if !ok {
+		return
+	}
+
+	if err := normalizeTemplateBlocks(req.Blocks); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rundown template", "details": err.Error()})
+		return
+	}
+
+	template := &models.RundownTemplate{
+		ShowID:    show.ID,
+		Blocks:    req.Blocks,
+		AutoApply: req.AutoApply == nil || *req.AutoApply,
+	}
+	if err := h.db.SetRundownTemplate(c.Request.Context(), template); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to set rundown template", err, utils.Fields{
+			"show_id": show.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rundown template"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Rundown template updated", utils.Fields{
+		"show_id":    show.ID,
+		"blocks":     len(template.Blocks),
+		"auto_apply": template.AutoApply,
+	})
+
+	c.JSON(http.StatusOK, models.RundownTemplateResponse{
+		Success: true,
+		Data:    template,
+	})
+}
+
+// DeleteRundownTemplate handles DELETE /api/v1/shows/{show_id}/rundown-template
+// @Summary Delete a show's rundown template
+// @Description Remove the show's rundown template. Blocks already created from it are kept.
+// @Tags blocks
+// @Produce json
+// @Param show_id path string true "Show ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/shows/{show_id}/rundown-template [delete]
+func (h *BlockHandler) DeleteRundownTemplate(c *gin.Context) {
+	show, ok := h.getOwnedShow(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteRundownTemplate(c.Request.Context(), show.ID); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to delete rundown template", err, utils.Fields{
+			"show_id": show.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rundown template"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Rundown template deleted", utils.Fields{
+		"show_id": show.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Rundown template deleted"})
+}
+
+// ApplyRundownTemplate handles POST /api/v1/events/{event_id}/rundown-template/apply
+// @Summary Apply the show's rundown template to an event
+// @Description Create the template's blocks after the event's blocks, or in their place with replace. Blocks with guests are never replaced.
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param event_id path string true "Event ID"
+// @Param request body models.ApplyRundownTemplateRequest false "Apply options"
+// @Success 200 {object} models.ApplyRundownTemplateResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/events/{event_id}/rundown-template/apply [post]
+func (h *BlockHandler) ApplyRundownTemplate(c *gin.Context) {
+	var req models.ApplyRundownTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+			return
+		}
+	}
+
+	event, ok := h.getOwnedEvent(c, c.Param("event_id"))
+	if !ok {
+		return
+	}
+
+	template, err := h.db.GetRundownTemplate(c.Request.Context(), event.ShowID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get rundown template", err, utils.Fields{
+			"show_id": event.ShowID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rundown template"})
+		return
+	}
+	if template == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Show has no rundown template"})
+		return
+	}
+
+	created, err := h.db.ApplyRundownTemplate(c.Request.Context(), event, template, req.Replace)
+	if err != nil {
+		if errors.Is(err, database.ErrRundownHasGuests) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event blocks have guests and can't be replaced"})
+			return
+		}
+		utils.LogError(c.Request.Context(), "Failed to apply rundown template", err, utils.Fields{
+			"event_id": event.ID,
+			"show_id":  event.ShowID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply rundown template"})
+		return
+	}
+
+	blocks, err := h.db.GetEventBlocks(c.Request.Context(), event.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get event blocks", err, utils.Fields{
+			"event_id": event.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Template applied but failed to retrieve blocks"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Rundown template applied", utils.Fields{
+		"event_id": event.ID,
+		"show_id":  event.ShowID,
+		"created":  created,
+		"replace":  req.Replace,
+	})
+
+	c.JSON(http.StatusOK, models.ApplyRundownTemplateResponse{
+		Success: true,
+		Data: &models.ApplyRundownTemplateData{
+			EventID: event.ID,
+			Created: created,
+			Blocks:  blocks,
+		},
+	})
+}
+
+// SaveRundownTemplate handles POST /api/v1/events/{event_id}/rundown-template/save
+// @Summary Save an event's blocks as the show's rundown template
+// @Description Replace the show's rundown template with the event's blocks and their media. Guests aren't part of the template.
+// @Tags blocks
+// @Accept json
+// @Produce json
+// @Param event_id path string true "Event ID"
+// @Param request body models.SaveRundownTemplateRequest false "Save options"
+// @Success 200 {object} models.RundownTemplateResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/events/{event_id}/rundown-template/save [post]
+func (h *BlockHandler) SaveRundownTemplate(c *gin.Context) {
+	var req models.SaveRundownTemplateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+			return
+		}
+	}
+
+	event, ok := h.getOwnedEvent(c, c.Param("event_id"))
+	if !ok {
+		return
+	}
+
+	blocks, err := h.db.RundownTemplateFromEvent(c.Request.Context(), event.ID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to read event blocks for template", err, utils.Fields{
+			"event_id": event.ID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blocks"})
+		return
+	}
+	if len(blocks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Event has no blocks"})
+		return
+	}
+
+	current, err := h.db.GetRundownTemplate(c.Request.Context(), event.ShowID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get rundown template", err, utils.Fields{
+			"show_id": event.ShowID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve rundown template"})
+		return
+	}
+
+	template := &models.RundownTemplate{
+		ShowID:    event.ShowID,
+		Blocks:    blocks,
+		AutoApply: current == nil || current.AutoApply,
+	}
+	if req.AutoApply != nil {
+		template.AutoApply = *req.AutoApply
+	}
+	if err := h.db.SetRundownTemplate(c.Request.Context(), template); err != nil {
+		utils.LogError(c.Request.Context(), "Failed to set rundown template", err, utils.Fields{
+			"show_id": event.ShowID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rundown template"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "Rundown template saved from event", utils.Fields{
+		"event_id": event.ID,
+		"show_id":  event.ShowID,
+		"blocks":   len(blocks),
+	})
+
+	c.JSON(http.StatusOK, models.RundownTemplateResponse{
+		Success: true,
+		Data:    template,
+	})
+}
+
+// getOwnedShow loads the show of the show_id path parameter and checks that the current
+// user owns it, writing the error response otherwise
+func (h *BlockHandler) getOwnedShow(c *gin.Context) (*models.Show, bool) {
+	showID, err := uuid.Parse(c.Param("show_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid show ID format"})
+		return nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
+	}
+
+	show, err := h.db.GetShowByID(c.Request.Context(), showID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get show", err, utils.Fields{
+			"show_id": showID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve show"})
+		return nil, false
+	}
+	if show == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Show not found"})
+		return nil, false
+	}
+	if show.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return show, true
+}
+
+// getOwnedEvent loads an event and checks that the current user owns it, writing the error
+// response otherwise
+func (h *BlockHandler) getOwnedEvent(c *gin.Context, idStr string) (*models.Event, bool) {
+	eventID, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID format"})
+		return nil, false
+	}
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user ID format"})
+		return nil, false
+	}
+
+	event, err := h.db.GetEventByID(c.Request.Context(), eventID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to get event", err, utils.Fields{
+			"event_id": eventID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve event"})
+		return nil, false
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if event.UserID != userUUID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+
+	return event, true
+}
+
+// normalizeTemplateBlocks fills in template block defaults and validates the blocks
+func normalizeTemplateBlocks(blocks []models.RundownTemplateBlock) error {
+	for i := range blocks {
+		block := &blocks[i]
+
+		if block.BlockType == "" {
+			block.BlockType = models.BlockTypeCustom
+		}
+		if !isValidBlockType(block.BlockType) {
+			return fmt.Errorf("block %d: invalid block type %q", i, block.BlockType)
+		}
+
+		block.TitlePattern = strings.TrimSpace(block.TitlePattern)
+		if block.TitlePattern == "" {
+			return fmt.Errorf("block %d: title pattern is required", i)
+		}
+
+		if block.EstimatedLength == 0 {
+			block.EstimatedLength = defaultTemplateBlockLength
+		}
+		if block.EstimatedLength < 0 {
+			return fmt.Errorf("block %d: estimated length must be positive", i)
+		}
+
+		for _, media := range block.Media {
+			if _, err := uuid.Parse(media.MediaID); err != nil {
+				return fmt.Errorf("block %d: invalid media ID %q", i, media.MediaID)
+			}
+			if media.MediaType == "" {
+				return fmt.Errorf("block %d: media type is required", i)
+			}
+		}
+	}
+	return nil
+}
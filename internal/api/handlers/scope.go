@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// hasPermission reports whether the authenticated user's token carries a permission
+func hasPermission(c *gin.Context, permission string) bool {
+	value, exists := c.Get("user_permissions")
+	if !exists {
+		return false
+	}
+
+	permissions, ok := value.([]string)
+	if !ok {
+		return false
+	}
+
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaScope returns the media visibility scope of the authenticated user
+func mediaScope(c *gin.Context) (models.MediaScope, *utils.AppError) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return models.MediaScope{}, utils.NewAuthError("User not authenticated")
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		return models.MediaScope{}, utils.NewAuthError("Invalid user ID")
+	}
+
+	return models.MediaScope{
+		UserID: userUUID,
+		All:    hasPermission(c, models.PermissionMediaManageAll),
+	}, nil
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/secrets"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// maskShowSecrets replaces the stream keys and Zoom passcode of a show before it is returned
+func maskShowSecrets(show *models.Show) {
+	show.YouTubeKey = secrets.Mask(show.YouTubeKey)
+	show.AdditionalKey = maskSecret(show.AdditionalKey)
+	show.ZoomPasscode = maskSecret(show.ZoomPasscode)
+}
+
+func maskSecret(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	masked := secrets.Mask(*value)
+	return &masked
+}
+
+// RevealShowSecrets handles GET /api/v1/shows/{show_id}/secrets
+// @Summary Reveal show secrets
+// @Description Get the stream keys and Zoom passcode of a show in plain text. Every other show response masks them. Requires the secrets:reveal permission; each reveal is recorded in the audit log.
+// @Tags shows
+// @Produce json
+// @Param show_id path string true "Show ID"
+// @Success 200 {object} models.SecretsResponseREST
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/shows/{show_id}/secrets [get]
+func (h *ShowHandler) RevealShowSecrets(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !hasPermission(c, models.PermissionSecretsReveal) {
+		h.errorResponse(c, utils.NewForbiddenError("Insufficient permissions"))
+		return
+	}
+
+	showID, err := uuid.Parse(c.Param("show_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid show ID format", map[string]interface{}{
+			"field": "show_id",
+		}))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return
+	}
+
+	show, err := h.db.GetShowByID(ctx, showID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get show", err, utils.Fields{
+			"show_id": showID,
+			"user_id": userUUID,
+		})
+		h.errorResponse(c, utils.NewInternalErrorWithMessage("Failed to retrieve show"))
+		return
+	}
+
+	if show == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Show not found"))
+		return
+	}
+
+	if show.UserID != userUUID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	// Secrets are only handed out once the reveal is on record
+	if err := h.db.RecordAuditEntry(ctx, "show", showID.String(), "reveal"); err != nil {
+		utils.LogError(ctx, "Failed to audit secret reveal", err, utils.Fields{
+			"show_id": showID,
+		})
+		h.errorResponse(c, utils.NewInternalErrorWithMessage("Failed to reveal secrets"))
+		return
+	}
+
+	utils.LogInfo(ctx, "Show secrets revealed", utils.Fields{
+		"show_id": showID,
+		"user_id": userUUID,
+	})
+
+	c.JSON(http.StatusOK, models.SecretsResponseREST{
+		Success: true,
+		Data: &models.SecretsREST{
+			YouTubeKey:    show.YouTubeKey,
+			AdditionalKey: show.AdditionalKey,
+			ZoomPasscode:  show.ZoomPasscode,
+		},
+	})
+}
+
+// RevealEventSecrets handles GET /api/v1/events/{event_id}/secrets
+// @Summary Reveal event secrets
+// @Description Get the stream keys and Zoom passcode of an event in plain text, falling back to the show's where the event doesn't override them. Every other event response masks them. Requires the secrets:reveal permission; each reveal is recorded in the audit log.
+// @Tags events
+// @Produce json
+// @Param event_id path string true "Event ID"
+// @Success 200 {object} models.SecretsResponseREST
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/events/{event_id}/secrets [get]
+func (h *EventHandler) RevealEventSecrets(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !hasPermission(c, models.PermissionSecretsReveal) {
+		h.errorResponse(c, utils.NewForbiddenError("Insufficient permissions"))
+		return
+	}
+
+	eventIDStr := c.Param("event_id")
+	eventID, err := uuid.Parse(eventIDStr)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid event ID format", map[string]interface{}{
+			"field": "event_id",
+			"value": eventIDStr,
+		}))
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return
+	}
+
+	event, err := h.db.GetEventByID(ctx, eventID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get event", err, utils.Fields{
+			"event_id": eventID,
+			"user_id":  userUUID,
+		})
+		h.errorResponse(c, utils.NewInternalErrorWithMessage("Failed to retrieve event"))
+		return
+	}
+
+	if event == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Event not found"))
+		return
+	}
+
+	if event.UserID != userUUID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return
+	}
+
+	show, err := h.db.GetShowByID(ctx, event.ShowID)
+	if err != nil || show == nil {
+		utils.LogError(ctx, "Failed to get event show", err, utils.Fields{
+			"event_id": eventID,
+			"show_id":  event.ShowID,
+		})
+		h.errorResponse(c, utils.NewInternalErrorWithMessage("Failed to retrieve event"))
+		return
+	}
+
+	// Secrets are only handed out once the reveal is on record
+	if err := h.db.RecordAuditEntry(ctx, "event", eventID.String(), "reveal"); err != nil {
+		utils.LogError(ctx, "Failed to audit secret reveal", err, utils.Fields{
+			"event_id": eventID,
+		})
+		h.errorResponse(c, utils.NewInternalErrorWithMessage("Failed to reveal secrets"))
+		return
+	}
+
+	utils.LogInfo(ctx, "Event secrets revealed", utils.Fields{
+		"event_id": eventID,
+		"user_id":  userUUID,
+	})
+
+	effective := utils.GetEffectiveEventData(event, show)
+	c.JSON(http.StatusOK, models.SecretsResponseREST{
+		Success: true,
+		Data: &models.SecretsREST{
+			YouTubeKey:    effective.YouTubeKey,
+			AdditionalKey: effective.AdditionalKey,
+			ZoomPasscode:  effective.ZoomPasscode,
+		},
+	})
+}
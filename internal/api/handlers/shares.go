@@ -0,0 +1,617 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	shareTokenBytes          = 32
+	sharePasswordHeader      = "X-Share-Password"
+	defaultShareAccessLimit  = 100
+	maxShareAccessLimit      = 1000
+	shareDeniedRevoked       = "revoked"
+	shareDeniedExpired       = "expired"
+	shareDeniedDownloadLimit = "download_limit"
+	shareDeniedNoPassword    = "password_required"
+	shareDeniedBadPassword   = "invalid_password"
+	shareDeniedNotInScope    = "not_in_scope"
+
+	// shareDownloadSessionIdle is how long a client session downloading a shared
+	// file lasts without requests before the next request counts as a new download
+	shareDownloadSessionIdle = 30 * time.Minute
+)
+
+type ShareHandler struct {
+	db     *database.PostgresDB
+	media  *MediaHandler
+	config *config.ShareConfig
+}
+
+func NewShareHandler(db *database.PostgresDB, media *MediaHandler, config *config.ShareConfig) *ShareHandler {
+	return &ShareHandler{
+		db:     db,
+		media:  media,
+		config: config,
+	}
+}
+
+// CreateShareLink godoc
+// @Summary Create a share link
+// @Description Create a revocable link that gives unauthenticated access to a media file or a collection. The token is only returned once.
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param request body models.CreateShareLinkRequest true "Share link options"
+// @Success 201 {object} models.CreateShareLinkResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/shares [post]
+// @Security BearerAuth
+func (h *ShareHandler) CreateShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	if (req.MediaID == "") == (req.CollectionID == "") {
+		h.errorResponse(c, utils.NewValidationError("Exactly one of media_id or collection_id is required", nil))
+		return
+	}
+
+	link := &models.ShareLink{
+		CreatedBy: userUUID,
+	}
+
+	if req.MediaID != "" {
+		media, _, ok := h.media.getVisibleMedia(c, req.MediaID)
+		if !ok {
+			return
+		}
+		link.Scope = models.ShareLinkScopeMedia
+		link.MediaUUID = &media.ID
+		link.MediaID = &media.MediaID
+	} else {
+		collectionID, err := uuid.Parse(req.CollectionID)
+		if err != nil {
+			h.errorResponse(c, utils.NewValidationError("Invalid collection ID format", map[string]interface{}{
+				"collection_id": req.CollectionID,
+			}))
+			return
+		}
+		collection, err := h.db.GetMediaCollection(ctx, collectionID)
+		if err != nil {
+			utils.LogError(ctx, "Failed to get collection", err)
+			h.errorResponse(c, utils.NewDatabaseError(err))
+			return
+		}
+		if collection == nil {
+			h.errorResponse(c, utils.NewNotFoundError("Collection not found"))
+			return
+		}
+		if collection.UserID != userUUID {
+			h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+			return
+		}
+		link.Scope = models.ShareLinkScopeCollection
+		link.CollectionID = &collection.ID
+	}
+
+	ttl := h.config.DefaultTTL
+	if req.ExpiresInMinutes != nil {
+		ttl = time.Duration(*req.ExpiresInMinutes) * time.Minute
+	}
+	if h.config.MaxTTL > 0 && ttl > h.config.MaxTTL {
+		h.errorResponse(c, utils.NewValidationError("Share link expiry exceeds the allowed maximum", map[string]interface{}{
+			"max_expires_in_minutes": int(h.config.MaxTTL.Minutes()),
+		}))
+		return
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		link.ExpiresAt = &expiresAt
+	}
+	link.MaxDownloads = req.MaxDownloads
+
+	if req.Password != nil {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), utils.DefaultBcryptCost)
+		if err != nil {
+			utils.LogError(ctx, "Failed to hash share link password", err)
+			h.errorResponse(c, utils.NewInternalError())
+			return
+		}
+		passwordHash := string(hash)
+		link.PasswordHash = &passwordHash
+	}
+
+	tokenBytes, err := utils.GenerateRandomBytes(shareTokenBytes)
+	if err != nil {
+		utils.LogError(ctx, "Failed to generate share token", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+	link.TokenHash = hashShareToken(token)
+
+	if err := h.db.CreateShareLink(ctx, link); err != nil {
+		utils.LogError(ctx, "Failed to create share link", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	utils.LogInfo(ctx, "Share link created", utils.Fields{
+		"share_link_id": link.ID,
+		"scope":         link.Scope,
+		"user_id":       userUUID,
+	})
+
+	c.JSON(http.StatusCreated, models.CreateShareLinkResponse{
+		Success: true,
+		Data: &models.CreatedShareLink{
+			ShareLink: *link,
+			Token:     token,
+			URL:       h.shareURL(token),
+		},
+	})
+}
+
+// ListShareLinks godoc
+// @Summary List share links
+// @Description List the share links created by the authenticated user, including revoked and expired ones
+// @Tags media
+// @Produce json
+// @Success 200 {object} models.ShareLinkListResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/shares [get]
+// @Security BearerAuth
+func (h *ShareHandler) ListShareLinks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.db.ListShareLinks(ctx, userUUID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list share links", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ShareLinkListResponse{
+		Success: true,
+		Data:    links,
+	})
+}
+
+// GetShareLinkAccesses godoc
+// @Summary Get share link access log
+// @Description List the most recent granted and denied accesses of a share link
+// @Tags media
+// @Produce json
+// @Param share_id path string true "Share link ID"
+// @Param limit query int false "Maximum number of records (default 100, max 1000)"
+// @Success 200 {object} models.ShareLinkAccessListResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/shares/{share_id}/accesses [get]
+// @Security BearerAuth
+func (h *ShareHandler) GetShareLinkAccesses(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	link, ok := h.getOwnedShareLink(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultShareAccessLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			h.errorResponse(c, utils.NewValidationError("Invalid limit", map[string]interface{}{
+				"limit": limitStr,
+			}))
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxShareAccessLimit {
+		limit = maxShareAccessLimit
+	}
+
+	accesses, err := h.db.ListShareLinkAccesses(ctx, link.ID, limit)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list share link accesses", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ShareLinkAccessListResponse{
+		Success: true,
+		Data:    accesses,
+	})
+}
+
+// RevokeShareLink godoc
+// @Summary Revoke a share link
+// @Description Revoke a share link immediately. Further requests with its token are rejected.
+// @Tags media
+// @Produce json
+// @Param share_id path string true "Share link ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/shares/{share_id} [delete]
+// @Security BearerAuth
+func (h *ShareHandler) RevokeShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	link, ok := h.getOwnedShareLink(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RevokeShareLink(ctx, link.ID, link.CreatedBy); err != nil {
+		utils.LogError(ctx, "Failed to revoke share link", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	utils.LogInfo(ctx, "Share link revoked", utils.Fields{
+		"share_link_id": link.ID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "success",
+		"message":       "Share link revoked successfully",
+		"share_link_id": link.ID,
+	})
+}
+
+// OpenShareLink godoc
+// @Summary Open a share link
+// @Description Public endpoint. Streams the shared media file, or lists the media of a shared collection. Password protected links expect the password in the X-Share-Password header.
+// @Tags share
+// @Produce application/octet-stream
+// @Produce json
+// @Param token path string true "Share token"
+// @Param Range header string false "Range header for partial content (e.g., bytes=0-1023)"
+// @Success 200 {file} binary "Shared media file"
+// @Success 206 {file} binary "Partial content (range request)"
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Router /share/{token} [get]
+func (h *ShareHandler) OpenShareLink(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	link, ok := h.resolveShareLink(c)
+	if !ok {
+		return
+	}
+
+	if link.Scope == models.ShareLinkScopeMedia {
+		media, err := h.db.GetMediaByID(ctx, *link.MediaID)
+		if err != nil {
+			utils.LogError(ctx, "Failed to find shared media", err)
+			h.errorResponse(c, utils.NewInternalError())
+			return
+		}
+		if media == nil {
+			h.errorResponse(c, utils.NewShareLinkNotFoundError())
+			return
+		}
+
+		h.streamSharedMedia(c, link, media)
+		return
+	}
+
+	// A collection whose downloads are used up is no longer listed
+	if link.MaxDownloads != nil && link.DownloadCount >= *link.MaxDownloads {
+		h.recordAccess(c, link, nil, false, shareDeniedDownloadLimit)
+		h.errorResponse(c, utils.NewShareLinkExpiredError(shareDeniedDownloadLimit))
+		return
+	}
+
+	collection, err := h.db.GetMediaCollection(ctx, *link.CollectionID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get shared collection", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return
+	}
+	if collection == nil {
+		h.errorResponse(c, utils.NewShareLinkNotFoundError())
+		return
+	}
+
+	items, err := h.db.GetMediaCollectionItems(ctx, collection.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get shared collection media", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return
+	}
+
+	h.recordAccess(c, link, nil, true, "")
+
+	token := c.Param("token")
+	response := models.SharedCollectionResponse{
+		Name:        collection.Name,
+		Description: collection.Description,
+		ExpiresAt:   link.ExpiresAt,
+		Media:       make([]models.SharedMediaItem, 0, len(items)),
+	}
+	for _, item := range items {
+		response.Media = append(response.Media, models.SharedMediaItem{
+			MediaID:  item.MediaID,
+			FileName: item.FileName,
+			FileType: item.FileType,
+			FileSize: item.FileSize,
+			URL:      h.shareURL(token) + "/media/" + item.MediaID,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DownloadSharedMedia godoc
+// @Summary Download a media file from a shared collection
+// @Description Public endpoint. Streams one media file of a shared collection.
+// @Tags share
+// @Produce application/octet-stream
+// @Param token path string true "Share token"
+// @Param media_id path string true "Media ID"
+// @Param Range header string false "Range header for partial content (e.g., bytes=0-1023)"
+// @Success 200 {file} binary "Shared media file"
+// @Success 206 {file} binary "Partial content (range request)"
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 410 {object} map[string]interface{}
+// @Router /share/{token}/media/{media_id} [get]
+func (h *ShareHandler) DownloadSharedMedia(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	link, ok := h.resolveShareLink(c)
+	if !ok {
+		return
+	}
+
+	mediaID := c.Param("media_id")
+	media, err := h.db.GetMediaByID(ctx, mediaID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to find shared media", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return
+	}
+
+	inScope := false
+	if media != nil {
+		if link.Scope == models.ShareLinkScopeMedia {
+			inScope = *link.MediaUUID == media.ID
+		} else {
+			inScope, err = h.db.IsMediaInCollection(ctx, *link.CollectionID, media.ID)
+			if err != nil {
+				utils.LogError(ctx, "Failed to check shared collection", err)
+				h.errorResponse(c, utils.NewInternalError())
+				return
+			}
+		}
+	}
+	if !inScope {
+		h.recordAccess(c, link, nil, false, shareDeniedNotInScope)
+		h.errorResponse(c, utils.NewMediaNotFoundError(mediaID))
+		return
+	}
+
+	h.streamSharedMedia(c, link, media)
+}
+
+// streamSharedMedia counts the download against the link and streams the file.
+// Every request is checked against the limit, but follow-up requests of the same
+// client session, such as range requests while seeking, are not counted again.
+func (h *ShareHandler) streamSharedMedia(c *gin.Context, link *models.ShareLink, media *models.Media) {
+	ctx := c.Request.Context()
+
+	sessionKey := shareDownloadSessionKey(c, media)
+	consumed, err := h.db.ConsumeShareLinkDownload(ctx, link.ID, sessionKey, time.Now().Add(-shareDownloadSessionIdle))
+	if err != nil {
+		utils.LogError(ctx, "Failed to count share link download", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return
+	}
+	if !consumed {
+		h.recordAccess(c, link, media, false, shareDeniedDownloadLimit)
+		h.errorResponse(c, utils.NewShareLinkExpiredError(shareDeniedDownloadLimit))
+		return
+	}
+
+	h.recordAccess(c, link, media, true, "")
+
+	// Shared responses must not outlive a revocation in shared caches
+	c.Header("Cache-Control", "private, no-store")
+	h.media.streamMedia(c, ctx, media)
+}
+
+// resolveShareLink looks up the link for the token in the path and checks
+// that it is still usable and that the correct password was supplied
+func (h *ShareHandler) resolveShareLink(c *gin.Context) (*models.ShareLink, bool) {
+	ctx := c.Request.Context()
+
+	link, err := h.db.GetShareLinkByTokenHash(ctx, hashShareToken(c.Param("token")))
+	if err != nil {
+		utils.LogError(ctx, "Failed to get share link", err)
+		h.errorResponse(c, utils.NewInternalError())
+		return nil, false
+	}
+	if link == nil {
+		h.errorResponse(c, utils.NewShareLinkNotFoundError())
+		return nil, false
+	}
+
+	reason := ""
+	switch {
+	case link.RevokedAt != nil:
+		reason = shareDeniedRevoked
+	case link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt):
+		reason = shareDeniedExpired
+	}
+	if reason != "" {
+		h.recordAccess(c, link, nil, false, reason)
+		h.errorResponse(c, utils.NewShareLinkExpiredError(reason))
+		return nil, false
+	}
+
+	if link.PasswordHash != nil {
+		// Only a header, so the password doesn't end up in access logs or browser history
+		password := c.GetHeader(sharePasswordHeader)
+		if password == "" {
+			h.recordAccess(c, link, nil, false, shareDeniedNoPassword)
+			h.errorResponse(c, utils.NewSharePasswordError("Share link password required"))
+			return nil, false
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte(password)) != nil {
+			h.recordAccess(c, link, nil, false, shareDeniedBadPassword)
+			h.errorResponse(c, utils.NewSharePasswordError("Invalid share link password"))
+			return nil, false
+		}
+	}
+
+	return link, true
+}
+
+func (h *ShareHandler) recordAccess(c *gin.Context, link *models.ShareLink, media *models.Media, granted bool, reason string) {
+	ctx := c.Request.Context()
+
+	access := &models.ShareLinkAccess{
+		ShareLinkID: link.ID,
+		Granted:     granted,
+	}
+	if media != nil {
+		access.MediaUUID = &media.ID
+	}
+	if reason != "" {
+		access.Reason = &reason
+	}
+	if ip := c.ClientIP(); ip != "" {
+		access.IPAddress = &ip
+	}
+	if userAgent := c.Request.UserAgent(); userAgent != "" {
+		access.UserAgent = &userAgent
+	}
+	if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+		if len(rangeHeader) > 100 {
+			rangeHeader = rangeHeader[:100]
+		}
+		access.RangeHeader = &rangeHeader
+	}
+
+	if err := h.db.RecordShareLinkAccess(ctx, access); err != nil {
+		utils.LogError(ctx, "Failed to record share link access", err, utils.Fields{
+			"share_link_id": link.ID,
+		})
+	}
+}
+
+func (h *ShareHandler) getOwnedShareLink(c *gin.Context) (*models.ShareLink, bool) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	linkID, err := uuid.Parse(c.Param("share_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid share link ID format", map[string]interface{}{
+			"share_id": c.Param("share_id"),
+		}))
+		return nil, false
+	}
+
+	link, err := h.db.GetShareLink(ctx, linkID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get share link", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, false
+	}
+	if link == nil {
+		h.errorResponse(c, utils.NewShareLinkNotFoundError())
+		return nil, false
+	}
+	if link.CreatedBy != userUUID {
+		h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+		return nil, false
+	}
+
+	return link, true
+}
+
+func (h *ShareHandler) shareURL(token string) string {
+	return h.config.PublicBaseURL + "/share/" + token
+}
+
+func (h *ShareHandler) getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return uuid.Nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
+
+func (h *ShareHandler) errorResponse(c *gin.Context, err *utils.AppError) {
+	c.JSON(err.StatusCode, gin.H{
+		"error":      err,
+		"request_id": c.GetString("request_id"),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// shareDownloadSessionKey identifies the client session downloading a shared file
+func shareDownloadSessionKey(c *gin.Context, media *models.Media) string {
+	sum := sha256.Sum256([]byte(c.ClientIP() + "\n" + c.Request.UserAgent() + "\n" + media.ID.String()))
+	return hex.EncodeToString(sum[:])
+}
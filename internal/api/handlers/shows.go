@@ -12,6 +12,7 @@ import (
 
 	"github.com/denisAlshanov/stPlaner/internal/database"
 	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/secrets"
 	"github.com/denisAlshanov/stPlaner/internal/utils"
 )
 
@@ -189,6 +190,7 @@ func (h *ShowHandler) CreateShowREST(c *gin.Context) {
 		"user_id":   userUUID,
 	})
 
+	maskShowSecrets(show)
 	c.JSON(http.StatusOK, models.ShowResponseREST{
 		Success: true,
 		Data:    show,
@@ -380,6 +382,7 @@ func (h *ShowHandler) UpdateShowREST(c *gin.Context) {
 		"user_id":   userUUID,
 	})
 
+	maskShowSecrets(updatedShow)
 	c.JSON(http.StatusOK, models.ShowResponseREST{
 		Success: true,
 		Data:    updatedShow,
@@ -590,7 +593,7 @@ func (h *ShowHandler) GetShowREST(c *gin.Context) {
 		LengthMinutes:    show.LengthMinutes,
 		RepeatPattern:    show.RepeatPattern,
 		SchedulingConfig: show.SchedulingConfig,
-		YouTubeKey:       show.YouTubeKey,
+		YouTubeKey:       secrets.Mask(show.YouTubeKey),
 		ZoomMeetingURL:   show.ZoomMeetingURL,
 		DefaultHost:      defaultHost,
 		DefaultDirector:  defaultDirector,
@@ -704,6 +707,7 @@ func (h *ShowHandler) ListShowsREST(c *gin.Context) {
 			ID:                   show.ID,
 			ShowName:             show.ShowName,
 			RepeatPattern:        show.RepeatPattern,
+			YouTubeKey:           secrets.Mask(show.YouTubeKey),
 			NextEventDate:        nextEventDate,
 			EventCount:           eventCount,
 			Status:               show.Status,
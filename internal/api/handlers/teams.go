@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// TeamHandler manages teams. Members of a team see each other's media library.
+type TeamHandler struct {
+	db *database.PostgresDB
+}
+
+func NewTeamHandler(db *database.PostgresDB) *TeamHandler {
+	return &TeamHandler{
+		db: db,
+	}
+}
+
+// CreateTeam godoc
+// @Summary Create a team
+// @Description Create a team whose members share their media library. The creator becomes the first member.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param request body models.CreateTeamRequest true "Team details"
+// @Success 201 {object} models.TeamResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/teams [post]
+// @Security BearerAuth
+func (h *TeamHandler) CreateTeam(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	var req models.CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		h.errorResponse(c, utils.NewValidationError("Team name is required", nil))
+		return
+	}
+
+	team := &models.Team{
+		Name:        name,
+		Description: req.Description,
+		CreatedBy:   &scope.UserID,
+	}
+	if err := h.db.CreateTeam(ctx, team); err != nil {
+		if strings.Contains(err.Error(), "teams_name_key") {
+			h.errorResponse(c, utils.NewErrorWithDetails(utils.ErrorCodeValidationError, "A team with this name already exists", http.StatusConflict, map[string]interface{}{
+				"name": name,
+			}))
+			return
+		}
+		utils.LogError(ctx, "Failed to create team", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithTeam(c, http.StatusCreated, team)
+}
+
+// ListTeams godoc
+// @Summary List own teams
+// @Description List the teams the authenticated user is a member of
+// @Tags teams
+// @Produce json
+// @Success 200 {object} models.TeamListResponse
+// @Failure 401 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/teams [get]
+// @Security BearerAuth
+func (h *TeamHandler) ListTeams(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	teams, err := h.db.ListUserTeams(ctx, scope.UserID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list teams", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TeamListResponse{
+		Success: true,
+		Data:    teams,
+	})
+}
+
+// GetTeam godoc
+// @Summary Get a team
+// @Description Get a team with its members. Only members can view a team.
+// @Tags teams
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Success 200 {object} models.TeamResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/teams/{team_id} [get]
+// @Security BearerAuth
+func (h *TeamHandler) GetTeam(c *gin.Context) {
+	team, ok := h.getMemberTeam(c)
+	if !ok {
+		return
+	}
+
+	h.respondWithTeam(c, http.StatusOK, team)
+}
+
+// AddTeamMember godoc
+// @Summary Add a team member
+// @Description Add a user to a team. Any member can add users; the new member immediately shares media with the team.
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Param request body models.AddTeamMemberRequest true "User to add"
+// @Success 200 {object} models.TeamResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/teams/{team_id}/members [post]
+// @Security BearerAuth
+func (h *TeamHandler) AddTeamMember(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.AddTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	team, ok := h.getMemberTeam(c)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid user ID format", map[string]interface{}{
+			"user_id": req.UserID,
+		}))
+		return
+	}
+	user, err := h.db.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get user", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+	if user == nil {
+		h.errorResponse(c, utils.NewNotFoundError("User not found"))
+		return
+	}
+
+	if err := h.db.AddTeamMember(ctx, team.ID, userID); err != nil {
+		utils.LogError(ctx, "Failed to add team member", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithTeam(c, http.StatusOK, team)
+}
+
+// RemoveTeamMember godoc
+// @Summary Remove a team member
+// @Description Remove a user from a team. Members can remove other members or leave the team themselves.
+// @Tags teams
+// @Produce json
+// @Param team_id path string true "Team ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.TeamResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/teams/{team_id}/members/{user_id} [delete]
+// @Security BearerAuth
+func (h *TeamHandler) RemoveTeamMember(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid user ID format", nil))
+		return
+	}
+
+	team, ok := h.getMemberTeam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.RemoveTeamMember(ctx, team.ID, userID); err != nil {
+		utils.LogError(ctx, "Failed to remove team member", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	h.respondWithTeam(c, http.StatusOK, team)
+}
+
+// getMemberTeam loads the team from the path and checks that the authenticated user is a member
+func (h *TeamHandler) getMemberTeam(c *gin.Context) (*models.Team, bool) {
+	ctx := c.Request.Context()
+
+	scope, appErr := mediaScope(c)
+	if appErr != nil {
+		h.errorResponse(c, appErr)
+		return nil, false
+	}
+
+	teamID, err := uuid.Parse(c.Param("team_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid team ID format", nil))
+		return nil, false
+	}
+
+	team, err := h.db.GetTeam(ctx, teamID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get team", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return nil, false
+	}
+	if team == nil {
+		h.errorResponse(c, utils.NewNotFoundError("Team not found"))
+		return nil, false
+	}
+
+	if !scope.All {
+		member, err := h.db.IsTeamMember(ctx, teamID, scope.UserID)
+		if err != nil {
+			utils.LogError(ctx, "Failed to check team membership", err)
+			h.errorResponse(c, utils.NewDatabaseError(err))
+			return nil, false
+		}
+		if !member {
+			h.errorResponse(c, utils.NewForbiddenError("Access denied"))
+			return nil, false
+		}
+	}
+
+	return team, true
+}
+
+func (h *TeamHandler) respondWithTeam(c *gin.Context, status int, team *models.Team) {
+	ctx := c.Request.Context()
+
+	members, err := h.db.GetTeamMembers(ctx, team.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get team members", err)
+		h.errorResponse(c, utils.NewDatabaseError(err))
+		return
+	}
+
+	c.JSON(status, models.TeamResponse{
+		Success: true,
+		Data: &models.TeamDetail{
+			Team:    *team,
+			Members: members,
+		},
+	})
+}
+
+func (h *TeamHandler) errorResponse(c *gin.Context, err *utils.AppError) {
+	c.JSON(err.StatusCode, gin.H{
+		"error":      err,
+		"request_id": c.GetString("request_id"),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
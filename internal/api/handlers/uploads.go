@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/uploader"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+type UploadHandler struct {
+	uploader *uploader.Uploader
+}
+
+func NewUploadHandler(uploader *uploader.Uploader) *UploadHandler {
+	return &UploadHandler{
+		uploader: uploader,
+	}
+}
+
+// InitiateUpload godoc
+// @Summary Start a chunked media upload
+// @Description Open a resumable upload session for a local file. The response contains the chunk size and the number of chunks the client must send.
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param request body models.InitiateUploadRequest true "File description"
+// @Success 201 {object} models.MediaUploadResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/upload [post]
+// @Security BearerAuth
+func (h *UploadHandler) InitiateUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.InitiateUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid request body", map[string]interface{}{
+			"error": err.Error(),
+		}))
+		return
+	}
+
+	upload, err := h.uploader.InitiateUpload(ctx, userUUID, &req)
+	if err != nil {
+		h.handleError(c, "Failed to initiate upload", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.MediaUploadResponse{
+		Success: true,
+		Data:    upload,
+	})
+}
+
+// GetUploadStatus godoc
+// @Summary Get chunked upload status
+// @Description Get the state of an upload session including the chunks received so far, used to resume interrupted uploads
+// @Tags media
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} models.MediaUploadResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/upload/{upload_id} [get]
+// @Security BearerAuth
+func (h *UploadHandler) GetUploadStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	uploadID, ok := h.getUploadID(c)
+	if !ok {
+		return
+	}
+
+	upload, err := h.uploader.GetUpload(ctx, userUUID, uploadID)
+	if err != nil {
+		h.handleError(c, "Failed to get upload", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MediaUploadResponse{
+		Success: true,
+		Data:    upload,
+	})
+}
+
+// UploadChunk godoc
+// @Summary Upload a file chunk
+// @Description Upload one chunk of a file. The body is either the raw chunk bytes or a multipart form with a "chunk" file field. Re-sending a chunk replaces it.
+// @Tags media
+// @Accept application/octet-stream
+// @Accept multipart/form-data
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Param chunk_number path int true "Chunk number, starting at 1"
+// @Success 200 {object} models.MediaUploadResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/upload/{upload_id}/chunks/{chunk_number} [put]
+// @Security BearerAuth
+func (h *UploadHandler) UploadChunk(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	uploadID, ok := h.getUploadID(c)
+	if !ok {
+		return
+	}
+
+	chunkNumber, err := strconv.Atoi(c.Param("chunk_number"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid chunk number", map[string]interface{}{
+			"chunk_number": c.Param("chunk_number"),
+		}))
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("chunk")
+		if err != nil {
+			h.errorResponse(c, utils.NewValidationError("Missing chunk file field", map[string]interface{}{
+				"error": err.Error(),
+			}))
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			h.errorResponse(c, utils.NewValidationError("Failed to read chunk file", map[string]interface{}{
+				"error": err.Error(),
+			}))
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	upload, err := h.uploader.UploadChunk(ctx, userUUID, uploadID, chunkNumber, body)
+	if err != nil {
+		h.handleError(c, "Failed to upload chunk", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MediaUploadResponse{
+		Success: true,
+		Data:    upload,
+	})
+}
+
+// CompleteUpload godoc
+// @Summary Complete a chunked upload
+// @Description Assemble all received chunks into a media file. Files identical to already stored media are deduplicated and the existing media is returned.
+// @Tags media
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} models.CompleteUploadResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/upload/{upload_id}/complete [post]
+// @Security BearerAuth
+func (h *UploadHandler) CompleteUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	uploadID, ok := h.getUploadID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.uploader.CompleteUpload(ctx, userUUID, uploadID)
+	if err != nil {
+		h.handleError(c, "Failed to complete upload", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CompleteUploadResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// AbortUpload godoc
+// @Summary Abort a chunked upload
+// @Description Cancel an in-progress upload and discard the chunks received so far
+// @Tags media
+// @Produce json
+// @Param upload_id path string true "Upload ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 401 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /api/v1/media/upload/{upload_id} [delete]
+// @Security BearerAuth
+func (h *UploadHandler) AbortUpload(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userUUID, ok := h.getUserID(c)
+	if !ok {
+		return
+	}
+
+	uploadID, ok := h.getUploadID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.uploader.AbortUpload(ctx, userUUID, uploadID); err != nil {
+		h.handleError(c, "Failed to abort upload", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    "success",
+		"message":   "Upload aborted successfully",
+		"upload_id": uploadID,
+	})
+}
+
+func (h *UploadHandler) getUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		h.errorResponse(c, utils.NewAuthError("User not authenticated"))
+		return uuid.Nil, false
+	}
+
+	userUUID, ok := userID.(uuid.UUID)
+	if !ok {
+		h.errorResponse(c, utils.NewAuthError("Invalid user ID"))
+		return uuid.Nil, false
+	}
+
+	return userUUID, true
+}
+
+func (h *UploadHandler) getUploadID(c *gin.Context) (uuid.UUID, bool) {
+	uploadID, err := uuid.Parse(c.Param("upload_id"))
+	if err != nil {
+		h.errorResponse(c, utils.NewValidationError("Invalid upload ID format", map[string]interface{}{
+			"upload_id": c.Param("upload_id"),
+		}))
+		return uuid.Nil, false
+	}
+
+	return uploadID, true
+}
+
+func (h *UploadHandler) handleError(c *gin.Context, message string, err error) {
+	if appErr, ok := err.(*utils.AppError); ok {
+		if appErr.StatusCode >= http.StatusInternalServerError {
+			utils.LogError(c.Request.Context(), message, appErr)
+		}
+		h.errorResponse(c, appErr)
+		return
+	}
+
+	utils.LogError(c.Request.Context(), message, err)
+	h.errorResponse(c, utils.NewInternalError())
+}
+
+func (h *UploadHandler) errorResponse(c *gin.Context, err *utils.AppError) {
+	c.JSON(err.StatusCode, gin.H{
+		"error":      err,
+		"request_id": c.GetString("request_id"),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+}
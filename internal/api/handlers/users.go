@@ -11,17 +11,20 @@ import (
 
 	"github.com/denisAlshanov/stPlaner/internal/database"
 	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/auth"
 	"github.com/denisAlshanov/stPlaner/internal/utils"
 )
 
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
-	db *database.PostgresDB
+	db             *database.PostgresDB
+	loginGuard     *auth.LoginGuard
+	accountService *auth.AccountService
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(db *database.PostgresDB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *database.PostgresDB, loginGuard *auth.LoginGuard, accountService *auth.AccountService) *UserHandler {
+	return &UserHandler{db: db, loginGuard: loginGuard, accountService: accountService}
 }
 
 
@@ -75,6 +78,9 @@ func (h *UserHandler) CreateUserREST(c *gin.Context) {
 		Email:   strings.ToLower(strings.TrimSpace(req.Email)),
 		Status:  models.UserStatusActive,
 	}
+	if req.RequireVerification {
+		user.Status = models.UserStatusPending
+	}
 
 	// Hash password
 	hashedPassword, err := utils.ValidateAndHashPassword(req.Password)
@@ -109,6 +115,15 @@ func (h *UserHandler) CreateUserREST(c *gin.Context) {
 		"email":   user.Email,
 	})
 
+	// Pending users are activated by the link in the verification email
+	if req.RequireVerification {
+		if err := h.accountService.SendVerification(c.Request.Context(), user); err != nil {
+			utils.LogError(c.Request.Context(), "Failed to send verification email", err, utils.Fields{
+				"user_id": user.ID,
+			})
+		}
+	}
+
 	c.JSON(http.StatusOK, models.CreateUserResponse{
 		Success: true,
 		Data:    user,
@@ -598,4 +613,141 @@ func (h *UserHandler) RemoveRoleFromUser(c *gin.Context) {
 		Success: true,
 		Message: "Role removed successfully",
 	})
-}
\ No newline at end of file
+}
+// ResetUserMFA handles DELETE /api/v1/users/{user_id}/mfa
+// @Summary Reset two-factor authentication of a user
+// @Description Remove the TOTP enrollment and recovery codes of a user who lost access to them. The user must enroll again if a role requires MFA.
+// @Tags users
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.MFAMessageResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/users/{user_id}/mfa [delete]
+func (h *UserHandler) ResetUserMFA(c *gin.Context) {
+	if !hasPermission(c, models.PermissionUsersUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	deleted, err := h.db.DeleteUserMFA(c.Request.Context(), userID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to reset user MFA", err, utils.Fields{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no two-factor authentication set up"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "User MFA reset", utils.Fields{
+		"user_id": userID,
+	})
+
+	c.JSON(http.StatusOK, models.MFAMessageResponse{
+		Success: true,
+		Message: "Two-factor authentication reset",
+	})
+}
+
+// UnlockUser handles POST /api/v1/users/{user_id}/unlock
+// @Summary Unlock a locked out user
+// @Description Lift the temporary lockout after too many failed logins and forget the failed attempts
+// @Tags users
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.UnlockUserResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/users/{user_id}/unlock [post]
+func (h *UserHandler) UnlockUser(c *gin.Context) {
+	if !hasPermission(c, models.PermissionUsersUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	found, err := h.loginGuard.Unlock(c.Request.Context(), userID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to unlock user", err, utils.Fields{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UnlockUserResponse{
+		Success: true,
+		Message: "User unlocked successfully",
+	})
+}
+
+// RevokeUserAPITokens handles DELETE /api/v1/users/{user_id}/tokens
+// @Summary Revoke the API tokens of a user
+// @Description Revoke every personal API token of a user, for example after a token leaked
+// @Tags users
+// @Produce json
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.RevokeAPITokensResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/v1/users/{user_id}/tokens [delete]
+func (h *UserHandler) RevokeUserAPITokens(c *gin.Context) {
+	if !hasPermission(c, models.PermissionUsersUpdate) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	revoked, err := h.db.RevokeUserAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		utils.LogError(c.Request.Context(), "Failed to revoke user API tokens", err, utils.Fields{
+			"user_id": userID,
+		})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API tokens"})
+		return
+	}
+
+	utils.LogInfo(c.Request.Context(), "User API tokens revoked", utils.Fields{
+		"user_id": userID,
+		"revoked": revoked,
+	})
+
+	c.JSON(http.StatusOK, models.RevokeAPITokensResponse{
+		Success: true,
+		Revoked: revoked,
+	})
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// setAuditActor records who is making the request for the audit log, from what the
+// authentication middleware stored in the context. It is stored both in the request
+// context and with c.Set, as handlers pass either one to the database.
+func setAuditActor(c *gin.Context) {
+	actor := &models.AuditActor{
+		AuthMethod:    c.GetString("auth_method"),
+		CorrelationID: c.GetString("correlation_id"),
+		RequestID:     c.GetString("request_id"),
+		IPAddress:     c.ClientIP(),
+	}
+
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uuid.UUID); ok {
+			actor.UserID = &id
+		}
+	}
+	if sessionID, err := uuid.Parse(c.GetString("session_id")); err == nil {
+		actor.SessionID = &sessionID
+	}
+	if tokenID, ok := c.Get("api_token_id"); ok {
+		if id, ok := tokenID.(uuid.UUID); ok {
+			actor.APITokenID = &id
+		}
+	}
+
+	c.Set(string(utils.AuditActorKey), actor)
+	c.Request = c.Request.WithContext(utils.WithAuditActor(c.Request.Context(), actor))
+}
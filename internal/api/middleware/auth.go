@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
@@ -99,12 +100,14 @@ func JWTAuthMiddleware(jwtService *auth.JWTService, sessionService *auth.Session
 		}
 
 		// Store user information in context
-		c.Set("user_id", claims.UserID)
+		setUserID(c, claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_roles", claims.Roles)
 		c.Set("user_permissions", claims.Permissions)
 		c.Set("session_id", claims.SessionID)
 		c.Set("token_jti", claims.ID)
+		c.Set("auth_method", "jwt")
+		setAuditActor(c)
 
 		c.Next()
 	}
@@ -137,7 +140,7 @@ func OptionalJWTAuthMiddleware(jwtService *auth.JWTService, sessionService *auth
 		}
 
 		// Valid token, store user information in context
-		c.Set("user_id", claims.UserID)
+		setUserID(c, claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_roles", claims.Roles)
 		c.Set("user_permissions", claims.Permissions)
@@ -251,7 +254,7 @@ func HybridAuthMiddleware(cfg *config.APIConfig, jwtService *auth.JWTService, se
 					}
 
 					// Store user information in context
-					c.Set("user_id", claims.UserID)
+					setUserID(c, claims.UserID)
 					c.Set("user_email", claims.Email)
 					c.Set("user_roles", claims.Roles)
 					c.Set("user_permissions", claims.Permissions)
@@ -275,8 +278,9 @@ func HybridAuthMiddleware(cfg *config.APIConfig, jwtService *auth.JWTService, se
 	}
 }
 
-// JWTOnlyMiddleware provides JWT-only authentication and rejects API key attempts
-func JWTOnlyMiddleware(jwtService *auth.JWTService, sessionService *auth.SessionService) gin.HandlerFunc {
+// JWTOnlyMiddleware provides JWT and personal API token authentication and rejects
+// attempts with the static API key
+func JWTOnlyMiddleware(jwtService *auth.JWTService, sessionService *auth.SessionService, apiTokenService *auth.APITokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if API key is being attempted
 		apiKey := c.GetHeader("X-API-Key")
@@ -304,6 +308,12 @@ func JWTOnlyMiddleware(jwtService *auth.JWTService, sessionService *auth.Session
 			return
 		}
 
+		// Personal API tokens authenticate scripts and integrations
+		if auth.IsAPIToken(token) {
+			authenticateAPIToken(c, apiTokenService, token)
+			return
+		}
+
 		// Validate access token
 		claims, err := jwtService.ValidateAccessToken(token)
 		if err != nil {
@@ -364,18 +374,75 @@ func JWTOnlyMiddleware(jwtService *auth.JWTService, sessionService *auth.Session
 		}
 
 		// Store user information in context
-		c.Set("user_id", claims.UserID)
+		setUserID(c, claims.UserID)
 		c.Set("user_email", claims.Email)
 		c.Set("user_roles", claims.Roles)
 		c.Set("user_permissions", claims.Permissions)
 		c.Set("session_id", claims.SessionID)
 		c.Set("token_jti", claims.ID)
 		c.Set("auth_method", "jwt")
+		setAuditActor(c)
 
 		c.Next()
 	}
 }
 
+// authenticateAPIToken authenticates a request with a personal API token. The token
+// acts as its owner, limited to the permissions in its scopes, and can only call routes
+// whose scope it holds.
+func authenticateAPIToken(c *gin.Context, apiTokenService *auth.APITokenService, token string) {
+	principal, err := apiTokenService.Authenticate(c, token, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, auth.ErrAPITokenInvalid) {
+			c.JSON(401, gin.H{
+				"error":      "INVALID_TOKEN",
+				"message":    "Invalid, expired or revoked API token",
+				"request_id": c.GetString("request_id"),
+				"timestamp":  time.Now().Format(time.RFC3339),
+			})
+			c.Abort()
+			return
+		}
+
+		utils.LogError(c, "Failed to authenticate API token", err)
+		c.JSON(500, gin.H{
+			"error":      "TOKEN_CHECK_ERROR",
+			"message":    "Failed to verify token status",
+			"request_id": c.GetString("request_id"),
+			"timestamp":  time.Now().Format(time.RFC3339),
+		})
+		c.Abort()
+		return
+	}
+
+	// Every route needs the token to hold the scope for its resource and action
+	scope, ok := apiTokenScope(c.Request.Method, c.FullPath())
+	if !ok || !hasScope(principal.Permissions, scope) {
+		message := "API tokens can't be used for this endpoint"
+		if ok {
+			message = fmt.Sprintf("API token lacks the %s scope", scope)
+		}
+		c.JSON(403, gin.H{
+			"error":      "INSUFFICIENT_SCOPE",
+			"message":    message,
+			"request_id": c.GetString("request_id"),
+			"timestamp":  time.Now().Format(time.RFC3339),
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", principal.User.ID)
+	c.Set("user_email", principal.User.Email)
+	c.Set("user_roles", principal.Roles)
+	c.Set("user_permissions", principal.Permissions)
+	c.Set("api_token_id", principal.Token.ID)
+	c.Set("auth_method", "api_token")
+	setAuditActor(c)
+
+	c.Next()
+}
+
 // CORSMiddleware provides Cross-Origin Resource Sharing support
 func CORSMiddleware(cfg *config.CORSConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -552,3 +619,16 @@ func ValidateCORSConfig(cfg *config.CORSConfig) {
 		}
 	}
 }
+
+// setUserID stores the authenticated user ID in the context as the uuid.UUID
+// handlers expect. Tokens carrying a malformed ID leave it unset.
+func setUserID(c *gin.Context, userID string) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(c, "Token contains an invalid user ID", utils.Fields{
+			"user_id": userID,
+		})
+		return
+	}
+	c.Set("user_id", userUUID)
+}
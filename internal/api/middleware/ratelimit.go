@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -98,7 +99,7 @@ func RateLimitMiddleware(cfg *config.APIConfig) gin.HandlerFunc {
 
 		// Override with user ID if available (from auth middleware)
 		if userID, exists := c.Get("user_id"); exists {
-			key = userID.(string)
+			key = fmt.Sprint(userID)
 		}
 
 		if !limiter.isAllowed(key) {
@@ -0,0 +1,30 @@
+package middleware
+
+import "testing"
+
+func TestAPITokenScope(t *testing.T) {
+	tests := []struct {
+		method, route string
+		want          string
+		allowed       bool
+	}{
+		{"GET", "/api/v1/shows/:show_id", "shows:read", true},
+		{"POST", "/api/v1/shows", "shows:create", true},
+		{"PUT", "/api/v1/guest/update", "guests:update", true},
+		{"DELETE", "/api/v1/block/delete", "blocks:delete", true},
+		{"DELETE", "/api/v1/events/:event_id", "events:delete", true},
+		{"POST", "/api/v1/guest/list", "guests:read", true},
+		{"POST", "/api/v1/media/grab", "posts:create", true},
+		{"GET", "/api/v1/event/:event_id/blocks", "blocks:read", true},
+		{"GET", "/api/v1/audit", "audit:read", true},
+		{"POST", "/api/v1/teams", "", false},
+		{"GET", "/api/v1/auth/tokens", "", false},
+	}
+
+	for _, tt := range tests {
+		got, allowed := apiTokenScope(tt.method, tt.route)
+		if got != tt.want || allowed != tt.allowed {
+			t.Errorf("apiTokenScope(%s %s) = %q, %v, want %q, %v", tt.method, tt.route, got, allowed, tt.want, tt.allowed)
+		}
+	}
+}
@@ -16,7 +16,7 @@ type Router struct {
 	config *config.Config
 }
 
-func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandler *handlers.MediaHandler, healthHandler *handlers.HealthHandler, showHandler *handlers.ShowHandler, eventHandler *handlers.EventHandler, guestHandler *handlers.GuestHandler, blockHandler *handlers.BlockHandler, userHandler *handlers.UserHandler, roleHandler *handlers.RoleHandler, authHandler *handlers.AuthHandlers, jwtService *auth.JWTService, sessionService *auth.SessionService) *Router {
+func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandler *handlers.MediaHandler, uploadHandler *handlers.UploadHandler, collectionHandler *handlers.CollectionHandler, shareHandler *handlers.ShareHandler, quotaHandler *handlers.QuotaHandler, teamHandler *handlers.TeamHandler, healthHandler *handlers.HealthHandler, showHandler *handlers.ShowHandler, eventHandler *handlers.EventHandler, guestHandler *handlers.GuestHandler, blockHandler *handlers.BlockHandler, invitationHandler *handlers.InvitationHandler, userHandler *handlers.UserHandler, roleHandler *handlers.RoleHandler, auditHandler *handlers.AuditHandler, authHandler *handlers.AuthHandlers, jwtService *auth.JWTService, sessionService *auth.SessionService, apiTokenService *auth.APITokenService) *Router {
 	// Set Gin mode
 	if cfg.Server.Host == "0.0.0.0" {
 		gin.SetMode(gin.ReleaseMode)
@@ -40,6 +40,28 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 	// Swagger documentation (no auth required)
 	engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// JWT verification keys for other services (no auth required)
+	engine.GET("/.well-known/jwks.json", authHandler.JWKS)
+
+	// Public share link endpoints (no auth required, access is checked per link)
+	share := engine.Group("/share")
+	share.Use(middleware.RateLimitMiddleware(&cfg.API))
+	{
+		share.GET("/:token", shareHandler.OpenShareLink)                         // /share/{token}
+		share.GET("/:token/media/:media_id", shareHandler.DownloadSharedMedia)   // /share/{token}/media/{media_id}
+	}
+
+	// Public RSVP links for guests (no auth required, the token is signed)
+	rsvp := engine.Group("/rsvp")
+	rsvp.Use(middleware.RateLimitMiddleware(&cfg.API))
+	{
+		rsvp.GET("/:token", invitationHandler.GetRSVP)      // /rsvp/{token}
+		rsvp.POST("/:token", invitationHandler.RespondRSVP) // /rsvp/{token}
+	}
+
+	// Public guest avatars (no auth required, URLs carry an unguessable version)
+	engine.GET("/avatars/guests/:guest_id/:version/:file", guestHandler.ServeGuestAvatar) // /avatars/guests/{guest_id}/{version}/{file}
+
 	// Authentication endpoints (no auth required)
 	authGroup := engine.Group("/api/v1/auth")
 	authGroup.Use(middleware.RateLimitMiddleware(&cfg.API))
@@ -47,10 +69,25 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 		// Password authentication
 		authGroup.POST("/login", authHandler.Login)
 		authGroup.POST("/refresh", authHandler.RefreshToken)
+
+		// Second login step for users with two-factor authentication
+		authGroup.POST("/mfa/verify", authHandler.VerifyMFA)
+		authGroup.POST("/mfa/enroll", authHandler.EnrollMFAChallenge)
+
+		// Self-service password reset and email verification
+		authGroup.POST("/password/forgot", authHandler.ForgotPassword)
+		authGroup.POST("/password/reset", authHandler.ResetPassword)
+		authGroup.POST("/email/verify", authHandler.VerifyEmail)
+		authGroup.POST("/email/resend", authHandler.ResendVerification)
 		
 		// Google OIDC authentication
 		authGroup.GET("/google/login", authHandler.GoogleLogin)
 		authGroup.POST("/google/callback", authHandler.GoogleCallback)
+
+		// OpenID Connect authentication with any configured provider
+		authGroup.GET("/oidc/providers", authHandler.ListOIDCProviders)
+		authGroup.GET("/oidc/:provider/login", authHandler.OIDCLogin)
+		authGroup.POST("/oidc/:provider/callback", authHandler.OIDCCallback)
 		
 		// Protected authentication endpoints (require JWT)
 		protected := authGroup.Group("")
@@ -60,13 +97,31 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			protected.GET("/verify", authHandler.VerifyToken)
 			protected.GET("/sessions", authHandler.GetActiveSessions)
 			protected.DELETE("/sessions/:session_id", authHandler.RevokeSession)
+			protected.POST("/sessions/alerts/:alert_id/acknowledge", authHandler.AcknowledgeLoginAlert)
 			protected.POST("/google/link", authHandler.GoogleLink)
+
+			// Personal API tokens, managed from interactive sessions only
+			protected.GET("/tokens", authHandler.ListAPITokens)
+			protected.POST("/tokens", authHandler.CreateAPIToken)
+			protected.DELETE("/tokens/:token_id", authHandler.RevokeAPIToken)
+
+			// Linked identity provider accounts
+			protected.POST("/oidc/:provider/link", authHandler.OIDCLink)
+			protected.GET("/identities", authHandler.ListIdentities)
+			protected.DELETE("/identities/:identity_id", authHandler.UnlinkIdentity)
+
+			// Two-factor authentication management
+			protected.GET("/mfa", authHandler.GetMFAStatus)
+			protected.POST("/mfa/totp", authHandler.BeginTOTPEnrollment)
+			protected.POST("/mfa/totp/confirm", authHandler.ConfirmTOTPEnrollment)
+			protected.DELETE("/mfa/totp", authHandler.DisableTOTP)
+			protected.POST("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
 		}
 	}
 
 	// API endpoints with JWT-only authentication and rate limiting
 	api := engine.Group("/api/v1")
-	api.Use(middleware.JWTOnlyMiddleware(jwtService, sessionService))
+	api.Use(middleware.JWTOnlyMiddleware(jwtService, sessionService, apiTokenService))
 	api.Use(middleware.RateLimitMiddleware(&cfg.API))
 	{
 		// Media endpoints
@@ -79,6 +134,52 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			media.PUT("/get", mediaHandler.UpdateLinkMedia)        // /api/v1/media/get (update)
 			media.DELETE("/get", mediaHandler.DeleteLinkMedia)     // /api/v1/media/get (delete)
 			media.POST("/getDirect", mediaHandler.GetLinkMediaURI) // /api/v1/media/getDirect
+			media.POST("/archive", mediaHandler.DownloadArchive)  // /api/v1/media/archive
+
+			// Direct chunked uploads
+			media.POST("/upload", uploadHandler.InitiateUpload)                                // /api/v1/media/upload
+			media.GET("/upload/:upload_id", uploadHandler.GetUploadStatus)                     // /api/v1/media/upload/{upload_id}
+			media.PUT("/upload/:upload_id/chunks/:chunk_number", uploadHandler.UploadChunk)    // /api/v1/media/upload/{upload_id}/chunks/{chunk_number}
+			media.POST("/upload/:upload_id/complete", uploadHandler.CompleteUpload)            // /api/v1/media/upload/{upload_id}/complete
+			media.DELETE("/upload/:upload_id", uploadHandler.AbortUpload)                      // /api/v1/media/upload/{upload_id}
+
+			// Media library
+			media.POST("/search", mediaHandler.SearchMedia) // /api/v1/media/search
+			media.GET("/tags", mediaHandler.ListMediaTags)  // /api/v1/media/tags
+
+			// Media collections
+			media.POST("/collections", collectionHandler.CreateCollection)                                  // /api/v1/media/collections
+			media.GET("/collections", collectionHandler.ListCollections)                                    // /api/v1/media/collections
+			media.GET("/collections/:collection_id", collectionHandler.GetCollection)                       // /api/v1/media/collections/{collection_id}
+			media.PUT("/collections/:collection_id", collectionHandler.UpdateCollection)                    // /api/v1/media/collections/{collection_id}
+			media.DELETE("/collections/:collection_id", collectionHandler.DeleteCollection)                 // /api/v1/media/collections/{collection_id}
+			media.POST("/collections/:collection_id/media", collectionHandler.AddCollectionMedia)           // /api/v1/media/collections/{collection_id}/media
+			media.DELETE("/collections/:collection_id/media", collectionHandler.RemoveCollectionMedia)      // /api/v1/media/collections/{collection_id}/media
+			media.POST("/collections/:collection_id/attach", collectionHandler.AttachCollection)            // /api/v1/media/collections/{collection_id}/attach
+
+			// Share links
+			media.POST("/shares", shareHandler.CreateShareLink)                              // /api/v1/media/shares
+			media.GET("/shares", shareHandler.ListShareLinks)                                // /api/v1/media/shares
+			media.GET("/shares/:share_id/accesses", shareHandler.GetShareLinkAccesses)       // /api/v1/media/shares/{share_id}/accesses
+			media.DELETE("/shares/:share_id", shareHandler.RevokeShareLink)                  // /api/v1/media/shares/{share_id}
+
+			// Storage usage and quotas
+			media.GET("/usage", quotaHandler.GetMyUsage)                                     // /api/v1/media/usage
+			media.GET("/usage/:user_id", quotaHandler.GetUserUsage)                          // /api/v1/media/usage/{user_id}
+			media.PUT("/quotas/users/:user_id", quotaHandler.SetUserQuota)                   // /api/v1/media/quotas/users/{user_id}
+			media.DELETE("/quotas/users/:user_id", quotaHandler.DeleteUserQuota)             // /api/v1/media/quotas/users/{user_id}
+			media.PUT("/quotas/roles/:role_id", quotaHandler.SetRoleQuota)                   // /api/v1/media/quotas/roles/{role_id}
+			media.DELETE("/quotas/roles/:role_id", quotaHandler.DeleteRoleQuota)             // /api/v1/media/quotas/roles/{role_id}
+		}
+
+		// Team endpoints (members share their media library)
+		teams := api.Group("/teams")
+		{
+			teams.POST("", teamHandler.CreateTeam)                                   // /api/v1/teams
+			teams.GET("", teamHandler.ListTeams)                                     // /api/v1/teams
+			teams.GET("/:team_id", teamHandler.GetTeam)                              // /api/v1/teams/{team_id}
+			teams.POST("/:team_id/members", teamHandler.AddTeamMember)               // /api/v1/teams/{team_id}/members
+			teams.DELETE("/:team_id/members/:user_id", teamHandler.RemoveTeamMember) // /api/v1/teams/{team_id}/members/{user_id}
 		}
 
 
@@ -90,6 +191,10 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			showREST.GET("/:show_id", showHandler.GetShowREST)               // /api/v1/shows/{show_id}
 			showREST.PUT("/:show_id", showHandler.UpdateShowREST)            // /api/v1/shows/{show_id}
 			showREST.DELETE("/:show_id", showHandler.DeleteShowREST)         // /api/v1/shows/{show_id}
+			showREST.GET("/:show_id/secrets", showHandler.RevealShowSecrets) // /api/v1/shows/{show_id}/secrets
+			showREST.GET("/:show_id/rundown-template", blockHandler.GetRundownTemplate)       // /api/v1/shows/{show_id}/rundown-template
+			showREST.PUT("/:show_id/rundown-template", blockHandler.SetRundownTemplate)       // /api/v1/shows/{show_id}/rundown-template
+			showREST.DELETE("/:show_id/rundown-template", blockHandler.DeleteRundownTemplate) // /api/v1/shows/{show_id}/rundown-template
 		}
 
 
@@ -101,6 +206,31 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			guest.POST("/list", guestHandler.ListGuests)           // /api/v1/guest/list
 			guest.GET("/autocomplete", guestHandler.AutocompleteGuests) // /api/v1/guest/autocomplete
 			guest.GET("/info/:guest_id", guestHandler.GetGuestInfo) // /api/v1/guest/info/{guest_id}
+			guest.GET("/appearances/:guest_id", guestHandler.GetGuestAppearances) // /api/v1/guest/appearances/{guest_id}
+			guest.GET("/duplicates", guestHandler.FindDuplicateGuests)               // /api/v1/guest/duplicates
+			guest.POST("/merge", guestHandler.MergeGuests)                           // /api/v1/guest/merge
+			guest.POST("/merge/:merge_id/undo", guestHandler.UndoGuestMerge)         // /api/v1/guest/merge/{merge_id}/undo
+			guest.POST("/import", guestHandler.ImportGuests)                         // /api/v1/guest/import
+			guest.POST("/export", guestHandler.ExportGuests)                         // /api/v1/guest/export
+			guest.POST("/avatar/:guest_id", guestHandler.UploadGuestAvatar)             // /api/v1/guest/avatar/{guest_id}
+			guest.POST("/avatar/:guest_id/telegram", guestHandler.ImportTelegramAvatar) // /api/v1/guest/avatar/{guest_id}/telegram
+			guest.GET("/consents/:guest_id", guestHandler.GetGuestConsents)              // /api/v1/guest/consents/{guest_id}
+			guest.POST("/consents/:guest_id", guestHandler.RecordGuestConsent)           // /api/v1/guest/consents/{guest_id}
+			guest.GET("/personal-data/:guest_id", guestHandler.ExportGuestPersonalData)  // /api/v1/guest/personal-data/{guest_id}
+			guest.POST("/erase/:guest_id", guestHandler.EraseGuest)                      // /api/v1/guest/erase/{guest_id}
+			guest.GET("/availability/:guest_id", guestHandler.GetGuestAvailability)      // /api/v1/guest/availability/{guest_id}
+			guest.PUT("/availability/:guest_id", guestHandler.SetGuestAvailability)      // /api/v1/guest/availability/{guest_id}
+			guest.POST("/availability/suggest", guestHandler.SuggestEvents)              // /api/v1/guest/availability/suggest
+			guest.GET("/tags", guestHandler.ListGuestTags)                               // /api/v1/guest/tags
+			guest.POST("/tags", guestHandler.CreateGuestTag)                             // /api/v1/guest/tags
+			guest.POST("/tags/merge", guestHandler.MergeGuestTags)                       // /api/v1/guest/tags/merge
+			guest.PUT("/tags/:tag_id", guestHandler.UpdateGuestTag)                      // /api/v1/guest/tags/{tag_id}
+			guest.DELETE("/tags/:tag_id", guestHandler.DeleteGuestTag)                   // /api/v1/guest/tags/{tag_id}
+			guest.GET("/segments", guestHandler.ListGuestSegments)                       // /api/v1/guest/segments
+			guest.POST("/segments", guestHandler.CreateGuestSegment)                     // /api/v1/guest/segments
+			guest.GET("/segments/:segment_id", guestHandler.GetGuestSegment)             // /api/v1/guest/segments/{segment_id}
+			guest.PUT("/segments/:segment_id", guestHandler.UpdateGuestSegment)          // /api/v1/guest/segments/{segment_id}
+			guest.DELETE("/segments/:segment_id", guestHandler.DeleteGuestSegment)       // /api/v1/guest/segments/{segment_id}
 			guest.DELETE("/delete", guestHandler.DeleteGuest)      // /api/v1/guest/delete
 		}
 
@@ -111,7 +241,12 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			block.PUT("/update", blockHandler.UpdateBlock)         // /api/v1/block/update
 			block.GET("/info/:block_id", blockHandler.GetBlockInfo) // /api/v1/block/info/{block_id}
 			block.PUT("/reorder", blockHandler.ReorderBlocks)      // /api/v1/block/reorder
+			block.POST("/clone", blockHandler.CloneBlocks)         // /api/v1/block/clone
 			block.DELETE("/delete", blockHandler.DeleteBlock)      // /api/v1/block/delete
+
+			// Guest invitations
+			block.POST("/:block_id/guests/:guest_id/invite", invitationHandler.InviteGuest)        // /api/v1/block/{block_id}/guests/{guest_id}/invite
+			block.PUT("/:block_id/guests/:guest_id/status", invitationHandler.SetInvitationStatus) // /api/v1/block/{block_id}/guests/{guest_id}/status
 		}
 
 		// Event-specific block endpoints
@@ -128,6 +263,9 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			userREST.DELETE("/:user_id", userHandler.DeleteUserREST)                   // /api/v1/users/{user_id}
 			userREST.PUT("/:user_id/roles/:role_id", userHandler.AddRoleToUser)        // /api/v1/users/{user_id}/roles/{role_id}
 			userREST.DELETE("/:user_id/roles/:role_id", userHandler.RemoveRoleFromUser) // /api/v1/users/{user_id}/roles/{role_id}
+			userREST.DELETE("/:user_id/mfa", userHandler.ResetUserMFA)                 // /api/v1/users/{user_id}/mfa
+			userREST.POST("/:user_id/unlock", userHandler.UnlockUser)                  // /api/v1/users/{user_id}/unlock
+			userREST.DELETE("/:user_id/tokens", userHandler.RevokeUserAPITokens)       // /api/v1/users/{user_id}/tokens
 		}
 
 
@@ -140,8 +278,12 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			roleREST.PUT("/:role_id", roleHandler.UpdateRoleREST)                      // /api/v1/roles/{role_id}
 			roleREST.DELETE("/:role_id", roleHandler.DeleteRoleREST)                   // /api/v1/roles/{role_id}
 			roleREST.PUT("/:role_id/users/:user_id", roleHandler.AddUserToRole)        // /api/v1/roles/{role_id}/users/{user_id}
+			roleREST.PUT("/:role_id/mfa", roleHandler.SetRoleMFA)                      // /api/v1/roles/{role_id}/mfa
 		}
 
+		// Audit log of changes to audited entities
+		api.GET("/audit", auditHandler.ListAuditLog) // /api/v1/audit
+
 		// RESTful Event endpoints (New)
 		eventREST := api.Group("/events")
 		{
@@ -149,6 +291,9 @@ func NewRouter(cfg *config.Config, postHandler *handlers.PostHandler, mediaHandl
 			eventREST.GET("/:event_id", eventHandler.GetEventREST)                     // /api/v1/events/{event_id}
 			eventREST.PUT("/:event_id", eventHandler.UpdateEventREST)                  // /api/v1/events/{event_id}
 			eventREST.DELETE("/:event_id", eventHandler.DeleteEventREST)               // /api/v1/events/{event_id}
+			eventREST.GET("/:event_id/secrets", eventHandler.RevealEventSecrets)       // /api/v1/events/{event_id}/secrets
+			eventREST.POST("/:event_id/rundown-template/apply", blockHandler.ApplyRundownTemplate) // /api/v1/events/{event_id}/rundown-template/apply
+			eventREST.POST("/:event_id/rundown-template/save", blockHandler.SaveRundownTemplate)   // /api/v1/events/{event_id}/rundown-template/save
 		}
 	}
 
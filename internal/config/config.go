@@ -11,13 +11,21 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig
-	Postgres PostgresConfig
-	S3       S3Config
-	Telegram TelegramConfig
-	API      APIConfig
-	Download DownloadConfig
-	CORS     CORSConfig
+	Server     ServerConfig
+	Postgres   PostgresConfig
+	S3         S3Config
+	Telegram   TelegramConfig
+	API        APIConfig
+	Download   DownloadConfig
+	Upload     UploadConfig
+	Share      ShareConfig
+	Mail       MailConfig
+	OIDC       OIDCConfig
+	Audit      AuditConfig
+	Secrets    SecretsConfig
+	Invitation InvitationConfig
+	Avatar     AvatarConfig
+	CORS       CORSConfig
 }
 
 type ServerConfig struct {
@@ -50,16 +58,96 @@ type TelegramConfig struct {
 }
 
 type APIConfig struct {
-	APIKey            string
-	JWTSecret         string
-	RateLimitRequests int
-	RateLimitWindow   time.Duration
+	APIKey                 string
+	JWTSecret              string
+	JWTSigningAlgorithm    string
+	JWTKeyRotationInterval time.Duration
+	JWTAcceptLegacyHS256   bool
+	MFAIssuer              string
+	MFAChallengeTTL        time.Duration
+	LoginMaxFailures       int
+	LoginIPMaxFailures     int
+	LoginFailureWindow     time.Duration
+	LoginLockoutDuration   time.Duration
+	LoginMaxLockout        time.Duration
+	APITokenDefaultTTL     time.Duration
+	APITokenMaxTTL         time.Duration
+	GeoIPDatabase          string // CSV of IP ranges and countries for session locations, empty disables the lookup
+	RateLimitRequests      int
+	RateLimitWindow        time.Duration
 }
 
 type DownloadConfig struct {
 	MaxConcurrentDownloads int
 	DownloadTimeout        time.Duration
 	MaxFileSize            int64
+	UserQuotaBytes         int64
+	UserQuotaFiles         int
+}
+
+type UploadConfig struct {
+	ChunkSize  int64
+	SessionTTL time.Duration
+}
+
+type ShareConfig struct {
+	PublicBaseURL string
+	DefaultTTL    time.Duration
+	MaxTTL        time.Duration
+}
+
+type MailConfig struct {
+	Driver       string // smtp, file or log
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	FileDir      string
+	// AppBaseURL is the frontend address used in password reset and verification links
+	AppBaseURL           string
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	RoleClaim    string
+	RoleMapping  map[string]string // claim value -> role name
+	DefaultRole  string
+	AllowSignup  bool
+	TrustEmail   bool
+}
+
+type AuditConfig struct {
+	Retention time.Duration // how long audit log entries are kept, 0 keeps them forever
+}
+
+type SecretsConfig struct {
+	Provider  string // key provider wrapping the data keys, only "local" for now
+	KeyID     string // identifies the master key in encrypted values
+	MasterKey string // base64 encoded 32 byte key used by the local provider
+}
+
+type InvitationConfig struct {
+	Notifier         string        // how guests are notified: mail or log
+	SigningKey       string        // base64 encoded key of at least 32 bytes signing RSVP links
+	ReminderLeadTime time.Duration // how long before the event invited guests are reminded
+}
+
+type AvatarConfig struct {
+	PublicBaseURL string // address the public avatar URLs point to, empty for relative URLs
+	MaxUploadSize int64  // largest accepted image in bytes
 }
 
 type CORSConfig struct {
@@ -116,6 +204,47 @@ func Load() (*Config, error) {
 	// API configuration
 	cfg.API.APIKey = getEnvRequired("API_KEY")
 	cfg.API.JWTSecret = getEnv("JWT_SECRET", "dev-jwt-secret-change-in-production-must-be-at-least-32-chars")
+	cfg.API.JWTSigningAlgorithm = getEnv("JWT_SIGNING_ALGORITHM", "EdDSA")
+	jwtKeyRotationInterval, err := time.ParseDuration(getEnv("JWT_KEY_ROTATION_INTERVAL", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_KEY_ROTATION_INTERVAL: %w", err)
+	}
+	cfg.API.JWTKeyRotationInterval = jwtKeyRotationInterval
+	cfg.API.JWTAcceptLegacyHS256 = getEnvBool("JWT_ACCEPT_LEGACY_HS256", true)
+	cfg.API.MFAIssuer = getEnv("MFA_ISSUER", "St. Planer")
+	mfaChallengeTTL, err := time.ParseDuration(getEnv("MFA_CHALLENGE_TTL", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MFA_CHALLENGE_TTL: %w", err)
+	}
+	cfg.API.MFAChallengeTTL = mfaChallengeTTL
+	cfg.API.LoginMaxFailures = getEnvInt("LOGIN_MAX_FAILURES", 5)
+	cfg.API.LoginIPMaxFailures = getEnvInt("LOGIN_IP_MAX_FAILURES", 50)
+	loginFailureWindow, err := time.ParseDuration(getEnv("LOGIN_FAILURE_WINDOW", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_FAILURE_WINDOW: %w", err)
+	}
+	cfg.API.LoginFailureWindow = loginFailureWindow
+	loginLockoutDuration, err := time.ParseDuration(getEnv("LOGIN_LOCKOUT_DURATION", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION: %w", err)
+	}
+	cfg.API.LoginLockoutDuration = loginLockoutDuration
+	loginMaxLockout, err := time.ParseDuration(getEnv("LOGIN_MAX_LOCKOUT", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid LOGIN_MAX_LOCKOUT: %w", err)
+	}
+	cfg.API.LoginMaxLockout = loginMaxLockout
+	apiTokenDefaultTTL, err := time.ParseDuration(getEnv("API_TOKEN_DEFAULT_TTL", "2160h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_TOKEN_DEFAULT_TTL: %w", err)
+	}
+	cfg.API.APITokenDefaultTTL = apiTokenDefaultTTL
+	apiTokenMaxTTL, err := time.ParseDuration(getEnv("API_TOKEN_MAX_TTL", "8760h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_TOKEN_MAX_TTL: %w", err)
+	}
+	cfg.API.APITokenMaxTTL = apiTokenMaxTTL
+	cfg.API.GeoIPDatabase = getEnv("GEOIP_DATABASE", "")
 	cfg.API.RateLimitRequests = getEnvInt("RATE_LIMIT_REQUESTS", 100)
 	rateLimitWindow, err := time.ParseDuration(getEnv("RATE_LIMIT_WINDOW", "1m"))
 	if err != nil {
@@ -131,6 +260,81 @@ func Load() (*Config, error) {
 	}
 	cfg.Download.DownloadTimeout = downloadTimeout
 	cfg.Download.MaxFileSize = getEnvInt64("MAX_FILE_SIZE", 2*1024*1024*1024) // 2GB default
+	cfg.Download.UserQuotaBytes = getEnvInt64("MEDIA_QUOTA_BYTES", 0)         // 0 means unlimited
+	cfg.Download.UserQuotaFiles = getEnvInt("MEDIA_QUOTA_FILES", 0)           // 0 means unlimited
+
+	// Upload configuration
+	cfg.Upload.ChunkSize = getEnvInt64("UPLOAD_CHUNK_SIZE", 8*1024*1024) // 8MB default, S3 requires at least 5MB per part
+	uploadSessionTTL, err := time.ParseDuration(getEnv("UPLOAD_SESSION_TTL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_SESSION_TTL: %w", err)
+	}
+	cfg.Upload.SessionTTL = uploadSessionTTL
+
+	// Share link configuration
+	cfg.Share.PublicBaseURL = strings.TrimRight(getEnv("SHARE_PUBLIC_BASE_URL", ""), "/")
+	shareDefaultTTL, err := time.ParseDuration(getEnv("SHARE_DEFAULT_TTL", "168h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHARE_DEFAULT_TTL: %w", err)
+	}
+	cfg.Share.DefaultTTL = shareDefaultTTL
+	shareMaxTTL, err := time.ParseDuration(getEnv("SHARE_MAX_TTL", "720h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHARE_MAX_TTL: %w", err)
+	}
+	cfg.Share.MaxTTL = shareMaxTTL
+
+	// Mail configuration
+	cfg.Mail.Driver = getEnv("MAIL_DRIVER", "log")
+	cfg.Mail.From = getEnv("MAIL_FROM", "St. Planer <no-reply@localhost>")
+	cfg.Mail.SMTPHost = getEnv("SMTP_HOST", "localhost")
+	cfg.Mail.SMTPPort = getEnvInt("SMTP_PORT", 587)
+	cfg.Mail.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	cfg.Mail.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	cfg.Mail.FileDir = getEnv("MAIL_FILE_DIR", "mail")
+	cfg.Mail.AppBaseURL = strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:3000"), "/")
+	passwordResetTTL, err := time.ParseDuration(getEnv("PASSWORD_RESET_TTL", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_TTL: %w", err)
+	}
+	cfg.Mail.PasswordResetTTL = passwordResetTTL
+	emailVerificationTTL, err := time.ParseDuration(getEnv("EMAIL_VERIFICATION_TTL", "48h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_TTL: %w", err)
+	}
+	cfg.Mail.EmailVerificationTTL = emailVerificationTTL
+
+	// OpenID Connect providers
+	oidcConfig, err := loadOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.OIDC = oidcConfig
+
+	// Audit log configuration
+	auditRetention, err := time.ParseDuration(getEnv("AUDIT_RETENTION", "8760h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUDIT_RETENTION: %w", err)
+	}
+	cfg.Audit.Retention = auditRetention
+
+	// Encryption of stream keys and Zoom passcodes at rest
+	cfg.Secrets.Provider = getEnv("SECRETS_PROVIDER", "local")
+	cfg.Secrets.KeyID = getEnv("SECRETS_KEY_ID", "local-1")
+	cfg.Secrets.MasterKey = getEnvRequired("SECRETS_MASTER_KEY")
+
+	// Guest invitations and RSVP links
+	cfg.Invitation.Notifier = getEnv("INVITATION_NOTIFIER", "mail")
+	cfg.Invitation.SigningKey = getEnvRequired("INVITATION_SIGNING_KEY")
+	reminderLeadTime, err := time.ParseDuration(getEnv("INVITATION_REMINDER_LEAD", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid INVITATION_REMINDER_LEAD: %w", err)
+	}
+	cfg.Invitation.ReminderLeadTime = reminderLeadTime
+
+	// Guest avatars, served from the same public address as share links by default
+	cfg.Avatar.PublicBaseURL = strings.TrimRight(getEnv("AVATAR_PUBLIC_BASE_URL", cfg.Share.PublicBaseURL), "/")
+	cfg.Avatar.MaxUploadSize = getEnvInt64("AVATAR_MAX_UPLOAD_SIZE", 5*1024*1024) // 5MB default
 
 	// CORS configuration
 	cfg.CORS = loadCORSConfig()
@@ -187,6 +391,84 @@ func getEnvStringSlice(key string, defaultValue []string) []string {
 	return defaultValue
 }
 
+// loadOIDCConfig reads the providers listed in OIDC_PROVIDERS from OIDC_<NAME>_* variables.
+// GOOGLE_CLIENT_ID and friends still configure a "google" provider if it isn't listed.
+func loadOIDCConfig() (OIDCConfig, error) {
+	var cfg OIDCConfig
+	listed := map[string]bool{}
+
+	for _, name := range getEnvStringSlice("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if listed[name] {
+			return cfg, fmt.Errorf("OIDC provider %s is listed twice in OIDC_PROVIDERS", name)
+		}
+		listed[name] = true
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		roleMapping, err := parseRoleMapping(getEnv(prefix+"ROLE_MAPPING", ""))
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %sROLE_MAPPING: %w", prefix, err)
+		}
+
+		provider := OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURI:  getEnv(prefix+"REDIRECT_URI", ""),
+			Scopes:       strings.Fields(strings.ReplaceAll(getEnv(prefix+"SCOPES", "openid,email,profile"), ",", " ")),
+			RoleClaim:    getEnv(prefix+"ROLE_CLAIM", ""),
+			RoleMapping:  roleMapping,
+			DefaultRole:  getEnv(prefix+"DEFAULT_ROLE", "user"),
+			AllowSignup:  getEnvBool(prefix+"ALLOW_SIGNUP", false),
+			TrustEmail:   getEnvBool(prefix+"TRUST_EMAIL", false),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return cfg, fmt.Errorf("%sISSUER and %sCLIENT_ID are required", prefix, prefix)
+		}
+		cfg.Providers = append(cfg.Providers, provider)
+	}
+
+	if clientID := os.Getenv("GOOGLE_CLIENT_ID"); clientID != "" && !listed["google"] {
+		cfg.Providers = append(cfg.Providers, OIDCProviderConfig{
+			Name:         "google",
+			DisplayName:  "Google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+			RedirectURI:  os.Getenv("GOOGLE_REDIRECT_URI"),
+			Scopes:       []string{"openid", "email", "profile"},
+			DefaultRole:  "user",
+			AllowSignup:  true,
+			TrustEmail:   true,
+		})
+	}
+
+	return cfg, nil
+}
+
+// parseRoleMapping parses "claim-value=role,other-value=role"
+func parseRoleMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		claimValue, role, ok := strings.Cut(pair, "=")
+		claimValue, role = strings.TrimSpace(claimValue), strings.TrimSpace(role)
+		if !ok || claimValue == "" || role == "" {
+			return nil, fmt.Errorf("expected claim-value=role, got %q", pair)
+		}
+		mapping[claimValue] = role
+	}
+	return mapping, nil
+}
+
 // loadCORSConfig loads CORS configuration based on profile or custom settings
 func loadCORSConfig() CORSConfig {
 	profile := getEnv("CORS_PROFILE", "custom")
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -20,8 +21,28 @@ import (
 )
 
 type PostgresDB struct {
-	pool *pgxpool.Pool
-	db   *sql.DB
+	pool    *pgxpool.Pool
+	db      *sql.DB
+	secrets SecretCipher
+}
+
+// SecretCipher encrypts the stream keys and Zoom passcodes of shows and events at rest.
+// Decrypt has to return values it didn't encrypt unchanged.
+type SecretCipher interface {
+	Encrypt(ctx context.Context, plaintext string) (string, error)
+	Decrypt(ctx context.Context, value string) (string, error)
+}
+
+// querier runs queries through either the pool or a transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// SetSecretCipher enables encryption of show and event secrets. Without a cipher they
+// are stored as they are.
+func (p *PostgresDB) SetSecretCipher(cipher SecretCipher) {
+	p.secrets = cipher
 }
 
 func NewPostgresDB(cfg *config.PostgresConfig) (*PostgresDB, error) {
@@ -38,6 +59,10 @@ func NewPostgresDB(cfg *config.PostgresConfig) (*PostgresDB, error) {
 		return nil, fmt.Errorf("failed to parse connection string: %w", err)
 	}
 
+	// Tell the audit triggers who is making the changes of a request
+	poolConfig.BeforeAcquire = applyAuditActor
+	poolConfig.AfterRelease = clearAuditActor
+
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
@@ -69,6 +94,47 @@ func NewPostgresDB(cfg *config.PostgresConfig) (*PostgresDB, error) {
 	return pgdb, nil
 }
 
+// auditActorSetting is the session setting the audit triggers read the acting user from
+const auditActorSetting = "stplaner.audit_actor"
+
+// applyAuditActor sets the actor of the request acquiring a connection for the audit
+// triggers. Connections acquired outside of a request record changes without an actor.
+func applyAuditActor(ctx context.Context, conn *pgx.Conn) bool {
+	actor := utils.GetAuditActor(ctx)
+	if actor == nil {
+		return true
+	}
+
+	value, err := json.Marshal(actor)
+	if err != nil {
+		return true
+	}
+	if _, err := conn.Exec(ctx, `SELECT set_config($1, $2, false)`, auditActorSetting, string(value)); err != nil {
+		return false
+	}
+	conn.PgConn().CustomData()[auditActorSetting] = true
+
+	return true
+}
+
+// clearAuditActor resets the actor before a connection goes back to the pool
+func clearAuditActor(conn *pgx.Conn) bool {
+	data := conn.PgConn().CustomData()
+	if data[auditActorSetting] == nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, `SELECT set_config($1, '', false)`, auditActorSetting); err != nil {
+		return false
+	}
+	delete(data, auditActorSetting)
+
+	return true
+}
+
 func (p *PostgresDB) createTables(ctx context.Context) error {
 	// Create custom type for post status
 	createTypeQuery := `
@@ -821,254 +887,4680 @@ func (p *PostgresDB) runMigrations(ctx context.Context) error {
 				-- This is just the function definition, actual scheduling would be done externally
 			`,
 		},
-	}
+		{
+			Version:     12,
+			Description: "Add media upload sessions for direct chunked uploads",
+			SQL: `
+				-- Create custom type for upload status
+				DO $$ BEGIN
+					CREATE TYPE upload_status AS ENUM ('in_progress', 'completed', 'aborted', 'expired');
+				EXCEPTION
+					WHEN duplicate_object THEN null;
+				END $$;
 
-	// Run each migration if not already applied
-	for _, migration := range migrations {
-		if err := p.runMigration(ctx, migration); err != nil {
-			return fmt.Errorf("failed to run migration %d: %w", migration.Version, err)
-		}
-	}
+				-- Create media_uploads table
+				CREATE TABLE IF NOT EXISTS media_uploads (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL,
+					file_name VARCHAR(500) NOT NULL,
+					file_type VARCHAR(100) NOT NULL,
+					file_size BIGINT NOT NULL CHECK (file_size > 0),
+					chunk_size BIGINT NOT NULL CHECK (chunk_size > 0),
+					total_chunks INTEGER NOT NULL CHECK (total_chunks > 0),
+					s3_key VARCHAR(500) NOT NULL,
+					storage_upload_id VARCHAR(1024) NOT NULL,
+					status upload_status NOT NULL DEFAULT 'in_progress',
+					media_id VARCHAR(255),
+					expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					completed_at TIMESTAMP WITH TIME ZONE
+				);
 
-	return nil
-}
+				-- Create media_upload_parts table, one row per received chunk
+				CREATE TABLE IF NOT EXISTS media_upload_parts (
+					upload_id UUID NOT NULL REFERENCES media_uploads(id) ON DELETE CASCADE,
+					part_number INTEGER NOT NULL CHECK (part_number > 0),
+					etag VARCHAR(255) NOT NULL,
+					size BIGINT NOT NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (upload_id, part_number)
+				);
 
-// createMigrationsTable creates the migrations tracking table
-func (p *PostgresDB) createMigrationsTable(ctx context.Context) error {
-	createTableSQL := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
-			version INTEGER PRIMARY KEY,
-			description VARCHAR(255) NOT NULL,
-			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-		);
-	`
+				-- Create indexes
+				CREATE INDEX IF NOT EXISTS idx_media_uploads_user_id ON media_uploads(user_id);
+				CREATE INDEX IF NOT EXISTS idx_media_uploads_status ON media_uploads(status);
+				CREATE INDEX IF NOT EXISTS idx_media_uploads_expires_at ON media_uploads(expires_at) WHERE status = 'in_progress';
 
-	_, err := p.pool.Exec(ctx, createTableSQL)
-	return err
-}
+				-- Create update trigger for media_uploads
+				DROP TRIGGER IF EXISTS update_media_uploads_updated_at ON media_uploads;
+				CREATE TRIGGER update_media_uploads_updated_at BEFORE UPDATE ON media_uploads
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+			`,
+		},
+		{
+			Version:     13,
+			Description: "Add media library tags, notes, full-text search and collections",
+			SQL: `
+				-- Add library columns to media
+				ALTER TABLE media ADD COLUMN IF NOT EXISTS tags JSONB DEFAULT '[]'::jsonb;
+				ALTER TABLE media ADD COLUMN IF NOT EXISTS notes TEXT;
+				ALTER TABLE media ADD COLUMN IF NOT EXISTS duration_seconds INTEGER;
+				ALTER TABLE media ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+				-- Keep the search vector in sync with file names, captions, notes, tags and the source channel
+				CREATE OR REPLACE FUNCTION media_search_vector_update()
+				RETURNS TRIGGER AS $$
+				DECLARE
+					channel_text TEXT;
+				BEGIN
+					SELECT COALESCE(channel_name, '') || ' ' || COALESCE(original_channel_name, '')
+					INTO channel_text
+					FROM posts WHERE content_id = NEW.content_id;
+
+					NEW.search_vector :=
+						setweight(to_tsvector('simple', COALESCE(NEW.original_file_name, '') || ' ' || COALESCE(NEW.file_name, '')), 'A') ||
+						setweight(to_tsvector('simple', COALESCE(NEW.metadata->>'title', '') || ' ' || COALESCE(NEW.metadata->>'caption', '')), 'B') ||
+						setweight(to_tsvector('simple', COALESCE(channel_text, '') || ' ' || COALESCE(NEW.metadata->>'author', '')), 'B') ||
+						setweight(to_tsvector('simple', COALESCE(NEW.notes, '') || ' ' ||
+							COALESCE((SELECT string_agg(value, ' ') FROM jsonb_array_elements_text(COALESCE(NEW.tags, '[]'::jsonb))), '')), 'B') ||
+						setweight(to_tsvector('simple', COALESCE(NEW.metadata->>'description', '')), 'C');
+					RETURN NEW;
+				END;
+				$$ LANGUAGE plpgsql;
 
-// runMigration executes a single migration if it hasn't been applied yet
-func (p *PostgresDB) runMigration(ctx context.Context, migration Migration) error {
-	// Check if migration has already been applied
-	var exists bool
-	checkSQL := "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)"
-	err := p.pool.QueryRow(ctx, checkSQL, migration.Version).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check migration status: %w", err)
-	}
+				DROP TRIGGER IF EXISTS update_media_search_vector ON media;
+				CREATE TRIGGER update_media_search_vector BEFORE INSERT OR UPDATE ON media
+					FOR EACH ROW EXECUTE FUNCTION media_search_vector_update();
 
-	if exists {
-		// Migration already applied, skip
-		utils.LogInfo(ctx, "Migration already applied", utils.Fields{
-			"version":     migration.Version,
-			"description": migration.Description,
-		})
-		return nil
-	}
+				-- Refresh media search vectors when a post's channel name changes
+				CREATE OR REPLACE FUNCTION posts_refresh_media_search_vector()
+				RETURNS TRIGGER AS $$
+				BEGIN
+					IF NEW.channel_name IS DISTINCT FROM OLD.channel_name
+						OR NEW.original_channel_name IS DISTINCT FROM OLD.original_channel_name THEN
+						UPDATE media SET search_vector = NULL WHERE content_id = NEW.content_id;
+					END IF;
+					RETURN NEW;
+				END;
+				$$ LANGUAGE plpgsql;
 
-	// Start transaction for migration
-	tx, err := p.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin migration transaction: %w", err)
-	}
-	defer tx.Rollback(ctx)
+				DROP TRIGGER IF EXISTS refresh_media_search_vector ON posts;
+				CREATE TRIGGER refresh_media_search_vector AFTER UPDATE ON posts
+					FOR EACH ROW EXECUTE FUNCTION posts_refresh_media_search_vector();
 
-	// Execute migration SQL
-	utils.LogInfo(ctx, "Applying migration", utils.Fields{
-		"version":     migration.Version,
-		"description": migration.Description,
-	})
+				-- Backfill search vectors for existing media
+				UPDATE media SET tags = COALESCE(tags, '[]'::jsonb);
 
-	_, err = tx.Exec(ctx, migration.SQL)
-	if err != nil {
-		return fmt.Errorf("failed to execute migration SQL: %w", err)
-	}
+				-- Create indexes
+				CREATE INDEX IF NOT EXISTS idx_media_search_vector ON media USING gin(search_vector);
+				CREATE INDEX IF NOT EXISTS idx_media_tags ON media USING gin(tags);
+				CREATE INDEX IF NOT EXISTS idx_media_file_type ON media(file_type);
+				CREATE INDEX IF NOT EXISTS idx_media_file_size ON media(file_size);
+				CREATE INDEX IF NOT EXISTS idx_media_downloaded_at ON media(downloaded_at DESC);
+
+				-- Create media_collections table
+				CREATE TABLE IF NOT EXISTS media_collections (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					name VARCHAR(255) NOT NULL,
+					description TEXT,
+					user_id UUID NOT NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
 
-	// Record migration as applied
-	recordSQL := `
-		INSERT INTO schema_migrations (version, description) 
-		VALUES ($1, $2)
-	`
-	_, err = tx.Exec(ctx, recordSQL, migration.Version, migration.Description)
-	if err != nil {
-		return fmt.Errorf("failed to record migration: %w", err)
-	}
+					-- Constraints
+					CONSTRAINT valid_collection_name CHECK (LENGTH(TRIM(name)) > 0),
+					CONSTRAINT unique_user_collection UNIQUE(user_id, name)
+				);
 
-	// Commit transaction
-	if err = tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit migration transaction: %w", err)
-	}
+				-- Create media_collection_items junction table
+				CREATE TABLE IF NOT EXISTS media_collection_items (
+					collection_id UUID NOT NULL REFERENCES media_collections(id) ON DELETE CASCADE,
+					media_id UUID NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+					order_index INTEGER NOT NULL DEFAULT 0,
+					added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (collection_id, media_id)
+				);
 
-	utils.LogInfo(ctx, "Migration applied successfully", utils.Fields{
-		"version":     migration.Version,
-		"description": migration.Description,
-	})
+				-- Create indexes for collections
+				CREATE INDEX IF NOT EXISTS idx_media_collections_user_id ON media_collections(user_id);
+				CREATE INDEX IF NOT EXISTS idx_media_collection_items_media_id ON media_collection_items(media_id);
+				CREATE INDEX IF NOT EXISTS idx_media_collection_items_order ON media_collection_items(collection_id, order_index);
 
-	return nil
-}
+				-- Create update trigger for media_collections
+				DROP TRIGGER IF EXISTS update_media_collections_updated_at ON media_collections;
+				CREATE TRIGGER update_media_collections_updated_at BEFORE UPDATE ON media_collections
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+			`,
+		},
+		{
+			Version:     14,
+			Description: "Create media share links tables",
+			SQL: `
+				-- Create share_links table
+				CREATE TABLE IF NOT EXISTS share_links (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					token_hash VARCHAR(64) NOT NULL UNIQUE,
+					media_id UUID REFERENCES media(id) ON DELETE CASCADE,
+					collection_id UUID REFERENCES media_collections(id) ON DELETE CASCADE,
+					created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					password_hash VARCHAR(255),
+					expires_at TIMESTAMP WITH TIME ZONE,
+					max_downloads INTEGER,
+					download_count INTEGER NOT NULL DEFAULT 0,
+					revoked_at TIMESTAMP WITH TIME ZONE,
+					revoked_by UUID REFERENCES users(id) ON DELETE SET NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					CONSTRAINT share_link_scope CHECK ((media_id IS NULL) <> (collection_id IS NULL)),
+					CONSTRAINT share_link_max_downloads CHECK (max_downloads IS NULL OR max_downloads > 0)
+				);
 
-// Post operations
-func (p *PostgresDB) CreatePost(ctx context.Context, post *models.Post) error {
-	post.ID = uuid.New()
-	post.CreatedAt = time.Now()
-	post.UpdatedAt = time.Now()
+				-- Create share_link_accesses table
+				CREATE TABLE IF NOT EXISTS share_link_accesses (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					share_link_id UUID NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+					media_id UUID REFERENCES media(id) ON DELETE SET NULL,
+					granted BOOLEAN NOT NULL,
+					reason VARCHAR(50),
+					ip_address INET,
+					user_agent TEXT,
+					range_header VARCHAR(100),
+					accessed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
 
-	query := `
-		INSERT INTO posts (id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
-			created_at, updated_at, status, media_count, total_size, error_message)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, created_at, updated_at`
+				-- Create indexes
+				CREATE INDEX IF NOT EXISTS idx_share_links_created_by ON share_links(created_by);
+				CREATE INDEX IF NOT EXISTS idx_share_links_media_id ON share_links(media_id);
+				CREATE INDEX IF NOT EXISTS idx_share_links_collection_id ON share_links(collection_id);
+				CREATE INDEX IF NOT EXISTS idx_share_link_accesses_share_link_id ON share_link_accesses(share_link_id, accessed_at DESC);
 
-	err := p.pool.QueryRow(ctx, query,
-		post.ID, post.ContentID, post.TelegramLink, post.ChannelName, post.OriginalChannelName, post.MessageID,
-		post.CreatedAt, post.UpdatedAt, post.Status, post.MediaCount, post.TotalSize,
-		post.ErrorMessage,
-	).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+				-- Create trigger for updated_at
+				CREATE TRIGGER update_share_links_updated_at BEFORE UPDATE ON share_links
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+			`,
+		},
+		{
+			Version:     15,
+			Description: "Add media ownership, teams and media quotas",
+			SQL: `
+				-- Record the user who grabbed a post or stored a media file
+				ALTER TABLE posts ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+				ALTER TABLE media ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id) ON DELETE SET NULL;
 
-	return err
-}
+				CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts(user_id);
+				CREATE INDEX IF NOT EXISTS idx_media_user_id ON media(user_id);
 
-func (p *PostgresDB) GetPostByContentID(ctx context.Context, contentID string) (*models.Post, error) {
-	post := &models.Post{}
-	query := `
-		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
-			created_at, updated_at, status, media_count, total_size, error_message
-		FROM posts WHERE content_id = $1`
+				-- Every user who requested a post, posts are shared between grabbers
+				CREATE TABLE IF NOT EXISTS post_grabs (
+					post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					PRIMARY KEY (post_id, user_id)
+				);
 
-	err := p.pool.QueryRow(ctx, query, contentID).Scan(
-		&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
-		&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
-		&post.ErrorMessage,
-	)
+				CREATE INDEX IF NOT EXISTS idx_post_grabs_user_id ON post_grabs(user_id);
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
-	}
-	return post, err
-}
+				-- Teams share their members' media
+				CREATE TABLE IF NOT EXISTS teams (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					name VARCHAR(255) UNIQUE NOT NULL,
+					description TEXT,
+					created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
 
-func (p *PostgresDB) GetPostByLink(ctx context.Context, link string) (*models.Post, error) {
-	post := &models.Post{}
-	query := `
-		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
-			created_at, updated_at, status, media_count, total_size, error_message
-		FROM posts WHERE telegram_link = $1`
+				CREATE TABLE IF NOT EXISTS team_members (
+					team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					PRIMARY KEY (team_id, user_id)
+				);
 
-	err := p.pool.QueryRow(ctx, query, link).Scan(
-		&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
-		&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
-		&post.ErrorMessage,
-	)
+				CREATE INDEX IF NOT EXISTS idx_team_members_user_id ON team_members(user_id);
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
-	}
-	return post, err
-}
+				CREATE TRIGGER update_teams_updated_at BEFORE UPDATE ON teams
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
-func (p *PostgresDB) UpdatePost(ctx context.Context, post *models.Post) error {
-	query := `
-		UPDATE posts SET 
-			telegram_link = $2, channel_name = $3, original_channel_name = $4, message_id = $5,
-			status = $6, media_count = $7, total_size = $8, error_message = $9
-		WHERE content_id = $1`
+				-- Storage quotas per user or per role, NULL limits are unlimited
+				CREATE TABLE IF NOT EXISTS media_quotas (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+					role_id UUID UNIQUE REFERENCES roles(id) ON DELETE CASCADE,
+					max_bytes BIGINT,
+					max_files INTEGER,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					CONSTRAINT media_quota_subject CHECK ((user_id IS NULL) <> (role_id IS NULL)),
+					CONSTRAINT media_quota_limits CHECK ((max_bytes IS NULL OR max_bytes >= 0) AND (max_files IS NULL OR max_files >= 0))
+				);
 
-	_, err := p.pool.Exec(ctx, query,
-		post.ContentID, post.TelegramLink, post.ChannelName, post.OriginalChannelName, post.MessageID,
-		post.Status, post.MediaCount, post.TotalSize, post.ErrorMessage,
-	)
-	return err
-}
+				CREATE TRIGGER update_media_quotas_updated_at BEFORE UPDATE ON media_quotas
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
-func (p *PostgresDB) ListPosts(ctx context.Context, opts models.PaginationOptions) ([]models.Post, int, error) {
-	// Set defaults
-	if opts.Limit <= 0 {
-		opts.Limit = 20
-	}
-	if opts.Page <= 0 {
-		opts.Page = 1
-	}
-	offset := (opts.Page - 1) * opts.Limit
+				-- Allow super admins to see and manage media of all users
+				UPDATE roles SET permissions = array_append(permissions, 'media:manage_all')
+				WHERE name = 'super_admin' AND NOT ('media:manage_all' = ANY(permissions));
+			`,
+		},
+		{
+			Version:     16,
+			Description: "Add JWT signing key ring",
+			SQL: `
+				DO $$ BEGIN
+					CREATE TYPE jwt_key_status AS ENUM ('active', 'previous', 'retired');
+				EXCEPTION
+					WHEN duplicate_object THEN null;
+				END $$;
 
-	// Count total
-	var total int
-	countQuery := `SELECT COUNT(*) FROM posts`
-	if err := p.pool.QueryRow(ctx, countQuery).Scan(&total); err != nil {
-		return nil, 0, err
-	}
+				-- Asymmetric keys used to sign JWTs, identified by kid
+				CREATE TABLE IF NOT EXISTS jwt_signing_keys (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					kid VARCHAR(64) UNIQUE NOT NULL,
+					algorithm VARCHAR(16) NOT NULL,
+					private_key TEXT NOT NULL,
+					public_key TEXT NOT NULL,
+					status jwt_key_status NOT NULL DEFAULT 'active',
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					rotated_at TIMESTAMP WITH TIME ZONE,
+					retired_at TIMESTAMP WITH TIME ZONE
+				);
 
-	// Get posts
-	query := `
-		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
-			created_at, updated_at, status, media_count, total_size, error_message
-		FROM posts 
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2`
+				-- Only one key may sign new tokens at a time
+				CREATE UNIQUE INDEX IF NOT EXISTS idx_jwt_signing_keys_single_active ON jwt_signing_keys(status) WHERE status = 'active';
+				CREATE INDEX IF NOT EXISTS idx_jwt_signing_keys_status ON jwt_signing_keys(status);
+			`,
+		},
+		{
+			Version:     17,
+			Description: "Add TOTP two-factor authentication",
+			SQL: `
+				-- TOTP enrollment per user, enabled once the first code is confirmed
+				CREATE TABLE IF NOT EXISTS user_mfa (
+					user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+					totp_secret VARCHAR(64) NOT NULL,
+					enabled BOOLEAN NOT NULL DEFAULT false,
+					last_used_step BIGINT,
+					confirmed_at TIMESTAMP WITH TIME ZONE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
 
-	rows, err := p.pool.Query(ctx, query, opts.Limit, offset)
-	if err != nil {
-		return nil, 0, err
+				-- One-time recovery codes, stored as bcrypt hashes
+				CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					code_hash VARCHAR(255) NOT NULL,
+					used_at TIMESTAMP WITH TIME ZONE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
+
+				-- Second login step issued after a correct password
+				CREATE TABLE IF NOT EXISTS mfa_challenges (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					token_hash VARCHAR(64) UNIQUE NOT NULL,
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					attempts INTEGER NOT NULL DEFAULT 0,
+					expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					consumed_at TIMESTAMP WITH TIME ZONE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
+
+				-- Roles can require their members to use two-factor authentication
+				ALTER TABLE roles ADD COLUMN IF NOT EXISTS require_mfa BOOLEAN NOT NULL DEFAULT false;
+
+				CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+				CREATE INDEX IF NOT EXISTS idx_mfa_challenges_expires_at ON mfa_challenges(expires_at);
+
+				CREATE TRIGGER update_user_mfa_updated_at BEFORE UPDATE ON user_mfa
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+			`,
+		},
+		{
+			Version:     18,
+			Description: "Add login failure tracking and temporary account lockout",
+			SQL: `
+				-- Failed password logins per account (normalized email) and per client IP
+				DO $$ BEGIN
+					CREATE TYPE login_throttle_scope AS ENUM ('account', 'ip');
+				EXCEPTION
+					WHEN duplicate_object THEN null;
+				END $$;
+
+				CREATE TABLE IF NOT EXISTS login_throttles (
+					scope login_throttle_scope NOT NULL,
+					subject VARCHAR(255) NOT NULL,
+					failures INTEGER NOT NULL DEFAULT 0,
+					lockouts INTEGER NOT NULL DEFAULT 0,
+					first_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+					last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+					locked_until TIMESTAMP WITH TIME ZONE,
+					PRIMARY KEY (scope, subject)
+				);
+
+				-- Temporary lockout of an account, expires on its own
+				ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE;
+
+				CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failed_at ON login_throttles(last_failed_at);
+			`,
+		},
+		{
+			Version:     19,
+			Description: "Add password reset and email verification tokens",
+			SQL: `
+				DO $$ BEGIN
+					CREATE TYPE user_token_purpose AS ENUM ('password_reset', 'email_verification');
+				EXCEPTION
+					WHEN duplicate_object THEN null;
+				END $$;
+
+				-- Single-use tokens sent by email, stored as SHA-256 hashes
+				CREATE TABLE IF NOT EXISTS user_tokens (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					purpose user_token_purpose NOT NULL,
+					token_hash VARCHAR(64) UNIQUE NOT NULL,
+					email VARCHAR(255) NOT NULL,
+					expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					used_at TIMESTAMP WITH TIME ZONE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
+
+				ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
+
+				CREATE INDEX IF NOT EXISTS idx_user_tokens_user_purpose ON user_tokens(user_id, purpose);
+				CREATE INDEX IF NOT EXISTS idx_user_tokens_expires_at ON user_tokens(expires_at);
+			`,
+		},
+		{
+			Version:     20,
+			Description: "Add linked OpenID Connect identities",
+			SQL: `
+				-- One row per external account; a user can link several providers
+				CREATE TABLE IF NOT EXISTS user_identities (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					provider VARCHAR(50) NOT NULL,
+					subject VARCHAR(255) NOT NULL,
+					email VARCHAR(255),
+					-- Roles assigned through the provider's role claim, revoked when the claim no longer maps to them
+					granted_role_ids UUID[] NOT NULL DEFAULT '{}',
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+					last_login_at TIMESTAMP WITH TIME ZONE,
+					UNIQUE(provider, subject)
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+				INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+				SELECT id, oidc_provider, oidc_subject, email, last_login_at
+				FROM users
+				WHERE oidc_provider IS NOT NULL AND oidc_subject IS NOT NULL
+				ON CONFLICT (provider, subject) DO NOTHING;
+
+				-- Authorization requests remember the provider, nonce and PKCE verifier
+				ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
+				ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS nonce VARCHAR(255);
+				ALTER TABLE oauth_states ADD COLUMN IF NOT EXISTS code_verifier VARCHAR(255);
+			`,
+		},
+		{
+			Version:     21,
+			Description: "Add personal API tokens",
+			SQL: `
+				-- Long-lived bearer tokens owned by a user, stored as SHA-256 hashes
+				CREATE TABLE IF NOT EXISTS api_tokens (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					name VARCHAR(100) NOT NULL,
+					token_hash VARCHAR(64) UNIQUE NOT NULL,
+					token_prefix VARCHAR(16) NOT NULL,
+					scopes TEXT[] NOT NULL DEFAULT '{}',
+					expires_at TIMESTAMP WITH TIME ZONE,
+					last_used_at TIMESTAMP WITH TIME ZONE,
+					last_used_ip VARCHAR(45),
+					revoked_at TIMESTAMP WITH TIME ZONE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+				CREATE UNIQUE INDEX IF NOT EXISTS idx_api_tokens_user_name ON api_tokens(user_id, LOWER(name)) WHERE revoked_at IS NULL;
+			`,
+		},
+		{
+			Version:     22,
+			Description: "Store refresh tokens hashed and track the access tokens issued to each session",
+			SQL: `
+				ALTER TABLE sessions RENAME COLUMN refresh_token TO refresh_token_hash;
+				UPDATE sessions SET refresh_token_hash = encode(sha256(convert_to(refresh_token_hash, 'UTF8')), 'hex');
+				DROP INDEX IF EXISTS idx_sessions_refresh_token;
+
+				-- Access tokens of a session, blacklisted together when a rotated refresh token is replayed
+				CREATE TABLE IF NOT EXISTS session_access_tokens (
+					token_jti VARCHAR(255) PRIMARY KEY,
+					session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_session_access_tokens_session ON session_access_tokens(session_id);
+				CREATE INDEX IF NOT EXISTS idx_session_access_tokens_expires_at ON session_access_tokens(expires_at);
+
+				CREATE OR REPLACE FUNCTION cleanup_expired_auth_data()
+				RETURNS void AS $$
+				BEGIN
+					DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM session_access_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM token_blacklist WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM oauth_states WHERE expires_at < CURRENT_TIMESTAMP;
+				END;
+				$$ LANGUAGE plpgsql;
+			`,
+		},
+		{
+			Version:     23,
+			Description: "Add append-only audit log written by triggers on audited tables",
+			SQL: `
+				CREATE TABLE IF NOT EXISTS audit_log (
+					id BIGSERIAL PRIMARY KEY,
+					occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					-- No foreign keys, entries outlive the users and sessions they mention
+					actor_user_id UUID,
+					actor_session_id UUID,
+					actor_api_token_id UUID,
+					auth_method VARCHAR(20),
+					correlation_id VARCHAR(100),
+					request_id VARCHAR(100),
+					ip_address VARCHAR(45),
+					entity_type VARCHAR(50) NOT NULL,
+					entity_id TEXT NOT NULL,
+					action VARCHAR(20) NOT NULL,
+					before_data JSONB,
+					after_data JSONB
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, occurred_at DESC);
+				CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_user_id, occurred_at DESC);
+				CREATE INDEX IF NOT EXISTS idx_audit_log_correlation ON audit_log(correlation_id);
+				CREATE INDEX IF NOT EXISTS idx_audit_log_occurred_at ON audit_log(occurred_at);
+
+				-- Entries can't be changed, and only the retention purge may delete them
+				CREATE OR REPLACE FUNCTION audit_log_append_only()
+				RETURNS trigger AS $$
+				BEGIN
+					IF TG_OP = 'DELETE' AND current_setting('stplaner.audit_purge', true) = 'on' THEN
+						RETURN OLD;
+					END IF;
+					RAISE EXCEPTION 'audit_log is append-only';
+				END;
+				$$ LANGUAGE plpgsql;
+
+				DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+				CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+					FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+				-- Records a row change. Arguments: entity type, column holding the entity ID, then
+				-- columns holding secrets, which are only recorded as changed. The acting user comes
+				-- from the stplaner.audit_actor setting the application sets per request.
+				CREATE OR REPLACE FUNCTION audit_row_change()
+				RETURNS trigger AS $$
+				DECLARE
+					old_row JSONB;
+					new_row JSONB;
+					before_diff JSONB := '{}'::jsonb;
+					after_diff JSONB := '{}'::jsonb;
+					actor JSONB;
+					col TEXT;
+					ignored TEXT[] := ARRAY['updated_at', 'last_login_at', 'last_activity', 'search_vector'];
+				BEGIN
+					IF TG_OP <> 'INSERT' THEN
+						old_row := to_jsonb(OLD) - ignored;
+					END IF;
+					IF TG_OP <> 'DELETE' THEN
+						new_row := to_jsonb(NEW) - ignored;
+					END IF;
+
+					IF TG_OP = 'UPDATE' THEN
+						FOR col IN SELECT jsonb_object_keys(new_row) LOOP
+							IF old_row->col IS DISTINCT FROM new_row->col THEN
+								before_diff := before_diff || jsonb_build_object(col, old_row->col);
+								after_diff := after_diff || jsonb_build_object(col, new_row->col);
+							END IF;
+						END LOOP;
+						IF after_diff = '{}'::jsonb THEN
+							RETURN NULL;
+						END IF;
+						old_row := before_diff;
+						new_row := after_diff;
+					END IF;
+
+					FOREACH col IN ARRAY TG_ARGV[2:] LOOP
+						IF old_row ? col AND old_row->col <> 'null'::jsonb THEN
+							old_row := jsonb_set(old_row, ARRAY[col], '"[redacted]"');
+						END IF;
+						IF new_row ? col AND new_row->col <> 'null'::jsonb THEN
+							new_row := jsonb_set(new_row, ARRAY[col], '"[redacted]"');
+						END IF;
+					END LOOP;
+
+					actor := NULLIF(current_setting('stplaner.audit_actor', true), '')::jsonb;
+
+					INSERT INTO audit_log (actor_user_id, actor_session_id, actor_api_token_id, auth_method,
+						correlation_id, request_id, ip_address, entity_type, entity_id, action, before_data, after_data)
+					VALUES ((actor->>'user_id')::uuid, (actor->>'session_id')::uuid, (actor->>'api_token_id')::uuid,
+						actor->>'auth_method', actor->>'correlation_id', actor->>'request_id', actor->>'ip_address',
+						TG_ARGV[0], COALESCE(to_jsonb(NEW), to_jsonb(OLD))->>TG_ARGV[1],
+						CASE TG_OP WHEN 'INSERT' THEN 'create' WHEN 'UPDATE' THEN 'update' ELSE 'delete' END,
+						old_row, new_row);
+
+					RETURN NULL;
+				END;
+				$$ LANGUAGE plpgsql;
+
+				DROP TRIGGER IF EXISTS audit_shows ON shows;
+				CREATE TRIGGER audit_shows AFTER INSERT OR UPDATE OR DELETE ON shows
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('show', 'id', 'youtube_key', 'additional_key', 'zoom_passcode');
+
+				DROP TRIGGER IF EXISTS audit_events ON events;
+				CREATE TRIGGER audit_events AFTER INSERT OR UPDATE OR DELETE ON events
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('event', 'id', 'youtube_key', 'additional_key', 'zoom_passcode');
+
+				DROP TRIGGER IF EXISTS audit_blocks ON blocks;
+				CREATE TRIGGER audit_blocks AFTER INSERT OR UPDATE OR DELETE ON blocks
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('block', 'id');
+
+				DROP TRIGGER IF EXISTS audit_block_guests ON block_guests;
+				CREATE TRIGGER audit_block_guests AFTER INSERT OR DELETE ON block_guests
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('block_guest', 'block_id');
+
+				DROP TRIGGER IF EXISTS audit_guests ON guests;
+				CREATE TRIGGER audit_guests AFTER INSERT OR UPDATE OR DELETE ON guests
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('guest', 'id');
+
+				DROP TRIGGER IF EXISTS audit_users ON users;
+				CREATE TRIGGER audit_users AFTER INSERT OR UPDATE OR DELETE ON users
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('user', 'id', 'password_hash');
+
+				DROP TRIGGER IF EXISTS audit_user_roles ON user_roles;
+				CREATE TRIGGER audit_user_roles AFTER INSERT OR DELETE ON user_roles
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('user_role', 'user_id');
+
+				DROP TRIGGER IF EXISTS audit_roles ON roles;
+				CREATE TRIGGER audit_roles AFTER INSERT OR UPDATE OR DELETE ON roles
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('role', 'id');
+
+				DROP TRIGGER IF EXISTS audit_media ON media;
+				CREATE TRIGGER audit_media AFTER INSERT OR UPDATE OR DELETE ON media
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('media', 'id');
+
+				UPDATE roles SET permissions = array_append(permissions, 'audit:read')
+				WHERE name = 'super_admin' AND NOT ('audit:read' = ANY(permissions));
+			`,
+		},
+		{
+			Version:     24,
+			Description: "Widen show and event secret columns for encrypted values",
+			SQL: `
+				-- Encrypted values are much longer than the plain text. Existing rows are
+				-- encrypted by the application at startup, it holds the key.
+				ALTER TABLE shows
+					ALTER COLUMN youtube_key TYPE TEXT,
+					ALTER COLUMN additional_key TYPE TEXT,
+					ALTER COLUMN zoom_passcode TYPE TEXT;
+
+				ALTER TABLE events
+					ALTER COLUMN youtube_key TYPE TEXT,
+					ALTER COLUMN additional_key TYPE TEXT,
+					ALTER COLUMN zoom_passcode TYPE TEXT;
+
+				UPDATE roles SET permissions = array_append(permissions, 'secrets:reveal')
+				WHERE name IN ('super_admin', 'admin') AND NOT ('secrets:reveal' = ANY(permissions));
+			`,
+		},
+		{
+			Version:     25,
+			Description: "Add session locations, known login origins and login alerts",
+			SQL: `
+				ALTER TABLE sessions ADD COLUMN IF NOT EXISTS location VARCHAR(100);
+
+				-- Devices and countries each user has logged in from
+				CREATE TABLE IF NOT EXISTS user_login_origins (
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					kind VARCHAR(20) NOT NULL,
+					value VARCHAR(255) NOT NULL,
+					first_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (user_id, kind, value),
+					CONSTRAINT user_login_origins_kind_check CHECK (kind IN ('device', 'country'))
+				);
+
+				CREATE TABLE IF NOT EXISTS login_alerts (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+					-- No foreign key, the alert outlives the session it was raised for
+					session_id UUID,
+					reasons TEXT[] NOT NULL,
+					device_name VARCHAR(255),
+					device_type VARCHAR(50),
+					ip_address VARCHAR(45),
+					location VARCHAR(100),
+					created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+					acknowledged_at TIMESTAMP WITH TIME ZONE
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_login_alerts_user_created ON login_alerts(user_id, created_at DESC);
+
+				CREATE OR REPLACE FUNCTION cleanup_expired_auth_data()
+				RETURNS void AS $$
+				BEGIN
+					DELETE FROM sessions WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM session_access_tokens WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM token_blacklist WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM oauth_states WHERE expires_at < CURRENT_TIMESTAMP;
+					DELETE FROM login_alerts WHERE created_at < CURRENT_TIMESTAMP - INTERVAL '90 days';
+				END;
+				$$ LANGUAGE plpgsql;
+			`,
+		},
+		{
+			Version:     26,
+			Description: "Index block guests for guest appearance history",
+			SQL: `
+				-- Covers the guest -> block lookups behind guest stats and sorting guests by last appearance
+				CREATE INDEX IF NOT EXISTS idx_block_guests_guest_block ON block_guests(guest_id, block_id);
+				CREATE INDEX IF NOT EXISTS idx_blocks_id_event ON blocks(id, event_id);
+			`,
+		},
+		{
+			Version:     27,
+			Description: "Track guest invitations and RSVP responses per block",
+			SQL: `
+				DO $$ BEGIN
+					CREATE TYPE invitation_status AS ENUM (
+						'invited', 'confirmed', 'declined', 'tentative', 'no_show'
+					);
+				EXCEPTION
+					WHEN duplicate_object THEN null;
+				END $$;
+
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS invitation_status invitation_status NOT NULL DEFAULT 'invited';
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS invited_at TIMESTAMP WITH TIME ZONE;
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS responded_at TIMESTAMP WITH TIME ZONE;
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMP WITH TIME ZONE;
+
+				-- Invitations still waiting for a reminder
+				CREATE INDEX IF NOT EXISTS idx_block_guests_reminder_pending ON block_guests(block_id)
+					WHERE invited_at IS NOT NULL AND reminder_sent_at IS NULL;
+
+				-- RSVP responses are audited along with bookings
+				DROP TRIGGER IF EXISTS audit_block_guests ON block_guests;
+				CREATE TRIGGER audit_block_guests AFTER INSERT OR UPDATE OF invitation_status OR DELETE ON block_guests
+					FOR EACH ROW EXECUTE FUNCTION audit_row_change('block_guest', 'block_id');
+			`,
+		},
+		{
+			Version:     28,
+			Description: "Record guest merges so they can be undone",
+			SQL: `
+				CREATE TABLE IF NOT EXISTS guest_merges (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL,
+					survivor_id UUID NOT NULL,
+					duplicate_ids UUID[] NOT NULL,
+					-- Snapshots for undo: the survivor before the merge, the deleted duplicates,
+					-- the block_guests rows moved to the survivor and the ones dropped because
+					-- the survivor was already on that block
+					survivor_before JSONB NOT NULL,
+					duplicates JSONB NOT NULL,
+					moved_block_guests JSONB NOT NULL DEFAULT '[]'::jsonb,
+					removed_block_guests JSONB NOT NULL DEFAULT '[]'::jsonb,
+					survivor_updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					undone_at TIMESTAMP WITH TIME ZONE
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_guest_merges_user_id ON guest_merges(user_id, created_at DESC);
+				CREATE INDEX IF NOT EXISTS idx_guest_merges_survivor_id ON guest_merges(survivor_id);
+			`,
+		},
+		{
+			Version:     29,
+			Description: "Add guest consent records and personal data erasure",
+			SQL: `
+				-- Consent is append-only history: a withdrawal is a new record with granted false,
+				-- and the latest record of a purpose is the current state
+				CREATE TABLE IF NOT EXISTS guest_consents (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					guest_id UUID NOT NULL REFERENCES guests(id) ON DELETE CASCADE,
+					purpose VARCHAR(50) NOT NULL,
+					granted BOOLEAN NOT NULL,
+					method VARCHAR(50) NOT NULL,
+					evidence TEXT,
+					obtained_at TIMESTAMP WITH TIME ZONE NOT NULL,
+					expires_at TIMESTAMP WITH TIME ZONE,
+					recorded_by UUID,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_guest_consents_guest_id ON guest_consents(guest_id, purpose, obtained_at DESC);
+
+				ALTER TABLE guests ADD COLUMN IF NOT EXISTS erased_at TIMESTAMP WITH TIME ZONE;
+
+				-- Erasing a guest redacts its audit entries, the only updates the log allows
+				CREATE OR REPLACE FUNCTION audit_log_append_only()
+				RETURNS trigger AS $$
+				BEGIN
+					IF TG_OP = 'DELETE' AND current_setting('stplaner.audit_purge', true) = 'on' THEN
+						RETURN OLD;
+					END IF;
+					IF TG_OP = 'UPDATE' AND current_setting('stplaner.audit_erase', true) = 'on' THEN
+						RETURN NEW;
+					END IF;
+					RAISE EXCEPTION 'audit_log is append-only';
+				END;
+				$$ LANGUAGE plpgsql;
+			`,
+		},
+		{
+			Version:     30,
+			Description: "Create guest availability table",
+			SQL: `
+				CREATE TABLE IF NOT EXISTS guest_availability (
+					guest_id UUID PRIMARY KEY REFERENCES guests(id) ON DELETE CASCADE,
+					timezone VARCHAR(64) NOT NULL,
+					windows JSONB NOT NULL DEFAULT '[]'::jsonb,
+					blackouts JSONB NOT NULL DEFAULT '[]'::jsonb,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			Version:     31,
+			Description: "Add guest tag vocabulary and saved guest segments",
+			SQL: `
+				-- Guests keep carrying tags by name; the vocabulary describes them
+				CREATE TABLE IF NOT EXISTS guest_tags (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL,
+					name VARCHAR(100) NOT NULL,
+					color VARCHAR(7),
+					parent_id UUID REFERENCES guest_tags(id) ON DELETE SET NULL,
+					description TEXT,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					CONSTRAINT valid_tag_name CHECK (LENGTH(TRIM(name)) > 0)
+				);
+
+				CREATE UNIQUE INDEX IF NOT EXISTS idx_guest_tags_user_name ON guest_tags(user_id, LOWER(name));
+				CREATE INDEX IF NOT EXISTS idx_guest_tags_parent_id ON guest_tags(parent_id) WHERE parent_id IS NOT NULL;
+
+				DROP TRIGGER IF EXISTS update_guest_tags_updated_at ON guest_tags;
+				CREATE TRIGGER update_guest_tags_updated_at BEFORE UPDATE ON guest_tags
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+				-- Start the vocabulary with the tags already in use
+				INSERT INTO guest_tags (user_id, name)
+				SELECT DISTINCT ON (g.user_id, LOWER(TRIM(t.name))) g.user_id, TRIM(t.name)
+				FROM guests g
+				CROSS JOIN LATERAL jsonb_array_elements_text(g.tags) AS t(name)
+				WHERE jsonb_typeof(g.tags) = 'array' AND TRIM(t.name) <> '' AND LENGTH(TRIM(t.name)) <= 100
+				ORDER BY g.user_id, LOWER(TRIM(t.name)), g.created_at
+				ON CONFLICT DO NOTHING;
+
+				CREATE TABLE IF NOT EXISTS guest_segments (
+					id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+					user_id UUID NOT NULL,
+					name VARCHAR(255) NOT NULL,
+					description TEXT,
+					filters JSONB NOT NULL DEFAULT '{}'::jsonb,
+					shared BOOLEAN NOT NULL DEFAULT FALSE,
+					created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					CONSTRAINT valid_segment_name CHECK (LENGTH(TRIM(name)) > 0),
+					CONSTRAINT unique_user_segment UNIQUE(user_id, name)
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_guest_segments_shared ON guest_segments(user_id) WHERE shared;
+
+				DROP TRIGGER IF EXISTS update_guest_segments_updated_at ON guest_segments;
+				CREATE TRIGGER update_guest_segments_updated_at BEFORE UPDATE ON guest_segments
+					FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+			`,
+		},
+		{
+			Version:     32,
+			Description: "Create show rundown templates table",
+			SQL: `
+				CREATE TABLE IF NOT EXISTS show_rundown_templates (
+					show_id UUID PRIMARY KEY REFERENCES shows(id) ON DELETE CASCADE,
+					blocks JSONB NOT NULL DEFAULT '[]'::jsonb,
+					auto_apply BOOLEAN NOT NULL DEFAULT TRUE,
+					updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			Version:     33,
+			Description: "Track data migrations run by the application",
+			SQL: `
+				-- Steps that need the application, such as encrypting existing secrets with
+				-- its key, are recorded here once they completed
+				CREATE TABLE IF NOT EXISTS data_migrations (
+					name VARCHAR(100) PRIMARY KEY,
+					completed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			Version:     34,
+			Description: "Keep consents and availability of merged guests for undo",
+			SQL: `
+				-- Consents moved from the duplicates to the survivor, the duplicates'
+				-- availability and the duplicate whose availability the survivor took over
+				ALTER TABLE guest_merges ADD COLUMN IF NOT EXISTS moved_consents JSONB NOT NULL DEFAULT '[]'::jsonb;
+				ALTER TABLE guest_merges ADD COLUMN IF NOT EXISTS duplicate_availability JSONB NOT NULL DEFAULT '[]'::jsonb;
+				ALTER TABLE guest_merges ADD COLUMN IF NOT EXISTS adopted_availability_from UUID;
+			`,
+		},
+		{
+			Version:     35,
+			Description: "Count share link downloads per client session",
+			SQL: `
+				-- A download is counted when a client starts a session for a file. Requests
+				-- of the same session, such as range requests while seeking, aren't counted again.
+				CREATE TABLE IF NOT EXISTS share_link_download_sessions (
+					share_link_id UUID NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+					session_key VARCHAR(64) NOT NULL,
+					started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (share_link_id, session_key)
+				);
+			`,
+		},
+		{
+			Version:     36,
+			Description: "Back off guest reminders that failed to send",
+			SQL: `
+				-- Failed reminders are retried after a delay and given up after a few attempts,
+				-- so undeliverable ones don't hold back the reminders after them
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS reminder_attempts INTEGER NOT NULL DEFAULT 0;
+				ALTER TABLE block_guests ADD COLUMN IF NOT EXISTS reminder_failed_at TIMESTAMP WITH TIME ZONE;
+			`,
+		},
+	}
+
+	// Run each migration if not already applied
+	for _, migration := range migrations {
+		if err := p.runMigration(ctx, migration); err != nil {
+			return fmt.Errorf("failed to run migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// createMigrationsTable creates the migrations tracking table
+func (p *PostgresDB) createMigrationsTable(ctx context.Context) error {
+	createTableSQL := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			description VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+		);
+	`
+
+	_, err := p.pool.Exec(ctx, createTableSQL)
+	return err
+}
+
+// runMigration executes a single migration if it hasn't been applied yet
+func (p *PostgresDB) runMigration(ctx context.Context, migration Migration) error {
+	// Check if migration has already been applied
+	var exists bool
+	checkSQL := "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)"
+	err := p.pool.QueryRow(ctx, checkSQL, migration.Version).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check migration status: %w", err)
+	}
+
+	if exists {
+		// Migration already applied, skip
+		utils.LogInfo(ctx, "Migration already applied", utils.Fields{
+			"version":     migration.Version,
+			"description": migration.Description,
+		})
+		return nil
+	}
+
+	// Start transaction for migration
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin migration transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Execute migration SQL
+	utils.LogInfo(ctx, "Applying migration", utils.Fields{
+		"version":     migration.Version,
+		"description": migration.Description,
+	})
+
+	_, err = tx.Exec(ctx, migration.SQL)
+	if err != nil {
+		return fmt.Errorf("failed to execute migration SQL: %w", err)
+	}
+
+	// Record migration as applied
+	recordSQL := `
+		INSERT INTO schema_migrations (version, description) 
+		VALUES ($1, $2)
+	`
+	_, err = tx.Exec(ctx, recordSQL, migration.Version, migration.Description)
+	if err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	// Commit transaction
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit migration transaction: %w", err)
+	}
+
+	utils.LogInfo(ctx, "Migration applied successfully", utils.Fields{
+		"version":     migration.Version,
+		"description": migration.Description,
+	})
+
+	return nil
+}
+
+// Post operations
+func (p *PostgresDB) CreatePost(ctx context.Context, post *models.Post) error {
+	post.ID = uuid.New()
+	post.CreatedAt = time.Now()
+	post.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO posts (id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
+			created_at, updated_at, status, media_count, total_size, error_message, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+
+	err := p.pool.QueryRow(ctx, query,
+		post.ID, post.ContentID, post.TelegramLink, post.ChannelName, post.OriginalChannelName, post.MessageID,
+		post.CreatedAt, post.UpdatedAt, post.Status, post.MediaCount, post.TotalSize,
+		post.ErrorMessage, post.UserID,
+	).Scan(&post.ID, &post.CreatedAt, &post.UpdatedAt)
+
+	return err
+}
+
+func (p *PostgresDB) GetPostByContentID(ctx context.Context, contentID string) (*models.Post, error) {
+	post := &models.Post{}
+	query := `
+		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
+			created_at, updated_at, status, media_count, total_size, error_message, user_id
+		FROM posts WHERE content_id = $1`
+
+	err := p.pool.QueryRow(ctx, query, contentID).Scan(
+		&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
+		&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
+		&post.ErrorMessage, &post.UserID,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return post, err
+}
+
+func (p *PostgresDB) GetPostByLink(ctx context.Context, link string) (*models.Post, error) {
+	post := &models.Post{}
+	query := `
+		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
+			created_at, updated_at, status, media_count, total_size, error_message, user_id
+		FROM posts WHERE telegram_link = $1`
+
+	err := p.pool.QueryRow(ctx, query, link).Scan(
+		&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
+		&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
+		&post.ErrorMessage, &post.UserID,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	return post, err
+}
+
+func (p *PostgresDB) UpdatePost(ctx context.Context, post *models.Post) error {
+	query := `
+		UPDATE posts SET 
+			telegram_link = $2, channel_name = $3, original_channel_name = $4, message_id = $5,
+			status = $6, media_count = $7, total_size = $8, error_message = $9
+		WHERE content_id = $1`
+
+	_, err := p.pool.Exec(ctx, query,
+		post.ContentID, post.TelegramLink, post.ChannelName, post.OriginalChannelName, post.MessageID,
+		post.Status, post.MediaCount, post.TotalSize, post.ErrorMessage,
+	)
+	return err
+}
+
+func (p *PostgresDB) ListPosts(ctx context.Context, scope models.MediaScope, opts models.PaginationOptions) ([]models.Post, int, error) {
+	// Set defaults
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+	offset := (opts.Page - 1) * opts.Limit
+
+	// Restrict to posts visible to the user
+	whereClause := ""
+	args := []interface{}{}
+	if !scope.All {
+		whereClause = "WHERE " + postVisibleCondition("posts", 1)
+		args = append(args, scope.UserID)
+	}
+
+	// Count total
+	var total int
+	countQuery := `SELECT COUNT(*) FROM posts ` + whereClause
+	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Get posts
+	query := fmt.Sprintf(`
+		SELECT id, content_id, telegram_link, channel_name, original_channel_name, message_id, 
+			created_at, updated_at, status, media_count, total_size, error_message, user_id
+		FROM posts 
+		%s
+		ORDER BY created_at DESC
+		LIMIT $%d OFFSET $%d`, whereClause, len(args)+1, len(args)+2)
+
+	args = append(args, opts.Limit, offset)
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var posts []models.Post
+	for rows.Next() {
+		var post models.Post
+		err := rows.Scan(
+			&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
+			&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
+			&post.ErrorMessage, &post.UserID,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		posts = append(posts, post)
+	}
+
+	return posts, total, nil
+}
+
+// Media operations
+func (p *PostgresDB) CreateMedia(ctx context.Context, media *models.Media) error {
+	media.ID = uuid.New()
+	media.DownloadedAt = time.Now()
+
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(media.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	if media.Tags == nil {
+		media.Tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(media.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	query := `
+		INSERT INTO media (id, media_id, content_id, telegram_file_id, file_name, original_file_name,
+			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata,
+			tags, notes, duration_seconds, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		RETURNING id, downloaded_at`
+
+	err = p.pool.QueryRow(ctx, query,
+		media.ID, media.MediaID, media.ContentID, media.TelegramFileID, media.FileName, media.OriginalFileName,
+		media.FileType, media.FileSize, media.S3Bucket, media.S3Key, media.FileHash,
+		media.DownloadedAt, metadataJSON, tagsJSON, media.Notes, media.DurationSeconds, media.UserID,
+	).Scan(&media.ID, &media.DownloadedAt)
+
+	return err
+}
+
+func (p *PostgresDB) GetMediaByID(ctx context.Context, mediaID string) (*models.Media, error) {
+	media := &models.Media{}
+	var metadataJSON, tagsJSON []byte
+
+	query := `
+		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
+			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata,
+			tags, notes, duration_seconds, user_id
+		FROM media WHERE media_id = $1`
+
+	err := p.pool.QueryRow(ctx, query, mediaID).Scan(
+		&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
+		&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
+		&media.DownloadedAt, &metadataJSON, &tagsJSON, &media.Notes, &media.DurationSeconds, &media.UserID,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal metadata if present
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	// Unmarshal tags if present
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &media.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+	}
+
+	return media, nil
+}
+
+func (p *PostgresDB) GetMediaByContentID(ctx context.Context, contentID string) ([]models.Media, error) {
+	query := `
+		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
+			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata,
+			tags, notes, duration_seconds, user_id
+		FROM media WHERE content_id = $1
+		ORDER BY downloaded_at ASC`
+
+	rows, err := p.pool.Query(ctx, query, contentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mediaList []models.Media
+	for rows.Next() {
+		var media models.Media
+		var metadataJSON, tagsJSON []byte
+
+		err := rows.Scan(
+			&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
+			&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
+			&media.DownloadedAt, &metadataJSON, &tagsJSON, &media.Notes, &media.DurationSeconds, &media.UserID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Unmarshal metadata if present
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		// Unmarshal tags if present
+		if len(tagsJSON) > 0 {
+			if err := json.Unmarshal(tagsJSON, &media.Tags); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+			}
+		}
+
+		mediaList = append(mediaList, media)
+	}
+
+	return mediaList, nil
+}
+
+func (p *PostgresDB) GetMediaByHash(ctx context.Context, hash string) (*models.Media, error) {
+	media := &models.Media{}
+	var metadataJSON, tagsJSON []byte
+
+	query := `
+		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
+			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata,
+			tags, notes, duration_seconds, user_id
+		FROM media WHERE file_hash = $1
+		LIMIT 1`
+
+	err := p.pool.QueryRow(ctx, query, hash).Scan(
+		&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
+		&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
+		&media.DownloadedAt, &metadataJSON, &tagsJSON, &media.Notes, &media.DurationSeconds, &media.UserID,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal metadata if present
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	// Unmarshal tags if present
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &media.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+	}
+
+	return media, nil
+}
+
+func (p *PostgresDB) UpdateMedia(ctx context.Context, media *models.Media) error {
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(media.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	if media.Tags == nil {
+		media.Tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(media.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	query := `
+		UPDATE media SET 
+			file_name = $2, original_file_name = $3, metadata = $4,
+			tags = $5, notes = $6, duration_seconds = $7
+		WHERE media_id = $1`
+
+	_, err = p.pool.Exec(ctx, query,
+		media.MediaID, media.FileName, media.OriginalFileName, metadataJSON,
+		tagsJSON, media.Notes, media.DurationSeconds,
+	)
+	return err
+}
+
+func (p *PostgresDB) DeleteMedia(ctx context.Context, mediaID string) error {
+	query := `DELETE FROM media WHERE media_id = $1`
+
+	result, err := p.pool.Exec(ctx, query, mediaID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("no media found with ID: %s", mediaID)
+	}
+
+	return nil
+}
+
+// GetMigrationStatus returns the current migration status
+func (p *PostgresDB) GetMigrationStatus(ctx context.Context) ([]Migration, error) {
+	// First check if migrations table exists
+	var tableExists bool
+	checkTableSQL := `
+		SELECT EXISTS (
+			SELECT FROM information_schema.tables 
+			WHERE table_name = 'schema_migrations'
+		)
+	`
+	err := p.pool.QueryRow(ctx, checkTableSQL).Scan(&tableExists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+
+	if !tableExists {
+		return []Migration{}, nil
+	}
+
+	// Get applied migrations
+	query := `
+		SELECT version, description, applied_at 
+		FROM schema_migrations 
+		ORDER BY version
+	`
+	rows, err := p.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var migrations []Migration
+	for rows.Next() {
+		var migration Migration
+		var appliedAt time.Time
+		err := rows.Scan(&migration.Version, &migration.Description, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan migration row: %w", err)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, nil
+}
+
+// Transaction support
+func (p *PostgresDB) WithTransaction(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Health check
+func (p *PostgresDB) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return p.pool.Ping(ctx)
+}
+
+// Close connection
+func (p *PostgresDB) Close() {
+	p.pool.Close()
+	p.db.Close()
+}
+
+// Show operations
+func (p *PostgresDB) CreateShow(ctx context.Context, show *models.Show) error {
+	show.ID = uuid.New()
+	show.CreatedAt = time.Now()
+	show.UpdatedAt = time.Now()
+
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(show.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Convert scheduling config to JSON
+	var schedulingConfigJSON []byte
+	if show.SchedulingConfig != nil {
+		schedulingConfigJSON, err = json.Marshal(show.SchedulingConfig)
+		if err != nil {
+			return fmt.Errorf("failed to marshal scheduling config: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO shows (id, show_name, youtube_key, additional_key, zoom_meeting_url, 
+			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
+			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
+			default_telegram, created_at, updated_at, status, user_id, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING id, created_at, updated_at`
+
+	sealed, err := p.sealSecrets(ctx, &show.YouTubeKey, show.AdditionalKey, show.ZoomPasscode)
+	if err != nil {
+		return err
+	}
+
+	err = p.pool.QueryRow(ctx, query,
+		show.ID, show.ShowName, *sealed.YouTubeKey, sealed.AdditionalKey, show.ZoomMeetingURL,
+		show.ZoomMeetingID, sealed.ZoomPasscode, show.StartTime, show.LengthMinutes, show.FirstEventDate,
+		show.RepeatPattern, schedulingConfigJSON, show.DefaultHost, show.DefaultDirector, show.DefaultProducer,
+		show.DefaultTelegram, show.CreatedAt, show.UpdatedAt, show.Status, show.UserID, metadataJSON,
+	).Scan(&show.ID, &show.CreatedAt, &show.UpdatedAt)
+
+	return err
+}
+
+func (p *PostgresDB) GetShowByID(ctx context.Context, showID uuid.UUID) (*models.Show, error) {
+	show := &models.Show{}
+	var metadataJSON []byte
+	var schedulingConfigJSON []byte
+
+	query := `
+		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
+			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
+			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
+			default_telegram, created_at, updated_at, status, user_id, metadata
+		FROM shows WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, showID).Scan(
+		&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
+		&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
+		&show.RepeatPattern, &schedulingConfigJSON, &show.DefaultHost, &show.DefaultDirector, &show.DefaultProducer,
+		&show.DefaultTelegram, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.openShowSecrets(ctx, show); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal metadata if present
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	// Unmarshal scheduling config if present
+	if len(schedulingConfigJSON) > 0 {
+		show.SchedulingConfig = &models.SchedulingConfig{}
+		if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
+		}
+	}
+
+	return show, nil
+}
+
+func (p *PostgresDB) DeleteShow(ctx context.Context, showID uuid.UUID, force bool) error {
+	var query string
+	var result pgconn.CommandTag
+	var err error
+	
+	if force {
+		// Hard delete (permanent removal)
+		query = `DELETE FROM shows WHERE id = $1`
+		result, err = p.pool.Exec(ctx, query, showID)
+	} else {
+		// Soft delete by updating status
+		query = `UPDATE shows SET status = $2 WHERE id = $1`
+		result, err = p.pool.Exec(ctx, query, showID, models.ShowStatusCancelled)
+	}
+	if err != nil {
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("no show found with ID: %s", showID)
+	}
+
+	return nil
+}
+
+
+func (p *PostgresDB) UpdateShow(ctx context.Context, show *models.Show) error {
+	// Convert metadata to JSON
+	metadataJSON, err := json.Marshal(show.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Convert scheduling config to JSON
+	var schedulingConfigJSON []byte
+	if show.SchedulingConfig != nil {
+		schedulingConfigJSON, err = json.Marshal(show.SchedulingConfig)
+		if err != nil {
+			return fmt.Errorf("failed to marshal scheduling config: %w", err)
+		}
+	}
+
+	query := `
+		UPDATE shows SET 
+			show_name = COALESCE($2, show_name), 
+			youtube_key = COALESCE($3, youtube_key), 
+			additional_key = COALESCE($4, additional_key), 
+			zoom_meeting_url = COALESCE($5, zoom_meeting_url),
+			zoom_meeting_id = COALESCE($6, zoom_meeting_id), 
+			zoom_passcode = COALESCE($7, zoom_passcode), 
+			start_time = COALESCE($8, start_time), 
+			length_minutes = COALESCE($9, length_minutes),
+			first_event_date = COALESCE($10, first_event_date), 
+			repeat_pattern = COALESCE($11, repeat_pattern), 
+			scheduling_config = COALESCE($12, scheduling_config),
+			default_host = COALESCE($13, default_host),
+			default_director = COALESCE($14, default_director),
+			default_producer = COALESCE($15, default_producer),
+			default_telegram = COALESCE($16, default_telegram),
+			status = COALESCE($17, status), 
+			metadata = COALESCE($18, metadata),
+			updated_at = $19
+		WHERE id = $1`
+
+	sealed, err := p.sealSecrets(ctx, &show.YouTubeKey, show.AdditionalKey, show.ZoomPasscode)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.pool.Exec(ctx, query,
+		show.ID, 
+		nullStringIfEmpty(show.ShowName), 
+		nullStringIfEmpty(*sealed.YouTubeKey), 
+		sealed.AdditionalKey, 
+		show.ZoomMeetingURL,
+		show.ZoomMeetingID, 
+		sealed.ZoomPasscode, 
+		nullTimeIfZero(show.StartTime), 
+		nullIntIfZero(show.LengthMinutes),
+		nullTimeIfZero(show.FirstEventDate), 
+		nullStringIfEmpty(string(show.RepeatPattern)), 
+		schedulingConfigJSON,
+		show.DefaultHost,
+		show.DefaultDirector,
+		show.DefaultProducer,
+		show.DefaultTelegram,
+		nullStringIfEmpty(string(show.Status)), 
+		metadataJSON,
+		time.Now(),
+	)
+	return err
+}
+
+// Event operations
+
+func (p *PostgresDB) CreateEvent(ctx context.Context, event *models.Event) error {
+	event.ID = uuid.New()
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = time.Now()
+	event.GeneratedAt = time.Now()
+
+	// Convert custom fields to JSON
+	var customFieldsJSON []byte
+	var err error
+	if event.CustomFields != nil {
+		customFieldsJSON, err = json.Marshal(event.CustomFields)
+		if err != nil {
+			return fmt.Errorf("failed to marshal custom fields: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO events (id, show_id, user_id, event_title, event_description, 
+			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
+			start_datetime, length_minutes, end_datetime, status, is_customized, 
+			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+		RETURNING id, created_at, updated_at, generated_at`
+
+	sealed, err := p.sealSecrets(ctx, event.YouTubeKey, event.AdditionalKey, event.ZoomPasscode)
+	if err != nil {
+		return err
+	}
+
+	err = p.pool.QueryRow(ctx, query,
+		event.ID, event.ShowID, event.UserID, event.EventTitle, event.EventDescription,
+		sealed.YouTubeKey, sealed.AdditionalKey, event.ZoomMeetingURL, event.ZoomMeetingID, sealed.ZoomPasscode,
+		event.StartDateTime, event.LengthMinutes, event.EndDateTime, event.Status, event.IsCustomized,
+		customFieldsJSON, event.GeneratedAt, event.LastSyncedAt, event.ShowVersion, event.CreatedAt, event.UpdatedAt,
+	).Scan(&event.ID, &event.CreatedAt, &event.UpdatedAt, &event.GeneratedAt)
+
+	return err
+}
+
+func (p *PostgresDB) CreateEvents(ctx context.Context, events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Use a transaction for batch insert
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO events (id, show_id, user_id, event_title, event_description, 
+			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
+			start_datetime, length_minutes, end_datetime, status, is_customized, 
+			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`
+
+	for i := range events {
+		event := &events[i]
+		event.ID = uuid.New()
+		event.CreatedAt = time.Now()
+		event.UpdatedAt = time.Now()
+		if event.GeneratedAt.IsZero() {
+			event.GeneratedAt = time.Now()
+		}
+
+		// Convert custom fields to JSON
+		var customFieldsJSON []byte
+		if event.CustomFields != nil {
+			customFieldsJSON, err = json.Marshal(event.CustomFields)
+			if err != nil {
+				return fmt.Errorf("failed to marshal custom fields: %w", err)
+			}
+		}
+
+		sealed, err := p.sealSecrets(ctx, event.YouTubeKey, event.AdditionalKey, event.ZoomPasscode)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, query,
+			event.ID, event.ShowID, event.UserID, event.EventTitle, event.EventDescription,
+			sealed.YouTubeKey, sealed.AdditionalKey, event.ZoomMeetingURL, event.ZoomMeetingID, sealed.ZoomPasscode,
+			event.StartDateTime, event.LengthMinutes, event.EndDateTime, event.Status, event.IsCustomized,
+			customFieldsJSON, event.GeneratedAt, event.LastSyncedAt, event.ShowVersion, event.CreatedAt, event.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert event %d: %w", i, err)
+		}
+	}
+
+	// Give each new event its show's rundown, when the show has one set to auto-apply
+	templates := make(map[uuid.UUID]*models.RundownTemplate)
+	for i := range events {
+		event := &events[i]
+		template, loaded := templates[event.ShowID]
+		if !loaded {
+			template, err = getRundownTemplate(ctx, tx, event.ShowID)
+			if err != nil {
+				return fmt.Errorf("failed to get rundown template: %w", err)
+			}
+			templates[event.ShowID] = template
+		}
+		if template == nil || !template.AutoApply || event.Status == models.EventStatusCancelled {
+			continue
+		}
+		if _, err := applyRundownTemplate(ctx, tx, template, event); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresDB) GetEventByID(ctx context.Context, eventID uuid.UUID) (*models.Event, error) {
+	event := &models.Event{}
+	var customFieldsJSON []byte
+
+	query := `
+		SELECT id, show_id, user_id, event_title, event_description, 
+			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
+			start_datetime, length_minutes, end_datetime, status, is_customized, 
+			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
+		FROM events WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, eventID).Scan(
+		&event.ID, &event.ShowID, &event.UserID, &event.EventTitle, &event.EventDescription,
+		&event.YouTubeKey, &event.AdditionalKey, &event.ZoomMeetingURL, &event.ZoomMeetingID, &event.ZoomPasscode,
+		&event.StartDateTime, &event.LengthMinutes, &event.EndDateTime, &event.Status, &event.IsCustomized,
+		&customFieldsJSON, &event.GeneratedAt, &event.LastSyncedAt, &event.ShowVersion, &event.CreatedAt, &event.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.openEventSecrets(ctx, event); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal custom fields if present
+	if len(customFieldsJSON) > 0 {
+		if err := json.Unmarshal(customFieldsJSON, &event.CustomFields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal custom fields: %w", err)
+		}
+	}
+
+	return event, nil
+}
+
+func (p *PostgresDB) UpdateEvent(ctx context.Context, event *models.Event) error {
+	// Convert custom fields to JSON
+	var customFieldsJSON []byte
+	var err error
+	if event.CustomFields != nil {
+		customFieldsJSON, err = json.Marshal(event.CustomFields)
+		if err != nil {
+			return fmt.Errorf("failed to marshal custom fields: %w", err)
+		}
+	}
+
+	query := `
+		UPDATE events SET 
+			event_title = $2, event_description = $3, youtube_key = $4, additional_key = $5,
+			zoom_meeting_url = $6, zoom_meeting_id = $7, zoom_passcode = $8,
+			start_datetime = $9, length_minutes = $10, end_datetime = $11,
+			status = $12, is_customized = $13, custom_fields = $14, last_synced_at = $15
+		WHERE id = $1`
+
+	sealed, err := p.sealSecrets(ctx, event.YouTubeKey, event.AdditionalKey, event.ZoomPasscode)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = p.pool.Exec(ctx, query,
+		event.ID, event.EventTitle, event.EventDescription, sealed.YouTubeKey, sealed.AdditionalKey,
+		event.ZoomMeetingURL, event.ZoomMeetingID, sealed.ZoomPasscode,
+		event.StartDateTime, event.LengthMinutes, event.EndDateTime,
+		event.Status, event.IsCustomized, customFieldsJSON, &now,
+	)
+	return err
+}
+
+
+func (p *PostgresDB) GetEventsByShowID(ctx context.Context, showID uuid.UUID) ([]models.Event, error) {
+	query := `
+		SELECT id, show_id, user_id, event_title, event_description, 
+			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
+			start_datetime, length_minutes, end_datetime, status, is_customized, 
+			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
+		FROM events 
+		WHERE show_id = $1
+		ORDER BY start_datetime ASC`
+
+	rows, err := p.pool.Query(ctx, query, showID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return p.scanEvents(ctx, rows)
+}
+
+func (p *PostgresDB) GetFutureEvents(ctx context.Context, showID uuid.UUID) ([]models.Event, error) {
+	query := `
+		SELECT id, show_id, user_id, event_title, event_description, 
+			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
+			start_datetime, length_minutes, end_datetime, status, is_customized, 
+			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
+		FROM events 
+		WHERE show_id = $1 AND start_datetime > $2 AND status != $3
+		ORDER BY start_datetime ASC`
+
+	rows, err := p.pool.Query(ctx, query, showID, time.Now(), models.EventStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return p.scanEvents(ctx, rows)
+}
+
+
+
+
+// Helper function to scan events from rows
+func (p *PostgresDB) scanEvents(ctx context.Context, rows pgx.Rows) ([]models.Event, error) {
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		var customFieldsJSON []byte
+
+		err := rows.Scan(
+			&event.ID, &event.ShowID, &event.UserID, &event.EventTitle, &event.EventDescription,
+			&event.YouTubeKey, &event.AdditionalKey, &event.ZoomMeetingURL, &event.ZoomMeetingID, &event.ZoomPasscode,
+			&event.StartDateTime, &event.LengthMinutes, &event.EndDateTime, &event.Status, &event.IsCustomized,
+			&customFieldsJSON, &event.GeneratedAt, &event.LastSyncedAt, &event.ShowVersion, &event.CreatedAt, &event.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.openEventSecrets(ctx, &event); err != nil {
+			return nil, err
+		}
+
+		// Unmarshal custom fields if present
+		if len(customFieldsJSON) > 0 {
+			if err := json.Unmarshal(customFieldsJSON, &event.CustomFields); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal custom fields: %w", err)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Event generation log operations
+
+func (p *PostgresDB) CreateEventGenerationLog(ctx context.Context, log *models.EventGenerationLog) error {
+	log.ID = uuid.New()
+	log.CreatedAt = time.Now()
+	if log.GenerationDate.IsZero() {
+		log.GenerationDate = time.Now()
+	}
+
+	query := `
+		INSERT INTO event_generation_logs (id, show_id, generation_date, events_generated, 
+			generated_until, trigger_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	err := p.pool.QueryRow(ctx, query,
+		log.ID, log.ShowID, log.GenerationDate, log.EventsGenerated,
+		log.GeneratedUntil, log.TriggerReason, log.CreatedAt,
+	).Scan(&log.ID, &log.CreatedAt)
+
+	return err
+}
+
+func (p *PostgresDB) GetLastGenerationDate(ctx context.Context, showID uuid.UUID) (time.Time, error) {
+	var lastDate time.Time
+	query := `
+		SELECT generated_until 
+		FROM event_generation_logs 
+		WHERE show_id = $1 
+		ORDER BY generation_date DESC 
+		LIMIT 1`
+
+	err := p.pool.QueryRow(ctx, query, showID).Scan(&lastDate)
+	if err == pgx.ErrNoRows {
+		// Return zero time if no logs found
+		return time.Time{}, nil
+	}
+	return lastDate, err
+}
+
+// RESTful Event Operations
+
+// UpdateEventREST updates an event with the new RESTful format
+func (p *PostgresDB) UpdateEventREST(ctx context.Context, eventID uuid.UUID, req *models.UpdateEventRequestREST) (*models.Event, error) {
+	// Get existing event first
+	event, err := p.GetEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	// Build dynamic update query
+	setParts := []string{}
+	args := []interface{}{}
+	argCount := 0
+
+	if req.LengthMinutes != nil {
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("length_minutes = $%d", argCount))
+		args = append(args, *req.LengthMinutes)
+	}
+
+	if req.EventName != nil {
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("event_title = $%d", argCount))
+		args = append(args, *req.EventName)
+	}
+
+	if req.EventDate != nil && req.EventTime != nil {
+		// Parse and combine date and time
+		dateTimeStr := *req.EventDate + "T" + *req.EventTime
+		startDateTime, err := time.Parse("2006-01-02T15:04:05", dateTimeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date/time format: %w", err)
+		}
+		
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("start_datetime = $%d", argCount))
+		args = append(args, startDateTime)
+		
+		// Update end time if length changed
+		lengthMinutes := event.LengthMinutes
+		if req.LengthMinutes != nil {
+			lengthMinutes = req.LengthMinutes
+		}
+		if lengthMinutes != nil {
+			endDateTime := startDateTime.Add(time.Duration(*lengthMinutes) * time.Minute)
+			argCount++
+			setParts = append(setParts, fmt.Sprintf("end_datetime = $%d", argCount))
+			args = append(args, endDateTime)
+		}
+	}
+
+	if req.YouTubeKey != nil {
+		sealed, err := p.sealSecrets(ctx, req.YouTubeKey, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("youtube_key = $%d", argCount))
+		args = append(args, *sealed.YouTubeKey)
+	}
+
+	if req.ZoomMeetingURL != nil {
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("zoom_meeting_url = $%d", argCount))
+		args = append(args, *req.ZoomMeetingURL)
+	}
+
+	if req.Host != nil {
+		// Convert string UUIDs to UUID array
+		hostUUIDs := make([]uuid.UUID, len(req.Host))
+		for i, hostStr := range req.Host {
+			hostUUID, err := uuid.Parse(hostStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host UUID %s: %w", hostStr, err)
+			}
+			hostUUIDs[i] = hostUUID
+		}
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("host = $%d", argCount))
+		args = append(args, hostUUIDs)
+	}
+
+	if req.Director != nil {
+		directorUUIDs := make([]uuid.UUID, len(req.Director))
+		for i, directorStr := range req.Director {
+			directorUUID, err := uuid.Parse(directorStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid director UUID %s: %w", directorStr, err)
+			}
+			directorUUIDs[i] = directorUUID
+		}
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("director = $%d", argCount))
+		args = append(args, directorUUIDs)
+	}
+
+	if req.Producer != nil {
+		producerUUIDs := make([]uuid.UUID, len(req.Producer))
+		for i, producerStr := range req.Producer {
+			producerUUID, err := uuid.Parse(producerStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid producer UUID %s: %w", producerStr, err)
+			}
+			producerUUIDs[i] = producerUUID
+		}
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("producer = $%d", argCount))
+		args = append(args, producerUUIDs)
+	}
+
+	if req.Telegram != nil {
+		argCount++
+		setParts = append(setParts, fmt.Sprintf("telegram = $%d", argCount))
+		args = append(args, *req.Telegram)
+	}
+
+	if len(setParts) == 0 {
+		return event, nil // No changes
+	}
+
+	// Always update the updated_at timestamp
+	argCount++
+	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argCount))
+	args = append(args, time.Now())
+
+	// Mark as customized
+	argCount++
+	setParts = append(setParts, fmt.Sprintf("is_customized = $%d", argCount))
+	args = append(args, true)
+
+	// Add WHERE clause
+	argCount++
+	args = append(args, eventID)
+
+	query := fmt.Sprintf("UPDATE events SET %s WHERE id = $%d", strings.Join(setParts, ", "), argCount)
+
+	_, err = p.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return updated event
+	return p.GetEventByID(ctx, eventID)
+}
+
+// DeleteEventREST deletes an event (soft delete by default)
+func (p *PostgresDB) DeleteEventREST(ctx context.Context, eventID uuid.UUID, force bool) error {
+	if force {
+		// Hard delete
+		query := "DELETE FROM events WHERE id = $1"
+		_, err := p.pool.Exec(ctx, query, eventID)
+		return err
+	} else {
+		// Soft delete - set status to cancelled
+		query := "UPDATE events SET status = $1, updated_at = $2 WHERE id = $3"
+		_, err := p.pool.Exec(ctx, query, models.EventStatusCancelled, time.Now(), eventID)
+		return err
+	}
+}
+
+// GetEventREST gets an event with full details for RESTful response
+func (p *PostgresDB) GetEventREST(ctx context.Context, eventID uuid.UUID) (*models.EventDetailREST, error) {
+	// Get the event
+	event, err := p.GetEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, nil
+	}
+
+	// Get show details
+	show, err := p.GetShowByID(ctx, event.ShowID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get host details
+	hostSummaries, err := p.getUserSummaries(ctx, event.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get director details
+	directorSummaries, err := p.getUserSummaries(ctx, event.Director)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get producer details
+	producerSummaries, err := p.getUserSummaries(ctx, event.Producer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get blocks
+	blocks, err := p.GetEventBlocks(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to REST format
+	blockSummaries := make([]models.BlockSummaryREST, len(blocks))
+	for i, block := range blocks {
+		blockSummaries[i] = models.BlockSummaryREST{
+			ID:                   block.ID,
+			Title:                block.Title,
+			OrderIndex:           block.OrderIndex,
+			EstimatedLength:      block.EstimatedLength,
+			UnconfirmedGuests:    block.UnconfirmedGuests,
+			HasUnconfirmedGuests: block.HasUnconfirmedGuests,
+		}
+	}
+
+	// Determine event name (use event title if set, otherwise show name)
+	eventName := show.ShowName
+	if event.EventTitle != nil && *event.EventTitle != "" {
+		eventName = *event.EventTitle
+	}
+
+	showSummary := &models.ShowSummary{
+		ID:            show.ID,
+		ShowName:      show.ShowName,
+		RepeatPattern: show.RepeatPattern,
+		Status:        show.Status,
+	}
+
+	// Determine length (use event length if set, otherwise show length)
+	lengthMinutes := show.LengthMinutes
+	if event.LengthMinutes != nil {
+		lengthMinutes = *event.LengthMinutes
+	}
+
+	return &models.EventDetailREST{
+		ID:            event.ID,
+		EventName:     eventName,
+		EventDate:     event.StartDateTime,
+		LengthMinutes: lengthMinutes,
+		Status:        event.Status,
+		YouTubeKey:    event.YouTubeKey,
+		ZoomMeetingURL: event.ZoomMeetingURL,
+		Host:          hostSummaries,
+		Director:      directorSummaries,
+		Producer:      producerSummaries,
+		Telegram:      event.Telegram,
+		Show:          showSummary,
+		Blocks:        blockSummaries,
+		CreatedAt:     event.CreatedAt,
+		UpdatedAt:     event.UpdatedAt,
+	}, nil
+}
+
+// ListEventsREST returns paginated events for a user with enhanced filtering
+func (p *PostgresDB) ListEventsREST(ctx context.Context, userID uuid.UUID, eventYear, eventMonth, eventWeek *int, status models.EventStatus, showID, search string, sortField, sortOrder string, limit, offset int) ([]models.EventListItemREST, int, *models.EventFiltersREST, error) {
+	// Build where clause
+	whereConditions := []string{"e.user_id = $1"}
+	args := []interface{}{userID}
+	argCount := 1
+
+	// Year filter
+	if eventYear != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(YEAR FROM e.start_datetime) = $%d", argCount))
+		args = append(args, *eventYear)
+	}
+
+	// Month filter
+	if eventMonth != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(MONTH FROM e.start_datetime) = $%d", argCount))
+		args = append(args, *eventMonth)
+	}
+
+	// Week filter (ISO week)
+	if eventWeek != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(WEEK FROM e.start_datetime) = $%d", argCount))
+		args = append(args, *eventWeek)
+	}
+
+	// Status filter
+	if status != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("e.status = $%d", argCount))
+		args = append(args, status)
+	}
+
+	// Show ID filter
+	if showID != "" {
+		showUUID, err := uuid.Parse(showID)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("invalid show ID: %w", err)
+		}
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("e.show_id = $%d", argCount))
+		args = append(args, showUUID)
+	}
+
+	// Search filter
+	if search != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("(LOWER(COALESCE(e.event_title, s.show_name)) LIKE LOWER($%d))", argCount))
+		args = append(args, "%"+search+"%")
+	}
+
+	whereClause := strings.Join(whereConditions, " AND ")
+
+	// Count total
+	var total int
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) 
+		FROM events e 
+		JOIN shows s ON e.show_id = s.id 
+		WHERE %s`, whereClause)
+	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, nil, err
+	}
+
+	// Build order by clause
+	orderBy := "e.start_datetime ASC" // default
+	if sortField != "" {
+		allowedFields := map[string]string{
+			"event_date":   "e.start_datetime",
+			"event_name":   "COALESCE(e.event_title, s.show_name)",
+			"created_at":   "e.created_at",
+		}
+		if dbField, exists := allowedFields[sortField]; exists {
+			order := "ASC"
+			if strings.ToUpper(sortOrder) == "DESC" {
+				order = "DESC"
+			}
+			orderBy = fmt.Sprintf("%s %s", dbField, order)
+		}
+	}
+
+	// Get events
+	query := fmt.Sprintf(`
+		SELECT e.id, COALESCE(e.event_title, s.show_name) as event_name, e.start_datetime,
+			COALESCE(e.length_minutes, s.length_minutes) as length_minutes, e.status,
+			s.id as show_id, s.show_name, s.repeat_pattern, s.status as show_status,
+			CASE WHEN e.zoom_meeting_url IS NOT NULL AND e.zoom_meeting_url != '' THEN true ELSE false END as has_zoom,
+			COALESCE(array_length(e.host, 1), 0) as host_count
+		FROM events e 
+		JOIN shows s ON e.show_id = s.id 
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
+
+	args = append(args, limit, offset)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer rows.Close()
+
+	var events []models.EventListItemREST
+	for rows.Next() {
+		var item models.EventListItemREST
+		var show models.ShowSummary
+
+		err := rows.Scan(
+			&item.ID, &item.EventName, &item.EventDate, &item.LengthMinutes, &item.Status,
+			&show.ID, &show.ShowName, &show.RepeatPattern, &show.Status,
+			&item.HasZoom, &item.HostCount,
+		)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		item.Show = &show
+		
+		// Get block count
+		blockCount, err := p.getEventBlockCount(ctx, item.ID)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		item.BlockCount = blockCount
+
+		events = append(events, item)
+	}
+
+	// Build filters response
+	filters := &models.EventFiltersREST{
+		EventYear:  eventYear,
+		EventMonth: eventMonth,
+		EventWeek:  eventWeek,
+	}
+	if status != "" {
+		filters.Status = &status
+	}
+	if showID != "" {
+		filters.ShowID = &showID
+	}
+	if search != "" {
+		filters.Search = &search
+	}
+
+	return events, total, filters, nil
+}
+
+// Helper function to get user summaries
+func (p *PostgresDB) getUserSummaries(ctx context.Context, userIDs []uuid.UUID) ([]models.UserSummary, error) {
+	if len(userIDs) == 0 {
+		return []models.UserSummary{}, nil
+	}
+
+	// Build placeholders for IN clause
+	placeholders := make([]string, len(userIDs))
+	args := make([]interface{}, len(userIDs))
+	for i, userID := range userIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = userID
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, first_name, last_name, email 
+		FROM users 
+		WHERE id IN (%s)`, strings.Join(placeholders, ","))
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.UserSummary
+	for rows.Next() {
+		var summary models.UserSummary
+		var firstName, lastName *string
+		
+		err := rows.Scan(&summary.ID, &firstName, &lastName, &summary.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		// Construct name
+		if firstName != nil && lastName != nil {
+			name := *firstName + " " + *lastName
+			summary.Name = name
+		} else if firstName != nil {
+			summary.Name = *firstName
+		} else if lastName != nil {
+			summary.Name = *lastName
+		} else {
+			summary.Name = summary.Email // Fallback to email
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, nil
+}
+
+// GetUserSummaries returns user summaries for the given user IDs (public method)
+func (p *PostgresDB) GetUserSummaries(ctx context.Context, userIDs []uuid.UUID) ([]models.UserSummary, error) {
+	return p.getUserSummaries(ctx, userIDs)
+}
+
+// Helper function to get event block count
+func (p *PostgresDB) getEventBlockCount(ctx context.Context, eventID uuid.UUID) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM blocks WHERE event_id = $1"
+	err := p.pool.QueryRow(ctx, query, eventID).Scan(&count)
+	return count, err
+}
+
+func (p *PostgresDB) GetActiveShows(ctx context.Context) ([]models.Show, error) {
+	query := `
+		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
+			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
+			repeat_pattern, scheduling_config, version, created_at, updated_at, status, user_id, metadata
+		FROM shows 
+		WHERE status = $1
+		ORDER BY created_at ASC`
+
+	rows, err := p.pool.Query(ctx, query, models.ShowStatusActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shows []models.Show
+	for rows.Next() {
+		var show models.Show
+		var metadataJSON []byte
+		var schedulingConfigJSON []byte
+
+		err := rows.Scan(
+			&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
+			&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
+			&show.RepeatPattern, &schedulingConfigJSON, &show.Version, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.openShowSecrets(ctx, &show); err != nil {
+			return nil, err
+		}
+
+		// Unmarshal metadata if present
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		// Unmarshal scheduling config if present
+		if len(schedulingConfigJSON) > 0 {
+			show.SchedulingConfig = &models.SchedulingConfig{}
+			if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
+			}
+		}
+
+		shows = append(shows, show)
+	}
+
+	return shows, nil
+}
+
+// ListShowsREST returns paginated shows for a user with basic filtering and sorting
+func (p *PostgresDB) ListShowsREST(ctx context.Context, userID uuid.UUID, search string, status models.ShowStatus, sortField, sortOrder string, limit, offset int) ([]models.Show, int, error) {
+	// Build where clause
+	whereConditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	argCount := 1
+
+	// Add search filter
+	if search != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(show_name) LIKE LOWER($%d)", argCount))
+		args = append(args, "%"+search+"%")
+	}
+
+	// Add status filter
+	if status != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("status = $%d", argCount))
+		args = append(args, status)
+	}
+
+	whereClause := strings.Join(whereConditions, " AND ")
+
+	// Count total
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM shows WHERE %s", whereClause)
+	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Build order by clause
+	orderBy := "created_at DESC" // default
+	if sortField != "" {
+		allowedFields := map[string]bool{
+			"show_name":        true,
+			"first_event_date": true,
+			"created_at":       true,
+			"updated_at":       true,
+		}
+		if allowedFields[sortField] {
+			order := "ASC"
+			if strings.ToUpper(sortOrder) == "DESC" {
+				order = "DESC"
+			}
+			orderBy = fmt.Sprintf("%s %s", sortField, order)
+		}
+	}
+
+	// Get shows
+	query := fmt.Sprintf(`
+		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
+			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
+			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
+			default_telegram, created_at, updated_at, status, user_id, metadata
+		FROM shows 
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
+
+	args = append(args, limit, offset)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var shows []models.Show
+	for rows.Next() {
+		var show models.Show
+		var metadataJSON []byte
+		var schedulingConfigJSON []byte
+
+		err := rows.Scan(
+			&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
+			&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
+			&show.RepeatPattern, &schedulingConfigJSON, &show.DefaultHost, &show.DefaultDirector, &show.DefaultProducer,
+			&show.DefaultTelegram, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if err := p.openShowSecrets(ctx, &show); err != nil {
+			return nil, 0, err
+		}
+
+		// Unmarshal metadata if present
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal metadata: %w", err)
+			}
+		}
+
+		// Unmarshal scheduling config if present
+		if len(schedulingConfigJSON) > 0 {
+			show.SchedulingConfig = &models.SchedulingConfig{}
+			if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
+				return nil, 0, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
+			}
+		}
+
+		shows = append(shows, show)
+	}
+
+	return shows, total, nil
+}
+
+// Guest operations
+
+func (p *PostgresDB) CreateGuest(ctx context.Context, guest *models.Guest) error {
+	guest.ID = uuid.New()
+	guest.CreatedAt = time.Now()
+	guest.UpdatedAt = time.Now()
+
+	// Convert contacts, tags, and metadata to JSON
+	contactsJSON, err := json.Marshal(guest.Contacts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contacts: %w", err)
+	}
+
+	tagsJSON, err := json.Marshal(guest.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	metadataJSON, err := json.Marshal(guest.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	query := `
+		INSERT INTO guests (id, user_id, name, surname, short_name, contacts, 
+			notes, avatar, tags, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at`
+
+	err = p.pool.QueryRow(ctx, query,
+		guest.ID, guest.UserID, guest.Name, guest.Surname, guest.ShortName,
+		contactsJSON, guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
+		guest.CreatedAt, guest.UpdatedAt,
+	).Scan(&guest.ID, &guest.CreatedAt, &guest.UpdatedAt)
+
+	return err
+}
+
+func (p *PostgresDB) GetGuestByID(ctx context.Context, guestID uuid.UUID) (*models.Guest, error) {
+	guest := &models.Guest{}
+	var contactsJSON, tagsJSON, metadataJSON []byte
+
+	query := `
+		SELECT id, user_id, name, surname, short_name, contacts, notes, avatar, 
+			tags, metadata, created_at, updated_at, erased_at
+		FROM guests WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, guestID).Scan(
+		&guest.ID, &guest.UserID, &guest.Name, &guest.Surname, &guest.ShortName,
+		&contactsJSON, &guest.Notes, &guest.Avatar, &tagsJSON, &metadataJSON,
+		&guest.CreatedAt, &guest.UpdatedAt, &guest.ErasedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal JSON fields
+	if len(contactsJSON) > 0 {
+		if err := json.Unmarshal(contactsJSON, &guest.Contacts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contacts: %w", err)
+		}
+	}
+
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &guest.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+	}
+
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &guest.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return guest, nil
+}
+
+func (p *PostgresDB) UpdateGuest(ctx context.Context, guest *models.Guest) error {
+	// Convert contacts, tags, and metadata to JSON
+	contactsJSON, err := json.Marshal(guest.Contacts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contacts: %w", err)
+	}
+
+	tagsJSON, err := json.Marshal(guest.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	metadataJSON, err := json.Marshal(guest.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	query := `
+		UPDATE guests SET 
+			name = $2, surname = $3, short_name = $4, contacts = $5,
+			notes = $6, avatar = $7, tags = $8, metadata = $9
+		WHERE id = $1`
+
+	_, err = p.pool.Exec(ctx, query,
+		guest.ID, guest.Name, guest.Surname, guest.ShortName, contactsJSON,
+		guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
+	)
+	return err
+}
+
+func (p *PostgresDB) DeleteGuest(ctx context.Context, guestID uuid.UUID) error {
+	query := `DELETE FROM guests WHERE id = $1`
+	
+	result, err := p.pool.Exec(ctx, query, guestID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("no guest found with ID: %s", guestID)
+	}
+
+	return nil
+}
+
+// UpdateGuestAvatar sets only the avatar of a guest, leaving concurrent edits of other fields intact
+func (p *PostgresDB) UpdateGuestAvatar(ctx context.Context, guestID uuid.UUID, avatar *string) error {
+	result, err := p.pool.Exec(ctx, `UPDATE guests SET avatar = $2 WHERE id = $1`, guestID, avatar)
+	if err != nil {
+		return fmt.Errorf("failed to update guest avatar: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no guest found with ID: %s", guestID)
+	}
+	return nil
+}
+
+func (p *PostgresDB) ListGuests(ctx context.Context, userID uuid.UUID, filters models.GuestFilters, pagination models.PaginationOptions, sort models.GuestSortOptions) ([]models.GuestListItem, int, error) {
+	// Set defaults
+	if pagination.Limit <= 0 {
+		pagination.Limit = 20
+	}
+	if pagination.Page <= 0 {
+		pagination.Page = 1
+	}
+	offset := (pagination.Page - 1) * pagination.Limit
+
+	whereClause, args := guestFilterClause(userID, filters)
+	argCount := len(args)
+
+	// Count total
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM guests WHERE %s", whereClause)
+	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	orderBy := guestOrderBy(sort)
+
+	// Get guests with summary data
+	query := fmt.Sprintf(`
+		SELECT id, name, surname, short_name, contacts, avatar, tags, 
+			LEFT(notes, 100) as notes_preview, 
+			jsonb_array_length(COALESCE(contacts, '[]'::jsonb)) as contact_count,
+			(
+				SELECT MAX(e.start_datetime)
+				FROM block_guests bg
+				JOIN blocks b ON b.id = bg.block_id
+				JOIN events e ON e.id = b.event_id
+				WHERE bg.guest_id = guests.id AND e.status <> 'cancelled'
+					AND e.start_datetime <= CURRENT_TIMESTAMP
+			) as last_appearance,
+			created_at
+		FROM guests 
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
+
+	args = append(args, pagination.Limit, offset)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var guests []models.GuestListItem
+	for rows.Next() {
+		var guest models.GuestListItem
+		var contactsJSON, tagsJSON []byte
+		var notesPreview sql.NullString
+
+		err := rows.Scan(
+			&guest.ID, &guest.Name, &guest.Surname, &guest.ShortName,
+			&contactsJSON, &guest.Avatar, &tagsJSON, &notesPreview,
+			&guest.ContactCount, &guest.LastAppearance, &guest.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Process notes preview
+		if notesPreview.Valid {
+			guest.NotesPreview = &notesPreview.String
+		}
+
+		// Unmarshal contacts to find primary email
+		var contacts []models.GuestContact
+		if len(contactsJSON) > 0 {
+			json.Unmarshal(contactsJSON, &contacts)
+			for _, contact := range contacts {
+				if contact.Type == models.ContactTypeEmail && contact.IsPrimary {
+					guest.PrimaryEmail = &contact.Value
+					break
+				}
+			}
+			// If no primary email found, use first email
+			if guest.PrimaryEmail == nil {
+				for _, contact := range contacts {
+					if contact.Type == models.ContactTypeEmail {
+						guest.PrimaryEmail = &contact.Value
+						break
+					}
+				}
+			}
+		}
+
+		// Unmarshal tags
+		if len(tagsJSON) > 0 {
+			json.Unmarshal(tagsJSON, &guest.Tags)
+		}
+
+		guests = append(guests, guest)
+	}
+
+	return guests, total, nil
+}
+
+// guestAppearanceCount counts the events a guest is booked into, like GetGuestStats
+const guestAppearanceCount = `(
+	SELECT COUNT(DISTINCT e.id)
+	FROM block_guests bg
+	JOIN blocks b ON b.id = bg.block_id
+	JOIN events e ON e.id = b.event_id
+	WHERE bg.guest_id = guests.id AND e.status <> 'cancelled'
+)`
+
+// guestLastAppearance is the start of the latest event a guest appeared in that already began
+const guestLastAppearance = `(
+	SELECT MAX(e.start_datetime)
+	FROM block_guests bg
+	JOIN blocks b ON b.id = bg.block_id
+	JOIN events e ON e.id = b.event_id
+	WHERE bg.guest_id = guests.id AND e.status <> 'cancelled'
+		AND e.start_datetime <= CURRENT_TIMESTAMP
+)`
+
+// guestFilterClause builds the WHERE clause shared by ListGuests, StreamGuests and CountGuests
+func guestFilterClause(userID uuid.UUID, filters models.GuestFilters) (string, []interface{}) {
+	whereConditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	argCount := 1
+
+	// Search filter
+	if filters.Search != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf(`(
+			LOWER(name) LIKE LOWER($%d) OR
+			LOWER(surname) LIKE LOWER($%d) OR
+			LOWER(short_name) LIKE LOWER($%d) OR
+			LOWER(name || ' ' || surname) LIKE LOWER($%d)
+		)`, argCount, argCount, argCount, argCount))
+		searchPattern := "%" + filters.Search + "%"
+		args = append(args, searchPattern)
+	}
+
+	// Tags filter
+	if len(filters.Tags) > 0 && filters.IncludeChildTags {
+		// Each tag matches itself or any tag below it in the user's vocabulary
+		for _, tag := range filters.Tags {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM jsonb_array_elements_text(tags) gt
+				WHERE LOWER(gt) IN (
+					WITH RECURSIVE tree AS (
+						SELECT id, name FROM guest_tags WHERE user_id = $1 AND LOWER(name) = LOWER($%[1]d)
+						UNION
+						SELECT child.id, child.name FROM guest_tags child JOIN tree ON child.parent_id = tree.id
+					)
+					SELECT LOWER(name) FROM tree UNION SELECT LOWER($%[1]d)
+				)
+			)`, argCount))
+			args = append(args, tag)
+		}
+	} else if len(filters.Tags) > 0 {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("tags ?& $%d", argCount))
+		args = append(args, filters.Tags)
+	}
+
+	// Contact type filter
+	if len(filters.HasContactType) > 0 {
+		contactConditions := make([]string, len(filters.HasContactType))
+		for i, contactType := range filters.HasContactType {
+			argCount++
+			contactConditions[i] = fmt.Sprintf("contacts @> $%d", argCount)
+			args = append(args, fmt.Sprintf(`[{"type": "%s"}]`, contactType))
+		}
+		whereConditions = append(whereConditions, "("+strings.Join(contactConditions, " OR ")+")")
+	}
+
+	// Date range filter
+	if filters.CreatedDateRange != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at >= $%d", argCount))
+		args = append(args, filters.CreatedDateRange.Start)
+		
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("created_at <= $%d", argCount))
+		args = append(args, filters.CreatedDateRange.End)
+	}
+
+	// Appearance filters
+	if filters.MinAppearances != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("%s >= $%d", guestAppearanceCount, argCount))
+		args = append(args, *filters.MinAppearances)
+	}
+	if filters.MaxAppearances != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("%s <= $%d", guestAppearanceCount, argCount))
+		args = append(args, *filters.MaxAppearances)
+	}
+	if filters.LastAppearanceAfter != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("%s >= $%d", guestLastAppearance, argCount))
+		args = append(args, *filters.LastAppearanceAfter)
+	}
+	if filters.LastAppearanceBefore != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("%s <= $%d", guestLastAppearance, argCount))
+		args = append(args, *filters.LastAppearanceBefore)
+	}
+
+	whereClause := strings.Join(whereConditions, " AND ")
+
+	return whereClause, args
+}
+
+// guestOrderBy builds the ORDER BY clause shared by ListGuests and StreamGuests
+func guestOrderBy(sort models.GuestSortOptions) string {
+	orderBy := "name ASC, surname ASC" // default
+	if sort.Field != "" {
+		allowedFields := map[string]bool{
+			"name":       true,
+			"surname":    true,
+			"short_name": true,
+			"created_at": true,
+			"updated_at": true,
+		}
+		if sort.Field == "last_appearance" {
+			// Guests who never appeared go last either way
+			order := "ASC"
+			if strings.ToUpper(sort.Order) == "DESC" {
+				order = "DESC"
+			}
+			orderBy = fmt.Sprintf("last_appearance %s NULLS LAST, name ASC, surname ASC", order)
+		} else if allowedFields[sort.Field] {
+			order := "ASC"
+			if strings.ToUpper(sort.Order) == "DESC" {
+				order = "DESC"
+			}
+			orderBy = fmt.Sprintf("%s %s", sort.Field, order)
+		}
+	}
+
+	return orderBy
+}
+
+func (p *PostgresDB) SearchGuests(ctx context.Context, userID uuid.UUID, query string, limit int) ([]models.GuestSuggestion, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	searchQuery := `
+		SELECT g.id, g.name, g.surname, g.short_name, g.avatar, g.contacts, g.tags,
+			similarity(LOWER(g.name || ' ' || g.surname || ' ' || COALESCE(g.short_name, '')), LOWER($2)) as score
+		FROM guests g
+		WHERE g.user_id = $1 
+		AND (
+			LOWER(g.name) LIKE LOWER($2 || '%') OR
+			LOWER(g.surname) LIKE LOWER($2 || '%') OR
+			LOWER(g.short_name) LIKE LOWER($2 || '%') OR
+			LOWER(g.name || ' ' || g.surname) LIKE LOWER('%' || $2 || '%')
+		)
+		ORDER BY score DESC, g.name ASC, g.surname ASC
+		LIMIT $3`
+
+	rows, err := p.pool.Query(ctx, searchQuery, userID, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.GuestSuggestion
+	for rows.Next() {
+		var suggestion models.GuestSuggestion
+		var contactsJSON, tagsJSON []byte
+		var score float64
+
+		err := rows.Scan(
+			&suggestion.ID, &suggestion.Name, &suggestion.Surname, &suggestion.ShortName,
+			&suggestion.Avatar, &contactsJSON, &tagsJSON, &score,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Build display name
+		displayName := suggestion.Name + " " + suggestion.Surname
+		if suggestion.ShortName != nil && *suggestion.ShortName != "" {
+			displayName += " (" + *suggestion.ShortName + ")"
+		}
+		suggestion.DisplayName = displayName
+
+		// Parse contacts and find primary
+		var contacts []models.GuestContact
+		if len(contactsJSON) > 0 {
+			json.Unmarshal(contactsJSON, &contacts)
+			for _, contact := range contacts {
+				if contact.IsPrimary {
+					suggestion.PrimaryContact = &contact
+					break
+				}
+			}
+			// If no primary contact found, use first contact
+			if suggestion.PrimaryContact == nil && len(contacts) > 0 {
+				suggestion.PrimaryContact = &contacts[0]
+			}
+		}
+
+		// Parse tags
+		if len(tagsJSON) > 0 {
+			json.Unmarshal(tagsJSON, &suggestion.Tags)
+		}
+
+		suggestion.MatchScore = score
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// Guest Appearance Operations
+
+// guestAppearancesFrom joins a guest's block bookings to their events and shows.
+// Cancelled events are not appearances.
+const guestAppearancesFrom = `
+		FROM block_guests bg
+		JOIN blocks b ON b.id = bg.block_id
+		JOIN events e ON e.id = b.event_id
+		JOIN shows s ON s.id = e.show_id
+		WHERE bg.guest_id = $1 AND e.status <> 'cancelled'`
+
+// GetGuestStats derives a guest's appearance history. A guest on several blocks of
+// the same event appears once.
+func (p *PostgresDB) GetGuestStats(ctx context.Context, guestID uuid.UUID) (*models.GuestStats, error) {
+	stats := &models.GuestStats{
+		Shows: []models.GuestShowAppearances{},
+		Roles: []models.GuestRoleCount{},
+	}
+
+	summaryQuery := `
+		WITH appearances AS (
+			SELECT DISTINCT e.id, e.show_id, e.start_datetime` + guestAppearancesFrom + `
+		)
+		SELECT COUNT(*), COUNT(DISTINCT show_id),
+			MAX(start_datetime) FILTER (WHERE start_datetime <= CURRENT_TIMESTAMP),
+			MIN(start_datetime) FILTER (WHERE start_datetime > CURRENT_TIMESTAMP),
+			COUNT(*) FILTER (WHERE start_datetime > CURRENT_TIMESTAMP)
+		FROM appearances`
+
+	err := p.pool.QueryRow(ctx, summaryQuery, guestID).Scan(
+		&stats.TotalAppearances, &stats.TotalShows, &stats.LastAppearance,
+		&stats.NextAppearance, &stats.UpcomingShows,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest appearance summary: %w", err)
+	}
+
+	if stats.TotalAppearances == 0 {
+		return stats, nil
+	}
+
+	showsQuery := `
+		SELECT s.id, s.show_name, COUNT(DISTINCT e.id),
+			MAX(e.start_datetime) FILTER (WHERE e.start_datetime <= CURRENT_TIMESTAMP)` + guestAppearancesFrom + `
+		GROUP BY s.id, s.show_name
+		ORDER BY COUNT(DISTINCT e.id) DESC, s.show_name`
+
+	rows, err := p.pool.Query(ctx, showsQuery, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest appearances per show: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var show models.GuestShowAppearances
+		if err := rows.Scan(&show.ShowID, &show.ShowName, &show.Appearances, &show.LastAppearance); err != nil {
+			return nil, fmt.Errorf("failed to scan guest show appearances: %w", err)
+		}
+		stats.Shows = append(stats.Shows, show)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get guest appearances per show: %w", err)
+	}
+
+	rolesQuery := `
+		SELECT bg.role, COUNT(DISTINCT e.id)` + guestAppearancesFrom + `
+			AND COALESCE(bg.role, '') <> ''
+		GROUP BY bg.role
+		ORDER BY COUNT(DISTINCT e.id) DESC, bg.role`
+
+	roleRows, err := p.pool.Query(ctx, rolesQuery, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest roles: %w", err)
+	}
+	defer roleRows.Close()
+
+	for roleRows.Next() {
+		var role models.GuestRoleCount
+		if err := roleRows.Scan(&role.Role, &role.Appearances); err != nil {
+			return nil, fmt.Errorf("failed to scan guest role: %w", err)
+		}
+		stats.Roles = append(stats.Roles, role)
+	}
+	if err := roleRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get guest roles: %w", err)
+	}
+
+	return stats, nil
+}
+
+// ListGuestAppearances returns a guest's block bookings, newest event first
+func (p *PostgresDB) ListGuestAppearances(ctx context.Context, guestID uuid.UUID, pagination models.PaginationOptions) ([]models.GuestAppearance, int, error) {
+	if pagination.Limit <= 0 {
+		pagination.Limit = 20
+	}
+	if pagination.Page <= 0 {
+		pagination.Page = 1
+	}
+	offset := (pagination.Page - 1) * pagination.Limit
+
+	var total int
+	if err := p.pool.QueryRow(ctx, `SELECT COUNT(*)`+guestAppearancesFrom, guestID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count guest appearances: %w", err)
+	}
+
+	query := `
+		SELECT e.id, COALESCE(e.event_title, s.show_name), e.start_datetime, e.status,
+			s.id, s.show_name, b.id, b.title, bg.role` + guestAppearancesFrom + `
+		ORDER BY e.start_datetime DESC, b.order_index
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.pool.Query(ctx, query, guestID, pagination.Limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list guest appearances: %w", err)
+	}
+	defer rows.Close()
+
+	appearances := []models.GuestAppearance{}
+	for rows.Next() {
+		var appearance models.GuestAppearance
+		err := rows.Scan(
+			&appearance.EventID, &appearance.EventTitle, &appearance.StartDatetime, &appearance.EventStatus,
+			&appearance.ShowID, &appearance.ShowName, &appearance.BlockID, &appearance.BlockTitle, &appearance.Role,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan guest appearance: %w", err)
+		}
+		appearances = append(appearances, appearance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list guest appearances: %w", err)
+	}
+
+	return appearances, total, nil
+}
+
+// Block operations
+
+func (p *PostgresDB) CreateBlock(ctx context.Context, block *models.Block, guestIDs []uuid.UUID, media []models.BlockMediaInput) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Insert block
+	block.ID = uuid.New()
+	block.CreatedAt = time.Now()
+	block.UpdatedAt = time.Now()
+
+	metadataJSON, err := json.Marshal(block.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// If order_index is not specified, get the next available index
+	if block.OrderIndex == 0 {
+		var maxOrder sql.NullInt32
+		err = tx.QueryRow(ctx, `
+			SELECT MAX(order_index) FROM blocks WHERE event_id = $1`,
+			block.EventID).Scan(&maxOrder)
+		if err != nil {
+			return fmt.Errorf("failed to get max order index: %w", err)
+		}
+		if maxOrder.Valid {
+			block.OrderIndex = int(maxOrder.Int32) + 1
+		} else {
+			block.OrderIndex = 0
+		}
+	}
+
+	query := `
+		INSERT INTO blocks (id, event_id, user_id, title, description, topic,
+			estimated_length, order_index, block_type, status, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(ctx, query,
+		block.ID, block.EventID, block.UserID, block.Title, block.Description,
+		block.Topic, block.EstimatedLength, block.OrderIndex, block.BlockType,
+		block.Status, metadataJSON, block.CreatedAt, block.UpdatedAt,
+	).Scan(&block.ID, &block.CreatedAt, &block.UpdatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create block: %w", err)
+	}
+
+	// Insert block-guest relationships
+	for _, guestID := range guestIDs {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_guests (block_id, guest_id)
+			VALUES ($1, $2)`, block.ID, guestID)
+		if err != nil {
+			return fmt.Errorf("failed to add guest to block: %w", err)
+		}
+	}
+
+	// Insert block-media relationships
+	for _, m := range media {
+		mediaID, err := uuid.Parse(m.MediaID)
+		if err != nil {
+			return fmt.Errorf("invalid media ID: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			block.ID, mediaID, m.MediaType, m.Title, m.Description, m.OrderIndex)
+		if err != nil {
+			return fmt.Errorf("failed to add media to block: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresDB) GetBlockByID(ctx context.Context, blockID uuid.UUID) (*models.BlockDetail, error) {
+	var block models.BlockDetail
+	var metadataJSON []byte
+
+	// Get block data
+	query := `
+		SELECT id, event_id, user_id, title, description, topic,
+			estimated_length, actual_length, order_index, block_type, status,
+			metadata, created_at, updated_at
+		FROM blocks WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, blockID).Scan(
+		&block.ID, &block.EventID, &block.UserID, &block.Title, &block.Description,
+		&block.Topic, &block.EstimatedLength, &block.ActualLength, &block.OrderIndex,
+		&block.BlockType, &block.Status, &metadataJSON, &block.CreatedAt, &block.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal metadata
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &block.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	// Get guests
+	guestRows, err := p.pool.Query(ctx, `
+		SELECT g.id, g.name, g.surname, g.short_name, g.contacts, bg.role, bg.notes,
+			bg.invitation_status, bg.invited_at, bg.responded_at
+		FROM guests g
+		JOIN block_guests bg ON g.id = bg.guest_id
+		WHERE bg.block_id = $1
+		ORDER BY g.name, g.surname`, blockID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block guests: %w", err)
+	}
+	defer guestRows.Close()
+
+	for guestRows.Next() {
+		var guest models.BlockGuestDetail
+		var contactsJSON []byte
+
+		err := guestRows.Scan(
+			&guest.ID, &guest.Name, &guest.Surname, &guest.ShortName,
+			&contactsJSON, &guest.Role, &guest.Notes,
+			&guest.InvitationStatus, &guest.InvitedAt, &guest.RespondedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// Find primary contact
+		var contacts []models.GuestContact
+		if len(contactsJSON) > 0 {
+			json.Unmarshal(contactsJSON, &contacts)
+			for _, contact := range contacts {
+				if contact.IsPrimary {
+					guest.PrimaryContact = &contact
+					break
+				}
+			}
+		}
+
+		block.Guests = append(block.Guests, guest)
+	}
+
+	// Get media
+	mediaRows, err := p.pool.Query(ctx, `
+		SELECT bm.media_id, bm.media_type, bm.title, bm.description, bm.order_index,
+			m.file_name, m.file_size
+		FROM block_media bm
+		JOIN media m ON bm.media_id = m.id
+		WHERE bm.block_id = $1
+		ORDER BY bm.order_index`, blockID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block media: %w", err)
+	}
+	defer mediaRows.Close()
+
+	for mediaRows.Next() {
+		var media models.BlockMediaDetail
+
+		err := mediaRows.Scan(
+			&media.MediaID, &media.MediaType, &media.Title, &media.Description,
+			&media.OrderIndex, &media.FileName, &media.FileSize,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// TODO: Generate S3 URL
+		media.S3URL = fmt.Sprintf("https://s3.example.com/media/%s", media.MediaID)
+
+		block.Media = append(block.Media, media)
+	}
+
+	return &block, nil
+}
+
+func (p *PostgresDB) UpdateBlock(ctx context.Context, block *models.Block, guestIDs []uuid.UUID, media []models.BlockMediaInput) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	block.UpdatedAt = time.Now()
+	metadataJSON, err := json.Marshal(block.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	// Update block
+	query := `
+		UPDATE blocks SET 
+			title = $2, description = $3, topic = $4, estimated_length = $5,
+			actual_length = $6, block_type = $7, status = $8, metadata = $9,
+			updated_at = $10
+		WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query,
+		block.ID, block.Title, block.Description, block.Topic, block.EstimatedLength,
+		block.ActualLength, block.BlockType, block.Status, metadataJSON, block.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update block: %w", err)
+	}
+
+	// Update guest relationships
+	// First, delete the guests that were removed; the others keep their invitation state
+	if guestIDs == nil {
+		guestIDs = []uuid.UUID{}
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM block_guests WHERE block_id = $1 AND NOT (guest_id = ANY($2))`, block.ID, guestIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing guest relationships: %w", err)
+	}
+
+	// Then insert the new ones
+	for _, guestID := range guestIDs {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_guests (block_id, guest_id)
+			VALUES ($1, $2)
+			ON CONFLICT (block_id, guest_id) DO NOTHING`, block.ID, guestID)
+		if err != nil {
+			return fmt.Errorf("failed to add guest to block: %w", err)
+		}
+	}
+
+	// Update media relationships
+	// First, delete existing relationships
+	_, err = tx.Exec(ctx, `DELETE FROM block_media WHERE block_id = $1`, block.ID)
+	if err != nil {
+		return fmt.Errorf("failed to delete existing media relationships: %w", err)
+	}
+
+	// Then insert new ones
+	for _, m := range media {
+		mediaID, err := uuid.Parse(m.MediaID)
+		if err != nil {
+			return fmt.Errorf("invalid media ID: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			block.ID, mediaID, m.MediaType, m.Title, m.Description, m.OrderIndex)
+		if err != nil {
+			return fmt.Errorf("failed to add media to block: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresDB) DeleteBlock(ctx context.Context, blockID uuid.UUID, reorderRemaining bool) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Get block info for potential reordering
+	var eventID uuid.UUID
+	var orderIndex int
+	err = tx.QueryRow(ctx, `
+		SELECT event_id, order_index FROM blocks WHERE id = $1`, blockID).Scan(&eventID, &orderIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get block info: %w", err)
+	}
+
+	// Delete the block (cascade will handle junction tables)
+	result, err := tx.Exec(ctx, `DELETE FROM blocks WHERE id = $1`, blockID)
+	if err != nil {
+		return fmt.Errorf("failed to delete block: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no block found with ID: %s", blockID)
+	}
+
+	// Reorder remaining blocks if requested
+	if reorderRemaining {
+		_, err = tx.Exec(ctx, `
+			UPDATE blocks 
+			SET order_index = order_index - 1 
+			WHERE event_id = $1 AND order_index > $2`,
+			eventID, orderIndex)
+		if err != nil {
+			return fmt.Errorf("failed to reorder remaining blocks: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresDB) ReorderBlocks(ctx context.Context, eventID uuid.UUID, blockOrders []models.BlockOrder) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Verify all blocks belong to the event
+	blockIDs := make([]uuid.UUID, len(blockOrders))
+	for i, bo := range blockOrders {
+		blockID, err := uuid.Parse(bo.BlockID)
+		if err != nil {
+			return fmt.Errorf("invalid block ID: %w", err)
+		}
+		blockIDs[i] = blockID
+	}
+
+	var count int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM blocks 
+		WHERE event_id = $1 AND id = ANY($2)`,
+		eventID, blockIDs).Scan(&count)
+	
+	if err != nil {
+		return err
+	}
+	
+	if count != len(blockOrders) {
+		return fmt.Errorf("some blocks do not belong to this event")
+	}
+
+	// Update order indexes
+	for _, bo := range blockOrders {
+		blockID, _ := uuid.Parse(bo.BlockID) // Already validated above
+		_, err = tx.Exec(ctx, `
+			UPDATE blocks SET order_index = $2, updated_at = $3
+			WHERE id = $1 AND event_id = $4`,
+			blockID, bo.OrderIndex, time.Now(), eventID)
+		if err != nil {
+			return fmt.Errorf("failed to update block order: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p *PostgresDB) GetEventBlocks(ctx context.Context, eventID uuid.UUID) ([]models.BlockSummary, error) {
+	return getEventBlocks(ctx, p.pool, eventID)
+}
+
+// getEventBlocks lists an event's blocks in rundown order through a pool or a transaction
+func getEventBlocks(ctx context.Context, q querier, eventID uuid.UUID) ([]models.BlockSummary, error) {
+	query := `
+		SELECT b.id, b.title, b.topic, b.estimated_length, b.actual_length,
+			b.order_index, b.block_type, b.status,
+			COUNT(DISTINCT bg.guest_id) as guest_count,
+			COUNT(DISTINCT bm.media_id) as media_count,
+			COUNT(DISTINCT bg.guest_id) FILTER (
+				WHERE bg.invitation_status IN ('invited', 'tentative')
+			) as unconfirmed_guests
+		FROM blocks b
+		LEFT JOIN block_guests bg ON b.id = bg.block_id
+		LEFT JOIN block_media bm ON b.id = bm.block_id
+		WHERE b.event_id = $1
+		GROUP BY b.id
+		ORDER BY b.order_index`
+
+	rows, err := q.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []models.BlockSummary
+	for rows.Next() {
+		var block models.BlockSummary
+		err := rows.Scan(
+			&block.ID, &block.Title, &block.Topic, &block.EstimatedLength,
+			&block.ActualLength, &block.OrderIndex, &block.BlockType,
+			&block.Status, &block.GuestCount, &block.MediaCount,
+			&block.UnconfirmedGuests,
+		)
+		if err != nil {
+			return nil, err
+		}
+		block.HasUnconfirmedGuests = block.UnconfirmedGuests > 0
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+// ErrBlockNotInEvent is returned when a block to clone doesn't belong to the source event
+var ErrBlockNotInEvent = errors.New("block does not belong to the event")
+
+// CloneBlocks copies blocks of the source event into the target event, with their media
+// and, unless withoutGuests, their guests, whose invitations start over. Without block IDs
+// every block is cloned. The copies keep their source order and start at position in the
+// target's rundown, shifting the blocks from there down, or follow the last block when
+// position is nil. With preview nothing is saved.
+// Returns the target's resulting blocks and how many blocks were cloned.
+func (p *PostgresDB) CloneBlocks(ctx context.Context, sourceEventID uuid.UUID, target *models.Event, blockIDs []uuid.UUID, position *int, withoutGuests, preview bool) ([]models.BlockSummary, int, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `SELECT id FROM blocks WHERE event_id = $1`
+	args := []interface{}{sourceEventID}
+	if len(blockIDs) > 0 {
+		query += ` AND id = ANY($2)`
+		args = append(args, blockIDs)
+	}
+	query += ` ORDER BY order_index`
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list source blocks: %w", err)
+	}
+	var sourceIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to scan source block: %w", err)
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list source blocks: %w", err)
+	}
+	if len(blockIDs) > 0 && len(sourceIDs) != len(blockIDs) {
+		return nil, 0, ErrBlockNotInEvent
+	}
+	if len(sourceIDs) == 0 {
+		blocks, err := getEventBlocks(ctx, tx, target.ID)
+		return blocks, 0, err
+	}
+
+	rows, err = tx.Query(ctx, `SELECT order_index FROM blocks WHERE event_id = $1 ORDER BY order_index`, target.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list target blocks: %w", err)
+	}
+	var orders []int
+	for rows.Next() {
+		var order int
+		if err := rows.Scan(&order); err != nil {
+			rows.Close()
+			return nil, 0, fmt.Errorf("failed to scan target block: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list target blocks: %w", err)
+	}
+
+	start := 0
+	count := len(sourceIDs)
+	switch {
+	case position != nil && *position < len(orders):
+		start = orders[*position]
+		last := orders[len(orders)-1]
+
+		// The order index is unique per event and checked row by row, so the blocks move
+		// past the last one first and then back to their place after the copies
+		lift := last - start + 1 + count
+		_, err = tx.Exec(ctx, `
+			UPDATE blocks SET order_index = order_index + $3
+			WHERE event_id = $1 AND order_index >= $2`,
+			target.ID, start, lift)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to shift blocks: %w", err)
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE blocks SET order_index = order_index - $3
+			WHERE event_id = $1 AND order_index > $2`,
+			target.ID, last, lift-count)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to shift blocks: %w", err)
+		}
+	case len(orders) > 0:
+		start = orders[len(orders)-1] + 1
+	}
+
+	for i, sourceID := range sourceIDs {
+		var blockID uuid.UUID
+		err := tx.QueryRow(ctx, `
+			INSERT INTO blocks (event_id, user_id, title, description, topic,
+				estimated_length, order_index, block_type, status, metadata)
+			SELECT $1, $2, title, description, topic, estimated_length, $3, block_type, $4, metadata
+			FROM blocks WHERE id = $5
+			RETURNING id`,
+			target.ID, target.UserID, start+i, models.BlockStatusPlanned, sourceID,
+		).Scan(&blockID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to clone block: %w", err)
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
+			SELECT $1, media_id, media_type, title, description, order_index
+			FROM block_media WHERE block_id = $2`,
+			blockID, sourceID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to clone block media: %w", err)
+		}
+
+		if withoutGuests {
+			continue
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO block_guests (block_id, guest_id, role, notes)
+			SELECT $1, bg.guest_id, bg.role, bg.notes
+			FROM block_guests bg
+			JOIN guests g ON g.id = bg.guest_id
+			WHERE bg.block_id = $2 AND g.erased_at IS NULL`,
+			blockID, sourceID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to clone block guests: %w", err)
+		}
+	}
+
+	blocks, err := getEventBlocks(ctx, tx, target.ID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list target blocks: %w", err)
+	}
+	if preview {
+		return blocks, count, nil
+	}
+
+	return blocks, count, tx.Commit(ctx)
+}
+
+func (p *PostgresDB) GetEventSummary(ctx context.Context, eventID uuid.UUID) (*models.EventSummary, error) {
+	var summary models.EventSummary
+
+	query := `
+		SELECT e.id, s.show_name, e.start_datetime,
+			COUNT(b.id) as total_blocks,
+			COALESCE(SUM(b.estimated_length), 0) as total_estimated_time
+		FROM events e
+		JOIN shows s ON e.show_id = s.id
+		LEFT JOIN blocks b ON e.id = b.event_id
+		WHERE e.id = $1
+		GROUP BY e.id, s.show_name, e.start_datetime`
+
+	err := p.pool.QueryRow(ctx, query, eventID).Scan(
+		&summary.ID, &summary.ShowName, &summary.StartDateTime,
+		&summary.TotalBlocks, &summary.TotalEstimatedTime,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// User Management Operations
+
+// CreateUser creates a new user with the specified roles
+func (p *PostgresDB) CreateUser(ctx context.Context, user *models.User, roleIDs []uuid.UUID) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Generate user ID
+	user.ID = uuid.New()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	// Insert user
+	query := `
+		INSERT INTO users (id, name, surname, email, password_hash, oidc_provider, 
+			oidc_subject, status, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(ctx, query,
+		user.ID, user.Name, user.Surname, user.Email, user.PasswordHash,
+		user.OIDCProvider, user.OIDCSubject, user.Status, user.Metadata,
+		user.CreatedAt, user.UpdatedAt,
+	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+
+	if err != nil {
+		return err
+	}
+
+	// Assign roles
+	for _, roleID := range roleIDs {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role_id) 
+			VALUES ($1, $2)`,
+			user.ID, roleID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetUserByID retrieves a user by ID with their roles
+func (p *PostgresDB) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.UserWithRoles, error) {
+	var user models.UserWithRoles
+
+	// Get user details
+	query := `
+		SELECT id, name, surname, email, password_hash, oidc_provider, oidc_subject, 
+			status, metadata, created_at, updated_at, last_login_at,
+			CASE WHEN locked_until > NOW() THEN locked_until END, email_verified_at
+		FROM users
+		WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, userID).Scan(
+		&user.ID, &user.Name, &user.Surname, &user.Email, &user.PasswordHash,
+		&user.OIDCProvider, &user.OIDCSubject, &user.Status,
+		&user.Metadata, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+		&user.LockedUntil, &user.EmailVerifiedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Get user roles, with the permissions they grant while active
+	roleQuery := `
+		SELECT r.id, r.name, r.description,
+			CASE WHEN r.status = 'active' THEN r.permissions ELSE '{}'::text[] END
+		FROM roles r
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1`
+
+	rows, err := p.pool.Query(ctx, roleQuery, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	user.Roles = []models.RoleInfo{}
+	for rows.Next() {
+		var role models.RoleInfo
+		err := rows.Scan(&role.ID, &role.Name, &role.Description, &role.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		user.Roles = append(user.Roles, role)
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by email with their roles
+func (p *PostgresDB) GetUserByEmail(ctx context.Context, email string) (*models.UserWithRoles, error) {
+	var userID uuid.UUID
+
+	// Get user ID by email (only active users for authentication)
+	err := p.pool.QueryRow(ctx, "SELECT id FROM users WHERE email = $1 AND status = 'active'", email).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetUserByID(ctx, userID)
+}
+
+// GetUserByOIDC retrieves a user by OIDC provider and subject
+func (p *PostgresDB) GetUserByOIDC(ctx context.Context, provider, subject string) (*models.UserWithRoles, error) {
+	var userID uuid.UUID
+
+	// Get user ID by linked identity
+	err := p.pool.QueryRow(ctx, 
+		"SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2", 
+		provider, subject).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.GetUserByID(ctx, userID)
+}
+
+// GetUserWithPassword retrieves a user with password hash for authentication
+func (p *PostgresDB) GetUserWithPassword(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+
+	query := `
+		SELECT id, name, surname, email, password_hash, oidc_provider, 
+			oidc_subject, status, metadata, created_at, updated_at, last_login_at
+		FROM users
+		WHERE email = $1`
+
+	err := p.pool.QueryRow(ctx, query, email).Scan(
+		&user.ID, &user.Name, &user.Surname, &user.Email, &user.PasswordHash,
+		&user.OIDCProvider, &user.OIDCSubject, &user.Status,
+		&user.Metadata, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdateUser updates user information
+func (p *PostgresDB) UpdateUser(ctx context.Context, user *models.User, roleIDs []uuid.UUID) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Update user
+	query := `
+		UPDATE users SET
+			name = COALESCE($2, name),
+			surname = COALESCE($3, surname),
+			email = COALESCE($4, email),
+			password_hash = COALESCE($5, password_hash),
+			status = COALESCE($6, status),
+			metadata = COALESCE($7, metadata),
+			email_verified_at = CASE WHEN NULLIF($4, '') IS NULL OR LOWER($4) = LOWER(email) THEN email_verified_at END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	_, err = tx.Exec(ctx, query,
+		user.ID, user.Name, user.Surname, user.Email,
+		user.PasswordHash, user.Status, user.Metadata,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Update roles if provided
+	if roleIDs != nil {
+		// Remove existing roles
+		_, err = tx.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1", user.ID)
+		if err != nil {
+			return err
+		}
+
+		// Add new roles
+		for _, roleID := range roleIDs {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO user_roles (user_id, role_id) 
+				VALUES ($1, $2)`,
+				user.ID, roleID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UpdateUserLoginTime updates the last login timestamp
+func (p *PostgresDB) UpdateUserLoginTime(ctx context.Context, userID uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE users SET last_login_at = CURRENT_TIMESTAMP 
+		WHERE id = $1`, userID)
+	return err
+}
+
+// DeleteUser soft deletes a user by changing their status
+func (p *PostgresDB) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE users SET 
+			status = 'inactive',
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID)
+	return err
+}
+
+// ListUsers retrieves a paginated list of users with filters
+func (p *PostgresDB) ListUsers(ctx context.Context, filters *models.UserFilters, sort *models.UserSortOptions, pagination *models.PaginationOptions) ([]models.UserListItem, int, error) {
+	// Build base query
+	query := `
+		SELECT DISTINCT u.id, u.name, u.surname, u.email, u.status, 
+			u.created_at, u.last_login_at
+		FROM users u`
+
+	countQuery := `SELECT COUNT(DISTINCT u.id) FROM users u`
+
+	// Add joins if filtering by roles
+	if filters != nil && len(filters.RoleIDs) > 0 {
+		joinClause := ` JOIN user_roles ur ON u.id = ur.user_id`
+		query += joinClause
+		countQuery += joinClause
+	}
+
+	// Build WHERE clause
+	var whereConditions []string
+	var args []interface{}
+	argCount := 0
+
+	if filters != nil {
+		if len(filters.Status) > 0 {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("u.status = ANY($%d)", argCount))
+			args = append(args, filters.Status)
+		}
+
+		if len(filters.RoleIDs) > 0 {
+			roleUUIDs := make([]uuid.UUID, len(filters.RoleIDs))
+			for i, id := range filters.RoleIDs {
+				roleUUIDs[i], _ = uuid.Parse(id)
+			}
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("ur.role_id = ANY($%d)", argCount))
+			args = append(args, roleUUIDs)
+		}
+
+		if filters.Search != "" {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("(u.name ILIKE $%d OR u.surname ILIKE $%d OR u.email ILIKE $%d)", argCount, argCount, argCount))
+			searchTerm := "%" + filters.Search + "%"
+			args = append(args, searchTerm)
+		}
+
+		if filters.OIDCProvider != nil {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("u.oidc_provider = $%d", argCount))
+			args = append(args, *filters.OIDCProvider)
+		}
+	}
+
+	if len(whereConditions) > 0 {
+		whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
+		query += whereClause
+		countQuery += whereClause
+	}
+
+	// Get total count
+	var totalCount int
+	err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Add sorting
+	if sort != nil {
+		orderClause := fmt.Sprintf(" ORDER BY u.%s %s", sort.Field, sort.Order)
+		query += orderClause
+	} else {
+		query += " ORDER BY u.created_at DESC"
+	}
+
+	// Add pagination
+	if pagination != nil {
+		limit := pagination.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		offset := (pagination.Page - 1) * limit
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	// Execute query
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.UserListItem
+	userIDs := make([]uuid.UUID, 0)
+
+	for rows.Next() {
+		var user models.UserListItem
+		err := rows.Scan(
+			&user.ID, &user.Name, &user.Surname, &user.Email,
+			&user.Status, &user.CreatedAt, &user.LastLoginAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+		userIDs = append(userIDs, user.ID)
+	}
+
+	// Get roles for all users
+	if len(userIDs) > 0 {
+		roleQuery := `
+			SELECT ur.user_id, r.id, r.name, r.description
+			FROM user_roles ur
+			JOIN roles r ON ur.role_id = r.id
+			WHERE ur.user_id = ANY($1)`
+
+		roleRows, err := p.pool.Query(ctx, roleQuery, userIDs)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer roleRows.Close()
+
+		// Create a map to store roles by user ID
+		userRoles := make(map[uuid.UUID][]models.RoleInfo)
+		for roleRows.Next() {
+			var userID uuid.UUID
+			var role models.RoleInfo
+			err := roleRows.Scan(&userID, &role.ID, &role.Name, &role.Description)
+			if err != nil {
+				return nil, 0, err
+			}
+			userRoles[userID] = append(userRoles[userID], role)
+		}
+
+		// Note: Roles are not included in the simplified UserListItem response
+	}
+
+	return users, totalCount, nil
+}
+
+// User-Role Assignment Operations
+
+// AddRoleToUser adds a role to a user
+func (p *PostgresDB) AddRoleToUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	query := `
+		INSERT INTO user_roles (id, user_id, role_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, role_id) DO NOTHING`
+
+	_, err := p.pool.Exec(ctx, query, uuid.New(), userID, roleID, time.Now())
+	return err
+}
+
+// RemoveRoleFromUser removes a role from a user
+func (p *PostgresDB) RemoveRoleFromUser(ctx context.Context, userID, roleID uuid.UUID) error {
+	query := `
+		DELETE FROM user_roles 
+		WHERE user_id = $1 AND role_id = $2`
+
+	result, err := p.pool.Exec(ctx, query, userID, roleID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("user role assignment not found")
+	}
+
+	return nil
+}
+
+// Role Management Operations
+
+// CreateRole creates a new role
+func (p *PostgresDB) CreateRole(ctx context.Context, role *models.Role) error {
+	role.ID = uuid.New()
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO roles (id, name, description, permissions, status, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+
+	err := p.pool.QueryRow(ctx, query,
+		role.ID, role.Name, role.Description, role.Permissions,
+		role.Status, role.Metadata, role.CreatedAt, role.UpdatedAt,
+	).Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+
+	return err
+}
+
+// GetRoleByID retrieves a role by ID
+func (p *PostgresDB) GetRoleByID(ctx context.Context, roleID uuid.UUID) (*models.RoleWithUserCount, error) {
+	var role models.RoleWithUserCount
+
+	query := `
+		SELECT r.id, r.name, r.description, r.permissions, r.status, 
+			r.metadata, r.created_at, r.updated_at,
+			COUNT(DISTINCT ur.user_id) as user_count
+		FROM roles r
+		LEFT JOIN user_roles ur ON r.id = ur.role_id
+		WHERE r.id = $1
+		GROUP BY r.id`
+
+	err := p.pool.QueryRow(ctx, query, roleID).Scan(
+		&role.ID, &role.Name, &role.Description, &role.Permissions,
+		&role.Status, &role.Metadata, &role.CreatedAt, &role.UpdatedAt,
+		&role.UserCount,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// GetRoleByName retrieves a role by name
+func (p *PostgresDB) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+
+	query := `
+		SELECT id, name, description, permissions, status, 
+			metadata, created_at, updated_at
+		FROM roles
+		WHERE name = $1`
+
+	err := p.pool.QueryRow(ctx, query, name).Scan(
+		&role.ID, &role.Name, &role.Description, &role.Permissions,
+		&role.Status, &role.Metadata, &role.CreatedAt, &role.UpdatedAt,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &role, nil
+}
+
+// UpdateRole updates role information
+func (p *PostgresDB) UpdateRole(ctx context.Context, role *models.Role) error {
+	query := `
+		UPDATE roles SET
+			name = COALESCE($2, name),
+			description = COALESCE($3, description),
+			permissions = COALESCE($4, permissions),
+			status = COALESCE($5, status),
+			metadata = COALESCE($6, metadata),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	_, err := p.pool.Exec(ctx, query,
+		role.ID, role.Name, role.Description,
+		role.Permissions, role.Status, role.Metadata,
+	)
+
+	return err
+}
+
+// DeleteRole deletes a role
+func (p *PostgresDB) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
+	// Check if role has associated users
+	var userCount int
+	err := p.pool.QueryRow(ctx, 
+		"SELECT COUNT(*) FROM user_roles WHERE role_id = $1", 
+		roleID).Scan(&userCount)
+	if err != nil {
+		return err
+	}
+
+	if userCount > 0 {
+		return fmt.Errorf("cannot delete role with %d associated users", userCount)
+	}
+
+	// Delete the role
+	_, err = p.pool.Exec(ctx, "DELETE FROM roles WHERE id = $1", roleID)
+	return err
+}
+
+// ListRoles retrieves a paginated list of roles with filters
+func (p *PostgresDB) ListRoles(ctx context.Context, filters *models.RoleFilters, sort *models.RoleSortOptions, pagination *models.PaginationOptions) ([]models.RoleListItem, int, error) {
+	// Build base query (simplified - no user count for REST API)
+	query := `
+		SELECT r.id, r.name, r.description, r.permissions, r.status, 
+			r.created_at
+		FROM roles r`
+
+	countQuery := `SELECT COUNT(*) FROM roles r`
+
+	// Build WHERE clause
+	var whereConditions []string
+	var args []interface{}
+	argCount := 0
+
+	if filters != nil {
+		if len(filters.Status) > 0 {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("r.status = ANY($%d)", argCount))
+			args = append(args, filters.Status)
+		}
+
+		if filters.Search != "" {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("(r.name ILIKE $%d OR r.description ILIKE $%d)", argCount, argCount))
+			searchTerm := "%" + filters.Search + "%"
+			args = append(args, searchTerm)
+		}
+
+		if len(filters.Permissions) > 0 {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("r.permissions && $%d", argCount))
+			args = append(args, filters.Permissions)
+		}
+	}
+
+	if len(whereConditions) > 0 {
+		whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
+		query += whereClause
+		countQuery += whereClause
+	}
+
+	// Get total count
+	var totalCount int
+	err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Note: No GROUP BY needed since we're not counting users
+
+	// Add sorting
+	if sort != nil {
+		orderClause := fmt.Sprintf(" ORDER BY r.%s %s", sort.Field, sort.Order)
+		query += orderClause
+	} else {
+		query += " ORDER BY r.name ASC"
+	}
+
+	// Add pagination
+	if pagination != nil {
+		limit := pagination.Limit
+		if limit <= 0 {
+			limit = 20
+		}
+		offset := (pagination.Page - 1) * limit
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+	}
+
+	// Execute query
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var roles []models.RoleListItem
+	for rows.Next() {
+		var role models.RoleListItem
+		err := rows.Scan(
+			&role.ID, &role.Name, &role.Description, &role.Permissions,
+			&role.Status, &role.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, totalCount, nil
+}
+
+// GetUserPermissions retrieves all permissions for a user based on their roles
+func (p *PostgresDB) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT DISTINCT unnest(r.permissions) as permission
+		FROM roles r
+		JOIN user_roles ur ON r.id = ur.role_id
+		WHERE ur.user_id = $1 AND r.status = 'active'`
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []string
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
+
+// CheckUserPermission checks if a user has a specific permission
+func (p *PostgresDB) CheckUserPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM roles r
+			JOIN user_roles ur ON r.id = ur.role_id
+			WHERE ur.user_id = $1 
+				AND r.status = 'active'
+				AND $2 = ANY(r.permissions)
+		)`
+
+	var hasPermission bool
+	err := p.pool.QueryRow(ctx, query, userID, permission).Scan(&hasPermission)
+	return hasPermission, err
+}
+
+// Session Management Operations
+
+// CreateSession creates a new user session
+func (p *PostgresDB) CreateSession(ctx context.Context, session *models.Session) error {
+	// Don't override session ID if already set
+	if session.ID == uuid.Nil {
+		session.ID = uuid.New()
+	}
+	// Don't override timestamps if already set
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	if session.LastActivity.IsZero() {
+		session.LastActivity = time.Now()
+	}
+
+	query := `
+		INSERT INTO sessions (id, user_id, refresh_token_hash, device_name, device_type, 
+			ip_address, user_agent, is_active, expires_at, created_at, last_activity, metadata, location)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, last_activity`
+
+	err := p.pool.QueryRow(ctx, query,
+		session.ID, session.UserID, session.RefreshTokenHash, session.DeviceName,
+		session.DeviceType, session.IPAddress, session.UserAgent, session.IsActive,
+		session.ExpiresAt, session.CreatedAt, session.LastActivity, session.Metadata, session.Location,
+	).Scan(&session.ID, &session.CreatedAt, &session.LastActivity)
+
+	return err
+}
+
+// GetSessionByID retrieves a session by ID
+func (p *PostgresDB) GetSessionByID(ctx context.Context, sessionID uuid.UUID) (*models.Session, error) {
+	var session models.Session
+
+	query := `
+		SELECT id, user_id, refresh_token_hash, device_name, device_type, ip_address,
+			user_agent, location, is_active, expires_at, created_at, last_activity, metadata
+		FROM sessions
+		WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, sessionID).Scan(
+		&session.ID, &session.UserID, &session.RefreshTokenHash, &session.DeviceName,
+		&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.Location, &session.IsActive,
+		&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetSessionByRefreshTokenHash retrieves an active session by the hash of its current refresh token
+func (p *PostgresDB) GetSessionByRefreshTokenHash(ctx context.Context, refreshTokenHash string) (*models.Session, error) {
+	var session models.Session
+
+	query := `
+		SELECT id, user_id, refresh_token_hash, device_name, device_type, ip_address,
+			user_agent, location, is_active, expires_at, created_at, last_activity, metadata
+		FROM sessions
+		WHERE refresh_token_hash = $1 AND is_active = true`
+
+	err := p.pool.QueryRow(ctx, query, refreshTokenHash).Scan(
+		&session.ID, &session.UserID, &session.RefreshTokenHash, &session.DeviceName,
+		&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.Location, &session.IsActive,
+		&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
+	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// UpdateSession updates session information
+func (p *PostgresDB) UpdateSession(ctx context.Context, session *models.Session) error {
+	query := `
+		UPDATE sessions SET
+			refresh_token_hash = $2,
+			device_name = $3,
+			device_type = $4,
+			ip_address = $5,
+			user_agent = $6,
+			is_active = $7,
+			expires_at = $8,
+			last_activity = CURRENT_TIMESTAMP,
+			metadata = $9
+		WHERE id = $1`
+
+	_, err := p.pool.Exec(ctx, query,
+		session.ID, session.RefreshTokenHash, session.DeviceName, session.DeviceType,
+		session.IPAddress, session.UserAgent, session.IsActive, session.ExpiresAt,
+		session.Metadata,
+	)
+
+	return err
+}
+
+// UpdateSessionActivity updates the last activity timestamp for a session
+func (p *PostgresDB) UpdateSessionActivity(ctx context.Context, sessionID uuid.UUID) error {
+	query := `
+		UPDATE sessions SET last_activity = CURRENT_TIMESTAMP 
+		WHERE id = $1 AND is_active = true`
+
+	_, err := p.pool.Exec(ctx, query, sessionID)
+	return err
+}
+
+// DeleteSession deletes a session by ID
+func (p *PostgresDB) DeleteSession(ctx context.Context, sessionID uuid.UUID) error {
+	query := `DELETE FROM sessions WHERE id = $1`
+	_, err := p.pool.Exec(ctx, query, sessionID)
+	return err
+}
+
+// DeleteUserSessions deletes all sessions for a user
+func (p *PostgresDB) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	query := `DELETE FROM sessions WHERE user_id = $1`
+	_, err := p.pool.Exec(ctx, query, userID)
+	return err
+}
+
+// GetUserSessions retrieves all active sessions for a user
+func (p *PostgresDB) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, refresh_token_hash, device_name, device_type, ip_address,
+			user_agent, location, is_active, expires_at, created_at, last_activity, metadata
+		FROM sessions
+		WHERE user_id = $1 AND is_active = true AND expires_at > CURRENT_TIMESTAMP
+		ORDER BY last_activity DESC`
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []models.Post
+	var sessions []models.Session
 	for rows.Next() {
-		var post models.Post
+		var session models.Session
 		err := rows.Scan(
-			&post.ID, &post.ContentID, &post.TelegramLink, &post.ChannelName, &post.OriginalChannelName, &post.MessageID,
-			&post.CreatedAt, &post.UpdatedAt, &post.Status, &post.MediaCount, &post.TotalSize,
-			&post.ErrorMessage,
+			&session.ID, &session.UserID, &session.RefreshTokenHash, &session.DeviceName,
+			&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.Location, &session.IsActive,
+			&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
 		)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		posts = append(posts, post)
+		sessions = append(sessions, session)
 	}
 
-	return posts, total, nil
+	return sessions, nil
 }
 
-// Media operations
-func (p *PostgresDB) CreateMedia(ctx context.Context, media *models.Media) error {
-	media.ID = uuid.New()
-	media.DownloadedAt = time.Now()
+// RecordSessionAccessToken remembers an access token issued to a session so it can be blacklisted with it
+func (p *PostgresDB) RecordSessionAccessToken(ctx context.Context, sessionID, userID uuid.UUID, jti string, expiresAt time.Time) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO session_access_tokens (token_jti, session_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		jti, sessionID, userID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record session access token: %w", err)
+	}
+	return nil
+}
+
+// RotateSessionRefreshToken replaces the refresh token of an active session if the presented one is
+// still current, and records the access token issued with the new one. It returns false if another
+// refresh rotated the token first.
+func (p *PostgresDB) RotateSessionRefreshToken(ctx context.Context, sessionID uuid.UUID, currentHash, newHash, accessJTI string, accessExpiresAt time.Time) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID uuid.UUID
+	err = tx.QueryRow(ctx, `
+		UPDATE sessions SET refresh_token_hash = $3, last_activity = CURRENT_TIMESTAMP
+		WHERE id = $1 AND refresh_token_hash = $2 AND is_active = true AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id`,
+		sessionID, currentHash, newHash).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO session_access_tokens (token_jti, session_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		accessJTI, sessionID, userID, accessExpiresAt); err != nil {
+		return false, fmt.Errorf("failed to record session access token: %w", err)
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// RevokeSessionTokens deletes a session after blacklisting the access tokens issued to it that
+// haven't expired yet. It returns the number of access tokens blacklisted.
+func (p *PostgresDB) RevokeSessionTokens(ctx context.Context, sessionID uuid.UUID, reason string) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO token_blacklist (token_jti, user_id, expires_at, reason)
+		SELECT token_jti, user_id, expires_at, $2
+		FROM session_access_tokens
+		WHERE session_id = $1 AND expires_at > CURRENT_TIMESTAMP
+		ON CONFLICT (token_jti) DO NOTHING`,
+		sessionID, reason)
+	if err != nil {
+		return 0, fmt.Errorf("failed to blacklist session access tokens: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM sessions WHERE id = $1`, sessionID); err != nil {
+		return 0, fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
+// Token Blacklist Operations
+
+// CreateTokenBlacklist adds a token to the blacklist
+func (p *PostgresDB) CreateTokenBlacklist(ctx context.Context, blacklist *models.TokenBlacklist) error {
+	blacklist.ID = uuid.New()
+	blacklist.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO token_blacklist (id, token_jti, user_id, expires_at, created_at, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	err := p.pool.QueryRow(ctx, query,
+		blacklist.ID, blacklist.TokenJTI, blacklist.UserID,
+		blacklist.ExpiresAt, blacklist.CreatedAt, blacklist.Reason,
+	).Scan(&blacklist.ID, &blacklist.CreatedAt)
+
+	return err
+}
+
+// IsTokenBlacklisted checks if a token is blacklisted
+func (p *PostgresDB) IsTokenBlacklisted(ctx context.Context, jti string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM token_blacklist 
+			WHERE token_jti = $1 AND expires_at > CURRENT_TIMESTAMP
+		)`
+
+	var exists bool
+	err := p.pool.QueryRow(ctx, query, jti).Scan(&exists)
+	return exists, err
+}
+
+// DeleteExpiredTokens removes expired tokens from blacklist
+func (p *PostgresDB) DeleteExpiredTokens(ctx context.Context) error {
+	query := `DELETE FROM token_blacklist WHERE expires_at <= CURRENT_TIMESTAMP`
+	_, err := p.pool.Exec(ctx, query)
+	return err
+}
+
+// OAuth State Management Operations
+
+// CreateOAuthState creates a new OAuth state for CSRF protection
+func (p *PostgresDB) CreateOAuthState(ctx context.Context, state *models.OAuthState) error {
+	query := `
+		INSERT INTO oauth_states (id, state, user_id, expires_at, created_at, metadata, provider, nonce, code_verifier)
+		VALUES (gen_random_uuid(), $1, $2, $3, CURRENT_TIMESTAMP, $4, $5, $6, $7)`
+
+	_, err := p.pool.Exec(ctx, query, state.State, state.UserID, state.ExpiresAt, nil,
+		state.Provider, state.Nonce, state.CodeVerifier)
+	return err
+}
+
+// ValidateOAuthState validates and consumes an OAuth state
+func (p *PostgresDB) ValidateOAuthState(ctx context.Context, state string) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Check if state exists and is not expired
+	var exists bool
+	checkQuery := `
+		SELECT EXISTS(
+			SELECT 1 FROM oauth_states 
+			WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP
+		)`
 
-	// Convert metadata to JSON
-	metadataJSON, err := json.Marshal(media.Metadata)
+	err = tx.QueryRow(ctx, checkQuery, state).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return false, err
 	}
 
-	query := `
-		INSERT INTO media (id, media_id, content_id, telegram_file_id, file_name, original_file_name,
-			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		RETURNING id, downloaded_at`
+	if !exists {
+		return false, nil
+	}
 
-	err = p.pool.QueryRow(ctx, query,
-		media.ID, media.MediaID, media.ContentID, media.TelegramFileID, media.FileName, media.OriginalFileName,
-		media.FileType, media.FileSize, media.S3Bucket, media.S3Key, media.FileHash,
-		media.DownloadedAt, metadataJSON,
-	).Scan(&media.ID, &media.DownloadedAt)
+	// Delete the state (one-time use)
+	deleteQuery := `DELETE FROM oauth_states WHERE state = $1`
+	_, err = tx.Exec(ctx, deleteQuery, state)
+	if err != nil {
+		return false, err
+	}
 
-	return err
+	return true, tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetMediaByID(ctx context.Context, mediaID string) (*models.Media, error) {
-	media := &models.Media{}
-	var metadataJSON []byte
-
+// GetOAuthState retrieves an OAuth state record
+func (p *PostgresDB) GetOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	var oauthState models.OAuthState
 	query := `
-		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
-			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata
-		FROM media WHERE media_id = $1`
-
-	err := p.pool.QueryRow(ctx, query, mediaID).Scan(
-		&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
-		&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
-		&media.DownloadedAt, &metadataJSON,
-	)
+		SELECT state, expires_at, user_id::text, COALESCE(provider, ''), COALESCE(nonce, ''), COALESCE(code_verifier, '')
+		FROM oauth_states 
+		WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP`
 
+	err := p.pool.QueryRow(ctx, query, state).Scan(
+		&oauthState.State, &oauthState.ExpiresAt, &oauthState.UserID,
+		&oauthState.Provider, &oauthState.Nonce, &oauthState.CodeVerifier)
+	
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -1076,72 +5568,85 @@ func (p *PostgresDB) GetMediaByID(ctx context.Context, mediaID string) (*models.
 		return nil, err
 	}
 
-	// Unmarshal metadata if present
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
-	}
+	return &oauthState, nil
+}
 
-	return media, nil
+// DeleteOAuthState deletes an OAuth state record
+func (p *PostgresDB) DeleteOAuthState(ctx context.Context, state string) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM oauth_states WHERE state = $1`, state)
+	return err
 }
 
-func (p *PostgresDB) GetMediaByContentID(ctx context.Context, contentID string) ([]models.Media, error) {
+// CleanupExpiredAuthData removes expired sessions, tokens, and OAuth states
+func (p *PostgresDB) CleanupExpiredAuthData(ctx context.Context) error {
+	query := `SELECT cleanup_expired_auth_data()`
+	_, err := p.pool.Exec(ctx, query)
+	return err
+}
+
+// Media Upload Operations
+
+// EnsurePost creates the post if no post with the same content ID exists and returns the stored row
+func (p *PostgresDB) EnsurePost(ctx context.Context, post *models.Post) (*models.Post, error) {
 	query := `
-		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
-			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata
-		FROM media WHERE content_id = $1
-		ORDER BY downloaded_at ASC`
+		INSERT INTO posts (id, content_id, telegram_link, channel_name, original_channel_name, message_id,
+			status, media_count, total_size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 0, 0)
+		ON CONFLICT (content_id) DO NOTHING`
 
-	rows, err := p.pool.Query(ctx, query, contentID)
+	_, err := p.pool.Exec(ctx, query,
+		uuid.New(), post.ContentID, post.TelegramLink, post.ChannelName, post.OriginalChannelName,
+		post.MessageID, post.Status,
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create post: %w", err)
 	}
-	defer rows.Close()
-
-	var mediaList []models.Media
-	for rows.Next() {
-		var media models.Media
-		var metadataJSON []byte
-
-		err := rows.Scan(
-			&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
-			&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
-			&media.DownloadedAt, &metadataJSON,
-		)
-		if err != nil {
-			return nil, err
-		}
 
-		// Unmarshal metadata if present
-		if len(metadataJSON) > 0 {
-			if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-			}
-		}
+	return p.GetPostByContentID(ctx, post.ContentID)
+}
 
-		mediaList = append(mediaList, media)
-	}
+// AddPostMediaStats atomically adds to the media count and total size of a post
+func (p *PostgresDB) AddPostMediaStats(ctx context.Context, contentID string, mediaCount int, totalSize int64) error {
+	query := `
+		UPDATE posts SET media_count = media_count + $2, total_size = total_size + $3
+		WHERE content_id = $1`
 
-	return mediaList, nil
+	_, err := p.pool.Exec(ctx, query, contentID, mediaCount, totalSize)
+	return err
 }
 
-func (p *PostgresDB) GetMediaByHash(ctx context.Context, hash string) (*models.Media, error) {
-	media := &models.Media{}
-	var metadataJSON []byte
+// CreateMediaUpload creates a new upload session
+func (p *PostgresDB) CreateMediaUpload(ctx context.Context, upload *models.MediaUpload) error {
+	upload.ID = uuid.New()
+	upload.CreatedAt = time.Now()
+	upload.UpdatedAt = time.Now()
 
 	query := `
-		SELECT id, media_id, content_id, telegram_file_id, file_name, original_file_name,
-			file_type, file_size, s3_bucket, s3_key, file_hash, downloaded_at, metadata
-		FROM media WHERE file_hash = $1
-		LIMIT 1`
+		INSERT INTO media_uploads (id, user_id, file_name, file_type, file_size, chunk_size, total_chunks,
+			s3_key, storage_upload_id, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
 
-	err := p.pool.QueryRow(ctx, query, hash).Scan(
-		&media.ID, &media.MediaID, &media.ContentID, &media.TelegramFileID, &media.FileName, &media.OriginalFileName,
-		&media.FileType, &media.FileSize, &media.S3Bucket, &media.S3Key, &media.FileHash,
-		&media.DownloadedAt, &metadataJSON,
-	)
+	return p.pool.QueryRow(ctx, query,
+		upload.ID, upload.UserID, upload.FileName, upload.FileType, upload.FileSize, upload.ChunkSize,
+		upload.TotalChunks, upload.S3Key, upload.StorageUploadID, upload.Status, upload.ExpiresAt,
+		upload.CreatedAt, upload.UpdatedAt,
+	).Scan(&upload.ID, &upload.CreatedAt, &upload.UpdatedAt)
+}
 
+// GetMediaUpload retrieves an upload session with its received chunks
+func (p *PostgresDB) GetMediaUpload(ctx context.Context, uploadID uuid.UUID) (*models.MediaUpload, error) {
+	upload := &models.MediaUpload{}
+	query := `
+		SELECT id, user_id, file_name, file_type, file_size, chunk_size, total_chunks, s3_key,
+			storage_upload_id, status, media_id, expires_at, created_at, updated_at, completed_at
+		FROM media_uploads WHERE id = $1`
+
+	err := p.pool.QueryRow(ctx, query, uploadID).Scan(
+		&upload.ID, &upload.UserID, &upload.FileName, &upload.FileType, &upload.FileSize, &upload.ChunkSize,
+		&upload.TotalChunks, &upload.S3Key, &upload.StorageUploadID, &upload.Status, &upload.MediaID,
+		&upload.ExpiresAt, &upload.CreatedAt, &upload.UpdatedAt, &upload.CompletedAt,
+	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -1149,405 +5654,435 @@ func (p *PostgresDB) GetMediaByHash(ctx context.Context, hash string) (*models.M
 		return nil, err
 	}
 
-	// Unmarshal metadata if present
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal(metadataJSON, &media.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
+	parts, err := p.GetMediaUploadParts(ctx, uploadID)
+	if err != nil {
+		return nil, err
 	}
 
-	return media, nil
+	upload.ReceivedChunks = make([]int, 0, len(parts))
+	for _, part := range parts {
+		upload.ReceivedChunks = append(upload.ReceivedChunks, part.PartNumber)
+		upload.BytesReceived += part.Size
+	}
+
+	return upload, nil
 }
 
-func (p *PostgresDB) UpdateMedia(ctx context.Context, media *models.Media) error {
-	// Convert metadata to JSON
-	metadataJSON, err := json.Marshal(media.Metadata)
+// GetMediaUploadParts retrieves the received chunks of an upload ordered by part number
+func (p *PostgresDB) GetMediaUploadParts(ctx context.Context, uploadID uuid.UUID) ([]models.MediaUploadPart, error) {
+	query := `
+		SELECT upload_id, part_number, etag, size, created_at
+		FROM media_upload_parts WHERE upload_id = $1
+		ORDER BY part_number ASC`
+
+	rows, err := p.pool.Query(ctx, query, uploadID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []models.MediaUploadPart
+	for rows.Next() {
+		var part models.MediaUploadPart
+		if err := rows.Scan(&part.UploadID, &part.PartNumber, &part.ETag, &part.Size, &part.CreatedAt); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
 	}
 
+	return parts, rows.Err()
+}
+
+// SaveMediaUploadPart records a received chunk, replacing a previous attempt with the same part number
+func (p *PostgresDB) SaveMediaUploadPart(ctx context.Context, part *models.MediaUploadPart) error {
+	part.CreatedAt = time.Now()
+
 	query := `
-		UPDATE media SET 
-			file_name = $2, original_file_name = $3, metadata = $4
-		WHERE media_id = $1`
+		INSERT INTO media_upload_parts (upload_id, part_number, etag, size, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (upload_id, part_number) DO UPDATE SET
+			etag = EXCLUDED.etag, size = EXCLUDED.size, created_at = EXCLUDED.created_at`
 
-	_, err = p.pool.Exec(ctx, query,
-		media.MediaID, media.FileName, media.OriginalFileName, metadataJSON,
-	)
+	_, err := p.pool.Exec(ctx, query, part.UploadID, part.PartNumber, part.ETag, part.Size, part.CreatedAt)
 	return err
 }
 
-func (p *PostgresDB) DeleteMedia(ctx context.Context, mediaID string) error {
-	query := `DELETE FROM media WHERE media_id = $1`
+// UpdateMediaUploadStatus updates the status of an upload session and the resulting media ID
+func (p *PostgresDB) UpdateMediaUploadStatus(ctx context.Context, uploadID uuid.UUID, status models.UploadStatus, mediaID *string) error {
+	query := `
+		UPDATE media_uploads SET
+			status = $2, media_id = $3,
+			completed_at = CASE WHEN $2 = 'completed'::upload_status THEN CURRENT_TIMESTAMP ELSE completed_at END
+		WHERE id = $1`
 
-	result, err := p.pool.Exec(ctx, query, mediaID)
+	result, err := p.pool.Exec(ctx, query, uploadID, status, mediaID)
 	if err != nil {
 		return err
 	}
-
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no media found with ID: %s", mediaID)
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no upload found with ID: %s", uploadID)
 	}
 
 	return nil
 }
 
-// GetMigrationStatus returns the current migration status
-func (p *PostgresDB) GetMigrationStatus(ctx context.Context) ([]Migration, error) {
-	// First check if migrations table exists
-	var tableExists bool
-	checkTableSQL := `
-		SELECT EXISTS (
-			SELECT FROM information_schema.tables 
-			WHERE table_name = 'schema_migrations'
-		)
-	`
-	err := p.pool.QueryRow(ctx, checkTableSQL).Scan(&tableExists)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check migrations table: %w", err)
-	}
-
-	if !tableExists {
-		return []Migration{}, nil
-	}
-
-	// Get applied migrations
+// GetExpiredMediaUploads retrieves in-progress uploads whose session has expired
+func (p *PostgresDB) GetExpiredMediaUploads(ctx context.Context, limit int) ([]models.MediaUpload, error) {
 	query := `
-		SELECT version, description, applied_at 
-		FROM schema_migrations 
-		ORDER BY version
-	`
-	rows, err := p.pool.Query(ctx, query)
+		SELECT id, user_id, file_name, file_type, file_size, chunk_size, total_chunks, s3_key,
+			storage_upload_id, status, media_id, expires_at, created_at, updated_at, completed_at
+		FROM media_uploads
+		WHERE status = 'in_progress' AND expires_at < CURRENT_TIMESTAMP
+		ORDER BY expires_at ASC
+		LIMIT $1`
+
+	rows, err := p.pool.Query(ctx, query, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query migrations: %w", err)
+		return nil, err
 	}
 	defer rows.Close()
 
-	var migrations []Migration
+	var uploads []models.MediaUpload
 	for rows.Next() {
-		var migration Migration
-		var appliedAt time.Time
-		err := rows.Scan(&migration.Version, &migration.Description, &appliedAt)
+		var upload models.MediaUpload
+		err := rows.Scan(
+			&upload.ID, &upload.UserID, &upload.FileName, &upload.FileType, &upload.FileSize, &upload.ChunkSize,
+			&upload.TotalChunks, &upload.S3Key, &upload.StorageUploadID, &upload.Status, &upload.MediaID,
+			&upload.ExpiresAt, &upload.CreatedAt, &upload.UpdatedAt, &upload.CompletedAt,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan migration row: %w", err)
+			return nil, err
 		}
-		migrations = append(migrations, migration)
+		uploads = append(uploads, upload)
 	}
 
-	return migrations, nil
+	return uploads, rows.Err()
 }
 
-// Transaction support
-func (p *PostgresDB) WithTransaction(ctx context.Context, fn func(tx pgx.Tx) error) error {
-	tx, err := p.pool.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// Media Library Operations
+
+// mediaSourceExpr derives the media source from metadata, Telegram media has no platform set
+const mediaSourceExpr = `COALESCE(m.metadata->>'platform', 'telegram')`
+
+// SearchMedia searches the media library with full-text search and filters
+func (p *PostgresDB) SearchMedia(ctx context.Context, scope models.MediaScope, filters models.MediaSearchFilters, pagination models.PaginationOptions, sort models.MediaSortOptions) ([]models.MediaSearchItem, int, error) {
+	// Set defaults
+	if pagination.Limit <= 0 {
+		pagination.Limit = 20
+	}
+	if pagination.Limit > 100 {
+		pagination.Limit = 100
 	}
+	if pagination.Page <= 0 {
+		pagination.Page = 1
+	}
+	offset := (pagination.Page - 1) * pagination.Limit
 
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback(ctx)
-		}
-	}()
+	// Build where clause
+	whereConditions := []string{"TRUE"}
+	args := []interface{}{}
+	argCount := 0
 
-	if err = fn(tx); err != nil {
-		return err
+	// Restrict to media visible to the user
+	if !scope.All {
+		argCount++
+		whereConditions = append(whereConditions, mediaVisibleCondition("m", argCount))
+		args = append(args, scope.UserID)
 	}
 
-	return tx.Commit(ctx)
-}
+	// Full-text search
+	rankExpr := "NULL::float8"
+	query := strings.TrimSpace(filters.Query)
+	if query != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.search_vector @@ websearch_to_tsquery('simple', $%d)", argCount))
+		rankExpr = fmt.Sprintf("ts_rank(m.search_vector, websearch_to_tsquery('simple', $%d))::float8", argCount)
+		args = append(args, query)
+	}
 
-// Health check
-func (p *PostgresDB) Ping(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-	return p.pool.Ping(ctx)
-}
+	// Tags filter
+	if len(filters.Tags) > 0 {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.tags ?& $%d", argCount))
+		args = append(args, filters.Tags)
+	}
 
-// Close connection
-func (p *PostgresDB) Close() {
-	p.pool.Close()
-	p.db.Close()
-}
+	// File type filter, either exact MIME types or families such as "video"
+	if len(filters.FileTypes) > 0 {
+		typeConditions := make([]string, len(filters.FileTypes))
+		for i, fileType := range filters.FileTypes {
+			argCount++
+			fileType = strings.ToLower(strings.TrimSpace(fileType))
+			if strings.Contains(fileType, "/") {
+				typeConditions[i] = fmt.Sprintf("LOWER(m.file_type) = $%d", argCount)
+				args = append(args, fileType)
+			} else {
+				if fileType == "photo" {
+					fileType = "image"
+				}
+				typeConditions[i] = fmt.Sprintf("LOWER(m.file_type) LIKE $%d", argCount)
+				args = append(args, fileType+"/%")
+			}
+		}
+		whereConditions = append(whereConditions, "("+strings.Join(typeConditions, " OR ")+")")
+	}
 
-// Show operations
-func (p *PostgresDB) CreateShow(ctx context.Context, show *models.Show) error {
-	show.ID = uuid.New()
-	show.CreatedAt = time.Now()
-	show.UpdatedAt = time.Now()
+	// Size filters
+	if filters.MinSize != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.file_size >= $%d", argCount))
+		args = append(args, *filters.MinSize)
+	}
+	if filters.MaxSize != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.file_size <= $%d", argCount))
+		args = append(args, *filters.MaxSize)
+	}
+
+	// Duration filters
+	if filters.MinDuration != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.duration_seconds >= $%d", argCount))
+		args = append(args, *filters.MinDuration)
+	}
+	if filters.MaxDuration != nil {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("m.duration_seconds <= $%d", argCount))
+		args = append(args, *filters.MaxDuration)
+	}
 
-	// Convert metadata to JSON
-	metadataJSON, err := json.Marshal(show.Metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+	// Date range filter
+	if filters.DateRange != nil {
+		if !filters.DateRange.Start.IsZero() {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("m.downloaded_at >= $%d", argCount))
+			args = append(args, filters.DateRange.Start)
+		}
+		if !filters.DateRange.End.IsZero() {
+			argCount++
+			whereConditions = append(whereConditions, fmt.Sprintf("m.downloaded_at <= $%d", argCount))
+			args = append(args, filters.DateRange.End)
+		}
 	}
 
-	// Convert scheduling config to JSON
-	var schedulingConfigJSON []byte
-	if show.SchedulingConfig != nil {
-		schedulingConfigJSON, err = json.Marshal(show.SchedulingConfig)
-		if err != nil {
-			return fmt.Errorf("failed to marshal scheduling config: %w", err)
+	// Source filter
+	if len(filters.Sources) > 0 {
+		sources := make([]string, len(filters.Sources))
+		for i, source := range filters.Sources {
+			sources[i] = string(source)
 		}
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf("%s = ANY($%d)", mediaSourceExpr, argCount))
+		args = append(args, sources)
 	}
 
-	query := `
-		INSERT INTO shows (id, show_name, youtube_key, additional_key, zoom_meeting_url, 
-			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
-			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
-			default_telegram, created_at, updated_at, status, user_id, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
-		RETURNING id, created_at, updated_at`
+	// Channel filter
+	if filters.ChannelName != "" {
+		argCount++
+		whereConditions = append(whereConditions, fmt.Sprintf(
+			"(LOWER(p.channel_name) = LOWER($%d) OR LOWER(p.original_channel_name) = LOWER($%d))", argCount, argCount))
+		args = append(args, filters.ChannelName)
+	}
 
-	err = p.pool.QueryRow(ctx, query,
-		show.ID, show.ShowName, show.YouTubeKey, show.AdditionalKey, show.ZoomMeetingURL,
-		show.ZoomMeetingID, show.ZoomPasscode, show.StartTime, show.LengthMinutes, show.FirstEventDate,
-		show.RepeatPattern, schedulingConfigJSON, show.DefaultHost, show.DefaultDirector, show.DefaultProducer,
-		show.DefaultTelegram, show.CreatedAt, show.UpdatedAt, show.Status, show.UserID, metadataJSON,
-	).Scan(&show.ID, &show.CreatedAt, &show.UpdatedAt)
+	whereClause := strings.Join(whereConditions, " AND ")
 
-	return err
-}
+	// Count total
+	var total int
+	countQuery := fmt.Sprintf(`
+		SELECT COUNT(*) FROM media m
+		JOIN posts p ON p.content_id = m.content_id
+		WHERE %s`, whereClause)
+	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
 
-func (p *PostgresDB) GetShowByID(ctx context.Context, showID uuid.UUID) (*models.Show, error) {
-	show := &models.Show{}
-	var metadataJSON []byte
-	var schedulingConfigJSON []byte
+	// Build order by clause
+	orderBy := "m.downloaded_at DESC" // default
+	if query != "" {
+		orderBy = "rank DESC, m.downloaded_at DESC"
+	}
+	if sort.Field != "" {
+		allowedFields := map[string]string{
+			"downloaded_at":    "m.downloaded_at",
+			"file_name":        "m.file_name",
+			"file_size":        "m.file_size",
+			"duration_seconds": "m.duration_seconds",
+		}
+		if query != "" {
+			allowedFields["rank"] = "rank"
+		}
+		if column, ok := allowedFields[sort.Field]; ok {
+			order := "ASC"
+			if strings.ToUpper(sort.Order) == "DESC" {
+				order = "DESC"
+			}
+			orderBy = fmt.Sprintf("%s %s NULLS LAST", column, order)
+		}
+	}
 
-	query := `
-		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
-			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
-			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
-			default_telegram, created_at, updated_at, status, user_id, metadata
-		FROM shows WHERE id = $1`
+	selectQuery := fmt.Sprintf(`
+		SELECT m.id, m.media_id, m.content_id, m.file_name, m.file_type, m.file_size,
+			m.duration_seconds, %s AS source, p.channel_name, m.metadata->>'title',
+			m.tags, m.notes, m.downloaded_at, %s AS rank
+		FROM media m
+		JOIN posts p ON p.content_id = m.content_id
+		WHERE %s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, mediaSourceExpr, rankExpr, whereClause, orderBy, argCount+1, argCount+2)
 
-	err := p.pool.QueryRow(ctx, query, showID).Scan(
-		&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
-		&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
-		&show.RepeatPattern, &schedulingConfigJSON, &show.DefaultHost, &show.DefaultDirector, &show.DefaultProducer,
-		&show.DefaultTelegram, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
-	)
+	args = append(args, pagination.Limit, offset)
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
-	}
+	rows, err := p.pool.Query(ctx, selectQuery, args...)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
+	defer rows.Close()
 
-	// Unmarshal metadata if present
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	items := []models.MediaSearchItem{}
+	for rows.Next() {
+		var item models.MediaSearchItem
+		var tagsJSON []byte
+
+		err := rows.Scan(
+			&item.ID, &item.MediaID, &item.ContentID, &item.FileName, &item.FileType, &item.FileSize,
+			&item.DurationSeconds, &item.Source, &item.ChannelName, &item.Title,
+			&tagsJSON, &item.Notes, &item.DownloadedAt, &item.Rank,
+		)
+		if err != nil {
+			return nil, 0, err
 		}
-	}
 
-	// Unmarshal scheduling config if present
-	if len(schedulingConfigJSON) > 0 {
-		show.SchedulingConfig = &models.SchedulingConfig{}
-		if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
+		item.Tags = []string{}
+		if len(tagsJSON) > 0 {
+			json.Unmarshal(tagsJSON, &item.Tags)
 		}
+
+		items = append(items, item)
 	}
 
-	return show, nil
+	return items, total, rows.Err()
 }
 
-func (p *PostgresDB) DeleteShow(ctx context.Context, showID uuid.UUID, force bool) error {
-	var query string
-	var result pgconn.CommandTag
-	var err error
-	
-	if force {
-		// Hard delete (permanent removal)
-		query = `DELETE FROM shows WHERE id = $1`
-		result, err = p.pool.Exec(ctx, query, showID)
-	} else {
-		// Soft delete by updating status
-		query = `UPDATE shows SET status = $2 WHERE id = $1`
-		result, err = p.pool.Exec(ctx, query, showID, models.ShowStatusCancelled)
+// ListMediaTags returns all tags used on media with their usage counts
+func (p *PostgresDB) ListMediaTags(ctx context.Context, scope models.MediaScope) ([]models.MediaTagCount, error) {
+	whereClause := ""
+	args := []interface{}{}
+	if !scope.All {
+		whereClause = "WHERE " + mediaVisibleCondition("m", 1)
+		args = append(args, scope.UserID)
 	}
+
+	query := `
+		SELECT tag, COUNT(*) AS count
+		FROM media m, jsonb_array_elements_text(COALESCE(m.tags, '[]'::jsonb)) AS tag
+		` + whereClause + `
+		GROUP BY tag
+		ORDER BY count DESC, tag ASC`
+
+	rows, err := p.pool.Query(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no show found with ID: %s", showID)
+	tags := []models.MediaTagCount{}
+	for rows.Next() {
+		var tag models.MediaTagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
 	}
 
-	return nil
+	return tags, rows.Err()
 }
 
+// GetMediaUUIDs resolves public media IDs to internal media UUIDs, preserving the input order.
+// Media outside the scope is reported as missing.
+func (p *PostgresDB) GetMediaUUIDs(ctx context.Context, scope models.MediaScope, mediaIDs []string) ([]uuid.UUID, error) {
+	query := `SELECT m.media_id, m.id FROM media m WHERE m.media_id = ANY($1)`
+	args := []interface{}{mediaIDs}
+	if !scope.All {
+		query += " AND " + mediaVisibleCondition("m", 2)
+		args = append(args, scope.UserID)
+	}
 
-func (p *PostgresDB) UpdateShow(ctx context.Context, show *models.Show) error {
-	// Convert metadata to JSON
-	metadataJSON, err := json.Marshal(show.Metadata)
+	rows, err := p.pool.Query(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Convert scheduling config to JSON
-	var schedulingConfigJSON []byte
-	if show.SchedulingConfig != nil {
-		schedulingConfigJSON, err = json.Marshal(show.SchedulingConfig)
-		if err != nil {
-			return fmt.Errorf("failed to marshal scheduling config: %w", err)
+	found := make(map[string]uuid.UUID, len(mediaIDs))
+	for rows.Next() {
+		var mediaID string
+		var id uuid.UUID
+		if err := rows.Scan(&mediaID, &id); err != nil {
+			return nil, err
 		}
+		found[mediaID] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	query := `
-		UPDATE shows SET 
-			show_name = COALESCE($2, show_name), 
-			youtube_key = COALESCE($3, youtube_key), 
-			additional_key = COALESCE($4, additional_key), 
-			zoom_meeting_url = COALESCE($5, zoom_meeting_url),
-			zoom_meeting_id = COALESCE($6, zoom_meeting_id), 
-			zoom_passcode = COALESCE($7, zoom_passcode), 
-			start_time = COALESCE($8, start_time), 
-			length_minutes = COALESCE($9, length_minutes),
-			first_event_date = COALESCE($10, first_event_date), 
-			repeat_pattern = COALESCE($11, repeat_pattern), 
-			scheduling_config = COALESCE($12, scheduling_config),
-			default_host = COALESCE($13, default_host),
-			default_director = COALESCE($14, default_director),
-			default_producer = COALESCE($15, default_producer),
-			default_telegram = COALESCE($16, default_telegram),
-			status = COALESCE($17, status), 
-			metadata = COALESCE($18, metadata),
-			updated_at = $19
-		WHERE id = $1`
-
-	_, err = p.pool.Exec(ctx, query,
-		show.ID, 
-		nullStringIfEmpty(show.ShowName), 
-		nullStringIfEmpty(show.YouTubeKey), 
-		show.AdditionalKey, 
-		show.ZoomMeetingURL,
-		show.ZoomMeetingID, 
-		show.ZoomPasscode, 
-		nullTimeIfZero(show.StartTime), 
-		nullIntIfZero(show.LengthMinutes),
-		nullTimeIfZero(show.FirstEventDate), 
-		nullStringIfEmpty(string(show.RepeatPattern)), 
-		schedulingConfigJSON,
-		show.DefaultHost,
-		show.DefaultDirector,
-		show.DefaultProducer,
-		show.DefaultTelegram,
-		nullStringIfEmpty(string(show.Status)), 
-		metadataJSON,
-		time.Now(),
-	)
-	return err
-}
-
-// Event operations
-
-func (p *PostgresDB) CreateEvent(ctx context.Context, event *models.Event) error {
-	event.ID = uuid.New()
-	event.CreatedAt = time.Now()
-	event.UpdatedAt = time.Now()
-	event.GeneratedAt = time.Now()
-
-	// Convert custom fields to JSON
-	var customFieldsJSON []byte
-	var err error
-	if event.CustomFields != nil {
-		customFieldsJSON, err = json.Marshal(event.CustomFields)
-		if err != nil {
-			return fmt.Errorf("failed to marshal custom fields: %w", err)
+	ids := make([]uuid.UUID, 0, len(mediaIDs))
+	for _, mediaID := range mediaIDs {
+		id, ok := found[mediaID]
+		if !ok {
+			return nil, fmt.Errorf("no media found with ID: %s", mediaID)
 		}
+		ids = append(ids, id)
 	}
 
-	query := `
-		INSERT INTO events (id, show_id, user_id, event_title, event_description, 
-			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
-			start_datetime, length_minutes, end_datetime, status, is_customized, 
-			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
-		RETURNING id, created_at, updated_at, generated_at`
-
-	err = p.pool.QueryRow(ctx, query,
-		event.ID, event.ShowID, event.UserID, event.EventTitle, event.EventDescription,
-		event.YouTubeKey, event.AdditionalKey, event.ZoomMeetingURL, event.ZoomMeetingID, event.ZoomPasscode,
-		event.StartDateTime, event.LengthMinutes, event.EndDateTime, event.Status, event.IsCustomized,
-		customFieldsJSON, event.GeneratedAt, event.LastSyncedAt, event.ShowVersion, event.CreatedAt, event.UpdatedAt,
-	).Scan(&event.ID, &event.CreatedAt, &event.UpdatedAt, &event.GeneratedAt)
-
-	return err
+	return ids, nil
 }
 
-func (p *PostgresDB) CreateEvents(ctx context.Context, events []models.Event) error {
-	if len(events) == 0 {
-		return nil
-	}
+// Media Collection Operations
 
-	// Use a transaction for batch insert
+// CreateMediaCollection creates a collection with optional initial media
+func (p *PostgresDB) CreateMediaCollection(ctx context.Context, collection *models.MediaCollection, mediaIDs []uuid.UUID) error {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
 	defer tx.Rollback(ctx)
 
-	query := `
-		INSERT INTO events (id, show_id, user_id, event_title, event_description, 
-			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
-			start_datetime, length_minutes, end_datetime, status, is_customized, 
-			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`
-
-	for i := range events {
-		event := &events[i]
-		event.ID = uuid.New()
-		event.CreatedAt = time.Now()
-		event.UpdatedAt = time.Now()
-		if event.GeneratedAt.IsZero() {
-			event.GeneratedAt = time.Now()
-		}
-
-		// Convert custom fields to JSON
-		var customFieldsJSON []byte
-		if event.CustomFields != nil {
-			customFieldsJSON, err = json.Marshal(event.CustomFields)
-			if err != nil {
-				return fmt.Errorf("failed to marshal custom fields: %w", err)
-			}
-		}
+	collection.ID = uuid.New()
+	collection.CreatedAt = time.Now()
+	collection.UpdatedAt = time.Now()
 
-		_, err = tx.Exec(ctx, query,
-			event.ID, event.ShowID, event.UserID, event.EventTitle, event.EventDescription,
-			event.YouTubeKey, event.AdditionalKey, event.ZoomMeetingURL, event.ZoomMeetingID, event.ZoomPasscode,
-			event.StartDateTime, event.LengthMinutes, event.EndDateTime, event.Status, event.IsCustomized,
-			customFieldsJSON, event.GeneratedAt, event.LastSyncedAt, event.ShowVersion, event.CreatedAt, event.UpdatedAt,
-		)
-		if err != nil {
-			return fmt.Errorf("failed to insert event %d: %w", i, err)
-		}
+	query := `
+		INSERT INTO media_collections (id, name, description, user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRow(ctx, query,
+		collection.ID, collection.Name, collection.Description, collection.UserID,
+		collection.CreatedAt, collection.UpdatedAt,
+	).Scan(&collection.ID, &collection.CreatedAt, &collection.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	if err := addMediaToCollection(ctx, tx, collection.ID, mediaIDs); err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetEventByID(ctx context.Context, eventID uuid.UUID) (*models.Event, error) {
-	event := &models.Event{}
-	var customFieldsJSON []byte
-
+// GetMediaCollection retrieves a collection by ID
+func (p *PostgresDB) GetMediaCollection(ctx context.Context, collectionID uuid.UUID) (*models.MediaCollection, error) {
+	collection := &models.MediaCollection{}
 	query := `
-		SELECT id, show_id, user_id, event_title, event_description, 
-			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
-			start_datetime, length_minutes, end_datetime, status, is_customized, 
-			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
-		FROM events WHERE id = $1`
+		SELECT id, name, description, user_id, created_at, updated_at
+		FROM media_collections WHERE id = $1`
 
-	err := p.pool.QueryRow(ctx, query, eventID).Scan(
-		&event.ID, &event.ShowID, &event.UserID, &event.EventTitle, &event.EventDescription,
-		&event.YouTubeKey, &event.AdditionalKey, &event.ZoomMeetingURL, &event.ZoomMeetingID, &event.ZoomPasscode,
-		&event.StartDateTime, &event.LengthMinutes, &event.EndDateTime, &event.Status, &event.IsCustomized,
-		&customFieldsJSON, &event.GeneratedAt, &event.LastSyncedAt, &event.ShowVersion, &event.CreatedAt, &event.UpdatedAt,
+	err := p.pool.QueryRow(ctx, query, collectionID).Scan(
+		&collection.ID, &collection.Name, &collection.Description, &collection.UserID,
+		&collection.CreatedAt, &collection.UpdatedAt,
 	)
-
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -1555,2448 +6090,4074 @@ func (p *PostgresDB) GetEventByID(ctx context.Context, eventID uuid.UUID) (*mode
 		return nil, err
 	}
 
-	// Unmarshal custom fields if present
-	if len(customFieldsJSON) > 0 {
-		if err := json.Unmarshal(customFieldsJSON, &event.CustomFields); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal custom fields: %w", err)
+	return collection, nil
+}
+
+// GetMediaCollectionItems retrieves the media of a collection in collection order
+func (p *PostgresDB) GetMediaCollectionItems(ctx context.Context, collectionID uuid.UUID) ([]models.MediaCollectionItem, error) {
+	query := fmt.Sprintf(`
+		SELECT m.id, m.media_id, m.content_id, m.file_name, m.file_type, m.file_size,
+			m.duration_seconds, %s AS source, p.channel_name, m.metadata->>'title',
+			m.tags, m.notes, m.downloaded_at, ci.order_index, ci.added_at
+		FROM media_collection_items ci
+		JOIN media m ON m.id = ci.media_id
+		JOIN posts p ON p.content_id = m.content_id
+		WHERE ci.collection_id = $1
+		ORDER BY ci.order_index ASC, ci.added_at ASC`, mediaSourceExpr)
+
+	rows, err := p.pool.Query(ctx, query, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.MediaCollectionItem{}
+	for rows.Next() {
+		var item models.MediaCollectionItem
+		var tagsJSON []byte
+
+		err := rows.Scan(
+			&item.ID, &item.MediaID, &item.ContentID, &item.FileName, &item.FileType, &item.FileSize,
+			&item.DurationSeconds, &item.Source, &item.ChannelName, &item.Title,
+			&tagsJSON, &item.Notes, &item.DownloadedAt, &item.OrderIndex, &item.AddedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Tags = []string{}
+		if len(tagsJSON) > 0 {
+			json.Unmarshal(tagsJSON, &item.Tags)
 		}
+
+		items = append(items, item)
 	}
 
-	return event, nil
+	return items, rows.Err()
 }
 
-func (p *PostgresDB) UpdateEvent(ctx context.Context, event *models.Event) error {
-	// Convert custom fields to JSON
-	var customFieldsJSON []byte
-	var err error
-	if event.CustomFields != nil {
-		customFieldsJSON, err = json.Marshal(event.CustomFields)
+// ListMediaCollections lists the collections of a user with media counts
+func (p *PostgresDB) ListMediaCollections(ctx context.Context, userID uuid.UUID) ([]models.MediaCollectionListItem, error) {
+	query := `
+		SELECT c.id, c.name, c.description, c.user_id, c.created_at, c.updated_at,
+			COUNT(m.id) AS media_count, COALESCE(SUM(m.file_size), 0) AS total_size
+		FROM media_collections c
+		LEFT JOIN media_collection_items ci ON ci.collection_id = c.id
+		LEFT JOIN media m ON m.id = ci.media_id
+		WHERE c.user_id = $1
+		GROUP BY c.id
+		ORDER BY c.name ASC`
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []models.MediaCollectionListItem{}
+	for rows.Next() {
+		var item models.MediaCollectionListItem
+		err := rows.Scan(
+			&item.ID, &item.Name, &item.Description, &item.UserID, &item.CreatedAt, &item.UpdatedAt,
+			&item.MediaCount, &item.TotalSize,
+		)
 		if err != nil {
-			return fmt.Errorf("failed to marshal custom fields: %w", err)
+			return nil, err
 		}
+		collections = append(collections, item)
 	}
 
+	return collections, rows.Err()
+}
+
+// UpdateMediaCollection updates the name and description of a collection
+func (p *PostgresDB) UpdateMediaCollection(ctx context.Context, collection *models.MediaCollection) error {
 	query := `
-		UPDATE events SET 
-			event_title = $2, event_description = $3, youtube_key = $4, additional_key = $5,
-			zoom_meeting_url = $6, zoom_meeting_id = $7, zoom_passcode = $8,
-			start_datetime = $9, length_minutes = $10, end_datetime = $11,
-			status = $12, is_customized = $13, custom_fields = $14, last_synced_at = $15
-		WHERE id = $1`
+		UPDATE media_collections SET name = $2, description = $3
+		WHERE id = $1
+		RETURNING updated_at`
 
-	now := time.Now()
-	_, err = p.pool.Exec(ctx, query,
-		event.ID, event.EventTitle, event.EventDescription, event.YouTubeKey, event.AdditionalKey,
-		event.ZoomMeetingURL, event.ZoomMeetingID, event.ZoomPasscode,
-		event.StartDateTime, event.LengthMinutes, event.EndDateTime,
-		event.Status, event.IsCustomized, customFieldsJSON, &now,
-	)
+	return p.pool.QueryRow(ctx, query, collection.ID, collection.Name, collection.Description).Scan(&collection.UpdatedAt)
+}
+
+// DeleteMediaCollection deletes a collection, the media itself is kept
+func (p *PostgresDB) DeleteMediaCollection(ctx context.Context, collectionID uuid.UUID) error {
+	result, err := p.pool.Exec(ctx, `DELETE FROM media_collections WHERE id = $1`, collectionID)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("no collection found with ID: %s", collectionID)
+	}
+
+	return nil
+}
+
+// AddMediaToCollection appends media to a collection, media already in it is skipped
+func (p *PostgresDB) AddMediaToCollection(ctx context.Context, collectionID uuid.UUID, mediaIDs []uuid.UUID) error {
+	return p.WithTransaction(ctx, func(tx pgx.Tx) error {
+		return addMediaToCollection(ctx, tx, collectionID, mediaIDs)
+	})
+}
+
+// RemoveMediaFromCollection removes media from a collection
+func (p *PostgresDB) RemoveMediaFromCollection(ctx context.Context, collectionID uuid.UUID, mediaIDs []uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		DELETE FROM media_collection_items
+		WHERE collection_id = $1 AND media_id = ANY($2)`, collectionID, mediaIDs)
 	return err
 }
 
+// AttachCollectionToBlock appends every media of a collection to a block after its existing media
+func (p *PostgresDB) AttachCollectionToBlock(ctx context.Context, collectionID, blockID uuid.UUID) (int, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the block so concurrent attachments do not collide on order_index
+	if _, err := tx.Exec(ctx, `SELECT id FROM blocks WHERE id = $1 FOR UPDATE`, blockID); err != nil {
+		return 0, fmt.Errorf("failed to lock block: %w", err)
+	}
 
-func (p *PostgresDB) GetEventsByShowID(ctx context.Context, showID uuid.UUID) ([]models.Event, error) {
-	query := `
-		SELECT id, show_id, user_id, event_title, event_description, 
-			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
-			start_datetime, length_minutes, end_datetime, status, is_customized, 
-			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
-		FROM events 
-		WHERE show_id = $1
-		ORDER BY start_datetime ASC`
+	var maxOrder sql.NullInt32
+	err = tx.QueryRow(ctx, `SELECT MAX(order_index) FROM block_media WHERE block_id = $1`, blockID).Scan(&maxOrder)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get max media order index: %w", err)
+	}
+	nextOrder := 0
+	if maxOrder.Valid {
+		nextOrder = int(maxOrder.Int32) + 1
+	}
 
-	rows, err := p.pool.Query(ctx, query, showID)
+	query := `
+		INSERT INTO block_media (block_id, media_id, media_type, title, order_index)
+		SELECT $1, m.id, split_part(m.file_type, '/', 1),
+			LEFT(COALESCE(m.metadata->>'title', m.original_file_name), 255),
+			$3 + (ROW_NUMBER() OVER (ORDER BY ci.order_index, ci.added_at))::int - 1
+		FROM media_collection_items ci
+		JOIN media m ON m.id = ci.media_id
+		WHERE ci.collection_id = $2`
+
+	result, err := tx.Exec(ctx, query, blockID, collectionID, nextOrder)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to attach collection media to block: %w", err)
 	}
-	defer rows.Close()
 
-	return p.scanEvents(rows)
+	return int(result.RowsAffected()), tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetFutureEvents(ctx context.Context, showID uuid.UUID) ([]models.Event, error) {
-	query := `
-		SELECT id, show_id, user_id, event_title, event_description, 
-			youtube_key, additional_key, zoom_meeting_url, zoom_meeting_id, zoom_passcode,
-			start_datetime, length_minutes, end_datetime, status, is_customized, 
-			custom_fields, generated_at, last_synced_at, show_version, created_at, updated_at
-		FROM events 
-		WHERE show_id = $1 AND start_datetime > $2 AND status != $3
-		ORDER BY start_datetime ASC`
+// addMediaToCollection appends media to a collection inside a transaction
+func addMediaToCollection(ctx context.Context, tx pgx.Tx, collectionID uuid.UUID, mediaIDs []uuid.UUID) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
 
-	rows, err := p.pool.Query(ctx, query, showID, time.Now(), models.EventStatusCancelled)
+	var maxOrder sql.NullInt32
+	err := tx.QueryRow(ctx, `
+		SELECT MAX(order_index) FROM media_collection_items WHERE collection_id = $1`,
+		collectionID).Scan(&maxOrder)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to get max collection order index: %w", err)
+	}
+	nextOrder := 0
+	if maxOrder.Valid {
+		nextOrder = int(maxOrder.Int32) + 1
+	}
+
+	for _, mediaID := range mediaIDs {
+		result, err := tx.Exec(ctx, `
+			INSERT INTO media_collection_items (collection_id, media_id, order_index)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (collection_id, media_id) DO NOTHING`,
+			collectionID, mediaID, nextOrder)
+		if err != nil {
+			return fmt.Errorf("failed to add media to collection: %w", err)
+		}
+		if result.RowsAffected() > 0 {
+			nextOrder++
+		}
 	}
-	defer rows.Close()
 
-	return p.scanEvents(rows)
+	return nil
 }
 
+// Media Archive Operations
 
+// GetEventArchiveMedia returns every media file attached to an event's blocks,
+// ordered by block order and then by the media order inside each block
+func (p *PostgresDB) GetEventArchiveMedia(ctx context.Context, eventID uuid.UUID) ([]models.EventArchiveMedia, error) {
+	query := `
+		SELECT b.id, b.title, b.order_index, bm.title, bm.description, bm.order_index,
+			m.id, m.media_id, m.content_id, m.file_name, m.original_file_name,
+			m.file_type, m.file_size, m.s3_bucket, m.s3_key, m.file_hash, m.downloaded_at
+		FROM blocks b
+		JOIN block_media bm ON bm.block_id = b.id
+		JOIN media m ON m.id = bm.media_id
+		WHERE b.event_id = $1
+		ORDER BY b.order_index, bm.order_index`
 
+	rows, err := p.pool.Query(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event media: %w", err)
+	}
+	defer rows.Close()
 
-// Helper function to scan events from rows
-func (p *PostgresDB) scanEvents(rows pgx.Rows) ([]models.Event, error) {
-	var events []models.Event
+	var items []models.EventArchiveMedia
 	for rows.Next() {
-		var event models.Event
-		var customFieldsJSON []byte
-
+		var item models.EventArchiveMedia
 		err := rows.Scan(
-			&event.ID, &event.ShowID, &event.UserID, &event.EventTitle, &event.EventDescription,
-			&event.YouTubeKey, &event.AdditionalKey, &event.ZoomMeetingURL, &event.ZoomMeetingID, &event.ZoomPasscode,
-			&event.StartDateTime, &event.LengthMinutes, &event.EndDateTime, &event.Status, &event.IsCustomized,
-			&customFieldsJSON, &event.GeneratedAt, &event.LastSyncedAt, &event.ShowVersion, &event.CreatedAt, &event.UpdatedAt,
+			&item.BlockID, &item.BlockTitle, &item.BlockOrderIndex,
+			&item.Title, &item.Description, &item.OrderIndex,
+			&item.Media.ID, &item.Media.MediaID, &item.Media.ContentID, &item.Media.FileName,
+			&item.Media.OriginalFileName, &item.Media.FileType, &item.Media.FileSize,
+			&item.Media.S3Bucket, &item.Media.S3Key, &item.Media.FileHash, &item.Media.DownloadedAt,
 		)
 		if err != nil {
-			return nil, err
-		}
-
-		// Unmarshal custom fields if present
-		if len(customFieldsJSON) > 0 {
-			if err := json.Unmarshal(customFieldsJSON, &event.CustomFields); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal custom fields: %w", err)
-			}
+			return nil, fmt.Errorf("failed to scan event media: %w", err)
 		}
-
-		events = append(events, event)
+		items = append(items, item)
 	}
 
-	return events, nil
+	return items, rows.Err()
 }
 
-// Event generation log operations
+// Share Link Operations
 
-func (p *PostgresDB) CreateEventGenerationLog(ctx context.Context, log *models.EventGenerationLog) error {
-	log.ID = uuid.New()
-	log.CreatedAt = time.Now()
-	if log.GenerationDate.IsZero() {
-		log.GenerationDate = time.Now()
-	}
+const shareLinkColumns = `
+		sl.id, sl.token_hash, sl.media_id, m.media_id, sl.collection_id, sl.created_by,
+		sl.password_hash, sl.expires_at, sl.max_downloads, sl.download_count,
+		sl.revoked_at, sl.revoked_by, sl.created_at, sl.updated_at`
 
-	query := `
-		INSERT INTO event_generation_logs (id, show_id, generation_date, events_generated, 
-			generated_until, trigger_reason, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at`
+func scanShareLink(row pgx.Row) (*models.ShareLink, error) {
+	var link models.ShareLink
+	err := row.Scan(
+		&link.ID, &link.TokenHash, &link.MediaUUID, &link.MediaID, &link.CollectionID, &link.CreatedBy,
+		&link.PasswordHash, &link.ExpiresAt, &link.MaxDownloads, &link.DownloadCount,
+		&link.RevokedAt, &link.RevokedBy, &link.CreatedAt, &link.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
 
-	err := p.pool.QueryRow(ctx, query,
-		log.ID, log.ShowID, log.GenerationDate, log.EventsGenerated,
-		log.GeneratedUntil, log.TriggerReason, log.CreatedAt,
-	).Scan(&log.ID, &log.CreatedAt)
+	link.Scope = models.ShareLinkScopeMedia
+	if link.CollectionID != nil {
+		link.Scope = models.ShareLinkScopeCollection
+	}
+	link.HasPassword = link.PasswordHash != nil
 
-	return err
+	return &link, nil
 }
 
-func (p *PostgresDB) GetLastGenerationDate(ctx context.Context, showID uuid.UUID) (time.Time, error) {
-	var lastDate time.Time
+// CreateShareLink stores a new share link
+func (p *PostgresDB) CreateShareLink(ctx context.Context, link *models.ShareLink) error {
+	link.ID = uuid.New()
+	link.CreatedAt = time.Now()
+	link.UpdatedAt = link.CreatedAt
+
 	query := `
-		SELECT generated_until 
-		FROM event_generation_logs 
-		WHERE show_id = $1 
-		ORDER BY generation_date DESC 
-		LIMIT 1`
+		INSERT INTO share_links (id, token_hash, media_id, collection_id, created_by,
+			password_hash, expires_at, max_downloads, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 
-	err := p.pool.QueryRow(ctx, query, showID).Scan(&lastDate)
-	if err == pgx.ErrNoRows {
-		// Return zero time if no logs found
-		return time.Time{}, nil
+	_, err := p.pool.Exec(ctx, query,
+		link.ID, link.TokenHash, link.MediaUUID, link.CollectionID, link.CreatedBy,
+		link.PasswordHash, link.ExpiresAt, link.MaxDownloads, link.CreatedAt, link.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create share link: %w", err)
 	}
-	return lastDate, err
+
+	link.HasPassword = link.PasswordHash != nil
+	return nil
 }
 
-// RESTful Event Operations
+// GetShareLink retrieves a share link by ID
+func (p *PostgresDB) GetShareLink(ctx context.Context, linkID uuid.UUID) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + `
+		FROM share_links sl
+		LEFT JOIN media m ON m.id = sl.media_id
+		WHERE sl.id = $1`
 
-// UpdateEventREST updates an event with the new RESTful format
-func (p *PostgresDB) UpdateEventREST(ctx context.Context, eventID uuid.UUID, req *models.UpdateEventRequestREST) (*models.Event, error) {
-	// Get existing event first
-	event, err := p.GetEventByID(ctx, eventID)
-	if err != nil {
-		return nil, err
+	link, err := scanShareLink(p.pool.QueryRow(ctx, query, linkID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
-	if event == nil {
-		return nil, fmt.Errorf("event not found")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
 	}
 
-	// Build dynamic update query
-	setParts := []string{}
-	args := []interface{}{}
-	argCount := 0
+	return link, nil
+}
 
-	if req.LengthMinutes != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("length_minutes = $%d", argCount))
-		args = append(args, *req.LengthMinutes)
-	}
+// GetShareLinkByTokenHash retrieves a share link by the hash of its token
+func (p *PostgresDB) GetShareLinkByTokenHash(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + `
+		FROM share_links sl
+		LEFT JOIN media m ON m.id = sl.media_id
+		WHERE sl.token_hash = $1`
 
-	if req.EventName != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("event_title = $%d", argCount))
-		args = append(args, *req.EventName)
+	link, err := scanShareLink(p.pool.QueryRow(ctx, query, tokenHash))
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
-
-	if req.EventDate != nil && req.EventTime != nil {
-		// Parse and combine date and time
-		dateTimeStr := *req.EventDate + "T" + *req.EventTime
-		startDateTime, err := time.Parse("2006-01-02T15:04:05", dateTimeStr)
-		if err != nil {
-			return nil, fmt.Errorf("invalid date/time format: %w", err)
-		}
-		
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("start_datetime = $%d", argCount))
-		args = append(args, startDateTime)
-		
-		// Update end time if length changed
-		lengthMinutes := event.LengthMinutes
-		if req.LengthMinutes != nil {
-			lengthMinutes = req.LengthMinutes
-		}
-		if lengthMinutes != nil {
-			endDateTime := startDateTime.Add(time.Duration(*lengthMinutes) * time.Minute)
-			argCount++
-			setParts = append(setParts, fmt.Sprintf("end_datetime = $%d", argCount))
-			args = append(args, endDateTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link: %w", err)
+	}
+
+	return link, nil
+}
+
+// ListShareLinks lists the share links created by a user, newest first
+func (p *PostgresDB) ListShareLinks(ctx context.Context, userID uuid.UUID) ([]models.ShareLink, error) {
+	query := `SELECT ` + shareLinkColumns + `
+		FROM share_links sl
+		LEFT JOIN media m ON m.id = sl.media_id
+		WHERE sl.created_by = $1
+		ORDER BY sl.created_at DESC`
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.ShareLink{}
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link: %w", err)
 		}
+		links = append(links, *link)
 	}
 
-	if req.YouTubeKey != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("youtube_key = $%d", argCount))
-		args = append(args, *req.YouTubeKey)
+	return links, rows.Err()
+}
+
+// ConsumeShareLinkDownload counts a download against the link's limit, once per
+// client session. A session seen after activeSince continues without being counted again.
+// It returns false when the link is revoked, expired or has no downloads left.
+func (p *PostgresDB) ConsumeShareLinkDownload(ctx context.Context, linkID uuid.UUID, sessionKey string, activeSince time.Time) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	if req.ZoomMeetingURL != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("zoom_meeting_url = $%d", argCount))
-		args = append(args, *req.ZoomMeetingURL)
+	// Lock the link so concurrent requests can't both take its last download
+	var downloadCount int
+	var maxDownloads *int
+	err = tx.QueryRow(ctx, `
+		SELECT download_count, max_downloads
+		FROM share_links
+		WHERE id = $1
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > NOW())
+		FOR UPDATE`, linkID).Scan(&downloadCount, &maxDownloads)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get share link: %w", err)
 	}
 
-	if req.Host != nil {
-		// Convert string UUIDs to UUID array
-		hostUUIDs := make([]uuid.UUID, len(req.Host))
-		for i, hostStr := range req.Host {
-			hostUUID, err := uuid.Parse(hostStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid host UUID %s: %w", hostStr, err)
-			}
-			hostUUIDs[i] = hostUUID
-		}
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("host = $%d", argCount))
-		args = append(args, hostUUIDs)
+	result, err := tx.Exec(ctx, `
+		UPDATE share_link_download_sessions
+		SET last_seen_at = NOW()
+		WHERE share_link_id = $1 AND session_key = $2 AND last_seen_at > $3`,
+		linkID, sessionKey, activeSince)
+	if err != nil {
+		return false, fmt.Errorf("failed to update share link download session: %w", err)
+	}
+	if result.RowsAffected() == 1 {
+		return true, tx.Commit(ctx)
 	}
 
-	if req.Director != nil {
-		directorUUIDs := make([]uuid.UUID, len(req.Director))
-		for i, directorStr := range req.Director {
-			directorUUID, err := uuid.Parse(directorStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid director UUID %s: %w", directorStr, err)
-			}
-			directorUUIDs[i] = directorUUID
-		}
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("director = $%d", argCount))
-		args = append(args, directorUUIDs)
+	if maxDownloads != nil && downloadCount >= *maxDownloads {
+		return false, nil
 	}
 
-	if req.Producer != nil {
-		producerUUIDs := make([]uuid.UUID, len(req.Producer))
-		for i, producerStr := range req.Producer {
-			producerUUID, err := uuid.Parse(producerStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid producer UUID %s: %w", producerStr, err)
-			}
-			producerUUIDs[i] = producerUUID
-		}
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("producer = $%d", argCount))
-		args = append(args, producerUUIDs)
+	_, err = tx.Exec(ctx, `
+		UPDATE share_links SET download_count = download_count + 1 WHERE id = $1`, linkID)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume share link download: %w", err)
 	}
 
-	if req.Telegram != nil {
-		argCount++
-		setParts = append(setParts, fmt.Sprintf("telegram = $%d", argCount))
-		args = append(args, *req.Telegram)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO share_link_download_sessions (share_link_id, session_key, started_at, last_seen_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (share_link_id, session_key)
+		DO UPDATE SET started_at = NOW(), last_seen_at = NOW()`,
+		linkID, sessionKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to start share link download session: %w", err)
 	}
 
-	if len(setParts) == 0 {
-		return event, nil // No changes
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit share link download: %w", err)
 	}
+	return true, nil
+}
 
-	// Always update the updated_at timestamp
-	argCount++
-	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argCount))
-	args = append(args, time.Now())
+// RevokeShareLink marks a share link as revoked
+func (p *PostgresDB) RevokeShareLink(ctx context.Context, linkID, revokedBy uuid.UUID) error {
+	query := `
+		UPDATE share_links
+		SET revoked_at = NOW(), revoked_by = $2
+		WHERE id = $1 AND revoked_at IS NULL`
 
-	// Mark as customized
-	argCount++
-	setParts = append(setParts, fmt.Sprintf("is_customized = $%d", argCount))
-	args = append(args, true)
+	_, err := p.pool.Exec(ctx, query, linkID, revokedBy)
+	if err != nil {
+		return fmt.Errorf("failed to revoke share link: %w", err)
+	}
 
-	// Add WHERE clause
-	argCount++
-	args = append(args, eventID)
+	return nil
+}
 
-	query := fmt.Sprintf("UPDATE events SET %s WHERE id = $%d", strings.Join(setParts, ", "), argCount)
+// RecordShareLinkAccess writes an access audit record for a share link
+func (p *PostgresDB) RecordShareLinkAccess(ctx context.Context, access *models.ShareLinkAccess) error {
+	access.ID = uuid.New()
+	access.AccessedAt = time.Now()
 
-	_, err = p.pool.Exec(ctx, query, args...)
+	query := `
+		INSERT INTO share_link_accesses (id, share_link_id, media_id, granted, reason,
+			ip_address, user_agent, range_header, accessed_at)
+		VALUES ($1, $2, $3, $4, $5, $6::inet, $7, $8, $9)`
+
+	_, err := p.pool.Exec(ctx, query,
+		access.ID, access.ShareLinkID, access.MediaUUID, access.Granted, access.Reason,
+		access.IPAddress, access.UserAgent, access.RangeHeader, access.AccessedAt,
+	)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to record share link access: %w", err)
 	}
 
-	// Return updated event
-	return p.GetEventByID(ctx, eventID)
+	return nil
 }
 
-// DeleteEventREST deletes an event (soft delete by default)
-func (p *PostgresDB) DeleteEventREST(ctx context.Context, eventID uuid.UUID, force bool) error {
-	if force {
-		// Hard delete
-		query := "DELETE FROM events WHERE id = $1"
-		_, err := p.pool.Exec(ctx, query, eventID)
-		return err
-	} else {
-		// Soft delete - set status to cancelled
-		query := "UPDATE events SET status = $1, updated_at = $2 WHERE id = $3"
-		_, err := p.pool.Exec(ctx, query, models.EventStatusCancelled, time.Now(), eventID)
-		return err
+// ListShareLinkAccesses returns the most recent accesses of a share link
+func (p *PostgresDB) ListShareLinkAccesses(ctx context.Context, linkID uuid.UUID, limit int) ([]models.ShareLinkAccess, error) {
+	query := `
+		SELECT a.id, a.share_link_id, a.media_id, m.media_id, a.granted, a.reason,
+			host(a.ip_address), a.user_agent, a.range_header, a.accessed_at
+		FROM share_link_accesses a
+		LEFT JOIN media m ON m.id = a.media_id
+		WHERE a.share_link_id = $1
+		ORDER BY a.accessed_at DESC
+		LIMIT $2`
+
+	rows, err := p.pool.Query(ctx, query, linkID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share link accesses: %w", err)
+	}
+	defer rows.Close()
+
+	accesses := []models.ShareLinkAccess{}
+	for rows.Next() {
+		var access models.ShareLinkAccess
+		err := rows.Scan(
+			&access.ID, &access.ShareLinkID, &access.MediaUUID, &access.MediaID, &access.Granted, &access.Reason,
+			&access.IPAddress, &access.UserAgent, &access.RangeHeader, &access.AccessedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share link access: %w", err)
+		}
+		accesses = append(accesses, access)
 	}
+
+	return accesses, rows.Err()
 }
 
-// GetEventREST gets an event with full details for RESTful response
-func (p *PostgresDB) GetEventREST(ctx context.Context, eventID uuid.UUID) (*models.EventDetailREST, error) {
-	// Get the event
-	event, err := p.GetEventByID(ctx, eventID)
+// IsMediaInCollection reports whether a media file belongs to a collection
+func (p *PostgresDB) IsMediaInCollection(ctx context.Context, collectionID, mediaID uuid.UUID) (bool, error) {
+	var exists bool
+	err := p.pool.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM media_collection_items WHERE collection_id = $1 AND media_id = $2)`,
+		collectionID, mediaID,
+	).Scan(&exists)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to check collection membership: %w", err)
 	}
-	if event == nil {
-		return nil, nil
+
+	return exists, nil
+}
+
+// Media Ownership Operations
+
+// visibleOwners lists the user bound to the given placeholder and everyone who shares a team with them
+func visibleOwners(arg int) string {
+	return fmt.Sprintf(`(SELECT $%[1]d::uuid UNION
+		SELECT tm2.user_id FROM team_members tm1
+		JOIN team_members tm2 ON tm2.team_id = tm1.team_id
+		WHERE tm1.user_id = $%[1]d)`, arg)
+}
+
+// postVisibleCondition matches posts grabbed by the user or one of their team mates
+func postVisibleCondition(alias string, arg int) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM post_grabs vpg WHERE vpg.post_id = %s.id AND vpg.user_id IN %s)`,
+		alias, visibleOwners(arg))
+}
+
+// mediaVisibleCondition matches media stored by the user or a team mate, and media of posts
+// they grabbed. The synthetic upload post is never shared through grabs.
+func mediaVisibleCondition(alias string, arg int) string {
+	owners := visibleOwners(arg)
+	return fmt.Sprintf(`(%[1]s.user_id IN %[2]s OR EXISTS (
+		SELECT 1 FROM posts vp JOIN post_grabs vpg ON vpg.post_id = vp.id
+		WHERE vp.content_id = %[1]s.content_id AND vp.content_id <> '%[3]s' AND vpg.user_id IN %[2]s))`,
+		alias, owners, models.UploadContentID)
+}
+
+// RecordPostGrab records that a user requested a post
+func (p *PostgresDB) RecordPostGrab(ctx context.Context, postID, userID uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO post_grabs (post_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (post_id, user_id) DO NOTHING`,
+		postID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record post grab: %w", err)
 	}
 
-	// Get show details
-	show, err := p.GetShowByID(ctx, event.ShowID)
+	return nil
+}
+
+// CanAccessPost reports whether a post is visible within the scope
+func (p *PostgresDB) CanAccessPost(ctx context.Context, scope models.MediaScope, postID uuid.UUID) (bool, error) {
+	if scope.All {
+		return true, nil
+	}
+
+	var visible bool
+	query := `SELECT ` + postVisibleCondition("p", 2) + ` FROM posts p WHERE p.id = $1`
+	err := p.pool.QueryRow(ctx, query, postID, scope.UserID).Scan(&visible)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to check post access: %w", err)
 	}
 
-	// Get host details
-	hostSummaries, err := p.getUserSummaries(ctx, event.Host)
+	return visible, nil
+}
+
+// CanAccessMedia reports whether a media file is visible within the scope
+func (p *PostgresDB) CanAccessMedia(ctx context.Context, scope models.MediaScope, mediaID uuid.UUID) (bool, error) {
+	if scope.All {
+		return true, nil
+	}
+
+	var visible bool
+	query := `SELECT ` + mediaVisibleCondition("m", 2) + ` FROM media m WHERE m.id = $1`
+	err := p.pool.QueryRow(ctx, query, mediaID, scope.UserID).Scan(&visible)
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to check media access: %w", err)
 	}
 
-	// Get director details
-	directorSummaries, err := p.getUserSummaries(ctx, event.Director)
+	return visible, nil
+}
+
+// GetMediaUsage returns the bytes and number of media files stored by a user
+func (p *PostgresDB) GetMediaUsage(ctx context.Context, userID uuid.UUID) (int64, int, error) {
+	var usedBytes int64
+	var usedFiles int
+	err := p.pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(file_size), 0), COUNT(*) FROM media WHERE user_id = $1`,
+		userID,
+	).Scan(&usedBytes, &usedFiles)
 	if err != nil {
-		return nil, err
+		return 0, 0, fmt.Errorf("failed to get media usage: %w", err)
 	}
 
-	// Get producer details
-	producerSummaries, err := p.getUserSummaries(ctx, event.Producer)
+	return usedBytes, usedFiles, nil
+}
+
+// GetUserMediaQuota returns the quota set directly on a user
+func (p *PostgresDB) GetUserMediaQuota(ctx context.Context, userID uuid.UUID) (*models.MediaQuota, error) {
+	var quota models.MediaQuota
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, user_id, role_id, max_bytes, max_files, created_at, updated_at
+		FROM media_quotas WHERE user_id = $1`,
+		userID,
+	).Scan(&quota.ID, &quota.UserID, &quota.RoleID, &quota.MaxBytes, &quota.MaxFiles, &quota.CreatedAt, &quota.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get user media quota: %w", err)
 	}
 
-	// Get blocks
-	blocks, err := p.GetEventBlocks(ctx, eventID)
+	return &quota, nil
+}
+
+// GetRoleMediaQuota combines the quotas of a user's active roles into the most
+// generous limits. It returns nil when none of the roles has a quota.
+func (p *PostgresDB) GetRoleMediaQuota(ctx context.Context, userID uuid.UUID) (*models.MediaQuota, error) {
+	var quotaCount int
+	var unlimitedBytes, unlimitedFiles bool
+	var maxBytes *int64
+	var maxFiles *int
+
+	err := p.pool.QueryRow(ctx, `
+		SELECT COUNT(q.id),
+			COALESCE(bool_or(q.max_bytes IS NULL), false), MAX(q.max_bytes),
+			COALESCE(bool_or(q.max_files IS NULL), false), MAX(q.max_files)
+		FROM media_quotas q
+		JOIN user_roles ur ON ur.role_id = q.role_id
+		JOIN roles r ON r.id = q.role_id
+		WHERE ur.user_id = $1 AND r.status = 'active'`,
+		userID,
+	).Scan(&quotaCount, &unlimitedBytes, &maxBytes, &unlimitedFiles, &maxFiles)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get role media quota: %w", err)
+	}
+	if quotaCount == 0 {
+		return nil, nil
 	}
 
-	// Convert to REST format
-	blockSummaries := make([]models.BlockSummaryREST, len(blocks))
-	for i, block := range blocks {
-		blockSummaries[i] = models.BlockSummaryREST{
-			ID:              block.ID,
-			Title:           block.Title,
-			OrderIndex:      block.OrderIndex,
-			EstimatedLength: block.EstimatedLength,
-		}
+	quota := &models.MediaQuota{UserID: &userID}
+	if !unlimitedBytes {
+		quota.MaxBytes = maxBytes
+	}
+	if !unlimitedFiles {
+		quota.MaxFiles = maxFiles
 	}
 
-	// Determine event name (use event title if set, otherwise show name)
-	eventName := show.ShowName
-	if event.EventTitle != nil && *event.EventTitle != "" {
-		eventName = *event.EventTitle
+	return quota, nil
+}
+
+// SetMediaQuota creates or replaces the quota of a user or a role
+func (p *PostgresDB) SetMediaQuota(ctx context.Context, quota *models.MediaQuota) error {
+	conflictColumn := "user_id"
+	if quota.RoleID != nil {
+		conflictColumn = "role_id"
 	}
 
-	showSummary := &models.ShowSummary{
-		ID:            show.ID,
-		ShowName:      show.ShowName,
-		RepeatPattern: show.RepeatPattern,
-		Status:        show.Status,
+	query := fmt.Sprintf(`
+		INSERT INTO media_quotas (id, user_id, role_id, max_bytes, max_files)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (%s) DO UPDATE
+		SET max_bytes = EXCLUDED.max_bytes, max_files = EXCLUDED.max_files
+		RETURNING id, created_at, updated_at`, conflictColumn)
+
+	err := p.pool.QueryRow(ctx, query,
+		uuid.New(), quota.UserID, quota.RoleID, quota.MaxBytes, quota.MaxFiles,
+	).Scan(&quota.ID, &quota.CreatedAt, &quota.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set media quota: %w", err)
 	}
 
-	// Determine length (use event length if set, otherwise show length)
-	lengthMinutes := show.LengthMinutes
-	if event.LengthMinutes != nil {
-		lengthMinutes = *event.LengthMinutes
+	return nil
+}
+
+// DeleteMediaQuota removes the quota of a user or a role, returning false if none was set
+func (p *PostgresDB) DeleteMediaQuota(ctx context.Context, userID, roleID *uuid.UUID) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		DELETE FROM media_quotas
+		WHERE ($1::uuid IS NOT NULL AND user_id = $1) OR ($2::uuid IS NOT NULL AND role_id = $2)`,
+		userID, roleID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete media quota: %w", err)
 	}
 
-	return &models.EventDetailREST{
-		ID:            event.ID,
-		EventName:     eventName,
-		EventDate:     event.StartDateTime,
-		LengthMinutes: lengthMinutes,
-		Status:        event.Status,
-		YouTubeKey:    event.YouTubeKey,
-		ZoomMeetingURL: event.ZoomMeetingURL,
-		Host:          hostSummaries,
-		Director:      directorSummaries,
-		Producer:      producerSummaries,
-		Telegram:      event.Telegram,
-		Show:          showSummary,
-		Blocks:        blockSummaries,
-		CreatedAt:     event.CreatedAt,
-		UpdatedAt:     event.UpdatedAt,
-	}, nil
+	return result.RowsAffected() > 0, nil
 }
 
-// ListEventsREST returns paginated events for a user with enhanced filtering
-func (p *PostgresDB) ListEventsREST(ctx context.Context, userID uuid.UUID, eventYear, eventMonth, eventWeek *int, status models.EventStatus, showID, search string, sortField, sortOrder string, limit, offset int) ([]models.EventListItemREST, int, *models.EventFiltersREST, error) {
-	// Build where clause
-	whereConditions := []string{"e.user_id = $1"}
-	args := []interface{}{userID}
-	argCount := 1
+// Team Operations
+
+// CreateTeam creates a team with its creator as the first member
+func (p *PostgresDB) CreateTeam(ctx context.Context, team *models.Team) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	team.ID = uuid.New()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO teams (id, name, description, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at, updated_at`,
+		team.ID, team.Name, team.Description, team.CreatedBy,
+	).Scan(&team.CreatedAt, &team.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create team: %w", err)
+	}
+
+	if team.CreatedBy != nil {
+		_, err = tx.Exec(ctx, `INSERT INTO team_members (team_id, user_id) VALUES ($1, $2)`, team.ID, *team.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("failed to add team creator: %w", err)
+		}
+	}
 
-	// Year filter
-	if eventYear != nil {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(YEAR FROM e.start_datetime) = $%d", argCount))
-		args = append(args, *eventYear)
+	return tx.Commit(ctx)
+}
+
+// GetTeam retrieves a team by ID
+func (p *PostgresDB) GetTeam(ctx context.Context, teamID uuid.UUID) (*models.Team, error) {
+	var team models.Team
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, name, description, created_by, created_at, updated_at
+		FROM teams WHERE id = $1`,
+		teamID,
+	).Scan(&team.ID, &team.Name, &team.Description, &team.CreatedBy, &team.CreatedAt, &team.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team: %w", err)
 	}
 
-	// Month filter
-	if eventMonth != nil {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(MONTH FROM e.start_datetime) = $%d", argCount))
-		args = append(args, *eventMonth)
+	return &team, nil
+}
+
+// ListUserTeams lists the teams a user is a member of
+func (p *PostgresDB) ListUserTeams(ctx context.Context, userID uuid.UUID) ([]models.Team, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT t.id, t.name, t.description, t.created_by, t.created_at, t.updated_at
+		FROM teams t
+		JOIN team_members tm ON tm.team_id = t.id
+		WHERE tm.user_id = $1
+		ORDER BY t.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list teams: %w", err)
 	}
+	defer rows.Close()
 
-	// Week filter (ISO week)
-	if eventWeek != nil {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("EXTRACT(WEEK FROM e.start_datetime) = $%d", argCount))
-		args = append(args, *eventWeek)
+	teams := []models.Team{}
+	for rows.Next() {
+		var team models.Team
+		if err := rows.Scan(&team.ID, &team.Name, &team.Description, &team.CreatedBy, &team.CreatedAt, &team.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan team: %w", err)
+		}
+		teams = append(teams, team)
 	}
 
-	// Status filter
-	if status != "" {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("e.status = $%d", argCount))
-		args = append(args, status)
+	return teams, rows.Err()
+}
+
+// GetTeamMembers lists the members of a team
+func (p *PostgresDB) GetTeamMembers(ctx context.Context, teamID uuid.UUID) ([]models.TeamMember, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT u.id, u.name, u.surname, u.email, tm.created_at
+		FROM team_members tm
+		JOIN users u ON u.id = tm.user_id
+		WHERE tm.team_id = $1
+		ORDER BY tm.created_at`,
+		teamID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team members: %w", err)
 	}
+	defer rows.Close()
 
-	// Show ID filter
-	if showID != "" {
-		showUUID, err := uuid.Parse(showID)
-		if err != nil {
-			return nil, 0, nil, fmt.Errorf("invalid show ID: %w", err)
+	members := []models.TeamMember{}
+	for rows.Next() {
+		var member models.TeamMember
+		if err := rows.Scan(&member.UserID, &member.Name, &member.Surname, &member.Email, &member.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan team member: %w", err)
 		}
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("e.show_id = $%d", argCount))
-		args = append(args, showUUID)
+		members = append(members, member)
 	}
 
-	// Search filter
-	if search != "" {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("(LOWER(COALESCE(e.event_title, s.show_name)) LIKE LOWER($%d))", argCount))
-		args = append(args, "%"+search+"%")
+	return members, rows.Err()
+}
+
+// IsTeamMember reports whether a user belongs to a team
+func (p *PostgresDB) IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := p.pool.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM team_members WHERE team_id = $1 AND user_id = $2)`,
+		teamID, userID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check team membership: %w", err)
 	}
 
-	whereClause := strings.Join(whereConditions, " AND ")
+	return exists, nil
+}
 
-	// Count total
-	var total int
-	countQuery := fmt.Sprintf(`
-		SELECT COUNT(*) 
-		FROM events e 
-		JOIN shows s ON e.show_id = s.id 
-		WHERE %s`, whereClause)
-	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, nil, err
+// AddTeamMember adds a user to a team
+func (p *PostgresDB) AddTeamMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO team_members (team_id, user_id) VALUES ($1, $2)
+		ON CONFLICT (team_id, user_id) DO NOTHING`,
+		teamID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add team member: %w", err)
 	}
 
-	// Build order by clause
-	orderBy := "e.start_datetime ASC" // default
-	if sortField != "" {
-		allowedFields := map[string]string{
-			"event_date":   "e.start_datetime",
-			"event_name":   "COALESCE(e.event_title, s.show_name)",
-			"created_at":   "e.created_at",
-		}
-		if dbField, exists := allowedFields[sortField]; exists {
-			order := "ASC"
-			if strings.ToUpper(sortOrder) == "DESC" {
-				order = "DESC"
-			}
-			orderBy = fmt.Sprintf("%s %s", dbField, order)
-		}
+	return nil
+}
+
+// RemoveTeamMember removes a user from a team
+func (p *PostgresDB) RemoveTeamMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `DELETE FROM team_members WHERE team_id = $1 AND user_id = $2`, teamID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove team member: %w", err)
 	}
 
-	// Get events
-	query := fmt.Sprintf(`
-		SELECT e.id, COALESCE(e.event_title, s.show_name) as event_name, e.start_datetime,
-			COALESCE(e.length_minutes, s.length_minutes) as length_minutes, e.status,
-			s.id as show_id, s.show_name, s.repeat_pattern, s.status as show_status,
-			CASE WHEN e.zoom_meeting_url IS NOT NULL AND e.zoom_meeting_url != '' THEN true ELSE false END as has_zoom,
-			COALESCE(array_length(e.host, 1), 0) as host_count
-		FROM events e 
-		JOIN shows s ON e.show_id = s.id 
-		WHERE %s
-		ORDER BY %s
-		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
+	return nil
+}
 
-	args = append(args, limit, offset)
+// JWT Signing Key Operations
 
-	rows, err := p.pool.Query(ctx, query, args...)
+// ListJWTSigningKeys returns the active and previous JWT signing keys, newest first
+func (p *PostgresDB) ListJWTSigningKeys(ctx context.Context) ([]models.JWTSigningKey, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, kid, algorithm, private_key, public_key, status, created_at, rotated_at, retired_at
+		FROM jwt_signing_keys
+		WHERE status <> 'retired'
+		ORDER BY created_at DESC`)
 	if err != nil {
-		return nil, 0, nil, err
+		return nil, fmt.Errorf("failed to list JWT signing keys: %w", err)
 	}
 	defer rows.Close()
 
-	var events []models.EventListItemREST
+	keys := []models.JWTSigningKey{}
 	for rows.Next() {
-		var item models.EventListItemREST
-		var show models.ShowSummary
+		var key models.JWTSigningKey
+		if err := rows.Scan(&key.ID, &key.KID, &key.Algorithm, &key.PrivateKey, &key.PublicKey,
+			&key.Status, &key.CreatedAt, &key.RotatedAt, &key.RetiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan JWT signing key: %w", err)
+		}
+		keys = append(keys, key)
+	}
 
-		err := rows.Scan(
-			&item.ID, &item.EventName, &item.EventDate, &item.LengthMinutes, &item.Status,
-			&show.ID, &show.ShowName, &show.RepeatPattern, &show.Status,
-			&item.HasZoom, &item.HostCount,
+	return keys, rows.Err()
+}
+
+// RotateJWTSigningKey demotes the active key identified by currentKID to previous and
+// stores key as the new active key. An empty currentKID installs the first key.
+// It returns false without changes when currentKID is no longer the active key,
+// which happens when another instance rotated first.
+func (p *PostgresDB) RotateJWTSigningKey(ctx context.Context, currentKID string, key *models.JWTSigningKey) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if currentKID != "" {
+		result, err := tx.Exec(ctx, `
+			UPDATE jwt_signing_keys SET status = 'previous', rotated_at = NOW()
+			WHERE kid = $1 AND status = 'active'`,
+			currentKID,
 		)
 		if err != nil {
-			return nil, 0, nil, err
+			return false, fmt.Errorf("failed to demote JWT signing key: %w", err)
 		}
+		if result.RowsAffected() == 0 {
+			return false, nil
+		}
+	}
 
-		item.Show = &show
-		
-		// Get block count
-		blockCount, err := p.getEventBlockCount(ctx, item.ID)
-		if err != nil {
-			return nil, 0, nil, err
+	key.ID = uuid.New()
+	key.Status = models.JWTKeyStatusActive
+	err = tx.QueryRow(ctx, `
+		INSERT INTO jwt_signing_keys (id, kid, algorithm, private_key, public_key, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`,
+		key.ID, key.KID, key.Algorithm, key.PrivateKey, key.PublicKey, key.Status,
+	).Scan(&key.CreatedAt)
+	if err != nil {
+		// The single active key index rejects a concurrent first key
+		if currentKID == "" && strings.Contains(err.Error(), "idx_jwt_signing_keys_single_active") {
+			return false, nil
 		}
-		item.BlockCount = blockCount
+		return false, fmt.Errorf("failed to store JWT signing key: %w", err)
+	}
 
-		events = append(events, item)
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	// Build filters response
-	filters := &models.EventFiltersREST{
-		EventYear:  eventYear,
-		EventMonth: eventMonth,
-		EventWeek:  eventWeek,
+	return true, nil
+}
+
+// RetireJWTSigningKeys retires previous keys that were rotated out before the given time
+func (p *PostgresDB) RetireJWTSigningKeys(ctx context.Context, rotatedBefore time.Time) (int64, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE jwt_signing_keys SET status = 'retired', retired_at = NOW()
+		WHERE status = 'previous' AND rotated_at < $1`,
+		rotatedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to retire JWT signing keys: %w", err)
 	}
-	if status != "" {
-		filters.Status = &status
+
+	return result.RowsAffected(), nil
+}
+
+// Two-Factor Authentication Operations
+
+// GetUserMFA retrieves the TOTP enrollment of a user
+func (p *PostgresDB) GetUserMFA(ctx context.Context, userID uuid.UUID) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	err := p.pool.QueryRow(ctx, `
+		SELECT user_id, totp_secret, enabled, last_used_step, confirmed_at, created_at, updated_at
+		FROM user_mfa WHERE user_id = $1`,
+		userID,
+	).Scan(&mfa.UserID, &mfa.TOTPSecret, &mfa.Enabled, &mfa.LastUsedStep, &mfa.ConfirmedAt, &mfa.CreatedAt, &mfa.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
-	if showID != "" {
-		filters.ShowID = &showID
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user MFA: %w", err)
 	}
-	if search != "" {
-		filters.Search = &search
+
+	return &mfa, nil
+}
+
+// SetPendingTOTPSecret stores a new unconfirmed TOTP secret for a user.
+// It returns false when the user already has MFA enabled.
+func (p *PostgresDB) SetPendingTOTPSecret(ctx context.Context, userID uuid.UUID, secret string) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		INSERT INTO user_mfa (user_id, totp_secret, enabled)
+		VALUES ($1, $2, false)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, last_used_step = NULL
+		WHERE user_mfa.enabled = false`,
+		userID, secret,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to store TOTP secret: %w", err)
 	}
 
-	return events, total, filters, nil
+	return result.RowsAffected() > 0, nil
 }
 
-// Helper function to get user summaries
-func (p *PostgresDB) getUserSummaries(ctx context.Context, userIDs []uuid.UUID) ([]models.UserSummary, error) {
-	if len(userIDs) == 0 {
-		return []models.UserSummary{}, nil
+// EnableUserMFA enables MFA after the first code was confirmed and replaces the recovery codes
+func (p *PostgresDB) EnableUserMFA(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Build placeholders for IN clause
-	placeholders := make([]string, len(userIDs))
-	args := make([]interface{}, len(userIDs))
-	for i, userID := range userIDs {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-		args[i] = userID
+	result, err := tx.Exec(ctx, `
+		UPDATE user_mfa SET enabled = true, confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled = false`,
+		userID, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
 	}
 
-	query := fmt.Sprintf(`
-		SELECT id, first_name, last_name, email 
-		FROM users 
-		WHERE id IN (%s)`, strings.Join(placeholders, ","))
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return false, err
+	}
 
-	rows, err := p.pool.Query(ctx, query, args...)
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return true, nil
+}
+
+// ClaimTOTPStep records the time step of an accepted TOTP code. It returns false
+// when that step or a later one was already used, so a code can't be replayed.
+func (p *PostgresDB) ClaimTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE user_mfa SET last_used_step = $2
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`,
+		userID, step,
+	)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
 	}
-	defer rows.Close()
 
-	var summaries []models.UserSummary
-	for rows.Next() {
-		var summary models.UserSummary
-		var firstName, lastName *string
-		
-		err := rows.Scan(&summary.ID, &firstName, &lastName, &summary.Email)
-		if err != nil {
-			return nil, err
-		}
+	return result.RowsAffected() > 0, nil
+}
 
-		// Construct name
-		if firstName != nil && lastName != nil {
-			name := *firstName + " " + *lastName
-			summary.Name = name
-		} else if firstName != nil {
-			summary.Name = *firstName
-		} else if lastName != nil {
-			summary.Name = *lastName
-		} else {
-			summary.Name = summary.Email // Fallback to email
-		}
+// DeleteUserMFA removes the TOTP enrollment and recovery codes of a user
+func (p *PostgresDB) DeleteUserMFA(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-		summaries = append(summaries, summary)
+	result, err := tx.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user MFA: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return false, fmt.Errorf("failed to delete recovery codes: %w", err)
 	}
 
-	return summaries, nil
-}
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-// GetUserSummaries returns user summaries for the given user IDs (public method)
-func (p *PostgresDB) GetUserSummaries(ctx context.Context, userIDs []uuid.UUID) ([]models.UserSummary, error) {
-	return p.getUserSummaries(ctx, userIDs)
+	return result.RowsAffected() > 0, nil
 }
 
-// Helper function to get event block count
-func (p *PostgresDB) getEventBlockCount(ctx context.Context, eventID uuid.UUID) (int, error) {
-	var count int
-	query := "SELECT COUNT(*) FROM blocks WHERE event_id = $1"
-	err := p.pool.QueryRow(ctx, query, eventID).Scan(&count)
-	return count, err
+// ReplaceRecoveryCodes invalidates all recovery codes of a user and stores new ones
+func (p *PostgresDB) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, codeHashes []string) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetActiveShows(ctx context.Context) ([]models.Show, error) {
-	query := `
-		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
-			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
-			repeat_pattern, scheduling_config, version, created_at, updated_at, status, user_id, metadata
-		FROM shows 
-		WHERE status = $1
-		ORDER BY created_at ASC`
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
 
-	rows, err := p.pool.Query(ctx, query, models.ShowStatusActive)
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash) VALUES ($1, $2, $3)`,
+			uuid.New(), userID, hash,
+		); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListUnusedRecoveryCodes returns the recovery codes of a user that were not used yet
+func (p *PostgresDB) ListUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) ([]models.MFARecoveryCode, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, user_id, code_hash, used_at, created_at
+		FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list recovery codes: %w", err)
 	}
 	defer rows.Close()
 
-	var shows []models.Show
+	codes := []models.MFARecoveryCode{}
 	for rows.Next() {
-		var show models.Show
-		var metadataJSON []byte
-		var schedulingConfigJSON []byte
-
-		err := rows.Scan(
-			&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
-			&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
-			&show.RepeatPattern, &schedulingConfigJSON, &show.Version, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Unmarshal metadata if present
-		if len(metadataJSON) > 0 {
-			if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-			}
+		var code models.MFARecoveryCode
+		if err := rows.Scan(&code.ID, &code.UserID, &code.CodeHash, &code.UsedAt, &code.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan recovery code: %w", err)
 		}
+		codes = append(codes, code)
+	}
 
-		// Unmarshal scheduling config if present
-		if len(schedulingConfigJSON) > 0 {
-			show.SchedulingConfig = &models.SchedulingConfig{}
-			if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
-			}
-		}
+	return codes, rows.Err()
+}
 
-		shows = append(shows, show)
+// UseRecoveryCode marks a recovery code as used, returning false if it already was
+func (p *PostgresDB) UseRecoveryCode(ctx context.Context, codeID uuid.UUID) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE id = $1 AND used_at IS NULL`,
+		codeID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
 	}
 
-	return shows, nil
+	return result.RowsAffected() > 0, nil
 }
 
-// ListShowsREST returns paginated shows for a user with basic filtering and sorting
-func (p *PostgresDB) ListShowsREST(ctx context.Context, userID uuid.UUID, search string, status models.ShowStatus, sortField, sortOrder string, limit, offset int) ([]models.Show, int, error) {
-	// Build where clause
-	whereConditions := []string{"user_id = $1"}
-	args := []interface{}{userID}
-	argCount := 1
-
-	// Add search filter
-	if search != "" {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("LOWER(show_name) LIKE LOWER($%d)", argCount))
-		args = append(args, "%"+search+"%")
+// UserRequiresMFA reports whether any active role of the user enforces MFA
+func (p *PostgresDB) UserRequiresMFA(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var required bool
+	err := p.pool.QueryRow(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM user_roles ur
+			JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.require_mfa AND r.status = 'active'
+		)`,
+		userID,
+	).Scan(&required)
+	if err != nil {
+		return false, fmt.Errorf("failed to check MFA requirement: %w", err)
 	}
 
-	// Add status filter
-	if status != "" {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("status = $%d", argCount))
-		args = append(args, status)
+	return required, nil
+}
+
+// SetRoleMFARequired enforces or lifts the MFA requirement of a role, returning false if the role doesn't exist
+func (p *PostgresDB) SetRoleMFARequired(ctx context.Context, roleID uuid.UUID, required bool) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE roles SET require_mfa = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`,
+		roleID, required,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update role MFA requirement: %w", err)
 	}
 
-	whereClause := strings.Join(whereConditions, " AND ")
+	return result.RowsAffected() > 0, nil
+}
 
-	// Count total
-	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM shows WHERE %s", whereClause)
-	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
+// CreateMFAChallenge stores a login challenge awaiting the second factor
+func (p *PostgresDB) CreateMFAChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	challenge.ID = uuid.New()
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO mfa_challenges (id, token_hash, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at`,
+		challenge.ID, challenge.TokenHash, challenge.UserID, challenge.ExpiresAt,
+	).Scan(&challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create MFA challenge: %w", err)
 	}
 
-	// Build order by clause
-	orderBy := "created_at DESC" // default
-	if sortField != "" {
-		allowedFields := map[string]bool{
-			"show_name":        true,
-			"first_event_date": true,
-			"created_at":       true,
-			"updated_at":       true,
-		}
-		if allowedFields[sortField] {
-			order := "ASC"
-			if strings.ToUpper(sortOrder) == "DESC" {
-				order = "DESC"
-			}
-			orderBy = fmt.Sprintf("%s %s", sortField, order)
-		}
+	// Expired challenges are useless, clean them up opportunistically
+	if _, err := p.pool.Exec(ctx, `DELETE FROM mfa_challenges WHERE expires_at < NOW() - INTERVAL '1 day'`); err != nil {
+		return fmt.Errorf("failed to delete expired MFA challenges: %w", err)
 	}
 
-	// Get shows
-	query := fmt.Sprintf(`
-		SELECT id, show_name, youtube_key, additional_key, zoom_meeting_url, 
-			zoom_meeting_id, zoom_passcode, start_time, length_minutes, first_event_date, 
-			repeat_pattern, scheduling_config, default_host, default_director, default_producer, 
-			default_telegram, created_at, updated_at, status, user_id, metadata
-		FROM shows 
-		WHERE %s
-		ORDER BY %s
-		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
-
-	args = append(args, limit, offset)
+	return nil
+}
 
-	rows, err := p.pool.Query(ctx, query, args...)
+// GetMFAChallengeByTokenHash retrieves a challenge by the hash of its token
+func (p *PostgresDB) GetMFAChallengeByTokenHash(ctx context.Context, tokenHash string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, token_hash, user_id, attempts, expires_at, consumed_at, created_at
+		FROM mfa_challenges WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&challenge.ID, &challenge.TokenHash, &challenge.UserID, &challenge.Attempts,
+		&challenge.ExpiresAt, &challenge.ConsumedAt, &challenge.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, fmt.Errorf("failed to get MFA challenge: %w", err)
 	}
-	defer rows.Close()
-
-	var shows []models.Show
-	for rows.Next() {
-		var show models.Show
-		var metadataJSON []byte
-		var schedulingConfigJSON []byte
 
-		err := rows.Scan(
-			&show.ID, &show.ShowName, &show.YouTubeKey, &show.AdditionalKey, &show.ZoomMeetingURL,
-			&show.ZoomMeetingID, &show.ZoomPasscode, &show.StartTime, &show.LengthMinutes, &show.FirstEventDate,
-			&show.RepeatPattern, &schedulingConfigJSON, &show.DefaultHost, &show.DefaultDirector, &show.DefaultProducer,
-			&show.DefaultTelegram, &show.CreatedAt, &show.UpdatedAt, &show.Status, &show.UserID, &metadataJSON,
-		)
-		if err != nil {
-			return nil, 0, err
-		}
+	return &challenge, nil
+}
 
-		// Unmarshal metadata if present
-		if len(metadataJSON) > 0 {
-			if err := json.Unmarshal(metadataJSON, &show.Metadata); err != nil {
-				return nil, 0, fmt.Errorf("failed to unmarshal metadata: %w", err)
-			}
-		}
+// RecordMFAChallengeAttempt counts a verification attempt while the challenge is still open
+// and below maxAttempts. It returns false when the challenge can't be attempted any more.
+func (p *PostgresDB) RecordMFAChallengeAttempt(ctx context.Context, challengeID uuid.UUID, maxAttempts int) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE mfa_challenges SET attempts = attempts + 1
+		WHERE id = $1 AND consumed_at IS NULL AND expires_at > NOW() AND attempts < $2`,
+		challengeID, maxAttempts,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record MFA attempt: %w", err)
+	}
 
-		// Unmarshal scheduling config if present
-		if len(schedulingConfigJSON) > 0 {
-			show.SchedulingConfig = &models.SchedulingConfig{}
-			if err := json.Unmarshal(schedulingConfigJSON, show.SchedulingConfig); err != nil {
-				return nil, 0, fmt.Errorf("failed to unmarshal scheduling config: %w", err)
-			}
-		}
+	return result.RowsAffected() > 0, nil
+}
 
-		shows = append(shows, show)
+// ConsumeMFAChallenge marks a challenge as used, returning false if it already was
+func (p *PostgresDB) ConsumeMFAChallenge(ctx context.Context, challengeID uuid.UUID) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE mfa_challenges SET consumed_at = NOW()
+		WHERE id = $1 AND consumed_at IS NULL`,
+		challengeID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume MFA challenge: %w", err)
 	}
 
-	return shows, total, nil
+	return result.RowsAffected() > 0, nil
 }
 
-// Guest operations
+// Login Throttling Operations
+
+// GetLoginThrottle retrieves the failed login counter of an account or client IP
+func (p *PostgresDB) GetLoginThrottle(ctx context.Context, scope models.LoginThrottleScope, subject string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := p.pool.QueryRow(ctx, `
+		SELECT scope, subject, failures, lockouts, first_failed_at, last_failed_at, locked_until
+		FROM login_throttles
+		WHERE scope = $1 AND subject = $2`, scope, subject).Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.Lockouts,
+		&throttle.FirstFailedAt, &throttle.LastFailedAt, &throttle.LockedUntil,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login throttle: %w", err)
+	}
 
-func (p *PostgresDB) CreateGuest(ctx context.Context, guest *models.Guest) error {
-	guest.ID = uuid.New()
-	guest.CreatedAt = time.Now()
-	guest.UpdatedAt = time.Now()
+	return &throttle, nil
+}
 
-	// Convert contacts, tags, and metadata to JSON
-	contactsJSON, err := json.Marshal(guest.Contacts)
+// RecordLoginFailure counts a failed login. Failures before windowStart are forgotten,
+// as are earlier lockouts when the last failure is older than lockoutsResetBefore.
+func (p *PostgresDB) RecordLoginFailure(ctx context.Context, scope models.LoginThrottleScope, subject string, now, windowStart, lockoutsResetBefore time.Time) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO login_throttles (scope, subject, failures, first_failed_at, last_failed_at)
+		VALUES ($1, $2, 1, $3, $3)
+		ON CONFLICT (scope, subject) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failed_at < $4 THEN 1 ELSE login_throttles.failures + 1 END,
+			first_failed_at = CASE WHEN login_throttles.last_failed_at < $4 THEN $3 ELSE login_throttles.first_failed_at END,
+			lockouts = CASE WHEN login_throttles.last_failed_at < $5 THEN 0 ELSE login_throttles.lockouts END,
+			last_failed_at = $3
+		RETURNING scope, subject, failures, lockouts, first_failed_at, last_failed_at, locked_until`,
+		scope, subject, now, windowStart, lockoutsResetBefore).Scan(
+		&throttle.Scope, &throttle.Subject, &throttle.Failures, &throttle.Lockouts,
+		&throttle.FirstFailedAt, &throttle.LastFailedAt, &throttle.LockedUntil,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to marshal contacts: %w", err)
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
 	}
 
-	tagsJSON, err := json.Marshal(guest.Tags)
+	return &throttle, nil
+}
+
+// LockLoginSubject locks an account or client IP until the given time and restarts its
+// failure count. It returns false if the subject is already locked. Locking an account
+// also marks the matching user as locked.
+func (p *PostgresDB) LockLoginSubject(ctx context.Context, scope models.LoginThrottleScope, subject string, now, until time.Time) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal tags: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	metadataJSON, err := json.Marshal(guest.Metadata)
+	result, err := tx.Exec(ctx, `
+		UPDATE login_throttles SET locked_until = $3, lockouts = lockouts + 1, failures = 0
+		WHERE scope = $1 AND subject = $2 AND (locked_until IS NULL OR locked_until <= $4)`,
+		scope, subject, until, now)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return false, fmt.Errorf("failed to lock login: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
 	}
 
-	query := `
-		INSERT INTO guests (id, user_id, name, surname, short_name, contacts, 
-			notes, avatar, tags, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, created_at, updated_at`
+	if scope == models.LoginThrottleScopeAccount {
+		if _, err := tx.Exec(ctx, `UPDATE users SET locked_until = $2 WHERE LOWER(email) = $1`, subject, until); err != nil {
+			return false, fmt.Errorf("failed to lock user: %w", err)
+		}
+	}
 
-	err = p.pool.QueryRow(ctx, query,
-		guest.ID, guest.UserID, guest.Name, guest.Surname, guest.ShortName,
-		contactsJSON, guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
-		guest.CreatedAt, guest.UpdatedAt,
-	).Scan(&guest.ID, &guest.CreatedAt, &guest.UpdatedAt)
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-	return err
+	return true, nil
 }
 
-func (p *PostgresDB) GetGuestByID(ctx context.Context, guestID uuid.UUID) (*models.Guest, error) {
-	guest := &models.Guest{}
-	var contactsJSON, tagsJSON, metadataJSON []byte
+// ClearLoginFailures forgets the failed logins and any lock of an account or client IP
+func (p *PostgresDB) ClearLoginFailures(ctx context.Context, scope models.LoginThrottleScope, subject string) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	query := `
-		SELECT id, user_id, name, surname, short_name, contacts, notes, avatar, 
-			tags, metadata, created_at, updated_at
-		FROM guests WHERE id = $1`
+	if _, err := tx.Exec(ctx, `DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`, scope, subject); err != nil {
+		return fmt.Errorf("failed to clear login failures: %w", err)
+	}
 
-	err := p.pool.QueryRow(ctx, query, guestID).Scan(
-		&guest.ID, &guest.UserID, &guest.Name, &guest.Surname, &guest.ShortName,
-		&contactsJSON, &guest.Notes, &guest.Avatar, &tagsJSON, &metadataJSON,
-		&guest.CreatedAt, &guest.UpdatedAt,
-	)
+	if scope == models.LoginThrottleScopeAccount {
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET locked_until = NULL
+			WHERE LOWER(email) = $1 AND locked_until IS NOT NULL`, subject); err != nil {
+			return fmt.Errorf("failed to unlock user: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
+	return nil
+}
+
+// UnlockUser lifts the lockout of a user and forgets their failed logins.
+// It returns the account subject, or false if the user doesn't exist.
+func (p *PostgresDB) UnlockUser(ctx context.Context, userID uuid.UUID) (string, bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var subject string
+	err = tx.QueryRow(ctx, `
+		UPDATE users SET locked_until = NULL
+		WHERE id = $1
+		RETURNING LOWER(email)`, userID).Scan(&subject)
 	if err == pgx.ErrNoRows {
-		return nil, nil
+		return "", false, nil
 	}
 	if err != nil {
-		return nil, err
+		return "", false, fmt.Errorf("failed to unlock user: %w", err)
 	}
 
-	// Unmarshal JSON fields
-	if len(contactsJSON) > 0 {
-		if err := json.Unmarshal(contactsJSON, &guest.Contacts); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal contacts: %w", err)
-		}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM login_throttles WHERE scope = $1 AND subject = $2`,
+		models.LoginThrottleScopeAccount, subject); err != nil {
+		return "", false, fmt.Errorf("failed to clear login failures: %w", err)
 	}
 
-	if len(tagsJSON) > 0 {
-		if err := json.Unmarshal(tagsJSON, &guest.Tags); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
-		}
+	if err := tx.Commit(ctx); err != nil {
+		return "", false, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal(metadataJSON, &guest.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
-		}
+	return subject, true, nil
+}
+
+// DeleteStaleLoginThrottles removes counters without failures since before that aren't locked
+func (p *PostgresDB) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) (int64, error) {
+	result, err := p.pool.Exec(ctx, `
+		DELETE FROM login_throttles
+		WHERE last_failed_at < $1 AND (locked_until IS NULL OR locked_until < $1)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete stale login throttles: %w", err)
 	}
 
-	return guest, nil
+	return result.RowsAffected(), nil
 }
 
-func (p *PostgresDB) UpdateGuest(ctx context.Context, guest *models.Guest) error {
-	// Convert contacts, tags, and metadata to JSON
-	contactsJSON, err := json.Marshal(guest.Contacts)
+// Password Reset and Email Verification Operations
+
+// LookupUserByEmail retrieves a user by email regardless of status
+func (p *PostgresDB) LookupUserByEmail(ctx context.Context, email string) (*models.UserWithRoles, error) {
+	var userID uuid.UUID
+	err := p.pool.QueryRow(ctx, `SELECT id FROM users WHERE LOWER(email) = LOWER($1)`, email).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal contacts: %w", err)
+		return nil, fmt.Errorf("failed to look up user by email: %w", err)
+	}
+
+	return p.GetUserByID(ctx, userID)
+}
+
+// CreateUserToken stores a new emailed token, replacing unused tokens of the same purpose
+func (p *PostgresDB) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM user_tokens
+		WHERE (user_id = $1 AND purpose = $2 AND used_at IS NULL)
+			OR expires_at < NOW() - INTERVAL '7 days'`,
+		token.UserID, token.Purpose); err != nil {
+		return fmt.Errorf("failed to delete previous tokens: %w", err)
 	}
 
-	tagsJSON, err := json.Marshal(guest.Tags)
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, email, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		token.UserID, token.Purpose, token.TokenHash, token.Email, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to marshal tags: %w", err)
+		return fmt.Errorf("failed to create user token: %w", err)
 	}
 
-	metadataJSON, err := json.Marshal(guest.Metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	query := `
-		UPDATE guests SET 
-			name = $2, surname = $3, short_name = $4, contacts = $5,
-			notes = $6, avatar = $7, tags = $8, metadata = $9
-		WHERE id = $1`
+	return nil
+}
 
-	_, err = p.pool.Exec(ctx, query,
-		guest.ID, guest.Name, guest.Surname, guest.ShortName, contactsJSON,
-		guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
+// ConsumeUserToken marks an unexpired, unused token as used and returns it.
+// It returns nil if there is no such token, so every token works only once.
+func (p *PostgresDB) ConsumeUserToken(ctx context.Context, purpose models.UserTokenPurpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := p.pool.QueryRow(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, purpose, token_hash, email, expires_at, used_at, created_at`,
+		tokenHash, purpose).Scan(
+		&token.ID, &token.UserID, &token.Purpose, &token.TokenHash, &token.Email,
+		&token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
 	)
-	return err
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+
+	return &token, nil
 }
 
-func (p *PostgresDB) DeleteGuest(ctx context.Context, guestID uuid.UUID) error {
-	query := `DELETE FROM guests WHERE id = $1`
-	
-	result, err := p.pool.Exec(ctx, query, guestID)
+// ResetUserPassword sets a new password hash and invalidates the other reset tokens of the user
+func (p *PostgresDB) ResetUserPassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no guest found with ID: %s", guestID)
+	if _, err := tx.Exec(ctx, `
+		UPDATE users SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID, passwordHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM user_tokens
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, models.UserTokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to delete reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (p *PostgresDB) ListGuests(ctx context.Context, userID uuid.UUID, filters models.GuestFilters, pagination models.PaginationOptions, sort models.GuestSortOptions) ([]models.GuestListItem, int, error) {
-	// Set defaults
-	if pagination.Limit <= 0 {
-		pagination.Limit = 20
-	}
-	if pagination.Page <= 0 {
-		pagination.Page = 1
+// VerifyUserEmail marks the email of a user as verified and activates a pending user.
+// It returns false if the user no longer has that email address.
+func (p *PostgresDB) VerifyUserEmail(ctx context.Context, userID uuid.UUID, email string) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE users SET
+			email_verified_at = COALESCE(email_verified_at, NOW()),
+			status = CASE WHEN status = 'pending' THEN 'active'::user_status ELSE status END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND LOWER(email) = LOWER($2)`, userID, email)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify user email: %w", err)
 	}
-	offset := (pagination.Page - 1) * pagination.Limit
 
-	// Build where clause
-	whereConditions := []string{"user_id = $1"}
-	args := []interface{}{userID}
-	argCount := 1
+	return result.RowsAffected() > 0, nil
+}
 
-	// Search filter
-	if filters.Search != "" {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf(`(
-			LOWER(name) LIKE LOWER($%d) OR
-			LOWER(surname) LIKE LOWER($%d) OR
-			LOWER(short_name) LIKE LOWER($%d) OR
-			LOWER(name || ' ' || surname) LIKE LOWER($%d)
-		)`, argCount, argCount, argCount, argCount))
-		searchPattern := "%" + filters.Search + "%"
-		args = append(args, searchPattern)
-	}
+// Linked Identity Operations
 
-	// Tags filter
-	if len(filters.Tags) > 0 {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("tags ?& $%d", argCount))
-		args = append(args, filters.Tags)
+// ConsumeOAuthState deletes an unexpired OAuth state and returns it, so each state works once
+func (p *PostgresDB) ConsumeOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	var oauthState models.OAuthState
+	err := p.pool.QueryRow(ctx, `
+		DELETE FROM oauth_states
+		WHERE state = $1
+		RETURNING state, expires_at, user_id::text, COALESCE(provider, ''), COALESCE(nonce, ''), COALESCE(code_verifier, '')`,
+		state).Scan(
+		&oauthState.State, &oauthState.ExpiresAt, &oauthState.UserID,
+		&oauthState.Provider, &oauthState.Nonce, &oauthState.CodeVerifier,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
 	}
-
-	// Contact type filter
-	if len(filters.HasContactType) > 0 {
-		contactConditions := make([]string, len(filters.HasContactType))
-		for i, contactType := range filters.HasContactType {
-			argCount++
-			contactConditions[i] = fmt.Sprintf("contacts @> $%d", argCount)
-			args = append(args, fmt.Sprintf(`[{"type": "%s"}]`, contactType))
-		}
-		whereConditions = append(whereConditions, "("+strings.Join(contactConditions, " OR ")+")")
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume OAuth state: %w", err)
 	}
-
-	// Date range filter
-	if filters.CreatedDateRange != nil {
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("created_at >= $%d", argCount))
-		args = append(args, filters.CreatedDateRange.Start)
-		
-		argCount++
-		whereConditions = append(whereConditions, fmt.Sprintf("created_at <= $%d", argCount))
-		args = append(args, filters.CreatedDateRange.End)
+	if !oauthState.ExpiresAt.After(time.Now()) {
+		return nil, nil
 	}
 
-	whereClause := strings.Join(whereConditions, " AND ")
+	return &oauthState, nil
+}
 
-	// Count total
-	var total int
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM guests WHERE %s", whereClause)
-	if err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
-	}
+const userIdentityColumns = `id, user_id, provider, subject, email, granted_role_ids, created_at, last_login_at`
 
-	// Build order by clause
-	orderBy := "name ASC, surname ASC" // default
-	if sort.Field != "" {
-		allowedFields := map[string]bool{
-			"name":       true,
-			"surname":    true,
-			"short_name": true,
-			"created_at": true,
-			"updated_at": true,
-		}
-		if allowedFields[sort.Field] {
-			order := "ASC"
-			if strings.ToUpper(sort.Order) == "DESC" {
-				order = "DESC"
-			}
-			orderBy = fmt.Sprintf("%s %s", sort.Field, order)
-		}
+func scanUserIdentity(row pgx.Row) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := row.Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email,
+		&identity.GrantedRoleIDs, &identity.CreatedAt, &identity.LastLoginAt,
+	)
+	if err != nil {
+		return nil, err
 	}
+	return &identity, nil
+}
 
-	// Get guests with summary data
-	query := fmt.Sprintf(`
-		SELECT id, name, surname, short_name, contacts, avatar, tags, 
-			LEFT(notes, 100) as notes_preview, 
-			jsonb_array_length(COALESCE(contacts, '[]'::jsonb)) as contact_count,
-			created_at
-		FROM guests 
-		WHERE %s
-		ORDER BY %s
-		LIMIT $%d OFFSET $%d`, whereClause, orderBy, argCount+1, argCount+2)
+// GetUserIdentity retrieves a linked identity by provider and subject
+func (p *PostgresDB) GetUserIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	identity, err := scanUserIdentity(p.pool.QueryRow(ctx,
+		`SELECT `+userIdentityColumns+` FROM user_identities WHERE provider = $1 AND subject = $2`,
+		provider, subject))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user identity: %w", err)
+	}
 
-	args = append(args, pagination.Limit, offset)
+	return identity, nil
+}
 
-	rows, err := p.pool.Query(ctx, query, args...)
+// ListUserIdentities lists the identities linked to a user, oldest first
+func (p *PostgresDB) ListUserIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+userIdentityColumns+` FROM user_identities WHERE user_id = $1 ORDER BY created_at, provider`,
+		userID)
 	if err != nil {
-		return nil, 0, err
+		return nil, fmt.Errorf("failed to list user identities: %w", err)
 	}
 	defer rows.Close()
 
-	var guests []models.GuestListItem
+	identities := []models.UserIdentity{}
 	for rows.Next() {
-		var guest models.GuestListItem
-		var contactsJSON, tagsJSON []byte
-		var notesPreview sql.NullString
-
-		err := rows.Scan(
-			&guest.ID, &guest.Name, &guest.Surname, &guest.ShortName,
-			&contactsJSON, &guest.Avatar, &tagsJSON, &notesPreview,
-			&guest.ContactCount, &guest.CreatedAt,
-		)
+		identity, err := scanUserIdentity(rows)
 		if err != nil {
-			return nil, 0, err
-		}
-
-		// Process notes preview
-		if notesPreview.Valid {
-			guest.NotesPreview = &notesPreview.String
+			return nil, fmt.Errorf("failed to scan user identity: %w", err)
 		}
-
-		// Unmarshal contacts to find primary email
-		var contacts []models.GuestContact
-		if len(contactsJSON) > 0 {
-			json.Unmarshal(contactsJSON, &contacts)
-			for _, contact := range contacts {
-				if contact.Type == models.ContactTypeEmail && contact.IsPrimary {
-					guest.PrimaryEmail = &contact.Value
-					break
-				}
-			}
-			// If no primary email found, use first email
-			if guest.PrimaryEmail == nil {
-				for _, contact := range contacts {
-					if contact.Type == models.ContactTypeEmail {
-						guest.PrimaryEmail = &contact.Value
-						break
-					}
-				}
-			}
-		}
-
-		// Unmarshal tags
-		if len(tagsJSON) > 0 {
-			json.Unmarshal(tagsJSON, &guest.Tags)
-		}
-
-		guests = append(guests, guest)
+		identities = append(identities, *identity)
 	}
 
-	return guests, total, nil
+	return identities, rows.Err()
 }
 
-func (p *PostgresDB) SearchGuests(ctx context.Context, userID uuid.UUID, query string, limit int) ([]models.GuestSuggestion, error) {
-	if limit <= 0 {
-		limit = 10
+// CreateUserIdentity links an identity to a user. It returns false if the identity
+// is already linked, to this or another user.
+func (p *PostgresDB) CreateUserIdentity(ctx context.Context, identity *models.UserIdentity) (bool, error) {
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, subject) DO NOTHING
+		RETURNING id, granted_role_ids, created_at`,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email,
+	).Scan(&identity.ID, &identity.GrantedRoleIDs, &identity.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return false, nil
 	}
-	if limit > 50 {
-		limit = 50
+	if err != nil {
+		return false, fmt.Errorf("failed to create user identity: %w", err)
 	}
 
-	searchQuery := `
-		SELECT g.id, g.name, g.surname, g.short_name, g.avatar, g.contacts, g.tags,
-			similarity(LOWER(g.name || ' ' || g.surname || ' ' || COALESCE(g.short_name, '')), LOWER($2)) as score
-		FROM guests g
-		WHERE g.user_id = $1 
-		AND (
-			LOWER(g.name) LIKE LOWER($2 || '%') OR
-			LOWER(g.surname) LIKE LOWER($2 || '%') OR
-			LOWER(g.short_name) LIKE LOWER($2 || '%') OR
-			LOWER(g.name || ' ' || g.surname) LIKE LOWER('%' || $2 || '%')
-		)
-		ORDER BY score DESC, g.name ASC, g.surname ASC
-		LIMIT $3`
+	return true, nil
+}
 
-	rows, err := p.pool.Query(ctx, searchQuery, userID, query, limit)
+// CreateUserWithIdentity creates an active user with a verified email and links the identity it signed up with
+func (p *PostgresDB) CreateUserWithIdentity(ctx context.Context, user *models.User, roleIDs []uuid.UUID, identity *models.UserIdentity) error {
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	defer rows.Close()
-
-	var suggestions []models.GuestSuggestion
-	for rows.Next() {
-		var suggestion models.GuestSuggestion
-		var contactsJSON, tagsJSON []byte
-		var score float64
-
-		err := rows.Scan(
-			&suggestion.ID, &suggestion.Name, &suggestion.Surname, &suggestion.ShortName,
-			&suggestion.Avatar, &contactsJSON, &tagsJSON, &score,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// Build display name
-		displayName := suggestion.Name + " " + suggestion.Surname
-		if suggestion.ShortName != nil && *suggestion.ShortName != "" {
-			displayName += " (" + *suggestion.ShortName + ")"
-		}
-		suggestion.DisplayName = displayName
+	defer tx.Rollback(ctx)
 
-		// Parse contacts and find primary
-		var contacts []models.GuestContact
-		if len(contactsJSON) > 0 {
-			json.Unmarshal(contactsJSON, &contacts)
-			for _, contact := range contacts {
-				if contact.IsPrimary {
-					suggestion.PrimaryContact = &contact
-					break
-				}
-			}
-			// If no primary contact found, use first contact
-			if suggestion.PrimaryContact == nil && len(contacts) > 0 {
-				suggestion.PrimaryContact = &contacts[0]
-			}
-		}
+	user.ID = uuid.New()
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (id, name, surname, email, status, metadata, email_verified_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING created_at, updated_at, email_verified_at`,
+		user.ID, user.Name, user.Surname, user.Email, user.Status, user.Metadata,
+	).Scan(&user.CreatedAt, &user.UpdatedAt, &user.EmailVerifiedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
 
-		// Parse tags
-		if len(tagsJSON) > 0 {
-			json.Unmarshal(tagsJSON, &suggestion.Tags)
+	for _, roleID := range roleIDs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role_id)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, role_id) DO NOTHING`,
+			user.ID, roleID); err != nil {
+			return fmt.Errorf("failed to assign role: %w", err)
 		}
+	}
 
-		suggestion.MatchScore = score
-		suggestions = append(suggestions, suggestion)
+	identity.UserID = user.ID
+	err = tx.QueryRow(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, granted_role_ids, created_at`,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email,
+	).Scan(&identity.ID, &identity.GrantedRoleIDs, &identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user identity: %w", err)
 	}
 
-	return suggestions, nil
+	return tx.Commit(ctx)
 }
 
-// Block operations
+// RecordIdentityLogin updates the email and last login time of an identity and its user
+func (p *PostgresDB) RecordIdentityLogin(ctx context.Context, identityID uuid.UUID, email *string) error {
+	_, err := p.pool.Exec(ctx, `
+		WITH identity AS (
+			UPDATE user_identities SET
+				email = COALESCE($2, email),
+				last_login_at = NOW()
+			WHERE id = $1
+			RETURNING user_id
+		)
+		UPDATE users SET last_login_at = NOW()
+		WHERE id = (SELECT user_id FROM identity)`,
+		identityID, email)
+	if err != nil {
+		return fmt.Errorf("failed to record identity login: %w", err)
+	}
+	return nil
+}
 
-func (p *PostgresDB) CreateBlock(ctx context.Context, block *models.Block, guestIDs []uuid.UUID, media []models.BlockMediaInput) error {
+// SyncIdentityRoles makes the roles granted through an identity match roleIDs. Roles the
+// identity granted before but no longer maps to are removed from the user; roles the user
+// had before the identity granted them are left alone.
+func (p *PostgresDB) SyncIdentityRoles(ctx context.Context, identityID uuid.UUID, roleIDs []uuid.UUID) error {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Insert block
-	block.ID = uuid.New()
-	block.CreatedAt = time.Now()
-	block.UpdatedAt = time.Now()
-
-	metadataJSON, err := json.Marshal(block.Metadata)
+	var userID uuid.UUID
+	var granted []uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, granted_role_ids FROM user_identities WHERE id = $1 FOR UPDATE`,
+		identityID).Scan(&userID, &granted)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return fmt.Errorf("failed to get user identity: %w", err)
 	}
 
-	// If order_index is not specified, get the next available index
-	if block.OrderIndex == 0 {
-		var maxOrder sql.NullInt32
-		err = tx.QueryRow(ctx, `
-			SELECT MAX(order_index) FROM blocks WHERE event_id = $1`,
-			block.EventID).Scan(&maxOrder)
+	wanted := make(map[uuid.UUID]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		wanted[roleID] = true
+	}
+
+	var revoke []uuid.UUID
+	for _, roleID := range granted {
+		if !wanted[roleID] {
+			revoke = append(revoke, roleID)
+		}
+	}
+	if len(revoke) > 0 {
+		// Keep roles another identity of the user still grants
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM user_roles WHERE user_id = $1 AND role_id = ANY($2)
+				AND NOT EXISTS (
+					SELECT 1 FROM user_identities ui
+					WHERE ui.user_id = $1 AND ui.id <> $3 AND user_roles.role_id = ANY(ui.granted_role_ids)
+				)`,
+			userID, revoke, identityID); err != nil {
+			return fmt.Errorf("failed to revoke identity roles: %w", err)
+		}
+	}
+
+	// Only roles this insert actually adds (or this identity added earlier) count as granted
+	nowGranted := []uuid.UUID{}
+	previously := make(map[uuid.UUID]bool, len(granted))
+	for _, roleID := range granted {
+		previously[roleID] = true
+	}
+	for _, roleID := range roleIDs {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO user_roles (user_id, role_id)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id, role_id) DO NOTHING`,
+			userID, roleID)
 		if err != nil {
-			return fmt.Errorf("failed to get max order index: %w", err)
+			return fmt.Errorf("failed to grant identity role: %w", err)
 		}
-		if maxOrder.Valid {
-			block.OrderIndex = int(maxOrder.Int32) + 1
-		} else {
-			block.OrderIndex = 0
+		if tag.RowsAffected() > 0 || previously[roleID] {
+			nowGranted = append(nowGranted, roleID)
 		}
 	}
 
-	query := `
-		INSERT INTO blocks (id, event_id, user_id, title, description, topic,
-			estimated_length, order_index, block_type, status, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
-		RETURNING id, created_at, updated_at`
+	if _, err := tx.Exec(ctx, `
+		UPDATE user_identities SET granted_role_ids = $2 WHERE id = $1`,
+		identityID, nowGranted); err != nil {
+		return fmt.Errorf("failed to update identity roles: %w", err)
+	}
 
-	err = tx.QueryRow(ctx, query,
-		block.ID, block.EventID, block.UserID, block.Title, block.Description,
-		block.Topic, block.EstimatedLength, block.OrderIndex, block.BlockType,
-		block.Status, metadataJSON, block.CreatedAt, block.UpdatedAt,
-	).Scan(&block.ID, &block.CreatedAt, &block.UpdatedAt)
+	return tx.Commit(ctx)
+}
 
+// DeleteUserIdentity unlinks an identity from a user and removes the roles it granted.
+// It returns false if the user has no such identity.
+func (p *PostgresDB) DeleteUserIdentity(ctx context.Context, userID, identityID uuid.UUID) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create block: %w", err)
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Insert block-guest relationships
-	for _, guestID := range guestIDs {
-		_, err = tx.Exec(ctx, `
-			INSERT INTO block_guests (block_id, guest_id)
-			VALUES ($1, $2)`, block.ID, guestID)
-		if err != nil {
-			return fmt.Errorf("failed to add guest to block: %w", err)
-		}
+	var granted []uuid.UUID
+	err = tx.QueryRow(ctx, `
+		DELETE FROM user_identities WHERE id = $1 AND user_id = $2
+		RETURNING granted_role_ids`,
+		identityID, userID).Scan(&granted)
+	if err == pgx.ErrNoRows {
+		return false, nil
 	}
-
-	// Insert block-media relationships
-	for _, m := range media {
-		mediaID, err := uuid.Parse(m.MediaID)
-		if err != nil {
-			return fmt.Errorf("invalid media ID: %w", err)
-		}
-		_, err = tx.Exec(ctx, `
-			INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			block.ID, mediaID, m.MediaType, m.Title, m.Description, m.OrderIndex)
-		if err != nil {
-			return fmt.Errorf("failed to add media to block: %w", err)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user identity: %w", err)
+	}
+
+	if len(granted) > 0 {
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM user_roles WHERE user_id = $1 AND role_id = ANY($2)
+				AND NOT EXISTS (
+					SELECT 1 FROM user_identities ui
+					WHERE ui.user_id = $1 AND user_roles.role_id = ANY(ui.granted_role_ids)
+				)`,
+			userID, granted); err != nil {
+			return false, fmt.Errorf("failed to revoke identity roles: %w", err)
 		}
 	}
 
-	return tx.Commit(ctx)
+	return true, tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetBlockByID(ctx context.Context, blockID uuid.UUID) (*models.BlockDetail, error) {
-	var block models.BlockDetail
-	var metadataJSON []byte
+// Personal API Token Operations
 
-	// Get block data
-	query := `
-		SELECT id, event_id, user_id, title, description, topic,
-			estimated_length, actual_length, order_index, block_type, status,
-			metadata, created_at, updated_at
-		FROM blocks WHERE id = $1`
+const apiTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, last_used_ip, created_at`
 
-	err := p.pool.QueryRow(ctx, query, blockID).Scan(
-		&block.ID, &block.EventID, &block.UserID, &block.Title, &block.Description,
-		&block.Topic, &block.EstimatedLength, &block.ActualLength, &block.OrderIndex,
-		&block.BlockType, &block.Status, &metadataJSON, &block.CreatedAt, &block.UpdatedAt,
+func scanAPIToken(row pgx.Row) (*models.APIToken, error) {
+	var token models.APIToken
+	err := row.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.TokenPrefix, &token.Scopes,
+		&token.ExpiresAt, &token.LastUsedAt, &token.LastUsedIP, &token.CreatedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateAPIToken stores a new token. It returns false if the user already has an
+// active token with the same name or maxTokens active tokens.
+func (p *PostgresDB) CreateAPIToken(ctx context.Context, token *models.APIToken, tokenHash string, maxTokens int) (bool, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialize token creation per user so the limit holds
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, token.UserID); err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	var active int
+	var nameTaken bool
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(BOOL_OR(LOWER(name) = LOWER($2)), false)
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`,
+		token.UserID, token.Name).Scan(&active, &nameTaken)
+	if err != nil {
+		return false, fmt.Errorf("failed to count API tokens: %w", err)
+	}
+	if nameTaken || active >= maxTokens {
+		return false, nil
+	}
+
+	// Expired tokens keep their name reserved in the unique index until revoked
+	if _, err := tx.Exec(ctx, `
+		UPDATE api_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at <= NOW()`,
+		token.UserID); err != nil {
+		return false, fmt.Errorf("failed to retire expired API tokens: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		token.UserID, token.Name, tokenHash, token.TokenPrefix, token.Scopes, token.ExpiresAt,
+	).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return true, tx.Commit(ctx)
+}
 
+// GetAPITokenByHash retrieves an active, unexpired token by the hash of its value
+func (p *PostgresDB) GetAPITokenByHash(ctx context.Context, tokenHash string) (*models.APIToken, error) {
+	token, err := scanAPIToken(p.pool.QueryRow(ctx, `
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())`,
+		tokenHash))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get API token: %w", err)
 	}
 
-	// Unmarshal metadata
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal(metadataJSON, &block.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	return token, nil
+}
+
+// ListAPITokens lists the active tokens of a user, newest first. Expired tokens are
+// listed until revoked so their owner sees why a script stopped working.
+func (p *PostgresDB) ListAPITokens(ctx context.Context, userID uuid.UUID) ([]models.APIToken, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+apiTokenColumns+`
+		FROM api_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`,
+		userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
 		}
+		tokens = append(tokens, *token)
 	}
 
-	// Get guests
-	guestRows, err := p.pool.Query(ctx, `
-		SELECT g.id, g.name, g.surname, g.short_name, g.contacts, bg.role, bg.notes
-		FROM guests g
-		JOIN block_guests bg ON g.id = bg.guest_id
-		WHERE bg.block_id = $1
-		ORDER BY g.name, g.surname`, blockID)
+	return tokens, rows.Err()
+}
+
+// TouchAPIToken records a use of a token. Writes are limited to one a minute per token.
+func (p *PostgresDB) TouchAPIToken(ctx context.Context, tokenID uuid.UUID, ip string) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE api_tokens SET last_used_at = NOW(), last_used_ip = NULLIF($2, '')
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`,
+		tokenID, ip)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get block guests: %w", err)
+		return fmt.Errorf("failed to record API token use: %w", err)
 	}
-	defer guestRows.Close()
+	return nil
+}
 
-	for guestRows.Next() {
-		var guest models.BlockGuestDetail
-		var contactsJSON []byte
+// RevokeAPIToken revokes a token of a user. It returns false if the user has no such active token.
+func (p *PostgresDB) RevokeAPIToken(ctx context.Context, userID, tokenID uuid.UUID) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE api_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		tokenID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
 
-		err := guestRows.Scan(
-			&guest.ID, &guest.Name, &guest.Surname, &guest.ShortName,
-			&contactsJSON, &guest.Role, &guest.Notes,
-		)
-		if err != nil {
-			return nil, err
+// RevokeUserAPITokens revokes all tokens of a user and returns how many were active
+func (p *PostgresDB) RevokeUserAPITokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE api_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL`,
+		userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API tokens: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+// Audit Log Operations
+
+// ListAuditLog retrieves audit log entries matching the filters, newest first
+func (p *PostgresDB) ListAuditLog(ctx context.Context, filters *models.AuditLogFilters, pagination *models.PaginationOptions) ([]models.AuditLogEntry, int, error) {
+	var whereConditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		whereConditions = append(whereConditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filters != nil {
+		if filters.EntityType != "" {
+			addCondition("entity_type = $%d", filters.EntityType)
+		}
+		if filters.EntityID != "" {
+			addCondition("entity_id = $%d", filters.EntityID)
+		}
+		if filters.ActorUserID != nil {
+			addCondition("actor_user_id = $%d", *filters.ActorUserID)
+		}
+		if filters.Action != "" {
+			addCondition("action = $%d", filters.Action)
+		}
+		if filters.CorrelationID != "" {
+			addCondition("correlation_id = $%d", filters.CorrelationID)
+		}
+		if filters.From != nil {
+			addCondition("occurred_at >= $%d", *filters.From)
+		}
+		if filters.To != nil {
+			addCondition("occurred_at < $%d", *filters.To)
 		}
+	}
 
-		// Find primary contact
-		var contacts []models.GuestContact
-		if len(contactsJSON) > 0 {
-			json.Unmarshal(contactsJSON, &contacts)
-			for _, contact := range contacts {
-				if contact.IsPrimary {
-					guest.PrimaryContact = &contact
-					break
-				}
-			}
+	whereClause := ""
+	if len(whereConditions) > 0 {
+		whereClause = " WHERE " + strings.Join(whereConditions, " AND ")
+	}
+
+	var total int
+	if err := p.pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log`+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit log entries: %w", err)
+	}
+
+	query := `
+		SELECT id, occurred_at, actor_user_id, actor_session_id, actor_api_token_id, auth_method,
+			correlation_id, request_id, ip_address, entity_type, entity_id, action, before_data, after_data
+		FROM audit_log` + whereClause + fmt.Sprintf(" ORDER BY occurred_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, pagination.Limit, (pagination.Page-1)*pagination.Limit)
+
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit log entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditLogEntry{}
+	for rows.Next() {
+		var entry models.AuditLogEntry
+		if err := rows.Scan(
+			&entry.ID, &entry.OccurredAt, &entry.ActorUserID, &entry.ActorSessionID, &entry.ActorAPITokenID,
+			&entry.AuthMethod, &entry.CorrelationID, &entry.RequestID, &entry.IPAddress,
+			&entry.EntityType, &entry.EntityID, &entry.Action, &entry.Before, &entry.After,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit log entry: %w", err)
 		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit log entries: %w", err)
+	}
 
-		block.Guests = append(block.Guests, guest)
+	return entries, total, nil
+}
+
+// PurgeAuditLog deletes up to limit audit log entries older than before, oldest first,
+// and returns how many were deleted
+func (p *PostgresDB) PurgeAuditLog(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
-	// Get media
-	mediaRows, err := p.pool.Query(ctx, `
-		SELECT bm.media_id, bm.media_type, bm.title, bm.description, bm.order_index,
-			m.file_name, m.file_size
-		FROM block_media bm
-		JOIN media m ON bm.media_id = m.id
-		WHERE bm.block_id = $1
-		ORDER BY bm.order_index`, blockID)
+	// The append-only trigger only lets deletes through with this setting on
+	if _, err := tx.Exec(ctx, `SELECT set_config('stplaner.audit_purge', 'on', true)`); err != nil {
+		return 0, fmt.Errorf("failed to enable audit log purge: %w", err)
+	}
+
+	result, err := tx.Exec(ctx, `
+		DELETE FROM audit_log
+		WHERE id IN (
+			SELECT id FROM audit_log WHERE occurred_at < $1 ORDER BY id LIMIT $2
+		)`,
+		before, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get block media: %w", err)
+		return 0, fmt.Errorf("failed to purge audit log: %w", err)
 	}
-	defer mediaRows.Close()
 
-	for mediaRows.Next() {
-		var media models.BlockMediaDetail
+	return result.RowsAffected(), tx.Commit(ctx)
+}
 
-		err := mediaRows.Scan(
-			&media.MediaID, &media.MediaType, &media.Title, &media.Description,
-			&media.OrderIndex, &media.FileName, &media.FileSize,
-		)
-		if err != nil {
-			return nil, err
-		}
+// RecordAuditEntry records an action that doesn't change a row, such as revealing a
+// secret, for the actor of the request. Changes are recorded by the audit triggers.
+func (p *PostgresDB) RecordAuditEntry(ctx context.Context, entityType, entityID, action string) error {
+	actor := utils.GetAuditActor(ctx)
+	if actor == nil {
+		actor = &models.AuditActor{}
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO audit_log (actor_user_id, actor_session_id, actor_api_token_id, auth_method,
+			correlation_id, request_id, ip_address, entity_type, entity_id, action)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10)`,
+		actor.UserID, actor.SessionID, actor.APITokenID, actor.AuthMethod,
+		actor.CorrelationID, actor.RequestID, actor.IPAddress, entityType, entityID, action,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
 
-		// TODO: Generate S3 URL
-		media.S3URL = fmt.Sprintf("https://s3.example.com/media/%s", media.MediaID)
+// Secret Operations
+
+const (
+	// secretBatchSize bounds how many rows a single pass of EncryptPlaintextSecrets rewrites
+	secretBatchSize = 500
+	// encryptSecretsMigration names the data migration encrypting existing secrets
+	encryptSecretsMigration = "encrypt_show_event_secrets"
+	// secretEncryptAttempts bounds how often EncryptPlaintextSecrets retries rows it found
+	// locked, waiting secretEncryptRetryDelay in between
+	secretEncryptAttempts   = 10
+	secretEncryptRetryDelay = 3 * time.Second
+	// plaintextSecretsCondition matches rows with a secret that isn't encrypted yet
+	plaintextSecretsCondition = `(youtube_key <> '' AND youtube_key NOT LIKE 'enc:%%')
+			OR (additional_key <> '' AND additional_key NOT LIKE 'enc:%%')
+			OR (zoom_passcode <> '' AND zoom_passcode NOT LIKE 'enc:%%')`
+)
 
-		block.Media = append(block.Media, media)
-	}
+// ErrNoSecretCipher is returned when secrets have to be encrypted but no cipher is set
+var ErrNoSecretCipher = errors.New("no secret cipher is configured")
 
-	return &block, nil
+// storedSecrets holds the secret columns of a show or event as written to the database
+type storedSecrets struct {
+	YouTubeKey    *string
+	AdditionalKey *string
+	ZoomPasscode  *string
 }
 
-func (p *PostgresDB) UpdateBlock(ctx context.Context, block *models.Block, guestIDs []uuid.UUID, media []models.BlockMediaInput) error {
-	tx, err := p.pool.Begin(ctx)
-	if err != nil {
-		return err
+// sealSecrets encrypts the secret columns of a show or event for storage
+func (p *PostgresDB) sealSecrets(ctx context.Context, youtubeKey, additionalKey, zoomPasscode *string) (*storedSecrets, error) {
+	sealed := &storedSecrets{}
+	for _, field := range []struct {
+		value  *string
+		target **string
+	}{
+		{youtubeKey, &sealed.YouTubeKey},
+		{additionalKey, &sealed.AdditionalKey},
+		{zoomPasscode, &sealed.ZoomPasscode},
+	} {
+		if field.value == nil || p.secrets == nil {
+			*field.target = field.value
+			continue
+		}
+		value, err := p.secrets.Encrypt(ctx, *field.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+		}
+		*field.target = &value
 	}
-	defer tx.Rollback(ctx)
+	return sealed, nil
+}
 
-	block.UpdatedAt = time.Now()
-	metadataJSON, err := json.Marshal(block.Metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+// openSecrets decrypts secret columns read from the database in place
+func (p *PostgresDB) openSecrets(ctx context.Context, values ...*string) error {
+	if p.secrets == nil {
+		return nil
+	}
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		opened, err := p.secrets.Decrypt(ctx, *value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt secret: %w", err)
+		}
+		*value = opened
 	}
+	return nil
+}
 
-	// Update block
-	query := `
-		UPDATE blocks SET 
-			title = $2, description = $3, topic = $4, estimated_length = $5,
-			actual_length = $6, block_type = $7, status = $8, metadata = $9,
-			updated_at = $10
-		WHERE id = $1`
+func (p *PostgresDB) openShowSecrets(ctx context.Context, show *models.Show) error {
+	return p.openSecrets(ctx, &show.YouTubeKey, show.AdditionalKey, show.ZoomPasscode)
+}
 
-	_, err = tx.Exec(ctx, query,
-		block.ID, block.Title, block.Description, block.Topic, block.EstimatedLength,
-		block.ActualLength, block.BlockType, block.Status, metadataJSON, block.UpdatedAt,
-	)
+func (p *PostgresDB) openEventSecrets(ctx context.Context, event *models.Event) error {
+	return p.openSecrets(ctx, event.YouTubeKey, event.AdditionalKey, event.ZoomPasscode)
+}
+
+// EncryptPlaintextSecrets encrypts the show and event secrets still stored in plain text
+// because they were written before encryption was enabled, and returns how many rows
+// were rewritten. It fails unless no plain text secret is left afterwards, so the service
+// mustn't serve requests before it succeeded; completion is recorded as a data migration
+// and later calls return right away.
+func (p *PostgresDB) EncryptPlaintextSecrets(ctx context.Context) (int64, error) {
+	if p.secrets == nil {
+		return 0, ErrNoSecretCipher
+	}
+
+	// Instances starting together take turns, so the ones after the first find the
+	// migration recorded instead of the rows the first one is encrypting
+	conn, err := p.pool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to update block: %w", err)
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock(hashtext($1))`, encryptSecretsMigration); err != nil {
+		return 0, fmt.Errorf("failed to lock secret encryption: %w", err)
 	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, encryptSecretsMigration)
 
-	// Update guest relationships
-	// First, delete existing relationships
-	_, err = tx.Exec(ctx, `DELETE FROM block_guests WHERE block_id = $1`, block.ID)
+	var completed bool
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM data_migrations WHERE name = $1)`, encryptSecretsMigration).Scan(&completed)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing guest relationships: %w", err)
+		return 0, fmt.Errorf("failed to check secret encryption: %w", err)
+	}
+	if completed {
+		return 0, nil
 	}
 
-	// Then insert new ones
-	for _, guestID := range guestIDs {
-		_, err = tx.Exec(ctx, `
-			INSERT INTO block_guests (block_id, guest_id)
-			VALUES ($1, $2)`, block.ID, guestID)
+	var total int64
+	for attempt := 1; ; attempt++ {
+		encrypted, remaining, err := p.encryptPlaintextSecretsPass(ctx)
+		total += encrypted
 		if err != nil {
-			return fmt.Errorf("failed to add guest to block: %w", err)
+			return total, err
+		}
+		if remaining == 0 {
+			break
+		}
+		if attempt == secretEncryptAttempts {
+			return total, fmt.Errorf("%d rows still have plain text secrets", remaining)
+		}
+
+		// The rows left were locked by requests writing them; wait for those to finish
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(secretEncryptRetryDelay):
 		}
 	}
 
-	// Update media relationships
-	// First, delete existing relationships
-	_, err = tx.Exec(ctx, `DELETE FROM block_media WHERE block_id = $1`, block.ID)
+	_, err = p.pool.Exec(ctx, `
+		INSERT INTO data_migrations (name) VALUES ($1)
+		ON CONFLICT (name) DO NOTHING`, encryptSecretsMigration)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing media relationships: %w", err)
+		return total, fmt.Errorf("failed to record secret encryption: %w", err)
 	}
 
-	// Then insert new ones
-	for _, m := range media {
-		mediaID, err := uuid.Parse(m.MediaID)
-		if err != nil {
-			return fmt.Errorf("invalid media ID: %w", err)
+	return total, nil
+}
+
+// encryptPlaintextSecretsPass encrypts the plain text secrets of rows nobody else has
+// locked and returns how many rows it rewrote and how many still have plain text secrets
+func (p *PostgresDB) encryptPlaintextSecretsPass(ctx context.Context) (int64, int64, error) {
+	var encrypted, remaining int64
+	for _, table := range []string{"shows", "events"} {
+		for {
+			batch, err := p.encryptPlaintextSecretsBatch(ctx, table)
+			encrypted += batch
+			if err != nil {
+				return encrypted, 0, err
+			}
+			if batch < secretBatchSize {
+				break
+			}
 		}
-		_, err = tx.Exec(ctx, `
-			INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			block.ID, mediaID, m.MediaType, m.Title, m.Description, m.OrderIndex)
+
+		var left int64
+		err := p.pool.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE `+plaintextSecretsCondition, table)).Scan(&left)
 		if err != nil {
-			return fmt.Errorf("failed to add media to block: %w", err)
+			return encrypted, 0, fmt.Errorf("failed to count plain text secrets in %s: %w", table, err)
 		}
+		remaining += left
 	}
-
-	return tx.Commit(ctx)
+	return encrypted, remaining, nil
 }
 
-func (p *PostgresDB) DeleteBlock(ctx context.Context, blockID uuid.UUID, reorderRemaining bool) error {
+func (p *PostgresDB) encryptPlaintextSecretsBatch(ctx context.Context, table string) (int64, error) {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Get block info for potential reordering
-	var eventID uuid.UUID
-	var orderIndex int
-	err = tx.QueryRow(ctx, `
-		SELECT event_id, order_index FROM blocks WHERE id = $1`, blockID).Scan(&eventID, &orderIndex)
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		SELECT id, youtube_key, additional_key, zoom_passcode
+		FROM %s
+		WHERE `+plaintextSecretsCondition+`
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, table), secretBatchSize)
 	if err != nil {
-		return fmt.Errorf("failed to get block info: %w", err)
+		return 0, fmt.Errorf("failed to find plain text secrets in %s: %w", table, err)
 	}
 
-	// Delete the block (cascade will handle junction tables)
-	result, err := tx.Exec(ctx, `DELETE FROM blocks WHERE id = $1`, blockID)
-	if err != nil {
-		return fmt.Errorf("failed to delete block: %w", err)
+	ids := []uuid.UUID{}
+	values := []storedSecrets{}
+	for rows.Next() {
+		var id uuid.UUID
+		var secrets storedSecrets
+		if err := rows.Scan(&id, &secrets.YouTubeKey, &secrets.AdditionalKey, &secrets.ZoomPasscode); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan secrets: %w", err)
+		}
+		ids = append(ids, id)
+		values = append(values, secrets)
 	}
-
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("no block found with ID: %s", blockID)
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find plain text secrets in %s: %w", table, err)
 	}
 
-	// Reorder remaining blocks if requested
-	if reorderRemaining {
-		_, err = tx.Exec(ctx, `
-			UPDATE blocks 
-			SET order_index = order_index - 1 
-			WHERE event_id = $1 AND order_index > $2`,
-			eventID, orderIndex)
+	for i, id := range ids {
+		// Columns of the row that are already encrypted are opened first so they aren't sealed twice
+		current := values[i]
+		if err := p.openSecrets(ctx, current.YouTubeKey, current.AdditionalKey, current.ZoomPasscode); err != nil {
+			return 0, err
+		}
+		sealed, err := p.sealSecrets(ctx, current.YouTubeKey, current.AdditionalKey, current.ZoomPasscode)
 		if err != nil {
-			return fmt.Errorf("failed to reorder remaining blocks: %w", err)
+			return 0, err
+		}
+
+		_, err = tx.Exec(ctx, fmt.Sprintf(`
+			UPDATE %s SET youtube_key = $2, additional_key = $3, zoom_passcode = $4
+			WHERE id = $1`, table),
+			id, sealed.YouTubeKey, sealed.AdditionalKey, sealed.ZoomPasscode,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt secrets in %s: %w", table, err)
 		}
 	}
 
-	return tx.Commit(ctx)
+	return int64(len(ids)), tx.Commit(ctx)
 }
 
-func (p *PostgresDB) ReorderBlocks(ctx context.Context, eventID uuid.UUID, blockOrders []models.BlockOrder) error {
+// Login Alert Operations
+
+// RecordLoginOrigin remembers the device and country a user logged in from and reports
+// whether each is new. A kind the user has no history for yet, such as the country
+// before GeoIP was configured, is never reported as new. Empty values are skipped.
+func (p *PostgresDB) RecordLoginOrigin(ctx context.Context, userID uuid.UUID, device, country string) (newDevice, newCountry bool, err error) {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return false, false, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Verify all blocks belong to the event
-	blockIDs := make([]uuid.UUID, len(blockOrders))
-	for i, bo := range blockOrders {
-		blockID, err := uuid.Parse(bo.BlockID)
-		if err != nil {
-			return fmt.Errorf("invalid block ID: %w", err)
-		}
-		blockIDs[i] = blockID
-	}
-
-	var count int
-	err = tx.QueryRow(ctx, `
-		SELECT COUNT(*) FROM blocks 
-		WHERE event_id = $1 AND id = ANY($2)`,
-		eventID, blockIDs).Scan(&count)
-	
+	rows, err := tx.Query(ctx, `SELECT DISTINCT kind FROM user_login_origins WHERE user_id = $1`, userID)
 	if err != nil {
-		return err
+		return false, false, fmt.Errorf("failed to get login origins: %w", err)
 	}
-	
-	if count != len(blockOrders) {
-		return fmt.Errorf("some blocks do not belong to this event")
+	known := map[string]bool{}
+	for rows.Next() {
+		var kind string
+		if err := rows.Scan(&kind); err != nil {
+			rows.Close()
+			return false, false, fmt.Errorf("failed to scan login origin: %w", err)
+		}
+		known[kind] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, false, fmt.Errorf("failed to get login origins: %w", err)
 	}
 
-	// Update order indexes
-	for _, bo := range blockOrders {
-		blockID, _ := uuid.Parse(bo.BlockID) // Already validated above
-		_, err = tx.Exec(ctx, `
-			UPDATE blocks SET order_index = $2, updated_at = $3
-			WHERE id = $1 AND event_id = $4`,
-			blockID, bo.OrderIndex, time.Now(), eventID)
+	remember := func(kind, value string) (bool, error) {
+		if value == "" {
+			return false, nil
+		}
+		var inserted bool
+		err := tx.QueryRow(ctx, `
+			INSERT INTO user_login_origins (user_id, kind, value)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (user_id, kind, value) DO UPDATE SET last_seen_at = CURRENT_TIMESTAMP
+			RETURNING (xmax = 0)`,
+			userID, kind, value).Scan(&inserted)
 		if err != nil {
-			return fmt.Errorf("failed to update block order: %w", err)
+			return false, fmt.Errorf("failed to record login origin: %w", err)
 		}
+		return inserted && known[kind], nil
 	}
 
-	return tx.Commit(ctx)
+	if newDevice, err = remember("device", device); err != nil {
+		return false, false, err
+	}
+	if newCountry, err = remember("country", country); err != nil {
+		return false, false, err
+	}
+
+	return newDevice, newCountry, tx.Commit(ctx)
 }
 
-func (p *PostgresDB) GetEventBlocks(ctx context.Context, eventID uuid.UUID) ([]models.BlockSummary, error) {
-	query := `
-		SELECT b.id, b.title, b.topic, b.estimated_length, b.actual_length,
-			b.order_index, b.block_type, b.status,
-			COUNT(DISTINCT bg.guest_id) as guest_count,
-			COUNT(DISTINCT bm.media_id) as media_count
-		FROM blocks b
-		LEFT JOIN block_guests bg ON b.id = bg.block_id
-		LEFT JOIN block_media bm ON b.id = bm.block_id
-		WHERE b.event_id = $1
-		GROUP BY b.id
-		ORDER BY b.order_index`
+// CreateLoginAlert stores a login alert for the user
+func (p *PostgresDB) CreateLoginAlert(ctx context.Context, alert *models.LoginAlert) error {
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO login_alerts (user_id, session_id, reasons, device_name, device_type, ip_address, location)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`,
+		alert.UserID, alert.SessionID, alert.Reasons, alert.DeviceName, alert.DeviceType, alert.IPAddress, alert.Location,
+	).Scan(&alert.ID, &alert.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create login alert: %w", err)
+	}
+	return nil
+}
 
-	rows, err := p.pool.Query(ctx, query, eventID)
+// GetPendingLoginAlerts returns the login alerts of a user that weren't acknowledged, newest first
+func (p *PostgresDB) GetPendingLoginAlerts(ctx context.Context, userID uuid.UUID) ([]models.LoginAlert, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, user_id, session_id, reasons, device_name, device_type, ip_address, location,
+			created_at, acknowledged_at
+		FROM login_alerts
+		WHERE user_id = $1 AND acknowledged_at IS NULL
+		ORDER BY created_at DESC`,
+		userID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get login alerts: %w", err)
 	}
 	defer rows.Close()
 
-	var blocks []models.BlockSummary
+	alerts := []models.LoginAlert{}
 	for rows.Next() {
-		var block models.BlockSummary
-		err := rows.Scan(
-			&block.ID, &block.Title, &block.Topic, &block.EstimatedLength,
-			&block.ActualLength, &block.OrderIndex, &block.BlockType,
-			&block.Status, &block.GuestCount, &block.MediaCount,
-		)
-		if err != nil {
-			return nil, err
+		var alert models.LoginAlert
+		if err := rows.Scan(
+			&alert.ID, &alert.UserID, &alert.SessionID, &alert.Reasons, &alert.DeviceName, &alert.DeviceType,
+			&alert.IPAddress, &alert.Location, &alert.CreatedAt, &alert.AcknowledgedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan login alert: %w", err)
 		}
-		blocks = append(blocks, block)
-	}
-
-	return blocks, nil
-}
-
-func (p *PostgresDB) GetEventSummary(ctx context.Context, eventID uuid.UUID) (*models.EventSummary, error) {
-	var summary models.EventSummary
-
-	query := `
-		SELECT e.id, s.show_name, e.start_datetime,
-			COUNT(b.id) as total_blocks,
-			COALESCE(SUM(b.estimated_length), 0) as total_estimated_time
-		FROM events e
-		JOIN shows s ON e.show_id = s.id
-		LEFT JOIN blocks b ON e.id = b.event_id
-		WHERE e.id = $1
-		GROUP BY e.id, s.show_name, e.start_datetime`
-
-	err := p.pool.QueryRow(ctx, query, eventID).Scan(
-		&summary.ID, &summary.ShowName, &summary.StartDateTime,
-		&summary.TotalBlocks, &summary.TotalEstimatedTime,
-	)
-
-	if err == pgx.ErrNoRows {
-		return nil, nil
+		alerts = append(alerts, alert)
 	}
-	if err != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get login alerts: %w", err)
 	}
 
-	return &summary, nil
+	return alerts, nil
 }
 
-// User Management Operations
-
-// CreateUser creates a new user with the specified roles
-func (p *PostgresDB) CreateUser(ctx context.Context, user *models.User, roleIDs []uuid.UUID) error {
-	tx, err := p.pool.Begin(ctx)
+// AcknowledgeLoginAlert marks a login alert of the user as seen. It returns false if the
+// user has no such pending alert.
+func (p *PostgresDB) AcknowledgeLoginAlert(ctx context.Context, userID, alertID uuid.UUID) (bool, error) {
+	result, err := p.pool.Exec(ctx, `
+		UPDATE login_alerts SET acknowledged_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND acknowledged_at IS NULL`,
+		alertID, userID)
 	if err != nil {
-		return err
+		return false, fmt.Errorf("failed to acknowledge login alert: %w", err)
 	}
-	defer tx.Rollback(ctx)
-
-	// Generate user ID
-	user.ID = uuid.New()
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
-
-	// Insert user
-	query := `
-		INSERT INTO users (id, name, surname, email, password_hash, oidc_provider, 
-			oidc_subject, status, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id, created_at, updated_at`
-
-	err = tx.QueryRow(ctx, query,
-		user.ID, user.Name, user.Surname, user.Email, user.PasswordHash,
-		user.OIDCProvider, user.OIDCSubject, user.Status, user.Metadata,
-		user.CreatedAt, user.UpdatedAt,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	return result.RowsAffected() > 0, nil
+}
 
+// Guest Invitation Operations
+
+const guestInvitationSelect = `
+	SELECT bg.id, bg.block_id, bg.guest_id, bg.role, bg.notes, bg.invitation_status,
+		bg.invited_at, bg.responded_at, bg.reminder_sent_at, bg.created_at,
+		e.user_id, g.name, g.surname, g.contacts, b.title,
+		e.id, COALESCE(e.event_title, s.show_name), s.show_name,
+		e.start_datetime, e.end_datetime, e.status
+	FROM block_guests bg
+	JOIN guests g ON g.id = bg.guest_id
+	JOIN blocks b ON b.id = bg.block_id
+	JOIN events e ON e.id = b.event_id
+	JOIN shows s ON s.id = e.show_id`
+
+func scanGuestInvitation(row pgx.Row) (*models.GuestInvitation, error) {
+	invitation := &models.GuestInvitation{}
+	var contactsJSON []byte
+
+	err := row.Scan(
+		&invitation.ID, &invitation.BlockID, &invitation.GuestID, &invitation.Role, &invitation.Notes,
+		&invitation.InvitationStatus, &invitation.InvitedAt, &invitation.RespondedAt,
+		&invitation.ReminderSentAt, &invitation.CreatedAt,
+		&invitation.OwnerID, &invitation.GuestName, &invitation.GuestSurname, &contactsJSON,
+		&invitation.BlockTitle, &invitation.EventID, &invitation.EventTitle, &invitation.ShowName,
+		&invitation.StartDatetime, &invitation.EndDatetime, &invitation.EventStatus,
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Assign roles
-	for _, roleID := range roleIDs {
-		_, err = tx.Exec(ctx, `
-			INSERT INTO user_roles (user_id, role_id) 
-			VALUES ($1, $2)`,
-			user.ID, roleID)
-		if err != nil {
-			return err
+	if len(contactsJSON) > 0 {
+		if err := json.Unmarshal(contactsJSON, &invitation.GuestContacts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contacts: %w", err)
 		}
 	}
 
-	return tx.Commit(ctx)
+	return invitation, nil
 }
 
-// GetUserByID retrieves a user by ID with their roles
-func (p *PostgresDB) GetUserByID(ctx context.Context, userID uuid.UUID) (*models.UserWithRoles, error) {
-	var user models.UserWithRoles
-
-	// Get user details
-	query := `
-		SELECT id, name, surname, email, password_hash, oidc_provider, oidc_subject, 
-			status, metadata, created_at, updated_at, last_login_at
-		FROM users
-		WHERE id = $1`
-
-	err := p.pool.QueryRow(ctx, query, userID).Scan(
-		&user.ID, &user.Name, &user.Surname, &user.Email, &user.PasswordHash,
-		&user.OIDCProvider, &user.OIDCSubject, &user.Status,
-		&user.Metadata, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
-	)
+// GetGuestInvitation returns a guest's booking on a block
+func (p *PostgresDB) GetGuestInvitation(ctx context.Context, blockID, guestID uuid.UUID) (*models.GuestInvitation, error) {
+	invitation, err := scanGuestInvitation(p.pool.QueryRow(ctx,
+		guestInvitationSelect+` WHERE bg.block_id = $1 AND bg.guest_id = $2`, blockID, guestID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest invitation: %w", err)
+	}
+	return invitation, nil
+}
 
+// GetGuestInvitationByID returns a booking by its block_guests ID, as carried by RSVP links
+func (p *PostgresDB) GetGuestInvitationByID(ctx context.Context, id uuid.UUID) (*models.GuestInvitation, error) {
+	invitation, err := scanGuestInvitation(p.pool.QueryRow(ctx,
+		guestInvitationSelect+` WHERE bg.id = $1`, id))
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get guest invitation: %w", err)
 	}
+	return invitation, nil
+}
 
-	// Get user roles
-	roleQuery := `
-		SELECT r.id, r.name, r.description
-		FROM roles r
-		JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1`
+// MarkGuestInvited records that an invitation was sent. A new invitation gets a new reminder.
+func (p *PostgresDB) MarkGuestInvited(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	var invitedAt time.Time
+	err := p.pool.QueryRow(ctx, `
+		UPDATE block_guests SET invited_at = CURRENT_TIMESTAMP, reminder_sent_at = NULL,
+			reminder_attempts = 0, reminder_failed_at = NULL
+		WHERE id = $1
+		RETURNING invited_at`, id).Scan(&invitedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to mark guest invited: %w", err)
+	}
+	return invitedAt, nil
+}
 
-	rows, err := p.pool.Query(ctx, roleQuery, userID)
+// SetGuestInvitationStatus records an RSVP response or a status set by the organizer
+func (p *PostgresDB) SetGuestInvitationStatus(ctx context.Context, id uuid.UUID, status models.InvitationStatus) (time.Time, error) {
+	var respondedAt time.Time
+	err := p.pool.QueryRow(ctx, `
+		UPDATE block_guests SET invitation_status = $2, responded_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING responded_at`, id, status).Scan(&respondedAt)
 	if err != nil {
-		return nil, err
+		return time.Time{}, fmt.Errorf("failed to set invitation status: %w", err)
+	}
+	return respondedAt, nil
+}
+
+// ListDueGuestReminders returns invited guests of scheduled events starting before the given
+// time that haven't been reminded yet. Guests who declined or didn't show are left out, as
+// are reminders that failed after failedBefore or failed maxAttempts times already.
+func (p *PostgresDB) ListDueGuestReminders(ctx context.Context, startsBefore, failedBefore time.Time, maxAttempts, limit int) ([]*models.GuestInvitation, error) {
+	rows, err := p.pool.Query(ctx, guestInvitationSelect+`
+		WHERE bg.invited_at IS NOT NULL AND bg.reminder_sent_at IS NULL
+			AND bg.invitation_status IN ('invited', 'confirmed', 'tentative')
+			AND e.status = 'scheduled'
+			AND e.start_datetime > CURRENT_TIMESTAMP AND e.start_datetime <= $1
+			AND (bg.reminder_failed_at IS NULL OR bg.reminder_failed_at <= $2)
+			AND bg.reminder_attempts < $3
+		ORDER BY bg.reminder_attempts, e.start_datetime
+		LIMIT $4`, startsBefore, failedBefore, maxAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due guest reminders: %w", err)
 	}
 	defer rows.Close()
 
-	user.Roles = []models.RoleInfo{}
+	var invitations []*models.GuestInvitation
 	for rows.Next() {
-		var role models.RoleInfo
-		err := rows.Scan(&role.ID, &role.Name, &role.Description)
+		invitation, err := scanGuestInvitation(rows)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to scan guest invitation: %w", err)
 		}
-		user.Roles = append(user.Roles, role)
+		invitations = append(invitations, invitation)
 	}
 
-	return &user, nil
+	return invitations, rows.Err()
 }
 
-// GetUserByEmail retrieves a user by email with their roles
-func (p *PostgresDB) GetUserByEmail(ctx context.Context, email string) (*models.UserWithRoles, error) {
-	var userID uuid.UUID
-
-	// Get user ID by email (only active users for authentication)
-	err := p.pool.QueryRow(ctx, "SELECT id FROM users WHERE email = $1 AND status = 'active'", email).Scan(&userID)
-	if err == pgx.ErrNoRows {
-		return nil, nil
-	}
+// MarkGuestReminderSent records that the reminder for a booking went out
+func (p *PostgresDB) MarkGuestReminderSent(ctx context.Context, id uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `UPDATE block_guests SET reminder_sent_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to mark guest reminder sent: %w", err)
 	}
+	return nil
+}
 
-	return p.GetUserByID(ctx, userID)
+// MarkGuestReminderFailed records a failed attempt to send the reminder for a booking
+func (p *PostgresDB) MarkGuestReminderFailed(ctx context.Context, id uuid.UUID) error {
+	_, err := p.pool.Exec(ctx, `
+		UPDATE block_guests SET reminder_attempts = reminder_attempts + 1, reminder_failed_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark guest reminder failed: %w", err)
+	}
+	return nil
 }
 
-// GetUserByOIDC retrieves a user by OIDC provider and subject
-func (p *PostgresDB) GetUserByOIDC(ctx context.Context, provider, subject string) (*models.UserWithRoles, error) {
-	var userID uuid.UUID
+// Guest Merge Operations
 
-	// Get user ID by OIDC credentials
-	err := p.pool.QueryRow(ctx, 
-		"SELECT id FROM users WHERE oidc_provider = $1 AND oidc_subject = $2", 
-		provider, subject).Scan(&userID)
-	if err == pgx.ErrNoRows {
-		return nil, nil
-	}
+// ErrGuestMergeConflict is returned when a merge can't be undone because it already was,
+// or because the surviving guest changed since
+var ErrGuestMergeConflict = errors.New("guest merge can no longer be undone")
+
+const guestColumns = `id, user_id, name, surname, short_name, contacts, notes, avatar,
+	tags, metadata, created_at, updated_at, erased_at`
+
+func scanGuest(row pgx.Row) (*models.Guest, error) {
+	guest := &models.Guest{}
+	var contactsJSON, tagsJSON, metadataJSON []byte
+
+	err := row.Scan(
+		&guest.ID, &guest.UserID, &guest.Name, &guest.Surname, &guest.ShortName,
+		&contactsJSON, &guest.Notes, &guest.Avatar, &tagsJSON, &metadataJSON,
+		&guest.CreatedAt, &guest.UpdatedAt, &guest.ErasedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return p.GetUserByID(ctx, userID)
-}
+	if len(contactsJSON) > 0 {
+		if err := json.Unmarshal(contactsJSON, &guest.Contacts); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contacts: %w", err)
+		}
+	}
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &guest.Tags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+		}
+	}
+	if len(metadataJSON) > 0 {
+		if err := json.Unmarshal(metadataJSON, &guest.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
 
-// GetUserWithPassword retrieves a user with password hash for authentication
-func (p *PostgresDB) GetUserWithPassword(ctx context.Context, email string) (*models.User, error) {
-	var user models.User
+	return guest, nil
+}
 
+// ListGuestDuplicatePairs returns pairs of a user's guests with similar names or a shared
+// contact value, optionally only pairs involving one guest. Scoring the pairs is left to
+// the caller; this only narrows the candidates down using the trigram index.
+func (p *PostgresDB) ListGuestDuplicatePairs(ctx context.Context, userID uuid.UUID, guestID *uuid.UUID, limit int) ([][2]*models.Guest, error) {
 	query := `
-		SELECT id, name, surname, email, password_hash, oidc_provider, 
-			oidc_subject, status, metadata, created_at, updated_at, last_login_at
-		FROM users
-		WHERE email = $1`
+		WITH contact_values AS (
+			SELECT g.id AS guest_id, c->>'type' AS type,
+				CASE
+					WHEN c->>'type' = 'phone' THEN regexp_replace(c->>'value', '[^0-9]', '', 'g')
+					WHEN c->>'type' IN ('telegram', 'twitter', 'instagram', 'discord') THEN LTRIM(LOWER(TRIM(c->>'value')), '@')
+					ELSE LOWER(TRIM(c->>'value'))
+				END AS value
+			FROM guests g, jsonb_array_elements(COALESCE(g.contacts, '[]'::jsonb)) c
+			WHERE g.user_id = $1
+		),
+		pairs AS (
+			SELECT a.id AS first_id, b.id AS second_id
+			FROM guests a
+			JOIN guests b ON b.user_id = a.user_id AND a.id < b.id AND b.erased_at IS NULL
+				AND (LOWER(b.name) || ' ' || LOWER(b.surname) || ' ' || COALESCE(LOWER(b.short_name), ''))
+					% (LOWER(a.name) || ' ' || LOWER(a.surname) || ' ' || COALESCE(LOWER(a.short_name), ''))
+			WHERE a.user_id = $1 AND a.erased_at IS NULL
+			UNION
+			SELECT ca.guest_id, cb.guest_id
+			FROM contact_values ca
+			JOIN contact_values cb ON cb.type = ca.type AND cb.value = ca.value AND ca.guest_id < cb.guest_id
+			WHERE ca.value <> ''
+		)
+		SELECT first_id, second_id FROM pairs
+		WHERE $2::uuid IS NULL OR first_id = $2 OR second_id = $2
+		LIMIT $3`
 
-	err := p.pool.QueryRow(ctx, query, email).Scan(
-		&user.ID, &user.Name, &user.Surname, &user.Email, &user.PasswordHash,
-		&user.OIDCProvider, &user.OIDCSubject, &user.Status,
-		&user.Metadata, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
-	)
+	rows, err := p.pool.Query(ctx, query, userID, guestID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate guests: %w", err)
+	}
+	defer rows.Close()
 
-	if err == pgx.ErrNoRows {
+	var ids [][2]uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	var guestIDs []uuid.UUID
+	for rows.Next() {
+		var pair [2]uuid.UUID
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate guest pair: %w", err)
+		}
+		ids = append(ids, pair)
+		for _, id := range pair {
+			if !seen[id] {
+				seen[id] = true
+				guestIDs = append(guestIDs, id)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find duplicate guests: %w", err)
+	}
+	if len(ids) == 0 {
 		return nil, nil
 	}
+
+	guestRows, err := p.pool.Query(ctx, `SELECT `+guestColumns+` FROM guests WHERE id = ANY($1)`, guestIDs)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get duplicate guests: %w", err)
 	}
+	defer guestRows.Close()
 
-	return &user, nil
+	guests := make(map[uuid.UUID]*models.Guest, len(guestIDs))
+	for guestRows.Next() {
+		guest, err := scanGuest(guestRows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan guest: %w", err)
+		}
+		guests[guest.ID] = guest
+	}
+	if err := guestRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get duplicate guests: %w", err)
+	}
+
+	pairs := make([][2]*models.Guest, 0, len(ids))
+	for _, pair := range ids {
+		if first, second := guests[pair[0]], guests[pair[1]]; first != nil && second != nil {
+			pairs = append(pairs, [2]*models.Guest{first, second})
+		}
+	}
+
+	return pairs, nil
 }
 
-// UpdateUser updates user information
-func (p *PostgresDB) UpdateUser(ctx context.Context, user *models.User, roleIDs []uuid.UUID) error {
+// movedGuestRow is a block_guests or guest_consents row moved from a duplicate to the survivor
+type movedGuestRow struct {
+	ID      uuid.UUID `json:"id"`
+	GuestID uuid.UUID `json:"guest_id"`
+}
+
+// MergeGuests folds duplicate guests into the survivor in one transaction. merge computes
+// the survivor's new contacts, tags, notes and other fields from the locked rows. The
+// duplicates' block bookings move to the survivor, unless the survivor is already on the
+// block, as does their consent history. A survivor without availability takes over the most
+// recently updated one of the duplicates. The duplicates are deleted. Returns nil if a guest
+// doesn't exist or belongs to another user.
+func (p *PostgresDB) MergeGuests(ctx context.Context, userID, survivorID uuid.UUID, duplicateIDs []uuid.UUID, merge func(survivor *models.Guest, duplicates []*models.Guest) *models.Guest) (*models.Guest, *models.GuestMerge, error) {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Update user
-	query := `
-		UPDATE users SET
-			name = COALESCE($2, name),
-			surname = COALESCE($3, surname),
-			email = COALESCE($4, email),
-			password_hash = COALESCE($5, password_hash),
-			status = COALESCE($6, status),
-			metadata = COALESCE($7, metadata),
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1`
-
-	_, err = tx.Exec(ctx, query,
-		user.ID, user.Name, user.Surname, user.Email,
-		user.PasswordHash, user.Status, user.Metadata,
-	)
+	// Lock in a stable order so concurrent merges can't deadlock
+	rows, err := tx.Query(ctx, `
+		SELECT `+guestColumns+` FROM guests
+		WHERE id = ANY($1) AND user_id = $2
+		ORDER BY id
+		FOR UPDATE`, append([]uuid.UUID{survivorID}, duplicateIDs...), userID)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to lock guests: %w", err)
+	}
+	locked := make(map[uuid.UUID]*models.Guest)
+	for rows.Next() {
+		guest, err := scanGuest(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan guest: %w", err)
+		}
+		locked[guest.ID] = guest
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to lock guests: %w", err)
 	}
 
-	// Update roles if provided
-	if roleIDs != nil {
-		// Remove existing roles
-		_, err = tx.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1", user.ID)
+	survivor := locked[survivorID]
+	if survivor == nil {
+		return nil, nil, nil
+	}
+	duplicates := make([]*models.Guest, 0, len(duplicateIDs))
+	for _, id := range duplicateIDs {
+		if locked[id] == nil {
+			return nil, nil, nil
+		}
+		duplicates = append(duplicates, locked[id])
+	}
+
+	var moved []movedGuestRow
+	removed := []json.RawMessage{}
+	for _, duplicate := range duplicates {
+		// Bookings on blocks the survivor is already on are dropped, the survivor's booking wins
+		dropRows, err := tx.Query(ctx, `
+			DELETE FROM block_guests bg
+			WHERE bg.guest_id = $1 AND EXISTS (
+				SELECT 1 FROM block_guests s WHERE s.block_id = bg.block_id AND s.guest_id = $2
+			)
+			RETURNING to_jsonb(bg)`, duplicate.ID, survivorID)
 		if err != nil {
-			return err
+			return nil, nil, fmt.Errorf("failed to drop duplicate bookings: %w", err)
+		}
+		for dropRows.Next() {
+			var row json.RawMessage
+			if err := dropRows.Scan(&row); err != nil {
+				dropRows.Close()
+				return nil, nil, fmt.Errorf("failed to scan dropped booking: %w", err)
+			}
+			removed = append(removed, row)
+		}
+		dropRows.Close()
+		if err := dropRows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to drop duplicate bookings: %w", err)
 		}
 
-		// Add new roles
-		for _, roleID := range roleIDs {
-			_, err = tx.Exec(ctx, `
-				INSERT INTO user_roles (user_id, role_id) 
-				VALUES ($1, $2)`,
-				user.ID, roleID)
-			if err != nil {
-				return err
+		moveRows, err := tx.Query(ctx, `
+			UPDATE block_guests SET guest_id = $2
+			WHERE guest_id = $1
+			RETURNING id`, duplicate.ID, survivorID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to move duplicate bookings: %w", err)
+		}
+		for moveRows.Next() {
+			row := movedGuestRow{GuestID: duplicate.ID}
+			if err := moveRows.Scan(&row.ID); err != nil {
+				moveRows.Close()
+				return nil, nil, fmt.Errorf("failed to scan moved booking: %w", err)
 			}
+			moved = append(moved, row)
+		}
+		moveRows.Close()
+		if err := moveRows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to move duplicate bookings: %w", err)
 		}
 	}
 
-	return tx.Commit(ctx)
-}
+	// Consents are evidence, so the duplicates' history joins the survivor's
+	consentRows, err := tx.Query(ctx, `
+		UPDATE guest_consents c SET guest_id = $2
+		FROM (SELECT id, guest_id FROM guest_consents WHERE guest_id = ANY($1)) moved
+		WHERE c.id = moved.id
+		RETURNING c.id, moved.guest_id`, duplicateIDs, survivorID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to move duplicate consents: %w", err)
+	}
+	movedConsents := []movedGuestRow{}
+	for consentRows.Next() {
+		var row movedGuestRow
+		if err := consentRows.Scan(&row.ID, &row.GuestID); err != nil {
+			consentRows.Close()
+			return nil, nil, fmt.Errorf("failed to scan moved consent: %w", err)
+		}
+		movedConsents = append(movedConsents, row)
+	}
+	consentRows.Close()
+	if err := consentRows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to move duplicate consents: %w", err)
+	}
 
-// UpdateUserLoginTime updates the last login timestamp
-func (p *PostgresDB) UpdateUserLoginTime(ctx context.Context, userID uuid.UUID) error {
-	_, err := p.pool.Exec(ctx, `
-		UPDATE users SET last_login_at = CURRENT_TIMESTAMP 
-		WHERE id = $1`, userID)
-	return err
-}
+	var availabilityJSON []byte
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(jsonb_agg(to_jsonb(a)), '[]'::jsonb)
+		FROM guest_availability a WHERE a.guest_id = ANY($1)`, duplicateIDs,
+	).Scan(&availabilityJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to snapshot duplicate availability: %w", err)
+	}
 
-// DeleteUser soft deletes a user by changing their status
-func (p *PostgresDB) DeleteUser(ctx context.Context, userID uuid.UUID) error {
-	_, err := p.pool.Exec(ctx, `
-		UPDATE users SET 
-			status = 'inactive',
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1`, userID)
-	return err
+	// A survivor without availability takes over the most recently updated one
+	var adoptedFrom *uuid.UUID
+	var candidate uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT a.guest_id FROM guest_availability a
+		WHERE a.guest_id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM guest_availability s WHERE s.guest_id = $2)
+		ORDER BY a.updated_at DESC NULLS LAST
+		LIMIT 1`, duplicateIDs, survivorID,
+	).Scan(&candidate)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, nil, fmt.Errorf("failed to find duplicate availability: %w", err)
+	}
+	if err == nil {
+		if _, err := tx.Exec(ctx, `UPDATE guest_availability SET guest_id = $2 WHERE guest_id = $1`, candidate, survivorID); err != nil {
+			return nil, nil, fmt.Errorf("failed to move duplicate availability: %w", err)
+		}
+		adoptedFrom = &candidate
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM guests WHERE id = ANY($1)`, duplicateIDs); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete duplicate guests: %w", err)
+	}
+
+	merged := merge(survivor, duplicates)
+	contactsJSON, err := json.Marshal(merged.Contacts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal contacts: %w", err)
+	}
+	tagsJSON, err := json.Marshal(merged.Tags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	metadataJSON, err := json.Marshal(merged.Metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	err = tx.QueryRow(ctx, `
+		UPDATE guests SET
+			short_name = $2, contacts = $3, notes = $4, avatar = $5, tags = $6, metadata = $7
+		WHERE id = $1
+		RETURNING updated_at`,
+		survivorID, merged.ShortName, contactsJSON, merged.Notes, merged.Avatar, tagsJSON, metadataJSON,
+	).Scan(&merged.UpdatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update surviving guest: %w", err)
+	}
+
+	survivorJSON, err := json.Marshal(survivor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal survivor: %w", err)
+	}
+	duplicatesJSON, err := json.Marshal(duplicates)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal duplicates: %w", err)
+	}
+	if moved == nil {
+		moved = []movedGuestRow{}
+	}
+	movedJSON, err := json.Marshal(moved)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal moved bookings: %w", err)
+	}
+	removedJSON, err := json.Marshal(removed)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal removed bookings: %w", err)
+	}
+	movedConsentsJSON, err := json.Marshal(movedConsents)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal moved consents: %w", err)
+	}
+
+	record := &models.GuestMerge{
+		UserID:           userID,
+		SurvivorID:       survivorID,
+		DuplicateIDs:     duplicateIDs,
+		MovedAppearances: len(moved) + len(removed),
+		MovedConsents:    len(movedConsents),
+	}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO guest_merges (user_id, survivor_id, duplicate_ids, survivor_before, duplicates,
+			moved_block_guests, removed_block_guests, survivor_updated_at,
+			moved_consents, duplicate_availability, adopted_availability_from)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at`,
+		userID, survivorID, duplicateIDs, survivorJSON, duplicatesJSON, movedJSON, removedJSON, merged.UpdatedAt,
+		movedConsentsJSON, availabilityJSON, adoptedFrom,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to record guest merge: %w", err)
+	}
+
+	return merged, record, tx.Commit(ctx)
 }
 
-// ListUsers retrieves a paginated list of users with filters
-func (p *PostgresDB) ListUsers(ctx context.Context, filters *models.UserFilters, sort *models.UserSortOptions, pagination *models.PaginationOptions) ([]models.UserListItem, int, error) {
-	// Build base query
-	query := `
-		SELECT DISTINCT u.id, u.name, u.surname, u.email, u.status, 
-			u.created_at, u.last_login_at
-		FROM users u`
+// UndoGuestMerge restores the duplicates of a merge with their bookings, consents and
+// availability and puts the survivor back as it was. Returns nil if the merge doesn't exist or belongs to another
+// user, and ErrGuestMergeConflict if it was undone already or the survivor changed since.
+func (p *PostgresDB) UndoGuestMerge(ctx context.Context, userID, mergeID uuid.UUID) (*models.GuestMerge, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	countQuery := `SELECT COUNT(DISTINCT u.id) FROM users u`
+	record := &models.GuestMerge{}
+	var survivorJSON, duplicatesJSON, movedJSON, removedJSON, movedConsentsJSON, availabilityJSON []byte
+	var survivorUpdatedAt time.Time
+	var adoptedFrom *uuid.UUID
+	err = tx.QueryRow(ctx, `
+		SELECT id, user_id, survivor_id, duplicate_ids, survivor_before, duplicates,
+			moved_block_guests, removed_block_guests, survivor_updated_at, created_at, undone_at,
+			moved_consents, duplicate_availability, adopted_availability_from
+		FROM guest_merges
+		WHERE id = $1 AND user_id = $2
+		FOR UPDATE`, mergeID, userID).Scan(
+		&record.ID, &record.UserID, &record.SurvivorID, &record.DuplicateIDs, &survivorJSON,
+		&duplicatesJSON, &movedJSON, &removedJSON, &survivorUpdatedAt, &record.CreatedAt, &record.UndoneAt,
+		&movedConsentsJSON, &availabilityJSON, &adoptedFrom,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest merge: %w", err)
+	}
+	if record.UndoneAt != nil {
+		return nil, ErrGuestMergeConflict
+	}
+
+	var currentUpdatedAt time.Time
+	err = tx.QueryRow(ctx, `SELECT updated_at FROM guests WHERE id = $1 FOR UPDATE`, record.SurvivorID).Scan(&currentUpdatedAt)
+	if err == pgx.ErrNoRows || (err == nil && !currentUpdatedAt.Equal(survivorUpdatedAt)) {
+		return nil, ErrGuestMergeConflict
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock surviving guest: %w", err)
+	}
+
+	var survivor models.Guest
+	if err := json.Unmarshal(survivorJSON, &survivor); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal survivor: %w", err)
+	}
+	var duplicates []models.Guest
+	if err := json.Unmarshal(duplicatesJSON, &duplicates); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal duplicates: %w", err)
+	}
+	var moved []movedGuestRow
+	if err := json.Unmarshal(movedJSON, &moved); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal moved bookings: %w", err)
+	}
+	var removed []json.RawMessage
+	if err := json.Unmarshal(removedJSON, &removed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal removed bookings: %w", err)
+	}
+	var movedConsents []movedGuestRow
+	if err := json.Unmarshal(movedConsentsJSON, &movedConsents); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal moved consents: %w", err)
+	}
+	var availability []json.RawMessage
+	if err := json.Unmarshal(availabilityJSON, &availability); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal duplicate availability: %w", err)
+	}
 
-	// Add joins if filtering by roles
-	if filters != nil && len(filters.RoleIDs) > 0 {
-		joinClause := ` JOIN user_roles ur ON u.id = ur.user_id`
-		query += joinClause
-		countQuery += joinClause
+	if err := restoreGuestFields(ctx, tx, &survivor); err != nil {
+		return nil, err
 	}
 
-	// Build WHERE clause
-	var whereConditions []string
-	var args []interface{}
-	argCount := 0
+	for i := range duplicates {
+		duplicate := &duplicates[i]
+		contactsJSON, tagsJSON, metadataJSON, err := marshalGuestJSON(duplicate)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO guests (id, user_id, name, surname, short_name, contacts,
+				notes, avatar, tags, metadata, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+			duplicate.ID, duplicate.UserID, duplicate.Name, duplicate.Surname, duplicate.ShortName,
+			contactsJSON, duplicate.Notes, duplicate.Avatar, tagsJSON, metadataJSON, duplicate.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore guest: %w", err)
+		}
+	}
 
-	if filters != nil {
-		if len(filters.Status) > 0 {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("u.status = ANY($%d)", argCount))
-			args = append(args, filters.Status)
+	for _, row := range moved {
+		if _, err := tx.Exec(ctx, `
+			UPDATE block_guests SET guest_id = $2
+			WHERE id = $1 AND guest_id = $3`, row.ID, row.GuestID, record.SurvivorID); err != nil {
+			return nil, fmt.Errorf("failed to move booking back: %w", err)
 		}
+	}
 
-		if len(filters.RoleIDs) > 0 {
-			roleUUIDs := make([]uuid.UUID, len(filters.RoleIDs))
-			for i, id := range filters.RoleIDs {
-				roleUUIDs[i], _ = uuid.Parse(id)
-			}
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("ur.role_id = ANY($%d)", argCount))
-			args = append(args, roleUUIDs)
+	// Blocks deleted since the merge take their bookings with them
+	for _, row := range removed {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO block_guests
+			SELECT r.* FROM jsonb_populate_record(NULL::block_guests, $1) r
+			WHERE EXISTS (SELECT 1 FROM blocks b WHERE b.id = r.block_id)
+			ON CONFLICT DO NOTHING`, row); err != nil {
+			return nil, fmt.Errorf("failed to restore booking: %w", err)
 		}
+	}
 
-		if filters.Search != "" {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("(u.name ILIKE $%d OR u.surname ILIKE $%d OR u.email ILIKE $%d)", argCount, argCount, argCount))
-			searchTerm := "%" + filters.Search + "%"
-			args = append(args, searchTerm)
+	for _, row := range movedConsents {
+		if _, err := tx.Exec(ctx, `
+			UPDATE guest_consents SET guest_id = $2
+			WHERE id = $1 AND guest_id = $3`, row.ID, row.GuestID, record.SurvivorID); err != nil {
+			return nil, fmt.Errorf("failed to move consent back: %w", err)
 		}
+	}
 
-		if filters.OIDCProvider != nil {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("u.oidc_provider = $%d", argCount))
-			args = append(args, *filters.OIDCProvider)
+	// The survivor gives back availability it took over, unless it was edited since
+	for _, row := range availability {
+		var snapshot struct {
+			GuestID   uuid.UUID  `json:"guest_id"`
+			UpdatedAt *time.Time `json:"updated_at"`
+		}
+		if err := json.Unmarshal(row, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal availability: %w", err)
+		}
+		if adoptedFrom != nil && *adoptedFrom == snapshot.GuestID {
+			if _, err := tx.Exec(ctx, `
+				DELETE FROM guest_availability
+				WHERE guest_id = $1 AND updated_at IS NOT DISTINCT FROM $2`,
+				record.SurvivorID, snapshot.UpdatedAt); err != nil {
+				return nil, fmt.Errorf("failed to remove adopted availability: %w", err)
+			}
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO guest_availability
+			SELECT * FROM jsonb_populate_record(NULL::guest_availability, $1)
+			ON CONFLICT (guest_id) DO NOTHING`, row); err != nil {
+			return nil, fmt.Errorf("failed to restore availability: %w", err)
 		}
 	}
 
-	if len(whereConditions) > 0 {
-		whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
-		query += whereClause
-		countQuery += whereClause
+	if err := tx.QueryRow(ctx, `
+		UPDATE guest_merges SET undone_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING undone_at`, mergeID).Scan(&record.UndoneAt); err != nil {
+		return nil, fmt.Errorf("failed to mark guest merge undone: %w", err)
 	}
+	record.MovedAppearances = len(moved) + len(removed)
+	record.MovedConsents = len(movedConsents)
 
-	// Get total count
-	var totalCount int
-	err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
+	return record, tx.Commit(ctx)
+}
+
+// restoreGuestFields writes back the editable fields of a guest snapshot
+func restoreGuestFields(ctx context.Context, tx pgx.Tx, guest *models.Guest) error {
+	contactsJSON, tagsJSON, metadataJSON, err := marshalGuestJSON(guest)
 	if err != nil {
-		return nil, 0, err
+		return err
 	}
 
-	// Add sorting
-	if sort != nil {
-		orderClause := fmt.Sprintf(" ORDER BY u.%s %s", sort.Field, sort.Order)
-		query += orderClause
-	} else {
-		query += " ORDER BY u.created_at DESC"
+	_, err = tx.Exec(ctx, `
+		UPDATE guests SET
+			name = $2, surname = $3, short_name = $4, contacts = $5,
+			notes = $6, avatar = $7, tags = $8, metadata = $9
+		WHERE id = $1`,
+		guest.ID, guest.Name, guest.Surname, guest.ShortName, contactsJSON,
+		guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore guest: %w", err)
 	}
+	return nil
+}
 
-	// Add pagination
-	if pagination != nil {
-		limit := pagination.Limit
-		if limit <= 0 {
-			limit = 20
-		}
-		offset := (pagination.Page - 1) * limit
-		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+func marshalGuestJSON(guest *models.Guest) (contacts, tags, metadata []byte, err error) {
+	if contacts, err = json.Marshal(guest.Contacts); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to marshal contacts: %w", err)
+	}
+	if tags, err = json.Marshal(guest.Tags); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	if metadata, err = json.Marshal(guest.Metadata); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to marshal metadata: %w", err)
 	}
+	return contacts, tags, metadata, nil
+}
+
+// Guest Import/Export Operations
+
+// StreamGuests calls fn for every guest matching the filters, in list order, without
+// loading the whole directory into memory. Returning an error from fn stops the stream.
+func (p *PostgresDB) StreamGuests(ctx context.Context, userID uuid.UUID, filters models.GuestFilters, sort models.GuestSortOptions, fn func(*models.Guest) error) error {
+	whereClause, args := guestFilterClause(userID, filters)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM (
+			SELECT guests.*, (
+				SELECT MAX(e.start_datetime)
+				FROM block_guests bg
+				JOIN blocks b ON b.id = bg.block_id
+				JOIN events e ON e.id = b.event_id
+				WHERE bg.guest_id = guests.id AND e.status <> 'cancelled'
+					AND e.start_datetime <= CURRENT_TIMESTAMP
+			) as last_appearance
+			FROM guests
+			WHERE %s
+		) guests
+		ORDER BY %s`, guestColumns, whereClause, guestOrderBy(sort))
 
-	// Execute query
 	rows, err := p.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return fmt.Errorf("failed to stream guests: %w", err)
 	}
 	defer rows.Close()
 
-	var users []models.UserListItem
-	userIDs := make([]uuid.UUID, 0)
-
 	for rows.Next() {
-		var user models.UserListItem
-		err := rows.Scan(
-			&user.ID, &user.Name, &user.Surname, &user.Email,
-			&user.Status, &user.CreatedAt, &user.LastLoginAt,
-		)
+		guest, err := scanGuest(rows)
 		if err != nil {
-			return nil, 0, err
+			return fmt.Errorf("failed to scan guest: %w", err)
+		}
+		if err := fn(guest); err != nil {
+			return err
 		}
-		users = append(users, user)
-		userIDs = append(userIDs, user.ID)
 	}
 
-	// Get roles for all users
-	if len(userIDs) > 0 {
-		roleQuery := `
-			SELECT ur.user_id, r.id, r.name, r.description
-			FROM user_roles ur
-			JOIN roles r ON ur.role_id = r.id
-			WHERE ur.user_id = ANY($1)`
+	return rows.Err()
+}
 
-		roleRows, err := p.pool.Query(ctx, roleQuery, userIDs)
+// ListAllGuests returns every guest of a user that wasn't erased, for matching imported guests against
+func (p *PostgresDB) ListAllGuests(ctx context.Context, userID uuid.UUID) ([]*models.Guest, error) {
+	query := fmt.Sprintf(`SELECT %s FROM guests WHERE user_id = $1 AND erased_at IS NULL ORDER BY name, surname`, guestColumns)
+
+	rows, err := p.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guests: %w", err)
+	}
+	defer rows.Close()
+
+	var guests []*models.Guest
+	for rows.Next() {
+		guest, err := scanGuest(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, fmt.Errorf("failed to scan guest: %w", err)
 		}
-		defer roleRows.Close()
+		guests = append(guests, guest)
+	}
 
-		// Create a map to store roles by user ID
-		userRoles := make(map[uuid.UUID][]models.RoleInfo)
-		for roleRows.Next() {
-			var userID uuid.UUID
-			var role models.RoleInfo
-			err := roleRows.Scan(&userID, &role.ID, &role.Name, &role.Description)
-			if err != nil {
-				return nil, 0, err
-			}
-			userRoles[userID] = append(userRoles[userID], role)
+	return guests, rows.Err()
+}
+
+// CreateGuests inserts imported guests in one transaction, so an import either lands
+// completely or not at all
+func (p *PostgresDB) CreateGuests(ctx context.Context, guests []*models.Guest) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO guests (id, user_id, name, surname, short_name, contacts,
+			notes, avatar, tags, metadata, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING created_at, updated_at`
+
+	now := time.Now()
+	for _, guest := range guests {
+		guest.ID = uuid.New()
+		guest.CreatedAt = now
+		guest.UpdatedAt = now
+
+		contactsJSON, tagsJSON, metadataJSON, err := marshalGuestJSON(guest)
+		if err != nil {
+			return err
 		}
 
-		// Note: Roles are not included in the simplified UserListItem response
+		err = tx.QueryRow(ctx, query,
+			guest.ID, guest.UserID, guest.Name, guest.Surname, guest.ShortName,
+			contactsJSON, guest.Notes, guest.Avatar, tagsJSON, metadataJSON,
+			guest.CreatedAt, guest.UpdatedAt,
+		).Scan(&guest.CreatedAt, &guest.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create guest %s %s: %w", guest.Name, guest.Surname, err)
+		}
 	}
 
-	return users, totalCount, nil
+	return tx.Commit(ctx)
 }
 
-// User-Role Assignment Operations
+// Guest Personal Data Operations
 
-// AddRoleToUser adds a role to a user
-func (p *PostgresDB) AddRoleToUser(ctx context.Context, userID, roleID uuid.UUID) error {
-	query := `
-		INSERT INTO user_roles (id, user_id, role_id, created_at)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (user_id, role_id) DO NOTHING`
+// guestPersonalFields are the guest columns holding personal data, stripped from the guest's
+// audit entries when it's erased
+var guestPersonalFields = []string{"name", "surname", "short_name", "contacts", "notes", "avatar", "tags", "metadata"}
 
-	_, err := p.pool.Exec(ctx, query, uuid.New(), userID, roleID, time.Now())
-	return err
+// RecordGuestConsent appends a consent record. Earlier records of the purpose stay as history.
+func (p *PostgresDB) RecordGuestConsent(ctx context.Context, consent *models.GuestConsent) error {
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO guest_consents (guest_id, purpose, granted, method, evidence, obtained_at, expires_at, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		consent.GuestID, consent.Purpose, consent.Granted, consent.Method, consent.Evidence,
+		consent.ObtainedAt, consent.ExpiresAt, consent.RecordedBy,
+	).Scan(&consent.ID, &consent.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record guest consent: %w", err)
+	}
+	return nil
 }
 
-// RemoveRoleFromUser removes a role from a user
-func (p *PostgresDB) RemoveRoleFromUser(ctx context.Context, userID, roleID uuid.UUID) error {
-	query := `
-		DELETE FROM user_roles 
-		WHERE user_id = $1 AND role_id = $2`
-
-	result, err := p.pool.Exec(ctx, query, userID, roleID)
+// ListGuestConsents returns a guest's consent records, latest first
+func (p *PostgresDB) ListGuestConsents(ctx context.Context, guestID uuid.UUID) ([]models.GuestConsent, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, guest_id, purpose, granted, method, evidence, obtained_at, expires_at, recorded_by, created_at
+		FROM guest_consents
+		WHERE guest_id = $1
+		ORDER BY obtained_at DESC, created_at DESC`, guestID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to list guest consents: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected := result.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("user role assignment not found")
+	consents := []models.GuestConsent{}
+	for rows.Next() {
+		var consent models.GuestConsent
+		if err := rows.Scan(
+			&consent.ID, &consent.GuestID, &consent.Purpose, &consent.Granted, &consent.Method,
+			&consent.Evidence, &consent.ObtainedAt, &consent.ExpiresAt, &consent.RecordedBy, &consent.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan guest consent: %w", err)
+		}
+		consents = append(consents, consent)
 	}
 
-	return nil
+	return consents, rows.Err()
 }
 
-// Role Management Operations
+// GetGuestPersonalData collects everything stored about a guest: the guest record, consent
+// history, every block booking including those of cancelled events, records merged into
+// the guest and its audit trail
+func (p *PostgresDB) GetGuestPersonalData(ctx context.Context, guestID uuid.UUID) (*models.GuestPersonalData, error) {
+	guest, err := p.GetGuestByID(ctx, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest: %w", err)
+	}
+	if guest == nil {
+		return nil, nil
+	}
 
-// CreateRole creates a new role
-func (p *PostgresDB) CreateRole(ctx context.Context, role *models.Role) error {
-	role.ID = uuid.New()
-	role.CreatedAt = time.Now()
-	role.UpdatedAt = time.Now()
+	data := &models.GuestPersonalData{
+		ExportedAt:    time.Now(),
+		Guest:         guest,
+		Bookings:      []models.GuestInvitation{},
+		MergedRecords: []models.MergedGuestRecords{},
+		AuditTrail:    []models.AuditLogEntry{},
+	}
 
-	query := `
-		INSERT INTO roles (id, name, description, permissions, status, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at`
+	if data.Consents, err = p.ListGuestConsents(ctx, guestID); err != nil {
+		return nil, err
+	}
+	if data.Availability, err = p.GetGuestAvailability(ctx, guestID); err != nil {
+		return nil, err
+	}
 
-	err := p.pool.QueryRow(ctx, query,
-		role.ID, role.Name, role.Description, role.Permissions,
-		role.Status, role.Metadata, role.CreatedAt, role.UpdatedAt,
-	).Scan(&role.ID, &role.CreatedAt, &role.UpdatedAt)
+	bookingRows, err := p.pool.Query(ctx,
+		guestInvitationSelect+` WHERE bg.guest_id = $1 ORDER BY e.start_datetime, b.order_index`, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest bookings: %w", err)
+	}
+	defer bookingRows.Close()
+	for bookingRows.Next() {
+		booking, err := scanGuestInvitation(bookingRows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan guest booking: %w", err)
+		}
+		data.Bookings = append(data.Bookings, *booking)
+	}
+	if err := bookingRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list guest bookings: %w", err)
+	}
 
-	return err
+	mergeRows, err := p.pool.Query(ctx, `
+		SELECT id, created_at, duplicates
+		FROM guest_merges
+		WHERE survivor_id = $1 AND undone_at IS NULL
+		ORDER BY created_at`, guestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest merges: %w", err)
+	}
+	defer mergeRows.Close()
+	for mergeRows.Next() {
+		var merged models.MergedGuestRecords
+		var duplicatesJSON []byte
+		if err := mergeRows.Scan(&merged.MergeID, &merged.MergedAt, &duplicatesJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan guest merge: %w", err)
+		}
+		if err := json.Unmarshal(duplicatesJSON, &merged.Duplicates); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal merged guests: %w", err)
+		}
+		data.MergedRecords = append(data.MergedRecords, merged)
+	}
+	if err := mergeRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list guest merges: %w", err)
+	}
+
+	// Booking entries are keyed by block, so they are found by the guest in their data
+	auditRows, err := p.pool.Query(ctx, `
+		SELECT id, occurred_at, actor_user_id, actor_session_id, actor_api_token_id, auth_method,
+			correlation_id, request_id, ip_address, entity_type, entity_id, action, before_data, after_data
+		FROM audit_log
+		WHERE (entity_type = 'guest' AND entity_id = $1)
+			OR (entity_type = 'block_guest' AND COALESCE(after_data, before_data)->>'guest_id' = $1)
+		ORDER BY occurred_at, id`, guestID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest audit trail: %w", err)
+	}
+	defer auditRows.Close()
+	for auditRows.Next() {
+		var entry models.AuditLogEntry
+		if err := auditRows.Scan(
+			&entry.ID, &entry.OccurredAt, &entry.ActorUserID, &entry.ActorSessionID, &entry.ActorAPITokenID,
+			&entry.AuthMethod, &entry.CorrelationID, &entry.RequestID, &entry.IPAddress,
+			&entry.EntityType, &entry.EntityID, &entry.Action, &entry.Before, &entry.After,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit log entry: %w", err)
+		}
+		data.AuditTrail = append(data.AuditTrail, entry)
+	}
+	if err := auditRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list guest audit trail: %w", err)
+	}
+
+	return data, nil
 }
 
-// GetRoleByID retrieves a role by ID
-func (p *PostgresDB) GetRoleByID(ctx context.Context, roleID uuid.UUID) (*models.RoleWithUserCount, error) {
-	var role models.RoleWithUserCount
+// EraseGuest anonymises a guest in one transaction. The guest row and its block bookings
+// stay, so rundowns of past events keep their structure, but the name becomes a
+// placeholder and contacts, notes, avatar, tags and metadata are cleared. Booking notes,
+// availability, consent evidence, snapshots of merged records and the personal fields of the
+// audit entries of the guest and the duplicates merged into it are removed too. Returns the guest before and after, or nils when it
+// doesn't exist.
+func (p *PostgresDB) EraseGuest(ctx context.Context, guestID uuid.UUID) (*models.Guest, *models.Guest, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	query := `
-		SELECT r.id, r.name, r.description, r.permissions, r.status, 
-			r.metadata, r.created_at, r.updated_at,
-			COUNT(DISTINCT ur.user_id) as user_count
-		FROM roles r
-		LEFT JOIN user_roles ur ON r.id = ur.role_id
-		WHERE r.id = $1
-		GROUP BY r.id`
+	before, err := scanGuest(tx.QueryRow(ctx, `SELECT `+guestColumns+` FROM guests WHERE id = $1 FOR UPDATE`, guestID))
+	if err == pgx.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock guest: %w", err)
+	}
 
-	err := p.pool.QueryRow(ctx, query, roleID).Scan(
-		&role.ID, &role.Name, &role.Description, &role.Permissions,
-		&role.Status, &role.Metadata, &role.CreatedAt, &role.UpdatedAt,
-		&role.UserCount,
-	)
+	// The ID keeps placeholder names apart for the unique name constraint
+	after, err := scanGuest(tx.QueryRow(ctx, `
+		UPDATE guests SET
+			name = 'Erased', surname = 'Guest ' || LEFT(id::text, 8), short_name = NULL,
+			contacts = '[]'::jsonb, notes = NULL, avatar = NULL, tags = '[]'::jsonb, metadata = '{}'::jsonb,
+			erased_at = COALESCE(erased_at, CURRENT_TIMESTAMP)
+		WHERE id = $1
+		RETURNING `+guestColumns, guestID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to erase guest: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE block_guests SET notes = NULL WHERE guest_id = $1`, guestID); err != nil {
+		return nil, nil, fmt.Errorf("failed to erase booking notes: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE guest_consents SET evidence = NULL WHERE guest_id = $1`, guestID); err != nil {
+		return nil, nil, fmt.Errorf("failed to erase consent evidence: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM guest_availability WHERE guest_id = $1`, guestID); err != nil {
+		return nil, nil, fmt.Errorf("failed to erase guest availability: %w", err)
+	}
+	// Duplicates merged into the guest, directly or through earlier merges, were the same
+	// person, and their audit entries still hold their personal fields
+	var mergedIDs []uuid.UUID
+	err = tx.QueryRow(ctx, `
+		WITH RECURSIVE merged(id) AS (
+			SELECT unnest(duplicate_ids) FROM guest_merges WHERE survivor_id = $1 AND undone_at IS NULL
+			UNION
+			SELECT unnest(m.duplicate_ids)
+			FROM guest_merges m JOIN merged ON m.survivor_id = merged.id
+			WHERE m.undone_at IS NULL
+		)
+		SELECT COALESCE(array_agg(id), '{}') FROM merged WHERE id <> $1`, guestID).Scan(&mergedIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list merged guests: %w", err)
+	}
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
+	// Merge snapshots hold the guest's record whether it survived or was merged in and
+	// restored by an undo. Without them the merges can't be undone anymore.
+	if _, err := tx.Exec(ctx, `DELETE FROM guest_merges WHERE survivor_id = $1 OR $1 = ANY(duplicate_ids)`, guestID); err != nil {
+		return nil, nil, fmt.Errorf("failed to erase merged guest records: %w", err)
 	}
+
+	// The append-only trigger only lets updates through with this setting on. This also
+	// covers the entry the erasing update above was just recorded in.
+	if _, err := tx.Exec(ctx, `SELECT set_config('stplaner.audit_erase', 'on', true)`); err != nil {
+		return nil, nil, fmt.Errorf("failed to enable audit log erasure: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE audit_log SET before_data = before_data - $2::text[], after_data = after_data - $2::text[]
+		WHERE entity_type = 'guest' AND entity_id = $1`,
+		guestID.String(), guestPersonalFields)
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to erase guest audit entries: %w", err)
+	}
+	if len(mergedIDs) > 0 {
+		mergedEntityIDs := make([]string, len(mergedIDs))
+		for i, id := range mergedIDs {
+			mergedEntityIDs[i] = id.String()
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE audit_log SET before_data = before_data - $2::text[], after_data = after_data - $2::text[]
+			WHERE entity_type = 'guest' AND entity_id = ANY($1)`,
+			mergedEntityIDs, guestPersonalFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to erase merged guest audit entries: %w", err)
+		}
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE audit_log SET before_data = before_data - 'notes', after_data = after_data - 'notes'
+		WHERE entity_type = 'block_guest' AND COALESCE(after_data, before_data)->>'guest_id' = $1`,
+		guestID.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to erase booking audit entries: %w", err)
 	}
 
-	return &role, nil
+	return before, after, tx.Commit(ctx)
 }
 
-// GetRoleByName retrieves a role by name
-func (p *PostgresDB) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
-	var role models.Role
-
-	query := `
-		SELECT id, name, description, permissions, status, 
-			metadata, created_at, updated_at
-		FROM roles
-		WHERE name = $1`
+// Guest Availability Operations
 
-	err := p.pool.QueryRow(ctx, query, name).Scan(
-		&role.ID, &role.Name, &role.Description, &role.Permissions,
-		&role.Status, &role.Metadata, &role.CreatedAt, &role.UpdatedAt,
-	)
+// GetGuestAvailability returns a guest's availability, or nil when it was never set
+func (p *PostgresDB) GetGuestAvailability(ctx context.Context, guestID uuid.UUID) (*models.GuestAvailability, error) {
+	availability := &models.GuestAvailability{}
+	var windowsJSON, blackoutsJSON []byte
 
+	err := p.pool.QueryRow(ctx, `
+		SELECT guest_id, timezone, windows, blackouts, updated_at
+		FROM guest_availability
+		WHERE guest_id = $1`, guestID,
+	).Scan(&availability.GuestID, &availability.Timezone, &windowsJSON, &blackoutsJSON, &availability.UpdatedAt)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get guest availability: %w", err)
 	}
 
-	return &role, nil
-}
-
-// UpdateRole updates role information
-func (p *PostgresDB) UpdateRole(ctx context.Context, role *models.Role) error {
-	query := `
-		UPDATE roles SET
-			name = COALESCE($2, name),
-			description = COALESCE($3, description),
-			permissions = COALESCE($4, permissions),
-			status = COALESCE($5, status),
-			metadata = COALESCE($6, metadata),
-			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1`
-
-	_, err := p.pool.Exec(ctx, query,
-		role.ID, role.Name, role.Description,
-		role.Permissions, role.Status, role.Metadata,
-	)
-
-	return err
+	if err := unmarshalGuestAvailability(availability, windowsJSON, blackoutsJSON); err != nil {
+		return nil, err
+	}
+	return availability, nil
 }
 
-// DeleteRole deletes a role
-func (p *PostgresDB) DeleteRole(ctx context.Context, roleID uuid.UUID) error {
-	// Check if role has associated users
-	var userCount int
-	err := p.pool.QueryRow(ctx, 
-		"SELECT COUNT(*) FROM user_roles WHERE role_id = $1", 
-		roleID).Scan(&userCount)
+// ListGuestAvailability returns the availability of several guests keyed by guest.
+// Guests whose availability was never set are missing from the map.
+func (p *PostgresDB) ListGuestAvailability(ctx context.Context, guestIDs []uuid.UUID) (map[uuid.UUID]*models.GuestAvailability, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT guest_id, timezone, windows, blackouts, updated_at
+		FROM guest_availability
+		WHERE guest_id = ANY($1)`, guestIDs)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to list guest availability: %w", err)
 	}
+	defer rows.Close()
 
-	if userCount > 0 {
-		return fmt.Errorf("cannot delete role with %d associated users", userCount)
+	availabilities := make(map[uuid.UUID]*models.GuestAvailability, len(guestIDs))
+	for rows.Next() {
+		availability := &models.GuestAvailability{}
+		var windowsJSON, blackoutsJSON []byte
+		if err := rows.Scan(&availability.GuestID, &availability.Timezone, &windowsJSON, &blackoutsJSON, &availability.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan guest availability: %w", err)
+		}
+		if err := unmarshalGuestAvailability(availability, windowsJSON, blackoutsJSON); err != nil {
+			return nil, err
+		}
+		availabilities[availability.GuestID] = availability
 	}
 
-	// Delete the role
-	_, err = p.pool.Exec(ctx, "DELETE FROM roles WHERE id = $1", roleID)
-	return err
+	return availabilities, rows.Err()
 }
 
-// ListRoles retrieves a paginated list of roles with filters
-func (p *PostgresDB) ListRoles(ctx context.Context, filters *models.RoleFilters, sort *models.RoleSortOptions, pagination *models.PaginationOptions) ([]models.RoleListItem, int, error) {
-	// Build base query (simplified - no user count for REST API)
-	query := `
-		SELECT r.id, r.name, r.description, r.permissions, r.status, 
-			r.created_at
-		FROM roles r`
+// SetGuestAvailability replaces a guest's availability
+func (p *PostgresDB) SetGuestAvailability(ctx context.Context, availability *models.GuestAvailability) error {
+	if availability.Windows == nil {
+		availability.Windows = []models.GuestAvailabilityWindow{}
+	}
+	if availability.Blackouts == nil {
+		availability.Blackouts = []models.GuestBlackout{}
+	}
 
-	countQuery := `SELECT COUNT(*) FROM roles r`
+	windowsJSON, err := json.Marshal(availability.Windows)
+	if err != nil {
+		return fmt.Errorf("failed to marshal availability windows: %w", err)
+	}
+	blackoutsJSON, err := json.Marshal(availability.Blackouts)
+	if err != nil {
+		return fmt.Errorf("failed to marshal blackouts: %w", err)
+	}
+
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO guest_availability (guest_id, timezone, windows, blackouts, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (guest_id) DO UPDATE SET
+			timezone = EXCLUDED.timezone, windows = EXCLUDED.windows,
+			blackouts = EXCLUDED.blackouts, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		availability.GuestID, availability.Timezone, windowsJSON, blackoutsJSON,
+	).Scan(&availability.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set guest availability: %w", err)
+	}
+	return nil
+}
 
-	// Build WHERE clause
-	var whereConditions []string
-	var args []interface{}
-	argCount := 0
+// ListGuestBookingsBetween returns the bookings of several guests into events overlapping
+// the given time range. A guest on several blocks of an event is booked once.
+func (p *PostgresDB) ListGuestBookingsBetween(ctx context.Context, guestIDs []uuid.UUID, from, to time.Time) ([]models.GuestBooking, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT DISTINCT bg.guest_id, e.id, COALESCE(e.event_title, s.show_name), s.id, s.show_name,
+			e.start_datetime, e.end_datetime
+		FROM block_guests bg
+		JOIN blocks b ON b.id = bg.block_id
+		JOIN events e ON e.id = b.event_id
+		JOIN shows s ON s.id = e.show_id
+		WHERE bg.guest_id = ANY($1) AND e.status <> 'cancelled'
+			AND e.start_datetime < $3 AND e.end_datetime > $2
+		ORDER BY e.start_datetime`, guestIDs, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guest bookings: %w", err)
+	}
+	defer rows.Close()
 
-	if filters != nil {
-		if len(filters.Status) > 0 {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("r.status = ANY($%d)", argCount))
-			args = append(args, filters.Status)
+	bookings := []models.GuestBooking{}
+	for rows.Next() {
+		var booking models.GuestBooking
+		if err := rows.Scan(
+			&booking.GuestID, &booking.EventID, &booking.EventTitle, &booking.ShowID, &booking.ShowName,
+			&booking.StartDatetime, &booking.EndDatetime,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan guest booking: %w", err)
 		}
+		bookings = append(bookings, booking)
+	}
 
-		if filters.Search != "" {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("(r.name ILIKE $%d OR r.description ILIKE $%d)", argCount, argCount))
-			searchTerm := "%" + filters.Search + "%"
-			args = append(args, searchTerm)
-		}
+	return bookings, rows.Err()
+}
 
-		if len(filters.Permissions) > 0 {
-			argCount++
-			whereConditions = append(whereConditions, fmt.Sprintf("r.permissions && $%d", argCount))
-			args = append(args, filters.Permissions)
-		}
+func unmarshalGuestAvailability(availability *models.GuestAvailability, windowsJSON, blackoutsJSON []byte) error {
+	if err := json.Unmarshal(windowsJSON, &availability.Windows); err != nil {
+		return fmt.Errorf("failed to unmarshal availability windows: %w", err)
 	}
-
-	if len(whereConditions) > 0 {
-		whereClause := " WHERE " + strings.Join(whereConditions, " AND ")
-		query += whereClause
-		countQuery += whereClause
+	if err := json.Unmarshal(blackoutsJSON, &availability.Blackouts); err != nil {
+		return fmt.Errorf("failed to unmarshal blackouts: %w", err)
 	}
+	return nil
+}
 
-	// Get total count
-	var totalCount int
-	err := p.pool.QueryRow(ctx, countQuery, args...).Scan(&totalCount)
-	if err != nil {
-		return nil, 0, err
-	}
+// Guest Tag Operations
 
-	// Note: No GROUP BY needed since we're not counting users
+// ErrGuestTagCycle is returned when a tag would be placed below itself
+var ErrGuestTagCycle = errors.New("a tag can't be placed below itself")
 
-	// Add sorting
-	if sort != nil {
-		orderClause := fmt.Sprintf(" ORDER BY r.%s %s", sort.Field, sort.Order)
-		query += orderClause
-	} else {
-		query += " ORDER BY r.name ASC"
-	}
+// guestTagSelect selects a user's tags with the number of guests carrying each, ignoring case
+const guestTagSelect = `
+	SELECT t.id, t.user_id, t.name, t.color, t.parent_id, t.description, t.created_at, t.updated_at,
+		(SELECT COUNT(*) FROM guests g
+		 WHERE g.user_id = t.user_id AND jsonb_typeof(g.tags) = 'array'
+			AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(g.tags) gt WHERE LOWER(gt) = LOWER(t.name)))
+	FROM guest_tags t`
 
-	// Add pagination
-	if pagination != nil {
-		limit := pagination.Limit
-		if limit <= 0 {
-			limit = 20
-		}
-		offset := (pagination.Page - 1) * limit
-		query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+func scanGuestTag(row pgx.Row) (*models.GuestTag, error) {
+	var tag models.GuestTag
+	err := row.Scan(
+		&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.ParentID, &tag.Description,
+		&tag.CreatedAt, &tag.UpdatedAt, &tag.GuestCount,
+	)
+	if err != nil {
+		return nil, err
 	}
+	return &tag, nil
+}
 
-	// Execute query
-	rows, err := p.pool.Query(ctx, query, args...)
+// ListGuestTags returns a user's tag vocabulary ordered by name
+func (p *PostgresDB) ListGuestTags(ctx context.Context, userID uuid.UUID) ([]models.GuestTag, error) {
+	rows, err := p.pool.Query(ctx, guestTagSelect+` WHERE t.user_id = $1 ORDER BY LOWER(t.name)`, userID)
 	if err != nil {
-		return nil, 0, err
+		return nil, fmt.Errorf("failed to list guest tags: %w", err)
 	}
 	defer rows.Close()
 
-	var roles []models.RoleListItem
+	tags := []models.GuestTag{}
 	for rows.Next() {
-		var role models.RoleListItem
-		err := rows.Scan(
-			&role.ID, &role.Name, &role.Description, &role.Permissions,
-			&role.Status, &role.CreatedAt,
-		)
+		tag, err := scanGuestTag(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, fmt.Errorf("failed to scan guest tag: %w", err)
 		}
-		roles = append(roles, role)
+		tags = append(tags, *tag)
 	}
 
-	return roles, totalCount, nil
+	return tags, rows.Err()
 }
 
-// GetUserPermissions retrieves all permissions for a user based on their roles
-func (p *PostgresDB) GetUserPermissions(ctx context.Context, userID uuid.UUID) ([]string, error) {
-	query := `
-		SELECT DISTINCT unnest(r.permissions) as permission
-		FROM roles r
-		JOIN user_roles ur ON r.id = ur.role_id
-		WHERE ur.user_id = $1 AND r.status = 'active'`
+// GetGuestTag returns a tag by ID
+func (p *PostgresDB) GetGuestTag(ctx context.Context, tagID uuid.UUID) (*models.GuestTag, error) {
+	tag, err := scanGuestTag(p.pool.QueryRow(ctx, guestTagSelect+` WHERE t.id = $1`, tagID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest tag: %w", err)
+	}
+	return tag, nil
+}
 
-	rows, err := p.pool.Query(ctx, query, userID)
+// CreateGuestTag adds a tag to a user's vocabulary
+func (p *PostgresDB) CreateGuestTag(ctx context.Context, tag *models.GuestTag) error {
+	err := p.pool.QueryRow(ctx, `
+		INSERT INTO guest_tags (user_id, name, color, parent_id, description)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		tag.UserID, tag.Name, tag.Color, tag.ParentID, tag.Description,
+	).Scan(&tag.ID, &tag.CreatedAt, &tag.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to create guest tag: %w", err)
+	}
+	return nil
+}
+
+// EnsureGuestTags adds the tags missing from a user's vocabulary and returns the names in
+// their vocabulary spelling, in the given order. Names are matched ignoring case.
+func (p *PostgresDB) EnsureGuestTags(ctx context.Context, userID uuid.UUID, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return names, nil
+	}
+
+	_, err := p.pool.Exec(ctx, `
+		INSERT INTO guest_tags (user_id, name)
+		SELECT $1, name FROM unnest($2::text[]) AS name
+		ON CONFLICT (user_id, LOWER(name)) DO NOTHING`,
+		userID, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add guest tags: %w", err)
+	}
+
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(name)
+	}
+	rows, err := p.pool.Query(ctx, `
+		SELECT name FROM guest_tags WHERE user_id = $1 AND LOWER(name) = ANY($2)`,
+		userID, lowered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest tags: %w", err)
 	}
 	defer rows.Close()
 
-	var permissions []string
+	spelling := make(map[string]string, len(names))
 	for rows.Next() {
-		var permission string
-		if err := rows.Scan(&permission); err != nil {
-			return nil, err
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan guest tag: %w", err)
 		}
-		permissions = append(permissions, permission)
+		spelling[strings.ToLower(name)] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get guest tags: %w", err)
 	}
 
-	return permissions, nil
-}
-
-// CheckUserPermission checks if a user has a specific permission
-func (p *PostgresDB) CheckUserPermission(ctx context.Context, userID uuid.UUID, permission string) (bool, error) {
-	query := `
-		SELECT EXISTS(
-			SELECT 1 FROM roles r
-			JOIN user_roles ur ON r.id = ur.role_id
-			WHERE ur.user_id = $1 
-				AND r.status = 'active'
-				AND $2 = ANY(r.permissions)
-		)`
-
-	var hasPermission bool
-	err := p.pool.QueryRow(ctx, query, userID, permission).Scan(&hasPermission)
-	return hasPermission, err
+	canonical := make([]string, len(names))
+	for i, name := range names {
+		canonical[i] = name
+		if known, ok := spelling[lowered[i]]; ok {
+			canonical[i] = known
+		}
+	}
+	return canonical, nil
 }
 
-// Session Management Operations
-
-// CreateSession creates a new user session
-func (p *PostgresDB) CreateSession(ctx context.Context, session *models.Session) error {
-	// Don't override session ID if already set
-	if session.ID == uuid.Nil {
-		session.ID = uuid.New()
-	}
-	// Don't override timestamps if already set
-	if session.CreatedAt.IsZero() {
-		session.CreatedAt = time.Now()
+// UpdateGuestTag saves a tag's name, color, parent and description. A new name is applied
+// to every guest of the user carrying the old one. Returns how many guests changed.
+func (p *PostgresDB) UpdateGuestTag(ctx context.Context, tag *models.GuestTag, oldName string) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if session.LastActivity.IsZero() {
-		session.LastActivity = time.Now()
+	defer tx.Rollback(ctx)
+
+	if tag.ParentID != nil {
+		var cycle bool
+		err := tx.QueryRow(ctx, `
+			WITH RECURSIVE ancestors AS (
+				SELECT id, parent_id FROM guest_tags WHERE id = $1
+				UNION
+				SELECT t.id, t.parent_id FROM guest_tags t JOIN ancestors a ON t.id = a.parent_id
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`,
+			*tag.ParentID, tag.ID,
+		).Scan(&cycle)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check tag hierarchy: %w", err)
+		}
+		if cycle {
+			return 0, ErrGuestTagCycle
+		}
 	}
 
-	query := `
-		INSERT INTO sessions (id, user_id, refresh_token, device_name, device_type, 
-			ip_address, user_agent, is_active, expires_at, created_at, last_activity, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		RETURNING id, created_at, last_activity`
+	err = tx.QueryRow(ctx, `
+		UPDATE guest_tags SET name = $2, color = $3, parent_id = $4, description = $5
+		WHERE id = $1
+		RETURNING updated_at`,
+		tag.ID, tag.Name, tag.Color, tag.ParentID, tag.Description,
+	).Scan(&tag.UpdatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update guest tag: %w", err)
+	}
 
-	err := p.pool.QueryRow(ctx, query,
-		session.ID, session.UserID, session.RefreshToken, session.DeviceName,
-		session.DeviceType, session.IPAddress, session.UserAgent, session.IsActive,
-		session.ExpiresAt, session.CreatedAt, session.LastActivity, session.Metadata,
-	).Scan(&session.ID, &session.CreatedAt, &session.LastActivity)
+	var updated int64
+	if tag.Name != oldName {
+		if updated, err = rewriteGuestTags(ctx, tx, tag.UserID, []string{oldName}, tag.Name); err != nil {
+			return 0, err
+		}
+	}
 
-	return err
+	return updated, tx.Commit(ctx)
 }
 
-// GetSessionByID retrieves a session by ID
-func (p *PostgresDB) GetSessionByID(ctx context.Context, sessionID uuid.UUID) (*models.Session, error) {
-	var session models.Session
-
-	query := `
-		SELECT id, user_id, refresh_token, device_name, device_type, ip_address,
-			user_agent, is_active, expires_at, created_at, last_activity, metadata
-		FROM sessions
-		WHERE id = $1`
+// MergeGuestTags folds the source tags into the target: guests carrying a source tag carry
+// the target instead, tags below a source move below the target and the sources are
+// deleted. Returns ErrGuestTagCycle when the target is below a source, otherwise how many
+// guests changed.
+func (p *PostgresDB) MergeGuestTags(ctx context.Context, target *models.GuestTag, sources []models.GuestTag) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	err := p.pool.QueryRow(ctx, query, sessionID).Scan(
-		&session.ID, &session.UserID, &session.RefreshToken, &session.DeviceName,
-		&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.IsActive,
-		&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
-	)
+	names := make([]string, len(sources))
+	ids := make([]uuid.UUID, len(sources))
+	for i, source := range sources {
+		names[i] = source.Name
+		ids[i] = source.ID
+	}
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
+	// Children of the sources move below the target, which can't be one of them
+	var below bool
+	err = tx.QueryRow(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM guest_tags WHERE id = $1
+			UNION
+			SELECT t.id, t.parent_id FROM guest_tags t JOIN ancestors a ON t.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ANY($2))`,
+		target.ID, ids,
+	).Scan(&below)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check tag hierarchy: %w", err)
+	}
+	if below {
+		return 0, ErrGuestTagCycle
+	}
+
+	updated, err := rewriteGuestTags(ctx, tx, target.UserID, names, target.Name)
+	if err != nil {
+		return 0, err
 	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE guest_tags SET parent_id = $1
+		WHERE parent_id = ANY($2) AND NOT (id = ANY($2))`,
+		target.ID, ids)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("failed to move child tags: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM guest_tags WHERE id = ANY($1)`, ids); err != nil {
+		return 0, fmt.Errorf("failed to delete merged tags: %w", err)
 	}
 
-	return &session, nil
+	return updated, tx.Commit(ctx)
 }
 
-// GetSessionByRefreshToken retrieves a session by refresh token
-func (p *PostgresDB) GetSessionByRefreshToken(ctx context.Context, refreshToken string) (*models.Session, error) {
-	var session models.Session
-
-	query := `
-		SELECT id, user_id, refresh_token, device_name, device_type, ip_address,
-			user_agent, is_active, expires_at, created_at, last_activity, metadata
-		FROM sessions
-		WHERE refresh_token = $1 AND is_active = true`
+// DeleteGuestTag removes a tag from the vocabulary and from every guest carrying it. Tags
+// below it move up to its parent. Returns how many guests changed.
+func (p *PostgresDB) DeleteGuestTag(ctx context.Context, tag *models.GuestTag) (int64, error) {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
 
-	err := p.pool.QueryRow(ctx, query, refreshToken).Scan(
-		&session.ID, &session.UserID, &session.RefreshToken, &session.DeviceName,
-		&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.IsActive,
-		&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
-	)
+	updated, err := rewriteGuestTags(ctx, tx, tag.UserID, []string{tag.Name}, "")
+	if err != nil {
+		return 0, err
+	}
 
-	if err == pgx.ErrNoRows {
-		return nil, nil
+	if _, err := tx.Exec(ctx, `UPDATE guest_tags SET parent_id = $2 WHERE parent_id = $1`, tag.ID, tag.ParentID); err != nil {
+		return 0, fmt.Errorf("failed to move child tags: %w", err)
 	}
-	if err != nil {
-		return nil, err
+	if _, err := tx.Exec(ctx, `DELETE FROM guest_tags WHERE id = $1`, tag.ID); err != nil {
+		return 0, fmt.Errorf("failed to delete guest tag: %w", err)
 	}
 
-	return &session, nil
+	return updated, tx.Commit(ctx)
 }
 
-// UpdateSession updates session information
-func (p *PostgresDB) UpdateSession(ctx context.Context, session *models.Session) error {
-	query := `
-		UPDATE sessions SET
-			refresh_token = $2,
-			device_name = $3,
-			device_type = $4,
-			ip_address = $5,
-			user_agent = $6,
-			is_active = $7,
-			expires_at = $8,
-			last_activity = CURRENT_TIMESTAMP,
-			metadata = $9
-		WHERE id = $1`
-
-	_, err := p.pool.Exec(ctx, query,
-		session.ID, session.RefreshToken, session.DeviceName, session.DeviceType,
-		session.IPAddress, session.UserAgent, session.IsActive, session.ExpiresAt,
-		session.Metadata,
-	)
-
-	return err
+// rewriteGuestTags renames the given tags on every guest of the user, or removes them when
+// to is empty. Tags are matched ignoring case and keep their position; a guest ending up
+// with a tag twice keeps the first.
+func rewriteGuestTags(ctx context.Context, tx pgx.Tx, userID uuid.UUID, from []string, to string) (int64, error) {
+	lowered := make([]string, len(from))
+	for i, name := range from {
+		lowered[i] = strings.ToLower(name)
+	}
+
+	result, err := tx.Exec(ctx, `
+		UPDATE guests SET tags = (
+			SELECT COALESCE(jsonb_agg(tag ORDER BY pos), '[]'::jsonb)
+			FROM (
+				SELECT CASE WHEN LOWER(elem) = ANY($2) THEN $3::text ELSE elem END AS tag, MIN(idx) AS pos
+				FROM jsonb_array_elements_text(guests.tags) WITH ORDINALITY AS t(elem, idx)
+				WHERE $3::text <> '' OR NOT (LOWER(elem) = ANY($2))
+				GROUP BY 1
+			) rewritten
+		)
+		WHERE user_id = $1 AND jsonb_typeof(tags) = 'array' AND EXISTS (
+			SELECT 1 FROM jsonb_array_elements_text(tags) gt WHERE LOWER(gt) = ANY($2)
+		)`,
+		userID, lowered, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update guest tags: %w", err)
+	}
+	return result.RowsAffected(), nil
 }
 
-// UpdateSessionActivity updates the last activity timestamp for a session
-func (p *PostgresDB) UpdateSessionActivity(ctx context.Context, sessionID uuid.UUID) error {
-	query := `
-		UPDATE sessions SET last_activity = CURRENT_TIMESTAMP 
-		WHERE id = $1 AND is_active = true`
+// Guest Segment Operations
 
-	_, err := p.pool.Exec(ctx, query, sessionID)
-	return err
-}
+const guestSegmentColumns = `id, user_id, name, description, filters, shared, created_at, updated_at`
 
-// DeleteSession deletes a session by ID
-func (p *PostgresDB) DeleteSession(ctx context.Context, sessionID uuid.UUID) error {
-	query := `DELETE FROM sessions WHERE id = $1`
-	_, err := p.pool.Exec(ctx, query, sessionID)
-	return err
+func scanGuestSegment(row pgx.Row) (*models.GuestSegment, error) {
+	var segment models.GuestSegment
+	var filtersJSON []byte
+	err := row.Scan(
+		&segment.ID, &segment.UserID, &segment.Name, &segment.Description, &filtersJSON,
+		&segment.Shared, &segment.CreatedAt, &segment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(filtersJSON, &segment.Filters); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal segment filters: %w", err)
+	}
+	return &segment, nil
 }
 
-// DeleteUserSessions deletes all sessions for a user
-func (p *PostgresDB) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
-	query := `DELETE FROM sessions WHERE user_id = $1`
-	_, err := p.pool.Exec(ctx, query, userID)
-	return err
+// CreateGuestSegment saves a guest segment
+func (p *PostgresDB) CreateGuestSegment(ctx context.Context, segment *models.GuestSegment) error {
+	filtersJSON, err := json.Marshal(segment.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal segment filters: %w", err)
+	}
+
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO guest_segments (user_id, name, description, filters, shared)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		segment.UserID, segment.Name, segment.Description, filtersJSON, segment.Shared,
+	).Scan(&segment.ID, &segment.CreatedAt, &segment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create guest segment: %w", err)
+	}
+	return nil
 }
 
-// GetUserSessions retrieves all active sessions for a user
-func (p *PostgresDB) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
-	query := `
-		SELECT id, user_id, refresh_token, device_name, device_type, ip_address,
-			user_agent, is_active, expires_at, created_at, last_activity, metadata
-		FROM sessions
-		WHERE user_id = $1 AND is_active = true AND expires_at > CURRENT_TIMESTAMP
-		ORDER BY last_activity DESC`
+// GetGuestSegment returns a segment by ID
+func (p *PostgresDB) GetGuestSegment(ctx context.Context, segmentID uuid.UUID) (*models.GuestSegment, error) {
+	segment, err := scanGuestSegment(p.pool.QueryRow(ctx,
+		`SELECT `+guestSegmentColumns+` FROM guest_segments WHERE id = $1`, segmentID))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest segment: %w", err)
+	}
+	return segment, nil
+}
 
-	rows, err := p.pool.Query(ctx, query, userID)
+// ListGuestSegments returns a user's segments and those their team mates shared, by name
+func (p *PostgresDB) ListGuestSegments(ctx context.Context, userID uuid.UUID) ([]models.GuestSegment, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT `+guestSegmentColumns+`
+		FROM guest_segments
+		WHERE user_id = $1 OR (shared AND user_id IN `+visibleOwners(1)+`)
+		ORDER BY LOWER(name), created_at`, userID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list guest segments: %w", err)
 	}
 	defer rows.Close()
 
-	var sessions []models.Session
+	segments := []models.GuestSegment{}
 	for rows.Next() {
-		var session models.Session
-		err := rows.Scan(
-			&session.ID, &session.UserID, &session.RefreshToken, &session.DeviceName,
-			&session.DeviceType, &session.IPAddress, &session.UserAgent, &session.IsActive,
-			&session.ExpiresAt, &session.CreatedAt, &session.LastActivity, &session.Metadata,
-		)
+		segment, err := scanGuestSegment(rows)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to scan guest segment: %w", err)
 		}
-		sessions = append(sessions, session)
+		segments = append(segments, *segment)
 	}
 
-	return sessions, nil
+	return segments, rows.Err()
 }
 
-// Token Blacklist Operations
+// CanViewGuestSegment reports whether a user owns a segment or it was shared by a team mate
+func (p *PostgresDB) CanViewGuestSegment(ctx context.Context, segment *models.GuestSegment, userID uuid.UUID) (bool, error) {
+	if segment.UserID == userID {
+		return true, nil
+	}
+	if !segment.Shared {
+		return false, nil
+	}
 
-// CreateTokenBlacklist adds a token to the blacklist
-func (p *PostgresDB) CreateTokenBlacklist(ctx context.Context, blacklist *models.TokenBlacklist) error {
-	blacklist.ID = uuid.New()
-	blacklist.CreatedAt = time.Now()
+	var visible bool
+	err := p.pool.QueryRow(ctx, `SELECT $2::uuid IN `+visibleOwners(1), userID, segment.UserID).Scan(&visible)
+	if err != nil {
+		return false, fmt.Errorf("failed to check segment visibility: %w", err)
+	}
+	return visible, nil
+}
 
-	query := `
-		INSERT INTO token_blacklist (id, token_jti, user_id, expires_at, created_at, reason)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at`
+// UpdateGuestSegment saves a segment's name, description, filters and sharing
+func (p *PostgresDB) UpdateGuestSegment(ctx context.Context, segment *models.GuestSegment) error {
+	filtersJSON, err := json.Marshal(segment.Filters)
+	if err != nil {
+		return fmt.Errorf("failed to marshal segment filters: %w", err)
+	}
 
-	err := p.pool.QueryRow(ctx, query,
-		blacklist.ID, blacklist.TokenJTI, blacklist.UserID,
-		blacklist.ExpiresAt, blacklist.CreatedAt, blacklist.Reason,
-	).Scan(&blacklist.ID, &blacklist.CreatedAt)
+	err = p.pool.QueryRow(ctx, `
+		UPDATE guest_segments SET name = $2, description = $3, filters = $4, shared = $5
+		WHERE id = $1
+		RETURNING updated_at`,
+		segment.ID, segment.Name, segment.Description, filtersJSON, segment.Shared,
+	).Scan(&segment.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update guest segment: %w", err)
+	}
+	return nil
+}
 
-	return err
+// DeleteGuestSegment deletes a segment
+func (p *PostgresDB) DeleteGuestSegment(ctx context.Context, segmentID uuid.UUID) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM guest_segments WHERE id = $1`, segmentID); err != nil {
+		return fmt.Errorf("failed to delete guest segment: %w", err)
+	}
+	return nil
 }
 
-// IsTokenBlacklisted checks if a token is blacklisted
-func (p *PostgresDB) IsTokenBlacklisted(ctx context.Context, jti string) (bool, error) {
-	query := `
-		SELECT EXISTS(
-			SELECT 1 FROM token_blacklist 
-			WHERE token_jti = $1 AND expires_at > CURRENT_TIMESTAMP
-		)`
+// CountGuests counts a user's guests matching the filters
+func (p *PostgresDB) CountGuests(ctx context.Context, userID uuid.UUID, filters models.GuestFilters) (int, error) {
+	whereClause, args := guestFilterClause(userID, filters)
 
-	var exists bool
-	err := p.pool.QueryRow(ctx, query, jti).Scan(&exists)
-	return exists, err
+	var count int
+	if err := p.pool.QueryRow(ctx, "SELECT COUNT(*) FROM guests WHERE "+whereClause, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count guests: %w", err)
+	}
+	return count, nil
 }
 
-// DeleteExpiredTokens removes expired tokens from blacklist
-func (p *PostgresDB) DeleteExpiredTokens(ctx context.Context) error {
-	query := `DELETE FROM token_blacklist WHERE expires_at <= CURRENT_TIMESTAMP`
-	_, err := p.pool.Exec(ctx, query)
-	return err
+// Rundown Template Operations
+
+// ErrRundownHasGuests is returned when blocks with guests would be replaced by a template
+var ErrRundownHasGuests = errors.New("event blocks have guests")
+
+// GetRundownTemplate returns a show's rundown template, or nil when it has none
+func (p *PostgresDB) GetRundownTemplate(ctx context.Context, showID uuid.UUID) (*models.RundownTemplate, error) {
+	template, err := getRundownTemplate(ctx, p.pool, showID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rundown template: %w", err)
+	}
+	return template, nil
 }
 
-// OAuth State Management Operations
+// SetRundownTemplate replaces a show's rundown template
+func (p *PostgresDB) SetRundownTemplate(ctx context.Context, template *models.RundownTemplate) error {
+	blocksJSON, err := json.Marshal(template.Blocks)
+	if err != nil {
+		return fmt.Errorf("failed to marshal template blocks: %w", err)
+	}
 
-// CreateOAuthState creates a new OAuth state for CSRF protection
-func (p *PostgresDB) CreateOAuthState(ctx context.Context, state *models.OAuthState) error {
-	query := `
-		INSERT INTO oauth_states (id, state, user_id, expires_at, created_at, metadata)
-		VALUES (gen_random_uuid(), $1, $2, $3, CURRENT_TIMESTAMP, $4)`
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO show_rundown_templates (show_id, blocks, auto_apply, updated_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)
+		ON CONFLICT (show_id) DO UPDATE SET
+			blocks = EXCLUDED.blocks, auto_apply = EXCLUDED.auto_apply, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`,
+		template.ShowID, blocksJSON, template.AutoApply,
+	).Scan(&template.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to set rundown template: %w", err)
+	}
+	return nil
+}
 
-	_, err := p.pool.Exec(ctx, query, state.State, nil, state.ExpiresAt, nil)
-	return err
+// DeleteRundownTemplate removes a show's rundown template. Blocks created from it stay.
+func (p *PostgresDB) DeleteRundownTemplate(ctx context.Context, showID uuid.UUID) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM show_rundown_templates WHERE show_id = $1`, showID); err != nil {
+		return fmt.Errorf("failed to delete rundown template: %w", err)
+	}
+	return nil
 }
 
-// ValidateOAuthState validates and consumes an OAuth state
-func (p *PostgresDB) ValidateOAuthState(ctx context.Context, state string) (bool, error) {
+// ApplyRundownTemplate creates the template's blocks in an event, after its existing blocks
+// or in their place. Replacing fails with ErrRundownHasGuests when a block has guests.
+// Returns how many blocks were created.
+func (p *PostgresDB) ApplyRundownTemplate(ctx context.Context, event *models.Event, template *models.RundownTemplate, replace bool) (int, error) {
 	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		return false, err
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Check if state exists and is not expired
-	var exists bool
-	checkQuery := `
-		SELECT EXISTS(
-			SELECT 1 FROM oauth_states 
-			WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP
-		)`
+	if replace {
+		var hasGuests bool
+		err := tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM block_guests bg JOIN blocks b ON b.id = bg.block_id WHERE b.event_id = $1
+			)`, event.ID,
+		).Scan(&hasGuests)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check block guests: %w", err)
+		}
+		if hasGuests {
+			return 0, ErrRundownHasGuests
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM blocks WHERE event_id = $1`, event.ID); err != nil {
+			return 0, fmt.Errorf("failed to delete event blocks: %w", err)
+		}
+	}
 
-	err = tx.QueryRow(ctx, checkQuery, state).Scan(&exists)
+	created, err := applyRundownTemplate(ctx, tx, template, event)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	if !exists {
-		return false, nil
+	return created, tx.Commit(ctx)
+}
+
+// RundownTemplateFromEvent describes an event's blocks as template blocks, in rundown order.
+// Guests aren't part of a template.
+func (p *PostgresDB) RundownTemplateFromEvent(ctx context.Context, eventID uuid.UUID) ([]models.RundownTemplateBlock, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, block_type, title, description, topic, estimated_length, metadata
+		FROM blocks
+		WHERE event_id = $1
+		ORDER BY order_index`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event blocks: %w", err)
 	}
+	defer rows.Close()
 
-	// Delete the state (one-time use)
-	deleteQuery := `DELETE FROM oauth_states WHERE state = $1`
-	_, err = tx.Exec(ctx, deleteQuery, state)
+	blocks := []models.RundownTemplateBlock{}
+	var blockIDs []uuid.UUID
+	for rows.Next() {
+		var block models.RundownTemplateBlock
+		var blockID uuid.UUID
+		var metadataJSON []byte
+		if err := rows.Scan(
+			&blockID, &block.BlockType, &block.TitlePattern, &block.Description, &block.Topic,
+			&block.EstimatedLength, &metadataJSON,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan event block: %w", err)
+		}
+		if len(metadataJSON) > 0 {
+			if err := json.Unmarshal(metadataJSON, &block.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal block metadata: %w", err)
+			}
+		}
+		blocks = append(blocks, block)
+		blockIDs = append(blockIDs, blockID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list event blocks: %w", err)
+	}
+	if len(blocks) == 0 {
+		return blocks, nil
+	}
+
+	mediaRows, err := p.pool.Query(ctx, `
+		SELECT block_id, media_id, media_type, title, description, order_index
+		FROM block_media
+		WHERE block_id = ANY($1)
+		ORDER BY order_index`, blockIDs)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("failed to list block media: %w", err)
 	}
+	defer mediaRows.Close()
 
-	return true, tx.Commit(ctx)
+	index := make(map[uuid.UUID]int, len(blockIDs))
+	for i, id := range blockIDs {
+		index[id] = i
+	}
+	for mediaRows.Next() {
+		var blockID, mediaID uuid.UUID
+		var media models.BlockMediaInput
+		if err := mediaRows.Scan(&blockID, &mediaID, &media.MediaType, &media.Title, &media.Description, &media.OrderIndex); err != nil {
+			return nil, fmt.Errorf("failed to scan block media: %w", err)
+		}
+		media.MediaID = mediaID.String()
+		block := &blocks[index[blockID]]
+		block.Media = append(block.Media, media)
+	}
+	if err := mediaRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list block media: %w", err)
+	}
+
+	return blocks, nil
 }
 
-// GetOAuthState retrieves an OAuth state record
-func (p *PostgresDB) GetOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
-	var oauthState models.OAuthState
-	query := `
-		SELECT state, expires_at, user_id
-		FROM oauth_states 
-		WHERE state = $1 AND expires_at > CURRENT_TIMESTAMP`
+// getRundownTemplate loads a show's rundown template through a pool or a transaction
+func getRundownTemplate(ctx context.Context, q querier, showID uuid.UUID) (*models.RundownTemplate, error) {
+	template := &models.RundownTemplate{}
+	var blocksJSON []byte
 
-	err := p.pool.QueryRow(ctx, query, state).Scan(
-		&oauthState.State, &oauthState.ExpiresAt, &oauthState.UserID)
-	
+	err := q.QueryRow(ctx, `
+		SELECT show_id, blocks, auto_apply, updated_at
+		FROM show_rundown_templates
+		WHERE show_id = $1`, showID,
+	).Scan(&template.ShowID, &blocksJSON, &template.AutoApply, &template.UpdatedAt)
 	if err == pgx.ErrNoRows {
 		return nil, nil
 	}
@@ -4004,20 +10165,74 @@ func (p *PostgresDB) GetOAuthState(ctx context.Context, state string) (*models.O
 		return nil, err
 	}
 
-	return &oauthState, nil
+	if err := json.Unmarshal(blocksJSON, &template.Blocks); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal template blocks: %w", err)
+	}
+	return template, nil
 }
 
-// DeleteOAuthState deletes an OAuth state record
-func (p *PostgresDB) DeleteOAuthState(ctx context.Context, state string) error {
-	_, err := p.pool.Exec(ctx, `DELETE FROM oauth_states WHERE state = $1`, state)
-	return err
-}
+// applyRundownTemplate creates the template's blocks after the event's existing blocks.
+// Template media deleted since the template was saved is left out.
+func applyRundownTemplate(ctx context.Context, tx pgx.Tx, template *models.RundownTemplate, event *models.Event) (int, error) {
+	var showName string
+	var episode int
+	err := tx.QueryRow(ctx, `
+		SELECT s.show_name, (
+			SELECT COUNT(*) FROM events e
+			WHERE e.show_id = s.id AND e.status <> 'cancelled' AND e.start_datetime <= $2
+		)
+		FROM shows s WHERE s.id = $1`,
+		event.ShowID, event.StartDateTime,
+	).Scan(&showName, &episode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get show for rundown template: %w", err)
+	}
 
-// CleanupExpiredAuthData removes expired sessions, tokens, and OAuth states
-func (p *PostgresDB) CleanupExpiredAuthData(ctx context.Context) error {
-	query := `SELECT cleanup_expired_auth_data()`
-	_, err := p.pool.Exec(ctx, query)
-	return err
+	var maxOrder sql.NullInt32
+	if err := tx.QueryRow(ctx, `SELECT MAX(order_index) FROM blocks WHERE event_id = $1`, event.ID).Scan(&maxOrder); err != nil {
+		return 0, fmt.Errorf("failed to get max order index: %w", err)
+	}
+	orderIndex := 0
+	if maxOrder.Valid {
+		orderIndex = int(maxOrder.Int32) + 1
+	}
+
+	for i, templateBlock := range template.Blocks {
+		metadataJSON, err := json.Marshal(templateBlock.Metadata)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+		if templateBlock.Metadata == nil {
+			metadataJSON = []byte("{}")
+		}
+
+		title := utils.RenderRundownTitle(templateBlock.TitlePattern, showName, event.EventTitle, event.StartDateTime, episode)
+		var blockID uuid.UUID
+		err = tx.QueryRow(ctx, `
+			INSERT INTO blocks (event_id, user_id, title, description, topic,
+				estimated_length, order_index, block_type, status, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id`,
+			event.ID, event.UserID, title, templateBlock.Description, templateBlock.Topic,
+			templateBlock.EstimatedLength, orderIndex+i, templateBlock.BlockType,
+			models.BlockStatusPlanned, metadataJSON,
+		).Scan(&blockID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create block from template: %w", err)
+		}
+
+		for _, media := range templateBlock.Media {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO block_media (block_id, media_id, media_type, title, description, order_index)
+				SELECT $1, id, $3, $4, $5, $6 FROM media WHERE id = $2`,
+				blockID, media.MediaID, media.MediaType, media.Title, media.Description, media.OrderIndex)
+			if err != nil {
+				return 0, fmt.Errorf("failed to add template media to block: %w", err)
+			}
+		}
+	}
+
+	return len(template.Blocks), nil
 }
 
 // Helper functions for nullable update operations
@@ -19,6 +19,7 @@ type Post struct {
 	MediaCount          int        `json:"media_count" db:"media_count"`
 	TotalSize           int64      `json:"total_size" db:"total_size"`
 	ErrorMessage        *string    `json:"error_message,omitempty" db:"error_message"`
+	UserID              *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
 }
 
 type PostStatus string
@@ -44,6 +45,10 @@ type Media struct {
 	FileHash         string                 `json:"file_hash" db:"file_hash"`
 	DownloadedAt     time.Time              `json:"downloaded_at" db:"downloaded_at"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
+	Tags             []string               `json:"tags" db:"tags"`
+	Notes            *string                `json:"notes,omitempty" db:"notes"`
+	DurationSeconds  *int                   `json:"duration_seconds,omitempty" db:"duration_seconds"`
+	UserID           *uuid.UUID             `json:"user_id,omitempty" db:"user_id"`
 }
 
 type PaginationOptions struct {
@@ -117,6 +122,8 @@ type UpdateMediaRequest struct {
 	FileName         *string                `json:"file_name,omitempty"`
 	OriginalFileName *string                `json:"original_file_name,omitempty"`
 	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	Tags             *[]string              `json:"tags,omitempty"`
+	Notes            *string                `json:"notes,omitempty"`
 }
 
 type UpdateMediaResponse struct {
@@ -135,6 +142,463 @@ type DeleteMediaResponse struct {
 	MediaID string `json:"media_id"`
 }
 
+// Media Upload Models
+
+// UploadContentID is the content ID of the synthetic post that owns directly uploaded media
+const UploadContentID = "upload"
+
+type UploadStatus string
+
+const (
+	UploadStatusInProgress UploadStatus = "in_progress"
+	UploadStatusCompleted  UploadStatus = "completed"
+	UploadStatusAborted    UploadStatus = "aborted"
+	UploadStatusExpired    UploadStatus = "expired"
+)
+
+// MediaUpload represents a resumable chunked upload session
+type MediaUpload struct {
+	ID              uuid.UUID    `json:"upload_id" db:"id"`
+	UserID          uuid.UUID    `json:"user_id" db:"user_id"`
+	FileName        string       `json:"file_name" db:"file_name"`
+	FileType        string       `json:"file_type" db:"file_type"`
+	FileSize        int64        `json:"file_size" db:"file_size"`
+	ChunkSize       int64        `json:"chunk_size" db:"chunk_size"`
+	TotalChunks     int          `json:"total_chunks" db:"total_chunks"`
+	S3Key           string       `json:"-" db:"s3_key"`
+	StorageUploadID string       `json:"-" db:"storage_upload_id"`
+	Status          UploadStatus `json:"status" db:"status"`
+	MediaID         *string      `json:"media_id,omitempty" db:"media_id"`
+	ExpiresAt       time.Time    `json:"expires_at" db:"expires_at"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at" db:"updated_at"`
+	CompletedAt     *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+
+	// Populated from media_upload_parts
+	ReceivedChunks []int `json:"received_chunks"`
+	BytesReceived  int64 `json:"bytes_received"`
+}
+
+// MediaUploadPart represents a single received chunk of an upload
+type MediaUploadPart struct {
+	UploadID   uuid.UUID `json:"upload_id" db:"upload_id"`
+	PartNumber int       `json:"part_number" db:"part_number"`
+	ETag       string    `json:"etag" db:"etag"`
+	Size       int64     `json:"size" db:"size"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// InitiateUploadRequest represents the request to start a chunked upload
+type InitiateUploadRequest struct {
+	FileName  string `json:"file_name" binding:"required"`
+	FileType  string `json:"file_type" binding:"required"`
+	FileSize  int64  `json:"file_size" binding:"required,min=1"`
+	ChunkSize int64  `json:"chunk_size,omitempty"`
+}
+
+// MediaUploadResponse represents the response for upload session operations
+type MediaUploadResponse struct {
+	Success bool         `json:"success"`
+	Data    *MediaUpload `json:"data"`
+}
+
+// CompleteUploadResponse represents the response after an upload is assembled
+type CompleteUploadResponse struct {
+	Success bool                `json:"success"`
+	Data    *CompleteUploadData `json:"data"`
+}
+
+// CompleteUploadData contains the finished upload and the resulting media
+type CompleteUploadData struct {
+	Upload       *MediaUpload `json:"upload"`
+	Media        *Media       `json:"media"`
+	Deduplicated bool         `json:"deduplicated"`
+}
+
+// Media Library Models
+
+// MediaSource identifies where a media file came from
+type MediaSource string
+
+const (
+	MediaSourceTelegram MediaSource = "telegram"
+	MediaSourceYouTube  MediaSource = "youtube"
+	MediaSourceUpload   MediaSource = "upload"
+)
+
+// MediaSearchFilters represents the filters for searching the media library
+type MediaSearchFilters struct {
+	Query       string        `json:"query,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	FileTypes   []string      `json:"file_types,omitempty"` // MIME types ("video/mp4") or families ("video")
+	MinSize     *int64        `json:"min_size,omitempty"`
+	MaxSize     *int64        `json:"max_size,omitempty"`
+	MinDuration *int          `json:"min_duration,omitempty"` // in seconds
+	MaxDuration *int          `json:"max_duration,omitempty"` // in seconds
+	DateRange   *DateRange    `json:"date_range,omitempty"`
+	Sources     []MediaSource `json:"sources,omitempty"`
+	ChannelName string        `json:"channel_name,omitempty"`
+}
+
+// MediaSortOptions represents the sort options for media search
+type MediaSortOptions struct {
+	Field string `json:"field,omitempty"`
+	Order string `json:"order,omitempty"`
+}
+
+// SearchMediaRequest represents the request for searching the media library
+type SearchMediaRequest struct {
+	Filters    MediaSearchFilters `json:"filters,omitempty"`
+	Pagination PaginationOptions  `json:"pagination,omitempty"`
+	Sort       MediaSortOptions   `json:"sort,omitempty"`
+}
+
+// MediaSearchItem represents a media file in library search results
+type MediaSearchItem struct {
+	ID              uuid.UUID   `json:"id"`
+	MediaID         string      `json:"media_id"`
+	ContentID       string      `json:"content_id"`
+	FileName        string      `json:"file_name"`
+	FileType        string      `json:"file_type"`
+	FileSize        int64       `json:"file_size"`
+	DurationSeconds *int        `json:"duration_seconds,omitempty"`
+	Source          MediaSource `json:"source"`
+	ChannelName     string      `json:"channel_name"`
+	Title           *string     `json:"title,omitempty"`
+	Tags            []string    `json:"tags"`
+	Notes           *string     `json:"notes,omitempty"`
+	DownloadedAt    time.Time   `json:"downloaded_at"`
+	Rank            *float64    `json:"rank,omitempty"`
+}
+
+// SearchMediaResponse represents the response for media library search
+type SearchMediaResponse struct {
+	Success bool             `json:"success"`
+	Data    *SearchMediaData `json:"data,omitempty"`
+}
+
+// SearchMediaData contains the search results and pagination info
+type SearchMediaData struct {
+	Media      []MediaSearchItem  `json:"media"`
+	Pagination PaginationResponse `json:"pagination"`
+}
+
+// MediaTagCount represents a tag and the number of media files using it
+type MediaTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// MediaTagsResponse represents the response for listing media tags
+type MediaTagsResponse struct {
+	Success bool            `json:"success"`
+	Data    []MediaTagCount `json:"data"`
+}
+
+// MediaCollection represents a named, curated set of media files
+type MediaCollection struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// MediaCollectionListItem represents a collection in list responses
+type MediaCollectionListItem struct {
+	MediaCollection
+	MediaCount int   `json:"media_count"`
+	TotalSize  int64 `json:"total_size"`
+}
+
+// MediaCollectionItem represents a media file inside a collection
+type MediaCollectionItem struct {
+	MediaSearchItem
+	OrderIndex int       `json:"order_index"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// MediaCollectionDetail represents a collection with its media files
+type MediaCollectionDetail struct {
+	MediaCollection
+	Items []MediaCollectionItem `json:"items"`
+}
+
+// CreateMediaCollectionRequest represents the request for creating a collection
+type CreateMediaCollectionRequest struct {
+	Name        string   `json:"name" binding:"required,min=1,max=255"`
+	Description *string  `json:"description,omitempty"`
+	MediaIDs    []string `json:"media_ids,omitempty"`
+}
+
+// UpdateMediaCollectionRequest represents the request for updating a collection
+type UpdateMediaCollectionRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description,omitempty"`
+}
+
+// MediaCollectionItemsRequest represents the request for adding or removing collection media
+type MediaCollectionItemsRequest struct {
+	MediaIDs []string `json:"media_ids" binding:"required,min=1"`
+}
+
+// AttachCollectionRequest represents the request for attaching a collection to a block
+type AttachCollectionRequest struct {
+	BlockID string `json:"block_id" binding:"required,uuid"`
+}
+
+// MediaCollectionResponse represents the response for collection operations
+type MediaCollectionResponse struct {
+	Success bool                   `json:"success"`
+	Data    *MediaCollectionDetail `json:"data,omitempty"`
+}
+
+// MediaCollectionListResponse represents the response for listing collections
+type MediaCollectionListResponse struct {
+	Success bool                      `json:"success"`
+	Data    []MediaCollectionListItem `json:"data"`
+}
+
+// Media Archive Models
+
+// MediaArchiveRequest selects the media bundled into a ZIP archive.
+// Exactly one of ContentID or EventID must be set.
+type MediaArchiveRequest struct {
+	ContentID string `json:"content_id,omitempty"`
+	EventID   string `json:"event_id,omitempty" binding:"omitempty,uuid"`
+}
+
+// EventArchiveMedia is a media file attached to one of an event's blocks
+type EventArchiveMedia struct {
+	BlockID         uuid.UUID `json:"block_id"`
+	BlockTitle      string    `json:"block_title"`
+	BlockOrderIndex int       `json:"block_order_index"`
+	Title           *string   `json:"title,omitempty"`
+	Description     *string   `json:"description,omitempty"`
+	OrderIndex      int       `json:"order_index"`
+	Media           Media     `json:"media"`
+}
+
+// MediaArchiveManifest is written to manifest.json inside every media archive
+type MediaArchiveManifest struct {
+	ContentID   *string                     `json:"content_id,omitempty"`
+	EventID     *uuid.UUID                  `json:"event_id,omitempty"`
+	Title       *string                     `json:"title,omitempty"`
+	GeneratedAt time.Time                   `json:"generated_at"`
+	Files       []MediaArchiveManifestEntry `json:"files"`
+}
+
+// MediaArchiveManifestEntry describes one file of a media archive
+type MediaArchiveManifestEntry struct {
+	Path        string     `json:"path"`
+	MediaID     string     `json:"media_id"`
+	FileName    string     `json:"file_name"`
+	FileType    string     `json:"file_type"`
+	FileSize    int64      `json:"file_size"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	BlockID     *uuid.UUID `json:"block_id,omitempty"`
+	BlockTitle  *string    `json:"block_title,omitempty"`
+	OrderIndex  int        `json:"order_index"`
+}
+
+// Share Link Models
+
+type ShareLinkScope string
+
+const (
+	ShareLinkScopeMedia      ShareLinkScope = "media"
+	ShareLinkScopeCollection ShareLinkScope = "collection"
+)
+
+// ShareLink grants unauthenticated access to a media file or a collection.
+// Only a hash of the token is stored; the token itself is returned once on creation.
+type ShareLink struct {
+	ID            uuid.UUID      `json:"id" db:"id"`
+	TokenHash     string         `json:"-" db:"token_hash"`
+	Scope         ShareLinkScope `json:"scope"`
+	MediaUUID     *uuid.UUID     `json:"-" db:"media_id"`
+	MediaID       *string        `json:"media_id,omitempty"`
+	CollectionID  *uuid.UUID     `json:"collection_id,omitempty" db:"collection_id"`
+	CreatedBy     uuid.UUID      `json:"created_by" db:"created_by"`
+	PasswordHash  *string        `json:"-" db:"password_hash"`
+	HasPassword   bool           `json:"has_password"`
+	ExpiresAt     *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
+	MaxDownloads  *int           `json:"max_downloads,omitempty" db:"max_downloads"`
+	DownloadCount int            `json:"download_count" db:"download_count"`
+	RevokedAt     *time.Time     `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedBy     *uuid.UUID     `json:"revoked_by,omitempty" db:"revoked_by"`
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// ShareLinkAccess is an audit record of one request made with a share link
+type ShareLinkAccess struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ShareLinkID uuid.UUID  `json:"share_link_id" db:"share_link_id"`
+	MediaUUID   *uuid.UUID `json:"-" db:"media_id"`
+	MediaID     *string    `json:"media_id,omitempty"`
+	Granted     bool       `json:"granted" db:"granted"`
+	Reason      *string    `json:"reason,omitempty" db:"reason"`
+	IPAddress   *string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent   *string    `json:"user_agent,omitempty" db:"user_agent"`
+	RangeHeader *string    `json:"range_header,omitempty" db:"range_header"`
+	AccessedAt  time.Time  `json:"accessed_at" db:"accessed_at"`
+}
+
+// CreateShareLinkRequest represents the request for creating a share link.
+// Exactly one of MediaID or CollectionID must be set.
+type CreateShareLinkRequest struct {
+	MediaID          string  `json:"media_id,omitempty"`
+	CollectionID     string  `json:"collection_id,omitempty" binding:"omitempty,uuid"`
+	ExpiresInMinutes *int    `json:"expires_in_minutes,omitempty" binding:"omitempty,min=1"`
+	MaxDownloads     *int    `json:"max_downloads,omitempty" binding:"omitempty,min=1"`
+	Password         *string `json:"password,omitempty" binding:"omitempty,min=4,max=72"`
+}
+
+// CreatedShareLink is a newly created share link together with its secret token
+type CreatedShareLink struct {
+	ShareLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+type CreateShareLinkResponse struct {
+	Success bool              `json:"success"`
+	Data    *CreatedShareLink `json:"data"`
+}
+
+type ShareLinkListResponse struct {
+	Success bool        `json:"success"`
+	Data    []ShareLink `json:"data"`
+}
+
+type ShareLinkAccessListResponse struct {
+	Success bool              `json:"success"`
+	Data    []ShareLinkAccess `json:"data"`
+}
+
+// SharedMediaItem is a media file listed on a shared collection page
+type SharedMediaItem struct {
+	MediaID  string `json:"media_id"`
+	FileName string `json:"file_name"`
+	FileType string `json:"file_type"`
+	FileSize int64  `json:"file_size"`
+	URL      string `json:"url"`
+}
+
+// SharedCollectionResponse is returned when a collection share link is opened
+type SharedCollectionResponse struct {
+	Name        string            `json:"name"`
+	Description *string           `json:"description,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Media       []SharedMediaItem `json:"media"`
+}
+
+// Media Ownership Models
+
+// PermissionMediaManageAll lets a user see and manage the media of every user
+const PermissionMediaManageAll = "media:manage_all"
+
+// MediaScope restricts media queries to what a user may see: their own media,
+// media of their team mates and posts grabbed by any of them. All disables the restriction.
+type MediaScope struct {
+	UserID uuid.UUID
+	All    bool
+}
+
+// MediaQuotaSource tells where the effective limits of a user come from
+type MediaQuotaSource string
+
+const (
+	MediaQuotaSourceUser    MediaQuotaSource = "user"
+	MediaQuotaSourceRole    MediaQuotaSource = "role"
+	MediaQuotaSourceDefault MediaQuotaSource = "default"
+)
+
+// MediaQuota limits the storage of a user or of every member of a role.
+// Nil limits are unlimited.
+type MediaQuota struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	RoleID    *uuid.UUID `json:"role_id,omitempty" db:"role_id"`
+	MaxBytes  *int64     `json:"max_bytes,omitempty" db:"max_bytes"`
+	MaxFiles  *int       `json:"max_files,omitempty" db:"max_files"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// SetMediaQuotaRequest represents the request for setting a user or role quota
+type SetMediaQuotaRequest struct {
+	MaxBytes *int64 `json:"max_bytes,omitempty" binding:"omitempty,min=0"`
+	MaxFiles *int   `json:"max_files,omitempty" binding:"omitempty,min=0"`
+}
+
+// MediaUsage is the storage used by a user together with the limits that apply
+type MediaUsage struct {
+	UserID         uuid.UUID        `json:"user_id"`
+	UsedBytes      int64            `json:"used_bytes"`
+	UsedFiles      int              `json:"used_files"`
+	MaxBytes       *int64           `json:"max_bytes,omitempty"`
+	MaxFiles       *int             `json:"max_files,omitempty"`
+	RemainingBytes *int64           `json:"remaining_bytes,omitempty"`
+	RemainingFiles *int             `json:"remaining_files,omitempty"`
+	QuotaSource    MediaQuotaSource `json:"quota_source"`
+}
+
+type MediaUsageResponse struct {
+	Success bool        `json:"success"`
+	Data    *MediaUsage `json:"data"`
+}
+
+type MediaQuotaResponse struct {
+	Success bool        `json:"success"`
+	Data    *MediaQuota `json:"data"`
+}
+
+// Team groups users who share their media with each other
+type Team struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description *string    `json:"description,omitempty" db:"description"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type TeamMember struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Name    string    `json:"name"`
+	Surname string    `json:"surname"`
+	Email   string    `json:"email"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type TeamDetail struct {
+	Team
+	Members []TeamMember `json:"members"`
+}
+
+type CreateTeamRequest struct {
+	Name        string  `json:"name" binding:"required,min=1,max=255"`
+	Description *string `json:"description,omitempty"`
+}
+
+type AddTeamMemberRequest struct {
+	UserID string `json:"user_id" binding:"required,uuid"`
+}
+
+type TeamResponse struct {
+	Success bool        `json:"success"`
+	Data    *TeamDetail `json:"data"`
+}
+
+type TeamListResponse struct {
+	Success bool   `json:"success"`
+	Data    []Team `json:"data"`
+}
+
 // Show types
 type RepeatPattern string
 
@@ -269,6 +733,7 @@ type ShowListItemREST struct {
 	ID                    uuid.UUID     `json:"id"`
 	ShowName              string        `json:"show_name"`
 	RepeatPattern         RepeatPattern `json:"repeat_pattern"`
+	YouTubeKey            string        `json:"youtube_key"` // masked
 	NextEventDate         *string       `json:"next_event_date,omitempty"`
 	EventCount            int           `json:"event_count"`
 	Status                ShowStatus    `json:"status"`
@@ -333,6 +798,23 @@ type ShowDetailResponseREST struct {
 	Data    *ShowDetailREST `json:"data"`
 }
 
+// PermissionSecretsReveal lets a user see the stream keys and Zoom passcodes of shows
+// and events in plain text. Every other response masks them.
+const PermissionSecretsReveal = "secrets:reveal"
+
+// SecretsREST holds the stream keys and Zoom passcode of a show or event in plain text
+type SecretsREST struct {
+	YouTubeKey    string  `json:"youtube_key"`
+	AdditionalKey *string `json:"additional_key,omitempty"`
+	ZoomPasscode  *string `json:"zoom_passcode,omitempty"`
+}
+
+// SecretsResponseREST represents the response for revealing secrets
+type SecretsResponseREST struct {
+	Success bool         `json:"success"`
+	Data    *SecretsREST `json:"data"`
+}
+
 // DeleteShowResponseREST represents the response for deleting shows
 type DeleteShowResponseREST struct {
 	Success bool                `json:"success"`
@@ -463,10 +945,12 @@ type EventDetailREST struct {
 
 // BlockSummaryREST represents block information in event details
 type BlockSummaryREST struct {
-	ID              uuid.UUID `json:"id"`
-	Title           string    `json:"title"`
-	OrderIndex      int       `json:"order_index"`
-	EstimatedLength int       `json:"estimated_length"`
+	ID                   uuid.UUID `json:"id"`
+	Title                string    `json:"title"`
+	OrderIndex           int       `json:"order_index"`
+	EstimatedLength      int       `json:"estimated_length"`
+	UnconfirmedGuests    int       `json:"unconfirmed_guests"`
+	HasUnconfirmedGuests bool      `json:"has_unconfirmed_guests"`
 }
 
 // DeleteEventRequestREST represents the request for deleting events
@@ -559,6 +1043,7 @@ type Guest struct {
 	Metadata  map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time              `json:"updated_at" db:"updated_at"`
+	ErasedAt  *time.Time             `json:"erased_at,omitempty" db:"erased_at"`
 }
 
 // Guest API Request/Response Types
@@ -614,22 +1099,449 @@ type GuestDeleteData struct {
 	DeletedAt time.Time `json:"deleted_at"`
 }
 
+// GuestDuplicateCandidate is a pair of guests that may be the same person
+type GuestDuplicateCandidate struct {
+	Guest          *Guest         `json:"guest"`
+	Duplicate      *Guest         `json:"duplicate"`
+	Score          float64        `json:"score"`
+	NameSimilarity float64        `json:"name_similarity"`
+	SharedContacts []GuestContact `json:"shared_contacts,omitempty"`
+	Reasons        []string       `json:"reasons"`
+}
+
+type GuestDuplicatesResponse struct {
+	Success bool                      `json:"success"`
+	Data    []GuestDuplicateCandidate `json:"data"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+type MergeGuestsRequest struct {
+	SurvivorID   string   `json:"survivor_id" binding:"required"`
+	DuplicateIDs []string `json:"duplicate_ids" binding:"required,min=1,max=20"`
+}
+
+// GuestMerge records a merge of duplicate guests into a surviving guest. The
+// duplicates and the survivor as it was before are kept so the merge can be undone.
+type GuestMerge struct {
+	ID               uuid.UUID   `json:"id"`
+	UserID           uuid.UUID   `json:"user_id"`
+	SurvivorID       uuid.UUID   `json:"survivor_id"`
+	DuplicateIDs     []uuid.UUID `json:"duplicate_ids"`
+	MovedAppearances int         `json:"moved_appearances"`
+	MovedConsents    int         `json:"moved_consents"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UndoneAt         *time.Time  `json:"undone_at,omitempty"`
+}
+
+type MergeGuestsResponse struct {
+	Success bool             `json:"success"`
+	Data    *MergeGuestsData `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type MergeGuestsData struct {
+	Guest *Guest      `json:"guest"`
+	Merge *GuestMerge `json:"merge"`
+}
+
+type UndoGuestMergeResponse struct {
+	Success bool        `json:"success"`
+	Data    *GuestMerge `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// Guest import outcomes of a row
+const (
+	GuestImportNew       = "new"
+	GuestImportCreated   = "created"
+	GuestImportDuplicate = "duplicate"
+	GuestImportInvalid   = "invalid"
+)
+
+// GuestImportMatch is the existing guest an imported row was matched to
+type GuestImportMatch struct {
+	GuestID uuid.UUID `json:"guest_id"`
+	Name    string    `json:"name"`
+	Surname string    `json:"surname"`
+	Score   float64   `json:"score"`
+	Reasons []string  `json:"reasons"`
+}
+
+type GuestImportRow struct {
+	Row     int               `json:"row"`
+	Status  string            `json:"status"`
+	Name    string            `json:"name"`
+	Surname string            `json:"surname"`
+	GuestID *uuid.UUID        `json:"guest_id,omitempty"`
+	Match   *GuestImportMatch `json:"match,omitempty"`
+	Errors  []string          `json:"errors,omitempty"`
+}
+
+type GuestImportResult struct {
+	DryRun     bool             `json:"dry_run"`
+	Format     string           `json:"format"`
+	Total      int              `json:"total"`
+	Created    int              `json:"created"`
+	New        int              `json:"new"`
+	Duplicates int              `json:"duplicates"`
+	Invalid    int              `json:"invalid"`
+	Rows       []GuestImportRow `json:"rows"`
+}
+
+type GuestImportResponse struct {
+	Success bool               `json:"success"`
+	Data    *GuestImportResult `json:"data,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// GuestAvatar is an uploaded avatar with the URLs of its sizes, keyed by edge length in pixels
+type GuestAvatar struct {
+	GuestID uuid.UUID         `json:"guest_id"`
+	Version string            `json:"version"`
+	URL     string            `json:"url"`
+	Sizes   map[string]string `json:"sizes"`
+}
+
+type GuestAvatarResponse struct {
+	Success bool         `json:"success"`
+	Data    *GuestAvatar `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// TelegramAvatarRequest picks the Telegram profile an avatar is pulled from. Without a
+// username the guest's primary Telegram contact is used.
+type TelegramAvatarRequest struct {
+	Username string `json:"username,omitempty"`
+}
+
+// ConsentPurpose is what a guest consented to
+type ConsentPurpose string
+
+const (
+	ConsentPurposeContact     ConsentPurpose = "contact"     // being contacted about appearances
+	ConsentPurposeRecording   ConsentPurpose = "recording"   // being recorded and streamed
+	ConsentPurposePublication ConsentPurpose = "publication" // name and photo published with episodes
+	ConsentPurposeMarketing   ConsentPurpose = "marketing"   // news and promotional messages
+)
+
+func (p ConsentPurpose) IsValid() bool {
+	switch p {
+	case ConsentPurposeContact, ConsentPurposeRecording, ConsentPurposePublication, ConsentPurposeMarketing:
+		return true
+	}
+	return false
+}
+
+// ConsentMethod is how a consent was obtained
+type ConsentMethod string
+
+const (
+	ConsentMethodVerbal   ConsentMethod = "verbal"
+	ConsentMethodWritten  ConsentMethod = "written"
+	ConsentMethodEmail    ConsentMethod = "email"
+	ConsentMethodWebForm  ConsentMethod = "web_form"
+	ConsentMethodRSVP     ConsentMethod = "rsvp"
+	ConsentMethodContract ConsentMethod = "contract"
+	ConsentMethodImported ConsentMethod = "imported"
+	ConsentMethodOther    ConsentMethod = "other"
+)
+
+func (m ConsentMethod) IsValid() bool {
+	switch m {
+	case ConsentMethodVerbal, ConsentMethodWritten, ConsentMethodEmail, ConsentMethodWebForm,
+		ConsentMethodRSVP, ConsentMethodContract, ConsentMethodImported, ConsentMethodOther:
+		return true
+	}
+	return false
+}
+
+// GuestConsent records that a guest granted or withdrew consent for a purpose
+type GuestConsent struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	GuestID    uuid.UUID      `json:"guest_id" db:"guest_id"`
+	Purpose    ConsentPurpose `json:"purpose" db:"purpose"`
+	Granted    bool           `json:"granted" db:"granted"`
+	Method     ConsentMethod  `json:"method" db:"method"`
+	Evidence   *string        `json:"evidence,omitempty" db:"evidence"`
+	ObtainedAt time.Time      `json:"obtained_at" db:"obtained_at"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty" db:"expires_at"`
+	RecordedBy *uuid.UUID     `json:"recorded_by,omitempty" db:"recorded_by"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+}
+
+// GuestConsentStatus is the current state of one purpose, from its latest record
+type GuestConsentStatus struct {
+	Purpose ConsentPurpose `json:"purpose"`
+	Active  bool           `json:"active"`
+	Consent *GuestConsent  `json:"consent"`
+}
+
+type RecordGuestConsentRequest struct {
+	Purpose    ConsentPurpose `json:"purpose" binding:"required"`
+	Granted    *bool          `json:"granted" binding:"required"`
+	Method     ConsentMethod  `json:"method" binding:"required"`
+	Evidence   *string        `json:"evidence,omitempty"`
+	ObtainedAt *time.Time     `json:"obtained_at,omitempty"` // defaults to now
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+}
+
+type RecordGuestConsentResponse struct {
+	Success bool          `json:"success"`
+	Data    *GuestConsent `json:"data,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type GuestConsentsData struct {
+	Current []GuestConsentStatus `json:"current"`
+	History []GuestConsent       `json:"history"`
+}
+
+type GuestConsentsResponse struct {
+	Success bool               `json:"success"`
+	Data    *GuestConsentsData `json:"data,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// MergedGuestRecords are guest records merged into a guest and kept to undo the merge
+type MergedGuestRecords struct {
+	MergeID    uuid.UUID `json:"merge_id"`
+	MergedAt   time.Time `json:"merged_at"`
+	Duplicates []Guest   `json:"duplicates"`
+}
+
+// GuestPersonalData is everything stored about a guest, for data subject access requests
+type GuestPersonalData struct {
+	ExportedAt    time.Time            `json:"exported_at"`
+	Guest         *Guest               `json:"guest"`
+	Consents      []GuestConsent       `json:"consents"`
+	Bookings      []GuestInvitation    `json:"bookings"`
+	Availability  *GuestAvailability   `json:"availability"`
+	MergedRecords []MergedGuestRecords `json:"merged_records"`
+	AuditTrail    []AuditLogEntry      `json:"audit_trail"`
+}
+
+type EraseGuestResponse struct {
+	Success bool   `json:"success"`
+	Data    *Guest `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// GuestAvailabilityWindow is a weekly time window a guest is free in, in the guest's timezone.
+// A window ending at or before its start runs past midnight into the next day.
+type GuestAvailabilityWindow struct {
+	Weekday   int    `json:"weekday" example:"1"`        // 0 is Sunday
+	StartTime string `json:"start_time" example:"18:00"` // HH:MM
+	EndTime   string `json:"end_time" example:"22:30"`   // HH:MM, 24:00 for midnight
+	Preferred bool   `json:"preferred"`                  // a time the guest would rather be booked at
+}
+
+// GuestBlackout is a range of days, inclusive and in the guest's timezone, the guest is away
+type GuestBlackout struct {
+	StartDate string  `json:"start_date" example:"2026-12-20"`
+	EndDate   string  `json:"end_date" example:"2027-01-05"`
+	Reason    *string `json:"reason,omitempty"`
+}
+
+// GuestAvailability is when a guest can be booked. Without windows the guest is free at any
+// time outside their blackouts.
+type GuestAvailability struct {
+	GuestID   uuid.UUID                 `json:"guest_id" db:"guest_id"`
+	Timezone  string                    `json:"timezone" db:"timezone" example:"Europe/Berlin"`
+	Windows   []GuestAvailabilityWindow `json:"windows" db:"windows"`
+	Blackouts []GuestBlackout           `json:"blackouts" db:"blackouts"`
+	UpdatedAt *time.Time                `json:"updated_at,omitempty" db:"updated_at"` // nil when never set
+}
+
+type SetGuestAvailabilityRequest struct {
+	Timezone  string                    `json:"timezone" binding:"required" example:"Europe/Berlin"`
+	Windows   []GuestAvailabilityWindow `json:"windows"`
+	Blackouts []GuestBlackout           `json:"blackouts"`
+}
+
+type GuestAvailabilityResponse struct {
+	Success bool               `json:"success"`
+	Data    *GuestAvailability `json:"data,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// Reasons a guest can't be booked into an event
+const (
+	UnavailableOutsideWindows = "outside_windows"
+	UnavailableBlackout       = "blackout"
+	UnavailableConflict       = "conflict"
+)
+
+// GuestBooking is a guest's booking into an event, used to find conflicting bookings
+type GuestBooking struct {
+	GuestID       uuid.UUID `json:"-"`
+	EventID       uuid.UUID `json:"event_id"`
+	EventTitle    string    `json:"event_title"`
+	ShowID        uuid.UUID `json:"show_id"`
+	ShowName      string    `json:"show_name"`
+	StartDatetime time.Time `json:"start_datetime"`
+	EndDatetime   time.Time `json:"end_datetime"`
+}
+
+// GuestEventAvailability is whether one guest can be booked into an event
+type GuestEventAvailability struct {
+	GuestID       uuid.UUID      `json:"guest_id"`
+	Available     bool           `json:"available"`
+	Preferred     bool           `json:"preferred"`
+	AlreadyBooked bool           `json:"already_booked"`
+	Reasons       []string       `json:"reasons"`
+	Blackout      *GuestBlackout `json:"blackout,omitempty"`
+	Conflicts     []GuestBooking `json:"conflicts"`
+}
+
+// EventSuggestion is an upcoming event checked against the availability of a set of guests
+type EventSuggestion struct {
+	EventID        uuid.UUID                `json:"event_id"`
+	EventTitle     *string                  `json:"event_title,omitempty"`
+	StartDatetime  time.Time                `json:"start_datetime"`
+	EndDatetime    time.Time                `json:"end_datetime"`
+	AllAvailable   bool                     `json:"all_available"`
+	PreferredCount int                      `json:"preferred_count"`
+	Guests         []GuestEventAvailability `json:"guests"`
+}
+
+type SuggestEventsRequest struct {
+	ShowID             string   `json:"show_id" binding:"required"`
+	GuestIDs           []string `json:"guest_ids" binding:"required,min=1,max=20"`
+	Limit              int      `json:"limit,omitempty"`               // 10 by default, at most 50
+	IncludeUnavailable bool     `json:"include_unavailable,omitempty"` // also list events not every guest can make
+}
+
+type SuggestEventsData struct {
+	ShowID      uuid.UUID         `json:"show_id"`
+	Checked     int               `json:"checked"` // upcoming events checked
+	Suggestions []EventSuggestion `json:"suggestions"`
+}
+
+type SuggestEventsResponse struct {
+	Success bool               `json:"success"`
+	Data    *SuggestEventsData `json:"data,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// GuestTag is an entry of a user's tag vocabulary. Guests carry tags by name; the vocabulary
+// adds colours and a hierarchy, and renaming or merging a tag changes every guest carrying it.
+type GuestTag struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	Color       *string    `json:"color,omitempty" db:"color" example:"#3b82f6"`
+	ParentID    *uuid.UUID `json:"parent_id,omitempty" db:"parent_id"`
+	Description *string    `json:"description,omitempty" db:"description"`
+	GuestCount  int        `json:"guest_count"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateGuestTagRequest struct {
+	Name        string  `json:"name" binding:"required"`
+	Color       *string `json:"color,omitempty"`
+	ParentID    *string `json:"parent_id,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// UpdateGuestTagRequest changes the given fields; an empty color, parent_id or description clears it
+type UpdateGuestTagRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	ParentID    *string `json:"parent_id,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+type MergeGuestTagsRequest struct {
+	SourceIDs []string `json:"source_ids" binding:"required,min=1"`
+	TargetID  string   `json:"target_id" binding:"required"`
+}
+
+type GuestTagResponse struct {
+	Success bool      `json:"success"`
+	Data    *GuestTag `json:"data,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type GuestTagsResponse struct {
+	Success bool       `json:"success"`
+	Data    []GuestTag `json:"data,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+type GuestTagChangeData struct {
+	Tag           *GuestTag `json:"tag,omitempty"`
+	UpdatedGuests int64     `json:"updated_guests"`
+}
+
+type GuestTagChangeResponse struct {
+	Success bool                `json:"success"`
+	Data    *GuestTagChangeData `json:"data,omitempty"`
+	Error   string              `json:"error,omitempty"`
+}
+
+// GuestSegment is a saved guest filter. Shared segments are visible to the owner's team mates,
+// who get their own guests matching the filters.
+type GuestSegment struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	UserID      uuid.UUID    `json:"user_id" db:"user_id"`
+	Name        string       `json:"name" db:"name"`
+	Description *string      `json:"description,omitempty" db:"description"`
+	Filters     GuestFilters `json:"filters" db:"filters"`
+	Shared      bool         `json:"shared" db:"shared"`
+	GuestCount  *int         `json:"guest_count,omitempty"` // guests of the requesting user matching the filters
+	CreatedAt   time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+type CreateGuestSegmentRequest struct {
+	Name        string       `json:"name" binding:"required"`
+	Description *string      `json:"description,omitempty"`
+	Filters     GuestFilters `json:"filters"`
+	Shared      bool         `json:"shared,omitempty"`
+}
+
+type UpdateGuestSegmentRequest struct {
+	Name        *string       `json:"name,omitempty"`
+	Description *string       `json:"description,omitempty"`
+	Filters     *GuestFilters `json:"filters,omitempty"`
+	Shared      *bool         `json:"shared,omitempty"`
+}
+
+type GuestSegmentResponse struct {
+	Success bool          `json:"success"`
+	Data    *GuestSegment `json:"data,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type GuestSegmentsResponse struct {
+	Success bool           `json:"success"`
+	Data    []GuestSegment `json:"data,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
 type DateRange struct {
 	Start time.Time `json:"start"`
 	End   time.Time `json:"end"`
 }
 
 type ListGuestsRequest struct {
+	SegmentID  string            `json:"segment_id,omitempty"` // use a saved segment's filters instead of filters
 	Filters    GuestFilters      `json:"filters,omitempty"`
 	Pagination PaginationOptions `json:"pagination,omitempty"`
 	Sort       GuestSortOptions  `json:"sort,omitempty"`
 }
 
 type GuestFilters struct {
-	Search           string        `json:"search,omitempty"`
-	Tags             []string      `json:"tags,omitempty"`
-	HasContactType   []ContactType `json:"has_contact_type,omitempty"`
-	CreatedDateRange *DateRange    `json:"created_date_range,omitempty"`
+	Search               string        `json:"search,omitempty"`
+	Tags                 []string      `json:"tags,omitempty"`
+	IncludeChildTags     bool          `json:"include_child_tags,omitempty"` // a tag also matches the tags below it
+	HasContactType       []ContactType `json:"has_contact_type,omitempty"`
+	CreatedDateRange     *DateRange    `json:"created_date_range,omitempty"`
+	MinAppearances       *int          `json:"min_appearances,omitempty"`
+	MaxAppearances       *int          `json:"max_appearances,omitempty"`
+	LastAppearanceAfter  *time.Time    `json:"last_appearance_after,omitempty"`
+	LastAppearanceBefore *time.Time    `json:"last_appearance_before,omitempty"` // guests who never appeared don't match
 }
 
 type GuestSortOptions struct {
@@ -649,16 +1561,17 @@ type ListGuestsData struct {
 }
 
 type GuestListItem struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Surname      string    `json:"surname"`
-	ShortName    *string   `json:"short_name,omitempty"`
-	PrimaryEmail *string   `json:"primary_email,omitempty"`
-	Avatar       *string   `json:"avatar,omitempty"`
-	Tags         []string  `json:"tags"`
-	NotesPreview *string   `json:"notes_preview,omitempty"`
-	ContactCount int       `json:"contact_count"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID             uuid.UUID  `json:"id"`
+	Name           string     `json:"name"`
+	Surname        string     `json:"surname"`
+	ShortName      *string    `json:"short_name,omitempty"`
+	PrimaryEmail   *string    `json:"primary_email,omitempty"`
+	Avatar         *string    `json:"avatar,omitempty"`
+	Tags           []string   `json:"tags"`
+	NotesPreview   *string    `json:"notes_preview,omitempty"`
+	ContactCount   int        `json:"contact_count"`
+	LastAppearance *time.Time `json:"last_appearance,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 type AutocompleteResponse struct {
@@ -696,10 +1609,52 @@ type GuestInfoData struct {
 	Stats *GuestStats `json:"stats,omitempty"`
 }
 
+// GuestStats summarizes a guest's appearances. An appearance is an event the guest
+// is booked on through one of its blocks; cancelled events don't count.
 type GuestStats struct {
-	TotalShows      int        `json:"total_shows"`
-	LastAppearance  *time.Time `json:"last_appearance,omitempty"`
-	UpcomingShows   int        `json:"upcoming_shows"`
+	TotalAppearances int                    `json:"total_appearances"`
+	TotalShows       int                    `json:"total_shows"`
+	LastAppearance   *time.Time             `json:"last_appearance,omitempty"`
+	NextAppearance   *time.Time             `json:"next_appearance,omitempty"`
+	UpcomingShows    int                    `json:"upcoming_shows"`
+	Shows            []GuestShowAppearances `json:"shows"`
+	Roles            []GuestRoleCount       `json:"roles"`
+}
+
+type GuestShowAppearances struct {
+	ShowID         uuid.UUID  `json:"show_id"`
+	ShowName       string     `json:"show_name"`
+	Appearances    int        `json:"appearances"`
+	LastAppearance *time.Time `json:"last_appearance,omitempty"`
+}
+
+type GuestRoleCount struct {
+	Role        string `json:"role"`
+	Appearances int    `json:"appearances"`
+}
+
+// GuestAppearance is one entry of a guest's appearance timeline
+type GuestAppearance struct {
+	EventID       uuid.UUID   `json:"event_id"`
+	EventTitle    string      `json:"event_title"`
+	StartDatetime time.Time   `json:"start_datetime"`
+	EventStatus   EventStatus `json:"event_status"`
+	ShowID        uuid.UUID   `json:"show_id"`
+	ShowName      string      `json:"show_name"`
+	BlockID       uuid.UUID   `json:"block_id"`
+	BlockTitle    string      `json:"block_title"`
+	Role          *string     `json:"role,omitempty"`
+}
+
+type GuestAppearancesResponse struct {
+	Success bool                  `json:"success"`
+	Data    *GuestAppearancesData `json:"data,omitempty"`
+	Error   string                `json:"error,omitempty"`
+}
+
+type GuestAppearancesData struct {
+	Appearances []GuestAppearance  `json:"appearances"`
+	Pagination  PaginationResponse `json:"pagination"`
 }
 
 // Show Blocks System Models
@@ -745,12 +1700,109 @@ type Block struct {
 
 // Junction table for block-guest relationships
 type BlockGuest struct {
-	ID        uuid.UUID  `json:"id" db:"id"`
-	BlockID   uuid.UUID  `json:"block_id" db:"block_id"`
-	GuestID   uuid.UUID  `json:"guest_id" db:"guest_id"`
-	Role      *string    `json:"role,omitempty" db:"role"`
-	Notes     *string    `json:"notes,omitempty" db:"notes"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ID               uuid.UUID        `json:"id" db:"id"`
+	BlockID          uuid.UUID        `json:"block_id" db:"block_id"`
+	GuestID          uuid.UUID        `json:"guest_id" db:"guest_id"`
+	Role             *string          `json:"role,omitempty" db:"role"`
+	Notes            *string          `json:"notes,omitempty" db:"notes"`
+	InvitationStatus InvitationStatus `json:"invitation_status" db:"invitation_status"`
+	InvitedAt        *time.Time       `json:"invited_at,omitempty" db:"invited_at"`
+	RespondedAt      *time.Time       `json:"responded_at,omitempty" db:"responded_at"`
+	ReminderSentAt   *time.Time       `json:"reminder_sent_at,omitempty" db:"reminder_sent_at"`
+	CreatedAt        time.Time        `json:"created_at" db:"created_at"`
+}
+
+// InvitationStatus tracks whether a guest agreed to appear on a block
+type InvitationStatus string
+
+const (
+	InvitationStatusInvited   InvitationStatus = "invited"
+	InvitationStatusConfirmed InvitationStatus = "confirmed"
+	InvitationStatusDeclined  InvitationStatus = "declined"
+	InvitationStatusTentative InvitationStatus = "tentative"
+	InvitationStatusNoShow    InvitationStatus = "no_show"
+)
+
+// IsValid reports whether the status is known
+func (s InvitationStatus) IsValid() bool {
+	switch s {
+	case InvitationStatusInvited, InvitationStatusConfirmed, InvitationStatusDeclined,
+		InvitationStatusTentative, InvitationStatusNoShow:
+		return true
+	}
+	return false
+}
+
+// IsGuestResponse reports whether a guest may answer with the status through an RSVP link
+func (s InvitationStatus) IsGuestResponse() bool {
+	return s == InvitationStatusConfirmed || s == InvitationStatusDeclined || s == InvitationStatusTentative
+}
+
+// IsUnconfirmed reports whether the guest hasn't committed to coming yet
+func (s InvitationStatus) IsUnconfirmed() bool {
+	return s == InvitationStatusInvited || s == InvitationStatusTentative
+}
+
+// GuestInvitation is a guest's booking on a block together with what the
+// invitation and reminder messages need to know about it
+
+type GuestInvitation struct {
+	BlockGuest
+	OwnerID       uuid.UUID      `json:"-"`
+	GuestName     string         `json:"guest_name"`
+	GuestSurname  string         `json:"guest_surname"`
+	GuestContacts []GuestContact `json:"-"`
+	BlockTitle    string         `json:"block_title"`
+	EventID       uuid.UUID      `json:"event_id"`
+	EventTitle    string         `json:"event_title"`
+	ShowName      string         `json:"show_name"`
+	StartDatetime time.Time      `json:"start_datetime"`
+	EndDatetime   time.Time      `json:"end_datetime"`
+	EventStatus   EventStatus    `json:"event_status"`
+}
+
+type InviteGuestResponse struct {
+	Success bool             `json:"success"`
+	Data    *InviteGuestData `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type InviteGuestData struct {
+	Invitation *GuestInvitation `json:"invitation"`
+	// RSVPURL can be passed on by hand when the guest has no email address
+	RSVPURL   string `json:"rsvp_url"`
+	EmailSent bool   `json:"email_sent"`
+}
+
+type SetInvitationStatusRequest struct {
+	Status InvitationStatus `json:"status" binding:"required"`
+}
+
+type GuestInvitationResponse struct {
+	Success bool             `json:"success"`
+	Data    *GuestInvitation `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// RSVPDetails is what a guest sees when opening an RSVP link
+type RSVPDetails struct {
+	GuestName     string           `json:"guest_name"`
+	EventTitle    string           `json:"event_title"`
+	ShowName      string           `json:"show_name"`
+	BlockTitle    string           `json:"block_title"`
+	Role          *string          `json:"role,omitempty"`
+	StartDatetime time.Time        `json:"start_datetime"`
+	Status        InvitationStatus `json:"status"`
+}
+
+type RSVPRequest struct {
+	Status InvitationStatus `json:"status" binding:"required"`
+}
+
+type RSVPResponse struct {
+	Success bool         `json:"success"`
+	Data    *RSVPDetails `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
 }
 
 // Junction table for block-media relationships
@@ -817,6 +1869,33 @@ type BlockMediaInput struct {
 	OrderIndex  int     `json:"order_index,omitempty"`
 }
 
+// CloneBlocksRequest copies blocks from one event into another. Without block IDs all of the
+// source's blocks are cloned. Position is the slot in the target's rundown the copies start
+// at; without it they go after the last block.
+type CloneBlocksRequest struct {
+	SourceEventID string   `json:"source_event_id" binding:"required,uuid"`
+	TargetEventID string   `json:"target_event_id" binding:"required,uuid"`
+	BlockIDs      []string `json:"block_ids,omitempty"`
+	Position      *int     `json:"position,omitempty" binding:"omitempty,min=0"`
+	WithoutGuests bool     `json:"without_guests,omitempty"`
+	Preview       bool     `json:"preview,omitempty"` // return the resulting rundown without saving it
+}
+
+type CloneBlocksData struct {
+	SourceEventID      uuid.UUID      `json:"source_event_id"`
+	TargetEventID      uuid.UUID      `json:"target_event_id"`
+	Cloned             int            `json:"cloned"`
+	Preview            bool           `json:"preview"`
+	Blocks             []BlockSummary `json:"blocks"` // the target's rundown after cloning
+	TotalEstimatedTime int            `json:"total_estimated_time"`
+}
+
+type CloneBlocksResponse struct {
+	Success bool             `json:"success"`
+	Data    *CloneBlocksData `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
 type AddBlockResponse struct {
 	Success bool         `json:"success"`
 	Data    *BlockDetail `json:"data,omitempty"`
@@ -855,13 +1934,16 @@ type BlockDetail struct {
 }
 
 type BlockGuestDetail struct {
-	ID             uuid.UUID     `json:"id"`
-	Name           string        `json:"name"`
-	Surname        string        `json:"surname"`
-	ShortName      *string       `json:"short_name,omitempty"`
-	Role           *string       `json:"role,omitempty"`
-	Notes          *string       `json:"notes,omitempty"`
-	PrimaryContact *GuestContact `json:"primary_contact,omitempty"`
+	ID               uuid.UUID        `json:"id"`
+	Name             string           `json:"name"`
+	Surname          string           `json:"surname"`
+	ShortName        *string          `json:"short_name,omitempty"`
+	Role             *string          `json:"role,omitempty"`
+	Notes            *string          `json:"notes,omitempty"`
+	InvitationStatus InvitationStatus `json:"invitation_status"`
+	InvitedAt        *time.Time       `json:"invited_at,omitempty"`
+	RespondedAt      *time.Time       `json:"responded_at,omitempty"`
+	PrimaryContact   *GuestContact    `json:"primary_contact,omitempty"`
 }
 
 type BlockMediaDetail struct {
@@ -931,10 +2013,78 @@ type BlockSummary struct {
 	Status          BlockStatus `json:"status"`
 	GuestCount      int         `json:"guest_count"`
 	MediaCount      int         `json:"media_count"`
+	// Guests that are invited or tentative
+	UnconfirmedGuests    int  `json:"unconfirmed_guests"`
+	HasUnconfirmedGuests bool `json:"has_unconfirmed_guests"`
+}
+
+// Rundown Template Models
+
+// RundownTemplateBlock defines a block created for every episode of a show. The title
+// pattern can use {show}, {title}, {date}, {time} and {episode}.
+type RundownTemplateBlock struct {
+	BlockType       BlockType              `json:"block_type"`
+	TitlePattern    string                 `json:"title_pattern" example:"Interview #{episode}"`
+	Description     *string                `json:"description,omitempty"`
+	Topic           *string                `json:"topic,omitempty"`
+	EstimatedLength int                    `json:"estimated_length"` // in minutes
+	Media           []BlockMediaInput      `json:"media,omitempty"`  // attached to every created block
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// RundownTemplate is the block skeleton of a show's episodes. With auto apply on, generated
+// events start with the template's blocks.
+type RundownTemplate struct {
+	ShowID    uuid.UUID              `json:"show_id" db:"show_id"`
+	Blocks    []RundownTemplateBlock `json:"blocks" db:"blocks"`
+	AutoApply bool                   `json:"auto_apply" db:"auto_apply"`
+	UpdatedAt time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+type SetRundownTemplateRequest struct {
+	Blocks    []RundownTemplateBlock `json:"blocks" binding:"required,min=1,max=100"`
+	AutoApply *bool                  `json:"auto_apply,omitempty"` // on by default
+}
+
+// ApplyRundownTemplateRequest picks whether the template's blocks follow the event's blocks
+// or replace them. Blocks with guests are never replaced.
+type ApplyRundownTemplateRequest struct {
+	Replace bool `json:"replace,omitempty"`
+}
+
+// SaveRundownTemplateRequest turns an event's blocks into its show's template. Titles are
+// taken over literally.
+type SaveRundownTemplateRequest struct {
+	AutoApply *bool `json:"auto_apply,omitempty"` // keeps the current setting by default
+}
+
+type RundownTemplateResponse struct {
+	Success bool             `json:"success"`
+	Data    *RundownTemplate `json:"data,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type ApplyRundownTemplateData struct {
+	EventID uuid.UUID      `json:"event_id"`
+	Created int            `json:"created"`
+	Blocks  []BlockSummary `json:"blocks"`
+}
+
+type ApplyRundownTemplateResponse struct {
+	Success bool                      `json:"success"`
+	Data    *ApplyRundownTemplateData `json:"data,omitempty"`
+	Error   string                    `json:"error,omitempty"`
 }
 
 // User and Role Management Models
 
+// Permissions for managing users and roles
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersUpdate = "users:update"
+	PermissionRolesUpdate = "roles:update"
+)
+
 // UserStatus represents the status of a user
 type UserStatus string
 
@@ -955,18 +2105,20 @@ const (
 
 // User represents a user account in the system
 type User struct {
-	ID           uuid.UUID              `json:"id" db:"id"`
-	Name         string                 `json:"name" db:"name"`
-	Surname      string                 `json:"surname" db:"surname"`
-	Email        string                 `json:"email" db:"email"`
-	PasswordHash *string                `json:"-" db:"password_hash"`
-	OIDCProvider *string                `json:"oidc_provider,omitempty" db:"oidc_provider"`
-	OIDCSubject  *string                `json:"oidc_subject,omitempty" db:"oidc_subject"`
-	Status       UserStatus             `json:"status" db:"status"`
-	Metadata     map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
-	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
-	LastLoginAt  *time.Time             `json:"last_login_at,omitempty" db:"last_login_at"`
+	ID              uuid.UUID              `json:"id" db:"id"`
+	Name            string                 `json:"name" db:"name"`
+	Surname         string                 `json:"surname" db:"surname"`
+	Email           string                 `json:"email" db:"email"`
+	PasswordHash    *string                `json:"-" db:"password_hash"`
+	OIDCProvider    *string                `json:"oidc_provider,omitempty" db:"oidc_provider"`
+	OIDCSubject     *string                `json:"oidc_subject,omitempty" db:"oidc_subject"`
+	Status          UserStatus             `json:"status" db:"status"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt       time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at" db:"updated_at"`
+	LastLoginAt     *time.Time             `json:"last_login_at,omitempty" db:"last_login_at"`
+	LockedUntil     *time.Time             `json:"locked_until,omitempty" db:"locked_until"`
+	EmailVerifiedAt *time.Time             `json:"email_verified_at,omitempty" db:"email_verified_at"`
 }
 
 // Role represents a role in the system with associated permissions
@@ -1000,6 +2152,7 @@ type RoleInfo struct {
 	ID          uuid.UUID `json:"id"`
 	Name        string    `json:"name"`
 	Description *string   `json:"description,omitempty"`
+	Permissions []string  `json:"-"` // granted permissions, empty for inactive roles
 }
 
 // RoleWithUserCount represents a role with the count of associated users
@@ -1016,6 +2169,8 @@ type CreateUserRequest struct {
 	Surname  string `json:"surname" binding:"required,min=1,max=100"`
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
+	// RequireVerification creates the user as pending and emails a verification link
+	RequireVerification bool `json:"require_verification,omitempty"`
 }
 
 
@@ -1231,18 +2386,19 @@ type RoleListItem struct {
 
 // Session represents an active user session
 type Session struct {
-	ID           uuid.UUID              `json:"id" db:"id"`
-	UserID       uuid.UUID              `json:"user_id" db:"user_id"`
-	RefreshToken string                 `json:"-" db:"refresh_token"`
-	DeviceName   *string                `json:"device_name,omitempty" db:"device_name"`
-	DeviceType   *string                `json:"device_type,omitempty" db:"device_type"`
-	IPAddress    *string                `json:"ip_address,omitempty" db:"ip_address"`
-	UserAgent    *string                `json:"user_agent,omitempty" db:"user_agent"`
-	IsActive     bool                   `json:"is_active" db:"is_active"`
-	ExpiresAt    time.Time              `json:"expires_at" db:"expires_at"`
-	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
-	LastActivity time.Time              `json:"last_activity" db:"last_activity"`
-	Metadata     map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
+	ID               uuid.UUID              `json:"id" db:"id"`
+	UserID           uuid.UUID              `json:"user_id" db:"user_id"`
+	RefreshTokenHash string                 `json:"-" db:"refresh_token_hash"`
+	DeviceName       *string                `json:"device_name,omitempty" db:"device_name"`
+	DeviceType       *string                `json:"device_type,omitempty" db:"device_type"`
+	IPAddress        *string                `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent        *string                `json:"user_agent,omitempty" db:"user_agent"`
+	Location         *string                `json:"location,omitempty" db:"location"`
+	IsActive         bool                   `json:"is_active" db:"is_active"`
+	ExpiresAt        time.Time              `json:"expires_at" db:"expires_at"`
+	CreatedAt        time.Time              `json:"created_at" db:"created_at"`
+	LastActivity     time.Time              `json:"last_activity" db:"last_activity"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty" db:"metadata"`
 }
 
 // TokenBlacklist represents a revoked JWT token
@@ -1296,6 +2452,8 @@ type LoginResponse struct {
 type LoginResponseData struct {
 	TokenPair
 	User *UserWithRoles `json:"user"`
+	// RecoveryCodes is set once, when the login also completed a required MFA enrollment
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 // RefreshTokenRequest represents the request to refresh access token
@@ -1391,6 +2549,118 @@ type GoogleLinkResponse struct {
 	Message string `json:"message"`
 }
 
+// OpenID Connect Models
+
+// OIDCProviderInfo describes a configured identity provider
+type OIDCProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// OIDCProviderListResponse represents the list of identity providers users can sign in with
+type OIDCProviderListResponse struct {
+	Success bool               `json:"success"`
+	Data    []OIDCProviderInfo `json:"data"`
+}
+
+// OIDCLoginResponse represents the response to start an OpenID Connect sign-in or link
+type OIDCLoginResponse struct {
+	Success bool                   `json:"success"`
+	Data    *OIDCLoginResponseData `json:"data"`
+}
+
+// OIDCLoginResponseData contains the provider's authorization URL
+type OIDCLoginResponseData struct {
+	AuthURL string `json:"auth_url"`
+	State   string `json:"state"`
+}
+
+// OIDCCallbackRequest represents the authorization response relayed by the frontend
+type OIDCCallbackRequest struct {
+	Code       string      `json:"code" binding:"required"`
+	State      string      `json:"state" binding:"required"`
+	DeviceInfo *DeviceInfo `json:"device_info,omitempty"`
+}
+
+// OIDCCallbackResponse represents the response after an OpenID Connect callback
+type OIDCCallbackResponse struct {
+	Success bool                      `json:"success"`
+	Data    *OIDCCallbackResponseData `json:"data"`
+}
+
+// OIDCCallbackResponseData contains the new session, or the linked identity when the callback finished a link
+type OIDCCallbackResponseData struct {
+	*TokenPair
+	User           *UserWithRoles `json:"user"`
+	IsNewUser      bool           `json:"is_new_user"`
+	LinkedIdentity *UserIdentity  `json:"linked_identity,omitempty"`
+}
+
+// UserIdentity represents an external account linked to a user
+type UserIdentity struct {
+	ID             uuid.UUID   `json:"id"`
+	UserID         uuid.UUID   `json:"user_id"`
+	Provider       string      `json:"provider"`
+	Subject        string      `json:"subject"`
+	Email          *string     `json:"email,omitempty"`
+	GrantedRoleIDs []uuid.UUID `json:"-"`
+	CreatedAt      time.Time   `json:"created_at"`
+	LastLoginAt    *time.Time  `json:"last_login_at,omitempty"`
+}
+
+// UserIdentityListResponse represents the identities linked to the current user
+type UserIdentityListResponse struct {
+	Success bool           `json:"success"`
+	Data    []UserIdentity `json:"data"`
+}
+
+// Personal API Token Models
+
+// APIToken represents a named bearer token a user created for scripts and integrations
+type APIToken struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"` // first characters of the token, to tell tokens apart
+	Scopes      []string   `json:"scopes"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP  *string    `json:"last_used_ip,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateAPITokenRequest represents the request to create a personal API token
+type CreateAPITokenRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	// Permissions the token may use, at least one; each must be granted to the user by a role
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPITokenResponse represents a newly created token. The token itself is only returned here.
+type CreateAPITokenResponse struct {
+	Success bool                 `json:"success"`
+	Data    *CreatedAPITokenData `json:"data"`
+}
+
+// CreatedAPITokenData contains a new token and its metadata
+type CreatedAPITokenData struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// APITokenListResponse represents the active tokens of a user
+type APITokenListResponse struct {
+	Success bool       `json:"success"`
+	Data    []APIToken `json:"data"`
+}
+
+// RevokeAPITokensResponse represents the response after revoking all tokens of a user
+type RevokeAPITokensResponse struct {
+	Success bool  `json:"success"`
+	Revoked int64 `json:"revoked"`
+}
+
 // Session Management Models
 
 // SessionListResponse represents the response for listing sessions
@@ -1402,6 +2672,7 @@ type SessionListResponse struct {
 // SessionListData represents session list data
 type SessionListData struct {
 	Sessions []SessionInfo `json:"sessions"`
+	Alerts   []LoginAlert  `json:"alerts"`
 }
 
 // SessionListResponseData contains session list data
@@ -1421,6 +2692,27 @@ type SessionInfo struct {
 	IsCurrent    bool      `json:"is_current"`
 }
 
+// Login alert reasons
+const (
+	LoginAlertNewDevice  = "new_device"
+	LoginAlertNewCountry = "new_country"
+)
+
+// LoginAlert notifies a user about a login from a device or country they never
+// logged in from before, until they acknowledge it
+type LoginAlert struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"-" db:"user_id"`
+	SessionID      *uuid.UUID `json:"session_id,omitempty" db:"session_id"`
+	Reasons        []string   `json:"reasons" db:"reasons"`
+	DeviceName     *string    `json:"device_name,omitempty" db:"device_name"`
+	DeviceType     *string    `json:"device_type,omitempty" db:"device_type"`
+	IPAddress      *string    `json:"ip_address,omitempty" db:"ip_address"`
+	Location       *string    `json:"location,omitempty" db:"location"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty" db:"acknowledged_at"`
+}
+
 // RevokeSessionResponse represents the response after revoking a session
 type RevokeSessionResponse struct {
 	Success bool   `json:"success"`
@@ -1431,9 +2723,314 @@ type RevokeSessionResponse struct {
 
 // OAuthState represents OAuth state for CSRF protection
 type OAuthState struct {
-	State     string    `json:"state"`
-	ExpiresAt time.Time `json:"expires_at"`
-	UserID    *string   `json:"user_id,omitempty"`
+	State        string    `json:"state"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	UserID       *string   `json:"user_id,omitempty"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+}
+
+// JWT Signing Key Models
+
+// JWTKeyStatus represents the lifecycle state of a JWT signing key
+type JWTKeyStatus string
+
+const (
+	JWTKeyStatusActive   JWTKeyStatus = "active"   // signs new tokens
+	JWTKeyStatusPrevious JWTKeyStatus = "previous" // only verifies tokens issued before rotation
+	JWTKeyStatusRetired  JWTKeyStatus = "retired"  // no longer trusted
+)
+
+// JWTSigningKey represents an asymmetric key of the JWT key ring
+type JWTSigningKey struct {
+	ID         uuid.UUID    `json:"id" db:"id"`
+	KID        string       `json:"kid" db:"kid"`
+	Algorithm  string       `json:"algorithm" db:"algorithm"`
+	PrivateKey string       `json:"-" db:"private_key"`
+	PublicKey  string       `json:"public_key" db:"public_key"`
+	Status     JWTKeyStatus `json:"status" db:"status"`
+	CreatedAt  time.Time    `json:"created_at" db:"created_at"`
+	RotatedAt  *time.Time   `json:"rotated_at,omitempty" db:"rotated_at"`
+	RetiredAt  *time.Time   `json:"retired_at,omitempty" db:"retired_at"`
+}
+
+// JSONWebKey represents a public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+// JSONWebKeySet represents the public keys published at /.well-known/jwks.json
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// Two-Factor Authentication Models
+
+// Second factor methods accepted by an MFA challenge
+const (
+	MFAMethodTOTP         = "totp"
+	MFAMethodRecoveryCode = "recovery_code"
+)
+
+// UserMFA represents the TOTP enrollment of a user
+type UserMFA struct {
+	UserID       uuid.UUID  `json:"user_id" db:"user_id"`
+	TOTPSecret   string     `json:"-" db:"totp_secret"`
+	Enabled      bool       `json:"enabled" db:"enabled"`
+	LastUsedStep *int64     `json:"-" db:"last_used_step"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// MFARecoveryCode represents a hashed one-time recovery code
+type MFARecoveryCode struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	CodeHash  string     `json:"-" db:"code_hash"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MFAChallenge represents the pending second step of a password login
+type MFAChallenge struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Attempts   int        `json:"attempts" db:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty" db:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MFAStatus describes the two-factor setup of a user
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"`
+	EnrollmentPending      bool       `json:"enrollment_pending"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+}
+
+type MFAStatusResponse struct {
+	Success bool       `json:"success"`
+	Data    *MFAStatus `json:"data"`
+}
+
+// TOTPEnrollment contains what an authenticator app needs to add the account.
+// ProvisioningURI is the otpauth:// URI to render as a QR code.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	Issuer          string `json:"issuer"`
+	AccountName     string `json:"account_name"`
+	Algorithm       string `json:"algorithm"`
+	Digits          int    `json:"digits"`
+	Period          int    `json:"period"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Success bool            `json:"success"`
+	Data    *TOTPEnrollment `json:"data"`
+}
+
+// MFACodeRequest carries a current TOTP code to confirm a sensitive MFA change
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFARecoveryCodesResponse returns freshly generated recovery codes, shown only once
+type MFARecoveryCodesResponse struct {
+	Success bool                  `json:"success"`
+	Data    *MFARecoveryCodesData `json:"data"`
+}
+
+type MFARecoveryCodesData struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAMessageResponse is returned by MFA actions without data
+type MFAMessageResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// MFAChallengeResponse is returned by login when a second factor is needed
+type MFAChallengeResponse struct {
+	Success bool              `json:"success"`
+	Data    *MFAChallengeData `json:"data"`
+}
+
+type MFAChallengeData struct {
+	MFARequired        bool     `json:"mfa_required"`
+	EnrollmentRequired bool     `json:"enrollment_required"`
+	MFAToken           string   `json:"mfa_token"`
+	ExpiresIn          int      `json:"expires_in"`
+	Methods            []string `json:"methods"`
+}
+
+// MFAVerifyRequest completes a login with a TOTP code or a recovery code
+type MFAVerifyRequest struct {
+	MFAToken     string      `json:"mfa_token" binding:"required"`
+	Code         string      `json:"code,omitempty"`
+	RecoveryCode string      `json:"recovery_code,omitempty"`
+	DeviceInfo   *DeviceInfo `json:"device_info,omitempty"`
+}
+
+// MFAEnrollChallengeRequest starts the enrollment a role requires before login can complete
+type MFAEnrollChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// SetRoleMFARequest represents the request for enforcing MFA on a role
+type SetRoleMFARequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+type RoleMFAResponse struct {
+	Success bool         `json:"success"`
+	Data    *RoleMFAData `json:"data"`
+}
+
+type RoleMFAData struct {
+	RoleID     uuid.UUID `json:"role_id"`
+	RequireMFA bool      `json:"require_mfa"`
+}
+
+// Login Throttling Models
+
+// LoginThrottleScope identifies what failed logins are counted against
+type LoginThrottleScope string
+
+const (
+	LoginThrottleScopeAccount LoginThrottleScope = "account"
+	LoginThrottleScopeIP      LoginThrottleScope = "ip"
+)
+
+// LoginThrottle counts recent failed password logins of an account or a client IP
+type LoginThrottle struct {
+	Scope         LoginThrottleScope `json:"scope" db:"scope"`
+	Subject       string             `json:"subject" db:"subject"`
+	Failures      int                `json:"failures" db:"failures"`
+	Lockouts      int                `json:"lockouts" db:"lockouts"`
+	FirstFailedAt time.Time          `json:"first_failed_at" db:"first_failed_at"`
+	LastFailedAt  time.Time          `json:"last_failed_at" db:"last_failed_at"`
+	LockedUntil   *time.Time         `json:"locked_until,omitempty" db:"locked_until"`
+}
+
+type UnlockUserResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// Password Reset and Email Verification Models
+
+// UserTokenPurpose identifies what an emailed token can be used for
+type UserTokenPurpose string
+
+const (
+	UserTokenPurposePasswordReset     UserTokenPurpose = "password_reset"
+	UserTokenPurposeEmailVerification UserTokenPurpose = "email_verification"
+)
+
+// UserToken is a single-use token sent to a user by email
+type UserToken struct {
+	ID        uuid.UUID        `json:"id" db:"id"`
+	UserID    uuid.UUID        `json:"user_id" db:"user_id"`
+	Purpose   UserTokenPurpose `json:"purpose" db:"purpose"`
+	TokenHash string           `json:"-" db:"token_hash"`
+	Email     string           `json:"email" db:"email"`
+	ExpiresAt time.Time        `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time       `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type AccountMessageResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// Audit Log Models
+
+// PermissionAuditRead lets a user query the audit log
+const PermissionAuditRead = "audit:read"
+
+// AuditActor identifies who made the changes of a request. It is handed to the
+// audit triggers, so fields that are unknown must stay empty.
+type AuditActor struct {
+	UserID        *uuid.UUID `json:"user_id,omitempty"`
+	SessionID     *uuid.UUID `json:"session_id,omitempty"`
+	APITokenID    *uuid.UUID `json:"api_token_id,omitempty"`
+	AuthMethod    string     `json:"auth_method,omitempty"`
+	CorrelationID string     `json:"correlation_id,omitempty"`
+	RequestID     string     `json:"request_id,omitempty"`
+	IPAddress     string     `json:"ip_address,omitempty"`
+}
+
+// AuditLogEntry records one change of an audited entity. Updates only carry the
+// changed fields in Before and After; creates have no Before and deletes no After.
+type AuditLogEntry struct {
+	ID              int64                  `json:"id" db:"id"`
+	OccurredAt      time.Time              `json:"occurred_at" db:"occurred_at"`
+	ActorUserID     *uuid.UUID             `json:"actor_user_id,omitempty" db:"actor_user_id"`
+	ActorSessionID  *uuid.UUID             `json:"actor_session_id,omitempty" db:"actor_session_id"`
+	ActorAPITokenID *uuid.UUID             `json:"actor_api_token_id,omitempty" db:"actor_api_token_id"`
+	AuthMethod      *string                `json:"auth_method,omitempty" db:"auth_method"`
+	CorrelationID   *string                `json:"correlation_id,omitempty" db:"correlation_id"`
+	RequestID       *string                `json:"request_id,omitempty" db:"request_id"`
+	IPAddress       *string                `json:"ip_address,omitempty" db:"ip_address"`
+	EntityType      string                 `json:"entity_type" db:"entity_type"`
+	EntityID        string                 `json:"entity_id" db:"entity_id"`
+	Action          string                 `json:"action" db:"action"`
+	Before          map[string]interface{} `json:"before,omitempty" db:"before_data"`
+	After           map[string]interface{} `json:"after,omitempty" db:"after_data"`
+}
+
+// AuditLogFilters narrows an audit log query; empty fields don't filter
+type AuditLogFilters struct {
+	EntityType    string
+	EntityID      string
+	ActorUserID   *uuid.UUID
+	Action        string
+	CorrelationID string
+	From          *time.Time
+	To            *time.Time
+}
+
+type AuditLogListData struct {
+	Entries    []AuditLogEntry     `json:"entries"`
+	Pagination *PaginationResponse `json:"pagination"`
+}
+
+type AuditLogListResponse struct {
+	Success bool              `json:"success"`
+	Data    *AuditLogListData `json:"data"`
 }
 
 // APIError represents a generic API error response
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// purgeBatchSize bounds how many entries a single purge statement deletes
+const purgeBatchSize = 10000
+
+// Store is the audit log storage, implemented by database.PostgresDB.
+// Changes are recorded by database triggers, so it has no insert method.
+type Store interface {
+	ListAuditLog(ctx context.Context, filters *models.AuditLogFilters, pagination *models.PaginationOptions) ([]models.AuditLogEntry, int, error)
+	PurgeAuditLog(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Service queries the audit log and enforces its retention policy
+type Service struct {
+	store  Store
+	config *config.AuditConfig
+	now    func() time.Time
+}
+
+func NewService(store Store, cfg *config.AuditConfig) *Service {
+	return &Service{
+		store:  store,
+		config: cfg,
+		now:    time.Now,
+	}
+}
+
+// List returns the entries matching the filters, newest first, and the total number of matches
+func (s *Service) List(ctx context.Context, filters *models.AuditLogFilters, pagination *models.PaginationOptions) ([]models.AuditLogEntry, int, error) {
+	return s.store.ListAuditLog(ctx, filters, pagination)
+}
+
+// PurgeExpired deletes the entries older than the retention period and returns how many were deleted
+func (s *Service) PurgeExpired(ctx context.Context) (int64, error) {
+	if s.config.Retention <= 0 {
+		return 0, nil
+	}
+
+	before := s.now().Add(-s.config.Retention)
+	var purged int64
+	for {
+		deleted, err := s.store.PurgeAuditLog(ctx, before, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		purged += deleted
+		if deleted < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// StartRetention periodically purges expired entries until the context is cancelled
+func (s *Service) StartRetention(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeExpired(ctx)
+			if err != nil {
+				utils.LogError(ctx, "Failed to purge audit log", err)
+				continue
+			}
+			if purged > 0 {
+				utils.LogInfo(ctx, "Purged expired audit log entries", utils.Fields{
+					"count": purged,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// fakeStore deletes entries older than the cutoff in batches like PurgeAuditLog
+type fakeStore struct {
+	entries []time.Time
+	cutoffs []time.Time
+}
+
+func (s *fakeStore) ListAuditLog(ctx context.Context, filters *models.AuditLogFilters, pagination *models.PaginationOptions) ([]models.AuditLogEntry, int, error) {
+	return nil, 0, nil
+}
+
+func (s *fakeStore) PurgeAuditLog(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.cutoffs = append(s.cutoffs, before)
+
+	var deleted int64
+	kept := s.entries[:0]
+	for _, occurredAt := range s.entries {
+		if occurredAt.Before(before) && deleted < int64(limit) {
+			deleted++
+			continue
+		}
+		kept = append(kept, occurredAt)
+	}
+	s.entries = kept
+
+	return deleted, nil
+}
+
+func TestPurgeExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{}
+	for i := 0; i < purgeBatchSize+5; i++ {
+		store.entries = append(store.entries, now.AddDate(0, 0, -40))
+	}
+	store.entries = append(store.entries, now.AddDate(0, 0, -10))
+
+	service := NewService(store, &config.AuditConfig{Retention: 30 * 24 * time.Hour})
+	service.now = func() time.Time { return now }
+
+	purged, err := service.PurgeExpired(context.Background())
+	if err != nil {
+		t.Fatalf("PurgeExpired() error = %v", err)
+	}
+	if purged != purgeBatchSize+5 {
+		t.Errorf("PurgeExpired() = %d, want %d", purged, purgeBatchSize+5)
+	}
+	if len(store.entries) != 1 {
+		t.Errorf("%d entries left, want the one within the retention period", len(store.entries))
+	}
+	if len(store.cutoffs) != 2 {
+		t.Errorf("purged in %d batches, want 2", len(store.cutoffs))
+	}
+	if want := now.AddDate(0, 0, -30); !store.cutoffs[0].Equal(want) {
+		t.Errorf("cutoff = %v, want %v", store.cutoffs[0], want)
+	}
+}
+
+func TestPurgeExpiredKeepsForeverWithoutRetention(t *testing.T) {
+	store := &fakeStore{entries: []time.Time{time.Now().AddDate(-10, 0, 0)}}
+	service := NewService(store, &config.AuditConfig{})
+
+	purged, err := service.PurgeExpired(context.Background())
+	if err != nil || purged != 0 {
+		t.Errorf("PurgeExpired() = %d, %v, want nothing purged", purged, err)
+	}
+	if len(store.cutoffs) != 0 {
+		t.Error("PurgeExpired() queried the store with retention disabled")
+	}
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/mail"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// mailSendTimeout bounds the delivery of a single email
+const mailSendTimeout = 30 * time.Second
+
+// ErrAccountTokenInvalid is returned for unknown, used or expired reset and verification tokens
+var ErrAccountTokenInvalid = errors.New("token is invalid or expired")
+
+// AccountConfig represents password reset and email verification configuration
+type AccountConfig struct {
+	AppBaseURL           string // frontend address the emailed links point to
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+}
+
+// AccountService handles self-service password reset and email verification
+type AccountService struct {
+	config         AccountConfig
+	db             *database.PostgresDB
+	sessionService *SessionService
+	sender         mail.Sender
+}
+
+// NewAccountService creates a new account service
+func NewAccountService(config AccountConfig, db *database.PostgresDB, sessionService *SessionService, sender mail.Sender) *AccountService {
+	return &AccountService{
+		config:         config,
+		db:             db,
+		sessionService: sessionService,
+		sender:         sender,
+	}
+}
+
+// RequestPasswordReset emails a reset link if the address belongs to an active user.
+// It never tells whether it did, so it can't be used to discover accounts.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, &user.User, models.UserTokenPurposePasswordReset, s.config.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Someone asked to reset the password of your account. Open the link below to choose a new one:\n\n"+
+			"%s\n\n"+
+			"The link works once and expires in %s. If you didn't ask for this, ignore this email.\n",
+			user.Name, s.link("/reset-password", token), s.config.PasswordResetTTL),
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out everywhere.
+// The password must already be validated.
+func (s *AccountService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	resetToken, err := s.db.ConsumeUserToken(ctx, models.UserTokenPurposePasswordReset, hashOpaqueToken(token))
+	if err != nil {
+		return err
+	}
+	if resetToken == nil {
+		return ErrAccountTokenInvalid
+	}
+
+	user, err := s.db.GetUserByID(ctx, resetToken.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status != models.UserStatusActive || !sameEmail(user.Email, resetToken.Email) {
+		return ErrAccountTokenInvalid
+	}
+
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.ResetUserPassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+
+	if err := s.sessionService.RevokeUserSessions(ctx, user.ID); err != nil {
+		utils.LogError(ctx, "Failed to revoke sessions after password reset", err, utils.Fields{
+			"user_id": user.ID,
+		})
+	}
+
+	// Whoever controls the mailbox owns the account, so lift any lockout too
+	if err := s.db.ClearLoginFailures(ctx, models.LoginThrottleScopeAccount, normalizeLoginEmail(user.Email)); err != nil {
+		utils.LogError(ctx, "Failed to clear login failures after password reset", err, utils.Fields{
+			"user_id": user.ID,
+		})
+	}
+
+	utils.LogInfo(ctx, "Password reset", utils.Fields{
+		"user_id": user.ID,
+	})
+
+	return nil
+}
+
+// SendVerification emails a link that verifies the current address of a user
+func (s *AccountService) SendVerification(ctx context.Context, user *models.User) error {
+	token, err := s.issueToken(ctx, user, models.UserTokenPurposeEmailVerification, s.config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	s.deliver(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"Please confirm your email address by opening the link below:\n\n"+
+			"%s\n\n"+
+			"The link expires in %s.\n",
+			user.Name, s.link("/verify-email", token), s.config.EmailVerificationTTL),
+	})
+
+	return nil
+}
+
+// ResendVerification emails a new verification link if the address belongs to an
+// unverified user. Like RequestPasswordReset it doesn't tell whether it did.
+func (s *AccountService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.db.LookupUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	if user.Status != models.UserStatusPending && user.Status != models.UserStatusActive {
+		return nil
+	}
+
+	return s.SendVerification(ctx, &user.User)
+}
+
+// VerifyEmail marks the address a token was sent to as verified, activating a pending user
+func (s *AccountService) VerifyEmail(ctx context.Context, token string) (uuid.UUID, error) {
+	verification, err := s.db.ConsumeUserToken(ctx, models.UserTokenPurposeEmailVerification, hashOpaqueToken(token))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if verification == nil {
+		return uuid.Nil, ErrAccountTokenInvalid
+	}
+
+	// Fails if the user changed their address after the link was sent
+	verified, err := s.db.VerifyUserEmail(ctx, verification.UserID, verification.Email)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !verified {
+		return uuid.Nil, ErrAccountTokenInvalid
+	}
+
+	utils.LogInfo(ctx, "Email verified", utils.Fields{
+		"user_id": verification.UserID,
+	})
+
+	return verification.UserID, nil
+}
+
+func (s *AccountService) issueToken(ctx context.Context, user *models.User, purpose models.UserTokenPurpose, ttl time.Duration) (string, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.db.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		TokenHash: hashOpaqueToken(token),
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *AccountService) link(path, token string) string {
+	return s.config.AppBaseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// deliver sends a message in the background, so response times don't reveal
+// whether an address belongs to an account
+func (s *AccountService) deliver(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.sender.Send(ctx, msg); err != nil {
+			utils.LogError(ctx, "Failed to send mail", err, utils.Fields{
+				"subject": msg.Subject,
+			})
+		}
+	}()
+}
+
+func sameEmail(a, b string) bool {
+	return normalizeLoginEmail(a) == normalizeLoginEmail(b)
+}
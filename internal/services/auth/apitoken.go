@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	// APITokenPrefix marks personal API tokens, so they can't be mistaken for JWTs
+	APITokenPrefix = "stp_"
+	// apiTokenDisplayLength is how much of a token is kept in clear to tell tokens apart
+	apiTokenDisplayLength = len(APITokenPrefix) + 8
+	// maxAPITokensPerUser bounds the active tokens of a user
+	maxAPITokensPerUser = 50
+)
+
+var (
+	ErrAPITokenInvalid     = errors.New("API token is invalid, expired or revoked")
+	ErrAPITokenNotFound    = errors.New("API token not found")
+	ErrAPITokenScope       = errors.New("API token scope is not granted to the user")
+	ErrAPITokenNoScopes    = errors.New("API token needs at least one scope")
+	ErrAPITokenExpiry      = errors.New("API token expiry is invalid")
+	ErrAPITokenUnavailable = errors.New("API token name is taken or the token limit is reached")
+)
+
+// APITokenConfig represents personal API token configuration
+type APITokenConfig struct {
+	DefaultTTL time.Duration // lifetime of tokens created without an expiry
+	MaxTTL     time.Duration // longest lifetime a token can be given, 0 for no limit
+}
+
+// APITokenPrincipal is the user a request authenticated with an API token acts as
+type APITokenPrincipal struct {
+	Token       *models.APIToken
+	User        *models.UserWithRoles
+	Roles       []string
+	Permissions []string // token scopes the user still holds
+}
+
+// APITokenService manages personal API tokens for scripts and integrations
+type APITokenService struct {
+	config APITokenConfig
+	db     *database.PostgresDB
+	now    func() time.Time
+}
+
+// NewAPITokenService creates a new API token service
+func NewAPITokenService(config APITokenConfig, db *database.PostgresDB) *APITokenService {
+	return &APITokenService{
+		config: config,
+		db:     db,
+		now:    time.Now,
+	}
+}
+
+// IsAPIToken tells whether a bearer credential is a personal API token rather than a JWT
+func IsAPIToken(credential string) bool {
+	return strings.HasPrefix(credential, APITokenPrefix)
+}
+
+// Create issues a token for a user. The token value is returned once and only its hash is stored.
+func (s *APITokenService) Create(ctx context.Context, userID uuid.UUID, req *models.CreateAPITokenRequest) (*models.APIToken, string, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user == nil || user.Status != models.UserStatusActive {
+		return nil, "", ErrAPITokenInvalid
+	}
+
+	_, granted := rolesAndPermissions(user)
+	scopes, err := normalizeScopes(req.Scopes, granted)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expiresAt, err := s.expiry(req.ExpiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	value := APITokenPrefix + secret
+
+	token := &models.APIToken{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		TokenPrefix: value[:apiTokenDisplayLength],
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	}
+	created, err := s.db.CreateAPIToken(ctx, token, hashOpaqueToken(value), maxAPITokensPerUser)
+	if err != nil {
+		return nil, "", err
+	}
+	if !created {
+		return nil, "", ErrAPITokenUnavailable
+	}
+
+	utils.LogInfo(ctx, "API token created", utils.Fields{
+		"user_id":  userID,
+		"token_id": token.ID,
+		"scopes":   scopes,
+	})
+
+	return token, value, nil
+}
+
+// List lists the tokens of a user
+func (s *APITokenService) List(ctx context.Context, userID uuid.UUID) ([]models.APIToken, error) {
+	return s.db.ListAPITokens(ctx, userID)
+}
+
+// Revoke revokes a token of a user
+func (s *APITokenService) Revoke(ctx context.Context, userID, tokenID uuid.UUID) error {
+	revoked, err := s.db.RevokeAPIToken(ctx, userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPITokenNotFound
+	}
+
+	utils.LogInfo(ctx, "API token revoked", utils.Fields{
+		"user_id":  userID,
+		"token_id": tokenID,
+	})
+
+	return nil
+}
+
+// Authenticate resolves a token to the user it acts for. The token can only use the
+// permissions in its scopes that the user's roles still grant.
+func (s *APITokenService) Authenticate(ctx context.Context, credential, clientIP string) (*APITokenPrincipal, error) {
+	if !IsAPIToken(credential) {
+		return nil, ErrAPITokenInvalid
+	}
+
+	token, err := s.db.GetAPITokenByHash(ctx, hashOpaqueToken(credential))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, ErrAPITokenInvalid
+	}
+
+	user, err := s.db.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status != models.UserStatusActive {
+		return nil, ErrAPITokenInvalid
+	}
+
+	if err := s.db.TouchAPIToken(ctx, token.ID, clientIP); err != nil {
+		utils.LogError(ctx, "Failed to record API token use", err, utils.Fields{
+			"token_id": token.ID,
+		})
+	}
+
+	roles, granted := rolesAndPermissions(user)
+	return &APITokenPrincipal{
+		Token:       token,
+		User:        user,
+		Roles:       roles,
+		Permissions: scopedPermissions(token.Scopes, granted),
+	}, nil
+}
+
+// expiry resolves the requested expiry against the configured default and limit
+func (s *APITokenService) expiry(requested *time.Time) (*time.Time, error) {
+	now := s.now()
+
+	if requested == nil {
+		if s.config.DefaultTTL <= 0 {
+			return nil, nil
+		}
+		expiresAt := now.Add(s.config.DefaultTTL)
+		return &expiresAt, nil
+	}
+
+	if !requested.After(now) {
+		return nil, fmt.Errorf("%w: must be in the future", ErrAPITokenExpiry)
+	}
+	if s.config.MaxTTL > 0 && requested.Sub(now) > s.config.MaxTTL {
+		return nil, fmt.Errorf("%w: must be within %s", ErrAPITokenExpiry, s.config.MaxTTL)
+	}
+
+	expiresAt := requested.UTC()
+	return &expiresAt, nil
+}
+
+// normalizeScopes sorts and deduplicates requested scopes, rejecting any the user isn't granted
+// and an empty list
+func normalizeScopes(requested, granted []string) ([]string, error) {
+	allowed := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		allowed[permission] = true
+	}
+
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range requested {
+		scope = strings.TrimSpace(scope)
+		if scope == "" || seen[scope] {
+			continue
+		}
+		if !allowed[scope] {
+			return nil, fmt.Errorf("%w: %s", ErrAPITokenScope, scope)
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, ErrAPITokenNoScopes
+	}
+	sort.Strings(scopes)
+
+	return scopes, nil
+}
+
+// scopedPermissions returns the scopes that are still among the granted permissions
+func scopedPermissions(scopes, granted []string) []string {
+	allowed := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		allowed[permission] = true
+	}
+
+	permissions := []string{}
+	for _, scope := range scopes {
+		if allowed[scope] {
+			permissions = append(permissions, scope)
+		}
+	}
+	return permissions
+}
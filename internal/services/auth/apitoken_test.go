@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	granted := []string{"events:read", "guests:read", "guests:update"}
+
+	scopes, err := normalizeScopes([]string{"guests:read", " events:read ", "guests:read", ""}, granted)
+	if err != nil {
+		t.Fatalf("normalizeScopes() error = %v", err)
+	}
+	if want := []string{"events:read", "guests:read"}; !reflect.DeepEqual(scopes, want) {
+		t.Errorf("normalizeScopes() = %v, want %v", scopes, want)
+	}
+
+	if _, err := normalizeScopes([]string{"guests:read", "users:delete"}, granted); !errors.Is(err, ErrAPITokenScope) {
+		t.Errorf("ungranted scope: error = %v, want %v", err, ErrAPITokenScope)
+	}
+
+	for _, requested := range [][]string{nil, {"", " "}} {
+		if _, err := normalizeScopes(requested, granted); !errors.Is(err, ErrAPITokenNoScopes) {
+			t.Errorf("no scopes %q: error = %v, want %v", requested, err, ErrAPITokenNoScopes)
+		}
+	}
+}
+
+func TestScopedPermissions(t *testing.T) {
+	// guests:update was revoked from the user's roles after the token was created
+	got := scopedPermissions([]string{"events:read", "guests:update"}, []string{"events:read", "guests:read"})
+	if want := []string{"events:read"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("scopedPermissions() = %v, want %v", got, want)
+	}
+}
+
+func TestAPITokenExpiry(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service := NewAPITokenService(APITokenConfig{DefaultTTL: 90 * 24 * time.Hour, MaxTTL: 365 * 24 * time.Hour}, nil)
+	service.now = clock.Now
+
+	expiresAt, err := service.expiry(nil)
+	if err != nil {
+		t.Fatalf("default expiry: error = %v", err)
+	}
+	if want := clock.now.Add(90 * 24 * time.Hour); expiresAt == nil || !expiresAt.Equal(want) {
+		t.Errorf("default expiry = %v, want %v", expiresAt, want)
+	}
+
+	requested := clock.now.Add(30 * 24 * time.Hour)
+	expiresAt, err = service.expiry(&requested)
+	if err != nil || expiresAt == nil || !expiresAt.Equal(requested) {
+		t.Errorf("requested expiry = %v, %v, want %v", expiresAt, err, requested)
+	}
+
+	past := clock.now.Add(-time.Minute)
+	if _, err := service.expiry(&past); !errors.Is(err, ErrAPITokenExpiry) {
+		t.Errorf("past expiry: error = %v, want %v", err, ErrAPITokenExpiry)
+	}
+
+	tooLate := clock.now.Add(2 * 365 * 24 * time.Hour)
+	if _, err := service.expiry(&tooLate); !errors.Is(err, ErrAPITokenExpiry) {
+		t.Errorf("expiry over the limit: error = %v, want %v", err, ErrAPITokenExpiry)
+	}
+
+	service.config.DefaultTTL = 0
+	if expiresAt, err := service.expiry(nil); err != nil || expiresAt != nil {
+		t.Errorf("no default TTL: got %v, %v, want a token that never expires", expiresAt, err)
+	}
+}
+
+func TestIsAPIToken(t *testing.T) {
+	if !IsAPIToken(APITokenPrefix + "abc") {
+		t.Error("IsAPIToken() = false for a prefixed token")
+	}
+	if IsAPIToken("eyJhbGciOiJSUzI1NiJ9.e30.sig") {
+		t.Error("IsAPIToken() = true for a JWT")
+	}
+}
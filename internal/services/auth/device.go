@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// Device types derived from the user agent
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+	DeviceTypeAPI     = "api"
+	DeviceTypeUnknown = "unknown"
+)
+
+// GeoLocator resolves the country of a client IP address, implemented by geoip.Database
+type GeoLocator interface {
+	Country(ip string) string
+}
+
+// LoginAlertHook is notified about logins from a new device or country, for example to email the user
+type LoginAlertHook func(ctx context.Context, alert *models.LoginAlert)
+
+// userAgentMarker maps a user agent token to the name it stands for
+type userAgentMarker struct {
+	token string
+	name  string
+}
+
+// Order matters: Chromium based browsers also claim to be Chrome and Safari,
+// and Chrome claims to be Safari
+var browserMarkers = []userAgentMarker{
+	{"edg/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex Browser"},
+	{"vivaldi/", "Vivaldi"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chromium"},
+	{"safari/", "Safari"},
+}
+
+var clientMarkers = []userAgentMarker{
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"postmanruntime/", "Postman"},
+	{"insomnia/", "Insomnia"},
+	{"okhttp/", "OkHttp"},
+	{"go-http-client/", "Go HTTP client"},
+	{"python-requests/", "Python Requests"},
+	{"axios/", "Axios"},
+	{"node-fetch/", "Node.js"},
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "slurp"}
+
+// ParseUserAgent derives a device name such as "Firefox on Windows" and a device type
+// from a User-Agent header. Only the browser and operating system are kept, without
+// versions, so updates don't make a device look new.
+func ParseUserAgent(userAgent string) (name, deviceType string) {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return "", ""
+	}
+
+	for _, token := range botTokens {
+		if strings.Contains(ua, token) {
+			return "Bot", DeviceTypeBot
+		}
+	}
+
+	for _, marker := range clientMarkers {
+		if strings.HasPrefix(ua, marker.token) {
+			return marker.name, DeviceTypeAPI
+		}
+	}
+
+	os, deviceType := parseOperatingSystem(ua)
+
+	browser := ""
+	for _, marker := range browserMarkers {
+		if strings.Contains(ua, marker.token) {
+			browser = marker.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os, deviceType
+	case browser != "":
+		return browser, deviceType
+	case os != "":
+		return os, deviceType
+	default:
+		return "Unknown device", DeviceTypeUnknown
+	}
+}
+
+func parseOperatingSystem(ua string) (string, string) {
+	switch {
+	case strings.Contains(ua, "ipad"):
+		return "iPadOS", DeviceTypeTablet
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipod"):
+		return "iOS", DeviceTypeMobile
+	case strings.Contains(ua, "android"):
+		// Android tablets leave out "Mobile"
+		if strings.Contains(ua, "mobile") {
+			return "Android", DeviceTypeMobile
+		}
+		return "Android", DeviceTypeTablet
+	case strings.Contains(ua, "windows phone"):
+		return "Windows Phone", DeviceTypeMobile
+	case strings.Contains(ua, "windows"):
+		return "Windows", DeviceTypeDesktop
+	case strings.Contains(ua, "cros"):
+		return "ChromeOS", DeviceTypeDesktop
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return "macOS", DeviceTypeDesktop
+	case strings.Contains(ua, "linux"):
+		return "Linux", DeviceTypeDesktop
+	default:
+		return "", DeviceTypeUnknown
+	}
+}
+
+// deviceKey identifies a device for new device detection
+func deviceKey(session *models.Session) string {
+	if session.DeviceName == nil {
+		return ""
+	}
+	key := *session.DeviceName
+	if session.DeviceType != nil {
+		key += "/" + *session.DeviceType
+	}
+	return key
+}
+
+// LogLoginAlert is a LoginAlertHook that logs the alert
+func LogLoginAlert(ctx context.Context, alert *models.LoginAlert) {
+	fields := utils.Fields{
+		"user_id": alert.UserID,
+		"reasons": alert.Reasons,
+	}
+	if alert.SessionID != nil {
+		fields["session_id"] = alert.SessionID
+	}
+	if alert.DeviceName != nil {
+		fields["device_name"] = *alert.DeviceName
+	}
+	if alert.IPAddress != nil {
+		fields["ip_address"] = *alert.IPAddress
+	}
+	if alert.Location != nil {
+		fields["location"] = *alert.Location
+	}
+
+	utils.LogWarn(ctx, "Login from a new device or country", fields)
+}
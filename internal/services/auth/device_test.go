@@ -0,0 +1,32 @@
+package auth
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent  string
+		name       string
+		deviceType string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "Chrome on Windows", DeviceTypeDesktop},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51", "Edge on Windows", DeviceTypeDesktop},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_4_1) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15", "Safari on macOS", DeviceTypeDesktop},
+		{"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0", "Firefox on Linux", DeviceTypeDesktop},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1", "Chrome on iOS", DeviceTypeMobile},
+		{"Mozilla/5.0 (iPad; CPU OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", "Safari on iPadOS", DeviceTypeTablet},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Mobile Safari/537.36", "Chrome on Android", DeviceTypeMobile},
+		{"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Safari/537.36", "Samsung Internet on Android", DeviceTypeTablet},
+		{"curl/8.5.0", "curl", DeviceTypeAPI},
+		{"PostmanRuntime/7.37.3", "Postman", DeviceTypeAPI},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Bot", DeviceTypeBot},
+		{"SomethingElse/1.0", "Unknown device", DeviceTypeUnknown},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		name, deviceType := ParseUserAgent(tt.userAgent)
+		if name != tt.name || deviceType != tt.deviceType {
+			t.Errorf("ParseUserAgent(%q) = %q, %q, want %q, %q", tt.userAgent, name, deviceType, tt.name, tt.deviceType)
+		}
+	}
+}
@@ -29,45 +29,48 @@ type JWTConfig struct {
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
 	Issuer               string
+	// AcceptLegacyHS256 keeps accepting HS256 tokens signed with SecretKey
+	// that were issued before the switch to the key ring
+	AcceptLegacyHS256 bool
 }
 
 // JWTService handles JWT token operations
 type JWTService struct {
 	config   JWTConfig
 	secretKey []byte
+	keys      *KeyRing
 }
 
-// NewJWTService creates a new JWT service
-func NewJWTService(config JWTConfig) *JWTService {
+// NewJWTService creates a new JWT service signing with the active key of the key ring
+func NewJWTService(config JWTConfig, keys *KeyRing) *JWTService {
 	return &JWTService{
 		config:   config,
 		secretKey: []byte(config.SecretKey),
+		keys:      keys,
 	}
 }
 
 // GenerateTokenPair generates both access and refresh tokens
 func (j *JWTService) GenerateTokenPair(user *models.UserWithRoles, sessionID uuid.UUID) (*models.TokenPair, error) {
-	// Extract roles and permissions
-	roles := make([]string, len(user.Roles))
-	
-	for i, role := range user.Roles {
-		roles[i] = role.Name
-	}
+	tokenPair, _, err := j.generateTokenPair(user, sessionID)
+	return tokenPair, err
+}
 
-	// Note: For full permission extraction, we'd need to query the database
-	// For now, we'll use a simplified approach
-	permissions := []string{} // This would be populated from user roles
+// generateTokenPair generates both tokens and also returns the claims of the access token,
+// so sessions can track the access tokens issued to them
+func (j *JWTService) generateTokenPair(user *models.UserWithRoles, sessionID uuid.UUID) (*models.TokenPair, *JWTClaims, error) {
+	roles, permissions := rolesAndPermissions(user)
 
 	// Generate access token
-	accessToken, err := j.generateToken(user, sessionID, "access", roles, permissions, j.config.AccessTokenDuration)
+	accessToken, accessClaims, err := j.generateToken(user, sessionID, "access", roles, permissions, j.config.AccessTokenDuration)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
 	// Generate refresh token
-	refreshToken, err := j.generateToken(user, sessionID, "refresh", roles, permissions, j.config.RefreshTokenDuration)
+	refreshToken, _, err := j.generateToken(user, sessionID, "refresh", roles, permissions, j.config.RefreshTokenDuration)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
 	return &models.TokenPair{
@@ -75,15 +78,34 @@ func (j *JWTService) GenerateTokenPair(user *models.UserWithRoles, sessionID uui
 		RefreshToken: refreshToken,
 		TokenType:    "Bearer",
 		ExpiresIn:    int(j.config.AccessTokenDuration.Seconds()),
-	}, nil
+	}, accessClaims, nil
+}
+
+// rolesAndPermissions returns the role names of a user and the union of their permissions
+func rolesAndPermissions(user *models.UserWithRoles) ([]string, []string) {
+	roles := make([]string, len(user.Roles))
+	permissions := []string{}
+	seen := make(map[string]bool)
+
+	for i, role := range user.Roles {
+		roles[i] = role.Name
+		for _, permission := range role.Permissions {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	return roles, permissions
 }
 
 // generateToken creates a JWT token with the specified parameters
-func (j *JWTService) generateToken(user *models.UserWithRoles, sessionID uuid.UUID, tokenType string, roles, permissions []string, duration time.Duration) (string, error) {
+func (j *JWTService) generateToken(user *models.UserWithRoles, sessionID uuid.UUID, tokenType string, roles, permissions []string, duration time.Duration) (string, *JWTClaims, error) {
 	now := time.Now()
 	jti, err := j.generateJTI()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate JTI: %w", err)
+		return "", nil, fmt.Errorf("failed to generate JTI: %w", err)
 	}
 
 	claims := JWTClaims{
@@ -103,23 +125,24 @@ func (j *JWTService) generateToken(user *models.UserWithRoles, sessionID uuid.UU
 		TokenType:   tokenType,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(j.secretKey)
+	key := j.keys.activeKey()
+	if key == nil {
+		return "", nil, fmt.Errorf("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", nil, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, &claims, nil
 }
 
 // ValidateToken validates and parses a JWT token
 func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secretKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, j.verificationKey)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -142,6 +165,34 @@ func (j *JWTService) ValidateToken(tokenString string) (*JWTClaims, error) {
 	return claims, nil
 }
 
+// verificationKey resolves the key a token was signed with from its kid header.
+// Only keys of the ring that are not retired are accepted, and the token's
+// algorithm must match the key's.
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if j.config.AcceptLegacyHS256 && token.Method == jwt.SigningMethodHS256 {
+			return j.secretKey, nil
+		}
+		return nil, fmt.Errorf("token has no key ID")
+	}
+
+	key := j.keys.verificationKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown or retired signing key: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.public, nil
+}
+
+// JWKS returns the public keys that verify tokens issued by this service
+func (j *JWTService) JWKS() models.JSONWebKeySet {
+	return j.keys.JWKS()
+}
+
 // ValidateAccessToken validates an access token specifically
 func (j *JWTService) ValidateAccessToken(tokenString string) (*JWTClaims, error) {
 	claims, err := j.ValidateToken(tokenString)
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// newTestKey generates a key the way the key ring stores it
+func newTestKey(t *testing.T, algorithm string) *signingKey {
+	t.Helper()
+
+	stored, err := generateSigningKey(algorithm)
+	if err != nil {
+		t.Fatalf("Failed to generate %s key: %v", algorithm, err)
+	}
+	stored.CreatedAt = time.Now()
+
+	key, err := parseSigningKey(stored)
+	if err != nil {
+		t.Fatalf("Failed to parse %s key: %v", algorithm, err)
+	}
+	return key
+}
+
+func newTestJWTService(acceptLegacy bool, keys ...*signingKey) *JWTService {
+	ring := &KeyRing{keys: make(map[string]*signingKey), lastReload: time.Now()}
+	for _, key := range keys {
+		ring.keys[key.kid] = key
+	}
+	if len(keys) > 0 {
+		ring.active = keys[0]
+	}
+
+	return NewJWTService(JWTConfig{
+		SecretKey:            "legacy-secret",
+		AccessTokenDuration:  15 * time.Minute,
+		RefreshTokenDuration: time.Hour,
+		Issuer:               "test",
+		AcceptLegacyHS256:    acceptLegacy,
+	}, ring)
+}
+
+func testUser() *models.UserWithRoles {
+	return &models.UserWithRoles{
+		User: models.User{ID: uuid.New(), Email: "user@example.com"},
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			oldKey := newTestKey(t, algorithm)
+			service := newTestJWTService(false, oldKey)
+
+			pair, err := service.GenerateTokenPair(testUser(), uuid.New())
+			if err != nil {
+				t.Fatalf("Failed to generate tokens: %v", err)
+			}
+
+			token, _, err := new(jwt.Parser).ParseUnverified(pair.AccessToken, &JWTClaims{})
+			if err != nil {
+				t.Fatalf("Failed to parse token: %v", err)
+			}
+			if token.Header["kid"] != oldKey.kid {
+				t.Errorf("Expected kid %s, got %v", oldKey.kid, token.Header["kid"])
+			}
+			if token.Method.Alg() != algorithm {
+				t.Errorf("Expected alg %s, got %s", algorithm, token.Method.Alg())
+			}
+
+			// Rotate: the previous key keeps verifying tokens it signed
+			newKey := newTestKey(t, algorithm)
+			service.keys.keys[newKey.kid] = newKey
+			service.keys.active = newKey
+
+			if _, err := service.ValidateAccessToken(pair.AccessToken); err != nil {
+				t.Errorf("Expected token of previous key to validate: %v", err)
+			}
+
+			// Retire: tokens of the old key are rejected
+			delete(service.keys.keys, oldKey.kid)
+			if _, err := service.ValidateAccessToken(pair.AccessToken); err == nil {
+				t.Error("Expected token of retired key to be rejected")
+			}
+
+			if jwks := service.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != newKey.kid {
+				t.Errorf("Expected JWKS with only the active key, got %+v", jwks.Keys)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRejectsForeignAlgorithms(t *testing.T) {
+	key := newTestKey(t, AlgorithmEdDSA)
+	claims := JWTClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		TokenType: "access",
+	}
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign legacy token: %v", err)
+	}
+
+	if _, err := newTestJWTService(false, key).ValidateToken(legacy); err == nil {
+		t.Error("Expected legacy HS256 token to be rejected when disabled")
+	}
+	if _, err := newTestJWTService(true, key).ValidateToken(legacy); err != nil {
+		t.Errorf("Expected legacy HS256 token to validate when enabled: %v", err)
+	}
+
+	// An HMAC token claiming the kid of an asymmetric key must not validate
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = key.kid
+	forgedString, err := forged.SignedString([]byte("legacy-secret"))
+	if err != nil {
+		t.Fatalf("Failed to sign forged token: %v", err)
+	}
+	if _, err := newTestJWTService(true, key).ValidateToken(forgedString); err == nil {
+		t.Error("Expected HS256 token with asymmetric kid to be rejected")
+	}
+}
+
+func TestGenerateTokenPairReturnsAccessClaims(t *testing.T) {
+	service := newTestJWTService(false, newTestKey(t, AlgorithmEdDSA))
+	sessionID := uuid.New()
+
+	pair, accessClaims, err := service.generateTokenPair(testUser(), sessionID)
+	if err != nil {
+		t.Fatalf("generateTokenPair() error = %v", err)
+	}
+
+	access, err := service.ValidateAccessToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken() error = %v", err)
+	}
+	if access.ID != accessClaims.ID || !access.ExpiresAt.Time.Equal(accessClaims.ExpiresAt.Time) {
+		t.Errorf("access claims = %s expiring %v, token has %s expiring %v",
+			accessClaims.ID, accessClaims.ExpiresAt.Time, access.ID, access.ExpiresAt.Time)
+	}
+	if access.SessionID != sessionID.String() {
+		t.Errorf("access token session = %s, want %s", access.SessionID, sessionID)
+	}
+
+	refresh, err := service.ValidateRefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() error = %v", err)
+	}
+	if refresh.ID == access.ID {
+		t.Error("access and refresh tokens share a JTI")
+	}
+}
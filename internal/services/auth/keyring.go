@@ -0,0 +1,386 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// Supported asymmetric signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits = 2048
+	// keyReloadCooldown limits how often an unknown kid triggers a reload from the database
+	keyReloadCooldown = 30 * time.Second
+)
+
+// KeyRingConfig represents JWT key ring configuration
+type KeyRingConfig struct {
+	Algorithm        string        // algorithm of newly generated keys, RS256 or EdDSA
+	RotationInterval time.Duration // how long a key signs new tokens before it is rotated
+	Retention        time.Duration // how long a rotated key keeps verifying tokens, at least the refresh token lifetime
+}
+
+// signingKey is a parsed key of the key ring
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+}
+
+// KeyRing holds the asymmetric keys used to sign and verify JWTs.
+// Keys are stored in the database so every instance signs with the same active key
+// and trusts the same previous keys. Retired keys are dropped from the ring.
+type KeyRing struct {
+	db     *database.PostgresDB
+	config KeyRingConfig
+
+	mu         sync.RWMutex
+	keys       map[string]*signingKey
+	active     *signingKey
+	lastReload time.Time
+}
+
+// NewKeyRing creates a new, empty key ring. Call Load before use.
+func NewKeyRing(db *database.PostgresDB, config KeyRingConfig) (*KeyRing, error) {
+	if _, err := signingMethod(config.Algorithm); err != nil {
+		return nil, err
+	}
+
+	return &KeyRing{
+		db:     db,
+		config: config,
+		keys:   make(map[string]*signingKey),
+	}, nil
+}
+
+// Load reads the keys from the database, generating the first key if there is none
+func (k *KeyRing) Load(ctx context.Context) error {
+	if err := k.reload(ctx); err != nil {
+		return err
+	}
+
+	if k.activeKey() == nil {
+		return k.rotate(ctx, "")
+	}
+
+	return nil
+}
+
+// Rotate generates a new active key. The current key keeps verifying tokens until it is retired.
+func (k *KeyRing) Rotate(ctx context.Context) error {
+	current := ""
+	if active := k.activeKey(); active != nil {
+		current = active.kid
+	}
+
+	return k.rotate(ctx, current)
+}
+
+// StartRotation periodically reloads the ring, rotates the active key once it is older
+// than the rotation interval and retires keys past their retention.
+// It blocks until ctx is cancelled.
+func (k *KeyRing) StartRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.maintain(ctx); err != nil {
+				utils.LogError(ctx, "JWT key ring maintenance failed", err)
+			}
+		}
+	}
+}
+
+func (k *KeyRing) maintain(ctx context.Context) error {
+	retired, err := k.db.RetireJWTSigningKeys(ctx, time.Now().Add(-k.config.Retention))
+	if err != nil {
+		return err
+	}
+	if retired > 0 {
+		utils.LogInfo(ctx, "Retired JWT signing keys", utils.Fields{
+			"count": retired,
+		})
+	}
+
+	if err := k.reload(ctx); err != nil {
+		return err
+	}
+
+	active := k.activeKey()
+	if active == nil {
+		return k.rotate(ctx, "")
+	}
+	if k.config.RotationInterval > 0 && time.Since(active.createdAt) >= k.config.RotationInterval {
+		return k.rotate(ctx, active.kid)
+	}
+
+	return nil
+}
+
+func (k *KeyRing) rotate(ctx context.Context, currentKID string) error {
+	key, err := generateSigningKey(k.config.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	rotated, err := k.db.RotateJWTSigningKey(ctx, currentKID, key)
+	if err != nil {
+		return err
+	}
+	if rotated {
+		utils.LogInfo(ctx, "Rotated JWT signing key", utils.Fields{
+			"kid":          key.KID,
+			"algorithm":    key.Algorithm,
+			"previous_kid": currentKID,
+		})
+	}
+
+	// Pick up our key, or the one another instance installed first
+	return k.reload(ctx)
+}
+
+func (k *KeyRing) reload(ctx context.Context) error {
+	stored, err := k.db.ListJWTSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(stored))
+	var active *signingKey
+	for _, s := range stored {
+		key, err := parseSigningKey(&s)
+		if err != nil {
+			utils.LogError(ctx, "Skipping unreadable JWT signing key", err, utils.Fields{
+				"kid": s.KID,
+			})
+			continue
+		}
+		keys[key.kid] = key
+		if s.Status == models.JWTKeyStatusActive {
+			active = key
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.active = active
+	k.lastReload = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *KeyRing) activeKey() *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// verificationKey returns the non-retired key with the given kid. An unknown kid
+// triggers a reload, throttled, in case another instance rotated in the meantime.
+func (k *KeyRing) verificationKey(kid string) *signingKey {
+	k.mu.RLock()
+	key := k.keys[kid]
+	stale := time.Since(k.lastReload) > keyReloadCooldown
+	k.mu.RUnlock()
+
+	if key != nil || !stale || k.db == nil {
+		return key
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := k.reload(ctx); err != nil {
+		utils.LogError(ctx, "Failed to reload JWT signing keys", err)
+		return nil
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+// JWKS returns the public keys of the ring, active key first
+func (k *KeyRing) JWKS() models.JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+	if k.active != nil {
+		set.Keys = append(set.Keys, publicJWK(k.active))
+	}
+	for kid, key := range k.keys {
+		if k.active != nil && kid == k.active.kid {
+			continue
+		}
+		set.Keys = append(set.Keys, publicJWK(key))
+	}
+
+	return set
+}
+
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT signing algorithm: %s", algorithm)
+	}
+}
+
+// generateSigningKey creates a new key pair encoded for storage
+func generateSigningKey(algorithm string) (*models.JWTSigningKey, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unsupported JWT signing algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	method, _ := signingMethod(algorithm)
+	kid, err := keyThumbprint(publicJWK(&signingKey{method: method, public: private.Public()}))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.JWTSigningKey{
+		KID:        kid,
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+	}, nil
+}
+
+// parseSigningKey decodes a stored key
+func parseSigningKey(stored *models.JWTSigningKey) (*signingKey, error) {
+	method, err := signingMethod(stored.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(stored.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	var private crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if stored.Algorithm != AlgorithmRS256 {
+			return nil, fmt.Errorf("RSA key stored for %s", stored.Algorithm)
+		}
+		private = key
+	case ed25519.PrivateKey:
+		if stored.Algorithm != AlgorithmEdDSA {
+			return nil, fmt.Errorf("Ed25519 key stored for %s", stored.Algorithm)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+
+	return &signingKey{
+		kid:       stored.KID,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		createdAt: stored.CreatedAt,
+	}, nil
+}
+
+// publicJWK encodes the public half of a key as a JWK
+func publicJWK(key *signingKey) models.JSONWebKey {
+	jwk := models.JSONWebKey{
+		KeyID:     key.kid,
+		Use:       "sig",
+		Algorithm: key.method.Alg(),
+	}
+
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// keyThumbprint computes the RFC 7638 thumbprint of a JWK, used as its kid
+func keyThumbprint(jwk models.JSONWebKey) (string, error) {
+	// The required members in lexicographic order; encoding/json sorts map keys
+	members := map[string]string{"kty": jwk.KeyType}
+	switch jwk.KeyType {
+	case "RSA":
+		members["e"] = jwk.E
+		members["n"] = jwk.N
+	case "OKP":
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+	default:
+		return "", fmt.Errorf("unsupported key type: %s", jwk.KeyType)
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
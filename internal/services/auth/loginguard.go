@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// lockoutsResetAfter is how long a subject must stay without failures before
+// its lockouts stop counting towards a longer lockout
+const lockoutsResetAfter = 24 * time.Hour
+
+// Lockout event types passed to lockout hooks
+const (
+	LockoutEventLocked   = "locked"
+	LockoutEventUnlocked = "unlocked"
+)
+
+// LoginThrottleStore persists failed login counters; implemented by database.PostgresDB
+type LoginThrottleStore interface {
+	GetLoginThrottle(ctx context.Context, scope models.LoginThrottleScope, subject string) (*models.LoginThrottle, error)
+	RecordLoginFailure(ctx context.Context, scope models.LoginThrottleScope, subject string, now, windowStart, lockoutsResetBefore time.Time) (*models.LoginThrottle, error)
+	LockLoginSubject(ctx context.Context, scope models.LoginThrottleScope, subject string, now, until time.Time) (bool, error)
+	ClearLoginFailures(ctx context.Context, scope models.LoginThrottleScope, subject string) error
+	UnlockUser(ctx context.Context, userID uuid.UUID) (string, bool, error)
+	DeleteStaleLoginThrottles(ctx context.Context, before time.Time) (int64, error)
+}
+
+// LoginGuardConfig represents brute-force protection configuration
+type LoginGuardConfig struct {
+	AccountMaxFailures int           // failures before an account is locked
+	IPMaxFailures      int           // failures before a client IP is locked
+	Window             time.Duration // failures older than this are forgotten
+	LockoutDuration    time.Duration // first lockout, doubled for every further lockout
+	MaxLockoutDuration time.Duration
+	DelayBase          time.Duration // wait after the second failure, doubled for every further failure
+	MaxDelay           time.Duration
+}
+
+// LoginBlock tells why and for how long password logins are refused
+type LoginBlock struct {
+	Scope      models.LoginThrottleScope
+	Locked     bool // locked out, rather than asked to slow down
+	RetryAfter time.Duration
+}
+
+// LockoutEvent describes a lockout or unlock, for notification hooks
+type LockoutEvent struct {
+	Type        string
+	Scope       models.LoginThrottleScope
+	Subject     string     // normalized email or client IP
+	UserID      *uuid.UUID // set on admin unlock
+	Failures    int
+	LockedUntil *time.Time
+}
+
+// LockoutHook is notified about lockouts, for example to alert the user or the admins
+type LockoutHook func(ctx context.Context, event LockoutEvent)
+
+// LoginGuard tracks failed password logins per account and per client IP,
+// slows down repeated failures and temporarily locks out after too many
+type LoginGuard struct {
+	store  LoginThrottleStore
+	config LoginGuardConfig
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	hooks []LockoutHook
+}
+
+// NewLoginGuard creates a new login guard
+func NewLoginGuard(store LoginThrottleStore, config LoginGuardConfig) *LoginGuard {
+	return &LoginGuard{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// OnLockout registers a hook called after every lockout and unlock
+func (g *LoginGuard) OnLockout(hook LockoutHook) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.hooks = append(g.hooks, hook)
+}
+
+// Check returns a block if a password login for this email from this IP must be
+// refused right now, nil otherwise. It is called before the password is checked.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) (*LoginBlock, error) {
+	now := g.now()
+
+	var block *LoginBlock
+	for _, s := range g.subjects(email, ip) {
+		throttle, err := g.store.GetLoginThrottle(ctx, s.scope, s.subject)
+		if err != nil {
+			return nil, err
+		}
+
+		if b := g.blockFor(throttle, now); b != nil && (block == nil || b.RetryAfter > block.RetryAfter) {
+			block = b
+		}
+	}
+
+	return block, nil
+}
+
+// RecordFailure counts a failed password login and locks the account or IP
+// once it reaches its limit
+func (g *LoginGuard) RecordFailure(ctx context.Context, email, ip string) error {
+	now := g.now()
+
+	for _, s := range g.subjects(email, ip) {
+		throttle, err := g.store.RecordLoginFailure(ctx, s.scope, s.subject,
+			now, now.Add(-g.config.Window), now.Add(-lockoutsResetAfter))
+		if err != nil {
+			return err
+		}
+
+		if s.maxFailures <= 0 || throttle.Failures < s.maxFailures {
+			continue
+		}
+
+		until := now.Add(g.lockoutDuration(throttle.Lockouts))
+		locked, err := g.store.LockLoginSubject(ctx, s.scope, s.subject, now, until)
+		if err != nil {
+			return err
+		}
+		if locked {
+			g.notify(ctx, LockoutEvent{
+				Type:        LockoutEventLocked,
+				Scope:       s.scope,
+				Subject:     s.subject,
+				Failures:    throttle.Failures,
+				LockedUntil: &until,
+			})
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess forgets the failed logins of an account after a correct password.
+// The IP counter is kept, so one known password can't reset guessing on others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.store.ClearLoginFailures(ctx, models.LoginThrottleScopeAccount, normalizeLoginEmail(email))
+}
+
+// Unlock lifts the lockout of a user. It returns false if the user doesn't exist.
+func (g *LoginGuard) Unlock(ctx context.Context, userID uuid.UUID) (bool, error) {
+	subject, found, err := g.store.UnlockUser(ctx, userID)
+	if err != nil || !found {
+		return found, err
+	}
+
+	g.notify(ctx, LockoutEvent{
+		Type:    LockoutEventUnlocked,
+		Scope:   models.LoginThrottleScopeAccount,
+		Subject: subject,
+		UserID:  &userID,
+	})
+
+	return true, nil
+}
+
+// StartCleanup periodically removes counters that no longer matter.
+// It blocks until ctx is cancelled.
+func (g *LoginGuard) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := g.store.DeleteStaleLoginThrottles(ctx, g.now().Add(-lockoutsResetAfter))
+			if err != nil {
+				utils.LogError(ctx, "Failed to clean up login throttles", err)
+				continue
+			}
+			if deleted > 0 {
+				utils.LogInfo(ctx, "Cleaned up login throttles", utils.Fields{
+					"count": deleted,
+				})
+			}
+		}
+	}
+}
+
+type loginSubject struct {
+	scope       models.LoginThrottleScope
+	subject     string
+	maxFailures int
+}
+
+func (g *LoginGuard) subjects(email, ip string) []loginSubject {
+	subjects := []loginSubject{{
+		scope:       models.LoginThrottleScopeAccount,
+		subject:     normalizeLoginEmail(email),
+		maxFailures: g.config.AccountMaxFailures,
+	}}
+	if ip != "" {
+		subjects = append(subjects, loginSubject{
+			scope:       models.LoginThrottleScopeIP,
+			subject:     ip,
+			maxFailures: g.config.IPMaxFailures,
+		})
+	}
+	return subjects
+}
+
+// blockFor returns the block a counter imposes at the given time, if any
+func (g *LoginGuard) blockFor(throttle *models.LoginThrottle, now time.Time) *LoginBlock {
+	if throttle == nil {
+		return nil
+	}
+
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return &LoginBlock{
+			Scope:      throttle.Scope,
+			Locked:     true,
+			RetryAfter: throttle.LockedUntil.Sub(now),
+		}
+	}
+
+	if now.Sub(throttle.LastFailedAt) > g.config.Window {
+		return nil
+	}
+
+	if next := throttle.LastFailedAt.Add(g.delay(throttle.Failures)); now.Before(next) {
+		return &LoginBlock{
+			Scope:      throttle.Scope,
+			RetryAfter: next.Sub(now),
+		}
+	}
+
+	return nil
+}
+
+// delay is how long to wait after the given number of failures. The first
+// failure, usually a typo, costs nothing.
+func (g *LoginGuard) delay(failures int) time.Duration {
+	if failures < 2 || g.config.DelayBase <= 0 {
+		return 0
+	}
+	return doubled(g.config.DelayBase, failures-2, g.config.MaxDelay)
+}
+
+// lockoutDuration doubles the lockout for every earlier lockout
+func (g *LoginGuard) lockoutDuration(lockouts int) time.Duration {
+	return doubled(g.config.LockoutDuration, lockouts, g.config.MaxLockoutDuration)
+}
+
+func (g *LoginGuard) notify(ctx context.Context, event LockoutEvent) {
+	g.mu.RLock()
+	hooks := g.hooks
+	g.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(ctx, event)
+	}
+}
+
+// doubled returns base doubled times times, capped at max if max is set
+func doubled(base time.Duration, times int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < times; i++ {
+		if max > 0 && d >= max {
+			break
+		}
+		d *= 2
+	}
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// LogLockoutEvent is a lockout hook that writes lockouts to the log
+func LogLockoutEvent(ctx context.Context, event LockoutEvent) {
+	fields := utils.Fields{
+		"event":   event.Type,
+		"scope":   event.Scope,
+		"subject": event.Subject,
+	}
+	if event.LockedUntil != nil {
+		fields["locked_until"] = event.LockedUntil
+		fields["failures"] = event.Failures
+	}
+	if event.UserID != nil {
+		fields["user_id"] = event.UserID
+	}
+
+	utils.LogWarn(ctx, "Login lockout "+event.Type, fields)
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// memoryThrottleStore mirrors the login_throttles queries of database.PostgresDB
+type memoryThrottleStore struct {
+	throttles map[models.LoginThrottleScope]map[string]*models.LoginThrottle
+	users     map[uuid.UUID]string
+}
+
+func newMemoryThrottleStore() *memoryThrottleStore {
+	return &memoryThrottleStore{
+		throttles: map[models.LoginThrottleScope]map[string]*models.LoginThrottle{
+			models.LoginThrottleScopeAccount: {},
+			models.LoginThrottleScopeIP:      {},
+		},
+		users: map[uuid.UUID]string{},
+	}
+}
+
+func (s *memoryThrottleStore) GetLoginThrottle(ctx context.Context, scope models.LoginThrottleScope, subject string) (*models.LoginThrottle, error) {
+	if t, ok := s.throttles[scope][subject]; ok {
+		copied := *t
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryThrottleStore) RecordLoginFailure(ctx context.Context, scope models.LoginThrottleScope, subject string, now, windowStart, lockoutsResetBefore time.Time) (*models.LoginThrottle, error) {
+	t, ok := s.throttles[scope][subject]
+	if !ok {
+		t = &models.LoginThrottle{Scope: scope, Subject: subject, FirstFailedAt: now, LastFailedAt: now}
+		s.throttles[scope][subject] = t
+	} else {
+		if t.LastFailedAt.Before(windowStart) {
+			t.Failures = 0
+			t.FirstFailedAt = now
+		}
+		if t.LastFailedAt.Before(lockoutsResetBefore) {
+			t.Lockouts = 0
+		}
+		t.LastFailedAt = now
+	}
+	t.Failures++
+
+	copied := *t
+	return &copied, nil
+}
+
+func (s *memoryThrottleStore) LockLoginSubject(ctx context.Context, scope models.LoginThrottleScope, subject string, now, until time.Time) (bool, error) {
+	t, ok := s.throttles[scope][subject]
+	if !ok || (t.LockedUntil != nil && t.LockedUntil.After(now)) {
+		return false, nil
+	}
+	t.LockedUntil = &until
+	t.Lockouts++
+	t.Failures = 0
+	return true, nil
+}
+
+func (s *memoryThrottleStore) ClearLoginFailures(ctx context.Context, scope models.LoginThrottleScope, subject string) error {
+	delete(s.throttles[scope], subject)
+	return nil
+}
+
+func (s *memoryThrottleStore) UnlockUser(ctx context.Context, userID uuid.UUID) (string, bool, error) {
+	subject, ok := s.users[userID]
+	if !ok {
+		return "", false, nil
+	}
+	delete(s.throttles[models.LoginThrottleScopeAccount], subject)
+	return subject, true, nil
+}
+
+func (s *memoryThrottleStore) DeleteStaleLoginThrottles(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time          { return c.now }
+func (c *testClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLoginGuard() (*LoginGuard, *memoryThrottleStore, *testClock, *[]LockoutEvent) {
+	store := newMemoryThrottleStore()
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+	guard := NewLoginGuard(store, LoginGuardConfig{
+		AccountMaxFailures: 5,
+		IPMaxFailures:      20,
+		Window:             15 * time.Minute,
+		LockoutDuration:    15 * time.Minute,
+		MaxLockoutDuration: time.Hour,
+		DelayBase:          time.Second,
+		MaxDelay:           30 * time.Second,
+	})
+	guard.now = clock.Now
+
+	events := &[]LockoutEvent{}
+	guard.OnLockout(func(ctx context.Context, event LockoutEvent) {
+		*events = append(*events, event)
+	})
+
+	return guard, store, clock, events
+}
+
+// failAfterWait waits out any delay, then records a failure
+func failAfterWait(t *testing.T, guard *LoginGuard, clock *testClock, email, ip string) {
+	t.Helper()
+	ctx := context.Background()
+
+	block, err := guard.Check(ctx, email, ip)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if block != nil {
+		if block.Locked {
+			t.Fatalf("Unexpected lockout of %s", block.Scope)
+		}
+		clock.Advance(block.RetryAfter)
+	}
+
+	if err := guard.RecordFailure(ctx, email, ip); err != nil {
+		t.Fatalf("RecordFailure failed: %v", err)
+	}
+}
+
+func TestLoginGuardProgressiveDelay(t *testing.T) {
+	guard, _, clock, _ := newTestLoginGuard()
+	ctx := context.Background()
+
+	expected := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second}
+	for i, want := range expected {
+		if err := guard.RecordFailure(ctx, "user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("RecordFailure failed: %v", err)
+		}
+
+		block, err := guard.Check(ctx, "User@Example.com", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("Check failed: %v", err)
+		}
+
+		var got time.Duration
+		if block != nil {
+			if block.Locked {
+				t.Fatalf("Failure %d: unexpected lockout", i+1)
+			}
+			got = block.RetryAfter
+		}
+		if got != want {
+			t.Errorf("Failure %d: expected delay %v, got %v", i+1, want, got)
+		}
+		clock.Advance(got)
+	}
+
+	// Failures outside the window are forgotten
+	clock.Advance(16 * time.Minute)
+	if block, _ := guard.Check(ctx, "user@example.com", "10.0.0.1"); block != nil {
+		t.Errorf("Expected no delay after the window, got %+v", block)
+	}
+}
+
+func TestLoginGuardAccountLockout(t *testing.T) {
+	guard, _, clock, events := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		failAfterWait(t, guard, clock, "user@example.com", "10.0.0.1")
+	}
+
+	block, err := guard.Check(ctx, "user@example.com", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if block == nil || !block.Locked || block.Scope != models.LoginThrottleScopeAccount {
+		t.Fatalf("Expected account lockout from any IP, got %+v", block)
+	}
+	if block.RetryAfter != 15*time.Minute {
+		t.Errorf("Expected 15m lockout, got %v", block.RetryAfter)
+	}
+
+	if len(*events) != 1 || (*events)[0].Type != LockoutEventLocked || (*events)[0].Subject != "user@example.com" {
+		t.Errorf("Expected one lockout event, got %+v", *events)
+	}
+
+	// Other accounts are not affected
+	if block, _ := guard.Check(ctx, "other@example.com", "10.0.0.2"); block != nil {
+		t.Errorf("Expected other account to be allowed, got %+v", block)
+	}
+
+	// The lockout expires on its own, the next one lasts twice as long
+	clock.Advance(15 * time.Minute)
+	if block, _ := guard.Check(ctx, "user@example.com", "10.0.0.2"); block != nil {
+		t.Fatalf("Expected lockout to expire, got %+v", block)
+	}
+
+	for i := 0; i < 5; i++ {
+		failAfterWait(t, guard, clock, "user@example.com", "10.0.0.2")
+	}
+	block, _ = guard.Check(ctx, "user@example.com", "10.0.0.2")
+	if block == nil || !block.Locked || block.RetryAfter != 30*time.Minute {
+		t.Errorf("Expected 30m second lockout, got %+v", block)
+	}
+}
+
+func TestLoginGuardIPLockout(t *testing.T) {
+	guard, _, clock, events := newTestLoginGuard()
+	ctx := context.Background()
+
+	// Password spraying: one attempt per account, all from the same IP
+	for i := 0; i < 20; i++ {
+		failAfterWait(t, guard, clock, uuid.NewString()+"@example.com", "10.0.0.1")
+	}
+
+	block, err := guard.Check(ctx, "fresh@example.com", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if block == nil || !block.Locked || block.Scope != models.LoginThrottleScopeIP {
+		t.Fatalf("Expected IP lockout, got %+v", block)
+	}
+	if len(*events) != 1 || (*events)[0].Scope != models.LoginThrottleScopeIP {
+		t.Errorf("Expected one IP lockout event, got %+v", *events)
+	}
+
+	if block, _ := guard.Check(ctx, "fresh@example.com", "10.0.0.2"); block != nil {
+		t.Errorf("Expected other IP to be allowed, got %+v", block)
+	}
+}
+
+func TestLoginGuardSuccessAndUnlock(t *testing.T) {
+	guard, store, clock, events := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		failAfterWait(t, guard, clock, "user@example.com", "10.0.0.1")
+	}
+	if err := guard.RecordSuccess(ctx, "USER@example.com"); err != nil {
+		t.Fatalf("RecordSuccess failed: %v", err)
+	}
+	if throttle, _ := store.GetLoginThrottle(ctx, models.LoginThrottleScopeAccount, "user@example.com"); throttle != nil {
+		t.Errorf("Expected account failures to be cleared, got %+v", throttle)
+	}
+	if throttle, _ := store.GetLoginThrottle(ctx, models.LoginThrottleScopeIP, "10.0.0.1"); throttle == nil || throttle.Failures != 3 {
+		t.Errorf("Expected IP failures to be kept, got %+v", throttle)
+	}
+
+	userID := uuid.New()
+	store.users[userID] = "user@example.com"
+	for i := 0; i < 5; i++ {
+		failAfterWait(t, guard, clock, "user@example.com", "10.0.0.1")
+	}
+
+	found, err := guard.Unlock(ctx, userID)
+	if err != nil || !found {
+		t.Fatalf("Unlock failed: found=%v err=%v", found, err)
+	}
+	if block, _ := guard.Check(ctx, "user@example.com", "10.0.0.3"); block != nil {
+		t.Errorf("Expected unlocked account to be allowed, got %+v", block)
+	}
+
+	last := (*events)[len(*events)-1]
+	if last.Type != LockoutEventUnlocked || last.UserID == nil || *last.UserID != userID {
+		t.Errorf("Expected unlock event for user, got %+v", last)
+	}
+
+	if found, _ := guard.Unlock(ctx, uuid.New()); found {
+		t.Error("Expected unknown user not to be found")
+	}
+}
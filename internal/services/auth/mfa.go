@@ -0,0 +1,454 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+const (
+	maxMFAChallengeAttempts = 5
+	recoveryCodeCount       = 10
+	recoveryCodeLength      = 10
+	// recoveryCodeAlphabet leaves out characters that are easy to confuse
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var (
+	ErrMFAInvalidCode          = errors.New("invalid MFA code")
+	ErrMFAChallengeInvalid     = errors.New("MFA challenge is invalid or expired")
+	ErrMFAAlreadyEnabled       = errors.New("MFA is already enabled")
+	ErrMFANotEnabled           = errors.New("MFA is not enabled")
+	ErrMFAEnrollmentNotStarted = errors.New("MFA enrollment has not been started")
+	ErrMFARequired             = errors.New("MFA is required by a role of the user")
+)
+
+// MFAConfig represents two-factor authentication configuration
+type MFAConfig struct {
+	Issuer       string        // shown in authenticator apps
+	ChallengeTTL time.Duration // how long the second login step may take
+}
+
+// MFAService handles TOTP enrollment, recovery codes and the second login step
+type MFAService struct {
+	config MFAConfig
+	db     *database.PostgresDB
+}
+
+// NewMFAService creates a new MFA service
+func NewMFAService(config MFAConfig, db *database.PostgresDB) *MFAService {
+	return &MFAService{
+		config: config,
+		db:     db,
+	}
+}
+
+// BeginLogin is called after a correct password. It returns nil when the user
+// needs no second factor, otherwise a challenge to complete with VerifyLogin.
+func (m *MFAService) BeginLogin(ctx context.Context, userID uuid.UUID) (*models.MFAChallengeData, error) {
+	mfa, err := m.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := mfa != nil && mfa.Enabled
+
+	required, err := m.db.UserRequiresMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !enabled && !required {
+		return nil, nil
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &models.MFAChallenge{
+		TokenHash: hashOpaqueToken(token),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(m.config.ChallengeTTL),
+	}
+	if err := m.db.CreateMFAChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	methods := []string{models.MFAMethodTOTP}
+	if enabled {
+		methods = append(methods, models.MFAMethodRecoveryCode)
+	}
+
+	return &models.MFAChallengeData{
+		MFARequired:        true,
+		EnrollmentRequired: !enabled,
+		MFAToken:           token,
+		ExpiresIn:          int(m.config.ChallengeTTL.Seconds()),
+		Methods:            methods,
+	}, nil
+}
+
+// BeginChallengeEnrollment starts TOTP enrollment for a user whose role requires MFA
+// but who has not enrolled yet, authenticated by their login challenge
+func (m *MFAService) BeginChallengeEnrollment(ctx context.Context, token string) (*models.TOTPEnrollment, error) {
+	challenge, err := m.openChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := m.db.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrMFAChallengeInvalid
+	}
+
+	return m.BeginEnrollment(ctx, user)
+}
+
+// VerifyLogin completes a login challenge with a TOTP code or a recovery code.
+// For a user enrolling during login the TOTP code also confirms the enrollment,
+// and the new recovery codes are returned.
+func (m *MFAService) VerifyLogin(ctx context.Context, token, code, recoveryCode string) (*models.UserWithRoles, []string, error) {
+	challenge, err := m.openChallenge(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allowed, err := m.db.RecordMFAChallengeAttempt(ctx, challenge.ID, maxMFAChallengeAttempts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !allowed {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+
+	mfa, err := m.db.GetUserMFA(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mfa == nil {
+		return nil, nil, ErrMFAEnrollmentNotStarted
+	}
+
+	var recoveryCodes []string
+	switch {
+	case !mfa.Enabled:
+		recoveryCodes, err = m.confirm(ctx, mfa, code)
+	case code != "":
+		err = m.verifyCode(ctx, mfa, code)
+	case recoveryCode != "":
+		err = m.useRecoveryCode(ctx, mfa.UserID, recoveryCode)
+	default:
+		err = ErrMFAInvalidCode
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	consumed, err := m.db.ConsumeMFAChallenge(ctx, challenge.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+
+	user, err := m.db.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Status != models.UserStatusActive {
+		return nil, nil, ErrMFAChallengeInvalid
+	}
+
+	return user, recoveryCodes, nil
+}
+
+// GetStatus returns the two-factor setup of a user
+func (m *MFAService) GetStatus(ctx context.Context, userID uuid.UUID) (*models.MFAStatus, error) {
+	mfa, err := m.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := m.db.UserRequiresMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.MFAStatus{Required: required}
+	if mfa == nil {
+		return status, nil
+	}
+
+	status.Enabled = mfa.Enabled
+	status.EnrollmentPending = !mfa.Enabled
+	status.ConfirmedAt = mfa.ConfirmedAt
+	if mfa.Enabled {
+		codes, err := m.db.ListUnusedRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		status.RecoveryCodesRemaining = len(codes)
+	}
+
+	return status, nil
+}
+
+// BeginEnrollment generates a new TOTP secret. MFA is enabled only once
+// ConfirmEnrollment receives a valid code for it.
+func (m *MFAService) BeginEnrollment(ctx context.Context, user *models.UserWithRoles) (*models.TOTPEnrollment, error) {
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := m.db.SetPendingTOTPSecret(ctx, user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totpProvisioningURI(m.config.Issuer, user.Email, secret),
+		Issuer:          m.config.Issuer,
+		AccountName:     user.Email,
+		Algorithm:       "SHA1",
+		Digits:          totpDigits,
+		Period:          totpPeriod,
+	}, nil
+}
+
+// ConfirmEnrollment enables MFA with the first valid code and returns the recovery codes
+func (m *MFAService) ConfirmEnrollment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := m.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFAEnrollmentNotStarted
+	}
+	if mfa.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return m.confirm(ctx, mfa, code)
+}
+
+// Disable turns MFA off after checking a current code. Users whose role requires MFA can't disable it.
+func (m *MFAService) Disable(ctx context.Context, userID uuid.UUID, code string) error {
+	mfa, err := m.enabledMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	required, err := m.db.UserRequiresMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrMFARequired
+	}
+
+	if err := m.verifyCode(ctx, mfa, code); err != nil {
+		return err
+	}
+
+	_, err = m.db.DeleteUserMFA(ctx, userID)
+	return err
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a current code
+func (m *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	mfa, err := m.enabledMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.verifyCode(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.db.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (m *MFAService) enabledMFA(ctx context.Context, userID uuid.UUID) (*models.UserMFA, error) {
+	mfa, err := m.db.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return nil, ErrMFANotEnabled
+	}
+	return mfa, nil
+}
+
+// openChallenge looks up a challenge that is neither expired nor used
+func (m *MFAService) openChallenge(ctx context.Context, token string) (*models.MFAChallenge, error) {
+	challenge, err := m.db.GetMFAChallengeByTokenHash(ctx, hashOpaqueToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.ConsumedAt != nil || time.Now().After(challenge.ExpiresAt) ||
+		challenge.Attempts >= maxMFAChallengeAttempts {
+		return nil, ErrMFAChallengeInvalid
+	}
+	return challenge, nil
+}
+
+// confirm enables a pending enrollment with a valid code and returns new recovery codes
+func (m *MFAService) confirm(ctx context.Context, mfa *models.UserMFA, code string) ([]string, error) {
+	step, ok := verifyTOTP(mfa.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := m.db.EnableUserMFA(ctx, mfa.UserID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return codes, nil
+}
+
+// verifyCode checks a TOTP code and burns its time step so it can't be replayed
+func (m *MFAService) verifyCode(ctx context.Context, mfa *models.UserMFA, code string) error {
+	step, ok := verifyTOTP(mfa.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+
+	claimed, err := m.db.ClaimTOTPStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrMFAInvalidCode
+	}
+
+	return nil
+}
+
+func (m *MFAService) useRecoveryCode(ctx context.Context, userID uuid.UUID, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if len(normalized) != recoveryCodeLength {
+		return ErrMFAInvalidCode
+	}
+
+	codes, err := m.db.ListUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, stored := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(stored.CodeHash), []byte(normalized)) != nil {
+			continue
+		}
+
+		used, err := m.db.UseRecoveryCode(ctx, stored.ID)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrMFAInvalidCode
+		}
+		return nil
+	}
+
+	return ErrMFAInvalidCode
+}
+
+// newRecoveryCodes generates a set of recovery codes with their bcrypt hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to hash recovery code: %w", err)
+		}
+
+		codes[i] = code
+		hashes[i] = string(hash)
+	}
+
+	return codes, hashes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	random := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+
+	var b strings.Builder
+	for i, r := range random {
+		if i == recoveryCodeLength/2 {
+			b.WriteByte('-')
+		}
+		// 256 is not a multiple of the alphabet size; the bias is negligible for one-time codes
+		b.WriteByte(recoveryCodeAlphabet[int(r)%len(recoveryCodeAlphabet)])
+	}
+
+	return b.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// generateOpaqueToken returns a random token for MFA challenges and emailed links
+func generateOpaqueToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// hashOpaqueToken is how opaque tokens are stored, so a database leak doesn't expose them
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
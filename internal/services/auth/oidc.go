@@ -0,0 +1,487 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+const (
+	// oidcMetadataTTL is how long discovery documents and key sets are cached
+	oidcMetadataTTL = 24 * time.Hour
+	// oidcKeyRefreshInterval limits key set refetches triggered by unknown key IDs
+	oidcKeyRefreshInterval = time.Minute
+	// oidcClockSkew is the leeway allowed on ID token timestamps
+	oidcClockSkew = time.Minute
+	// oidcMaxResponseSize bounds discovery, key set and token responses
+	oidcMaxResponseSize = 1 << 20
+)
+
+// oidcSigningMethods are the ID token algorithms accepted from any provider. Symmetric
+// and "none" algorithms are never accepted.
+var oidcSigningMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// OIDCProviderConfig represents an OpenID Connect identity provider
+type OIDCProviderConfig struct {
+	Name         string // used in URLs and stored with linked identities
+	DisplayName  string
+	Issuer       string // discovery document is read from <Issuer>/.well-known/openid-configuration
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	// RoleClaim names the ID token claim holding group or role names; dots select nested claims
+	RoleClaim string
+	// RoleMapping maps values of RoleClaim to role names
+	RoleMapping map[string]string
+	DefaultRole string // role of users who sign up through the provider, "user" if empty
+	AllowSignup bool   // create accounts for unknown identities
+	TrustEmail  bool   // link unknown identities to the account with the same verified email
+}
+
+// OIDCClaims are the verified claims of an ID token
+type OIDCClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	GivenName     string
+	FamilyName    string
+	Raw           jwt.MapClaims
+}
+
+// oidcMetadata is the part of a discovery document the provider uses
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OIDCProvider talks to one OpenID Connect issuer. Endpoints come from the issuer's
+// discovery document and ID tokens are verified locally against its published keys.
+type OIDCProvider struct {
+	config     OIDCProviderConfig
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu            sync.Mutex
+	metadata      *oidcMetadata
+	metadataAt    time.Time
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// NewOIDCProvider creates a provider. Discovery happens on first use, so an issuer
+// that is down at startup doesn't keep the server from starting.
+func NewOIDCProvider(config OIDCProviderConfig, httpClient *http.Client) (*OIDCProvider, error) {
+	config.Name = strings.ToLower(strings.TrimSpace(config.Name))
+	if config.Name == "" {
+		return nil, errors.New("OIDC provider name is required")
+	}
+	if config.Issuer == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("OIDC provider %s: issuer and client ID are required", config.Name)
+	}
+	if _, err := url.ParseRequestURI(config.Issuer); err != nil {
+		return nil, fmt.Errorf("OIDC provider %s: invalid issuer: %w", config.Name, err)
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = config.Name
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &OIDCProvider{
+		config:     config,
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// Config returns the provider configuration
+func (p *OIDCProvider) Config() OIDCProviderConfig {
+	return p.config
+}
+
+// AuthCodeURL builds the authorization request URL. The PKCE challenge is derived from codeVerifier.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	query := url.Values{
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURI},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"response_type":         {"code"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURI},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tokens oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", fmt.Errorf("token request failed with status %d: %s %s", resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return "", errors.New("token response has no ID token")
+	}
+
+	return tokens.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience and lifetime of an ID token.
+// If nonce is not empty the token must carry the same nonce.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.key(ctx, metadata, kid)
+		},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+
+	// A token issued to several clients must name this one as its authorized party
+	if audiences, _ := claims.GetAudience(); len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.config.ClientID {
+			return nil, errors.New("invalid ID token: authorized party mismatch")
+		}
+	}
+
+	if nonce != "" {
+		if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+			return nil, errors.New("invalid ID token: nonce mismatch")
+		}
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+
+	result := &OIDCClaims{
+		Subject:       subject,
+		EmailVerified: claimBool(claims["email_verified"]),
+		Raw:           claims,
+	}
+	result.Email, _ = claims["email"].(string)
+	result.Name, _ = claims["name"].(string)
+	result.GivenName, _ = claims["given_name"].(string)
+	result.FamilyName, _ = claims["family_name"].(string)
+
+	return result, nil
+}
+
+// RoleNames maps the role claim of an ID token to role names, sorted and without duplicates
+func (p *OIDCProvider) RoleNames(claims *OIDCClaims) []string {
+	if p.config.RoleClaim == "" || len(p.config.RoleMapping) == 0 {
+		return nil
+	}
+
+	var value interface{} = map[string]interface{}(claims.Raw)
+	for _, part := range strings.Split(p.config.RoleClaim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var roles []string
+	for _, v := range values {
+		if role, ok := p.config.RoleMapping[v]; ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+
+	return roles
+}
+
+// discover returns the cached discovery document, fetching it when missing or stale
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && p.now().Sub(p.metadataAt) < oidcMetadataTTL {
+		return p.metadata, nil
+	}
+
+	var metadata oidcMetadata
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &metadata); err != nil {
+		if p.metadata != nil {
+			// Keep using the old document while the issuer is unreachable
+			return p.metadata, nil
+		}
+		return nil, fmt.Errorf("OIDC discovery for %s failed: %w", p.config.Name, err)
+	}
+
+	if strings.TrimSuffix(metadata.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("OIDC discovery for %s failed: issuer mismatch %q", p.config.Name, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery for %s failed: incomplete provider metadata", p.config.Name)
+	}
+
+	p.metadata = &metadata
+	p.metadataAt = p.now()
+	return p.metadata, nil
+}
+
+// key returns the verification key with the given ID. The key set is refetched when
+// it is stale or doesn't know the key, which is how provider key rotation is picked up.
+func (p *OIDCProvider) key(ctx context.Context, metadata *oidcMetadata, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok && p.now().Sub(p.keysFetchedAt) < oidcMetadataTTL {
+		return key, nil
+	}
+	if p.keys != nil && p.now().Sub(p.keysFetchedAt) < oidcKeyRefreshInterval {
+		if key, ok := p.lookupKey(kid); ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set models.JSONWebKeySet
+	if err := p.getJSON(ctx, metadata.JWKSURI, &set); err != nil {
+		if key, ok := p.lookupKey(kid); ok {
+			// Keep using the old keys while the issuer is unreachable
+			return key, nil
+		}
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			// Keys of unsupported types can sit next to usable ones
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey finds a key by ID; a token without key ID matches a key set with a single key
+func (p *OIDCProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned status %d", target, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseSize)).Decode(v)
+}
+
+// parseJWK converts an RSA, EC or Ed25519 public JWK to a key golang-jwt can verify with
+func parseJWK(jwk models.JSONWebKey) (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+		return key, nil
+
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+// claimBool reads a boolean claim some providers send as a string
+func claimBool(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
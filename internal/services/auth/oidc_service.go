@@ -0,0 +1,459 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// oidcStateTTL is how long a user has to finish signing in at the provider
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrOIDCProviderNotFound = errors.New("unknown identity provider")
+	ErrOIDCStateInvalid     = errors.New("OIDC state is invalid or expired")
+	ErrOIDCAuthFailed       = errors.New("identity provider authentication failed")
+	ErrOIDCEmailNotVerified = errors.New("identity provider did not verify the email address")
+	ErrOIDCSignupDisabled   = errors.New("no account is linked to this identity")
+	ErrOIDCAccountExists    = errors.New("an account with this email exists, sign in and link the identity instead")
+	ErrOIDCIdentityLinked   = errors.New("identity is already linked to another user")
+	ErrOIDCUserInactive     = errors.New("user account is not active")
+	ErrOIDCIdentityNotFound = errors.New("linked identity not found")
+	ErrOIDCLastLoginMethod  = errors.New("cannot unlink the only way to sign in")
+)
+
+// OIDCLoginResult is the outcome of an authorization callback. A callback that
+// finished linking an identity, or that needs a second factor, has no token pair.
+type OIDCLoginResult struct {
+	TokenPair      *models.TokenPair
+	User           *models.UserWithRoles
+	IsNewUser      bool
+	LinkedIdentity *models.UserIdentity
+	MFAChallenge   *models.MFAChallengeData
+}
+
+// OIDCService signs users in through the configured OpenID Connect providers and
+// manages the identities linked to their accounts
+type OIDCService struct {
+	providers      map[string]*OIDCProvider
+	order          []string
+	db             *database.PostgresDB
+	sessionService *SessionService
+	mfaService     *MFAService
+}
+
+// NewOIDCService creates the provider registry
+func NewOIDCService(providers []*OIDCProvider, db *database.PostgresDB, sessionService *SessionService, mfaService *MFAService) (*OIDCService, error) {
+	s := &OIDCService{
+		providers:      make(map[string]*OIDCProvider, len(providers)),
+		db:             db,
+		sessionService: sessionService,
+		mfaService:     mfaService,
+	}
+	for _, provider := range providers {
+		if _, exists := s.providers[provider.Name()]; exists {
+			return nil, fmt.Errorf("duplicate OIDC provider %s", provider.Name())
+		}
+		s.providers[provider.Name()] = provider
+		s.order = append(s.order, provider.Name())
+	}
+	return s, nil
+}
+
+// Providers lists the configured providers in configuration order
+func (s *OIDCService) Providers() []models.OIDCProviderInfo {
+	infos := make([]models.OIDCProviderInfo, 0, len(s.order))
+	for _, name := range s.order {
+		infos = append(infos, models.OIDCProviderInfo{
+			Name:        name,
+			DisplayName: s.providers[name].Config().DisplayName,
+		})
+	}
+	return infos
+}
+
+// Provider returns a configured provider by name
+func (s *OIDCService) Provider(name string) (*OIDCProvider, error) {
+	provider, ok := s.providers[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+	return provider, nil
+}
+
+// BeginLogin starts an authorization code flow and returns the provider URL and state.
+// With a linkUserID the callback links the identity to that user instead of signing in.
+func (s *OIDCService) BeginLogin(ctx context.Context, providerName string, linkUserID *uuid.UUID) (string, string, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	codeVerifier, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	oauthState := &models.OAuthState{
+		State:        state,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+		Provider:     provider.Name(),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}
+	if linkUserID != nil {
+		oauthState.UserID = utils.StringPtr(linkUserID.String())
+	}
+	if err := s.db.CreateOAuthState(ctx, oauthState); err != nil {
+		return "", "", fmt.Errorf("failed to store OAuth state: %w", err)
+	}
+
+	return authURL, state, nil
+}
+
+// HandleCallback finishes an authorization code flow. It signs the user in, creating
+// the account if the provider allows sign-ups, or links the identity if the flow was
+// started by BeginLogin with a user.
+func (s *OIDCService) HandleCallback(ctx context.Context, providerName, code, state string, deviceInfo *models.DeviceInfo) (*OIDCLoginResult, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthState, err := s.db.ConsumeOAuthState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if oauthState == nil || oauthState.Provider != provider.Name() {
+		return nil, ErrOIDCStateInvalid
+	}
+
+	idToken, err := provider.Exchange(ctx, code, oauthState.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCAuthFailed, err)
+	}
+	claims, err := provider.VerifyIDToken(ctx, idToken, oauthState.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCAuthFailed, err)
+	}
+
+	if oauthState.UserID != nil {
+		userID, err := uuid.Parse(*oauthState.UserID)
+		if err != nil {
+			return nil, ErrOIDCStateInvalid
+		}
+		identity, err := s.link(ctx, provider, userID, claims)
+		if err != nil {
+			return nil, err
+		}
+		user, err := s.db.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return &OIDCLoginResult{User: user, LinkedIdentity: identity}, nil
+	}
+
+	user, identity, isNewUser, err := s.resolveUser(ctx, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != models.UserStatusActive {
+		return nil, ErrOIDCUserInactive
+	}
+
+	if err := s.syncRoles(ctx, provider, identity, claims); err != nil {
+		return nil, err
+	}
+	if err := s.db.RecordIdentityLogin(ctx, identity.ID, emailPtr(claims)); err != nil {
+		utils.LogError(ctx, "Failed to record identity login", err, utils.Fields{
+			"identity_id": identity.ID,
+		})
+	}
+
+	// Reload so the session carries the roles the provider just granted
+	user, err = s.db.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider only replaces the password, so the second factor is asked for as well
+	challenge, err := s.mfaService.BeginLogin(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start MFA challenge: %w", err)
+	}
+	if challenge != nil {
+		utils.LogInfo(ctx, "User passed identity provider sign in, MFA required", utils.Fields{
+			"user_id":  user.ID,
+			"provider": provider.Name(),
+		})
+		return &OIDCLoginResult{User: user, IsNewUser: isNewUser, MFAChallenge: challenge}, nil
+	}
+
+	tokenPair, err := s.sessionService.CreateSession(ctx, user, deviceInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	utils.LogInfo(ctx, "User signed in with identity provider", utils.Fields{
+		"user_id":  user.ID,
+		"provider": provider.Name(),
+		"new_user": isNewUser,
+	})
+
+	return &OIDCLoginResult{TokenPair: tokenPair, User: user, IsNewUser: isNewUser}, nil
+}
+
+// LinkIDToken links the identity in an ID token the frontend got from the provider itself
+func (s *OIDCService) LinkIDToken(ctx context.Context, providerName string, userID uuid.UUID, idToken string) (*models.UserIdentity, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, idToken, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCAuthFailed, err)
+	}
+
+	return s.link(ctx, provider, userID, claims)
+}
+
+// ListIdentities lists the identities linked to a user
+func (s *OIDCService) ListIdentities(ctx context.Context, userID uuid.UUID) ([]models.UserIdentity, error) {
+	return s.db.ListUserIdentities(ctx, userID)
+}
+
+// Unlink removes a linked identity and the roles it granted. The last identity of a
+// user without a password can't be removed.
+func (s *OIDCService) Unlink(ctx context.Context, userID, identityID uuid.UUID) error {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrOIDCIdentityNotFound
+	}
+
+	identities, err := s.db.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.PasswordHash == nil && len(identities) <= 1 {
+		for _, identity := range identities {
+			if identity.ID == identityID {
+				return ErrOIDCLastLoginMethod
+			}
+		}
+	}
+
+	deleted, err := s.db.DeleteUserIdentity(ctx, userID, identityID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrOIDCIdentityNotFound
+	}
+
+	utils.LogInfo(ctx, "Identity unlinked", utils.Fields{
+		"user_id":     userID,
+		"identity_id": identityID,
+	})
+
+	return nil
+}
+
+// resolveUser finds the user an identity signs in as, linking or creating the account
+// on first sign-in as the provider configuration allows
+func (s *OIDCService) resolveUser(ctx context.Context, provider *OIDCProvider, claims *OIDCClaims) (*models.UserWithRoles, *models.UserIdentity, bool, error) {
+	identity, err := s.db.GetUserIdentity(ctx, provider.Name(), claims.Subject)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if identity != nil {
+		user, err := s.db.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if user == nil {
+			return nil, nil, false, ErrOIDCSignupDisabled
+		}
+		return user, identity, false, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, nil, false, ErrOIDCEmailNotVerified
+	}
+
+	config := provider.Config()
+	existing, err := s.db.LookupUserByEmail(ctx, claims.Email)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if existing != nil {
+		// Only providers trusted to own their email addresses may take over an account by email
+		if !config.TrustEmail {
+			return nil, nil, false, ErrOIDCAccountExists
+		}
+		identity = &models.UserIdentity{
+			UserID:   existing.ID,
+			Provider: provider.Name(),
+			Subject:  claims.Subject,
+			Email:    emailPtr(claims),
+		}
+		created, err := s.db.CreateUserIdentity(ctx, identity)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !created {
+			return nil, nil, false, ErrOIDCIdentityLinked
+		}
+		return existing, identity, false, nil
+	}
+
+	if !config.AllowSignup {
+		return nil, nil, false, ErrOIDCSignupDisabled
+	}
+
+	defaultRoleID, err := s.defaultRoleID(ctx, config.DefaultRole)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	user := &models.User{
+		Name:    claims.GivenName,
+		Surname: claims.FamilyName,
+		Email:   claims.Email,
+		Status:  models.UserStatusActive,
+	}
+	if user.Name == "" {
+		user.Name = claims.Name
+	}
+	if user.Name == "" {
+		user.Name = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	identity = &models.UserIdentity{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+		Email:    emailPtr(claims),
+	}
+	if err := s.db.CreateUserWithIdentity(ctx, user, []uuid.UUID{defaultRoleID}, identity); err != nil {
+		return nil, nil, false, err
+	}
+
+	created, err := s.db.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return created, identity, true, nil
+}
+
+// link attaches an identity to a user; linking an identity the user already has is a no-op
+func (s *OIDCService) link(ctx context.Context, provider *OIDCProvider, userID uuid.UUID, claims *OIDCClaims) (*models.UserIdentity, error) {
+	existing, err := s.db.GetUserIdentity(ctx, provider.Name(), claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.UserID != userID {
+			return nil, ErrOIDCIdentityLinked
+		}
+		return existing, nil
+	}
+
+	identity := &models.UserIdentity{
+		UserID:   userID,
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+		Email:    emailPtr(claims),
+	}
+	created, err := s.db.CreateUserIdentity(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrOIDCIdentityLinked
+	}
+
+	if err := s.syncRoles(ctx, provider, identity, claims); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(ctx, "Identity linked", utils.Fields{
+		"user_id":  userID,
+		"provider": provider.Name(),
+	})
+
+	return identity, nil
+}
+
+// syncRoles grants the roles the provider's role claim maps to and revokes the ones it no longer does
+func (s *OIDCService) syncRoles(ctx context.Context, provider *OIDCProvider, identity *models.UserIdentity, claims *OIDCClaims) error {
+	if provider.Config().RoleClaim == "" {
+		return nil
+	}
+
+	roleIDs := []uuid.UUID{}
+	for _, name := range provider.RoleNames(claims) {
+		role, err := s.db.GetRoleByName(ctx, name)
+		if err != nil || role == nil {
+			utils.LogWarn(ctx, "Mapped role does not exist", utils.Fields{
+				"provider": provider.Name(),
+				"role":     name,
+			})
+			continue
+		}
+		roleIDs = append(roleIDs, role.ID)
+	}
+
+	return s.db.SyncIdentityRoles(ctx, identity.ID, roleIDs)
+}
+
+// defaultRoleID returns the role of users who sign up through a provider
+func (s *OIDCService) defaultRoleID(ctx context.Context, name string) (uuid.UUID, error) {
+	if name == "" {
+		name = "user"
+	}
+	role, err := s.db.GetRoleByName(ctx, name)
+	if err == nil && role != nil {
+		return role.ID, nil
+	}
+
+	// Fall back to any role, like users created before roles were set up
+	roles, _, err := s.db.ListRoles(ctx, nil, nil, &models.PaginationOptions{Limit: 1})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	if len(roles) == 0 {
+		return uuid.Nil, errors.New("no roles available for new users")
+	}
+
+	return roles[0].ID, nil
+}
+
+func emailPtr(claims *OIDCClaims) *string {
+	if claims.Email == "" {
+		return nil
+	}
+	return &claims.Email
+}
@@ -0,0 +1,396 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// fakeIssuerKey is a signing key of the fake issuer
+type fakeIssuerKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+}
+
+// fakeIssuer is a local OpenID Connect provider serving discovery, a key set and a token endpoint
+type fakeIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu         sync.Mutex
+	keys       []fakeIssuerKey
+	codes      map[string]fakeAuthorization
+	jwksHits   int
+	issuerName string // issuer advertised in discovery, defaults to the server URL
+}
+
+// fakeAuthorization is what the fake issuer remembers about an issued code
+type fakeAuthorization struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	f := &fakeIssuer{t: t, codes: map[string]fakeAuthorization{}}
+	f.addRSAKey("rsa-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		issuer := f.issuerName
+		f.mu.Unlock()
+		if issuer == "" {
+			issuer = f.server.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.jwksHits++
+
+		set := models.JSONWebKeySet{Keys: []models.JSONWebKey{}}
+		for _, key := range f.keys {
+			set.Keys = append(set.Keys, fakeIssuerJWK(t, key))
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("client_secret") != "test-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		f.mu.Lock()
+		auth, ok := f.codes[r.PostForm.Get("code")]
+		delete(f.codes, r.PostForm.Get("code"))
+		f.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != auth.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     f.sign(auth.claims),
+		})
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeIssuer) addRSAKey(kid string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		f.t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	f.keys = append(f.keys, fakeIssuerKey{kid: kid, method: jwt.SigningMethodRS256, private: key})
+}
+
+// rotate replaces the signing keys with a single new key
+func (f *fakeIssuer) rotate(key fakeIssuerKey) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keys = []fakeIssuerKey{key}
+}
+
+// sign signs claims with the current key, filling in the standard claims that are missing
+func (f *fakeIssuer) sign(claims jwt.MapClaims) string {
+	f.mu.Lock()
+	key := f.keys[len(f.keys)-1]
+	f.mu.Unlock()
+
+	now := time.Now()
+	defaults := jwt.MapClaims{
+		"iss": f.server.URL,
+		"aud": "test-client",
+		"sub": "subject-1",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for name, value := range defaults {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		f.t.Fatalf("Failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+// authorize plays the user signing in at the provider and returns the code it redirects back with
+func (f *fakeIssuer) authorize(authURL string, claims jwt.MapClaims) string {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatalf("Invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != "test-client" {
+		f.t.Fatalf("Unexpected authorization request: %s", authURL)
+	}
+	claims["nonce"] = query.Get("nonce")
+
+	code := "code-" + query.Get("state")
+	f.mu.Lock()
+	f.codes[code] = fakeAuthorization{challenge: query.Get("code_challenge"), claims: claims}
+	f.mu.Unlock()
+	return code
+}
+
+func fakeIssuerJWK(t *testing.T, key fakeIssuerKey) models.JSONWebKey {
+	jwk := models.JSONWebKey{KeyID: key.kid, Use: "sig", Algorithm: key.method.Alg()}
+	switch pub := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		t.Fatalf("Unsupported key type %T", pub)
+	}
+	return jwk
+}
+
+func newTestOIDCProvider(t *testing.T, issuer *fakeIssuer, config OIDCProviderConfig) *OIDCProvider {
+	t.Helper()
+
+	config.Name = "test"
+	config.Issuer = issuer.server.URL
+	config.ClientID = "test-client"
+	config.ClientSecret = "test-secret"
+	config.RedirectURI = "http://app.example.com/callback"
+
+	provider, err := NewOIDCProvider(config, issuer.server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return provider
+}
+
+func TestOIDCProviderAuthorizationCodeFlow(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := newTestOIDCProvider(t, issuer, OIDCProviderConfig{})
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-0123456789-0123456789-0123456789")
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	if !strings.HasPrefix(authURL, issuer.server.URL+"/authorize?") {
+		t.Fatalf("Authorization endpoint not discovered: %s", authURL)
+	}
+
+	code := issuer.authorize(authURL, jwt.MapClaims{
+		"email":          "ada@example.com",
+		"email_verified": true,
+		"given_name":     "Ada",
+		"family_name":    "Lovelace",
+	})
+
+	// A stolen code is useless without the PKCE verifier
+	if _, err := provider.Exchange(ctx, code, "wrong-verifier"); err == nil {
+		t.Fatal("Expected exchange with wrong verifier to fail")
+	}
+
+	code = issuer.authorize(authURL, jwt.MapClaims{"email": "ada@example.com", "email_verified": "true"})
+	idToken, err := provider.Exchange(ctx, code, "verifier-0123456789-0123456789-0123456789")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, idToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "ada@example.com" || !claims.EmailVerified {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	if _, err := provider.VerifyIDToken(ctx, idToken, "other-nonce"); err == nil {
+		t.Error("Expected nonce mismatch to be rejected")
+	}
+}
+
+func TestOIDCProviderRejectsInvalidIDTokens(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := newTestOIDCProvider(t, issuer, OIDCProviderConfig{})
+	ctx := context.Background()
+
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err != nil {
+		t.Fatalf("Expected valid token to verify: %v", err)
+	}
+
+	forged, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	forgedToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": issuer.server.URL, "aud": "test-client", "sub": "subject-1",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	forgedToken.Header["kid"] = "rsa-1"
+	forgedSigned, _ := forgedToken.SignedString(forged)
+
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": issuer.server.URL, "aud": "test-client", "sub": "subject-1",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	hmacToken.Header["kid"] = "rsa-1"
+	hmacSigned, _ := hmacToken.SignedString([]byte("test-secret"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong audience", issuer.sign(jwt.MapClaims{"aud": "other-client"})},
+		{"wrong issuer", issuer.sign(jwt.MapClaims{"iss": "https://evil.example.com"})},
+		{"expired", issuer.sign(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})},
+		{"missing expiry", issuer.sign(jwt.MapClaims{"exp": nil})},
+		{"missing subject", issuer.sign(jwt.MapClaims{"sub": ""})},
+		{"other authorized party", issuer.sign(jwt.MapClaims{"aud": []string{"test-client", "other"}, "azp": "other"})},
+		{"forged signature", forgedSigned},
+		{"symmetric algorithm", hmacSigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provider.VerifyIDToken(ctx, tt.token, ""); err == nil {
+				t.Error("Expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestOIDCProviderPicksUpRotatedKeys(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := newTestOIDCProvider(t, issuer, OIDCProviderConfig{})
+	ctx := context.Background()
+
+	clock := &testClock{now: time.Now()}
+	provider.now = clock.Now
+
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err != nil {
+		t.Fatalf("Expected token to verify: %v", err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate Ed25519 key: %v", err)
+	}
+	issuer.rotate(fakeIssuerKey{kid: "ed-1", method: jwt.SigningMethodEdDSA, private: edKey})
+
+	// Unknown key IDs trigger a refetch, but not more than once a minute
+	clock.Advance(2 * time.Minute)
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err != nil {
+		t.Fatalf("Expected token signed with the rotated key to verify: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate EC key: %v", err)
+	}
+	issuer.rotate(fakeIssuerKey{kid: "ec-1", method: jwt.SigningMethodES256, private: ecKey})
+
+	hits := issuer.jwksHits
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err == nil {
+		t.Error("Expected refetch to be rate limited")
+	}
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err == nil {
+		t.Error("Expected refetch to be rate limited")
+	}
+	if issuer.jwksHits != hits {
+		t.Errorf("Expected no key set requests within a minute, got %d", issuer.jwksHits-hits)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if _, err := provider.VerifyIDToken(ctx, issuer.sign(jwt.MapClaims{}), ""); err != nil {
+		t.Fatalf("Expected token signed with the EC key to verify: %v", err)
+	}
+}
+
+func TestOIDCProviderDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.issuerName = "https://evil.example.com"
+	provider := newTestOIDCProvider(t, issuer, OIDCProviderConfig{})
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier"); err == nil {
+		t.Error("Expected discovery with a different issuer to fail")
+	}
+}
+
+func TestOIDCProviderRoleNames(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := newTestOIDCProvider(t, issuer, OIDCProviderConfig{
+		RoleClaim: "realm_access.roles",
+		RoleMapping: map[string]string{
+			"planner-admins":  "admin",
+			"planner-editors": "editor",
+			"planner-owners":  "admin",
+		},
+	})
+
+	claims, err := provider.VerifyIDToken(context.Background(), issuer.sign(jwt.MapClaims{
+		"realm_access": map[string]interface{}{
+			"roles": []string{"planner-owners", "offline_access", "planner-editors", "planner-admins"},
+		},
+	}), "")
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+
+	if got := provider.RoleNames(claims); !reflect.DeepEqual(got, []string{"admin", "editor"}) {
+		t.Errorf("Expected [admin editor], got %v", got)
+	}
+
+	if got := provider.RoleNames(&OIDCClaims{Raw: jwt.MapClaims{"realm_access": "planner-admins"}}); got != nil {
+		t.Errorf("Expected no roles for a claim of the wrong shape, got %v", got)
+	}
+}
@@ -3,20 +3,31 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/denisAlshanov/stPlaner/internal/database"
 	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
 )
 
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated is presented again
+var ErrRefreshTokenReused = errors.New("refresh token was already used")
+
 // SessionService handles session management operations
 type SessionService struct {
 	db         *database.PostgresDB
 	jwtService *JWTService
+	geo        GeoLocator
+
+	mu         sync.RWMutex
+	alertHooks []LoginAlertHook
 }
 
 // NewSessionService creates a new session service
@@ -27,27 +38,39 @@ func NewSessionService(db *database.PostgresDB, jwtService *JWTService) *Session
 	}
 }
 
+// SetGeoLocator enables resolving the country of new sessions from the client IP
+func (s *SessionService) SetGeoLocator(geo GeoLocator) {
+	s.geo = geo
+}
+
+// OnLoginAlert registers a hook called after every login from a new device or country
+func (s *SessionService) OnLoginAlert(hook LoginAlertHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alertHooks = append(s.alertHooks, hook)
+}
+
 // CreateSession creates a new user session
 func (s *SessionService) CreateSession(ctx context.Context, user *models.UserWithRoles, deviceInfo *models.DeviceInfo) (*models.TokenPair, error) {
 	// Generate session ID
 	sessionID := uuid.New()
 
 	// Generate JWT token pair first
-	tokenPair, err := s.jwtService.GenerateTokenPair(user, sessionID)
+	tokenPair, accessClaims, err := s.jwtService.generateTokenPair(user, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate tokens: %w", err)
 	}
 
-	// Create session record with refresh token
+	// Create session record with the hash of the refresh token
 	session := &models.Session{
-		ID:           sessionID,
-		UserID:       user.ID,
-		RefreshToken: tokenPair.RefreshToken,
-		IsActive:     true,
-		ExpiresAt:    time.Now().Add(s.jwtService.config.RefreshTokenDuration),
-		CreatedAt:    time.Now(),
-		LastActivity: time.Now(),
-		Metadata:     make(map[string]interface{}),
+		ID:               sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: hashOpaqueToken(tokenPair.RefreshToken),
+		IsActive:         true,
+		ExpiresAt:        time.Now().Add(s.jwtService.config.RefreshTokenDuration),
+		CreatedAt:        time.Now(),
+		LastActivity:     time.Now(),
+		Metadata:         make(map[string]interface{}),
 	}
 
 	// Set device info if provided
@@ -65,16 +88,105 @@ func (s *SessionService) CreateSession(ctx context.Context, user *models.UserWit
 			session.UserAgent = &deviceInfo.UserAgent
 		}
 	}
+	s.describeDevice(session)
 
 	// Save session to database
 	if err := s.db.CreateSession(ctx, session); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
+	if err := s.db.RecordSessionAccessToken(ctx, sessionID, user.ID, accessClaims.ID, accessClaims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	s.checkLoginOrigin(ctx, session)
+
 	return tokenPair, nil
 }
 
-// RefreshSession refreshes an existing session and generates new tokens
+// describeDevice replaces the device the client claims to be with what its user agent
+// says, and resolves the location of the client IP when GeoIP is configured
+func (s *SessionService) describeDevice(session *models.Session) {
+	if session.UserAgent != nil {
+		if name, deviceType := ParseUserAgent(*session.UserAgent); name != "" {
+			session.DeviceName = &name
+			session.DeviceType = &deviceType
+		}
+	}
+
+	if s.geo != nil && session.IPAddress != nil {
+		if country := s.geo.Country(*session.IPAddress); country != "" {
+			session.Location = &country
+		}
+	}
+}
+
+// checkLoginOrigin raises a login alert when a session comes from a device or country
+// the user never logged in from before. Failures are logged, they don't fail the login.
+func (s *SessionService) checkLoginOrigin(ctx context.Context, session *models.Session) {
+	country := ""
+	if session.Location != nil {
+		country = *session.Location
+	}
+
+	newDevice, newCountry, err := s.db.RecordLoginOrigin(ctx, session.UserID, deviceKey(session), country)
+	if err != nil {
+		utils.LogError(ctx, "Failed to record login origin", err, utils.Fields{
+			"user_id":    session.UserID,
+			"session_id": session.ID,
+		})
+		return
+	}
+	if !newDevice && !newCountry {
+		return
+	}
+
+	alert := &models.LoginAlert{
+		UserID:     session.UserID,
+		SessionID:  &session.ID,
+		Reasons:    []string{},
+		DeviceName: session.DeviceName,
+		DeviceType: session.DeviceType,
+		IPAddress:  session.IPAddress,
+		Location:   session.Location,
+	}
+	if newDevice {
+		alert.Reasons = append(alert.Reasons, models.LoginAlertNewDevice)
+	}
+	if newCountry {
+		alert.Reasons = append(alert.Reasons, models.LoginAlertNewCountry)
+	}
+
+	if err := s.db.CreateLoginAlert(ctx, alert); err != nil {
+		utils.LogError(ctx, "Failed to create login alert", err, utils.Fields{
+			"user_id":    session.UserID,
+			"session_id": session.ID,
+		})
+		return
+	}
+
+	s.mu.RLock()
+	hooks := s.alertHooks
+	s.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(ctx, alert)
+	}
+}
+
+// GetLoginAlerts returns the login alerts the user hasn't acknowledged yet
+func (s *SessionService) GetLoginAlerts(ctx context.Context, userID uuid.UUID) ([]models.LoginAlert, error) {
+	return s.db.GetPendingLoginAlerts(ctx, userID)
+}
+
+// AcknowledgeLoginAlert dismisses a login alert of the user, returning false if there is no such pending alert
+func (s *SessionService) AcknowledgeLoginAlert(ctx context.Context, userID, alertID uuid.UUID) (bool, error) {
+	return s.db.AcknowledgeLoginAlert(ctx, userID, alertID)
+}
+
+// RefreshSession rotates the refresh token of a session and generates new tokens. Every refresh
+// token can be used once: presenting one that was already rotated revokes the session and the
+// access tokens issued to it, since either the caller or whoever else holds the token stole it.
 func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
 	// Validate refresh token
 	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
@@ -106,8 +218,9 @@ func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string
 		return nil, fmt.Errorf("session has expired")
 	}
 
-	if session.RefreshToken != refreshToken {
-		return nil, fmt.Errorf("refresh token mismatch")
+	// The token is signed for this session, so if it isn't the current one it was rotated before
+	if !s.MatchesRefreshToken(session, refreshToken) {
+		return nil, s.revokeReusedSession(ctx, session)
 	}
 
 	// Get user with roles
@@ -125,27 +238,46 @@ func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string
 		return nil, fmt.Errorf("user not found")
 	}
 
-	// Blacklist old tokens
-	if err := s.BlacklistToken(ctx, claims.ID, userID, "token_refresh"); err != nil {
-		return nil, fmt.Errorf("failed to blacklist old token: %w", err)
-	}
-
 	// Generate new token pair
-	tokenPair, err := s.jwtService.GenerateTokenPair(user, sessionID)
+	tokenPair, accessClaims, err := s.jwtService.generateTokenPair(user, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate new tokens: %w", err)
 	}
 
-	// Update session with new refresh token and activity
-	session.RefreshToken = tokenPair.RefreshToken
-	session.LastActivity = time.Now()
-	if err := s.db.UpdateSession(ctx, session); err != nil {
+	// Rotate the refresh token; losing the race to a parallel refresh with the same token is reuse too
+	rotated, err := s.db.RotateSessionRefreshToken(ctx, sessionID, hashOpaqueToken(refreshToken),
+		hashOpaqueToken(tokenPair.RefreshToken), accessClaims.ID, accessClaims.ExpiresAt.Time)
+	if err != nil {
 		return nil, fmt.Errorf("failed to update session: %w", err)
 	}
+	if !rotated {
+		return nil, s.revokeReusedSession(ctx, session)
+	}
 
 	return tokenPair, nil
 }
 
+// MatchesRefreshToken tells whether a refresh token is the current one of a session
+func (s *SessionService) MatchesRefreshToken(session *models.Session, refreshToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(session.RefreshTokenHash), []byte(hashOpaqueToken(refreshToken))) == 1
+}
+
+// revokeReusedSession revokes a session whose refresh token was replayed and blacklists its access tokens
+func (s *SessionService) revokeReusedSession(ctx context.Context, session *models.Session) error {
+	blacklisted, err := s.db.RevokeSessionTokens(ctx, session.ID, "refresh_token_reuse")
+	if err != nil {
+		return fmt.Errorf("failed to revoke session after refresh token reuse: %w", err)
+	}
+
+	utils.LogWarn(ctx, "Refresh token reuse detected, session revoked", utils.Fields{
+		"user_id":            session.UserID,
+		"session_id":         session.ID,
+		"blacklisted_tokens": blacklisted,
+	})
+
+	return ErrRefreshTokenReused
+}
+
 // RevokeSession revokes a user session
 func (s *SessionService) RevokeSession(ctx context.Context, sessionID uuid.UUID) error {
 	return s.db.DeleteSession(ctx, sessionID)
@@ -170,6 +302,7 @@ func (s *SessionService) GetUserSessions(ctx context.Context, userID uuid.UUID)
 			DeviceName:   session.DeviceName,
 			DeviceType:   session.DeviceType,
 			IPAddress:    session.IPAddress,
+			Location:     session.Location,
 			CreatedAt:    session.CreatedAt,
 			LastActivity: session.LastActivity,
 			IsCurrent:    false, // This would be determined by comparing with current session
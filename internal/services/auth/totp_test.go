@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed of the RFC 6238 test vectors
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestVerifyTOTP(t *testing.T) {
+	testCases := []struct {
+		name        string
+		code        string
+		at          time.Time
+		expectValid bool
+	}{
+		{
+			name:        "RFC 6238 vector at 59s",
+			code:        "287082",
+			at:          time.Unix(59, 0),
+			expectValid: true,
+		},
+		{
+			name:        "RFC 6238 vector at 1111111109s",
+			code:        "081804",
+			at:          time.Unix(1111111109, 0),
+			expectValid: true,
+		},
+		{
+			name:        "Previous step within skew",
+			code:        "081804",
+			at:          time.Unix(1111111109+totpPeriod, 0),
+			expectValid: true,
+		},
+		{
+			name:        "Two steps late",
+			code:        "081804",
+			at:          time.Unix(1111111109+2*totpPeriod, 0),
+			expectValid: false,
+		},
+		{
+			name:        "Wrong code",
+			code:        "123456",
+			at:          time.Unix(59, 0),
+			expectValid: false,
+		},
+		{
+			name:        "Wrong length",
+			code:        "94287082",
+			at:          time.Unix(59, 0),
+			expectValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, valid := verifyTOTP(rfc6238Secret, tc.code, tc.at)
+			if valid != tc.expectValid {
+				t.Errorf("Expected valid=%v for code %s", tc.expectValid, tc.code)
+			}
+		})
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri, err := url.Parse(totpProvisioningURI("St. Planer", "user@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatalf("Failed to parse URI: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	if uri.Path != "/St. Planer:user@example.com" {
+		t.Errorf("Unexpected label: %s", uri.Path)
+	}
+	if uri.Query().Get("secret") != rfc6238Secret || uri.Query().Get("issuer") != "St. Planer" {
+		t.Errorf("Unexpected query: %s", uri.RawQuery)
+	}
+}
+
+func TestRecoveryCodeFormat(t *testing.T) {
+	code, err := generateRecoveryCode()
+	if err != nil {
+		t.Fatalf("Failed to generate recovery code: %v", err)
+	}
+
+	if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' {
+		t.Errorf("Unexpected recovery code format: %s", code)
+	}
+	if normalized := normalizeRecoveryCode(" " + code[:5] + " " + code[6:] + " "); len(normalized) != recoveryCodeLength {
+		t.Errorf("Unexpected normalized code: %q", normalized)
+	}
+}
@@ -0,0 +1,192 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/storage"
+	"github.com/denisAlshanov/stPlaner/internal/services/telegram"
+)
+
+// pathPrefix is where avatars live, both as storage keys and as public URL paths
+const pathPrefix = "avatars/guests/"
+
+var (
+	ErrTooLarge            = errors.New("avatar file is too large")
+	ErrNotFound            = errors.New("avatar not found")
+	ErrTelegramUnavailable = errors.New("the configured Telegram client can't fetch profile photos")
+	ErrTelegramFetch       = errors.New("failed to fetch Telegram profile photo")
+)
+
+var versionRegex = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// Service processes guest avatars and keeps them in storage. Stored images are never
+// overwritten: a version derived from the upload is part of every key, so avatar URLs
+// are stable and can be cached forever.
+type Service struct {
+	storage storage.StorageInterface
+	photos  telegram.ProfilePhotoFetcher // nil when the Telegram client can't fetch profile photos
+	config  *config.AvatarConfig
+}
+
+// NewService creates an avatar service. Avatars are pulled from Telegram when the client
+// supports fetching profile photos.
+func NewService(storage storage.StorageInterface, telegramClient telegram.TelegramClient, cfg *config.AvatarConfig) *Service {
+	photos, _ := telegramClient.(telegram.ProfilePhotoFetcher)
+	return &Service{storage: storage, photos: photos, config: cfg}
+}
+
+// MaxUploadSize is the largest accepted image in bytes
+func (s *Service) MaxUploadSize() int64 {
+	return s.config.MaxUploadSize
+}
+
+// Upload validates and processes an image and stores it in every size for the guest
+func (s *Service) Upload(ctx context.Context, guestID uuid.UUID, r io.Reader) (*models.GuestAvatar, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.config.MaxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %w", err)
+	}
+	if int64(len(data)) > s.config.MaxUploadSize {
+		return nil, ErrTooLarge
+	}
+
+	images, err := Process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(data)
+	version := hex.EncodeToString(sum[:8])
+
+	for i, size := range Sizes {
+		key := objectKey(guestID, version, size)
+		if err := s.storage.Upload(ctx, key, bytes.NewReader(images[size]), "image/jpeg"); err != nil {
+			for _, stored := range Sizes[:i] {
+				s.storage.Delete(ctx, objectKey(guestID, version, stored))
+			}
+			return nil, fmt.Errorf("failed to store avatar: %w", err)
+		}
+	}
+
+	return s.avatar(guestID, version), nil
+}
+
+// FromTelegram stores the public profile photo of a Telegram username as the guest's avatar
+func (s *Service) FromTelegram(ctx context.Context, guestID uuid.UUID, username string) (*models.GuestAvatar, error) {
+	if s.photos == nil {
+		return nil, ErrTelegramUnavailable
+	}
+
+	photo, err := s.photos.GetProfilePhoto(ctx, username)
+	if errors.Is(err, telegram.ErrInvalidUsername) || errors.Is(err, telegram.ErrNoProfilePhoto) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTelegramFetch, err)
+	}
+	defer photo.Close()
+
+	return s.Upload(ctx, guestID, photo)
+}
+
+// Open returns a stored avatar image by the file name used in its URL, such as "256.jpg"
+func (s *Service) Open(ctx context.Context, guestID uuid.UUID, version, file string) (io.ReadCloser, error) {
+	size, ok := parseFile(file)
+	if !ok || !versionRegex.MatchString(version) {
+		return nil, ErrNotFound
+	}
+
+	key := objectKey(guestID, version, size)
+	exists, err := s.storage.Exists(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check avatar: %w", err)
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return s.storage.Download(ctx, key)
+}
+
+// Remove deletes the stored images behind a guest's avatar URL. URLs this service didn't
+// create, such as external links set through the guest API, and avatars of other guests,
+// which the URL field can point at as well, are left alone.
+func (s *Service) Remove(ctx context.Context, ownerID uuid.UUID, avatarURL string) error {
+	guestID, version, ok := ParseURL(avatarURL)
+	if !ok || guestID != ownerID {
+		return nil
+	}
+
+	var errs []error
+	for _, size := range Sizes {
+		if err := s.storage.Delete(ctx, objectKey(guestID, version, size)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseURL returns the guest and version of an avatar URL created by the service
+func ParseURL(avatarURL string) (guestID uuid.UUID, version string, ok bool) {
+	index := strings.LastIndex(avatarURL, "/"+pathPrefix)
+	if index < 0 {
+		return uuid.Nil, "", false
+	}
+
+	parts := strings.Split(avatarURL[index+len(pathPrefix)+1:], "/")
+	if len(parts) != 3 || !versionRegex.MatchString(parts[1]) {
+		return uuid.Nil, "", false
+	}
+	if _, ok := parseFile(parts[2]); !ok {
+		return uuid.Nil, "", false
+	}
+	guestID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return uuid.Nil, "", false
+	}
+
+	return guestID, parts[1], true
+}
+
+func (s *Service) avatar(guestID uuid.UUID, version string) *models.GuestAvatar {
+	avatar := &models.GuestAvatar{
+		GuestID: guestID,
+		Version: version,
+		Sizes:   make(map[string]string, len(Sizes)),
+	}
+	for _, size := range Sizes {
+		avatar.Sizes[strconv.Itoa(size)] = s.config.PublicBaseURL + "/" + objectKey(guestID, version, size)
+	}
+	avatar.URL = avatar.Sizes[strconv.Itoa(Sizes[0])]
+	return avatar
+}
+
+func objectKey(guestID uuid.UUID, version string, size int) string {
+	return fmt.Sprintf("%s%s/%s/%d.jpg", pathPrefix, guestID, version, size)
+}
+
+func parseFile(file string) (int, bool) {
+	size, err := strconv.Atoi(strings.TrimSuffix(file, ".jpg"))
+	if err != nil || !strings.HasSuffix(file, ".jpg") {
+		return 0, false
+	}
+	for _, known := range Sizes {
+		if size == known {
+			return size, true
+		}
+	}
+	return 0, false
+}
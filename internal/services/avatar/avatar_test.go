@@ -0,0 +1,172 @@
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/services/telegram"
+)
+
+type fakeStorage struct {
+	objects map[string][]byte
+}
+
+func (s *fakeStorage) BucketName() string { return "test" }
+
+func (s *fakeStorage) Upload(ctx context.Context, key string, data io.Reader, contentType string) error {
+	b, err := io.ReadAll(data)
+	s.objects[key] = b
+	return err
+}
+
+func (s *fakeStorage) UploadWithMetadata(ctx context.Context, key string, data io.Reader, contentType string, metadata map[string]string) error {
+	return s.Upload(ctx, key, data, contentType)
+}
+
+func (s *fakeStorage) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.objects[key])), nil
+}
+
+func (s *fakeStorage) GetMetadata(ctx context.Context, key string) (map[string]string, error) {
+	return nil, nil
+}
+
+func (s *fakeStorage) Delete(ctx context.Context, key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *fakeStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, ok := s.objects[key]
+	return ok, nil
+}
+
+func (s *fakeStorage) GeneratePresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return "", nil
+}
+
+// fakeTelegram is a Telegram client that can fetch profile photos
+type fakeTelegram struct {
+	telegram.TelegramClient
+	photos map[string][]byte
+}
+
+func (f *fakeTelegram) GetProfilePhoto(ctx context.Context, username string) (io.ReadCloser, error) {
+	photo, ok := f.photos[username]
+	if !ok {
+		return nil, telegram.ErrNoProfilePhoto
+	}
+	return io.NopCloser(bytes.NewReader(photo)), nil
+}
+
+func newTestService(t *testing.T, telegramClient telegram.TelegramClient) (*Service, *fakeStorage) {
+	t.Helper()
+	store := &fakeStorage{objects: make(map[string][]byte)}
+	return NewService(store, telegramClient, &config.AvatarConfig{
+		PublicBaseURL: "https://media.example.com",
+		MaxUploadSize: 1 << 20,
+	}), store
+}
+
+func TestUploadAndRemove(t *testing.T) {
+	service, store := newTestService(t, nil)
+	guestID := uuid.New()
+	data := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 128, 128)))
+
+	avatar, err := service.Upload(context.Background(), guestID, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if len(store.objects) != len(Sizes) {
+		t.Errorf("stored %d objects, want %d", len(store.objects), len(Sizes))
+	}
+	wantURL := "https://media.example.com/avatars/guests/" + guestID.String() + "/" + avatar.Version + "/512.jpg"
+	if avatar.URL != wantURL || avatar.Sizes["512"] != wantURL {
+		t.Errorf("URL = %q, want %q", avatar.URL, wantURL)
+	}
+
+	// The same image gives the same version, so URLs are stable
+	again, err := service.Upload(context.Background(), guestID, bytes.NewReader(data))
+	if err != nil || again.URL != avatar.URL {
+		t.Errorf("second upload = %v, %v, want the same URL", again, err)
+	}
+
+	reader, err := service.Open(context.Background(), guestID, avatar.Version, "64.jpg")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	reader.Close()
+	if _, err := service.Open(context.Background(), guestID, avatar.Version, "100.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() of an unknown size error = %v, want ErrNotFound", err)
+	}
+
+	// Another guest's avatar URL doesn't remove this guest's images
+	if err := service.Remove(context.Background(), uuid.New(), avatar.URL); err != nil {
+		t.Fatalf("Remove() of a foreign avatar error = %v", err)
+	}
+	if len(store.objects) != len(Sizes) {
+		t.Errorf("%d objects left after removing a foreign avatar, want %d", len(store.objects), len(Sizes))
+	}
+
+	if err := service.Remove(context.Background(), guestID, avatar.URL); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if len(store.objects) != 0 {
+		t.Errorf("%d objects left after Remove()", len(store.objects))
+	}
+}
+
+func TestUploadTooLarge(t *testing.T) {
+	service, store := newTestService(t, nil)
+	if _, err := service.Upload(context.Background(), uuid.New(), strings.NewReader(strings.Repeat("x", 1<<20+1))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Upload() error = %v, want ErrTooLarge", err)
+	}
+	if len(store.objects) != 0 {
+		t.Error("rejected upload was stored")
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	guestID := uuid.New()
+	if id, version, ok := ParseURL("/avatars/guests/" + guestID.String() + "/0123456789abcdef/256.jpg"); !ok || id != guestID || version != "0123456789abcdef" {
+		t.Errorf("ParseURL() = %v, %q, %v", id, version, ok)
+	}
+	for _, url := range []string{
+		"https://cdn.example.com/photo.jpg",
+		"/avatars/guests/" + guestID.String() + "/nothex/256.jpg",
+		"/avatars/guests/" + guestID.String() + "/0123456789abcdef/100.jpg",
+	} {
+		if _, _, ok := ParseURL(url); ok {
+			t.Errorf("ParseURL(%q) accepted a foreign URL", url)
+		}
+	}
+}
+
+func TestFromTelegram(t *testing.T) {
+	service, _ := newTestService(t, nil)
+	if _, err := service.FromTelegram(context.Background(), uuid.New(), "ivanp"); !errors.Is(err, ErrTelegramUnavailable) {
+		t.Errorf("without a capable client error = %v, want ErrTelegramUnavailable", err)
+	}
+
+	service, store := newTestService(t, &fakeTelegram{photos: map[string][]byte{
+		"ivanp": encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 100, 100))),
+	}})
+	if _, err := service.FromTelegram(context.Background(), uuid.New(), "ivanp"); err != nil {
+		t.Fatalf("FromTelegram() error = %v", err)
+	}
+	if len(store.objects) != len(Sizes) {
+		t.Errorf("stored %d objects, want %d", len(store.objects), len(Sizes))
+	}
+	if _, err := service.FromTelegram(context.Background(), uuid.New(), "nobody"); !errors.Is(err, telegram.ErrNoProfilePhoto) {
+		t.Errorf("FromTelegram() error = %v, want ErrNoProfilePhoto", err)
+	}
+}
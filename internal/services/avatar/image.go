@@ -0,0 +1,143 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"net/http"
+
+	// Registered decoders of the accepted upload types
+	_ "image/gif"
+	_ "image/png"
+)
+
+// Sizes are the edge lengths in pixels of the square images stored for an avatar, largest first
+var Sizes = []int{512, 256, 64}
+
+const (
+	// MinDimension is the smallest accepted width and height
+	MinDimension = 64
+	// MaxPixels bounds the decoded size of an upload, about 100MB of memory at 4 bytes per pixel
+	MaxPixels   = 25_000_000
+	jpegQuality = 85
+)
+
+var (
+	ErrUnsupportedType = errors.New("avatar must be a JPEG, PNG or GIF image")
+	ErrImageTooSmall   = fmt.Errorf("avatar must be at least %dx%d pixels", MinDimension, MinDimension)
+	ErrImageTooLarge   = fmt.Errorf("avatar must have at most %d pixels", MaxPixels)
+	ErrInvalidImage    = errors.New("avatar image is damaged or incomplete")
+)
+
+// acceptedTypes are the content types detected from the image data, not the ones claimed by the client
+var acceptedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Process validates an uploaded image, crops it to a centered square and encodes it as JPEG
+// in each of Sizes. Transparent areas become white.
+func Process(data []byte) (map[int][]byte, error) {
+	if !acceptedTypes[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if cfg.Width < MinDimension || cfg.Height < MinDimension {
+		return nil, ErrImageTooSmall
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	// Each size is scaled from the previous one, which is cheaper and as sharp with area averaging
+	current := cropSquare(img)
+	images := make(map[int][]byte, len(Sizes))
+	for _, size := range Sizes {
+		current = resize(current, size)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, current, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, fmt.Errorf("failed to encode avatar: %w", err)
+		}
+		images[size] = buf.Bytes()
+	}
+
+	return images, nil
+}
+
+// cropSquare copies the centered square of img onto a white background
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	offset := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, offset, draw.Over)
+	return square
+}
+
+// resize scales a square image to size x size by averaging the source area behind every
+// target pixel, weighting partly covered source pixels by their coverage
+func resize(src *image.RGBA, size int) *image.RGBA {
+	side := src.Bounds().Dx()
+	if side == size {
+		return src
+	}
+
+	type span struct {
+		start   int
+		weights []float64
+	}
+	scale := float64(side) / float64(size)
+	spans := make([]span, size)
+	for i := range spans {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		start, end := int(lo), min(int(math.Ceil(hi)), side)
+		weights := make([]float64, end-start)
+		for j := start; j < end; j++ {
+			weights[j-start] = math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+		}
+		spans[i] = span{start: start, weights: weights}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y, sy := range spans {
+		for x, sx := range spans {
+			var sum [4]float64
+			var total float64
+			for j, wy := range sy.weights {
+				row := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+sy.start+j)
+				for i, wx := range sx.weights {
+					w := wy * wx
+					p := row + (sx.start+i)*4
+					for c := 0; c < 4; c++ {
+						sum[c] += w * float64(src.Pix[p+c])
+					}
+					total += w
+				}
+			}
+
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[d+c] = uint8(math.Min(255, sum[c]/total+0.5))
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,94 @@
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	// A wide image: red on the left and right thirds, transparent in the middle
+	img := image.NewNRGBA(image.Rect(0, 0, 900, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 900; x++ {
+			if x < 300 || x >= 600 {
+				img.Set(x, y, color.NRGBA{R: 255, A: 255})
+			}
+		}
+	}
+
+	images, err := Process(encodePNG(t, img))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	for _, size := range Sizes {
+		decoded, err := jpeg.Decode(bytes.NewReader(images[size]))
+		if err != nil {
+			t.Fatalf("size %d isn't a JPEG: %v", size, err)
+		}
+		if b := decoded.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Errorf("size %d is %dx%d", size, b.Dx(), b.Dy())
+		}
+
+		// The centered crop is the transparent middle, flattened onto white
+		r, g, b, _ := decoded.At(size/2, size/2).RGBA()
+		if r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+			t.Errorf("size %d center = %d,%d,%d, want white", size, r>>8, g>>8, b>>8)
+		}
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("definitely not an image"), ErrUnsupportedType},
+		{"too small", encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 63, 200))), ErrImageTooSmall},
+		{"truncated", encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 100, 100)))[:60], ErrInvalidImage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Process() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizeAveragesArea(t *testing.T) {
+	// Alternating black and white columns average to grey at half the size
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	dst := resize(src, 2)
+	for _, p := range []image.Point{{0, 0}, {1, 1}} {
+		if c := dst.RGBAAt(p.X, p.Y); c.R < 127 || c.R > 128 || c.A != 255 {
+			t.Errorf("pixel %v = %+v, want mid grey", p, c)
+		}
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/denisAlshanov/stPlaner/internal/config"
 	"github.com/denisAlshanov/stPlaner/internal/database"
 	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/quota"
 	"github.com/denisAlshanov/stPlaner/internal/services/storage"
 	"github.com/denisAlshanov/stPlaner/internal/services/telegram"
 	"github.com/denisAlshanov/stPlaner/internal/services/youtube"
@@ -26,32 +27,36 @@ type Downloader struct {
 	storage   storage.StorageInterface
 	telegram  telegram.TelegramClient
 	youtube   youtube.YouTubeClient
+	quota     *quota.Service
 	config    *config.DownloadConfig
 	semaphore chan struct{}
 	mu        sync.Mutex
 }
 
-func NewDownloader(db *database.PostgresDB, storage storage.StorageInterface, telegram telegram.TelegramClient, youtube youtube.YouTubeClient, cfg *config.DownloadConfig) *Downloader {
+func NewDownloader(db *database.PostgresDB, storage storage.StorageInterface, telegram telegram.TelegramClient, youtube youtube.YouTubeClient, quota *quota.Service, cfg *config.DownloadConfig) *Downloader {
 	return &Downloader{
 		db:        db,
 		storage:   storage,
 		telegram:  telegram,
 		youtube:   youtube,
+		quota:     quota,
 		config:    cfg,
 		semaphore: make(chan struct{}, cfg.MaxConcurrentDownloads),
 	}
 }
 
-func (d *Downloader) ProcessPost(ctx context.Context, link string) (*models.Post, error) {
+// ProcessPost grabs the media of a link on behalf of a user. Posts that were already
+// downloaded are shared with the user without counting against their quota.
+func (d *Downloader) ProcessPost(ctx context.Context, link string, userID uuid.UUID) (*models.Post, error) {
 	// Auto-detect if this is a YouTube or Telegram URL
 	if d.youtube.IsYouTubeURL(link) {
-		return d.processYouTubePost(ctx, link)
+		return d.processYouTubePost(ctx, link, userID)
 	} else {
-		return d.processTelegramPost(ctx, link)
+		return d.processTelegramPost(ctx, link, userID)
 	}
 }
 
-func (d *Downloader) processTelegramPost(ctx context.Context, link string) (*models.Post, error) {
+func (d *Downloader) processTelegramPost(ctx context.Context, link string, userID uuid.UUID) (*models.Post, error) {
 	// Parse the Telegram link
 	channelName, messageID, err := d.telegram.ParseTelegramLink(link)
 	if err != nil {
@@ -65,6 +70,9 @@ func (d *Downloader) processTelegramPost(ctx context.Context, link string) (*mod
 	if err == nil && existingPost != nil {
 		// Post already exists
 		if existingPost.Status == models.PostStatusCompleted {
+			if err := d.db.RecordPostGrab(ctx, existingPost.ID, userID); err != nil {
+				return nil, utils.NewDatabaseError(err)
+			}
 			return existingPost, nil
 		}
 		// If processing or failed, we might want to retry
@@ -74,6 +82,11 @@ func (d *Downloader) processTelegramPost(ctx context.Context, link string) (*mod
 		}
 	}
 
+	// Make sure the user still has room before downloading anything
+	if err := d.quota.CheckQuota(ctx, userID, 0); err != nil {
+		return nil, err
+	}
+
 	// Create or update post
 	post := &models.Post{
 		ContentID:           contentID,
@@ -84,11 +97,13 @@ func (d *Downloader) processTelegramPost(ctx context.Context, link string) (*mod
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 		Status:              models.PostStatusPending,
+		UserID:              &userID,
 	}
 
 	if existingPost != nil {
 		post.ID = existingPost.ID
 		post.CreatedAt = existingPost.CreatedAt
+		post.UserID = existingPost.UserID
 	}
 
 	// Save post to database
@@ -96,13 +111,17 @@ func (d *Downloader) processTelegramPost(ctx context.Context, link string) (*mod
 		return nil, utils.NewDatabaseError(err)
 	}
 
+	if err := d.db.RecordPostGrab(ctx, post.ID, userID); err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
 	// Process asynchronously
-	go d.downloadTelegramMedia(context.Background(), post)
+	go d.downloadTelegramMedia(context.Background(), post, userID)
 
 	return post, nil
 }
 
-func (d *Downloader) processYouTubePost(ctx context.Context, link string) (*models.Post, error) {
+func (d *Downloader) processYouTubePost(ctx context.Context, link string, userID uuid.UUID) (*models.Post, error) {
 	// Parse YouTube URL to get video ID
 	videoID, err := d.youtube.ParseYouTubeURL(link)
 	if err != nil {
@@ -116,6 +135,9 @@ func (d *Downloader) processYouTubePost(ctx context.Context, link string) (*mode
 	if err == nil && existingPost != nil {
 		// Post already exists
 		if existingPost.Status == models.PostStatusCompleted {
+			if err := d.db.RecordPostGrab(ctx, existingPost.ID, userID); err != nil {
+				return nil, utils.NewDatabaseError(err)
+			}
 			return existingPost, nil
 		}
 		// If processing or failed, we might want to retry
@@ -131,6 +153,11 @@ func (d *Downloader) processYouTubePost(ctx context.Context, link string) (*mode
 		return nil, utils.NewInvalidLinkError(link)
 	}
 
+	// Make sure the user still has room before downloading anything
+	if err := d.quota.CheckQuota(ctx, userID, 0); err != nil {
+		return nil, err
+	}
+
 	// Create or update post
 	post := &models.Post{
 		ContentID:           contentID,
@@ -141,11 +168,13 @@ func (d *Downloader) processYouTubePost(ctx context.Context, link string) (*mode
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 		Status:              models.PostStatusPending,
+		UserID:              &userID,
 	}
 
 	if existingPost != nil {
 		post.ID = existingPost.ID
 		post.CreatedAt = existingPost.CreatedAt
+		post.UserID = existingPost.UserID
 	}
 
 	// Save post to database
@@ -153,13 +182,17 @@ func (d *Downloader) processYouTubePost(ctx context.Context, link string) (*mode
 		return nil, utils.NewDatabaseError(err)
 	}
 
+	if err := d.db.RecordPostGrab(ctx, post.ID, userID); err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
 	// Process asynchronously
-	go d.downloadYouTubeMedia(context.Background(), post, videoID)
+	go d.downloadYouTubeMedia(context.Background(), post, videoID, userID)
 
 	return post, nil
 }
 
-func (d *Downloader) downloadTelegramMedia(ctx context.Context, post *models.Post) {
+func (d *Downloader) downloadTelegramMedia(ctx context.Context, post *models.Post, userID uuid.UUID) {
 	// Update status to processing
 	post.Status = models.PostStatusProcessing
 	if err := d.updatePostStatus(ctx, post); err != nil {
@@ -193,7 +226,7 @@ func (d *Downloader) downloadTelegramMedia(ctx context.Context, post *models.Pos
 			d.semaphore <- struct{}{}
 			defer func() { <-d.semaphore }()
 
-			if err := d.downloadAndStoreTelegramMedia(ctx, post, info); err != nil {
+			if err := d.downloadAndStoreTelegramMedia(ctx, post, info, userID); err != nil {
 				errorChan <- err
 				utils.LogError(ctx, "Failed to download media", err, utils.Fields{
 					"media_id":   info.FileID,
@@ -237,7 +270,7 @@ func (d *Downloader) downloadTelegramMedia(ctx context.Context, post *models.Pos
 	}
 }
 
-func (d *Downloader) downloadAndStoreTelegramMedia(ctx context.Context, post *models.Post, mediaInfo telegram.MediaInfo) error {
+func (d *Downloader) downloadAndStoreTelegramMedia(ctx context.Context, post *models.Post, mediaInfo telegram.MediaInfo, userID uuid.UUID) error {
 	mediaID := generateMediaID(post.ContentID, mediaInfo.FileID)
 
 	// Check if media already exists (deduplication by hash)
@@ -247,6 +280,12 @@ func (d *Downloader) downloadAndStoreTelegramMedia(ctx context.Context, post *mo
 		return nil
 	}
 
+	// Concurrent downloads of the same post may overshoot the byte quota by at
+	// most MaxConcurrentDownloads files
+	if err := d.quota.CheckQuota(ctx, userID, mediaInfo.FileSize); err != nil {
+		return err
+	}
+
 	// Download media from Telegram
 	reader, err := d.telegram.DownloadMedia(ctx, post.ChannelName, post.MessageID, mediaInfo)
 	if err != nil {
@@ -291,6 +330,7 @@ func (d *Downloader) downloadAndStoreTelegramMedia(ctx context.Context, post *mo
 		Metadata: map[string]interface{}{
 			"type": string(mediaInfo.Type),
 		},
+		UserID: &userID,
 	}
 
 	if err := d.saveMedia(ctx, media); err != nil {
@@ -327,7 +367,7 @@ func (d *Downloader) saveMedia(ctx context.Context, media *models.Media) error {
 	return d.db.CreateMedia(ctx, media)
 }
 
-func (d *Downloader) downloadYouTubeMedia(ctx context.Context, post *models.Post, videoID string) {
+func (d *Downloader) downloadYouTubeMedia(ctx context.Context, post *models.Post, videoID string, userID uuid.UUID) {
 	// Update status to processing
 	post.Status = models.PostStatusProcessing
 	if err := d.updatePostStatus(ctx, post); err != nil {
@@ -336,7 +376,7 @@ func (d *Downloader) downloadYouTubeMedia(ctx context.Context, post *models.Post
 	}
 
 	// Download YouTube video
-	err := d.downloadAndStoreYouTubeMedia(ctx, post, videoID)
+	err := d.downloadAndStoreYouTubeMedia(ctx, post, videoID, userID)
 	if err != nil {
 		utils.LogError(ctx, "Failed to download YouTube video", err)
 		post.Status = models.PostStatusFailed
@@ -355,7 +395,7 @@ func (d *Downloader) downloadYouTubeMedia(ctx context.Context, post *models.Post
 	}
 }
 
-func (d *Downloader) downloadAndStoreYouTubeMedia(ctx context.Context, post *models.Post, videoID string) error {
+func (d *Downloader) downloadAndStoreYouTubeMedia(ctx context.Context, post *models.Post, videoID string, userID uuid.UUID) error {
 	mediaID := generateMediaID(post.ContentID, videoID)
 
 	// Check if media already exists (deduplication)
@@ -372,6 +412,10 @@ func (d *Downloader) downloadAndStoreYouTubeMedia(ctx context.Context, post *mod
 	}
 	defer reader.Close()
 
+	if err := d.quota.CheckQuota(ctx, userID, videoInfo.FileSize); err != nil {
+		return err
+	}
+
 	// Calculate hash while reading
 	hasher := sha256.New()
 	teeReader := io.TeeReader(reader, hasher)
@@ -424,6 +468,8 @@ func (d *Downloader) downloadAndStoreYouTubeMedia(ctx context.Context, post *mod
 			"description":   videoInfo.Description,
 			"thumbnail_url": videoInfo.ThumbnailURL,
 		},
+		DurationSeconds: parseDurationSeconds(videoInfo.Duration),
+		UserID:          &userID,
 	}
 
 	if err := d.saveMedia(ctx, media); err != nil {
@@ -465,6 +511,16 @@ func (d *Downloader) sanitizeFileName(filename string) string {
 	return sanitized
 }
 
+// parseDurationSeconds converts a duration string such as "1h2m3s" to whole seconds
+func parseDurationSeconds(duration string) *int {
+	parsed, err := time.ParseDuration(duration)
+	if err != nil || parsed <= 0 {
+		return nil
+	}
+	seconds := int(parsed.Seconds())
+	return &seconds
+}
+
 func generateMediaID(contentID, fileID string) string {
 	return fmt.Sprintf("%s_%s", contentID, fileID)
 }
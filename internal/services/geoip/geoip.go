@@ -0,0 +1,111 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ipRange maps an inclusive address range to a country
+type ipRange struct {
+	first   netip.Addr
+	last    netip.Addr
+	country string
+}
+
+// Database looks up the country of IP addresses offline, from a CSV file of
+// first address, last address and ISO country code per line, such as the free
+// DB-IP "IP to Country Lite" download. IPv4 and IPv6 ranges can be mixed.
+type Database struct {
+	ranges []ipRange
+}
+
+// Open loads a database file
+func Open(path string) (*Database, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+	defer file.Close()
+
+	return Load(file)
+}
+
+// Load reads a database from CSV
+func Load(r io.Reader) (*Database, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	db := &Database{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("GeoIP database line %d: expected first address, last address and country", line)
+		}
+
+		first, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("GeoIP database line %d: %w", line, err)
+		}
+		last, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("GeoIP database line %d: %w", line, err)
+		}
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return nil, fmt.Errorf("GeoIP database line %d: invalid range %s-%s", line, first, last)
+		}
+
+		db.ranges = append(db.ranges, ipRange{
+			first:   first,
+			last:    last,
+			country: strings.ToUpper(strings.TrimSpace(record[2])),
+		})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].first.Less(db.ranges[j].first)
+	})
+
+	return db, nil
+}
+
+// Country returns the ISO country code of an IP address, or an empty string when
+// the address is invalid or not covered by the database
+func (db *Database) Country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// The last range starting at or before the address is the only one that can contain it
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return addr.Less(db.ranges[i].first)
+	})
+	if i == 0 {
+		return ""
+	}
+
+	r := db.ranges[i-1]
+	if r.first.Is4() != addr.Is4() || r.last.Less(addr) {
+		return ""
+	}
+	return r.country
+}
+
+// Len returns the number of ranges in the database
+func (db *Database) Len() int {
+	return len(db.ranges)
+}
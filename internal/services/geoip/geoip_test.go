@@ -0,0 +1,45 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountry(t *testing.T) {
+	db, err := Load(strings.NewReader(`1.0.0.0,1.0.0.255,au
+5.0.0.0,5.255.255.255,DE
+"2001:db8::","2001:db8::ffff",NL
+`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"1.0.0.1":         "AU",
+		"5.10.20.30":      "DE",
+		"::ffff:5.1.1.1":  "DE",
+		"2001:db8::1":     "NL",
+		"2001:db8::1:0":   "",
+		"4.4.4.4":         "",
+		"0.0.0.1":         "",
+		"not an ip":       "",
+		"255.255.255.255": "",
+	}
+	for ip, want := range tests {
+		if got := db.Country(ip); got != want {
+			t.Errorf("Country(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidRanges(t *testing.T) {
+	for _, data := range []string{
+		"1.0.0.0,1.0.0.255\n",
+		"1.0.0.255,1.0.0.0,AU\n",
+		"1.0.0.0,2001:db8::1,AU\n",
+	} {
+		if _, err := Load(strings.NewReader(data)); err == nil {
+			t.Errorf("Load(%q) accepted an invalid range", data)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package guests
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+const (
+	// MaxAvailabilityWindows and MaxBlackouts bound the size of a guest's availability
+	MaxAvailabilityWindows = 50
+	MaxBlackouts           = 100
+
+	dateLayout = "2006-01-02"
+)
+
+// ValidateAvailability checks a guest's timezone, windows and blackouts
+func ValidateAvailability(availability *models.GuestAvailability) error {
+	if availability.Timezone == "" || availability.Timezone == "Local" {
+		return errors.New("timezone must be an IANA time zone such as Europe/Berlin")
+	}
+	if _, err := time.LoadLocation(availability.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", availability.Timezone)
+	}
+
+	if len(availability.Windows) > MaxAvailabilityWindows {
+		return fmt.Errorf("at most %d windows are allowed", MaxAvailabilityWindows)
+	}
+	for i, window := range availability.Windows {
+		if window.Weekday < 0 || window.Weekday > 6 {
+			return fmt.Errorf("window %d: weekday must be 0 (Sunday) to 6 (Saturday)", i+1)
+		}
+		if _, err := parseClock(window.StartTime, false); err != nil {
+			return fmt.Errorf("window %d: start_time %w", i+1, err)
+		}
+		if _, err := parseClock(window.EndTime, true); err != nil {
+			return fmt.Errorf("window %d: end_time %w", i+1, err)
+		}
+	}
+
+	if len(availability.Blackouts) > MaxBlackouts {
+		return fmt.Errorf("at most %d blackouts are allowed", MaxBlackouts)
+	}
+	for i, blackout := range availability.Blackouts {
+		start, err := time.Parse(dateLayout, blackout.StartDate)
+		if err != nil {
+			return fmt.Errorf("blackout %d: start_date must be YYYY-MM-DD", i+1)
+		}
+		end, err := time.Parse(dateLayout, blackout.EndDate)
+		if err != nil {
+			return fmt.Errorf("blackout %d: end_date must be YYYY-MM-DD", i+1)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("blackout %d: end_date is before start_date", i+1)
+		}
+	}
+
+	return nil
+}
+
+// CheckAvailability reports whether a guest is free from start to end by their windows and
+// blackouts. The whole event has to fit in one window; it's preferred when that window is.
+// A guest without availability is free at any time. Bookings aren't looked at.
+func CheckAvailability(availability *models.GuestAvailability, start, end time.Time) models.GuestEventAvailability {
+	result := models.GuestEventAvailability{
+		Available: true,
+		Reasons:   []string{},
+		Conflicts: []models.GuestBooking{},
+	}
+	if availability == nil {
+		return result
+	}
+	result.GuestID = availability.GuestID
+
+	loc, err := time.LoadLocation(availability.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	for i := range availability.Blackouts {
+		from, to, ok := blackoutRange(availability.Blackouts[i], loc)
+		if ok && start.Before(to) && end.After(from) {
+			blackout := availability.Blackouts[i]
+			result.Available = false
+			result.Blackout = &blackout
+			result.Reasons = append(result.Reasons, models.UnavailableBlackout)
+			break
+		}
+	}
+
+	if len(availability.Windows) == 0 {
+		return result
+	}
+
+	inWindow := false
+	for _, window := range availability.Windows {
+		if windowCovers(window, start, end, loc) {
+			inWindow = true
+			if window.Preferred {
+				result.Preferred = true
+			}
+		}
+	}
+	if !inWindow {
+		result.Available = false
+		result.Reasons = append(result.Reasons, models.UnavailableOutsideWindows)
+	}
+	if !result.Available {
+		result.Preferred = false
+	}
+
+	return result
+}
+
+// SuggestEvents checks every event against the availability and bookings of the guests, in
+// event order. A booking into another event overlapping the event is a conflict; a booking
+// into the event itself only marks the guest as already booked.
+func SuggestEvents(events []models.Event, guestIDs []uuid.UUID, availability map[uuid.UUID]*models.GuestAvailability, bookings []models.GuestBooking) []models.EventSuggestion {
+	suggestions := make([]models.EventSuggestion, 0, len(events))
+	for _, event := range events {
+		suggestion := models.EventSuggestion{
+			EventID:       event.ID,
+			EventTitle:    event.EventTitle,
+			StartDatetime: event.StartDateTime,
+			EndDatetime:   event.EndDateTime,
+			AllAvailable:  true,
+			Guests:        make([]models.GuestEventAvailability, 0, len(guestIDs)),
+		}
+
+		for _, guestID := range guestIDs {
+			check := CheckAvailability(availability[guestID], event.StartDateTime, event.EndDateTime)
+			check.GuestID = guestID
+
+			for _, booking := range bookings {
+				if booking.GuestID != guestID {
+					continue
+				}
+				if booking.EventID == event.ID {
+					check.AlreadyBooked = true
+					continue
+				}
+				if booking.StartDatetime.Before(event.EndDateTime) && booking.EndDatetime.After(event.StartDateTime) {
+					check.Conflicts = append(check.Conflicts, booking)
+				}
+			}
+			if len(check.Conflicts) > 0 {
+				check.Available = false
+				check.Preferred = false
+				check.Reasons = append(check.Reasons, models.UnavailableConflict)
+			}
+
+			if !check.Available {
+				suggestion.AllAvailable = false
+			}
+			if check.Preferred {
+				suggestion.PreferredCount++
+			}
+			suggestion.Guests = append(suggestion.Guests, check)
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions
+}
+
+// windowCovers reports whether start to end falls within one occurrence of the window. An
+// occurrence starting the day before can run past midnight into the event's day.
+func windowCovers(window models.GuestAvailabilityWindow, start, end time.Time, loc *time.Location) bool {
+	from, err := parseClock(window.StartTime, false)
+	if err != nil {
+		return false
+	}
+	to, err := parseClock(window.EndTime, true)
+	if err != nil {
+		return false
+	}
+
+	local := start.In(loc)
+	for _, offset := range []int{-1, 0} {
+		day := time.Date(local.Year(), local.Month(), local.Day()+offset, 0, 0, 0, 0, loc)
+		if int(day.Weekday()) != window.Weekday {
+			continue
+		}
+
+		// Built from the wall clock so windows keep their local times across DST changes
+		occurrenceStart := time.Date(day.Year(), day.Month(), day.Day(), 0, from, 0, 0, loc)
+		endDay := day.Day()
+		if to <= from {
+			endDay++
+		}
+		occurrenceEnd := time.Date(day.Year(), day.Month(), endDay, 0, to, 0, 0, loc)
+
+		if !start.Before(occurrenceStart) && !end.After(occurrenceEnd) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blackoutRange returns the span of a blackout, from the start of its first day to the end
+// of its last day in the guest's timezone
+func blackoutRange(blackout models.GuestBlackout, loc *time.Location) (time.Time, time.Time, bool) {
+	start, err := time.Parse(dateLayout, blackout.StartDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	end, err := time.Parse(dateLayout, blackout.EndDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	to := time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, loc)
+	return from, to, true
+}
+
+// parseClock parses HH:MM into minutes since midnight. 24:00 is allowed for the end of a day.
+func parseClock(value string, endOfDay bool) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	if !ok || len(hours) != 2 || len(minutes) != 2 {
+		return 0, errors.New("must be HH:MM")
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, errors.New("must be HH:MM")
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return 0, errors.New("must be HH:MM")
+	}
+
+	if endOfDay && h == 24 && m == 0 {
+		return 24 * 60, nil
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, errors.New("must be a time between 00:00 and 23:59")
+	}
+	return h*60 + m, nil
+}
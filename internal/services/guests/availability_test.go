@@ -0,0 +1,166 @@
+package guests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestCheckAvailabilityWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	availability := &models.GuestAvailability{
+		Timezone: "Europe/Berlin",
+		Windows: []models.GuestAvailabilityWindow{
+			{Weekday: 1, StartTime: "18:00", EndTime: "22:00"},
+			{Weekday: 1, StartTime: "23:00", EndTime: "02:00", Preferred: true}, // Monday night into Tuesday
+		},
+	}
+
+	local := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, berlin)
+	}
+
+	tests := []struct {
+		name      string
+		start     time.Time
+		length    time.Duration
+		available bool
+		preferred bool
+	}{
+		{"inside window", local(2026, 3, 23, 19, 0), time.Hour, true, false},
+		{"window bounds", local(2026, 3, 23, 18, 0), 4 * time.Hour, true, false},
+		// Berlin moves to summer time on 29 March; the window stays at 18:00 local
+		{"inside window after DST change", local(2026, 3, 30, 18, 0), time.Hour, true, false},
+		{"starts before window", local(2026, 3, 23, 17, 30), time.Hour, false, false},
+		{"ends after window", local(2026, 3, 23, 21, 30), time.Hour, false, false},
+		{"other weekday", local(2026, 3, 24, 19, 0), time.Hour, false, false},
+		{"past midnight", local(2026, 3, 31, 0, 30), time.Hour, true, true},
+		{"spans both windows", local(2026, 3, 23, 21, 30), 2 * time.Hour, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckAvailability(availability, tt.start, tt.start.Add(tt.length))
+			if got.Available != tt.available || got.Preferred != tt.preferred {
+				t.Errorf("CheckAvailability() = available %v preferred %v, want %v %v (reasons %v)",
+					got.Available, got.Preferred, tt.available, tt.preferred, got.Reasons)
+			}
+		})
+	}
+}
+
+func TestCheckAvailabilityBlackouts(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	availability := &models.GuestAvailability{
+		Timezone:  "Europe/Berlin",
+		Blackouts: []models.GuestBlackout{{StartDate: "2026-06-01", EndDate: "2026-06-02"}},
+	}
+
+	// Just after midnight on 1 June in Berlin, still 31 May in UTC
+	start := time.Date(2026, 5, 31, 22, 30, 0, 0, time.UTC)
+	got := CheckAvailability(availability, start, start.Add(time.Hour))
+	if got.Available || got.Blackout == nil || len(got.Reasons) != 1 || got.Reasons[0] != models.UnavailableBlackout {
+		t.Errorf("event in blackout: got %+v", got)
+	}
+
+	// The day after the last blackout day
+	start = time.Date(2026, 6, 2, 22, 0, 0, 0, time.UTC)
+	if got := CheckAvailability(availability, start, start.Add(time.Hour)); !got.Available {
+		t.Errorf("event after blackout: got %+v", got)
+	}
+
+	if got := CheckAvailability(nil, start, start.Add(time.Hour)); !got.Available {
+		t.Errorf("guest without availability should be available, got %+v", got)
+	}
+}
+
+func TestSuggestEvents(t *testing.T) {
+	first := time.Date(2026, 7, 6, 18, 0, 0, 0, time.UTC)
+	second := first.Add(7 * 24 * time.Hour)
+	events := []models.Event{
+		{ID: uuid.New(), StartDateTime: first, EndDateTime: first.Add(time.Hour)},
+		{ID: uuid.New(), StartDateTime: second, EndDateTime: second.Add(time.Hour)},
+	}
+
+	booked, busy := uuid.New(), uuid.New()
+	bookings := []models.GuestBooking{
+		{GuestID: booked, EventID: events[0].ID, StartDatetime: first, EndDatetime: first.Add(time.Hour)},
+		// Another show's event overlapping the second event
+		{GuestID: busy, EventID: uuid.New(), StartDatetime: second.Add(30 * time.Minute), EndDatetime: second.Add(2 * time.Hour)},
+	}
+
+	suggestions := SuggestEvents(events, []uuid.UUID{booked, busy}, nil, bookings)
+	if len(suggestions) != 2 {
+		t.Fatalf("SuggestEvents() returned %d suggestions, want 2", len(suggestions))
+	}
+
+	if !suggestions[0].AllAvailable {
+		t.Errorf("first event should suit both guests, got %+v", suggestions[0].Guests)
+	}
+	if !suggestions[0].Guests[0].AlreadyBooked || len(suggestions[0].Guests[0].Conflicts) != 0 {
+		t.Errorf("booking into the event itself should not conflict, got %+v", suggestions[0].Guests[0])
+	}
+
+	if suggestions[1].AllAvailable {
+		t.Error("second event should not suit both guests")
+	}
+	busyCheck := suggestions[1].Guests[1]
+	if busyCheck.Available || len(busyCheck.Conflicts) != 1 || busyCheck.Reasons[0] != models.UnavailableConflict {
+		t.Errorf("overlapping booking should conflict, got %+v", busyCheck)
+	}
+}
+
+func TestValidateAvailability(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skipf("time zone data not available: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		availability models.GuestAvailability
+		valid        bool
+	}{
+		{"valid", models.GuestAvailability{
+			Timezone:  "Europe/Berlin",
+			Windows:   []models.GuestAvailabilityWindow{{Weekday: 0, StartTime: "09:00", EndTime: "24:00"}},
+			Blackouts: []models.GuestBlackout{{StartDate: "2026-08-01", EndDate: "2026-08-01"}},
+		}, true},
+		{"missing timezone", models.GuestAvailability{}, false},
+		{"unknown timezone", models.GuestAvailability{Timezone: "Mars/Olympus"}, false},
+		{"bad weekday", models.GuestAvailability{
+			Timezone: "UTC",
+			Windows:  []models.GuestAvailabilityWindow{{Weekday: 7, StartTime: "09:00", EndTime: "10:00"}},
+		}, false},
+		{"bad time", models.GuestAvailability{
+			Timezone: "UTC",
+			Windows:  []models.GuestAvailabilityWindow{{Weekday: 1, StartTime: "9:00", EndTime: "10:00"}},
+		}, false},
+		{"24:00 start", models.GuestAvailability{
+			Timezone: "UTC",
+			Windows:  []models.GuestAvailabilityWindow{{Weekday: 1, StartTime: "24:00", EndTime: "10:00"}},
+		}, false},
+		{"blackout ends before start", models.GuestAvailability{
+			Timezone:  "UTC",
+			Blackouts: []models.GuestBlackout{{StartDate: "2026-08-02", EndDate: "2026-08-01"}},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAvailability(&tt.availability)
+			if (err == nil) != tt.valid {
+				t.Errorf("ValidateAvailability() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
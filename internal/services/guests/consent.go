@@ -0,0 +1,35 @@
+package guests
+
+import (
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// CurrentConsents derives the current state of every purpose from a guest's consent history,
+// which must be ordered latest first. A purpose is active when its latest record grants
+// consent that hasn't expired; purposes without records are reported as inactive.
+func CurrentConsents(history []models.GuestConsent, now time.Time) []models.GuestConsentStatus {
+	purposes := []models.ConsentPurpose{
+		models.ConsentPurposeContact,
+		models.ConsentPurposeRecording,
+		models.ConsentPurposePublication,
+		models.ConsentPurposeMarketing,
+	}
+
+	statuses := make([]models.GuestConsentStatus, 0, len(purposes))
+	for _, purpose := range purposes {
+		status := models.GuestConsentStatus{Purpose: purpose}
+		for i := range history {
+			if history[i].Purpose == purpose {
+				consent := history[i]
+				status.Consent = &consent
+				status.Active = consent.Granted && (consent.ExpiresAt == nil || now.Before(*consent.ExpiresAt))
+				break
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
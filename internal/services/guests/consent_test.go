@@ -0,0 +1,44 @@
+package guests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestCurrentConsents(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	expired := now.Add(-time.Hour)
+
+	// Latest first, as the database returns them
+	history := []models.GuestConsent{
+		{Purpose: models.ConsentPurposeContact, Granted: false, ObtainedAt: now.Add(-24 * time.Hour)},
+		{Purpose: models.ConsentPurposeRecording, Granted: true, ObtainedAt: now.Add(-48 * time.Hour)},
+		{Purpose: models.ConsentPurposePublication, Granted: true, ObtainedAt: now.Add(-72 * time.Hour), ExpiresAt: &expired},
+		{Purpose: models.ConsentPurposeContact, Granted: true, ObtainedAt: now.Add(-96 * time.Hour)},
+	}
+
+	want := map[models.ConsentPurpose]bool{
+		models.ConsentPurposeContact:     false, // withdrawn after it was granted
+		models.ConsentPurposeRecording:   true,
+		models.ConsentPurposePublication: false, // expired
+		models.ConsentPurposeMarketing:   false, // never asked
+	}
+
+	statuses := CurrentConsents(history, now)
+	if len(statuses) != len(want) {
+		t.Fatalf("CurrentConsents() returned %d purposes, want %d", len(statuses), len(want))
+	}
+	for _, status := range statuses {
+		if status.Active != want[status.Purpose] {
+			t.Errorf("%s active = %v, want %v", status.Purpose, status.Active, want[status.Purpose])
+		}
+	}
+	if statuses[0].Consent == nil || statuses[0].Consent.Granted {
+		t.Errorf("contact status should point at the withdrawal, got %+v", statuses[0].Consent)
+	}
+	if statuses[3].Consent != nil {
+		t.Errorf("marketing has no records, got %+v", statuses[3].Consent)
+	}
+}
@@ -0,0 +1,190 @@
+package guests
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// Weights of the duplicate score. A shared contact value is strong evidence on its own,
+// but names carry most of the score so namesakes with a shared office phone stay low.
+const (
+	nameWeight    = 0.7
+	contactWeight = 0.3
+)
+
+// Reasons a pair of guests is reported as a duplicate candidate
+const (
+	ReasonSameName    = "same_name"
+	ReasonSimilarName = "similar_name"
+)
+
+// similarNameThreshold is the name similarity from which names count as similar
+const similarNameThreshold = 0.5
+
+// NormalizeName reduces a guest name for comparison: lower case, without annotations in
+// brackets such as "(TG)" and with single spaces
+func NormalizeName(name, surname string) string {
+	full := strings.ToLower(name + " " + surname)
+
+	var b strings.Builder
+	depth := 0
+	for _, r := range full {
+		switch r {
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if depth == 0 {
+			b.WriteRune(r)
+		}
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// NameSimilarity compares two normalized names like pg_trgm's similarity(): the share
+// of trigrams the two have in common, from 0 to 1
+func NameSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams splits a string into words of letters and digits and returns the trigrams
+// of each word padded with two spaces in front and one behind
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = true
+		}
+	}
+	return set
+}
+
+// NormalizeContactValue reduces a contact value for comparison, so "+7 (900) 123-45-67"
+// matches "79001234567" and "@Ivan" matches "ivan"
+func NormalizeContactValue(contactType models.ContactType, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	switch contactType {
+	case models.ContactTypePhone:
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, value)
+	case models.ContactTypeTelegram, models.ContactTypeTwitter, models.ContactTypeInstagram, models.ContactTypeDiscord:
+		value = strings.TrimPrefix(value, "@")
+		if i := strings.LastIndex(value, "/"); i >= 0 {
+			value = value[i+1:]
+		}
+		return value
+	case models.ContactTypeWebsite, models.ContactTypeLinkedIn:
+		value = strings.TrimPrefix(strings.TrimPrefix(value, "https://"), "http://")
+		value = strings.TrimPrefix(value, "www.")
+		return strings.TrimRight(value, "/")
+	default:
+		return value
+	}
+}
+
+func contactKey(contact models.GuestContact) string {
+	return string(contact.Type) + ":" + NormalizeContactValue(contact.Type, contact.Value)
+}
+
+// SharedContacts returns the contacts of a that b has too
+func SharedContacts(a, b []models.GuestContact) []models.GuestContact {
+	keys := make(map[string]bool, len(b))
+	for _, contact := range b {
+		if NormalizeContactValue(contact.Type, contact.Value) != "" {
+			keys[contactKey(contact)] = true
+		}
+	}
+
+	var shared []models.GuestContact
+	for _, contact := range a {
+		if key := contactKey(contact); keys[key] {
+			shared = append(shared, contact)
+			delete(keys, key)
+		}
+	}
+	return shared
+}
+
+// ScoreDuplicate rates how likely two guests are the same person, from 0 to 1
+func ScoreDuplicate(a, b *models.Guest) models.GuestDuplicateCandidate {
+	nameA, nameB := NormalizeName(a.Name, a.Surname), NormalizeName(b.Name, b.Surname)
+	similarity := NameSimilarity(nameA, nameB)
+	shared := SharedContacts(a.Contacts, b.Contacts)
+
+	candidate := models.GuestDuplicateCandidate{
+		Guest:          a,
+		Duplicate:      b,
+		NameSimilarity: similarity,
+		SharedContacts: shared,
+		Reasons:        []string{},
+	}
+
+	switch {
+	case nameA != "" && nameA == nameB:
+		candidate.Reasons = append(candidate.Reasons, ReasonSameName)
+	case similarity >= similarNameThreshold:
+		candidate.Reasons = append(candidate.Reasons, ReasonSimilarName)
+	}
+
+	seen := make(map[models.ContactType]bool)
+	for _, contact := range shared {
+		if !seen[contact.Type] {
+			seen[contact.Type] = true
+			candidate.Reasons = append(candidate.Reasons, "shared_"+string(contact.Type))
+		}
+	}
+
+	candidate.Score = nameWeight * similarity
+	if len(shared) > 0 {
+		candidate.Score += contactWeight
+	}
+
+	return candidate
+}
+
+// RankDuplicates scores candidate pairs and returns those reaching minScore, best first
+func RankDuplicates(pairs [][2]*models.Guest, minScore float64) []models.GuestDuplicateCandidate {
+	candidates := []models.GuestDuplicateCandidate{}
+	for _, pair := range pairs {
+		candidate := ScoreDuplicate(pair[0], pair[1])
+		if candidate.Score >= minScore {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
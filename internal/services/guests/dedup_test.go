@@ -0,0 +1,86 @@
+package guests
+
+import (
+	"testing"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := map[[2]string]string{
+		{"Ivan", "Petrov (TG)"}:    "ivan petrov",
+		{" Ivan ", " Petrov "}:     "ivan petrov",
+		{"Ivan [old]", "Petrov"}:   "ivan petrov",
+		{"Анна", "Иванова"}:        "анна иванова",
+		{"Ivan", "Petrov (a (b))"}: "ivan petrov",
+	}
+	for in, want := range tests {
+		if got := NormalizeName(in[0], in[1]); got != want {
+			t.Errorf("NormalizeName(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+func TestNameSimilarity(t *testing.T) {
+	if got := NameSimilarity("ivan petrov", "ivan petrov"); got != 1 {
+		t.Errorf("NameSimilarity(same) = %v, want 1", got)
+	}
+	if got := NameSimilarity("ivan petrov", "ivan petrova"); got < 0.5 {
+		t.Errorf("NameSimilarity(petrov, petrova) = %v, want at least 0.5", got)
+	}
+	if got := NameSimilarity("ivan petrov", "maria lopez"); got > 0.1 {
+		t.Errorf("NameSimilarity(unrelated) = %v, want close to 0", got)
+	}
+	if got := NameSimilarity("", "ivan"); got != 0 {
+		t.Errorf("NameSimilarity(empty) = %v, want 0", got)
+	}
+}
+
+func TestNormalizeContactValue(t *testing.T) {
+	tests := []struct {
+		contactType models.ContactType
+		a, b        string
+	}{
+		{models.ContactTypePhone, "+7 (900) 123-45-67", "79001234567"},
+		{models.ContactTypeTelegram, "@IvanP", "https://t.me/ivanp"},
+		{models.ContactTypeEmail, " Ivan@Example.com", "ivan@example.com"},
+		{models.ContactTypeWebsite, "https://www.example.com/", "example.com"},
+	}
+	for _, tt := range tests {
+		if a, b := NormalizeContactValue(tt.contactType, tt.a), NormalizeContactValue(tt.contactType, tt.b); a != b {
+			t.Errorf("NormalizeContactValue(%s) = %q and %q, want them equal", tt.contactType, a, b)
+		}
+	}
+}
+
+func TestScoreDuplicate(t *testing.T) {
+	ivan := &models.Guest{Name: "Ivan", Surname: "Petrov", Contacts: []models.GuestContact{
+		{Type: models.ContactTypeTelegram, Value: "@ivanp"},
+	}}
+	ivanTG := &models.Guest{Name: "Ivan", Surname: "Petrov (TG)", Contacts: []models.GuestContact{
+		{Type: models.ContactTypeTelegram, Value: "ivanp"},
+	}}
+	maria := &models.Guest{Name: "Maria", Surname: "Lopez", Contacts: []models.GuestContact{
+		{Type: models.ContactTypeTelegram, Value: "ivanp"},
+	}}
+
+	const minScore = 0.5
+
+	same := ScoreDuplicate(ivan, ivanTG)
+	if same.Score < 0.99 {
+		t.Errorf("Score(same person) = %v, want 1", same.Score)
+	}
+	if len(same.Reasons) != 2 || same.Reasons[0] != ReasonSameName || same.Reasons[1] != "shared_telegram" {
+		t.Errorf("Reasons = %v, want same_name and shared_telegram", same.Reasons)
+	}
+
+	other := ScoreDuplicate(ivan, maria)
+	if other.Score >= minScore {
+		t.Errorf("Score(different names, shared contact) = %v, want below %v", other.Score, minScore)
+	}
+
+	ranked := RankDuplicates([][2]*models.Guest{{ivan, maria}, {ivan, ivanTG}}, minScore)
+	if len(ranked) != 1 || ranked[0].Duplicate != ivanTG {
+		t.Errorf("RankDuplicates() = %d candidates, want only Ivan Petrov (TG)", len(ranked))
+	}
+}
@@ -0,0 +1,112 @@
+package guests
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// Export formats
+const (
+	FormatCSV   = "csv"
+	FormatVCard = "vcard"
+)
+
+// CSVWriter writes guests as CSV rows with one column per contact type. The header uses
+// the import field names, so an exported file imports back without a mapping.
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter creates a CSV writer on w
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteHeader writes the header row
+func (cw *CSVWriter) WriteHeader() error {
+	header := []string{FieldName, FieldSurname, FieldShortName}
+	for _, contactType := range contactTypes {
+		header = append(header, string(contactType))
+	}
+	header = append(header, FieldTags, FieldNotes, FieldAvatar, "id", "created_at")
+	return cw.w.Write(header)
+}
+
+// Write writes one guest. Several contacts of a type share the cell, primary first.
+func (cw *CSVWriter) Write(guest *models.Guest) error {
+	record := []string{guest.Name, guest.Surname, stringValue(guest.ShortName)}
+	for _, contactType := range contactTypes {
+		var values []string
+		for _, contact := range guest.Contacts {
+			if contact.Type != contactType {
+				continue
+			}
+			if contact.IsPrimary {
+				values = append([]string{contact.Value}, values...)
+			} else {
+				values = append(values, contact.Value)
+			}
+		}
+		record = append(record, strings.Join(values, listSeparator+" "))
+	}
+	record = append(record,
+		strings.Join(guest.Tags, listSeparator+" "),
+		stringValue(guest.Notes),
+		stringValue(guest.Avatar),
+		guest.ID.String(),
+		guest.CreatedAt.UTC().Format(time.RFC3339),
+	)
+
+	for i := range record {
+		record[i] = guardFormula(record[i])
+	}
+	return cw.w.Write(record)
+}
+
+// Flush writes buffered rows to the underlying writer
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// phoneCellRegex matches a cell of plain phone numbers, which can start with + or -
+// without being a formula
+var phoneCellRegex = regexp.MustCompile(`^[+-][0-9 ()-]+(` + listSeparator + ` *\+?[0-9 ()-]+)*$`)
+
+// guardFormula prefixes cells a spreadsheet would evaluate as a formula with a quote.
+// Phone numbers such as "+44 20 7946 0000" are left alone.
+func guardFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '@', '\t', '\r':
+		return "'" + value
+	case '+', '-':
+		if phoneCellRegex.MatchString(value) {
+			return value
+		}
+		return "'" + value
+	}
+	return value
+}
+
+// unguardFormula reverses guardFormula for values read back from an exported file
+func unguardFormula(value string) string {
+	if len(value) > 1 && value[0] == '\'' && guardFormula(value[1:]) == value {
+		return value[1:]
+	}
+	return value
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,319 @@
+package guests
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// Limits of a single import
+const (
+	MaxImportRows = 5000
+	// listSeparator separates several tags or contact values in one CSV cell
+	listSeparator = ";"
+)
+
+// Guest fields a CSV column can be mapped to. Contact columns are named after the contact type.
+const (
+	FieldName      = "name"
+	FieldSurname   = "surname"
+	FieldFullName  = "full_name"
+	FieldShortName = "short_name"
+	FieldNotes     = "notes"
+	FieldTags      = "tags"
+	FieldAvatar    = "avatar"
+	FieldIgnore    = "ignore"
+)
+
+var contactTypes = []models.ContactType{
+	models.ContactTypeEmail, models.ContactTypePhone, models.ContactTypeTelegram,
+	models.ContactTypeDiscord, models.ContactTypeTwitter, models.ContactTypeLinkedIn,
+	models.ContactTypeInstagram, models.ContactTypeWebsite, models.ContactTypeOther,
+}
+
+// headerAliases maps common spreadsheet and address book headers to guest fields,
+// used for the columns the mapping leaves out
+var headerAliases = map[string]string{
+	"name":          FieldName,
+	"first name":    FieldName,
+	"first_name":    FieldName,
+	"given name":    FieldName,
+	"surname":       FieldSurname,
+	"last name":     FieldSurname,
+	"last_name":     FieldSurname,
+	"family name":   FieldSurname,
+	"full name":     FieldFullName,
+	"full_name":     FieldFullName,
+	"display name":  FieldFullName,
+	"short name":    FieldShortName,
+	"short_name":    FieldShortName,
+	"nickname":      FieldShortName,
+	"notes":         FieldNotes,
+	"note":          FieldNotes,
+	"tags":          FieldTags,
+	"labels":        FieldTags,
+	"avatar":        FieldAvatar,
+	"e-mail":        string(models.ContactTypeEmail),
+	"email address": string(models.ContactTypeEmail),
+	"phone number":  string(models.ContactTypePhone),
+	"mobile":        string(models.ContactTypePhone),
+	"tg":            string(models.ContactTypeTelegram),
+	"x":             string(models.ContactTypeTwitter),
+	"web":           string(models.ContactTypeWebsite),
+	"url":           string(models.ContactTypeWebsite),
+}
+
+// ErrNoNameColumn is returned for CSV files where no column maps to a name
+var ErrNoNameColumn = errors.New("no column is mapped to name, surname or full_name")
+
+// ImportRow is a guest read from an import file, before it is checked against the directory
+type ImportRow struct {
+	Row    int // line or card number in the file, from 1
+	Guest  *models.Guest
+	Errors []string
+}
+
+// IsValidField reports whether a CSV column can be mapped to the field
+func IsValidField(field string) bool {
+	switch field {
+	case FieldName, FieldSurname, FieldFullName, FieldShortName, FieldNotes, FieldTags, FieldAvatar, FieldIgnore:
+		return true
+	}
+	return isContactType(field)
+}
+
+// resolveColumns maps each header to a field: the explicit mapping first, then the
+// header itself if it names a field, then the known aliases
+func resolveColumns(header []string, mapping map[string]string) []string {
+	normalized := make(map[string]string, len(mapping))
+	for column, field := range mapping {
+		normalized[strings.ToLower(strings.TrimSpace(column))] = field
+	}
+
+	fields := make([]string, len(header))
+	for i, column := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		switch {
+		case normalized[key] != "":
+			fields[i] = normalized[key]
+		case IsValidField(key):
+			fields[i] = key
+		case headerAliases[key] != "":
+			fields[i] = headerAliases[key]
+		default:
+			fields[i] = FieldIgnore
+		}
+	}
+	return fields
+}
+
+// ParseCSV reads guests from a CSV file with a header row. mapping assigns header names to
+// guest fields; unmapped headers that name a field or a common alias are picked up too.
+// Tags and contact values can hold several entries separated by semicolons.
+func ParseCSV(r io.Reader, mapping map[string]string) ([]ImportRow, error) {
+	for column, field := range mapping {
+		if !IsValidField(field) {
+			return nil, fmt.Errorf("column %q is mapped to unknown field %q", column, field)
+		}
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	fields := resolveColumns(header, mapping)
+
+	hasName := false
+	for _, field := range fields {
+		if field == FieldName || field == FieldSurname || field == FieldFullName {
+			hasName = true
+		}
+	}
+	if !hasName {
+		return nil, ErrNoNameColumn
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV line %d: %w", line, err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("file has more than %d guests", MaxImportRows)
+		}
+
+		guest := &models.Guest{Tags: []string{}, Contacts: []models.GuestContact{}}
+		var fullName string
+		for i, value := range record {
+			if i >= len(fields) {
+				break
+			}
+			value = unguardFormula(strings.TrimSpace(value))
+			if value == "" {
+				continue
+			}
+
+			switch fields[i] {
+			case FieldIgnore:
+			case FieldName:
+				guest.Name = value
+			case FieldSurname:
+				guest.Surname = value
+			case FieldFullName:
+				fullName = value
+			case FieldShortName:
+				guest.ShortName = &value
+			case FieldNotes:
+				guest.Notes = &value
+			case FieldAvatar:
+				guest.Avatar = &value
+			case FieldTags:
+				guest.Tags = appendUnique(guest.Tags, splitList(value)...)
+			default:
+				for _, item := range splitList(value) {
+					guest.Contacts = append(guest.Contacts, models.GuestContact{
+						Type:  models.ContactType(fields[i]),
+						Value: item,
+					})
+				}
+			}
+		}
+
+		if guest.Name == "" && guest.Surname == "" && fullName != "" {
+			guest.Name, guest.Surname = SplitFullName(fullName)
+		}
+
+		rows = append(rows, ImportRow{Row: line, Guest: guest, Errors: Validate(guest)})
+	}
+
+	return rows, nil
+}
+
+// ImportMatchScore is the duplicate score from which an imported guest is taken to be an
+// existing one: the same name, or a similar name with a shared contact
+const ImportMatchScore = 0.7
+
+// FindMatch returns the existing guest that best matches an imported one, or nil when
+// none scores ImportMatchScore. The imported guest is the candidate's Guest.
+func FindMatch(guest *models.Guest, existing []*models.Guest) *models.GuestDuplicateCandidate {
+	var best *models.GuestDuplicateCandidate
+	for _, other := range existing {
+		candidate := ScoreDuplicate(guest, other)
+		if candidate.Score >= ImportMatchScore && (best == nil || candidate.Score > best.Score) {
+			best = &candidate
+		}
+	}
+	return best
+}
+
+// SplitFullName splits "Ivan Petrov" into name and surname at the last space
+func SplitFullName(fullName string) (name, surname string) {
+	parts := strings.Fields(fullName)
+	switch len(parts) {
+	case 0:
+		return "", ""
+	case 1:
+		return parts[0], ""
+	default:
+		return strings.Join(parts[:len(parts)-1], " "), parts[len(parts)-1]
+	}
+}
+
+// Validate checks an imported guest the way the guest API would, cleans up its tags and
+// marks the first contact of each type primary
+func Validate(guest *models.Guest) []string {
+	errs := []string{}
+	if strings.TrimSpace(guest.Name) == "" {
+		errs = append(errs, "name is required")
+	}
+	if strings.TrimSpace(guest.Surname) == "" {
+		errs = append(errs, "surname is required")
+	}
+	if len(guest.Name) > 255 || len(guest.Surname) > 255 {
+		errs = append(errs, "name and surname must be at most 255 characters")
+	}
+	if guest.ShortName != nil && len(*guest.ShortName) > 100 {
+		errs = append(errs, "short name must be at most 100 characters")
+	}
+	if guest.Avatar != nil && len(*guest.Avatar) > 500 {
+		errs = append(errs, "avatar must be at most 500 characters")
+	}
+	if tags, err := NormalizeTags(guest.Tags); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		guest.Tags = tags
+	}
+
+	primary := make(map[models.ContactType]bool)
+	for i := range guest.Contacts {
+		contact := &guest.Contacts[i]
+		if contact.Type == models.ContactTypeEmail {
+			if _, err := mail.ParseAddress(contact.Value); err != nil {
+				errs = append(errs, fmt.Sprintf("invalid email address %q", contact.Value))
+			}
+		}
+		if contact.IsPrimary {
+			primary[contact.Type] = true
+		}
+	}
+	for i := range guest.Contacts {
+		contact := &guest.Contacts[i]
+		if !primary[contact.Type] {
+			contact.IsPrimary = true
+			primary[contact.Type] = true
+		}
+	}
+
+	return errs
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if strings.EqualFold(existing, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func isBlankRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package guests
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestParseCSV(t *testing.T) {
+	input := "First,Last,Mail,Phone number,Labels,Company\n" +
+		"Ivan,Petrov,ivan@example.com; ivan@work.example.com,+44 20 7946 0000,tech;speaker,Acme\n" +
+		",,,,,\n" +
+		"Anna,,not-an-email,,,\n"
+
+	rows, err := ParseCSV(strings.NewReader(input), map[string]string{
+		"First": FieldName,
+		"Last":  FieldSurname,
+		"Mail":  string(models.ContactTypeEmail),
+	})
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("ParseCSV() returned %d rows, want 2", len(rows))
+	}
+
+	ivan := rows[0]
+	if ivan.Row != 2 || ivan.Guest.Name != "Ivan" || ivan.Guest.Surname != "Petrov" || len(ivan.Errors) != 0 {
+		t.Errorf("first row = %+v, errors %v", ivan.Guest, ivan.Errors)
+	}
+	if len(ivan.Guest.Contacts) != 3 {
+		t.Fatalf("contacts = %+v, want 2 emails and a phone", ivan.Guest.Contacts)
+	}
+	if !ivan.Guest.Contacts[0].IsPrimary || ivan.Guest.Contacts[1].IsPrimary || !ivan.Guest.Contacts[2].IsPrimary {
+		t.Errorf("first contact of each type should be primary: %+v", ivan.Guest.Contacts)
+	}
+	if strings.Join(ivan.Guest.Tags, ",") != "tech,speaker" {
+		t.Errorf("tags = %v", ivan.Guest.Tags)
+	}
+
+	anna := rows[1]
+	if anna.Row != 4 || len(anna.Errors) != 2 {
+		t.Errorf("last row errors = %v, want missing surname and invalid email", anna.Errors)
+	}
+}
+
+func TestParseCSVFullName(t *testing.T) {
+	rows, err := ParseCSV(strings.NewReader("Full name,Telegram\nMaria de la Cruz,@maria\n"), nil)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	guest := rows[0].Guest
+	if guest.Name != "Maria de la" || guest.Surname != "Cruz" {
+		t.Errorf("name = %q %q", guest.Name, guest.Surname)
+	}
+	if guest.Contacts[0].Type != models.ContactTypeTelegram {
+		t.Errorf("contact = %+v", guest.Contacts[0])
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	if _, err := ParseCSV(strings.NewReader("Company,City\nAcme,Riga\n"), nil); !errors.Is(err, ErrNoNameColumn) {
+		t.Errorf("without a name column error = %v, want ErrNoNameColumn", err)
+	}
+	if _, err := ParseCSV(strings.NewReader("a\n"), map[string]string{"a": "birthday"}); err == nil {
+		t.Error("mapping to an unknown field should fail")
+	}
+}
+
+func TestCSVExportRoundTrip(t *testing.T) {
+	guest := &models.Guest{
+		ID:        uuid.New(),
+		Name:      "Ivan",
+		Surname:   "Petrov",
+		ShortName: stringPtr("Vanya"),
+		Notes:     stringPtr("=HYPERLINK(\"x\")"),
+		Tags:      []string{"tech", "speaker"},
+		Contacts: []models.GuestContact{
+			{Type: models.ContactTypePhone, Value: "+44 20 7946 0000"},
+			{Type: models.ContactTypeEmail, Value: "ivan@work.example.com"},
+			{Type: models.ContactTypeEmail, Value: "ivan@example.com", IsPrimary: true},
+		},
+		CreatedAt: time.Now(),
+	}
+
+	var buf bytes.Buffer
+	writer := NewCSVWriter(&buf)
+	if err := writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(guest); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"'=HYPERLINK`) {
+		t.Errorf("formula in notes wasn't guarded: %s", buf.String())
+	}
+
+	rows, err := ParseCSV(&buf, nil)
+	if err != nil {
+		t.Fatalf("ParseCSV() error = %v", err)
+	}
+	got := rows[0].Guest
+	if got.Name != "Ivan" || got.Surname != "Petrov" || *got.ShortName != "Vanya" || *got.Notes != *guest.Notes {
+		t.Errorf("round trip = %+v", got)
+	}
+	if len(got.Contacts) != 3 || got.Contacts[0].Value != "ivan@example.com" || !got.Contacts[0].IsPrimary {
+		t.Errorf("contacts = %+v, want the primary email first", got.Contacts)
+	}
+	if got.Contacts[2].Value != "+44 20 7946 0000" {
+		t.Errorf("phone = %q", got.Contacts[2].Value)
+	}
+}
+
+func TestGuardFormula(t *testing.T) {
+	tests := map[string]string{
+		"+44 20 7946 0000":                "+44 20 7946 0000",
+		"+44 20 7946 0000; +1 (555) 0100": "+44 20 7946 0000; +1 (555) 0100",
+		"-5":                              "-5",
+		"Ivan":                            "Ivan",
+		"=HYPERLINK(\"x\")":               "'=HYPERLINK(\"x\")",
+		"@SUM(A1)":                        "'@SUM(A1)",
+		"+1+cmd|' /C calc'!A0":            "'+1+cmd|' /C calc'!A0",
+		"-2+3+cmd|' /C calc'!A0":          "'-2+3+cmd|' /C calc'!A0",
+		"+1 (SUM(A1:A9))":                 "'+1 (SUM(A1:A9))",
+	}
+	for value, want := range tests {
+		got := guardFormula(value)
+		if got != want {
+			t.Errorf("guardFormula(%q) = %q, want %q", value, got, want)
+		}
+		if unguardFormula(got) != value {
+			t.Errorf("unguardFormula(%q) = %q, want %q", got, unguardFormula(got), value)
+		}
+	}
+}
+
+func TestFindMatch(t *testing.T) {
+	existing := []*models.Guest{
+		{ID: uuid.New(), Name: "Ivan", Surname: "Petrov"},
+		{ID: uuid.New(), Name: "Anna", Surname: "Smirnova", Contacts: []models.GuestContact{
+			{Type: models.ContactTypeEmail, Value: "anna@example.com"},
+		}},
+	}
+
+	if match := FindMatch(&models.Guest{Name: "ivan", Surname: "petrov (TG)"}, existing); match == nil || match.Duplicate != existing[0] {
+		t.Errorf("same name should match, got %+v", match)
+	}
+	if match := FindMatch(&models.Guest{Name: "Ana", Surname: "Smirnova", Contacts: []models.GuestContact{
+		{Type: models.ContactTypeEmail, Value: "Anna@Example.com"},
+	}}, existing); match == nil || match.Duplicate != existing[1] {
+		t.Errorf("similar name with a shared email should match, got %+v", match)
+	}
+	if match := FindMatch(&models.Guest{Name: "Anna", Surname: "Ivanova"}, existing); match != nil {
+		t.Errorf("different guest matched %+v", match.Duplicate)
+	}
+}
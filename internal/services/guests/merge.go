@@ -0,0 +1,106 @@
+package guests
+
+import (
+	"strings"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// notesSeparator separates the notes of merged guests
+const notesSeparator = "\n\n---\n\n"
+
+// Merge folds the duplicates into a copy of the survivor. The survivor's values win;
+// contacts, tags and metadata keys it lacks are added, notes are appended, and the
+// short name and avatar are taken from a duplicate when the survivor has none.
+func Merge(survivor *models.Guest, duplicates []*models.Guest) *models.Guest {
+	merged := *survivor
+	merged.Contacts = append([]models.GuestContact{}, survivor.Contacts...)
+	merged.Tags = append([]string{}, survivor.Tags...)
+	merged.Metadata = make(map[string]interface{}, len(survivor.Metadata))
+	for key, value := range survivor.Metadata {
+		merged.Metadata[key] = value
+	}
+
+	contactKeys := make(map[string]bool)
+	primaryTypes := make(map[models.ContactType]bool)
+	for _, contact := range merged.Contacts {
+		contactKeys[contactKey(contact)] = true
+		if contact.IsPrimary {
+			primaryTypes[contact.Type] = true
+		}
+	}
+
+	tags := make(map[string]bool)
+	for _, tag := range merged.Tags {
+		tags[strings.ToLower(tag)] = true
+	}
+
+	var notes []string
+	if survivor.Notes != nil && strings.TrimSpace(*survivor.Notes) != "" {
+		notes = append(notes, *survivor.Notes)
+	}
+
+	for _, duplicate := range duplicates {
+		for _, contact := range duplicate.Contacts {
+			key := contactKey(contact)
+			if contactKeys[key] {
+				continue
+			}
+			contactKeys[key] = true
+
+			// Only one primary contact per type
+			if contact.IsPrimary {
+				if primaryTypes[contact.Type] {
+					contact.IsPrimary = false
+				} else {
+					primaryTypes[contact.Type] = true
+				}
+			}
+			merged.Contacts = append(merged.Contacts, contact)
+		}
+
+		for _, tag := range duplicate.Tags {
+			if !tags[strings.ToLower(tag)] {
+				tags[strings.ToLower(tag)] = true
+				merged.Tags = append(merged.Tags, tag)
+			}
+		}
+
+		for key, value := range duplicate.Metadata {
+			if _, ok := merged.Metadata[key]; !ok {
+				merged.Metadata[key] = value
+			}
+		}
+
+		if duplicate.Notes != nil && strings.TrimSpace(*duplicate.Notes) != "" && !containsNote(notes, *duplicate.Notes) {
+			notes = append(notes, *duplicate.Notes)
+		}
+
+		if isBlank(merged.ShortName) && !isBlank(duplicate.ShortName) {
+			merged.ShortName = duplicate.ShortName
+		}
+		if isBlank(merged.Avatar) && !isBlank(duplicate.Avatar) {
+			merged.Avatar = duplicate.Avatar
+		}
+	}
+
+	if len(notes) > 0 {
+		joined := strings.Join(notes, notesSeparator)
+		merged.Notes = &joined
+	}
+
+	return &merged
+}
+
+func containsNote(notes []string, note string) bool {
+	for _, existing := range notes {
+		if strings.TrimSpace(existing) == strings.TrimSpace(note) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(value *string) bool {
+	return value == nil || strings.TrimSpace(*value) == ""
+}
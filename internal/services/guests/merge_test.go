@@ -0,0 +1,68 @@
+package guests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestMerge(t *testing.T) {
+	survivor := &models.Guest{
+		Name:    "Ivan",
+		Surname: "Petrov",
+		Notes:   stringPtr("Prefers mornings"),
+		Tags:    []string{"tech"},
+		Contacts: []models.GuestContact{
+			{Type: models.ContactTypeEmail, Value: "ivan@example.com", IsPrimary: true},
+		},
+		Metadata: map[string]interface{}{"company": "Acme"},
+	}
+	duplicate := &models.Guest{
+		Name:      "Ivan",
+		Surname:   "Petrov (TG)",
+		ShortName: stringPtr("Vanya"),
+		Notes:     stringPtr("Met at the conference"),
+		Tags:      []string{"Tech", "speaker"},
+		Contacts: []models.GuestContact{
+			{Type: models.ContactTypeEmail, Value: "IVAN@example.com"},
+			{Type: models.ContactTypeEmail, Value: "petrov@example.org", IsPrimary: true},
+			{Type: models.ContactTypeTelegram, Value: "@ivanp", IsPrimary: true},
+		},
+		Metadata: map[string]interface{}{"company": "Other", "city": "Riga"},
+	}
+
+	merged := Merge(survivor, []*models.Guest{duplicate})
+
+	if merged.Surname != "Petrov" {
+		t.Errorf("Surname = %q, want the survivor's", merged.Surname)
+	}
+	if len(merged.Contacts) != 3 {
+		t.Fatalf("Contacts = %v, want the survivor's email plus the other email and Telegram", merged.Contacts)
+	}
+	if merged.Contacts[1].IsPrimary {
+		t.Error("a second primary email was kept")
+	}
+	if !merged.Contacts[2].IsPrimary {
+		t.Error("the only Telegram contact lost its primary flag")
+	}
+	if strings.Join(merged.Tags, ",") != "tech,speaker" {
+		t.Errorf("Tags = %v, want tech,speaker", merged.Tags)
+	}
+	if merged.Notes == nil || !strings.Contains(*merged.Notes, "Prefers mornings") || !strings.Contains(*merged.Notes, "Met at the conference") {
+		t.Errorf("Notes = %v, want both notes", merged.Notes)
+	}
+	if merged.ShortName == nil || *merged.ShortName != "Vanya" {
+		t.Errorf("ShortName = %v, want the duplicate's", merged.ShortName)
+	}
+	if merged.Metadata["company"] != "Acme" || merged.Metadata["city"] != "Riga" {
+		t.Errorf("Metadata = %v, want the survivor's company and the duplicate's city", merged.Metadata)
+	}
+	if len(survivor.Contacts) != 1 || len(survivor.Tags) != 1 || len(survivor.Metadata) != 1 {
+		t.Error("Merge() modified the survivor")
+	}
+}
@@ -0,0 +1,77 @@
+package guests
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+const (
+	// MaxTagLength is the longest tag name, in characters
+	MaxTagLength = 100
+	// MaxGuestTags is the most tags a guest can carry
+	MaxGuestTags = 50
+)
+
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// NormalizeTags trims tag names and drops blank ones and repeats, which are matched
+// ignoring case. The first spelling of a tag wins.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > MaxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, MaxTagLength)
+		}
+		seen[strings.ToLower(tag)] = true
+		normalized = append(normalized, tag)
+	}
+
+	if len(normalized) > MaxGuestTags {
+		return nil, fmt.Errorf("a guest can have at most %d tags", MaxGuestTags)
+	}
+	return normalized, nil
+}
+
+// ValidTagColor reports whether a color is a hex color like #3b82f6
+func ValidTagColor(color string) bool {
+	return tagColorPattern.MatchString(color)
+}
+
+// ValidateFilters checks the guest filters a list or saved segment is built from
+func ValidateFilters(filters models.GuestFilters) error {
+	for _, contactType := range filters.HasContactType {
+		if !isContactType(string(contactType)) {
+			return fmt.Errorf("unknown contact type %q", contactType)
+		}
+	}
+
+	if filters.MinAppearances != nil && *filters.MinAppearances < 0 {
+		return errors.New("min_appearances can't be negative")
+	}
+	if filters.MaxAppearances != nil && *filters.MaxAppearances < 0 {
+		return errors.New("max_appearances can't be negative")
+	}
+	if filters.MinAppearances != nil && filters.MaxAppearances != nil && *filters.MinAppearances > *filters.MaxAppearances {
+		return errors.New("min_appearances is greater than max_appearances")
+	}
+
+	if filters.LastAppearanceAfter != nil && filters.LastAppearanceBefore != nil &&
+		filters.LastAppearanceAfter.After(*filters.LastAppearanceBefore) {
+		return errors.New("last_appearance_after is later than last_appearance_before")
+	}
+	if filters.CreatedDateRange != nil && filters.CreatedDateRange.Start.After(filters.CreatedDateRange.End) {
+		return errors.New("created_date_range starts after it ends")
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package guests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags([]string{" Speaker ", "", "speaker", "Music", "  ", "MUSIC", "Berlin"})
+	if err != nil {
+		t.Fatalf("NormalizeTags() error = %v", err)
+	}
+	want := []string{"Speaker", "Music", "Berlin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags() = %v, want %v", got, want)
+	}
+
+	if _, err := NormalizeTags([]string{strings.Repeat("ä", MaxTagLength+1)}); err == nil {
+		t.Error("NormalizeTags() accepted a tag that is too long")
+	}
+
+	tooMany := make([]string, MaxGuestTags+1)
+	for i := range tooMany {
+		tooMany[i] = strings.Repeat("x", i+1)
+	}
+	if _, err := NormalizeTags(tooMany); err == nil {
+		t.Error("NormalizeTags() accepted too many tags")
+	}
+}
+
+func TestValidTagColor(t *testing.T) {
+	for color, want := range map[string]bool{
+		"#3b82f6": true,
+		"#FFAA00": true,
+		"3b82f6":  false,
+		"#fff":    false,
+		"#12345g": false,
+		"":        false,
+	} {
+		if got := ValidTagColor(color); got != want {
+			t.Errorf("ValidTagColor(%q) = %v, want %v", color, got, want)
+		}
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	one, three := 1, 3
+	negative := -1
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.AddDate(0, 6, 0)
+
+	tests := []struct {
+		name    string
+		filters models.GuestFilters
+		valid   bool
+	}{
+		{"empty", models.GuestFilters{}, true},
+		{"appearances", models.GuestFilters{MinAppearances: &one, MaxAppearances: &three}, true},
+		{"last appearance", models.GuestFilters{LastAppearanceAfter: &earlier, LastAppearanceBefore: &later}, true},
+		{"contact type", models.GuestFilters{HasContactType: []models.ContactType{models.ContactTypeEmail}}, true},
+		{"unknown contact type", models.GuestFilters{HasContactType: []models.ContactType{"fax"}}, false},
+		{"negative appearances", models.GuestFilters{MinAppearances: &negative}, false},
+		{"min above max", models.GuestFilters{MinAppearances: &three, MaxAppearances: &one}, false},
+		{"last appearance reversed", models.GuestFilters{LastAppearanceAfter: &later, LastAppearanceBefore: &earlier}, false},
+		{"created range reversed", models.GuestFilters{CreatedDateRange: &models.DateRange{Start: later, End: earlier}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFilters(tt.filters)
+			if (err == nil) != tt.valid {
+				t.Errorf("ValidateFilters() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package guests
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+// maxVCardLine is the line length in octets after which written vCards are folded
+const maxVCardLine = 75
+
+// vCardTypes are TYPE parameter values describing the transport rather than a label
+var vCardTypes = map[string]bool{
+	"pref": true, "internet": true, "voice": true, "text": true, "msg": true, "x400": true,
+}
+
+// socialProperties maps the address book extensions for social profiles to contact types
+var socialProperties = map[string]models.ContactType{
+	"X-TELEGRAM":  models.ContactTypeTelegram,
+	"X-DISCORD":   models.ContactTypeDiscord,
+	"X-TWITTER":   models.ContactTypeTwitter,
+	"X-LINKEDIN":  models.ContactTypeLinkedIn,
+	"X-INSTAGRAM": models.ContactTypeInstagram,
+}
+
+type vCardProperty struct {
+	name   string
+	params map[string][]string
+	value  string
+}
+
+// ParseVCard reads guests from a vCard 3.0 or 4.0 file with one or more cards
+func ParseVCard(r io.Reader) ([]ImportRow, error) {
+	lines, err := unfoldVCard(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vCard: %w", err)
+	}
+
+	var rows []ImportRow
+	var guest *models.Guest
+	var fullName string
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, ok := parseVCardLine(line)
+		if !ok {
+			return nil, fmt.Errorf("malformed vCard line %d", i+1)
+		}
+
+		switch prop.name {
+		case "BEGIN":
+			if strings.EqualFold(prop.value, "VCARD") {
+				guest = &models.Guest{Tags: []string{}, Contacts: []models.GuestContact{}}
+				fullName = ""
+			}
+			continue
+		case "END":
+			if guest == nil || !strings.EqualFold(prop.value, "VCARD") {
+				continue
+			}
+			if guest.Name == "" && guest.Surname == "" {
+				guest.Name, guest.Surname = SplitFullName(fullName)
+			}
+			if len(rows) == MaxImportRows {
+				return nil, fmt.Errorf("file has more than %d guests", MaxImportRows)
+			}
+			rows = append(rows, ImportRow{Row: len(rows) + 1, Guest: guest, Errors: Validate(guest)})
+			guest = nil
+			continue
+		}
+		if guest == nil {
+			continue
+		}
+
+		switch prop.name {
+		case "N":
+			parts := splitEscaped(prop.value, ';')
+			if len(parts) > 0 {
+				guest.Surname = strings.TrimSpace(parts[0])
+			}
+			if len(parts) > 1 {
+				given := strings.TrimSpace(parts[1])
+				if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+					given += " " + strings.TrimSpace(parts[2])
+				}
+				guest.Name = given
+			}
+		case "FN":
+			fullName = strings.TrimSpace(unescapeVCard(prop.value))
+		case "NICKNAME":
+			if names := splitEscaped(prop.value, ','); len(names) > 0 && strings.TrimSpace(names[0]) != "" {
+				shortName := strings.TrimSpace(names[0])
+				guest.ShortName = &shortName
+			}
+		case "NOTE":
+			if note := strings.TrimSpace(unescapeVCard(prop.value)); note != "" {
+				guest.Notes = &note
+			}
+		case "CATEGORIES":
+			for _, tag := range splitEscaped(prop.value, ',') {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					guest.Tags = appendUnique(guest.Tags, tag)
+				}
+			}
+		case "PHOTO":
+			value := strings.TrimSpace(prop.value)
+			if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+				guest.Avatar = &value
+			}
+		case "EMAIL":
+			addVCardContact(guest, models.ContactTypeEmail, prop, unescapeVCard(prop.value))
+		case "TEL":
+			addVCardContact(guest, models.ContactTypePhone, prop, strings.TrimPrefix(unescapeVCard(prop.value), "tel:"))
+		case "URL":
+			addVCardContact(guest, models.ContactTypeWebsite, prop, unescapeVCard(prop.value))
+		case "IMPP":
+			contactType, value := models.ContactTypeOther, unescapeVCard(prop.value)
+			if scheme, handle, ok := strings.Cut(value, ":"); ok {
+				switch strings.ToLower(scheme) {
+				case "telegram", "tg":
+					contactType, value = models.ContactTypeTelegram, handle
+				case "discord":
+					contactType, value = models.ContactTypeDiscord, handle
+				}
+			}
+			addVCardContact(guest, contactType, prop, value)
+		case "X-SOCIALPROFILE":
+			contactType := models.ContactTypeOther
+			for _, t := range prop.params["TYPE"] {
+				if isContactType(t) {
+					contactType = models.ContactType(t)
+				}
+			}
+			addVCardContact(guest, contactType, prop, unescapeVCard(prop.value))
+		default:
+			if contactType, ok := socialProperties[prop.name]; ok {
+				addVCardContact(guest, contactType, prop, unescapeVCard(prop.value))
+			}
+		}
+	}
+
+	return rows, nil
+}
+
+// unfoldVCard joins folded continuation lines, which start with a space or a tab
+func unfoldVCard(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseVCardLine splits "item1.EMAIL;TYPE=work,pref:ivan@example.com" into its upper-cased
+// name without the group, its parameters and its raw value
+func parseVCardLine(line string) (vCardProperty, bool) {
+	inQuotes := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		}
+		if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return vCardProperty{}, false
+	}
+
+	segments := strings.Split(line[:colon], ";")
+	name := strings.ToUpper(segments[0])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+
+	prop := vCardProperty{name: name, params: make(map[string][]string), value: line[colon+1:]}
+	for _, segment := range segments[1:] {
+		key, value, ok := strings.Cut(segment, "=")
+		if !ok {
+			// vCard 2.1 style bare types, such as TEL;CELL
+			key, value = "TYPE", segment
+		}
+		key = strings.ToUpper(key)
+		for _, v := range strings.Split(strings.Trim(value, `"`), ",") {
+			if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+				prop.params[key] = append(prop.params[key], v)
+			}
+		}
+	}
+
+	return prop, true
+}
+
+func addVCardContact(guest *models.Guest, contactType models.ContactType, prop vCardProperty, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	contact := models.GuestContact{Type: contactType, Value: value}
+	for _, t := range prop.params["TYPE"] {
+		if t == "pref" {
+			contact.IsPrimary = true
+		} else if !vCardTypes[t] && contact.Label == nil && prop.name != "X-SOCIALPROFILE" {
+			label := t
+			contact.Label = &label
+		}
+	}
+	if pref := prop.params["PREF"]; len(pref) > 0 && pref[0] == "1" {
+		contact.IsPrimary = true
+	}
+
+	guest.Contacts = append(guest.Contacts, contact)
+}
+
+func isContactType(value string) bool {
+	for _, contactType := range contactTypes {
+		if value == string(contactType) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitEscaped splits a structured value at separators that aren't escaped with a backslash
+// and unescapes the parts
+func splitEscaped(value string, sep byte) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			current.WriteByte(value[i])
+			current.WriteByte(value[i+1])
+			i++
+		case value[i] == sep:
+			parts = append(parts, unescapeVCard(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(value[i])
+		}
+	}
+	return append(parts, unescapeVCard(current.String()))
+}
+
+func unescapeVCard(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+func escapeVCard(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		",", `\,`,
+		";", `\;`,
+	).Replace(value)
+}
+
+// WriteVCard writes a guest as a vCard 3.0 card, the version address books import most reliably
+func WriteVCard(w io.Writer, guest *models.Guest) error {
+	var lines []string
+	add := func(name, value string) {
+		lines = append(lines, name+":"+value)
+	}
+
+	add("BEGIN", "VCARD")
+	add("VERSION", "3.0")
+	add("UID", "urn:uuid:"+guest.ID.String())
+	add("N", escapeVCard(guest.Surname)+";"+escapeVCard(guest.Name)+";;;")
+	add("FN", escapeVCard(strings.TrimSpace(guest.Name+" "+guest.Surname)))
+	if guest.ShortName != nil && *guest.ShortName != "" {
+		add("NICKNAME", escapeVCard(*guest.ShortName))
+	}
+
+	for _, contact := range guest.Contacts {
+		var types []string
+		if contact.Label != nil && isVCardToken(*contact.Label) {
+			types = append(types, strings.ToLower(*contact.Label))
+		}
+		if contact.IsPrimary {
+			types = append(types, "pref")
+		}
+
+		name := ""
+		switch contact.Type {
+		case models.ContactTypeEmail:
+			name = "EMAIL"
+			types = append([]string{"internet"}, types...)
+		case models.ContactTypePhone:
+			name = "TEL"
+		case models.ContactTypeWebsite:
+			name = "URL"
+		default:
+			name = "X-SOCIALPROFILE"
+			types = []string{string(contact.Type)}
+		}
+		if len(types) > 0 {
+			name += ";TYPE=" + strings.Join(types, ",")
+		}
+		add(name, escapeVCard(contact.Value))
+	}
+
+	if len(guest.Tags) > 0 {
+		tags := make([]string, len(guest.Tags))
+		for i, tag := range guest.Tags {
+			tags[i] = escapeVCard(tag)
+		}
+		add("CATEGORIES", strings.Join(tags, ","))
+	}
+	if guest.Notes != nil && *guest.Notes != "" {
+		add("NOTE", escapeVCard(*guest.Notes))
+	}
+	if guest.Avatar != nil && *guest.Avatar != "" {
+		add("PHOTO;VALUE=uri", *guest.Avatar)
+	}
+	add("REV", guest.UpdatedAt.UTC().Format("20060102T150405Z"))
+	add("END", "VCARD")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldVCardLine(line))
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// foldVCardLine breaks lines longer than 75 octets without splitting a UTF-8 character
+func foldVCardLine(line string) string {
+	if len(line) <= maxVCardLine {
+		return line
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := utf8.RuneLen(r)
+		if width+size > maxVCardLine {
+			b.WriteString("\r\n ")
+			// the leading space of the continuation counts toward its length
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+func isVCardToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package guests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestParseVCard(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:3.0",
+		"N:Petrov;Ivan;;;",
+		"FN:Ivan Petrov",
+		"NICKNAME:Vanya",
+		"item1.EMAIL;TYPE=INTERNET,WORK:ivan@work.example.com",
+		"EMAIL;TYPE=INTERNET,pref:ivan@example.com",
+		"TEL;TYPE=CELL:+44 20 7946 0000",
+		"X-SOCIALPROFILE;TYPE=telegram:@ivanp",
+		"NOTE:Prefers mornings\\, avoids Mondays.\\nVegetarian. This note is long enough t",
+		" o be folded.",
+		"CATEGORIES:tech,speaker",
+		"END:VCARD",
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:Anna Smirnova",
+		"TEL;VALUE=uri;PREF=1;TYPE=home:tel:+371-2000-0000",
+		"IMPP:telegram:annas",
+		"PHOTO:https://example.com/anna.jpg",
+		"END:VCARD",
+	}, "\r\n")
+
+	rows, err := ParseVCard(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseVCard() error = %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("ParseVCard() returned %d cards, want 2", len(rows))
+	}
+
+	ivan := rows[0].Guest
+	if ivan.Name != "Ivan" || ivan.Surname != "Petrov" || *ivan.ShortName != "Vanya" || len(rows[0].Errors) != 0 {
+		t.Errorf("first card = %+v, errors %v", ivan, rows[0].Errors)
+	}
+	if want := "Prefers mornings, avoids Mondays.\nVegetarian. This note is long enough to be folded."; *ivan.Notes != want {
+		t.Errorf("notes = %q, want %q", *ivan.Notes, want)
+	}
+	if len(ivan.Contacts) != 4 {
+		t.Fatalf("contacts = %+v", ivan.Contacts)
+	}
+	if ivan.Contacts[0].IsPrimary || *ivan.Contacts[0].Label != "work" || !ivan.Contacts[1].IsPrimary {
+		t.Errorf("emails = %+v, %+v", ivan.Contacts[0], ivan.Contacts[1])
+	}
+	if ivan.Contacts[3].Type != models.ContactTypeTelegram || ivan.Contacts[3].Value != "@ivanp" {
+		t.Errorf("telegram = %+v", ivan.Contacts[3])
+	}
+	if strings.Join(ivan.Tags, ",") != "tech,speaker" {
+		t.Errorf("tags = %v", ivan.Tags)
+	}
+
+	anna := rows[1].Guest
+	if anna.Name != "Anna" || anna.Surname != "Smirnova" || *anna.Avatar != "https://example.com/anna.jpg" {
+		t.Errorf("second card = %+v", anna)
+	}
+	if anna.Contacts[0].Value != "+371-2000-0000" || !anna.Contacts[0].IsPrimary {
+		t.Errorf("phone = %+v", anna.Contacts[0])
+	}
+	if anna.Contacts[1].Type != models.ContactTypeTelegram || anna.Contacts[1].Value != "annas" {
+		t.Errorf("impp = %+v", anna.Contacts[1])
+	}
+}
+
+func TestVCardRoundTrip(t *testing.T) {
+	guest := &models.Guest{
+		ID:      uuid.New(),
+		Name:    "Jean-Luc",
+		Surname: "Picard; Captain",
+		Notes:   stringPtr(strings.Repeat("Тест, ", 30)),
+		Tags:    []string{"sci-fi", "a,b"},
+		Contacts: []models.GuestContact{
+			{Type: models.ContactTypeEmail, Value: "jl@example.com", Label: stringPtr("work"), IsPrimary: true},
+			{Type: models.ContactTypeDiscord, Value: "picard#1701", IsPrimary: true},
+		},
+		UpdatedAt: time.Now(),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteVCard(&buf, guest); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxVCardLine {
+			t.Errorf("line longer than %d octets: %q", maxVCardLine, line)
+		}
+	}
+
+	rows, err := ParseVCard(&buf)
+	if err != nil {
+		t.Fatalf("ParseVCard() error = %v", err)
+	}
+	got := rows[0].Guest
+	if got.Name != guest.Name || got.Surname != guest.Surname || *got.Notes != strings.TrimSpace(*guest.Notes) {
+		t.Errorf("round trip = %q %q %q", got.Name, got.Surname, *got.Notes)
+	}
+	if strings.Join(got.Tags, "|") != "sci-fi|a,b" {
+		t.Errorf("tags = %v", got.Tags)
+	}
+	if len(got.Contacts) != 2 || *got.Contacts[0].Label != "work" || !got.Contacts[0].IsPrimary || got.Contacts[1].Type != models.ContactTypeDiscord {
+		t.Errorf("contacts = %+v", got.Contacts)
+	}
+}
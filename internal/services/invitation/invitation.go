@@ -0,0 +1,261 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	// notifyTimeout bounds the delivery of a single notification
+	notifyTimeout = 30 * time.Second
+	// reminderBatchSize bounds how many reminders a single run sends
+	reminderBatchSize = 100
+	// reminderRetryDelay is how long a failed reminder waits before it is sent again
+	reminderRetryDelay = 30 * time.Minute
+	// maxReminderAttempts is how often a reminder is tried before it is given up
+	maxReminderAttempts = 5
+)
+
+var (
+	// ErrEventClosed is returned when inviting or answering for an event that was cancelled or has ended
+	ErrEventClosed = errors.New("event is cancelled or over")
+	// ErrInvalidResponse is returned for statuses guests can't answer with
+	ErrInvalidResponse = errors.New("response must be confirmed, declined or tentative")
+)
+
+// Store is the invitation storage, implemented by database.PostgresDB
+type Store interface {
+	GetGuestInvitationByID(ctx context.Context, id uuid.UUID) (*models.GuestInvitation, error)
+	MarkGuestInvited(ctx context.Context, id uuid.UUID) (time.Time, error)
+	SetGuestInvitationStatus(ctx context.Context, id uuid.UUID, status models.InvitationStatus) (time.Time, error)
+	ListDueGuestReminders(ctx context.Context, startsBefore, failedBefore time.Time, maxAttempts, limit int) ([]*models.GuestInvitation, error)
+	MarkGuestReminderSent(ctx context.Context, id uuid.UUID) error
+	MarkGuestReminderFailed(ctx context.Context, id uuid.UUID) error
+}
+
+// Service invites guests to blocks, takes their RSVP responses and reminds them before the event
+type Service struct {
+	store      Store
+	signer     *Signer
+	notifier   Notifier
+	config     *config.InvitationConfig
+	appBaseURL string
+	now        func() time.Time
+}
+
+// NewService creates an invitation service. RSVP links point to the frontend at appBaseURL.
+func NewService(store Store, signer *Signer, notifier Notifier, cfg *config.InvitationConfig, appBaseURL string) *Service {
+	return &Service{
+		store:      store,
+		signer:     signer,
+		notifier:   notifier,
+		config:     cfg,
+		appBaseURL: appBaseURL,
+		now:        time.Now,
+	}
+}
+
+// Invite records that the guest was invited and emails them an RSVP link if they have an
+// email address. The link is returned either way, so it can be passed on by hand.
+func (s *Service) Invite(ctx context.Context, invitation *models.GuestInvitation) (rsvpURL string, emailSent bool, err error) {
+	if !s.isOpen(invitation) {
+		return "", false, ErrEventClosed
+	}
+
+	invitedAt, err := s.store.MarkGuestInvited(ctx, invitation.ID)
+	if err != nil {
+		return "", false, err
+	}
+	invitation.InvitedAt = &invitedAt
+	invitation.ReminderSentAt = nil
+
+	rsvpURL = s.RSVPURL(invitation)
+
+	email := guestEmail(invitation)
+	if email == "" {
+		return rsvpURL, false, nil
+	}
+
+	if err := s.notify(ctx, NotificationInvitation, email, invitation, rsvpURL); err != nil {
+		utils.LogError(ctx, "Failed to send guest invitation", err, utils.Fields{
+			"block_id": invitation.BlockID,
+			"guest_id": invitation.GuestID,
+		})
+		return rsvpURL, false, nil
+	}
+
+	return rsvpURL, true, nil
+}
+
+// Resolve returns the booking an RSVP link was issued for
+func (s *Service) Resolve(ctx context.Context, token string) (*models.GuestInvitation, error) {
+	bookingID, err := s.signer.Verify(token, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.store.GetGuestInvitationByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	// The guest was removed from the block since
+	if invitation == nil {
+		return nil, ErrInvalidLink
+	}
+
+	return invitation, nil
+}
+
+// Respond records a guest's answer given through an RSVP link
+func (s *Service) Respond(ctx context.Context, token string, status models.InvitationStatus) (*models.GuestInvitation, error) {
+	if !status.IsGuestResponse() {
+		return nil, ErrInvalidResponse
+	}
+
+	invitation, err := s.Resolve(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !s.isOpen(invitation) {
+		return nil, ErrEventClosed
+	}
+
+	if err := s.SetStatus(ctx, invitation, status); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(ctx, "Guest responded to invitation", utils.Fields{
+		"block_id": invitation.BlockID,
+		"guest_id": invitation.GuestID,
+		"status":   status,
+	})
+
+	return invitation, nil
+}
+
+// SetStatus records a new invitation status, for example a no-show marked by the organizer
+func (s *Service) SetStatus(ctx context.Context, invitation *models.GuestInvitation, status models.InvitationStatus) error {
+	respondedAt, err := s.store.SetGuestInvitationStatus(ctx, invitation.ID, status)
+	if err != nil {
+		return err
+	}
+	invitation.InvitationStatus = status
+	invitation.RespondedAt = &respondedAt
+	return nil
+}
+
+// RSVPURL returns the link a guest answers an invitation with. It stays valid until the event ends.
+func (s *Service) RSVPURL(invitation *models.GuestInvitation) string {
+	token := s.signer.Sign(invitation.ID, invitation.EndDatetime)
+	return s.appBaseURL + "/rsvp?token=" + url.QueryEscape(token)
+}
+
+// SendDueReminders reminds invited guests of events starting within the reminder lead time
+// and returns how many reminders were sent
+func (s *Service) SendDueReminders(ctx context.Context) (int, error) {
+	if s.config.ReminderLeadTime <= 0 {
+		return 0, nil
+	}
+
+	now := s.now()
+	invitations, err := s.store.ListDueGuestReminders(ctx, now.Add(s.config.ReminderLeadTime), now.Add(-reminderRetryDelay), maxReminderAttempts, reminderBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, invitation := range invitations {
+		if email := guestEmail(invitation); email != "" {
+			if err := s.notify(ctx, NotificationReminder, email, invitation, s.RSVPURL(invitation)); err != nil {
+				// Retried after a delay, so the batch moves on to the reminders after it
+				utils.LogError(ctx, "Failed to send guest reminder", err, utils.Fields{
+					"block_id": invitation.BlockID,
+					"guest_id": invitation.GuestID,
+				})
+				if err := s.store.MarkGuestReminderFailed(ctx, invitation.ID); err != nil {
+					return sent, err
+				}
+				continue
+			}
+			sent++
+		}
+
+		// Guests without an email address are marked too, so they aren't picked up again
+		if err := s.store.MarkGuestReminderSent(ctx, invitation.ID); err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// StartReminders periodically sends due reminders until the context is cancelled
+func (s *Service) StartReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, err := s.SendDueReminders(ctx)
+			if err != nil {
+				utils.LogError(ctx, "Failed to send guest reminders", err)
+				continue
+			}
+			if sent > 0 {
+				utils.LogInfo(ctx, "Sent guest reminders", utils.Fields{
+					"count": sent,
+				})
+			}
+		}
+	}
+}
+
+func (s *Service) notify(ctx context.Context, kind, email string, invitation *models.GuestInvitation, rsvpURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	return s.notifier.Notify(ctx, Notification{
+		Kind:          kind,
+		To:            email,
+		GuestName:     invitation.GuestName,
+		EventTitle:    invitation.EventTitle,
+		ShowName:      invitation.ShowName,
+		BlockTitle:    invitation.BlockTitle,
+		StartDatetime: invitation.StartDatetime,
+		Confirmed:     invitation.InvitationStatus == models.InvitationStatusConfirmed,
+		RSVPURL:       rsvpURL,
+	})
+}
+
+// isOpen reports whether the event still takes invitations and answers
+func (s *Service) isOpen(invitation *models.GuestInvitation) bool {
+	return invitation.EventStatus != models.EventStatusCancelled && s.now().Before(invitation.EndDatetime)
+}
+
+// guestEmail returns the primary email address of the guest, or the first one
+func guestEmail(invitation *models.GuestInvitation) string {
+	email := ""
+	for _, contact := range invitation.GuestContacts {
+		if contact.Type != models.ContactTypeEmail {
+			continue
+		}
+		if contact.IsPrimary {
+			return contact.Value
+		}
+		if email == "" {
+			email = contact.Value
+		}
+	}
+	return email
+}
@@ -0,0 +1,147 @@
+package invitation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+type fakeStore struct {
+	due      []*models.GuestInvitation
+	reminded []uuid.UUID
+	failed   []uuid.UUID
+}
+
+func (s *fakeStore) GetGuestInvitationByID(ctx context.Context, id uuid.UUID) (*models.GuestInvitation, error) {
+	for _, invitation := range s.due {
+		if invitation.ID == id {
+			return invitation, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) MarkGuestInvited(ctx context.Context, id uuid.UUID) (time.Time, error) {
+	return time.Now(), nil
+}
+
+func (s *fakeStore) SetGuestInvitationStatus(ctx context.Context, id uuid.UUID, status models.InvitationStatus) (time.Time, error) {
+	return time.Now(), nil
+}
+
+func (s *fakeStore) ListDueGuestReminders(ctx context.Context, startsBefore, failedBefore time.Time, maxAttempts, limit int) ([]*models.GuestInvitation, error) {
+	return s.due, nil
+}
+
+func (s *fakeStore) MarkGuestReminderSent(ctx context.Context, id uuid.UUID) error {
+	s.reminded = append(s.reminded, id)
+	return nil
+}
+
+func (s *fakeStore) MarkGuestReminderFailed(ctx context.Context, id uuid.UUID) error {
+	s.failed = append(s.failed, id)
+	return nil
+}
+
+type fakeNotifier struct {
+	sent []Notification
+	err  error
+}
+
+func (n *fakeNotifier) Notify(ctx context.Context, notification Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func newTestInvitation(email string, status models.InvitationStatus) *models.GuestInvitation {
+	invitation := &models.GuestInvitation{
+		GuestName:     "Ada",
+		EventTitle:    "Episode 12",
+		StartDatetime: time.Now().Add(2 * time.Hour),
+		EndDatetime:   time.Now().Add(3 * time.Hour),
+		EventStatus:   models.EventStatusScheduled,
+	}
+	invitation.ID = uuid.New()
+	invitation.InvitationStatus = status
+	if email != "" {
+		invitation.GuestContacts = []models.GuestContact{
+			{Type: models.ContactTypeEmail, Value: email},
+		}
+	}
+	return invitation
+}
+
+func TestSendDueReminders(t *testing.T) {
+	store := &fakeStore{due: []*models.GuestInvitation{
+		newTestInvitation("ada@example.com", models.InvitationStatusInvited),
+		newTestInvitation("grace@example.com", models.InvitationStatusConfirmed),
+		newTestInvitation("", models.InvitationStatusInvited),
+	}}
+	notifier := &fakeNotifier{}
+	service := NewService(store, newTestSigner(t, 'a'), notifier, &config.InvitationConfig{ReminderLeadTime: 24 * time.Hour}, "https://app.example.com")
+
+	sent, err := service.SendDueReminders(context.Background())
+	if err != nil {
+		t.Fatalf("SendDueReminders() error = %v", err)
+	}
+	if sent != 2 {
+		t.Errorf("SendDueReminders() = %d, want 2", sent)
+	}
+	if len(store.reminded) != 3 {
+		t.Errorf("%d bookings marked reminded, want all 3 including the one without email", len(store.reminded))
+	}
+	if len(notifier.sent) == 2 && (notifier.sent[0].Confirmed || !notifier.sent[1].Confirmed) {
+		t.Error("reminders don't tell confirmed guests apart")
+	}
+}
+
+func TestSendDueRemindersRetriesFailedDeliveries(t *testing.T) {
+	store := &fakeStore{due: []*models.GuestInvitation{
+		newTestInvitation("ada@example.com", models.InvitationStatusInvited),
+	}}
+	notifier := &fakeNotifier{err: errors.New("smtp down")}
+	service := NewService(store, newTestSigner(t, 'a'), notifier, &config.InvitationConfig{ReminderLeadTime: time.Hour}, "")
+
+	if _, err := service.SendDueReminders(context.Background()); err != nil {
+		t.Fatalf("SendDueReminders() error = %v", err)
+	}
+	if len(store.reminded) != 0 {
+		t.Error("a failed reminder was marked sent")
+	}
+	if len(store.failed) != 1 || store.failed[0] != store.due[0].ID {
+		t.Errorf("failed attempts = %v, want the failed reminder", store.failed)
+	}
+}
+
+func TestRespond(t *testing.T) {
+	invitation := newTestInvitation("ada@example.com", models.InvitationStatusInvited)
+	store := &fakeStore{due: []*models.GuestInvitation{invitation}}
+	service := NewService(store, newTestSigner(t, 'a'), &fakeNotifier{}, &config.InvitationConfig{}, "")
+	token := service.signer.Sign(invitation.ID, invitation.EndDatetime)
+
+	if _, err := service.Respond(context.Background(), token, models.InvitationStatusNoShow); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Respond(no_show) error = %v, want ErrInvalidResponse", err)
+	}
+
+	got, err := service.Respond(context.Background(), token, models.InvitationStatusConfirmed)
+	if err != nil {
+		t.Fatalf("Respond() error = %v", err)
+	}
+	if got.InvitationStatus != models.InvitationStatusConfirmed || got.RespondedAt == nil {
+		t.Errorf("Respond() = %s, want a confirmed response", got.InvitationStatus)
+	}
+
+	invitation.EventStatus = models.EventStatusCancelled
+	if _, err := service.Respond(context.Background(), token, models.InvitationStatusDeclined); !errors.Is(err, ErrEventClosed) {
+		t.Errorf("Respond() to a cancelled event error = %v, want ErrEventClosed", err)
+	}
+}
@@ -0,0 +1,105 @@
+package invitation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/services/mail"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// Kinds of guest notifications
+const (
+	NotificationInvitation = "invitation"
+	NotificationReminder   = "reminder"
+)
+
+// Notification tells a guest about an appearance
+type Notification struct {
+	Kind          string
+	To            string // email address of the guest
+	GuestName     string
+	EventTitle    string
+	ShowName      string
+	BlockTitle    string
+	StartDatetime time.Time
+	// Confirmed is set on reminders to guests who already confirmed
+	Confirmed bool
+	RSVPURL   string
+}
+
+// Notifier delivers guest notifications
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NewNotifier creates the notifier selected by the configuration
+func NewNotifier(driver string, sender mail.Sender) (Notifier, error) {
+	switch driver {
+	case "mail":
+		return &MailNotifier{sender: sender}, nil
+	case "log":
+		return &LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported invitation notifier: %s", driver)
+	}
+}
+
+// MailNotifier emails guests
+type MailNotifier struct {
+	sender mail.Sender
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, notification Notification) error {
+	when := notification.StartDatetime.UTC().Format("Monday, 2 January 2006 at 15:04 UTC")
+
+	var subject, body string
+	switch {
+	case notification.Kind == NotificationInvitation:
+		subject = "You're invited to " + notification.EventTitle
+		body = fmt.Sprintf("Hello %s,\n\n"+
+			"You're invited to appear on %s (%s) in the segment \"%s\", on %s.\n\n"+
+			"Please let us know whether you can make it:\n\n"+
+			"%s\n",
+			notification.GuestName, notification.EventTitle, notification.ShowName,
+			notification.BlockTitle, when, notification.RSVPURL)
+	case notification.Confirmed:
+		subject = "Reminder: " + notification.EventTitle
+		body = fmt.Sprintf("Hello %s,\n\n"+
+			"Thanks for confirming. This is a reminder that you're appearing on %s (%s) "+
+			"in the segment \"%s\", on %s.\n\n"+
+			"If your plans changed, please let us know:\n\n"+
+			"%s\n",
+			notification.GuestName, notification.EventTitle, notification.ShowName,
+			notification.BlockTitle, when, notification.RSVPURL)
+	default:
+		subject = "Please confirm: " + notification.EventTitle
+		body = fmt.Sprintf("Hello %s,\n\n"+
+			"%s (%s) is coming up on %s and we haven't heard from you yet about "+
+			"the segment \"%s\".\n\n"+
+			"Please let us know whether you can make it:\n\n"+
+			"%s\n",
+			notification.GuestName, notification.EventTitle, notification.ShowName,
+			when, notification.BlockTitle, notification.RSVPURL)
+	}
+
+	return n.sender.Send(ctx, mail.Message{
+		To:      notification.To,
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// LogNotifier writes notifications to the log, for local testing
+type LogNotifier struct{}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	utils.LogInfo(ctx, "Guest notification not sent, logged instead", utils.Fields{
+		"kind":     notification.Kind,
+		"to":       notification.To,
+		"event":    notification.EventTitle,
+		"rsvp_url": notification.RSVPURL,
+	})
+	return nil
+}
@@ -0,0 +1,69 @@
+package invitation
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestSigner(t *testing.T, fill byte) *Signer {
+	t.Helper()
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(fill)), 32)))
+	signer, err := NewSigner(key)
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	return signer
+}
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := newTestSigner(t, 'a')
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	bookingID := uuid.New()
+
+	token := signer.Sign(bookingID, now.Add(time.Hour))
+
+	got, err := signer.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got != bookingID {
+		t.Errorf("Verify() = %s, want %s", got, bookingID)
+	}
+
+	if _, err := signer.Verify(token, now.Add(time.Hour)); !errors.Is(err, ErrExpiredLink) {
+		t.Errorf("Verify() after expiry error = %v, want ErrExpiredLink", err)
+	}
+}
+
+func TestSignerRejectsForgedTokens(t *testing.T) {
+	signer := newTestSigner(t, 'a')
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	token := signer.Sign(uuid.New(), now.Add(time.Hour))
+
+	payload, mac, _ := strings.Cut(token, ".")
+	other := signer.Sign(uuid.New(), now.Add(time.Hour))
+	otherPayload, _, _ := strings.Cut(other, ".")
+
+	tests := map[string]string{
+		"other key":       newTestSigner(t, 'b').Sign(uuid.New(), now.Add(time.Hour)),
+		"swapped payload": otherPayload + "." + mac,
+		"no signature":    payload,
+		"garbage":         "not-a-token",
+	}
+	for name, forged := range tests {
+		if _, err := signer.Verify(forged, now); !errors.Is(err, ErrInvalidLink) {
+			t.Errorf("Verify(%s) error = %v, want ErrInvalidLink", name, err)
+		}
+	}
+}
+
+func TestNewSignerRejectsShortKeys(t *testing.T) {
+	if _, err := NewSigner(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Error("NewSigner() accepted a 5 byte key")
+	}
+}
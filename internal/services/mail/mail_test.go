@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender, err := NewSender(&config.MailConfig{
+		Driver:  "file",
+		From:    "St. Planer <no-reply@example.com>",
+		FileDir: dir,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sender: %v", err)
+	}
+
+	body := "Hello Zoë,\n\nOpen https://app.example.com/reset-password?token=" + strings.Repeat("a", 64) + " to continue.\n"
+	err = sender.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Réinitialiser le mot de passe",
+		Body:    body,
+	})
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v (%v)", files, err)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatalf("Failed to open mail file: %v", err)
+	}
+	defer f.Close()
+
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("Failed to parse mail file: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Réinitialiser le mot de passe" {
+		t.Errorf("Unexpected subject %q (%v)", subject, err)
+	}
+	if to := parsed.Header.Get("To"); to != "<user@example.com>" {
+		t.Errorf("Unexpected recipient %q", to)
+	}
+	if _, err := parsed.Header.Date(); err != nil {
+		t.Errorf("Invalid date header: %v", err)
+	}
+
+	decoded, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatalf("Failed to decode body: %v", err)
+	}
+	if got := strings.ReplaceAll(string(decoded), "\r\n", "\n"); got != body {
+		t.Errorf("Body changed in transit:\n%q\n%q", got, body)
+	}
+}
+
+func TestBuildMessageRejectsHeaderInjection(t *testing.T) {
+	from := &mail.Address{Address: "no-reply@example.com"}
+	to := &mail.Address{Address: "user@example.com"}
+
+	_, err := buildMessage(from, to, Message{Subject: "Hi\r\nBcc: victim@example.com"}, time.Now())
+	if err == nil {
+		t.Error("Expected subject with line breaks to be rejected")
+	}
+}
@@ -0,0 +1,133 @@
+package quota
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+// Service resolves and enforces per-user media storage quotas.
+// A quota set on the user wins over role quotas, which win over the configured default.
+type Service struct {
+	db     *database.PostgresDB
+	config *config.DownloadConfig
+}
+
+func NewService(db *database.PostgresDB, cfg *config.DownloadConfig) *Service {
+	return &Service{
+		db:     db,
+		config: cfg,
+	}
+}
+
+// GetUsage returns the storage used by a user and the limits that apply to them
+func (s *Service) GetUsage(ctx context.Context, userID uuid.UUID) (*models.MediaUsage, error) {
+	usedBytes, usedFiles, err := s.db.GetMediaUsage(ctx, userID)
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	usage := &models.MediaUsage{
+		UserID:    userID,
+		UsedBytes: usedBytes,
+		UsedFiles: usedFiles,
+	}
+
+	quota, err := s.db.GetUserMediaQuota(ctx, userID)
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+	usage.QuotaSource = models.MediaQuotaSourceUser
+
+	if quota == nil {
+		quota, err = s.db.GetRoleMediaQuota(ctx, userID)
+		if err != nil {
+			return nil, utils.NewDatabaseError(err)
+		}
+		usage.QuotaSource = models.MediaQuotaSourceRole
+	}
+
+	if quota == nil {
+		quota = &models.MediaQuota{}
+		if s.config.UserQuotaBytes > 0 {
+			quota.MaxBytes = &s.config.UserQuotaBytes
+		}
+		if s.config.UserQuotaFiles > 0 {
+			quota.MaxFiles = &s.config.UserQuotaFiles
+		}
+		usage.QuotaSource = models.MediaQuotaSourceDefault
+	}
+
+	if quota.MaxBytes != nil {
+		maxBytes := *quota.MaxBytes
+		remaining := maxBytes - usedBytes
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.MaxBytes = &maxBytes
+		usage.RemainingBytes = &remaining
+	}
+	if quota.MaxFiles != nil {
+		maxFiles := *quota.MaxFiles
+		remaining := maxFiles - usedFiles
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.MaxFiles = &maxFiles
+		usage.RemainingFiles = &remaining
+	}
+
+	return usage, nil
+}
+
+// CheckQuota returns a quota error when storing one more file of the given size
+// would exceed the user's limits. A size of 0 only checks that the user has room left.
+func (s *Service) CheckQuota(ctx context.Context, userID uuid.UUID, fileSize int64) error {
+	usage, err := s.GetUsage(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return checkUsage(usage, fileSize)
+}
+
+func checkUsage(usage *models.MediaUsage, fileSize int64) error {
+	exceeded := false
+	if usage.MaxFiles != nil && usage.UsedFiles+1 > *usage.MaxFiles {
+		exceeded = true
+	}
+	if usage.MaxBytes != nil {
+		if fileSize > 0 && usage.UsedBytes+fileSize > *usage.MaxBytes {
+			exceeded = true
+		}
+		if usage.UsedBytes >= *usage.MaxBytes {
+			exceeded = true
+		}
+	}
+
+	if !exceeded {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"used_bytes":   usage.UsedBytes,
+		"used_files":   usage.UsedFiles,
+		"quota_source": usage.QuotaSource,
+	}
+	if usage.MaxBytes != nil {
+		details["max_bytes"] = *usage.MaxBytes
+	}
+	if usage.MaxFiles != nil {
+		details["max_files"] = *usage.MaxFiles
+	}
+	if fileSize > 0 {
+		details["file_size"] = fileSize
+	}
+
+	return utils.NewQuotaExceededError(details)
+}
@@ -0,0 +1,74 @@
+package quota
+
+import (
+	"testing"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
+)
+
+func TestCheckUsage(t *testing.T) {
+	maxBytes := int64(1000)
+	maxFiles := 3
+
+	testCases := []struct {
+		name        string
+		usage       models.MediaUsage
+		fileSize    int64
+		expectError bool
+	}{
+		{
+			name:        "Unlimited",
+			usage:       models.MediaUsage{UsedBytes: 1 << 40, UsedFiles: 100000},
+			fileSize:    1 << 30,
+			expectError: false,
+		},
+		{
+			name:        "Fits within byte limit",
+			usage:       models.MediaUsage{UsedBytes: 500, MaxBytes: &maxBytes},
+			fileSize:    500,
+			expectError: false,
+		},
+		{
+			name:        "File larger than remaining bytes",
+			usage:       models.MediaUsage{UsedBytes: 500, MaxBytes: &maxBytes},
+			fileSize:    501,
+			expectError: true,
+		},
+		{
+			name:        "Unknown size with room left",
+			usage:       models.MediaUsage{UsedBytes: 999, MaxBytes: &maxBytes},
+			fileSize:    0,
+			expectError: false,
+		},
+		{
+			name:        "Unknown size with byte limit reached",
+			usage:       models.MediaUsage{UsedBytes: 1000, MaxBytes: &maxBytes},
+			fileSize:    0,
+			expectError: true,
+		},
+		{
+			name:        "File limit reached",
+			usage:       models.MediaUsage{UsedFiles: 3, MaxFiles: &maxFiles},
+			fileSize:    1,
+			expectError: true,
+		},
+		{
+			name:        "Last file within file limit",
+			usage:       models.MediaUsage{UsedFiles: 2, MaxFiles: &maxFiles},
+			fileSize:    1,
+			expectError: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkUsage(&tc.usage, tc.fileSize)
+			if tc.expectError && err == nil {
+				t.Error("Expected quota error")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
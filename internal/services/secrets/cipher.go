@@ -0,0 +1,177 @@
+package secrets
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// envelopePrefix marks values encrypted by a Cipher. Values without it are legacy plain text.
+const envelopePrefix = "enc:v1:"
+
+// maxCachedKeys bounds how many unwrapped data keys a Cipher keeps in memory
+const maxCachedKeys = 1024
+
+// Cipher encrypts values with envelope encryption: each value is sealed with a data key,
+// and the data key, wrapped by the provider's master key, is stored alongside it as
+// enc:v1:<master key ID>:<wrapped data key>:<nonce and ciphertext>.
+type Cipher struct {
+	provider KeyProvider
+
+	mu      sync.Mutex
+	current *dataKeyCipher
+	cache   map[string]cipher.AEAD
+}
+
+type dataKeyCipher struct {
+	keyID   string
+	wrapped string
+	aead    cipher.AEAD
+}
+
+func NewCipher(provider KeyProvider) *Cipher {
+	return &Cipher{
+		provider: provider,
+		cache:    make(map[string]cipher.AEAD),
+	}
+}
+
+// IsEncrypted reports whether the value was produced by Encrypt
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// Encrypt seals a value. Empty values stay empty so optional fields keep their meaning.
+func (c *Cipher) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	key, err := c.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(key.keyID))
+
+	return envelopePrefix + key.keyID + ":" + key.wrapped + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt. Values that aren't encrypted are returned as
+// they are, so rows written before encryption was enabled stay readable.
+func (c *Cipher) Decrypt(ctx context.Context, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, envelopePrefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	keyID, wrapped := parts[0], parts[1]
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	aead, err := c.dataKey(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(keyID))
+	if err != nil {
+		return "", ErrMalformed
+	}
+
+	return string(plaintext), nil
+}
+
+// currentKey returns the data key new values are sealed with, generating it on first use.
+// One data key per process keeps the provider out of the write path.
+func (c *Cipher) currentKey(ctx context.Context) (*dataKeyCipher, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil {
+		return c.current, nil
+	}
+
+	dataKey, err := c.provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey.Plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	c.current = &dataKeyCipher{
+		keyID:   dataKey.KeyID,
+		wrapped: base64.StdEncoding.EncodeToString(dataKey.Wrapped),
+		aead:    aead,
+	}
+	c.cache[dataKey.KeyID+":"+c.current.wrapped] = aead
+
+	return c.current, nil
+}
+
+// dataKey returns the cipher for a wrapped data key, asking the provider to unwrap it
+// the first time it is seen
+func (c *Cipher) dataKey(ctx context.Context, keyID, wrapped string) (cipher.AEAD, error) {
+	cacheKey := keyID + ":" + wrapped
+
+	c.mu.Lock()
+	aead, ok := c.cache[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	plaintext, err := c.provider.DecryptDataKey(ctx, keyID, wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key: %w", err)
+	}
+	aead, err = newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= maxCachedKeys {
+		c.cache = make(map[string]cipher.AEAD)
+	}
+	c.cache[cacheKey] = aead
+	c.mu.Unlock()
+
+	return aead, nil
+}
+
+// Mask hides a secret for display. Only long values such as stream keys keep their
+// last four characters; short ones such as passcodes are hidden completely.
+func Mask(value string) string {
+	if value == "" {
+		return ""
+	}
+	if len(value) < 12 {
+		return "********"
+	}
+	return "********" + value[len(value)-4:]
+}
@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, keyID string) *Cipher {
+	t.Helper()
+	provider, err := NewLocalKeyProvider(keyID, bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider() error = %v", err)
+	}
+	return NewCipher(provider)
+}
+
+func TestCipherRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, "local-1")
+
+	encrypted, err := c.Encrypt(ctx, "abcd-efgh-ijkl-mnop")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "abcd-efgh") {
+		t.Fatalf("Encrypt() = %q, want an envelope without the plain text", encrypted)
+	}
+
+	again, _ := c.Encrypt(ctx, "abcd-efgh-ijkl-mnop")
+	if again == encrypted {
+		t.Error("Encrypt() reused a nonce for the same value")
+	}
+
+	// A fresh cipher has to unwrap the data key through the provider
+	decrypted, err := newTestCipher(t, "local-1").Decrypt(ctx, encrypted)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if decrypted != "abcd-efgh-ijkl-mnop" {
+		t.Errorf("Decrypt() = %q, want the original value", decrypted)
+	}
+}
+
+func TestCipherPassesThroughPlainText(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, "local-1")
+
+	if got, err := c.Decrypt(ctx, "legacy-key"); err != nil || got != "legacy-key" {
+		t.Errorf("Decrypt(legacy) = %q, %v, want it unchanged", got, err)
+	}
+	if got, err := c.Encrypt(ctx, ""); err != nil || got != "" {
+		t.Errorf("Encrypt(\"\") = %q, %v, want empty", got, err)
+	}
+}
+
+func TestCipherRejectsTamperingAndUnknownKeys(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, "local-1")
+
+	encrypted, err := c.Encrypt(ctx, "123456")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	tampered := encrypted[:len(encrypted)-4] + "AAA="
+	if _, err := c.Decrypt(ctx, tampered); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Decrypt(tampered) error = %v, want ErrMalformed", err)
+	}
+
+	if _, err := newTestCipher(t, "local-2").Decrypt(ctx, encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Decrypt() with another master key error = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"123456":              "********",
+		"abcd-efgh-ijkl-mnop": "********mnop",
+	}
+	for value, want := range tests {
+		if got := Mask(value); got != want {
+			t.Errorf("Mask(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
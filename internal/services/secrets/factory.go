@@ -0,0 +1,22 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+)
+
+// NewKeyProvider creates the key provider selected in the configuration
+func NewKeyProvider(cfg *config.SecretsConfig) (KeyProvider, error) {
+	switch cfg.Provider {
+	case "local":
+		masterKey, err := base64.StdEncoding.DecodeString(cfg.MasterKey)
+		if err != nil {
+			return nil, fmt.Errorf("master key is not valid base64: %w", err)
+		}
+		return NewLocalKeyProvider(cfg.KeyID, masterKey)
+	default:
+		return nil, fmt.Errorf("unsupported secrets provider %q", cfg.Provider)
+	}
+}
@@ -0,0 +1,29 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrUnknownKey is returned when a data key was wrapped with a master key the provider doesn't hold
+	ErrUnknownKey = errors.New("unknown master key")
+	// ErrMalformed is returned when an encrypted value can't be parsed or fails authentication
+	ErrMalformed = errors.New("malformed encrypted value")
+)
+
+// DataKey is a data encryption key, both in plain text and wrapped by a master key
+type DataKey struct {
+	KeyID     string
+	Plaintext []byte
+	Wrapped   []byte
+}
+
+// KeyProvider holds the master keys and wraps and unwraps data keys with them, in the
+// style of a cloud KMS. The master keys never leave the provider.
+type KeyProvider interface {
+	// GenerateDataKey returns a new 256-bit data key wrapped by the current master key
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	// DecryptDataKey unwraps a data key wrapped by the master key with the given ID
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// LocalKeyProvider wraps data keys with an AES-256-GCM master key from the configuration
+type LocalKeyProvider struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+func NewLocalKeyProvider(keyID string, masterKey []byte) (*LocalKeyProvider, error) {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid master key ID %q", keyID)
+	}
+	if len(masterKey) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(masterKey))
+	}
+
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalKeyProvider{
+		keyID: keyID,
+		aead:  aead,
+	}, nil
+}
+
+func (p *LocalKeyProvider) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	plaintext := make([]byte, 32)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &DataKey{
+		KeyID:     p.keyID,
+		Plaintext: plaintext,
+		Wrapped:   p.aead.Seal(nonce, nonce, plaintext, []byte(p.keyID)),
+	}, nil
+}
+
+func (p *LocalKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.keyID {
+		return nil, ErrUnknownKey
+	}
+
+	nonceSize := p.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := p.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], []byte(keyID))
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -13,3 +13,9 @@ type TelegramClient interface {
 	DownloadMedia(ctx context.Context, channelName string, messageID int64, mediaInfo MediaInfo) (io.ReadCloser, error)
 	Close() error
 }
+
+// ProfilePhotoFetcher is implemented by clients that can fetch the public profile photo of
+// a Telegram user, group or channel by its username
+type ProfilePhotoFetcher interface {
+	GetProfilePhoto(ctx context.Context, username string) (io.ReadCloser, error)
+}
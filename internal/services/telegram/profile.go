@@ -0,0 +1,84 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrInvalidUsername is returned for values that aren't a Telegram username or t.me link
+	ErrInvalidUsername = errors.New("not a valid Telegram username")
+	// ErrNoProfilePhoto is returned when the profile is private, has no photo or doesn't exist
+	ErrNoProfilePhoto = errors.New("telegram profile has no public photo")
+)
+
+var (
+	usernameRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+	ogImageRegex  = regexp.MustCompile(`<meta property="og:image" content="([^"]+)"`)
+)
+
+// ParseUsername extracts the username from "@name", "name", "t.me/name" or
+// "https://t.me/name"
+func ParseUsername(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "t.me/") || strings.Contains(value, "telegram.me/") {
+		if !strings.Contains(value, "://") {
+			value = "https://" + value
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Host != "t.me" && u.Host != "telegram.me") {
+			return "", ErrInvalidUsername
+		}
+		value = strings.Trim(u.Path, "/")
+	}
+	value = strings.TrimPrefix(value, "@")
+
+	if !usernameRegex.MatchString(value) {
+		return "", ErrInvalidUsername
+	}
+	return value, nil
+}
+
+// GetProfilePhoto downloads the profile photo shown on the public t.me page of a username
+func (c *WebScraperClient) GetProfilePhoto(ctx context.Context, username string) (io.ReadCloser, error) {
+	username, err := ParseUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://t.me/"+username, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch profile: status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	// Profiles without a public photo, and unknown usernames, show the Telegram logo
+	matches := ogImageRegex.FindStringSubmatch(string(body))
+	if len(matches) < 2 || strings.Contains(matches[1], "telegram.org/img/") {
+		return nil, ErrNoProfilePhoto
+	}
+
+	return c.downloadMediaFromURL(ctx, html.UnescapeString(matches[1]))
+}
@@ -0,0 +1,498 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/denisAlshanov/stPlaner/internal/config"
+	"github.com/denisAlshanov/stPlaner/internal/database"
+	"github.com/denisAlshanov/stPlaner/internal/models"
+	"github.com/denisAlshanov/stPlaner/internal/services/quota"
+	"github.com/denisAlshanov/stPlaner/internal/services/storage"
+	"github.com/denisAlshanov/stPlaner/internal/utils"
+)
+
+const (
+	// S3 requires every part except the last one to be at least 5MB
+	minChunkSize = 5 * 1024 * 1024
+	// Chunks are buffered in memory before they go to storage, so they're kept well below
+	// the 5GB S3 allows per part
+	maxChunkSize = 64 * 1024 * 1024
+	// S3 allows at most 10,000 parts per multipart upload
+	maxChunks = 10000
+)
+
+type Uploader struct {
+	db          *database.PostgresDB
+	storage     storage.MultipartStorageInterface
+	quota       *quota.Service
+	config      *config.UploadConfig
+	maxFileSize int64
+}
+
+func NewUploader(db *database.PostgresDB, storage storage.MultipartStorageInterface, quota *quota.Service, cfg *config.UploadConfig, maxFileSize int64) *Uploader {
+	return &Uploader{
+		db:          db,
+		storage:     storage,
+		quota:       quota,
+		config:      cfg,
+		maxFileSize: maxFileSize,
+	}
+}
+
+// InitiateUpload opens a multipart upload in storage and records the upload session
+func (u *Uploader) InitiateUpload(ctx context.Context, userID uuid.UUID, req *models.InitiateUploadRequest) (*models.MediaUpload, error) {
+	if u.maxFileSize > 0 && req.FileSize > u.maxFileSize {
+		return nil, utils.NewFileTooLargeError(req.FileSize, u.maxFileSize)
+	}
+
+	if err := u.quota.CheckQuota(ctx, userID, req.FileSize); err != nil {
+		return nil, err
+	}
+
+	chunkSize := req.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = u.config.ChunkSize
+	}
+	if chunkSize > req.FileSize {
+		chunkSize = req.FileSize
+	}
+	if chunkSize < req.FileSize && chunkSize < minChunkSize {
+		return nil, utils.NewValidationError("Chunk size is too small", map[string]interface{}{
+			"chunk_size":     chunkSize,
+			"min_chunk_size": minChunkSize,
+		})
+	}
+	if chunkSize > maxChunkSize {
+		return nil, utils.NewValidationError("Chunk size is too large", map[string]interface{}{
+			"chunk_size":     chunkSize,
+			"max_chunk_size": maxChunkSize,
+		})
+	}
+
+	totalChunks := int((req.FileSize + chunkSize - 1) / chunkSize)
+	if totalChunks > maxChunks {
+		return nil, utils.NewValidationError("Too many chunks, increase the chunk size", map[string]interface{}{
+			"total_chunks": totalChunks,
+			"max_chunks":   maxChunks,
+		})
+	}
+
+	fileName := sanitizeFileName(req.FileName)
+	uploadKey := uuid.New().String()
+	s3Key := fmt.Sprintf("%s/%s/%s", models.UploadContentID, uploadKey, fileName)
+
+	storageUploadID, err := u.storage.InitiateMultipartUpload(ctx, s3Key, req.FileType)
+	if err != nil {
+		return nil, utils.NewS3Error(err)
+	}
+
+	upload := &models.MediaUpload{
+		UserID:          userID,
+		FileName:        req.FileName,
+		FileType:        req.FileType,
+		FileSize:        req.FileSize,
+		ChunkSize:       chunkSize,
+		TotalChunks:     totalChunks,
+		S3Key:           s3Key,
+		StorageUploadID: storageUploadID,
+		Status:          models.UploadStatusInProgress,
+		ExpiresAt:       time.Now().Add(u.config.SessionTTL),
+		ReceivedChunks:  []int{},
+	}
+
+	if err := u.db.CreateMediaUpload(ctx, upload); err != nil {
+		u.storage.AbortMultipartUpload(ctx, s3Key, storageUploadID)
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	utils.LogInfo(ctx, "Media upload initiated", utils.Fields{
+		"upload_id":    upload.ID,
+		"file_name":    upload.FileName,
+		"file_size":    upload.FileSize,
+		"total_chunks": upload.TotalChunks,
+	})
+
+	return upload, nil
+}
+
+// GetUpload returns an upload session owned by the user
+func (u *Uploader) GetUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.MediaUpload, error) {
+	upload, err := u.db.GetMediaUpload(ctx, uploadID)
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+	if upload == nil || upload.UserID != userID {
+		return nil, utils.NewUploadNotFoundError(uploadID.String())
+	}
+
+	return upload, nil
+}
+
+// UploadChunk stores a single chunk of the upload. Chunks may arrive in any order and
+// re-sending a chunk replaces the previous copy, which makes interrupted uploads resumable.
+func (u *Uploader) UploadChunk(ctx context.Context, userID, uploadID uuid.UUID, chunkNumber int, data io.Reader) (*models.MediaUpload, error) {
+	upload, err := u.getActiveUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunkNumber < 1 || chunkNumber > upload.TotalChunks {
+		return nil, utils.NewValidationError("Chunk number out of range", map[string]interface{}{
+			"chunk_number": chunkNumber,
+			"total_chunks": upload.TotalChunks,
+		})
+	}
+
+	expectedSize := upload.ChunkSize
+	if chunkNumber == upload.TotalChunks {
+		expectedSize = upload.FileSize - int64(upload.TotalChunks-1)*upload.ChunkSize
+	}
+	if expectedSize > maxChunkSize {
+		return nil, utils.NewValidationError("Chunk size is too large", map[string]interface{}{
+			"chunk_size":     expectedSize,
+			"max_chunk_size": maxChunkSize,
+		})
+	}
+
+	// Read one byte more than expected so oversized chunks are detected
+	buf := new(bytes.Buffer)
+	size, err := io.Copy(buf, io.LimitReader(data, expectedSize+1))
+	if err != nil {
+		return nil, utils.NewValidationError("Failed to read chunk data", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if size != expectedSize {
+		return nil, utils.NewValidationError("Chunk size does not match the upload session", map[string]interface{}{
+			"chunk_number":  chunkNumber,
+			"expected_size": expectedSize,
+			"received_size": size,
+		})
+	}
+
+	part, err := u.storage.UploadPart(ctx, upload.S3Key, upload.StorageUploadID, int32(chunkNumber), buf)
+	if err != nil {
+		return nil, utils.NewS3Error(err)
+	}
+
+	uploadPart := &models.MediaUploadPart{
+		UploadID:   upload.ID,
+		PartNumber: chunkNumber,
+		ETag:       stringValue(part.ETag),
+		Size:       size,
+	}
+	if err := u.db.SaveMediaUploadPart(ctx, uploadPart); err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	return u.GetUpload(ctx, userID, uploadID)
+}
+
+// CompleteUpload assembles the uploaded chunks and registers the file as media under the
+// synthetic upload post. Files whose content hash matches existing media are deduplicated.
+func (u *Uploader) CompleteUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.CompleteUploadData, error) {
+	upload, err := u.getActiveUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := u.db.GetMediaUploadParts(ctx, upload.ID)
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	if missing := missingChunks(parts, upload.TotalChunks); len(missing) > 0 {
+		return nil, utils.NewValidationError("Upload is missing chunks", map[string]interface{}{
+			"missing_chunks": missing,
+		})
+	}
+
+	// Other uploads may have used up the quota while this one was in progress.
+	// The session stays open so the upload can be completed once space is freed.
+	if err := u.quota.CheckQuota(ctx, userID, upload.FileSize); err != nil {
+		return nil, err
+	}
+
+	completedParts := make([]storage.CompletedPart, len(parts))
+	for i, part := range parts {
+		etag := part.ETag
+		partNumber := int32(part.PartNumber)
+		completedParts[i] = storage.CompletedPart{
+			ETag:       &etag,
+			PartNumber: &partNumber,
+		}
+	}
+
+	if err := u.storage.CompleteMultipartUpload(ctx, upload.S3Key, upload.StorageUploadID, completedParts); err != nil {
+		return nil, utils.NewS3Error(err)
+	}
+
+	// Parts may arrive out of order, so the hash is calculated over the assembled object
+	hash, err := u.hashObject(ctx, upload.S3Key)
+	if err != nil {
+		return nil, utils.NewS3Error(err)
+	}
+
+	// Check if the same file already exists (deduplication by hash). Only media the
+	// user can already see is reused, otherwise the upload would be unreachable for them.
+	existingMedia, err := u.db.GetMediaByHash(ctx, hash)
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+	if existingMedia != nil {
+		visible, err := u.db.CanAccessMedia(ctx, models.MediaScope{UserID: userID}, existingMedia.ID)
+		if err != nil {
+			return nil, utils.NewDatabaseError(err)
+		}
+		if !visible {
+			existingMedia = nil
+		}
+	}
+	if existingMedia != nil {
+		if err := u.storage.Delete(ctx, upload.S3Key); err != nil {
+			utils.LogWarn(ctx, "Failed to delete duplicate upload from S3", utils.Fields{
+				"s3_key": upload.S3Key,
+				"error":  err.Error(),
+			})
+		}
+
+		if err := u.db.UpdateMediaUploadStatus(ctx, upload.ID, models.UploadStatusCompleted, &existingMedia.MediaID); err != nil {
+			return nil, utils.NewDatabaseError(err)
+		}
+
+		upload, err = u.GetUpload(ctx, userID, uploadID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.CompleteUploadData{
+			Upload:       upload,
+			Media:        existingMedia,
+			Deduplicated: true,
+		}, nil
+	}
+
+	post, err := u.db.EnsurePost(ctx, &models.Post{
+		ContentID:           models.UploadContentID,
+		TelegramLink:        "upload://" + models.UploadContentID,
+		ChannelName:         models.UploadContentID,
+		OriginalChannelName: models.UploadContentID,
+		MessageID:           0,
+		Status:              models.PostStatusCompleted,
+	})
+	if err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	media := &models.Media{
+		MediaID:          generateMediaID(post.ContentID, upload.ID.String()),
+		ContentID:        post.ContentID,
+		TelegramFileID:   upload.ID.String(), // Store upload ID here
+		FileName:         filepath.Base(upload.S3Key),
+		OriginalFileName: upload.FileName,
+		FileType:         upload.FileType,
+		FileSize:         upload.FileSize,
+		S3Bucket:         u.storage.BucketName(),
+		S3Key:            upload.S3Key,
+		FileHash:         hash,
+		DownloadedAt:     time.Now(),
+		Metadata: map[string]interface{}{
+			"platform":    "upload",
+			"type":        mediaType(upload.FileType),
+			"upload_id":   upload.ID.String(),
+			"uploaded_by": upload.UserID.String(),
+		},
+		UserID: &upload.UserID,
+	}
+
+	if err := u.db.CreateMedia(ctx, media); err != nil {
+		// Try to clean up S3
+		u.storage.Delete(ctx, upload.S3Key)
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	if err := u.db.AddPostMediaStats(ctx, post.ContentID, 1, media.FileSize); err != nil {
+		utils.LogError(ctx, "Failed to update upload post stats", err)
+	}
+
+	if err := u.db.UpdateMediaUploadStatus(ctx, upload.ID, models.UploadStatusCompleted, &media.MediaID); err != nil {
+		return nil, utils.NewDatabaseError(err)
+	}
+
+	upload, err = u.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(ctx, "Media upload completed", utils.Fields{
+		"upload_id": upload.ID,
+		"media_id":  media.MediaID,
+		"file_size": media.FileSize,
+	})
+
+	return &models.CompleteUploadData{
+		Upload: upload,
+		Media:  media,
+	}, nil
+}
+
+// AbortUpload discards an in-progress upload and the chunks stored so far
+func (u *Uploader) AbortUpload(ctx context.Context, userID, uploadID uuid.UUID) error {
+	upload, err := u.getActiveUpload(ctx, userID, uploadID)
+	if err != nil {
+		return err
+	}
+
+	if err := u.storage.AbortMultipartUpload(ctx, upload.S3Key, upload.StorageUploadID); err != nil {
+		return utils.NewS3Error(err)
+	}
+
+	if err := u.db.UpdateMediaUploadStatus(ctx, upload.ID, models.UploadStatusAborted, nil); err != nil {
+		return utils.NewDatabaseError(err)
+	}
+
+	return nil
+}
+
+// CleanupExpiredUploads aborts in-progress uploads whose session has expired
+func (u *Uploader) CleanupExpiredUploads(ctx context.Context) (int, error) {
+	uploads, err := u.db.GetExpiredMediaUploads(ctx, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	cleaned := 0
+	for _, upload := range uploads {
+		if err := u.storage.AbortMultipartUpload(ctx, upload.S3Key, upload.StorageUploadID); err != nil {
+			utils.LogWarn(ctx, "Failed to abort expired multipart upload", utils.Fields{
+				"upload_id": upload.ID,
+				"error":     err.Error(),
+			})
+		}
+		if err := u.db.UpdateMediaUploadStatus(ctx, upload.ID, models.UploadStatusExpired, nil); err != nil {
+			return cleaned, err
+		}
+		cleaned++
+	}
+
+	return cleaned, nil
+}
+
+// StartCleanup periodically expires abandoned uploads until the context is cancelled
+func (u *Uploader) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleaned, err := u.CleanupExpiredUploads(ctx)
+			if err != nil {
+				utils.LogError(ctx, "Failed to clean up expired uploads", err)
+				continue
+			}
+			if cleaned > 0 {
+				utils.LogInfo(ctx, "Expired uploads cleaned up", utils.Fields{
+					"count": cleaned,
+				})
+			}
+		}
+	}
+}
+
+func (u *Uploader) getActiveUpload(ctx context.Context, userID, uploadID uuid.UUID) (*models.MediaUpload, error) {
+	upload, err := u.GetUpload(ctx, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Status == models.UploadStatusInProgress && time.Now().After(upload.ExpiresAt) {
+		return nil, utils.NewUploadClosedError(uploadID.String(), string(models.UploadStatusExpired))
+	}
+	if upload.Status != models.UploadStatusInProgress {
+		return nil, utils.NewUploadClosedError(uploadID.String(), string(upload.Status))
+	}
+
+	return upload, nil
+}
+
+func (u *Uploader) hashObject(ctx context.Context, key string) (string, error) {
+	reader, err := u.storage.Download(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("failed to hash uploaded file: %w", err)
+	}
+
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+func missingChunks(parts []models.MediaUploadPart, totalChunks int) []int {
+	received := make(map[int]bool, len(parts))
+	for _, part := range parts {
+		received[part.PartNumber] = true
+	}
+
+	var missing []int
+	for i := 1; i <= totalChunks; i++ {
+		if !received[i] {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+func mediaType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "photo"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	default:
+		return "document"
+	}
+}
+
+func sanitizeFileName(filename string) string {
+	// Remove or replace invalid characters for file names
+	invalidChars := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
+	sanitized := filename
+	for _, char := range invalidChars {
+		sanitized = strings.ReplaceAll(sanitized, char, "_")
+	}
+
+	// Limit filename length
+	if len(sanitized) > 200 {
+		ext := filepath.Ext(sanitized)
+		base := sanitized[:200-len(ext)]
+		sanitized = base + ext
+	}
+
+	return sanitized
+}
+
+func generateMediaID(contentID, fileID string) string {
+	return fmt.Sprintf("%s_%s", contentID, fileID)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -21,6 +21,13 @@ const (
 	ErrorCodeInternalError     ErrorCode = "INTERNAL_ERROR"
 	ErrorCodeValidationError   ErrorCode = "VALIDATION_ERROR"
 	ErrorCodeDuplicatePost     ErrorCode = "DUPLICATE_POST"
+	ErrorCodeUploadNotFound    ErrorCode = "UPLOAD_NOT_FOUND"
+	ErrorCodeUploadClosed      ErrorCode = "UPLOAD_CLOSED"
+	ErrorCodeFileTooLarge      ErrorCode = "FILE_TOO_LARGE"
+	ErrorCodeShareLinkNotFound ErrorCode = "SHARE_LINK_NOT_FOUND"
+	ErrorCodeShareLinkExpired  ErrorCode = "SHARE_LINK_EXPIRED"
+	ErrorCodeSharePassword     ErrorCode = "SHARE_LINK_PASSWORD_REQUIRED"
+	ErrorCodeQuotaExceeded     ErrorCode = "QUOTA_EXCEEDED"
 )
 
 type AppError struct {
@@ -101,6 +108,73 @@ func NewS3Error(err error) *AppError {
 	)
 }
 
+func NewUploadNotFoundError(uploadID string) *AppError {
+	return NewError(
+		ErrorCodeUploadNotFound,
+		fmt.Sprintf("Upload with ID %s not found", uploadID),
+		http.StatusNotFound,
+	)
+}
+
+func NewUploadClosedError(uploadID string, status string) *AppError {
+	return NewErrorWithDetails(
+		ErrorCodeUploadClosed,
+		fmt.Sprintf("Upload with ID %s is no longer accepting changes", uploadID),
+		http.StatusConflict,
+		map[string]interface{}{
+			"status": status,
+		},
+	)
+}
+
+func NewFileTooLargeError(size, maxSize int64) *AppError {
+	return NewErrorWithDetails(
+		ErrorCodeFileTooLarge,
+		"File exceeds the maximum allowed size",
+		http.StatusRequestEntityTooLarge,
+		map[string]interface{}{
+			"file_size":     size,
+			"max_file_size": maxSize,
+		},
+	)
+}
+
+func NewQuotaExceededError(details map[string]interface{}) *AppError {
+	return NewErrorWithDetails(
+		ErrorCodeQuotaExceeded,
+		"Media storage quota exceeded",
+		http.StatusForbidden,
+		details,
+	)
+}
+
+func NewShareLinkNotFoundError() *AppError {
+	return NewError(
+		ErrorCodeShareLinkNotFound,
+		"Share link not found",
+		http.StatusNotFound,
+	)
+}
+
+func NewShareLinkExpiredError(reason string) *AppError {
+	return NewErrorWithDetails(
+		ErrorCodeShareLinkExpired,
+		"Share link is no longer available",
+		http.StatusGone,
+		map[string]interface{}{
+			"reason": reason,
+		},
+	)
+}
+
+func NewSharePasswordError(message string) *AppError {
+	return NewError(
+		ErrorCodeSharePassword,
+		message,
+		http.StatusUnauthorized,
+	)
+}
+
 func NewDownloadError(err error) *AppError {
 	return NewError(
 		ErrorCodeDownloadFailed,
@@ -6,6 +6,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+
+	"github.com/denisAlshanov/stPlaner/internal/models"
 )
 
 type contextKey string
@@ -15,6 +17,7 @@ type Fields = logrus.Fields
 const (
 	CorrelationIDKey contextKey = "correlation_id"
 	RequestIDKey     contextKey = "request_id"
+	AuditActorKey    contextKey = "audit_actor"
 )
 
 var logger *logrus.Logger
@@ -74,6 +77,22 @@ func GetRequestID(ctx context.Context) string {
 	return ""
 }
 
+func WithAuditActor(ctx context.Context, actor *models.AuditActor) context.Context {
+	return context.WithValue(ctx, AuditActorKey, actor)
+}
+
+// GetAuditActor returns who is making the changes of a request. Handlers that pass
+// the gin context itself only expose values stored with c.Set, so those are checked too.
+func GetAuditActor(ctx context.Context) *models.AuditActor {
+	if actor, ok := ctx.Value(AuditActorKey).(*models.AuditActor); ok {
+		return actor
+	}
+	if actor, ok := ctx.Value(string(AuditActorKey)).(*models.AuditActor); ok {
+		return actor
+	}
+	return nil
+}
+
 func GenerateCorrelationID() string {
 	return uuid.New().String()
 }
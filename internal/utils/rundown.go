@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxBlockTitleLength is the longest block title, in characters
+const MaxBlockTitleLength = 255
+
+// RenderRundownTitle fills in the placeholders of a rundown template's title pattern:
+// {show} is the show name, {title} the event title or else the show name, {date} and
+// {time} the event start in UTC and {episode} the event's number in its show. Unknown
+// placeholders are kept as they are.
+func RenderRundownTitle(pattern, showName string, eventTitle *string, start time.Time, episode int) string {
+	title := showName
+	if eventTitle != nil && strings.TrimSpace(*eventTitle) != "" {
+		title = *eventTitle
+	}
+
+	replacer := strings.NewReplacer(
+		"{show}", showName,
+		"{title}", title,
+		"{date}", start.UTC().Format("2006-01-02"),
+		"{time}", start.UTC().Format("15:04"),
+		"{episode}", strconv.Itoa(episode),
+	)
+	rendered := strings.TrimSpace(replacer.Replace(pattern))
+
+	if utf8.RuneCountInString(rendered) > MaxBlockTitleLength {
+		rendered = string([]rune(rendered)[:MaxBlockTitleLength])
+	}
+	return rendered
+}
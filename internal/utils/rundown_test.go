@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestRenderRundownTitle(t *testing.T) {
+	start := time.Date(2026, 3, 5, 19, 30, 0, 0, time.FixedZone("CET", 3600))
+	eventTitle := "Spring special"
+	blank := "  "
+
+	testCases := []struct {
+		name       string
+		pattern    string
+		eventTitle *string
+		expected   string
+	}{
+		{"Plain title", "Intro", nil, "Intro"},
+		{"Show and episode", "{show} #{episode}", nil, "Late Talk #12"},
+		{"Event title", "Interview: {title}", &eventTitle, "Interview: Spring special"},
+		{"Title falls back to show", "Interview: {title}", &blank, "Interview: Late Talk"},
+		{"Date and time in UTC", "Q&A {date} {time}", nil, "Q&A 2026-03-05 18:30"},
+		{"Unknown placeholder", "{guest} joins", nil, "{guest} joins"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := RenderRundownTitle(tc.pattern, "Late Talk", tc.eventTitle, start, 12)
+			if result != tc.expected {
+				t.Errorf("RenderRundownTitle(%q) = %q, expected %q", tc.pattern, result, tc.expected)
+			}
+		})
+	}
+
+	long := RenderRundownTitle(strings.Repeat("ж", 200)+"{show}", strings.Repeat("ж", 100), nil, start, 1)
+	if utf8.RuneCountInString(long) != MaxBlockTitleLength {
+		t.Errorf("long title has %d characters, expected %d", utf8.RuneCountInString(long), MaxBlockTitleLength)
+	}
+}